@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const defaultExportPageSize = 500
+
+// exportKind is a collection export can page through: its list RPC, the
+// timestamp the range flags filter on, and its columns in output order.
+type exportKind struct {
+	method    string
+	timeField string
+	afterKey  string
+	beforeKey string
+	filters   []string
+	columns   []string
+}
+
+var exportKinds = map[string]exportKind{
+	"attempts": {
+		method:    rpccontract.MethodListPromptAttempts,
+		timeField: "created_at",
+		afterKey:  "created_after",
+		beforeKey: "created_before",
+		filters:   []string{"run_id", "workflow", "agent_id", "model", "outcome", "prompt_version"},
+		columns: []string{
+			"id", "project", "run_id", "attempt_number", "workflow", "agent_id", "provider_type", "provider", "model",
+			"prompt_version", "prompt_hash", "outcome", "error_type", "error_message", "tokens_in", "tokens_out",
+			"cost_usd", "latency_ms", "quality_score", "metadata", "created_at",
+		},
+	},
+	"runs": {
+		method:    rpccontract.MethodListRuns,
+		timeField: "started_at",
+		afterKey:  "started_after",
+		beforeKey: "started_before",
+		filters:   []string{"task_id", "workflow", "agent_id", "status", "prompt_version"},
+		columns: []string{
+			"id", "external_id", "name", "description", "project", "task_id", "workflow", "agent_id", "prompt_version", "model_policy", "status", "max_retries",
+			"total_attempts", "success_attempts", "failed_attempts", "total_tokens_in", "total_tokens_out",
+			"total_cost_usd", "duration_ms", "last_error", "metadata", "started_at", "finished_at", "starred", "starred_at",
+		},
+	},
+}
+
+// runExport writes runs or prompt attempts to CSV, JSONL or Parquet, newest
+// first. It
+// pages backwards through the list RPC by timestamp: each page asks for rows
+// at or before the oldest timestamp seen so far and skips the IDs already
+// written at that timestamp, so rows sharing a timestamp are neither lost
+// nor repeated. callTimeout applies to each page.
+func runExport(ctx context.Context, conn grpc.ClientConnInterface, args []string, callTimeout time.Duration) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	kindName := flags.String("kind", "", "required: attempts or runs")
+	format := flags.String("format", "csv", "csv, jsonl or parquet")
+	outPath := flags.String("out", "", "output file (default stdout)")
+	columnsFlag := flags.String("columns", "", "optional comma-separated columns (default all)")
+	since := flags.String("since", "", "optional RFC3339; oldest created_at (attempts) or started_at (runs) to include")
+	until := flags.String("until", "", "optional RFC3339; newest timestamp to include")
+	pageSize := flags.Int64("page-size", defaultExportPageSize, "rows per list call")
+	includeArchived := flags.Bool("include-archived", false, "also export attempts in cold storage")
+	filterValues := map[string]*string{}
+	for _, name := range []string{"run_id", "task_id", "workflow", "agent_id", "model", "outcome", "status", "prompt_version"} {
+		filterValues[name] = flags.String(strings.ReplaceAll(name, "_", "-"), "", "optional filter")
+	}
+	_ = flags.Parse(args)
+
+	kind, ok := exportKinds[*kindName]
+	if !ok {
+		log.Fatalf("--kind must be attempts or runs")
+	}
+	if *format != "csv" && *format != "jsonl" && *format != "parquet" {
+		log.Fatalf("--format must be csv, jsonl or parquet")
+	}
+	if *pageSize <= 0 {
+		log.Fatalf("--page-size must be positive")
+	}
+	if *includeArchived && *kindName != "attempts" {
+		log.Fatalf("--include-archived applies to attempts only")
+	}
+	columns := kind.columns
+	if strings.TrimSpace(*columnsFlag) != "" {
+		columns = nil
+		for _, column := range strings.Split(*columnsFlag, ",") {
+			column = strings.TrimSpace(column)
+			if !slices.Contains(kind.columns, column) {
+				log.Fatalf("unknown %s column %q; expected one of %s", *kindName, column, strings.Join(kind.columns, ","))
+			}
+			columns = append(columns, column)
+		}
+	}
+	for _, bound := range []string{*since, *until} {
+		if bound == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, bound); err != nil {
+			log.Fatalf("--since and --until must be RFC3339: %v", err)
+		}
+	}
+	for name, value := range filterValues {
+		if *value != "" && !slices.Contains(kind.filters, name) {
+			log.Fatalf("--%s does not apply to %s", strings.ReplaceAll(name, "_", "-"), *kindName)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("open %s: %v", *outPath, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+	writer := newExportWriter(buffered, *format, columns)
+
+	request := map[string]any{}
+	for _, name := range kind.filters {
+		if value := strings.TrimSpace(*filterValues[name]); value != "" {
+			request[name] = value
+		}
+	}
+	if *since != "" {
+		request[kind.afterKey] = *since
+	}
+	if *includeArchived {
+		request["include_archived"] = true
+	}
+	before := *until
+	var boundary time.Time
+	written := 0
+	// seen holds the IDs already written at boundary, which the next page
+	// returns again because its before bound is inclusive; the page's limit
+	// grows by as many so it still makes progress.
+	seen := map[string]bool{}
+	// A page the server clamped can come back short without being the
+	// last, so only a short page without warnings ends the export.
+	warned := map[string]bool{}
+	for {
+		if before != "" {
+			request[kind.beforeKey] = before
+		}
+		limit := *pageSize + int64(len(seen))
+		request["limit"] = limit
+		rows, warnings, err := exportPage(ctx, conn, kind.method, request, callTimeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, warning := range warnings {
+			if !warned[warning] {
+				warned[warning] = true
+				fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+			}
+		}
+		previous := boundary
+		times := make([]time.Time, len(rows))
+		added := 0
+		for i, row := range rows {
+			id, _ := row["id"].(string)
+			at, err := time.Parse(time.RFC3339Nano, fmt.Sprint(row[kind.timeField]))
+			if err != nil {
+				log.Fatalf("row %s has no valid %s; cannot page past it", id, kind.timeField)
+			}
+			times[i] = at
+			if boundary.IsZero() || at.Before(boundary) {
+				boundary = at
+			}
+			if seen[id] {
+				continue
+			}
+			if err := writer.write(row); err != nil {
+				log.Fatalf("write export: %v", err)
+			}
+			added++
+			written++
+		}
+		if (int64(len(rows)) < limit && len(warnings) == 0) || added == 0 {
+			break
+		}
+		if !boundary.Equal(previous) {
+			seen = map[string]bool{}
+		}
+		for i, row := range rows {
+			if times[i].Equal(boundary) {
+				id, _ := row["id"].(string)
+				seen[id] = true
+			}
+		}
+		before = boundary.Format(time.RFC3339Nano)
+	}
+	if err := writer.flush(); err != nil {
+		log.Fatalf("write export: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		log.Fatalf("write export: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d %s\n", written, *kindName)
+}
+
+func exportPage(ctx context.Context, conn grpc.ClientConnInterface, method string, request map[string]any, callTimeout time.Duration) ([]map[string]any, []string, error) {
+	payload, err := structpb.NewStruct(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request build error: %w", err)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	response := &structpb.ListValue{}
+	warnings, err := callWithWarnings(callCtx, conn, method, payload, response)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]map[string]any, 0, len(response.GetValues()))
+	for _, value := range response.GetValues() {
+		rows = append(rows, value.GetStructValue().AsMap())
+	}
+	return rows, warnings, nil
+}
+
+// exportWriter writes rows restricted to columns, as CSV with a header row,
+// as one JSON object per line or as a Parquet file.
+type exportWriter struct {
+	columns []string
+	csv     *csv.Writer
+	jsonl   *json.Encoder
+	parquet *parquetWriter
+}
+
+func newExportWriter(out io.Writer, format string, columns []string) *exportWriter {
+	writer := &exportWriter{columns: columns}
+	switch format {
+	case "jsonl":
+		writer.jsonl = json.NewEncoder(out)
+		return writer
+	case "parquet":
+		writer.parquet = newParquetWriter(out, columns)
+		return writer
+	}
+	writer.csv = csv.NewWriter(out)
+	_ = writer.csv.Write(columns)
+	return writer
+}
+
+func (w *exportWriter) write(row map[string]any) error {
+	if w.parquet != nil {
+		return w.parquet.write(row)
+	}
+	if w.jsonl != nil {
+		selected := make(map[string]any, len(w.columns))
+		for _, column := range w.columns {
+			selected[column] = row[column]
+		}
+		return w.jsonl.Encode(selected)
+	}
+	record := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		record[i] = exportCell(row[column])
+	}
+	return w.csv.Write(record)
+}
+
+func (w *exportWriter) flush() error {
+	if w.parquet != nil {
+		return w.parquet.close()
+	}
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// exportCell renders a value for CSV: numbers without exponents, and maps
+// and lists such as metadata as JSON.
+func exportCell(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	default:
+		encoded, _ := json.Marshal(typed)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// importRecordIndex finds the record an ImportTelemetry validation error is
+// about, such as "attempts[3]: run not found".
+var importRecordIndex = regexp.MustCompile(`(?:attempts|benchmarks)\[(\d+)\]: (.*)$`)
+
+// runImport loads a JSONL file of historical prompt attempts or benchmarks,
+// one record per line with the RPC's field names, in ImportTelemetry
+// batches. created_at may be RFC3339 or Unix seconds or milliseconds, and a
+// metadata object's values are sent as strings. Attempts without a run_id go
+// to synthetic runs for --import-id; rerunning with the same ID and
+// --from-line resumes into the same runs after a rejected line.
+func runImport(ctx context.Context, conn grpc.ClientConnInterface, args []string, callTimeout time.Duration, project string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	path := flags.String("file", "", "required JSONL file, - for stdin")
+	kind := flags.String("kind", "attempts", "attempts or benchmarks")
+	importID := flags.String("import-id", "", "optional; groups synthetic runs across calls (default a new ID)")
+	workflow := flags.String("workflow", "", "optional workflow for attempts without one")
+	agentID := flags.String("agent-id", "", "optional agent_id for attempts without one")
+	fromLine := flags.Int("from-line", 1, "first line to import")
+	batchSize := flags.Int("batch-size", 200, "records per call, at most 500")
+	_ = flags.Parse(args)
+
+	if *path == "" {
+		log.Fatalf("import requires --file")
+	}
+	if *kind != "attempts" && *kind != "benchmarks" {
+		log.Fatalf("--kind must be attempts or benchmarks")
+	}
+	if *batchSize <= 0 || *batchSize > 500 {
+		log.Fatalf("--batch-size must be between 1 and 500")
+	}
+	if *fromLine < 1 {
+		log.Fatalf("--from-line must be at least 1")
+	}
+	if *importID == "" {
+		raw := make([]byte, 6)
+		if _, err := rand.Read(raw); err != nil {
+			log.Fatalf("generate import id: %v", err)
+		}
+		*importID = "imp_" + time.Now().UTC().Format("20060102T150405") + "_" + hex.EncodeToString(raw)
+	}
+	input := os.Stdin
+	if *path != "-" {
+		file, err := os.Open(*path)
+		if err != nil {
+			log.Fatalf("open import file: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	counts := map[string]int{"attempts": 0, "benchmarks": 0}
+	syntheticRuns := []string{}
+	batch := []any{}
+	batchLines := []int{}
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		payload := map[string]any{"import_id": *importID, *kind: batch}
+		if project != "" {
+			payload["project"] = project
+		}
+		request, err := structpb.NewStruct(payload)
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		response := &structpb.Struct{}
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		err = call(callCtx, conn, rpccontract.MethodImportTelemetry, request, response)
+		cancel()
+		if err != nil {
+			if match := importRecordIndex.FindStringSubmatch(status.Convert(err).Message()); match != nil {
+				if index, _ := strconv.Atoi(match[1]); index < len(batchLines) {
+					log.Fatalf("%s:%d: %s (imported %d %s before it; fix the line and rerun with --import-id %s --from-line %d)",
+						*path, batchLines[index], match[2], counts[*kind], *kind, *importID, batchLines[0])
+				}
+			}
+			log.Fatalf("%v (imported %d %s before line %d)", err, counts[*kind], *kind, batchLines[0])
+		}
+		for name := range counts {
+			counts[name] += int(response.GetFields()[name].GetNumberValue())
+		}
+		for _, run := range response.GetFields()["synthetic_runs"].GetListValue().GetValues() {
+			if id := run.GetStringValue(); !slices.Contains(syntheticRuns, id) {
+				syntheticRuns = append(syntheticRuns, id)
+			}
+		}
+		batch, batchLines = batch[:0], batchLines[:0]
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || line < *fromLine {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			log.Fatalf("%s:%d: invalid JSON: %v", *path, line, err)
+		}
+		if err := normalizeImportRecord(record); err != nil {
+			log.Fatalf("%s:%d: %v", *path, line, err)
+		}
+		if *kind == "attempts" {
+			if value, _ := record["workflow"].(string); value == "" && *workflow != "" {
+				record["workflow"] = *workflow
+			}
+			if value, _ := record["agent_id"].(string); value == "" && *agentID != "" {
+				record["agent_id"] = *agentID
+			}
+		}
+		batch = append(batch, record)
+		batchLines = append(batchLines, line)
+		if len(batch) == *batchSize {
+			send()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("read import file: %v", err)
+	}
+	send()
+	slices.Sort(syntheticRuns)
+	printJSON(map[string]any{
+		"import_id":      *importID,
+		"attempts":       counts["attempts"],
+		"benchmarks":     counts["benchmarks"],
+		"synthetic_runs": syntheticRuns,
+	})
+}
+
+// normalizeImportRecord converts what other tools commonly export into the
+// RPC's types: numbers written as strings, Unix timestamps, and metadata
+// values that are not strings.
+func normalizeImportRecord(record map[string]any) error {
+	for field, value := range record {
+		switch {
+		case value == nil:
+			delete(record, field)
+		case slices.Contains(ingestIntFields, field):
+			number, err := toNumber(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			record[field] = int64(number)
+		case slices.Contains(ingestFloatFields, field):
+			number, err := toNumber(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			record[field] = number
+		case field == "created_at":
+			createdAt, err := toTimestamp(value)
+			if err != nil {
+				return fmt.Errorf("created_at: %w", err)
+			}
+			record[field] = createdAt
+		case field == "metadata":
+			labels, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("metadata must be an object")
+			}
+			for key, label := range labels {
+				if text, isText := label.(string); isText {
+					labels[key] = text
+					continue
+				}
+				encoded, _ := json.Marshal(label)
+				labels[key] = string(encoded)
+			}
+		}
+	}
+	return nil
+}
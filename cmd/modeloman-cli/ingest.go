@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ingestMapping says how an agent's log lines become run events and prompt
+// attempts. Fields maps a request field to a dot-separated path in the log
+// entry; request fields without a mapping are read from the key of the same
+// name. Entries whose kind_field value is in attempt_kinds become attempts,
+// those in skip_kinds are ignored, and everything else becomes an event.
+type ingestMapping struct {
+	KindField    string            `json:"kind_field"`
+	AttemptKinds []string          `json:"attempt_kinds"`
+	SkipKinds    []string          `json:"skip_kinds"`
+	Fields       map[string]string `json:"fields"`
+	Defaults     map[string]any    `json:"defaults"`
+}
+
+var (
+	ingestEventFields   = []string{"run_id", "event_type", "level", "message", "created_at", "data"}
+	ingestAttemptFields = []string{
+		"run_id", "attempt_number", "workflow", "agent_id", "provider_type", "provider", "model",
+		"prompt_version", "prompt_hash", "outcome", "error_type", "error_message",
+		"tokens_in", "tokens_out", "cost_usd", "latency_ms", "quality_score",
+	}
+	ingestIntFields   = []string{"attempt_number", "tokens_in", "tokens_out", "latency_ms"}
+	ingestFloatFields = []string{"cost_usd", "quality_score"}
+)
+
+func loadIngestMapping(path string) (ingestMapping, error) {
+	mapping := ingestMapping{}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return mapping, err
+		}
+		if err := json.Unmarshal(raw, &mapping); err != nil {
+			return mapping, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	for field := range mapping.Fields {
+		if !slices.Contains(ingestEventFields, field) && !slices.Contains(ingestAttemptFields, field) {
+			return mapping, fmt.Errorf("unknown mapped field %q", field)
+		}
+	}
+	for field := range mapping.Defaults {
+		if !slices.Contains(ingestEventFields, field) && !slices.Contains(ingestAttemptFields, field) {
+			return mapping, fmt.Errorf("unknown default field %q", field)
+		}
+	}
+	return mapping, nil
+}
+
+// lookup returns field's value in entry, following its mapped path.
+func (m ingestMapping) lookup(entry map[string]any, field string) (any, bool) {
+	path := field
+	if mapped := m.Fields[field]; mapped != "" {
+		path = mapped
+	}
+	var value any = entry
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	if value == nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (m ingestMapping) kind(entry map[string]any) string {
+	if m.KindField == "" {
+		return ""
+	}
+	value, _ := m.lookup(entry, m.KindField)
+	kind, _ := value.(string)
+	if kind == "" && value != nil {
+		kind = fmt.Sprint(value)
+	}
+	return kind
+}
+
+// build copies fields from entry into a request payload, falling back to
+// the mapping's defaults. Numeric fields logged as strings are converted.
+func (m ingestMapping) build(entry map[string]any, fields []string) (map[string]any, error) {
+	payload := map[string]any{}
+	for _, field := range fields {
+		value, ok := m.lookup(entry, field)
+		if !ok {
+			if value, ok = m.Defaults[field]; !ok {
+				continue
+			}
+		}
+		switch {
+		case slices.Contains(ingestIntFields, field):
+			number, err := toNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			payload[field] = int64(number)
+		case slices.Contains(ingestFloatFields, field):
+			number, err := toNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			payload[field] = number
+		case field == "created_at":
+			createdAt, err := toTimestamp(value)
+			if err != nil {
+				return nil, fmt.Errorf("created_at: %w", err)
+			}
+			payload[field] = createdAt
+		case field == "data":
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("data: %w", err)
+			}
+			payload["data_json"] = string(encoded)
+		default:
+			text, isText := value.(string)
+			if !isText {
+				text = fmt.Sprint(value)
+			}
+			payload[field] = text
+		}
+	}
+	return payload, nil
+}
+
+func toNumber(value any) (float64, error) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(typed), 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %T", value)
+}
+
+// toTimestamp accepts RFC3339 strings and Unix times in seconds or
+// milliseconds.
+func toTimestamp(value any) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(typed))
+		if err != nil {
+			return "", errors.New("expected RFC3339")
+		}
+		return parsed.UTC().Format(time.RFC3339Nano), nil
+	case float64:
+		if typed > 1e12 {
+			return time.UnixMilli(int64(typed)).UTC().Format(time.RFC3339Nano), nil
+		}
+		return time.Unix(int64(typed), 0).UTC().Format(time.RFC3339Nano), nil
+	}
+	return "", fmt.Errorf("expected a timestamp, got %T", value)
+}
+
+// errTailEOF means the tailer has read every complete line written so far.
+var errTailEOF = errors.New("end of log")
+
+// logTailer reads complete lines from a growing file and follows it across
+// truncation and rotation by rename.
+type logTailer struct {
+	path     string
+	file     *os.File
+	reader   *bufio.Reader
+	partial  []byte
+	offset   int64
+	draining bool
+}
+
+func openTailer(path string, offset int64, fromEnd bool) (*logTailer, error) {
+	t := &logTailer{path: path}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	info, err := t.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fromEnd {
+		offset = info.Size()
+	}
+	if offset > info.Size() {
+		// The file was truncated or replaced since the offset was saved.
+		offset = 0
+	}
+	if _, err := t.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	t.offset = offset
+	return t, nil
+}
+
+func (t *logTailer) open() error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+	t.file = file
+	t.reader = bufio.NewReaderSize(file, 64*1024)
+	t.partial = nil
+	t.offset = 0
+	return nil
+}
+
+// next returns the next complete line without its newline, or errTailEOF.
+// A line still being written is held back until its newline arrives.
+func (t *logTailer) next() ([]byte, error) {
+	chunk, err := t.reader.ReadBytes('\n')
+	t.partial = append(t.partial, chunk...)
+	if errors.Is(err, io.EOF) {
+		return nil, errTailEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	line := t.partial
+	t.partial = nil
+	t.offset += int64(len(line))
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// follow is called at errTailEOF. It reopens the path when the file was
+// truncated or renamed away, reading the old file once more first so lines
+// written just before a rotation are not lost. It reports whether it
+// switched files.
+func (t *logTailer) follow() (bool, error) {
+	current, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	latest, err := os.Stat(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Between the rename and the new file being created.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if os.SameFile(current, latest) {
+		if latest.Size() < t.offset+int64(len(t.partial)) {
+			if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+				return false, err
+			}
+			t.reader.Reset(t.file)
+			t.partial = nil
+			t.offset = 0
+			return true, nil
+		}
+		return false, nil
+	}
+	if !t.draining {
+		t.draining = true
+		return false, nil
+	}
+	t.draining = false
+	return true, t.open()
+}
+
+func (t *logTailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+}
+
+// runIngest tails a structured agent log and records its entries as run
+// events and prompt attempts, for agents that cannot call the API
+// themselves. Events are sent in RecordRunEvents batches whenever the tailer
+// catches up; attempts are sent as they are read. With --offset-file, the
+// read position is saved after every flush and a restart resumes there, so
+// entries are recorded at least once.
+func runIngest(ctx context.Context, conn grpc.ClientConnInterface, args []string, callTimeout time.Duration) {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	path := flags.String("file", "", "required JSONL log file to tail")
+	mappingPath := flags.String("mapping", "", "optional JSON field mapping (see docs/log-ingestion.md)")
+	runID := flags.String("run-id", "", "optional run_id for entries without one")
+	workflow := flags.String("workflow", "", "optional; with --agent-id, start a run for entries without a run_id and finish it on exit")
+	agentID := flags.String("agent-id", "", "optional; see --workflow")
+	fromStart := flags.Bool("from-start", false, "read the existing file instead of only new lines")
+	offsetPath := flags.String("offset-file", "", "optional file keeping the read position across restarts")
+	batchSize := flags.Int("batch-size", 200, "events per call, at most 500")
+	poll := flags.Duration("poll", 500*time.Millisecond, "how often to check the file for new lines")
+	_ = flags.Parse(args)
+
+	if *path == "" {
+		log.Fatalf("ingest requires --file")
+	}
+	if *batchSize <= 0 || *batchSize > 500 {
+		log.Fatalf("--batch-size must be between 1 and 500")
+	}
+	if (*workflow == "") != (*agentID == "") {
+		log.Fatalf("--workflow and --agent-id must be set together")
+	}
+	if *workflow != "" && *runID != "" {
+		log.Fatalf("--run-id cannot be combined with --workflow and --agent-id")
+	}
+	mapping, err := loadIngestMapping(*mappingPath)
+	if err != nil {
+		log.Fatalf("load mapping: %v", err)
+	}
+
+	offset, resume := int64(0), false
+	if *offsetPath != "" {
+		offset, resume = readIngestOffset(*offsetPath)
+	}
+	tailer, err := openTailer(*path, offset, !*fromStart && !resume)
+	if err != nil {
+		log.Fatalf("open log file: %v", err)
+	}
+	defer tailer.close()
+
+	// Keep calls going after an interrupt so the last flush and finish-run
+	// still reach the server.
+	callCtx := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.WithoutCancel(ctx), callTimeout)
+	}
+
+	defaultRunID := *runID
+	if *workflow != "" {
+		request, err := structpb.NewStruct(map[string]any{"workflow": *workflow, "agent_id": *agentID})
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		response := &structpb.Struct{}
+		startCtx, cancel := callCtx()
+		invoke(startCtx, conn, rpccontract.MethodStartRun, request, response)
+		cancel()
+		defaultRunID = response.GetFields()["id"].GetStringValue()
+		log.Printf("ingest: started run %s", defaultRunID)
+	}
+
+	counts := map[string]int{"events": 0, "attempts": 0, "skipped": 0}
+	attemptNumbers := map[string]int64{}
+	events := []any{}
+	savedOffset := tailer.offset
+
+	// skip drops what the server refuses outright. Anything else is fatal,
+	// leaving the saved offset at the last flush for a restart.
+	skip := func(err error) {
+		switch status.Code(err) {
+		case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition:
+			log.Printf("ingest: skipped: %v", err)
+			counts["skipped"]++
+			return
+		}
+		log.Fatal(err)
+	}
+	sendEvents := func(batch []any) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		request, err := structpb.NewStruct(map[string]any{"events": batch})
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		response := &structpb.Struct{}
+		sendCtx, cancel := callCtx()
+		defer cancel()
+		if err := call(sendCtx, conn, rpccontract.MethodRecordRunEvents, request, response); err != nil {
+			return err
+		}
+		counts["events"] += int(response.GetFields()["recorded"].GetNumberValue())
+		return nil
+	}
+	flush := func() {
+		if err := sendEvents(events); err != nil {
+			switch status.Code(err) {
+			case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition:
+				// One bad event fails the whole batch; send them one by one
+				// so only the bad ones are dropped.
+				for _, event := range events {
+					if err := sendEvents([]any{event}); err != nil {
+						skip(err)
+					}
+				}
+			default:
+				log.Fatal(err)
+			}
+		}
+		events = events[:0]
+		if *offsetPath != "" && tailer.offset != savedOffset {
+			writeIngestOffset(*offsetPath, tailer.offset)
+			savedOffset = tailer.offset
+		}
+	}
+	handle := func(line []byte) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("ingest: skipped non-JSON line: %v", err)
+			counts["skipped"]++
+			return
+		}
+		kind := mapping.kind(entry)
+		if kind != "" && slices.Contains(mapping.SkipKinds, kind) {
+			return
+		}
+		isAttempt := kind != "" && slices.Contains(mapping.AttemptKinds, kind)
+		fields := ingestEventFields
+		if isAttempt {
+			fields = ingestAttemptFields
+		}
+		payload, err := mapping.build(entry, fields)
+		if err != nil {
+			log.Printf("ingest: skipped entry: %v", err)
+			counts["skipped"]++
+			return
+		}
+		if id, _ := payload["run_id"].(string); id == "" {
+			if defaultRunID == "" {
+				counts["skipped"]++
+				return
+			}
+			payload["run_id"] = defaultRunID
+		}
+		if !isAttempt {
+			if _, ok := payload["event_type"]; !ok {
+				payload["event_type"] = cmp.Or(kind, "log")
+			}
+			if _, ok := payload["data_json"]; !ok {
+				payload["data_json"] = string(line)
+			}
+			events = append(events, payload)
+			if len(events) == *batchSize {
+				flush()
+			}
+			return
+		}
+
+		run := payload["run_id"].(string)
+		if number, ok := payload["attempt_number"].(int64); ok {
+			attemptNumbers[run] = max(attemptNumbers[run], number)
+		} else {
+			attemptNumbers[run]++
+			payload["attempt_number"] = attemptNumbers[run]
+		}
+		if _, ok := payload["outcome"]; !ok {
+			payload["outcome"] = "success"
+		}
+		request, err := structpb.NewStruct(payload)
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		attemptCtx, cancel := callCtx()
+		err = call(attemptCtx, conn, rpccontract.MethodRecordPromptAttempt, request, &structpb.Struct{})
+		cancel()
+		if err != nil {
+			skip(err)
+			return
+		}
+		counts["attempts"]++
+	}
+
+	for {
+		line, err := tailer.next()
+		if err == nil {
+			handle(line)
+			continue
+		}
+		if !errors.Is(err, errTailEOF) {
+			log.Fatalf("read log file: %v", err)
+		}
+		flush()
+		if ctx.Err() != nil {
+			break
+		}
+		switched, err := tailer.follow()
+		if err != nil {
+			log.Fatalf("follow log file: %v", err)
+		}
+		if switched {
+			log.Printf("ingest: %s was rotated or truncated; reading from the start", *path)
+			continue
+		}
+		if tailer.draining {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(*poll):
+		}
+	}
+
+	if *workflow != "" {
+		request, err := structpb.NewStruct(map[string]any{"run_id": defaultRunID, "status": "completed"})
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		finishCtx, cancel := callCtx()
+		invoke(finishCtx, conn, rpccontract.MethodFinishRun, request, &structpb.Struct{})
+		cancel()
+	}
+	printJSON(counts)
+}
+
+// readIngestOffset returns the saved read position and whether there was one.
+func readIngestOffset(path string) (int64, bool) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false
+	}
+	if err != nil {
+		log.Fatalf("read offset file: %v", err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil || offset < 0 {
+		log.Fatalf("offset file %s does not hold a byte offset", path)
+	}
+	return offset, true
+}
+
+func writeIngestOffset(path string, offset int64) {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(strconv.FormatInt(offset, 10)+"\n"), 0o600); err != nil {
+		log.Fatalf("write offset file: %v", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		log.Fatalf("write offset file: %v", err)
+	}
+}
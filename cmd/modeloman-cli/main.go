@@ -1,16 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/tlsconfig"
+	httpx "github.com/bcrosbie/modeloman/internal/transport/http"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -26,6 +37,14 @@ func main() {
 	base := flag.NewFlagSet("modeloman-cli", flag.ExitOnError)
 	addr := base.String("addr", "127.0.0.1:50051", "gRPC address")
 	token := base.String("token", os.Getenv("AUTH_TOKEN"), "optional auth token or agent API key")
+	project := base.String("project", os.Getenv("MODELOMAN_PROJECT"), "optional project for project-scoped RPCs")
+	useTLS := base.Bool("tls", false, "connect with TLS (implied by the other --tls-* flags)")
+	tlsCA := base.String("tls-ca", os.Getenv("MODELOMAN_TLS_CA"), "optional CA bundle to verify the server (default: system roots)")
+	tlsCert := base.String("tls-cert", os.Getenv("MODELOMAN_TLS_CERT"), "optional client certificate for mTLS")
+	tlsKey := base.String("tls-key", os.Getenv("MODELOMAN_TLS_KEY"), "optional client key for mTLS")
+	tlsServerName := base.String("tls-server-name", "", "optional server name override for certificate verification")
+	timeout := base.Duration("timeout", 0, "RPC deadline (default per command: 3s for health, 30s for lists, 2m for export-state and prune, 7s otherwise; per call for ingest, export, import and watch-events)")
+	retries := base.Int("retries", defaultRetries, "retries for Unavailable and ResourceExhausted responses; 0 disables")
 	_ = base.Parse(os.Args[1:])
 
 	args := base.Args()
@@ -37,23 +56,51 @@ func main() {
 	command := args[0]
 	commandArgs := args[1:]
 
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	transportCreds := insecure.NewCredentials()
+	tlsOpts := tlsconfig.ClientOptions{CAFile: *tlsCA, CertFile: *tlsCert, KeyFile: *tlsKey, ServerName: *tlsServerName}
+	if *useTLS || tlsOpts.Configured() {
+		tlsCfg, err := tlsconfig.Client(tlsOpts)
+		if err != nil {
+			log.Fatalf("tls error: %v", err)
+		}
+		transportCreds = credentials.NewTLS(tlsCfg)
+	}
+	conn, err := grpc.NewClient(*addr,
+		grpc.WithTransportCredentials(transportCreds),
+		grpc.WithUnaryInterceptor(retryInterceptor(*retries)),
+	)
 	if err != nil {
 		log.Fatalf("dial error: %v", err)
 	}
 	defer conn.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 7*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout(command, *timeout))
+	if command == "ingest" || command == "export" || command == "import" || command == "watch-events" {
+		// ingest, export, import and watch-events run until done or
+		// interrupted and apply the timeout per call.
+		cancel()
+		ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	}
 	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, rpccontract.APIVersionHeader, rpccontract.APIVersion)
 	if *token != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, "x-modeloman-token", *token)
 	}
+	if *project != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-modeloman-project", *project)
+	}
 
 	switch command {
 	case "health":
 		callStruct(ctx, conn, rpccontract.MethodGetHealth, &emptypb.Empty{})
+	case "api-info":
+		callStruct(ctx, conn, rpccontract.MethodGetAPIInfo, &emptypb.Empty{})
+	case "openapi":
+		runOpenAPI(commandArgs)
 	case "summary":
 		callStruct(ctx, conn, rpccontract.MethodGetSummary, &emptypb.Empty{})
+	case "export-state":
+		callStruct(ctx, conn, rpccontract.MethodExportState, &emptypb.Empty{})
 	case "telemetry-summary":
 		callStruct(ctx, conn, rpccontract.MethodGetTelemetrySummary, &emptypb.Empty{})
 	case "get-policy":
@@ -61,54 +108,136 @@ func main() {
 	case "list-policy-caps":
 		callList(ctx, conn, rpccontract.MethodListPolicyCaps, &emptypb.Empty{})
 	case "list-tasks":
-		callList(ctx, conn, rpccontract.MethodListTasks, &emptypb.Empty{})
+		runListTasks(ctx, conn, commandArgs)
 	case "list-runs":
 		runListRuns(ctx, conn, commandArgs)
+	case "get-run":
+		runGetRun(ctx, conn, commandArgs)
 	case "list-attempts":
 		runListAttempts(ctx, conn, commandArgs)
 	case "list-events":
 		runListEvents(ctx, conn, commandArgs)
 	case "leaderboard":
 		runLeaderboard(ctx, conn, commandArgs)
+	case "leaderboard-diff":
+		runLeaderboardDiff(ctx, conn, commandArgs)
+	case "recommend-model":
+		runRecommendModel(ctx, conn, commandArgs)
+	case "retry-report":
+		runRetryReport(ctx, conn, commandArgs)
 	case "create-task":
 		runCreateTask(ctx, conn, commandArgs)
+	case "delete-task":
+		runDeleteTask(ctx, conn, commandArgs)
 	case "start-run":
 		runStartRun(ctx, conn, commandArgs)
 	case "finish-run":
 		runFinishRun(ctx, conn, commandArgs)
+	case "cancel-run":
+		runCancelRun(ctx, conn, commandArgs)
+	case "star-run":
+		runStarRun(ctx, conn, commandArgs)
+	case "share-run":
+		runShareRun(ctx, conn, commandArgs)
 	case "record-attempt":
 		runRecordAttempt(ctx, conn, commandArgs)
 	case "record-event":
 		runRecordEvent(ctx, conn, commandArgs)
+	case "record-events":
+		runRecordEvents(ctx, conn, commandArgs)
 	case "set-policy":
 		runSetPolicy(ctx, conn, commandArgs)
 	case "upsert-policy-cap":
 		runUpsertPolicyCap(ctx, conn, commandArgs)
 	case "delete-policy-cap":
 		runDeletePolicyCap(ctx, conn, commandArgs)
+	case "list-policy-audit":
+		runListPolicyAudit(ctx, conn, commandArgs)
 	case "append-changelog":
 		runAppendChangelog(ctx, conn, commandArgs)
 	case "record-benchmark":
 		runRecordBenchmark(ctx, conn, commandArgs)
+	case "set-prompt-version":
+		runSetPromptVersion(ctx, conn, commandArgs)
+	case "rollback-prompt-version":
+		runRollbackPromptVersion(ctx, conn, commandArgs)
+	case "list-prompt-releases":
+		runListPromptReleases(ctx, conn, commandArgs)
+	case "record-artifact":
+		runRecordArtifact(ctx, conn, commandArgs)
+	case "get-artifact":
+		runGetArtifact(ctx, conn, commandArgs)
+	case "list-artifacts":
+		runListArtifacts(ctx, conn, commandArgs)
+	case "prune":
+		runPrune(ctx, conn, commandArgs)
+	case "ingest":
+		runIngest(ctx, conn, commandArgs, commandTimeout(command, *timeout))
+	case "export":
+		runExport(ctx, conn, commandArgs, commandTimeout(command, *timeout))
+	case "import":
+		runImport(ctx, conn, commandArgs, commandTimeout(command, *timeout), *project)
+	case "watch-events":
+		runWatchEvents(ctx, conn, commandArgs, commandTimeout(command, *timeout))
 	default:
 		usage()
 	}
 }
 
+const defaultCommandTimeout = 7 * time.Second
+
+// commandTimeouts overrides defaultCommandTimeout for commands whose calls
+// are much cheaper or costlier than a single write.
+var commandTimeouts = map[string]time.Duration{
+	"health":               3 * time.Second,
+	"api-info":             3 * time.Second,
+	"export-state":         2 * time.Minute,
+	"prune":                2 * time.Minute,
+	"record-events":        2 * time.Minute,
+	"list-tasks":           30 * time.Second,
+	"list-runs":            30 * time.Second,
+	"get-run":              30 * time.Second,
+	"list-attempts":        30 * time.Second,
+	"list-events":          30 * time.Second,
+	"list-policy-audit":    30 * time.Second,
+	"list-prompt-releases": 30 * time.Second,
+	"list-artifacts":       30 * time.Second,
+	"export":               30 * time.Second,
+	"import":               30 * time.Second,
+	"get-artifact":         30 * time.Second,
+	"leaderboard":          30 * time.Second,
+	"leaderboard-diff":     30 * time.Second,
+	"recommend-model":      30 * time.Second,
+	"retry-report":         30 * time.Second,
+}
+
+// commandTimeout is --timeout when set, else the command's default.
+func commandTimeout(command string, flagValue time.Duration) time.Duration {
+	if flagValue > 0 {
+		return flagValue
+	}
+	if timeout, ok := commandTimeouts[command]; ok {
+		return timeout
+	}
+	return defaultCommandTimeout
+}
+
 func runCreateTask(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("create-task", flag.ExitOnError)
 	title := flags.String("title", "", "required")
 	details := flags.String("details", "", "optional")
 	status := flags.String("status", "todo", "todo|in_progress|done|blocked")
+	externalID := flags.String("external-id", "", "optional caller ID, unique per project")
 	_ = flags.Parse(args)
 
 	if *title == "" {
 		log.Fatalf("create-task requires --title")
 	}
 	request, err := structpb.NewStruct(map[string]any{
-		"title":   *title,
-		"details": *details,
-		"status":  *status,
+		"title":       *title,
+		"details":     *details,
+		"status":      *status,
+		"external_id": *externalID,
 	})
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
@@ -124,6 +253,10 @@ func runStartRun(ctx context.Context, conn grpc.ClientConnInterface, args []stri
 	promptVersion := flags.String("prompt-version", "", "optional")
 	modelPolicy := flags.String("model-policy", "", "optional")
 	maxRetries := flags.Int64("max-retries", 0, "optional")
+	metadata := flags.String("metadata", "", "optional comma-separated key=value labels")
+	name := flags.String("name", "", "optional human-readable run name")
+	description := flags.String("description", "", "optional run description")
+	externalID := flags.String("external-id", "", "optional caller ID, unique per project")
 	_ = flags.Parse(args)
 
 	if *workflow == "" || *agentID == "" {
@@ -136,6 +269,10 @@ func runStartRun(ctx context.Context, conn grpc.ClientConnInterface, args []stri
 		"prompt_version": *promptVersion,
 		"model_policy":   *modelPolicy,
 		"max_retries":    *maxRetries,
+		"metadata":       parseKeyValues(*metadata),
+		"name":           *name,
+		"description":    *description,
+		"external_id":    *externalID,
 	})
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
@@ -164,6 +301,84 @@ func runFinishRun(ctx context.Context, conn grpc.ClientConnInterface, args []str
 	callStruct(ctx, conn, rpccontract.MethodFinishRun, request)
 }
 
+func runCancelRun(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("cancel-run", flag.ExitOnError)
+	runID := flags.String("run-id", "", "required")
+	reason := flags.String("reason", "", "optional, stored as the run's last_error")
+	_ = flags.Parse(args)
+
+	if *runID == "" {
+		log.Fatalf("cancel-run requires --run-id")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id": *runID,
+		"reason": *reason,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodCancelRun, request)
+}
+
+func runStarRun(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("star-run", flag.ExitOnError)
+	runID := flags.String("run-id", "", "required")
+	unstar := flags.Bool("unstar", false, "remove the star instead")
+	_ = flags.Parse(args)
+
+	if *runID == "" {
+		log.Fatalf("star-run requires --run-id")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id":  *runID,
+		"starred": !*unstar,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodStarRun, request)
+}
+
+func runShareRun(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("share-run", flag.ExitOnError)
+	runID := flags.String("run-id", "", "required")
+	ttlSeconds := flags.Int64("ttl-seconds", 0, "optional link lifetime (default 24h)")
+	_ = flags.Parse(args)
+
+	if *runID == "" {
+		log.Fatalf("share-run requires --run-id")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id":      *runID,
+		"ttl_seconds": *ttlSeconds,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodShareRun, request)
+}
+
+func runGetRun(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("get-run", flag.ExitOnError)
+	runID := flags.String("run-id", "", "required unless --external-id is given")
+	externalID := flags.String("external-id", "", "find the run by its external ID instead")
+	includeArchived := flags.Bool("include-archived", false, "also return attempts and events in cold storage")
+	_ = flags.Parse(args)
+
+	if *runID == "" && *externalID == "" {
+		log.Fatalf("get-run requires --run-id or --external-id")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id":           *runID,
+		"external_id":      *externalID,
+		"include_archived": *includeArchived,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodGetRun, request)
+}
+
 func runRecordAttempt(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("record-attempt", flag.ExitOnError)
 	runID := flags.String("run-id", "", "required")
@@ -183,6 +398,7 @@ func runRecordAttempt(ctx context.Context, conn grpc.ClientConnInterface, args [
 	costUSD := flags.Float64("cost-usd", 0, "optional")
 	latencyMS := flags.Int64("latency-ms", 0, "optional")
 	quality := flags.Float64("quality-score", 0, "optional")
+	metadata := flags.String("metadata", "", "optional comma-separated key=value labels")
 	_ = flags.Parse(args)
 
 	if *runID == "" || *model == "" {
@@ -206,6 +422,7 @@ func runRecordAttempt(ctx context.Context, conn grpc.ClientConnInterface, args [
 		"cost_usd":       *costUSD,
 		"latency_ms":     *latencyMS,
 		"quality_score":  *quality,
+		"metadata":       parseKeyValues(*metadata),
 	})
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
@@ -238,6 +455,82 @@ func runRecordEvent(ctx context.Context, conn grpc.ClientConnInterface, args []s
 	callStruct(ctx, conn, rpccontract.MethodRecordRunEvent, request)
 }
 
+// runRecordEvents sends a JSONL file of run events in RecordRunEvents
+// batches over one connection. Each line holds record-event's fields plus an
+// optional created_at; a "data" object is sent as data_json.
+func runRecordEvents(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("record-events", flag.ExitOnError)
+	path := flags.String("file", "", "required JSONL file, - for stdin")
+	runID := flags.String("run-id", "", "optional run_id for lines without one")
+	batchSize := flags.Int("batch-size", 200, "events per call, at most 500")
+	_ = flags.Parse(args)
+
+	if *path == "" {
+		log.Fatalf("record-events requires --file")
+	}
+	if *batchSize <= 0 || *batchSize > 500 {
+		log.Fatalf("--batch-size must be between 1 and 500")
+	}
+	input := os.Stdin
+	if *path != "-" {
+		file, err := os.Open(*path)
+		if err != nil {
+			log.Fatalf("open events file: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	recorded := 0
+	batch := []any{}
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		request, err := structpb.NewStruct(map[string]any{"events": batch})
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		response := &structpb.Struct{}
+		invoke(ctx, conn, rpccontract.MethodRecordRunEvents, request, response)
+		recorded += int(response.GetFields()["recorded"].GetNumberValue())
+		batch = batch[:0]
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var event map[string]any
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			log.Fatalf("%s:%d: invalid JSON: %v", *path, line, err)
+		}
+		if data, ok := event["data"]; ok {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				log.Fatalf("%s:%d: encode data: %v", *path, line, err)
+			}
+			event["data_json"] = string(encoded)
+			delete(event, "data")
+		}
+		if id, _ := event["run_id"].(string); id == "" && *runID != "" {
+			event["run_id"] = *runID
+		}
+		batch = append(batch, event)
+		if len(batch) == *batchSize {
+			send()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("read events file: %v", err)
+	}
+	send()
+	printJSON(map[string]any{"recorded": recorded})
+}
+
 func runSetPolicy(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("set-policy", flag.ExitOnError)
 	killSwitch := flags.Bool("kill-switch", false, "true|false")
@@ -246,16 +539,37 @@ func runSetPolicy(ctx context.Context, conn grpc.ClientConnInterface, args []str
 	maxAttempts := flags.Int64("max-attempts-per-run", 0, "0 means unlimited")
 	maxTokens := flags.Int64("max-tokens-per-run", 0, "0 means unlimited")
 	maxLatency := flags.Int64("max-latency-ms-per-attempt", 0, "0 means unlimited")
+	maxCostHour := flags.Float64("max-cost-per-hour", 0, "rolling 1h hub-wide spend limit, 0 means unlimited")
+	maxCostDay := flags.Float64("max-cost-per-day", 0, "rolling 24h hub-wide spend limit, 0 means unlimited")
+	windows := flags.String("maintenance-windows", "", `optional JSON list replacing the schedule, e.g. '[{"name":"deploy","cron":"0 2 * * *","duration_minutes":30}]'; '[]' clears it`)
+	alertWindows := flags.String("alert-maintenance-windows", "", `optional JSON list of windows that silence alerts without blocking runs, same format as --maintenance-windows; '[]' clears it`)
 	_ = flags.Parse(args)
 
-	request, err := structpb.NewStruct(map[string]any{
+	payload := map[string]any{
 		"kill_switch":                *killSwitch,
 		"kill_switch_reason":         *reason,
 		"max_cost_per_run_usd":       *maxCost,
 		"max_attempts_per_run":       *maxAttempts,
 		"max_tokens_per_run":         *maxTokens,
 		"max_latency_per_attempt_ms": *maxLatency,
-	})
+		"max_cost_per_hour_usd":      *maxCostHour,
+		"max_cost_per_day_usd":       *maxCostDay,
+	}
+	if strings.TrimSpace(*windows) != "" {
+		var decoded []any
+		if err := json.Unmarshal([]byte(*windows), &decoded); err != nil {
+			log.Fatalf("--maintenance-windows must be a JSON list: %v", err)
+		}
+		payload["maintenance_windows"] = decoded
+	}
+	if strings.TrimSpace(*alertWindows) != "" {
+		var decoded []any
+		if err := json.Unmarshal([]byte(*alertWindows), &decoded); err != nil {
+			log.Fatalf("--alert-maintenance-windows must be a JSON list: %v", err)
+		}
+		payload["alert_maintenance_windows"] = decoded
+	}
+	request, err := structpb.NewStruct(payload)
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
 	}
@@ -269,39 +583,98 @@ func runUpsertPolicyCap(ctx context.Context, conn grpc.ClientConnInterface, args
 	providerType := flags.String("provider-type", "", "optional api|subscription|opensource")
 	provider := flags.String("provider", "", "optional")
 	model := flags.String("model", "", "optional")
+	workflow := flags.String("workflow", "", "optional; limit the cap to one workflow")
+	agentID := flags.String("agent-id", "", "optional; limit the cap to one agent")
 	maxCostRun := flags.Float64("max-cost-run", 0, "0 means inherit global")
 	maxAttemptsRun := flags.Int64("max-attempts-run", 0, "0 means inherit global")
 	maxTokensRun := flags.Int64("max-tokens-run", 0, "0 means inherit global")
 	maxCostAttempt := flags.Float64("max-cost-attempt", 0, "0 means unset")
 	maxTokensAttempt := flags.Int64("max-tokens-attempt", 0, "0 means unset")
 	maxLatencyAttempt := flags.Int64("max-latency-attempt-ms", 0, "0 means inherit global")
+	maxCostDay := flags.Float64("max-cost-day", 0, "rolling 24h spend per agent; 0 means unset")
+	maxCostMonth := flags.Float64("max-cost-month", 0, "rolling 30d spend per agent; 0 means unset")
 	priority := flags.Int64("priority", 0, "higher wins on same specificity")
 	dryRun := flags.Bool("dry-run", false, "log violations without blocking")
 	active := flags.Bool("active", true, "true|false")
+	validFrom := flags.String("valid-from", "", "optional RFC3339 start; empty clears")
+	validUntil := flags.String("valid-until", "", "optional RFC3339 expiry; empty clears")
+	validFor := flags.Duration("valid-for", 0, "optional; sets valid-until to now plus this duration (e.g. 48h)")
+	kind := flags.String("kind", "", "optional limit|routing (default limit)")
+	preferProviderType := flags.String("prefer-provider-type", "", "routing: provider type to prefer")
+	preferUnderCost := flags.Float64("prefer-under-cost", 0, "routing: prefer models averaging under this cost per attempt; 0 means any")
+	fallbackProviderType := flags.String("fallback-provider-type", "", "routing: provider type allowed when no preferred model meets the quality target")
+	qualityTarget := flags.Float64("quality-target", 0, "routing: quality (0-1) a model must reach")
 	_ = flags.Parse(args)
 
-	request, err := structpb.NewStruct(map[string]any{
+	payload := map[string]any{
 		"id":                         *id,
 		"name":                       *name,
 		"provider_type":              *providerType,
 		"provider":                   *provider,
 		"model":                      *model,
+		"workflow":                   *workflow,
+		"agent_id":                   *agentID,
 		"max_cost_per_run_usd":       *maxCostRun,
 		"max_attempts_per_run":       *maxAttemptsRun,
 		"max_tokens_per_run":         *maxTokensRun,
 		"max_cost_per_attempt_usd":   *maxCostAttempt,
 		"max_tokens_per_attempt":     *maxTokensAttempt,
 		"max_latency_per_attempt_ms": *maxLatencyAttempt,
+		"max_cost_per_day_usd":       *maxCostDay,
+		"max_cost_per_month_usd":     *maxCostMonth,
 		"priority":                   *priority,
 		"dry_run":                    *dryRun,
 		"is_active":                  *active,
+	}
+	// Validity is only sent when asked for, so updates keep an existing window.
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "valid-from":
+			payload["valid_from"] = *validFrom
+		case "valid-until":
+			payload["valid_until"] = *validUntil
+		}
 	})
+	if *validFor > 0 {
+		payload["valid_until"] = time.Now().UTC().Add(*validFor).Format(time.RFC3339)
+	}
+	if *kind != "" {
+		payload["kind"] = *kind
+	}
+	if *preferProviderType != "" {
+		payload["routing"] = map[string]any{
+			"prefer_provider_type":   *preferProviderType,
+			"prefer_under_cost_usd":  *preferUnderCost,
+			"fallback_provider_type": *fallbackProviderType,
+			"quality_target":         *qualityTarget,
+		}
+	}
+	request, err := structpb.NewStruct(payload)
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
 	}
 	callStruct(ctx, conn, rpccontract.MethodUpsertPolicyCap, request)
 }
 
+func runPrune(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	runEventsDays := flags.Int64("run-events-days", -1, "keep this many days of run events (default: server setting, 0 skips)")
+	attemptsDays := flags.Int64("attempts-days", -1, "keep this many days of prompt attempts (default: server setting, 0 skips)")
+	_ = flags.Parse(args)
+	payload := map[string]any{}
+	if *runEventsDays >= 0 {
+		payload["run_events_retention_days"] = *runEventsDays
+	}
+	if *attemptsDays >= 0 {
+		payload["attempts_retention_days"] = *attemptsDays
+	}
+	request, err := structpb.NewStruct(payload)
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodPrune, request)
+}
+
 func runDeletePolicyCap(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("delete-policy-cap", flag.ExitOnError)
 	id := flags.String("id", "", "required")
@@ -316,9 +689,49 @@ func runDeletePolicyCap(ctx context.Context, conn grpc.ClientConnInterface, args
 	callStruct(ctx, conn, rpccontract.MethodDeletePolicyCap, request)
 }
 
+func runDeleteTask(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("delete-task", flag.ExitOnError)
+	id := flags.String("id", "", "required")
+	purge := flags.Bool("purge", false, "delete permanently instead of archiving")
+	_ = flags.Parse(args)
+	if *id == "" {
+		log.Fatalf("delete-task requires --id")
+	}
+	request, err := structpb.NewStruct(map[string]any{"id": *id, "purge": *purge})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodDeleteTask, request)
+}
+
+func runListTasks(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("list-tasks", flag.ExitOnError)
+	status := flags.String("status", "", "optional todo|in_progress|done|blocked|archived")
+	tags := flags.String("tags", "", "optional comma-separated, all must match")
+	query := flags.String("query", "", "optional text match on title/details")
+	includeArchived := flags.Bool("include-archived", false, "also list archived tasks")
+	externalID := flags.String("external-id", "", "optional; the task with this external ID, archived or not")
+	limit := flags.Int64("limit", 0, "optional")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"external_id":      *externalID,
+		"status":           *status,
+		"tags":             splitCSV(*tags),
+		"query":            *query,
+		"include_archived": *includeArchived,
+		"limit":            *limit,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callList(ctx, conn, rpccontract.MethodListTasks, request)
+}
+
 func runListRuns(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("list-runs", flag.ExitOnError)
 	runID := flags.String("run-id", "", "optional")
+	externalID := flags.String("external-id", "", "optional")
 	taskID := flags.String("task-id", "", "optional")
 	workflow := flags.String("workflow", "", "optional")
 	agentID := flags.String("agent-id", "", "optional")
@@ -326,11 +739,14 @@ func runListRuns(ctx context.Context, conn grpc.ClientConnInterface, args []stri
 	promptVersion := flags.String("prompt-version", "", "optional")
 	startedAfter := flags.String("started-after", "", "optional RFC3339")
 	startedBefore := flags.String("started-before", "", "optional RFC3339")
+	labels := flags.String("labels", "", "optional comma-separated key=value, all must match")
+	starred := flags.Bool("starred", false, "only starred runs, from any time unless --started-after is given")
 	limit := flags.Int64("limit", 0, "optional")
 	_ = flags.Parse(args)
 
 	request, err := structpb.NewStruct(map[string]any{
 		"run_id":         *runID,
+		"external_id":    *externalID,
 		"task_id":        *taskID,
 		"workflow":       *workflow,
 		"agent_id":       *agentID,
@@ -338,6 +754,8 @@ func runListRuns(ctx context.Context, conn grpc.ClientConnInterface, args []stri
 		"prompt_version": *promptVersion,
 		"started_after":  *startedAfter,
 		"started_before": *startedBefore,
+		"labels":         parseKeyValues(*labels),
+		"starred":        *starred,
 		"limit":          *limit,
 	})
 	if err != nil {
@@ -357,18 +775,20 @@ func runListAttempts(ctx context.Context, conn grpc.ClientConnInterface, args []
 	createdAfter := flags.String("created-after", "", "optional RFC3339")
 	createdBefore := flags.String("created-before", "", "optional RFC3339")
 	limit := flags.Int64("limit", 0, "optional")
+	includeArchived := flags.Bool("include-archived", false, "also list attempts in cold storage")
 	_ = flags.Parse(args)
 
 	request, err := structpb.NewStruct(map[string]any{
-		"run_id":         *runID,
-		"workflow":       *workflow,
-		"agent_id":       *agentID,
-		"model":          *model,
-		"outcome":        *outcome,
-		"prompt_version": *promptVersion,
-		"created_after":  *createdAfter,
-		"created_before": *createdBefore,
-		"limit":          *limit,
+		"run_id":           *runID,
+		"workflow":         *workflow,
+		"agent_id":         *agentID,
+		"model":            *model,
+		"outcome":          *outcome,
+		"prompt_version":   *promptVersion,
+		"created_after":    *createdAfter,
+		"created_before":   *createdBefore,
+		"limit":            *limit,
+		"include_archived": *includeArchived,
 	})
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
@@ -384,15 +804,23 @@ func runListEvents(ctx context.Context, conn grpc.ClientConnInterface, args []st
 	createdAfter := flags.String("created-after", "", "optional RFC3339")
 	createdBefore := flags.String("created-before", "", "optional RFC3339")
 	limit := flags.Int64("limit", 0, "optional")
+	includeArchived := flags.Bool("include-archived", false, "also list events in cold storage")
+	sinceID := flags.String("since-id", "", "optional; only events recorded after this one")
+	sinceTime := flags.String("since-time", "", "optional RFC3339; only events created after it")
+	waitSeconds := flags.Int64("wait-seconds", 0, "with --since-id or --since-time, wait up to this long (max 60) for new events")
 	_ = flags.Parse(args)
 
 	request, err := structpb.NewStruct(map[string]any{
-		"run_id":         *runID,
-		"event_type":     *eventType,
-		"level":          *level,
-		"created_after":  *createdAfter,
-		"created_before": *createdBefore,
-		"limit":          *limit,
+		"run_id":           *runID,
+		"event_type":       *eventType,
+		"level":            *level,
+		"created_after":    *createdAfter,
+		"created_before":   *createdBefore,
+		"limit":            *limit,
+		"include_archived": *includeArchived,
+		"since_id":         *sinceID,
+		"since_time":       *sinceTime,
+		"wait_seconds":     *waitSeconds,
 	})
 	if err != nil {
 		log.Fatalf("request build error: %v", err)
@@ -402,6 +830,7 @@ func runListEvents(ctx context.Context, conn grpc.ClientConnInterface, args []st
 
 func runLeaderboard(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+	project := flags.String("project", "", "optional")
 	workflow := flags.String("workflow", "", "optional")
 	model := flags.String("model", "", "optional")
 	promptVersion := flags.String("prompt-version", "", "optional")
@@ -410,6 +839,7 @@ func runLeaderboard(ctx context.Context, conn grpc.ClientConnInterface, args []s
 	_ = flags.Parse(args)
 
 	request, err := structpb.NewStruct(map[string]any{
+		"project":        *project,
 		"workflow":       *workflow,
 		"model":          *model,
 		"prompt_version": *promptVersion,
@@ -422,6 +852,185 @@ func runLeaderboard(ctx context.Context, conn grpc.ClientConnInterface, args []s
 	callList(ctx, conn, rpccontract.MethodGetLeaderboard, request)
 }
 
+func runRecommendModel(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("recommend-model", flag.ExitOnError)
+	project := flags.String("project", "", "optional")
+	workflow := flags.String("workflow", "", "optional")
+	agentID := flags.String("agent-id", "", "optional; selects agent-specific routing caps")
+	provider := flags.String("provider", "", "optional; only consider this provider's models")
+	windowDays := flags.Int64("window-days", 0, "optional (default 30)")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"project":     *project,
+		"workflow":    *workflow,
+		"agent_id":    *agentID,
+		"provider":    *provider,
+		"window_days": *windowDays,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodRecommendModel, request)
+}
+
+func runRetryReport(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("retry-report", flag.ExitOnError)
+	project := flags.String("project", "", "optional")
+	workflow := flags.String("workflow", "", "optional")
+	model := flags.String("model", "", "optional; runs whose first attempt used this model")
+	windowDays := flags.Int64("window-days", 0, "optional (default 30)")
+	minSuccessRate := flags.Float64("min-success-rate", 0, "optional success rate a retry must reach to be suggested (default 0.2)")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"project":          *project,
+		"workflow":         *workflow,
+		"model":            *model,
+		"window_days":      *windowDays,
+		"min_success_rate": *minSuccessRate,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodGetRetryReport, request)
+}
+
+// runLeaderboardDiff compares a recent window (A) against a baseline window (B)
+// and reports rank/score movement per workflow, prompt_version, and model.
+func runLeaderboardDiff(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("leaderboard-diff", flag.ExitOnError)
+	project := flags.String("project", "", "optional")
+	workflow := flags.String("workflow", "", "optional")
+	model := flags.String("model", "", "optional")
+	windowA := flags.Int64("window-a", 7, "recent window in days")
+	windowB := flags.Int64("window-b", 30, "baseline window in days")
+	threshold := flags.Float64("regression-threshold", 5, "score drop that counts as a regression")
+	regressionsOnly := flags.Bool("regressions-only", false, "only print regressed entries")
+	_ = flags.Parse(args)
+
+	if *windowA <= 0 || *windowB <= 0 {
+		log.Fatalf("leaderboard-diff requires positive --window-a and --window-b")
+	}
+
+	fetch := func(windowDays int64) []map[string]any {
+		request, err := structpb.NewStruct(map[string]any{
+			"project":     *project,
+			"workflow":    *workflow,
+			"model":       *model,
+			"window_days": windowDays,
+		})
+		if err != nil {
+			log.Fatalf("request build error: %v", err)
+		}
+		response := &structpb.ListValue{}
+		invoke(ctx, conn, rpccontract.MethodGetLeaderboard, request, response)
+		out := []map[string]any{}
+		for _, item := range response.AsSlice() {
+			if entry, ok := item.(map[string]any); ok {
+				out = append(out, entry)
+			}
+		}
+		return out
+	}
+
+	type ranked struct {
+		rank  int
+		entry map[string]any
+	}
+	index := func(entries []map[string]any) (map[string]ranked, []string) {
+		byKey := map[string]ranked{}
+		keys := []string{}
+		for i, entry := range entries {
+			key := fmt.Sprintf("%v|%v|%v", entry["workflow"], entry["prompt_version"], entry["model"])
+			byKey[key] = ranked{rank: i + 1, entry: entry}
+			keys = append(keys, key)
+		}
+		return byKey, keys
+	}
+
+	recent, recentKeys := index(fetch(*windowA))
+	baseline, baselineKeys := index(fetch(*windowB))
+	keys := recentKeys
+	for _, key := range baselineKeys {
+		if _, ok := recent[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	rows := []map[string]any{}
+	for _, key := range keys {
+		a, inA := recent[key]
+		b, inB := baseline[key]
+		source := a.entry
+		if !inA {
+			source = b.entry
+		}
+		row := map[string]any{
+			"workflow":       source["workflow"],
+			"prompt_version": source["prompt_version"],
+			"model":          source["model"],
+		}
+		switch {
+		case inA && inB:
+			scoreA, _ := a.entry["score"].(float64)
+			scoreB, _ := b.entry["score"].(float64)
+			delta := scoreA - scoreB
+			row["status"] = "compared"
+			row["rank_a"], row["rank_b"] = a.rank, b.rank
+			row["rank_change"] = b.rank - a.rank
+			row["score_a"], row["score_b"] = scoreA, scoreB
+			row["score_delta"] = delta
+			row["success_rate_a"], row["success_rate_b"] = a.entry["success_rate"], b.entry["success_rate"]
+			row["regression"] = delta <= -*threshold
+		case inA:
+			row["status"] = "new"
+			row["rank_a"] = a.rank
+			row["score_a"] = a.entry["score"]
+			row["regression"] = false
+		default:
+			row["status"] = "dropped"
+			row["rank_b"] = b.rank
+			row["score_b"] = b.entry["score"]
+			row["regression"] = false
+		}
+		if *regressionsOnly && row["regression"] != true {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	// Regressions first, worst score drop on top; everything else keeps window A order.
+	slices.SortStableFunc(rows, func(x, y map[string]any) int {
+		xr, yr := x["regression"] == true, y["regression"] == true
+		if xr != yr {
+			if xr {
+				return -1
+			}
+			return 1
+		}
+		if !xr {
+			return 0
+		}
+		xd, _ := x["score_delta"].(float64)
+		yd, _ := y["score_delta"].(float64)
+		return cmp.Compare(xd, yd)
+	})
+
+	regressions := 0
+	for _, row := range rows {
+		if row["regression"] == true {
+			regressions++
+		}
+	}
+	printJSON(map[string]any{
+		"window_a_days": *windowA,
+		"window_b_days": *windowB,
+		"regressions":   regressions,
+		"entries":       rows,
+	})
+}
+
 func runAppendChangelog(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
 	flags := flag.NewFlagSet("append-changelog", flag.ExitOnError)
 	summary := flags.String("summary", "", "required")
@@ -480,22 +1089,260 @@ func runRecordBenchmark(ctx context.Context, conn grpc.ClientConnInterface, args
 	callStruct(ctx, conn, rpccontract.MethodRecordBenchmark, request)
 }
 
-func callStruct(ctx context.Context, conn grpc.ClientConnInterface, method string, request any) {
+func runSetPromptVersion(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("set-prompt-version", flag.ExitOnError)
+	workflow := flags.String("workflow", "", "required")
+	version := flags.String("version", "", "required")
+	actor := flags.String("actor", os.Getenv("USER"), "optional")
+	reason := flags.String("reason", "", "optional")
+	canaryPercent := flags.Int64("canary-percent", 0, "1-99 routes that share of new runs to --version; 0 or 100 pins it fully")
+	rollbackMargin := flags.Float64("rollback-margin", 0, "success-rate gap (0-1) that triggers canary rollback; 0 uses the server default")
+	minRuns := flags.Int64("min-runs", 0, "finished runs per version before the canary is judged; 0 uses the server default")
+	_ = flags.Parse(args)
+
+	if *workflow == "" || *version == "" {
+		log.Fatalf("set-prompt-version requires --workflow and --version")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"workflow":        *workflow,
+		"prompt_version":  *version,
+		"actor":           *actor,
+		"reason":          *reason,
+		"canary_percent":  *canaryPercent,
+		"rollback_margin": *rollbackMargin,
+		"min_runs":        *minRuns,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodSetActivePromptVersion, request)
+}
+
+func runRollbackPromptVersion(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("rollback-prompt-version", flag.ExitOnError)
+	workflow := flags.String("workflow", "", "required")
+	actor := flags.String("actor", os.Getenv("USER"), "optional")
+	reason := flags.String("reason", "", "optional")
+	_ = flags.Parse(args)
+
+	if *workflow == "" {
+		log.Fatalf("rollback-prompt-version requires --workflow")
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"workflow": *workflow,
+		"actor":    *actor,
+		"reason":   *reason,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodRollbackPromptVersion, request)
+}
+
+func runListPromptReleases(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("list-prompt-releases", flag.ExitOnError)
+	workflow := flags.String("workflow", "", "optional")
+	limit := flags.Int64("limit", 0, "optional")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"workflow": *workflow,
+		"limit":    *limit,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callList(ctx, conn, rpccontract.MethodListPromptReleases, request)
+}
+
+func runListPolicyAudit(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("list-policy-audit", flag.ExitOnError)
+	targetType := flags.String("target-type", "", "optional policy|policy_cap|run_share")
+	targetID := flags.String("target-id", "", "optional")
+	limit := flags.Int64("limit", 0, "optional")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"target_type": *targetType,
+		"target_id":   *targetID,
+		"limit":       *limit,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callList(ctx, conn, rpccontract.MethodListPolicyAudit, request)
+}
+
+func runRecordArtifact(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("record-artifact", flag.ExitOnError)
+	runID := flags.String("run-id", "", "required")
+	name := flags.String("name", "", "optional; defaults to file name")
+	kind := flags.String("kind", "file", "diff|transcript|file|log|other")
+	contentType := flags.String("content-type", "", "optional")
+	path := flags.String("file", "", "required path to upload")
+	_ = flags.Parse(args)
+
+	if *runID == "" || *path == "" {
+		log.Fatalf("record-artifact requires --run-id and --file")
+	}
+	content, err := os.ReadFile(*path)
+	if err != nil {
+		log.Fatalf("read artifact file: %v", err)
+	}
+	if *name == "" {
+		*name = filepath.Base(*path)
+	}
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id":         *runID,
+		"name":           *name,
+		"kind":           *kind,
+		"content_type":   *contentType,
+		"content_base64": base64.StdEncoding.EncodeToString(content),
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	callStruct(ctx, conn, rpccontract.MethodRecordArtifact, request)
+}
+
+func runGetArtifact(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("get-artifact", flag.ExitOnError)
+	id := flags.String("id", "", "required")
+	out := flags.String("out", "", "optional path to write content to")
+	_ = flags.Parse(args)
+
+	if *id == "" {
+		log.Fatalf("get-artifact requires --id")
+	}
+	request, err := structpb.NewStruct(map[string]any{"id": *id})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
+	}
+	if *out == "" {
+		callStruct(ctx, conn, rpccontract.MethodGetArtifact, request)
+		return
+	}
+
 	response := &structpb.Struct{}
-	if err := conn.Invoke(ctx, method, request, response); err != nil {
-		log.Fatalf("rpc error %s: %v", method, err)
+	invoke(ctx, conn, rpccontract.MethodGetArtifact, request, response)
+	fields := response.AsMap()
+	encoded, _ := fields["content_base64"].(string)
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		log.Fatalf("decode artifact content: %v", err)
+	}
+	if err := os.WriteFile(*out, content, 0o644); err != nil {
+		log.Fatalf("write artifact file: %v", err)
+	}
+	delete(fields, "content_base64")
+	printJSON(fields)
+}
+
+func runListArtifacts(ctx context.Context, conn grpc.ClientConnInterface, args []string) {
+	flags := flag.NewFlagSet("list-artifacts", flag.ExitOnError)
+	runID := flags.String("run-id", "", "optional")
+	kind := flags.String("kind", "", "optional")
+	limit := flags.Int64("limit", 0, "optional")
+	_ = flags.Parse(args)
+
+	request, err := structpb.NewStruct(map[string]any{
+		"run_id": *runID,
+		"kind":   *kind,
+		"limit":  *limit,
+	})
+	if err != nil {
+		log.Fatalf("request build error: %v", err)
 	}
+	callList(ctx, conn, rpccontract.MethodListArtifacts, request)
+}
+
+func callStruct(ctx context.Context, conn grpc.ClientConnInterface, method string, request any) {
+	response := &structpb.Struct{}
+	invoke(ctx, conn, method, request, response)
 	printJSON(response.AsMap())
 }
 
 func callList(ctx context.Context, conn grpc.ClientConnInterface, method string, request any) {
 	response := &structpb.ListValue{}
-	if err := conn.Invoke(ctx, method, request, response); err != nil {
-		log.Fatalf("rpc error %s: %v", method, err)
-	}
+	invoke(ctx, conn, method, request, response)
 	printJSON(response.AsSlice())
 }
 
+// invoke calls method and exits on error, quoting the server's request ID so
+// the failure can be found in the server logs.
+func invoke(ctx context.Context, conn grpc.ClientConnInterface, method string, request, response any) {
+	if err := call(ctx, conn, method, request, response); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// call is invoke for commands that keep going after a failed call. Warnings
+// the server sends back, such as a list cut short by its guardrails or a
+// deprecated method, go to stderr.
+func call(ctx context.Context, conn grpc.ClientConnInterface, method string, request, response any) error {
+	warnings, err := callWithWarnings(ctx, conn, method, request, response)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	return err
+}
+
+// callWithWarnings is call without the printing: it returns the server's
+// x-modeloman-warning values, and the method's deprecation note if any, for
+// the caller to report.
+func callWithWarnings(ctx context.Context, conn grpc.ClientConnInterface, method string, request, response any) ([]string, error) {
+	var header, trailer metadata.MD
+	err := conn.Invoke(ctx, method, request, response, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err == nil {
+		warnings := header.Get("x-modeloman-warning")
+		if note := first(header.Get(rpccontract.DeprecationHeader)); note != "" {
+			warnings = append(warnings, method+" is deprecated: "+note)
+		}
+		return warnings, nil
+	}
+	// Internal errors already name the request ID in their message.
+	requestID := first(header.Get("x-request-id"), trailer.Get("x-request-id"))
+	if requestID == "" || strings.Contains(err.Error(), "request_id="+requestID) {
+		return nil, fmt.Errorf("rpc error %s: %w", method, err)
+	}
+	return nil, fmt.Errorf("rpc error %s (request_id=%s): %w", method, requestID, err)
+}
+
+func first(lists ...[]string) string {
+	for _, values := range lists {
+		if len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func splitCSV(raw string) []any {
+	out := []any{}
+	for _, item := range strings.Split(raw, ",") {
+		if clean := strings.TrimSpace(item); clean != "" {
+			out = append(out, clean)
+		}
+	}
+	return out
+}
+
+func parseKeyValues(raw string) map[string]any {
+	out := map[string]any{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			log.Fatalf("invalid key=value pair %q", item)
+		}
+		out[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return out
+}
+
 func printJSON(value any) {
 	serialized, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
@@ -504,32 +1351,81 @@ func printJSON(value any) {
 	fmt.Println(string(serialized))
 }
 
+// runOpenAPI prints the HTTP API's OpenAPI document, the one the server
+// serves at /api/openapi.json, without contacting a server.
+func runOpenAPI(args []string) {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	out := flags.String("out", "", "optional file to write instead of stdout")
+	_ = flags.Parse(args)
+	if *out == "" {
+		printJSON(httpx.OpenAPIDocument())
+		return
+	}
+	serialized, err := json.MarshalIndent(httpx.OpenAPIDocument(), "", "  ")
+	if err != nil {
+		log.Fatalf("encode error: %v", err)
+	}
+	if err := os.WriteFile(*out, append(serialized, '\n'), 0o644); err != nil {
+		log.Fatalf("write %s: %v", *out, err)
+	}
+}
+
 func usage() {
 	fmt.Print(`ModeloMan gRPC CLI
 
 Usage:
-  modeloman-cli [--addr 127.0.0.1:50051] [--token ...] <command> [flags]
+  modeloman-cli [--addr 127.0.0.1:50051] [--token ...] [--project ...] [--timeout 30s] [--retries 2] <command> [flags]
 
 Commands:
   health
+  api-info
+  openapi [--out openapi.json]
   summary
   telemetry-summary
+  export-state
   get-policy
   list-policy-caps
-  list-tasks
-  list-runs [--workflow "..." --status "..."]
-  list-attempts [--run-id "..."]
-  list-events [--run-id "..."]
+  list-tasks [--status todo --tags "a,b" --query "..." --external-id "..." --include-archived --limit 20]
+  list-runs [--workflow "..." --status "..." --labels "env=staging" --external-id "..." --starred]
+  get-run (--run-id "..." | --external-id "...") [--include-archived]
+  list-attempts [--run-id "..." --include-archived]
+  list-events [--run-id "..." --include-archived --since-id "..." --wait-seconds 30]
+  watch-events [--run-id "..." --event-type "..." --level "..." --since-id "..." | --since-time RFC3339]
+  export --kind attempts|runs [--format csv|jsonl --out attempts.csv --columns id,model,cost_usd --since 2026-01-01T00:00:00Z --until ... --workflow "..." --include-archived]
   leaderboard [--workflow "..." --window-days 14 --limit 20]
-  create-task --title "..."
-  start-run --workflow "..." --agent-id "..."
+  leaderboard-diff [--window-a 7 --window-b 30 --workflow "..." --regression-threshold 5 --regressions-only]
+  recommend-model --workflow "..." [--agent-id "..." --provider wrapped-cli --window-days 30]
+  retry-report [--workflow "..." --model "..." --window-days 30 --min-success-rate 0.2]
+  create-task --title "..." [--external-id "..."]
+  delete-task --id "..." [--purge]
+  start-run --workflow "..." --agent-id "..." [--name "..." --description "..."] [--external-id "..."] [--metadata "ticket=ENG-1,env=staging"]
   finish-run --run-id "..." --status completed|failed|cancelled
+  cancel-run --run-id "..." [--reason "..."]
+  star-run --run-id "..." [--unstar]
+  share-run --run-id "..." [--ttl-seconds 86400]
   record-attempt --run-id "..." --attempt-number 1 --model "..." --outcome success|failed|timeout|retryable_error|tool_error
   record-event --run-id "..." --event-type "..."
+  record-events --file events.jsonl [--run-id "..." --batch-size 200]
+  ingest --file agent.log [--mapping mapping.json --run-id "..." | --workflow "..." --agent-id "..."] [--from-start --offset-file agent.log.offset]
+  import --file attempts.jsonl [--kind attempts|benchmarks --import-id "..." --workflow "..." --agent-id "..." --from-line N --batch-size 200]
   set-policy --kill-switch false --max-cost-per-run 2.5 --max-attempts-per-run 8 --max-tokens-per-run 50000
+  set-policy --maintenance-windows '[{"name":"deploy","cron":"0 2 * * 1-5","duration_minutes":30}]'
+  set-policy --alert-maintenance-windows '[{"name":"load-test","starts_at":"2026-03-01T14:00:00Z","ends_at":"2026-03-01T16:00:00Z"}]'
   upsert-policy-cap --name "expensive-model" --provider-type api --provider openai --model gpt-5 --max-cost-run 5 --max-cost-attempt 0.8 --priority 50
+  upsert-policy-cap --name "agent-budget" --agent-id "codex-a" --max-cost-day 20 --max-cost-month 300
+  upsert-policy-cap --name "release-notes-cap" --workflow release-notes --max-cost-run 1
+  upsert-policy-cap --name "gpt5-clamp" --model gpt-5 --max-cost-attempt 1 --valid-for 48h
+  upsert-policy-cap --name "prefer-local" --kind routing --workflow bugfix --prefer-provider-type opensource --prefer-under-cost 0.05 --fallback-provider-type api --quality-target 0.8
   delete-policy-cap --id "cap_..."
+  list-policy-audit [--target-type policy|policy_cap|run_share --target-id "cap_..." --limit 20]
   append-changelog --summary "..."
   record-benchmark --workflow "..." --model "..."
+  set-prompt-version --workflow "..." --version "v7" [--reason "..."] [--canary-percent 10 --rollback-margin 0.1 --min-runs 10]
+  rollback-prompt-version --workflow "..." [--reason "..."]
+  list-prompt-releases [--workflow "..." --limit 20]
+  record-artifact --run-id "..." --file ./patch.diff [--kind diff --name "..."]
+  get-artifact --id "art_..." [--out ./patch.diff]
+  list-artifacts [--run-id "..." --kind diff]
+  prune [--run-events-days 30 --attempts-days 90]
 `)
 }
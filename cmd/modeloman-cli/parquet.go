@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// parquetRowGroupRows is how many rows parquetWriter buffers before writing
+// them out as a row group.
+const parquetRowGroupRows = 50000
+
+// Parquet physical types, converted types and encodings, numbered as in
+// parquet.thrift.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetColumnType maps export columns that are not strings to their
+// Parquet type; the rest are written as UTF-8 strings, with metadata as
+// JSON. Timestamps are written as UTC microseconds.
+var parquetColumnType = map[string]int32{
+	"attempt_number":   parquetInt64,
+	"tokens_in":        parquetInt64,
+	"tokens_out":       parquetInt64,
+	"latency_ms":       parquetInt64,
+	"max_retries":      parquetInt64,
+	"total_attempts":   parquetInt64,
+	"success_attempts": parquetInt64,
+	"failed_attempts":  parquetInt64,
+	"total_tokens_in":  parquetInt64,
+	"total_tokens_out": parquetInt64,
+	"duration_ms":      parquetInt64,
+	"cost_usd":         parquetDouble,
+	"total_cost_usd":   parquetDouble,
+	"quality_score":    parquetDouble,
+	"starred":          parquetBoolean,
+	"created_at":       parquetInt64,
+	"started_at":       parquetInt64,
+	"finished_at":      parquetInt64,
+	"starred_at":       parquetInt64,
+}
+
+var parquetTimestampColumns = map[string]bool{"created_at": true, "started_at": true, "finished_at": true, "starred_at": true}
+
+// parquetColumn buffers one column of the current row group: a definition
+// level per row (0 for null) and the PLAIN-encoded non-null values.
+type parquetColumn struct {
+	name      string
+	kind      int32
+	timestamp bool
+	levels    []byte
+	values    []byte
+	present   int
+}
+
+type parquetChunk struct {
+	column *parquetColumn
+	offset int64
+	size   int64
+	rows   int64
+}
+
+// parquetWriter writes rows as an uncompressed Parquet file with one
+// optional column per export column. Rows are buffered into row groups of
+// parquetRowGroupRows and the footer is written by close. The first write
+// error is kept and returned by every later call.
+type parquetWriter struct {
+	out       io.Writer
+	offset    int64
+	err       error
+	columns   []*parquetColumn
+	rows      int64
+	groupRows int64
+	groups    [][]parquetChunk
+}
+
+func newParquetWriter(out io.Writer, columns []string) *parquetWriter {
+	writer := &parquetWriter{out: out}
+	for _, name := range columns {
+		kind, ok := parquetColumnType[name]
+		if !ok {
+			kind = parquetByteArray
+		}
+		writer.columns = append(writer.columns, &parquetColumn{name: name, kind: kind, timestamp: parquetTimestampColumns[name]})
+	}
+	writer.emit([]byte("PAR1"))
+	return writer
+}
+
+func (w *parquetWriter) emit(data []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	w.err = err
+}
+
+func (w *parquetWriter) write(row map[string]any) error {
+	for _, column := range w.columns {
+		column.add(row[column.name])
+	}
+	w.groupRows++
+	if w.groupRows >= parquetRowGroupRows {
+		w.writeRowGroup()
+	}
+	return w.err
+}
+
+func (c *parquetColumn) add(value any) {
+	switch c.kind {
+	case parquetBoolean:
+		flag, ok := value.(bool)
+		if !ok {
+			c.levels = append(c.levels, 0)
+			return
+		}
+		if c.present%8 == 0 {
+			c.values = append(c.values, 0)
+		}
+		if flag {
+			c.values[len(c.values)-1] |= 1 << (c.present % 8)
+		}
+	case parquetInt64:
+		var number int64
+		if c.timestamp {
+			text, _ := value.(string)
+			at, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				c.levels = append(c.levels, 0)
+				return
+			}
+			number = at.UnixMicro()
+		} else {
+			float, ok := value.(float64)
+			if !ok {
+				c.levels = append(c.levels, 0)
+				return
+			}
+			number = int64(float)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(number))
+	case parquetDouble:
+		float, ok := value.(float64)
+		if !ok {
+			c.levels = append(c.levels, 0)
+			return
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(float))
+	default:
+		if value == nil {
+			c.levels = append(c.levels, 0)
+			return
+		}
+		text := exportCell(value)
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(text)))
+		c.values = append(c.values, text...)
+	}
+	c.levels = append(c.levels, 1)
+	c.present++
+}
+
+// writeRowGroup writes each column's buffered rows as one data page: the
+// definition levels RLE-encoded behind their byte length, then the values.
+func (w *parquetWriter) writeRowGroup() {
+	if w.groupRows == 0 {
+		return
+	}
+	chunks := make([]parquetChunk, 0, len(w.columns))
+	for _, column := range w.columns {
+		levels := encodeParquetLevels(column.levels)
+		page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+		page = append(page, levels...)
+		page = append(page, column.values...)
+
+		var header thriftCompact
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.structBegin(5)
+		header.i32(1, int32(w.groupRows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{column: column, offset: w.offset, rows: w.groupRows, size: int64(len(header.buf) + len(page))}
+		w.emit(header.buf)
+		w.emit(page)
+		chunks = append(chunks, chunk)
+		column.levels = column.levels[:0]
+		column.values = column.values[:0]
+		column.present = 0
+	}
+	w.groups = append(w.groups, chunks)
+	w.rows += w.groupRows
+	w.groupRows = 0
+}
+
+// encodeParquetLevels writes levels of bit width 1 as RLE runs.
+func encodeParquetLevels(levels []byte) []byte {
+	var out []byte
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		out = binary.AppendUvarint(out, uint64(end-start)<<1)
+		out = append(out, levels[start])
+		start = end
+	}
+	return out
+}
+
+// close writes the last row group and the footer: the file metadata, its
+// length and the closing magic.
+func (w *parquetWriter) close() error {
+	w.writeRowGroup()
+
+	var meta thriftCompact
+	meta.begin()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(w.columns)+1)
+	meta.begin()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(w.columns)))
+	meta.end()
+	for _, column := range w.columns {
+		meta.begin()
+		meta.i32(1, column.kind)
+		meta.i32(3, 1) // OPTIONAL
+		meta.str(4, column.name)
+		switch {
+		case column.timestamp:
+			meta.i32(6, parquetTimestampMicros)
+		case column.kind == parquetByteArray:
+			meta.i32(6, parquetUTF8)
+		}
+		meta.end()
+	}
+	meta.i64(3, w.rows)
+	meta.list(4, thriftStruct, len(w.groups))
+	for _, chunks := range w.groups {
+		var total int64
+		for _, chunk := range chunks {
+			total += chunk.size
+		}
+		meta.begin()
+		meta.list(1, thriftStruct, len(chunks))
+		for _, chunk := range chunks {
+			meta.begin()
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3)
+			meta.i32(1, chunk.column.kind)
+			meta.list(2, thriftI32, 2)
+			meta.listI32(parquetPlain)
+			meta.listI32(parquetRLE)
+			meta.list(3, thriftBinary, 1)
+			meta.listStr(chunk.column.name)
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, chunk.rows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, total)
+		meta.i64(3, chunks[0].rows)
+		meta.end()
+	}
+	meta.str(6, "modeloman-cli")
+	meta.end()
+
+	w.emit(meta.buf)
+	w.emit(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf))))
+	w.emit([]byte("PAR1"))
+	return w.err
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes the Thrift compact protocol structs Parquet's
+// headers and footer are made of. begin and end bracket each struct,
+// including list elements and the outermost one.
+type thriftCompact struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (t *thriftCompact) begin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftCompact) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftCompact) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|kind)
+	} else {
+		t.buf = append(t.buf, kind)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftCompact) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(value))
+}
+
+func (t *thriftCompact) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, value)
+}
+
+func (t *thriftCompact) str(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.listStr(value)
+}
+
+func (t *thriftCompact) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+func (t *thriftCompact) list(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elem)
+	t.buf = binary.AppendUvarint(t.buf, uint64(size))
+}
+
+func (t *thriftCompact) listI32(value int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(value))
+}
+
+func (t *thriftCompact) listStr(value string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(value)))
+	t.buf = append(t.buf, value...)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	mathrand "math/rand/v2"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	defaultRetries   = 2
+	retryBaseBackoff = 250 * time.Millisecond
+	retryMaxBackoff  = 5 * time.Second
+)
+
+// retryInterceptor retries calls that fail with Unavailable or
+// ResourceExhausted up to retries more times, waiting for the server's
+// RetryInfo delay when it sends one and backing off exponentially otherwise.
+// Write calls get an idempotency key first, so a retry after a lost response
+// replays the original result instead of writing twice.
+func retryInterceptor(retries int) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if retries <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx = withIdempotencyKey(ctx, method, req)
+		for attempt := 0; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= retries || !retryable(err) {
+				return err
+			}
+			delay := retryDelay(err, attempt)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+				return err
+			}
+			log.Printf("retrying %s in %s after %v (retry %d/%d)", method, delay.Round(time.Millisecond), status.Code(err), attempt+1, retries)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// retryDelay is the server's RetryInfo delay when present, else a jittered
+// exponential backoff.
+func retryDelay(err error, attempt int) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			if delay := info.GetRetryDelay().AsDuration(); delay > 0 {
+				return delay
+			}
+		}
+	}
+	backoff := min(retryBaseBackoff<<attempt, retryMaxBackoff)
+	return backoff/2 + mathrand.N(backoff/2+1)
+}
+
+// withIdempotencyKey adds an x-idempotency-key to write calls that do not
+// already carry one in their payload or metadata.
+func withIdempotencyKey(ctx context.Context, method string, req any) context.Context {
+	if _, ok := rpccontract.WriteMethods[method]; !ok {
+		return ctx
+	}
+	if request, ok := req.(*structpb.Struct); ok {
+		if key, _ := request.AsMap()["idempotency_key"].(string); key != "" {
+			return ctx
+		}
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get("x-idempotency-key")) > 0 {
+		return ctx
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "x-idempotency-key", "cli_"+hex.EncodeToString(raw))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+)
+
+// watchWaitSeconds is how long each watch-events poll waits on the server.
+const watchWaitSeconds = 30
+
+// runWatchEvents prints run events as they are recorded, oldest first, one
+// JSON object per line, until interrupted. It long-polls ListRunEvents with
+// the newest event seen as since_id, starting from --since-id, --since-time
+// or now. callTimeout applies to each poll on top of the server's wait.
+func runWatchEvents(ctx context.Context, conn grpc.ClientConnInterface, args []string, callTimeout time.Duration) {
+	flags := flag.NewFlagSet("watch-events", flag.ExitOnError)
+	runID := flags.String("run-id", "", "optional")
+	eventType := flags.String("event-type", "", "optional")
+	level := flags.String("level", "", "optional")
+	sinceID := flags.String("since-id", "", "optional; start after this event")
+	sinceTime := flags.String("since-time", "", "optional RFC3339; start after this time (default now)")
+	_ = flags.Parse(args)
+	if *sinceID == "" && *sinceTime == "" {
+		*sinceTime = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for ctx.Err() == nil {
+		request := map[string]any{
+			"run_id":       *runID,
+			"event_type":   *eventType,
+			"level":        *level,
+			"since_id":     *sinceID,
+			"since_time":   *sinceTime,
+			"wait_seconds": watchWaitSeconds,
+		}
+		events, _, err := exportPage(ctx, conn, rpccontract.MethodListRunEvents, request, callTimeout+watchWaitSeconds*time.Second)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "watch-events: %v; retrying\n", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * time.Second):
+			}
+			continue
+		}
+		if len(events) == 0 {
+			continue
+		}
+		// Events come newest first; the newest is the next cursor.
+		if id, ok := events[0]["id"].(string); ok {
+			*sinceID = id
+		}
+		for _, event := range slices.Backward(events) {
+			if err := encoder.Encode(event); err != nil {
+				log.Fatalf("write event: %v", err)
+			}
+		}
+	}
+}
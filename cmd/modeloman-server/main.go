@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -12,15 +15,21 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/bcrosbie/modeloman/db"
 	"github.com/bcrosbie/modeloman/internal/config"
+	"github.com/bcrosbie/modeloman/internal/logging"
+	"github.com/bcrosbie/modeloman/internal/notify"
 	"github.com/bcrosbie/modeloman/internal/service"
 	"github.com/bcrosbie/modeloman/internal/store"
+	"github.com/bcrosbie/modeloman/internal/tlsconfig"
+	"github.com/bcrosbie/modeloman/internal/tracing"
 	grpcx "github.com/bcrosbie/modeloman/internal/transport/grpc"
 	httpx "github.com/bcrosbie/modeloman/internal/transport/http"
+	modelomanv1 "github.com/bcrosbie/modeloman/proto/modeloman/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
-	"google.golang.org/grpc/reflection"
 )
 
 const (
@@ -36,36 +45,118 @@ const (
 
 func main() {
 	cfg := config.Load()
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatalf("logging setup failed: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			fatal("migrate failed", "err", err)
+		}
+		return
+	}
+
+	stopTracing, err := setupTracing(cfg)
+	if err != nil {
+		fatal("tracing setup failed", "err", err)
+	}
+	defer stopTracing()
 
 	hubStore, dataSource, err := buildStore(cfg)
 	if err != nil {
-		log.Fatalf("store setup failed: %v", err)
+		fatal("store setup failed", "err", err)
 	}
 	defer func() {
 		if err := hubStore.Close(); err != nil {
-			log.Printf("store close warning: %v", err)
+			slog.Warn("store close failed", "err", err)
 		}
 	}()
 
+	if cfg.AutoMigrate {
+		for _, pgStore := range postgresStores(hubStore) {
+			if err := migratePostgres(pgStore, store.MigrateOptions{}); err != nil {
+				fatal("auto migrate failed", "err", err)
+			}
+		}
+	}
 	if err := hubStore.Load(); err != nil {
-		log.Fatalf("store initialization failed: %v", err)
+		fatal("store initialization failed", "err", err)
+	}
+	for _, pgStore := range postgresStores(hubStore) {
+		for _, column := range pgStore.MissingColumns() {
+			slog.Warn("schema compatibility: running without a column until its migration is applied", "schema", pgStore.Schema(), "column", column)
+		}
 	}
 
 	keyAuth, _ := hubStore.(store.AgentKeyAuthenticator)
 	idempotencyStore, _ := hubStore.(store.IdempotencyStore)
 	if keyAuth != nil && strings.TrimSpace(cfg.BootstrapAgentKey) != "" {
-		keyID, created, err := keyAuth.EnsureAgentKey(cfg.BootstrapAgentID, cfg.BootstrapAgentKey)
+		keyID, created, err := keyAuth.EnsureAgentKey(context.Background(), cfg.BootstrapAgentID, cfg.BootstrapAgentKey)
 		if err != nil {
-			log.Fatalf("failed to seed bootstrap agent key: %v", err)
+			fatal("failed to seed bootstrap agent key", "err", err)
 		}
 		if created {
-			log.Printf("Bootstrapped agent key agent_id=%s key_id=%s", cfg.BootstrapAgentID, keyID)
+			slog.Info("bootstrapped agent key", "agent_id", cfg.BootstrapAgentID, "key_id", keyID)
 		}
 	}
 
 	hubService := service.NewHubService(hubStore, dataSource)
+	hubService.EnableArtifacts(buildArtifactBlobStore(cfg), cfg.ArtifactMaxBytes)
+	hubService.SetRetention(service.RetentionPolicy{
+		RunEventsDays: cfg.RunEventsRetentionDays,
+		AttemptsDays:  cfg.AttemptsRetentionDays,
+	})
+	if err := hubService.SetArchiving(cfg.ArchiveAfterDays); err != nil {
+		fatal("invalid ARCHIVE_AFTER_DAYS", "err", err)
+	}
+	hubService.SetListGuardrails(service.ListGuardrails{
+		MaxLimit:  cfg.ListMaxLimit,
+		MaxWindow: time.Duration(cfg.ListMaxWindowDays) * 24 * time.Hour,
+	})
+	if err := hubService.SetIDPrefixes(cfg.RunIDPrefix, cfg.TaskIDPrefix); err != nil {
+		fatal("invalid RUN_ID_PREFIX or TASK_ID_PREFIX", "err", err)
+	}
+	if cfg.ShareLinkSecret != "" {
+		if err := hubService.EnableShareLinks([]byte(cfg.ShareLinkSecret), cfg.ShareLinkMaxTTL); err != nil {
+			fatal("invalid SHARE_LINK_SECRET or SHARE_LINK_MAX_TTL_HOURS", "err", err)
+		}
+		slog.Info("run share links enabled", "max_ttl", cfg.ShareLinkMaxTTL)
+	}
+	if strings.TrimSpace(cfg.AlertWebhookURL) != "" {
+		notifier := notify.New(notify.NewWebhookSender(cfg.AlertWebhookURL), cfg.AlertWindow)
+		hubService.EnableNotifications(notifier)
+		slog.Info("alert webhook enabled", "window", cfg.AlertWindow)
+		defer notifier.Close()
+	}
+	if strings.TrimSpace(cfg.PolicyWebhookURL) != "" {
+		hubService.EnablePolicyValidation(service.PolicyValidation{
+			Validator: service.NewPolicyWebhook(cfg.PolicyWebhookURL, []byte(cfg.PolicyWebhookSecret), cfg.PolicyWebhookTimeout),
+			FailOpen:  cfg.PolicyWebhookFailOpen,
+		})
+		slog.Info("policy validation webhook enabled", "timeout", cfg.PolicyWebhookTimeout, "fail_open", cfg.PolicyWebhookFailOpen)
+	}
+	// The memory store has no disk write to hide, so it never buffers.
+	if cfg.IngestBufferSize > 0 && !strings.EqualFold(strings.TrimSpace(cfg.StoreDriver), "memory") {
+		hubService.EnableIngestBuffer(service.IngestBufferConfig{
+			MaxPending:    int(cfg.IngestBufferSize),
+			BatchSize:     int(cfg.IngestBatchSize),
+			FlushInterval: cfg.IngestFlushInterval,
+		})
+		slog.Info("ingest buffer enabled",
+			"max_pending", cfg.IngestBufferSize, "batch", cfg.IngestBatchSize, "flush_every", cfg.IngestFlushInterval)
+		// Runs before the store's deferred Close.
+		defer func() {
+			if err := hubService.StopIngestBuffer(); err != nil {
+				slog.Warn("ingest buffer flush failed", "err", err)
+			}
+		}()
+	}
+	var selfMetrics *service.SelfMetrics
+	var latencyObserver grpcx.LatencyObserver
+	if cfg.SelfMetricsInterval > 0 {
+		selfMetrics = service.NewSelfMetrics()
+		latencyObserver = selfMetrics
+	}
 	handler := grpcx.NewHubHandler(hubService)
-	httpServer := httpx.NewServer(cfg.HTTPAddr, hubService)
 	rateLimiter := grpcx.NewTokenBucketRateLimiter(grpcx.TokenBucketRateLimiterConfig{
 		AuthenticatedPerSecond:   authenticatedRPS,
 		AuthenticatedBurst:       authenticatedBurst,
@@ -76,50 +167,132 @@ func main() {
 
 	listener, err := net.Listen("tcp", cfg.GRPCAddr)
 	if err != nil {
-		log.Fatalf("failed to listen on %s: %v", cfg.GRPCAddr, err)
+		fatal("failed to listen", "addr", cfg.GRPCAddr, "err", err)
 	}
 
-	server := grpc.NewServer(
+	var verifiers []grpcx.TokenVerifier
+	if strings.TrimSpace(cfg.JWTIssuer) != "" || strings.TrimSpace(cfg.JWTJWKSURL) != "" {
+		jwtVerifier, err := grpcx.NewJWTVerifier(grpcx.JWTVerifierConfig{
+			Issuer:       cfg.JWTIssuer,
+			Audience:     cfg.JWTAudience,
+			JWKSURL:      cfg.JWTJWKSURL,
+			AgentIDClaim: cfg.JWTAgentIDClaim,
+			ScopesClaim:  cfg.JWTScopesClaim,
+			ScopePrefix:  cfg.JWTScopePrefix,
+		})
+		if err != nil {
+			fatal("jwt auth setup failed", "err", err)
+		}
+		verifiers = append(verifiers, jwtVerifier)
+	}
+
+	serverOptions, err := transportOptions(cfg)
+	if err != nil {
+		fatal("tls setup failed", "err", err)
+	}
+	panicRecorders := []grpcx.PanicRecorder{hubService}
+	if selfMetrics != nil {
+		panicRecorders = append(panicRecorders, selfMetrics)
+	}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		grpcx.RequestIDUnaryInterceptor(),
+		grpcx.TracingUnaryInterceptor(),
+		grpcx.RecoveryUnaryInterceptor(panicRecorders...),
+		grpcx.APIVersionUnaryInterceptor(),
+		grpcx.LatencyUnaryInterceptor(latencyObserver),
+		grpcx.AuthUnaryInterceptor(cfg.AuthToken, cfg.AllowLegacyAuth, keyAuth, verifiers...),
+		grpcx.RateLimitUnaryInterceptor(rateLimiter),
+		grpcx.LoggingUnaryInterceptor(),
+		grpcx.ErrorUnaryInterceptor(),
+		grpcx.WarningUnaryInterceptor(),
+		grpcx.IdempotencyUnaryInterceptor(idempotencyStore),
+	}
+	server := grpc.NewServer(append(serverOptions,
 		grpc.MaxRecvMsgSize(maxRecvMsgSizeBytes),
 		grpc.MaxSendMsgSize(maxSendMsgSizeBytes),
 		grpc.MaxConcurrentStreams(maxConcurrentStreams),
-		grpc.ChainUnaryInterceptor(
-			grpcx.RecoveryUnaryInterceptor(),
-			grpcx.AuthUnaryInterceptor(cfg.AuthToken, cfg.AllowLegacyAuth, keyAuth),
-			grpcx.RateLimitUnaryInterceptor(rateLimiter),
-			grpcx.LoggingUnaryInterceptor(),
-			grpcx.ErrorUnaryInterceptor(),
-			grpcx.IdempotencyUnaryInterceptor(idempotencyStore),
-		),
-	)
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+	)...)
 	grpcx.RegisterHubServer(server, handler)
+	httpAuth := httpx.Auth{
+		Token:         cfg.HTTPAuthToken,
+		BasicUser:     cfg.HTTPBasicAuthUser,
+		BasicPassword: cfg.HTTPBasicAuthPassword,
+	}
+	if httpAuth.BasicUser != "" && httpAuth.BasicPassword == "" {
+		fatal("HTTP_BASIC_AUTH_PASSWORD is required when HTTP_BASIC_AUTH_USER is set")
+	}
+	if cfg.HTTPAuthAgentKeys {
+		httpAuth.Keys = grpcx.NewAuthenticator(cfg.AuthToken, cfg.AllowLegacyAuth, keyAuth, verifiers...)
+	}
+	httpCORS := httpx.CORS{
+		AllowedOrigins: cfg.HTTPCORSOrigins,
+		AllowedHeaders: cfg.HTTPCORSHeaders,
+		MaxAge:         cfg.HTTPCORSMaxAge,
+	}
+	if err := httpCORS.Validate(); err != nil {
+		fatal("HTTP_CORS_ALLOWED_ORIGINS is invalid", "err", err)
+	}
+	if len(httpCORS.AllowedOrigins) > 0 {
+		slog.Info("HTTP CORS enabled", "origins", httpCORS.AllowedOrigins)
+	}
+	// The HTTP write API runs the same chain in process.
+	httpServer := httpx.NewServer(cfg.HTTPAddr, hubService, httpx.Options{
+		AllowRunCancel: cfg.HTTPAllowRunCancel,
+		Invoker:        grpcx.NewLocalInvoker(handler, unaryInterceptors...),
+		Auth:           httpAuth,
+		CORS:           httpCORS,
+		Dashboard: httpx.Dashboard{
+			Title:           cfg.DashboardTitle,
+			RefreshInterval: cfg.DashboardRefresh,
+		},
+	})
 
 	healthService := health.NewServer()
 	healthService.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
 	healthpb.RegisterHealthServer(server, healthService)
 
-	if cfg.EnableReflection {
-		reflection.Register(server)
-		log.Printf("gRPC reflection is enabled")
+	switch {
+	case cfg.EnableReflection && cfg.IsProduction():
+		slog.Warn("ENABLE_REFLECTION is ignored because MODELOMAN_ENV is production; set MODELOMAN_ENV to a non-production name such as development")
+	case cfg.EnableReflection:
+		if err := grpcx.RegisterReflection(server, modelomanv1.HubProto, modelomanv1.HubProtoPath, grpcx.ReflectionFilter{
+			Allow: cfg.ReflectionAllow,
+			Deny:  cfg.ReflectionDeny,
+		}); err != nil {
+			fatal("reflection setup failed", "err", err)
+		}
+		slog.Info("gRPC reflection is enabled", "env", cfg.Environment, "allow", cfg.ReflectionAllow, "deny", cfg.ReflectionDeny)
 	}
 
 	go func() {
-		log.Printf("ModeloMan gRPC server listening on %s", cfg.GRPCAddr)
-		log.Printf("Store driver=%s source=%s", cfg.StoreDriver, dataSource)
-		if keyAuth == nil && (!cfg.AllowLegacyAuth || strings.TrimSpace(cfg.AuthToken) == "") {
-			log.Printf("agent key auth is disabled and legacy AUTH_TOKEN auth is not enabled; private/write RPCs will return Unauthenticated.")
+		slog.Info("ModeloMan gRPC server listening", "addr", cfg.GRPCAddr)
+		slog.Info("store ready", "driver", cfg.StoreDriver, "source", dataSource)
+		switch {
+		case strings.TrimSpace(cfg.TLSClientCAFile) != "":
+			slog.Info("gRPC TLS is enabled and client certificates are required (mTLS)")
+		case strings.TrimSpace(cfg.TLSCertFile) != "":
+			slog.Info("gRPC TLS is enabled")
+		default:
+			slog.Warn("gRPC is listening in plaintext; set TLS_CERT_FILE and TLS_KEY_FILE to enable TLS")
+		}
+		if keyAuth == nil && len(verifiers) == 0 && (!cfg.AllowLegacyAuth || strings.TrimSpace(cfg.AuthToken) == "") {
+			slog.Warn("agent key auth is disabled and legacy AUTH_TOKEN auth is not enabled; private/write RPCs will return Unauthenticated")
 		}
 		if keyAuth != nil {
-			log.Printf("Per-agent API key auth is enabled for private/write methods.")
+			slog.Info("per-agent API key auth is enabled for private/write methods")
+		}
+		if len(verifiers) > 0 {
+			slog.Info("JWT bearer auth is enabled", "issuer", cfg.JWTIssuer, "audience", cfg.JWTAudience)
 		}
 		if strings.TrimSpace(cfg.AuthToken) != "" && !cfg.AllowLegacyAuth {
-			log.Printf("AUTH_TOKEN is set but ignored because ALLOW_LEGACY_AUTH_TOKEN is false.")
+			slog.Warn("AUTH_TOKEN is set but ignored because ALLOW_LEGACY_AUTH_TOKEN is false")
 		}
 		if cfg.AllowLegacyAuth && strings.TrimSpace(cfg.AuthToken) != "" {
-			log.Printf("Legacy shared AUTH_TOKEN fallback is enabled.")
+			slog.Info("legacy shared AUTH_TOKEN fallback is enabled")
 		}
 		if err := server.Serve(listener); err != nil {
-			log.Fatalf("grpc serve failed: %v", err)
+			fatal("grpc serve failed", "err", err)
 		}
 	}()
 
@@ -127,21 +300,284 @@ func main() {
 		if strings.TrimSpace(cfg.HTTPAddr) == "" {
 			return
 		}
-		log.Printf("ModeloMan HTTP dashboard listening on %s", cfg.HTTPAddr)
+		slog.Info("ModeloMan HTTP dashboard listening", "addr", cfg.HTTPAddr)
+		if httpAuth.Token == "" && httpAuth.Keys == nil && httpAuth.BasicUser == "" {
+			slog.Warn("dashboard APIs are unauthenticated; set HTTP_AUTH_TOKEN, HTTP_AUTH_AGENT_KEYS or HTTP_BASIC_AUTH_USER to require credentials")
+		} else {
+			slog.Info("dashboard API auth is enabled", "dashboard_token", httpAuth.Token != "", "agent_keys", httpAuth.Keys != nil, "basic", httpAuth.BasicUser != "")
+		}
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("http serve failed: %v", err)
+			fatal("http serve failed", "err", err)
 		}
 	}()
 
+	scheduleCtx, stopSchedule := context.WithCancel(context.Background())
+	defer stopSchedule()
+	go runPolicyScheduler(scheduleCtx, hubService, cfg.PolicyScheduleInterval)
+	if cfg.RunEventsRetentionDays > 0 || cfg.AttemptsRetentionDays > 0 {
+		slog.Info("retention pruning enabled",
+			"run_events_days", cfg.RunEventsRetentionDays, "attempts_days", cfg.AttemptsRetentionDays, "every", cfg.PruneInterval)
+		go runRetentionPruner(scheduleCtx, hubService, cfg.PruneInterval)
+	}
+	if cfg.ArchiveAfterDays > 0 {
+		slog.Info("cold storage enabled", "archive_after_days", cfg.ArchiveAfterDays, "every", cfg.PruneInterval)
+		go runArchiver(scheduleCtx, hubService, cfg.PruneInterval)
+	}
+	go runRollupRebuilder(scheduleCtx, hubService)
+	if selfMetrics != nil {
+		slog.Info("self metrics enabled", "every", cfg.SelfMetricsInterval, "workflow", service.SelfMetricsWorkflow)
+		go runSelfMetrics(scheduleCtx, hubService, selfMetrics, cfg.SelfMetricsInterval)
+	}
+
 	waitForShutdown(server, httpServer)
 }
 
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// runMigrate implements `modeloman-server migrate [-baseline VERSION]
+// [-contract]`: it applies the embedded migrations to DATABASE_URL and exits.
+func runMigrate(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	baseline := flags.String("baseline", "", "record migrations up to this version (e.g. 015) as applied without running them; for databases migrated by hand")
+	contract := flags.Bool("contract", false, "also apply contract migrations; only once every replica runs this release")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimSpace(cfg.StoreDriver), "postgres") {
+		return fmt.Errorf("migrate needs STORE_DRIVER=postgres (got %q)", cfg.StoreDriver)
+	}
+	hubStore, _, err := buildStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer hubStore.Close()
+	// The primary comes first, so shard schemas find the timescaledb
+	// extension it creates.
+	for _, pgStore := range postgresStores(hubStore) {
+		if err := migratePostgres(pgStore, store.MigrateOptions{Baseline: *baseline, Contract: *contract}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migratePostgres(pgStore *store.PostgresStore, options store.MigrateOptions) error {
+	migrations, err := fs.Sub(db.Migrations, "migrations")
+	if err != nil {
+		return err
+	}
+	result, err := pgStore.Migrate(migrations, options)
+	for _, name := range result.Applied {
+		slog.Info("applied migration", "migration", name, "schema", pgStore.Schema())
+	}
+	if err != nil {
+		return err
+	}
+	for _, name := range result.Held {
+		slog.Warn("held contract migration; run `modeloman-server migrate -contract` once every replica runs this release", "migration", name, "schema", pgStore.Schema())
+	}
+	if len(result.Applied) == 0 && len(result.Held) == 0 {
+		slog.Info("database schema is up to date", "schema", pgStore.Schema())
+	}
+	return nil
+}
+
+// postgresStores lists the Postgres stores behind hubStore, the primary
+// first and then any project shards.
+func postgresStores(hubStore store.HubStore) []*store.PostgresStore {
+	stores := []store.HubStore{hubStore}
+	if sharded, ok := hubStore.(*store.ShardedStore); ok {
+		stores = sharded.Stores()
+	}
+	out := []*store.PostgresStore{}
+	for _, candidate := range stores {
+		if pgStore, ok := candidate.(*store.PostgresStore); ok {
+			out = append(out, pgStore)
+		}
+	}
+	return out
+}
+
+// runPolicyScheduler flips the scheduled kill switch as maintenance windows
+// open and close.
+func runPolicyScheduler(ctx context.Context, hubService *service.HubService, interval time.Duration) {
+	evaluate := func() {
+		policy, changed, err := hubService.EvaluatePolicySchedule(ctx, time.Now())
+		if err != nil {
+			slog.Error("policy schedule evaluation failed", "err", err)
+			return
+		}
+		if !changed {
+			return
+		}
+		if policy.ScheduledKillSwitch {
+			slog.Warn("scheduled kill switch engaged", "reason", policy.ScheduledKillSwitchReason)
+		} else {
+			slog.Info("scheduled kill switch released")
+		}
+	}
+
+	evaluate()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			evaluate()
+		}
+	}
+}
+
+// runRetentionPruner drops run events and attempts older than the configured
+// retention, once at startup and then every interval.
+func runRetentionPruner(ctx context.Context, hubService *service.HubService, interval time.Duration) {
+	prune := func() {
+		result, _, err := hubService.PruneExpired(ctx, time.Now())
+		if err != nil {
+			slog.Error("retention prune failed", "err", err)
+			return
+		}
+		if result.RunEventsDeleted > 0 || result.AttemptsDeleted > 0 || result.ChunksDropped > 0 {
+			slog.Info("retention prune",
+				"run_events_deleted", result.RunEventsDeleted, "attempts_deleted", result.AttemptsDeleted, "chunks_dropped", result.ChunksDropped)
+		}
+	}
+
+	prune()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
+
+// runArchiver moves attempts and run events past ARCHIVE_AFTER_DAYS to cold
+// storage, once at startup and then every interval.
+func runArchiver(ctx context.Context, hubService *service.HubService, interval time.Duration) {
+	archive := func() {
+		result, _, err := hubService.ArchiveExpired(ctx, time.Now())
+		if err != nil {
+			slog.Error("cold storage failed", "err", err)
+			return
+		}
+		if result.AttemptsArchived > 0 || result.RunEventsArchived > 0 {
+			slog.Info("cold storage",
+				"attempts_archived", result.AttemptsArchived, "run_events_archived", result.RunEventsArchived)
+		}
+	}
+
+	archive()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			archive()
+		}
+	}
+}
+
+// rollupRebuildDelay is how long after UTC midnight the previous day's
+// rollups are rebuilt, leaving time for buffered and late attempts to land.
+const rollupRebuildDelay = 15 * time.Minute
+
+// runRollupRebuilder recounts the previous UTC day's rollups once at startup
+// and then shortly after each UTC midnight.
+func runRollupRebuilder(ctx context.Context, hubService *service.HubService) {
+	rebuild := func() {
+		day := time.Now().UTC().Add(-24 * time.Hour)
+		if err := hubService.RebuildDailyRollups(ctx, day); err != nil {
+			slog.Error("daily rollup rebuild failed", "day", day.Format(time.DateOnly), "err", err)
+		}
+	}
+
+	rebuild()
+	for {
+		now := time.Now().UTC()
+		next := now.Truncate(24 * time.Hour).Add(rollupRebuildDelay)
+		if !next.After(now) {
+			next = next.Add(24 * time.Hour)
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			rebuild()
+		}
+	}
+}
+
+// runSelfMetrics probes store latency about 30 times per interval and writes
+// the collected RPC and store latencies as benchmark rows every interval.
+func runSelfMetrics(ctx context.Context, hubService *service.HubService, metrics *service.SelfMetrics, interval time.Duration) {
+	probe := time.NewTicker(max(interval/30, time.Second))
+	defer probe.Stop()
+	record := time.NewTicker(interval)
+	defer record.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-probe.C:
+			if err := hubService.ProbeStoreLatency(ctx, metrics); err != nil {
+				slog.Error("self metrics store probe failed", "err", err)
+			}
+		case <-record.C:
+			if _, err := hubService.RecordSelfMetrics(ctx, metrics); err != nil {
+				slog.Error("self metrics recording failed", "err", err)
+			}
+		}
+	}
+}
+
+// setupTracing exports spans over OTLP/HTTP JSON when an OTLP endpoint is
+// configured. The returned function flushes queued spans.
+func setupTracing(cfg config.Config) (func(), error) {
+	if cfg.OTLPTracesEndpoint == "" {
+		return func() {}, nil
+	}
+	if cfg.OTLPProtocol != "http/json" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL %q is not supported; use http/json", cfg.OTLPProtocol)
+	}
+	exporter := tracing.NewExporter(tracing.ExporterConfig{
+		Endpoint:           cfg.OTLPTracesEndpoint,
+		Headers:            tracing.ParseKeyValues(cfg.OTLPHeaders),
+		ServiceName:        cfg.OTELServiceName,
+		ResourceAttributes: tracing.ParseKeyValues(cfg.OTELResourceAttributes),
+	})
+	tracing.SetProvider(tracing.NewProvider(exporter, cfg.TraceSampleRatio))
+	slog.Info("tracing enabled", "endpoint", cfg.OTLPTracesEndpoint, "service", cfg.OTELServiceName, "sample_ratio", cfg.TraceSampleRatio)
+	return func() {
+		tracing.SetProvider(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := exporter.Shutdown(ctx); err != nil {
+			slog.Warn("tracing flush failed", "err", err)
+		}
+	}, nil
+}
+
 func waitForShutdown(server *grpc.Server, httpServer *http.Server) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	<-sigCh
 
-	log.Println("shutdown signal received; draining gRPC server")
+	slog.Info("shutdown signal received; draining gRPC server")
 	done := make(chan struct{})
 	go func() {
 		server.GracefulStop()
@@ -150,32 +586,128 @@ func waitForShutdown(server *grpc.Server, httpServer *http.Server) {
 
 	select {
 	case <-done:
-		log.Println("gRPC server stopped gracefully")
+		slog.Info("gRPC server stopped gracefully")
 	case <-time.After(5 * time.Second):
-		log.Println("graceful timeout reached; forcing stop")
+		slog.Warn("graceful timeout reached; forcing stop")
 		server.Stop()
 	}
 	if httpServer != nil {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := httpServer.Shutdown(shutdownCtx); err != nil {
-			log.Printf("http shutdown warning: %v", err)
+			slog.Warn("http shutdown failed", "err", err)
+		}
+	}
+
+}
+
+// transportOptions returns the gRPC credentials option when TLS is
+// configured. A client CA without a server certificate is rejected rather
+// than silently serving plaintext.
+func transportOptions(cfg config.Config) ([]grpc.ServerOption, error) {
+	if strings.TrimSpace(cfg.TLSCertFile) == "" && strings.TrimSpace(cfg.TLSKeyFile) == "" {
+		if strings.TrimSpace(cfg.TLSClientCAFile) != "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
 		}
+		return nil, nil
 	}
+	tlsCfg, err := tlsconfig.Server(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsCfg))}, nil
+}
 
+// buildArtifactBlobStore keeps artifact bytes in memory alongside the memory
+// store, so STORE_DRIVER=memory writes nothing to disk.
+func buildArtifactBlobStore(cfg config.Config) store.ArtifactBlobStore {
+	if strings.EqualFold(strings.TrimSpace(cfg.StoreDriver), "memory") {
+		return store.NewMemoryArtifactBlobStore()
+	}
+	return store.NewDiskArtifactBlobStore(cfg.ArtifactDir)
 }
 
 func buildStore(cfg config.Config) (store.HubStore, string, error) {
-	switch strings.ToLower(strings.TrimSpace(cfg.StoreDriver)) {
+	driver := strings.ToLower(strings.TrimSpace(cfg.StoreDriver))
+	if len(cfg.ProjectShards) > 0 && driver != "postgres" {
+		return nil, "", fmt.Errorf("PROJECT_SHARDS needs STORE_DRIVER=postgres (got %q)", cfg.StoreDriver)
+	}
+	switch driver {
 	case "postgres":
-		pgStore, err := store.NewPostgresStore(cfg.DatabaseURL)
+		pgStore, err := openPostgres(cfg, "")
 		if err != nil {
 			return nil, "", err
 		}
-		return pgStore, "postgres", nil
+		if len(cfg.ProjectShards) == 0 {
+			return pgStore, "postgres", nil
+		}
+		sharded, err := buildShardedStore(cfg, pgStore)
+		if err != nil {
+			_ = pgStore.Close()
+			return nil, "", err
+		}
+		return sharded, "postgres", nil
 	case "", "file":
 		return store.NewFileStore(cfg.DataFile), cfg.DataFile, nil
+	case "memory":
+		return store.NewMemoryStore(), "memory", nil
 	default:
-		return nil, "", fmt.Errorf("unsupported STORE_DRIVER %q; expected file|postgres", cfg.StoreDriver)
+		return nil, "", fmt.Errorf("unsupported STORE_DRIVER %q; expected file|postgres|memory", cfg.StoreDriver)
+	}
+}
+
+// openPostgres opens the primary store, or with schema set a shard store,
+// with the configured timeouts, compression, compatibility mode and replica.
+func openPostgres(cfg config.Config, schema string) (*store.PostgresStore, error) {
+	var pgStore *store.PostgresStore
+	var err error
+	if schema == "" {
+		pgStore, err = store.NewPostgresStore(cfg.DatabaseURL)
+	} else {
+		pgStore, err = store.NewPostgresShardStore(cfg.DatabaseURL, schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pgStore.SetCompressAfterDays(cfg.CompressAfterDays)
+	pgStore.SetQueryTimeout(cfg.DatabaseQueryTimeout)
+	pgStore.SetSchemaCompat(cfg.SchemaCompat)
+	if err := pgStore.SetReadReplica(cfg.DatabaseReadURL, cfg.DatabaseReadMethods); err != nil {
+		_ = pgStore.Close()
+		return nil, err
+	}
+	return pgStore, nil
+}
+
+// buildShardedStore opens a store per PROJECT_SHARDS schema and routes
+// their projects to them, leaving the rest on primary.
+func buildShardedStore(cfg config.Config, primary *store.PostgresStore) (*store.ShardedStore, error) {
+	routes, err := store.ParseProjectShards(cfg.ProjectShards)
+	if err != nil {
+		return nil, err
+	}
+	shards := map[string]store.HubStore{}
+	closeShards := func() {
+		for _, shard := range shards {
+			_ = shard.Close()
+		}
+	}
+	for _, schema := range routes {
+		if _, ok := shards[schema]; ok {
+			continue
+		}
+		shard, err := openPostgres(cfg, schema)
+		if err != nil {
+			closeShards()
+			return nil, err
+		}
+		shards[schema] = shard
+	}
+	sharded, err := store.NewShardedStore(primary, shards, routes)
+	if err != nil {
+		closeShards()
+		return nil, err
 	}
+	slog.Info("project sharding enabled", "projects", len(routes), "schemas", len(shards))
+	return sharded, nil
 }
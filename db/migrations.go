@@ -0,0 +1,10 @@
+// Package db embeds the versioned Postgres migrations so the server binary
+// can apply them itself (see `modeloman-server migrate`).
+package db
+
+import "embed"
+
+// Migrations holds migrations/NNN_name.sql, applied in file name order.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,9 @@
+-- Free-form key/value labels on runs and attempts (ticket IDs, environments, experiments).
+
+ALTER TABLE agent_runs
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'::JSONB;
+
+ALTER TABLE prompt_attempts
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'::JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_agent_runs_metadata ON agent_runs USING GIN (metadata jsonb_path_ops);
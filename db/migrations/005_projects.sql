@@ -0,0 +1,18 @@
+-- Project dimension for multi-team hubs. Existing rows land in the 'default' project;
+-- policy caps with an empty project stay hub-wide.
+
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE agent_runs
+    ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE prompt_attempts
+    ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE policy_caps
+    ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_tasks_project_updated_at ON tasks (project, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_agent_runs_project_started_at ON agent_runs (project, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_prompt_attempts_project_created_at ON prompt_attempts (project, created_at DESC);
@@ -0,0 +1,16 @@
+-- Prompt version pinning history. The newest row per (project, workflow) is the
+-- active version StartRun adopts when a client omits prompt_version.
+
+CREATE TABLE IF NOT EXISTS prompt_releases (
+    id TEXT PRIMARY KEY,
+    project TEXT NOT NULL DEFAULT 'default',
+    workflow TEXT NOT NULL,
+    prompt_version TEXT NOT NULL,
+    previous_version TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_prompt_releases_workflow_created_at ON prompt_releases (project, workflow, created_at DESC);
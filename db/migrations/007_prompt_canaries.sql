@@ -0,0 +1,9 @@
+-- Canary rollout for prompt releases. While canary_version is set, new runs
+-- that omit prompt_version are split between it and prompt_version by
+-- canary_percent; the hub rolls the canary back automatically when its
+-- finished-run success rate trails the incumbent by more than canary_margin.
+
+ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_percent BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_margin DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_min_runs BIGINT NOT NULL DEFAULT 0;
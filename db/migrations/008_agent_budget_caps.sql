@@ -0,0 +1,8 @@
+-- Per-agent budget caps. agent_id narrows a cap to one agent; the day/month
+-- limits are rolling 24h/30d spend windows summed over that agent's attempts.
+
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS agent_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS max_cost_per_month_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_prompt_attempts_agent_created_at ON prompt_attempts (agent_id, created_at DESC);
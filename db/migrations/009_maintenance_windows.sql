@@ -0,0 +1,7 @@
+-- Scheduled kill switch. maintenance_windows holds the schedule set through
+-- SetPolicy; the server's evaluator keeps scheduled_kill_switch in sync with
+-- whichever window is open.
+
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS maintenance_windows JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS scheduled_kill_switch BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS scheduled_kill_switch_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,18 @@
+-- Audit trail for SetPolicy/UpsertPolicyCap/DeletePolicyCap and scheduled
+-- kill-switch flips. before_json/after_json hold the record around the change.
+
+CREATE TABLE IF NOT EXISTS policy_audit (
+    id TEXT PRIMARY KEY,
+    project TEXT NOT NULL DEFAULT '',
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    actor_agent_id TEXT NOT NULL DEFAULT '',
+    actor_key_id TEXT NOT NULL DEFAULT '',
+    before_json TEXT NOT NULL DEFAULT '',
+    after_json TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_audit_created_at ON policy_audit (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_policy_audit_target ON policy_audit (target_type, target_id, created_at DESC);
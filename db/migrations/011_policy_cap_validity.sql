@@ -0,0 +1,5 @@
+-- Expiring policy caps. A cap only applies between valid_from and
+-- valid_until; NULL leaves that side of the window open.
+
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ NULL;
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS valid_until TIMESTAMPTZ NULL;
@@ -0,0 +1,4 @@
+-- Workflow-scoped policy caps. workflow narrows a cap to attempts recorded
+-- for one workflow and counts toward cap specificity like agent_id.
+
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS workflow TEXT NOT NULL DEFAULT '';
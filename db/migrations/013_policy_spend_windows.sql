@@ -0,0 +1,6 @@
+-- Rolling-window spend limits on the global policy. RecordPromptAttempt sums
+-- hub-wide attempt cost over the last hour and day and rejects attempts that
+-- would pass either limit. 0 means unlimited.
+
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS max_cost_per_hour_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
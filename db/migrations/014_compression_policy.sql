@@ -0,0 +1,28 @@
+-- Re-tune compression on prompt_attempts and run_events (002 sets 7 days).
+-- Run with the table owner, e.g.:
+--   psql "$DATABASE_URL_ADMIN" -v compress_after='30 days' -f db/migrations/014_compression_policy.sql
+-- Set COMPRESS_AFTER_DAYS to the same value so startup verifies it; the app
+-- role then needs no DDL privileges.
+
+\if :{?compress_after}
+\else
+\set compress_after '7 days'
+\endif
+
+ALTER TABLE prompt_attempts
+SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'run_id,workflow,agent_id,model,outcome'
+);
+
+ALTER TABLE run_events
+SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'run_id,event_type,level'
+);
+
+SELECT remove_compression_policy('prompt_attempts', if_exists => TRUE);
+SELECT add_compression_policy('prompt_attempts', :'compress_after'::interval);
+
+SELECT remove_compression_policy('run_events', if_exists => TRUE);
+SELECT add_compression_policy('run_events', :'compress_after'::interval);
@@ -0,0 +1,6 @@
+-- Routing policy caps. kind = 'routing' caps carry a routing preference
+-- (preferred provider type under a cost, fallback provider type and quality
+-- target) that RecommendModel applies instead of enforcing limits.
+
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'limit';
+ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS routing JSONB NULL;
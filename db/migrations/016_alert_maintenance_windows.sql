@@ -0,0 +1,5 @@
+-- Alert maintenance windows. alert_maintenance_windows uses the same window
+-- format as maintenance_windows but only silences webhook alerts while open;
+-- runs are not blocked.
+
+ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS alert_maintenance_windows JSONB NOT NULL DEFAULT '[]'::jsonb;
@@ -0,0 +1,37 @@
+-- Cold storage for old prompt attempts and run events. ARCHIVE_AFTER_DAYS
+-- moves rows out of the hot hypertables into these archive hypertables,
+-- which use long chunks and compress after a day, so the hot tables and
+-- their indexes stay small. List RPCs read the archive only when asked to
+-- with include_archived.
+
+CREATE TABLE IF NOT EXISTS prompt_attempts_archive (
+    LIKE prompt_attempts INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+    PRIMARY KEY (id, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS run_events_archive (
+    LIKE run_events INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+    PRIMARY KEY (id, created_at)
+);
+
+SELECT create_hypertable('prompt_attempts_archive', 'created_at', chunk_time_interval => INTERVAL '30 days', if_not_exists => TRUE, migrate_data => TRUE);
+SELECT create_hypertable('run_events_archive', 'created_at', chunk_time_interval => INTERVAL '30 days', if_not_exists => TRUE, migrate_data => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_prompt_attempts_archive_run_created_at ON prompt_attempts_archive (run_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_prompt_attempts_archive_project_created_at ON prompt_attempts_archive (project, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_run_events_archive_run_created_at ON run_events_archive (run_id, created_at DESC);
+
+ALTER TABLE prompt_attempts_archive
+SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'run_id,workflow,agent_id,model,outcome'
+);
+
+ALTER TABLE run_events_archive
+SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'run_id,event_type,level'
+);
+
+SELECT add_compression_policy('prompt_attempts_archive', INTERVAL '1 day', if_not_exists => TRUE);
+SELECT add_compression_policy('run_events_archive', INTERVAL '1 day', if_not_exists => TRUE);
@@ -0,0 +1,13 @@
+-- HyperLogLog sketches behind the distinct counts in GetTelemetrySummary:
+-- one row per UTC day and dimension (models, prompt_hashes, agents) holding
+-- 4096 one-byte registers. Servers merge new attempts into them as they are
+-- written, so the summary never runs COUNT(DISTINCT ...) over
+-- prompt_attempts. Attempts written before this migration are not counted.
+
+CREATE TABLE IF NOT EXISTS telemetry_sketches (
+    day DATE NOT NULL,
+    dimension TEXT NOT NULL,
+    registers BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, dimension)
+);
@@ -0,0 +1,42 @@
+-- Per-day attempt totals by project, workflow, provider, model and prompt
+-- version. Servers update them in the transaction that writes each attempt
+-- and rebuild the previous day nightly; leaderboards and cost series read
+-- them instead of scanning prompt_attempts. Existing attempts, archived ones
+-- included, are counted here.
+
+CREATE TABLE IF NOT EXISTS attempt_daily_rollups (
+    day DATE NOT NULL,
+    project TEXT NOT NULL,
+    workflow TEXT NOT NULL,
+    provider_type TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    prompt_version TEXT NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    success_attempts BIGINT NOT NULL DEFAULT 0,
+    tokens_in BIGINT NOT NULL DEFAULT 0,
+    tokens_out BIGINT NOT NULL DEFAULT 0,
+    cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, project, workflow, provider_type, provider, model, prompt_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attempt_daily_rollups_project_day ON attempt_daily_rollups (project, day);
+
+INSERT INTO attempt_daily_rollups (
+    day, project, workflow, provider_type, provider, model, prompt_version,
+    attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+)
+SELECT (created_at AT TIME ZONE 'UTC')::date, project, workflow, provider_type, provider, model, prompt_version,
+       COUNT(*), COUNT(*) FILTER (WHERE outcome = 'success'),
+       SUM(tokens_in), SUM(tokens_out), SUM(cost_usd), SUM(latency_ms)
+FROM (
+    SELECT created_at, project, workflow, provider_type, provider, model, prompt_version, outcome, tokens_in, tokens_out, cost_usd, latency_ms
+    FROM prompt_attempts
+    UNION ALL
+    SELECT created_at, project, workflow, provider_type, provider, model, prompt_version, outcome, tokens_in, tokens_out, cost_usd, latency_ms
+    FROM prompt_attempts_archive
+) attempts
+GROUP BY 1, 2, 3, 4, 5, 6, 7
+ON CONFLICT DO NOTHING;
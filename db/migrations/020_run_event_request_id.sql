@@ -0,0 +1,6 @@
+-- Request IDs on run events. The server stores the x-request-id of the call
+-- that recorded each event, so an event links back to that call's log lines.
+-- Older events keep an empty request_id.
+
+ALTER TABLE run_events ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE run_events_archive ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
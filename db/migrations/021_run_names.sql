@@ -0,0 +1,5 @@
+-- Human-readable run names and descriptions, set by StartRun and shown in
+-- run lists and the dashboard. Older runs keep empty ones.
+
+ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
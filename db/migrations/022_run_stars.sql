@@ -0,0 +1,7 @@
+-- Starred runs, bookmarked with StarRun for later review and listed with
+-- ListRuns starred=true.
+
+ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS starred BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS starred_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_agent_runs_starred ON agent_runs (started_at DESC) WHERE starred;
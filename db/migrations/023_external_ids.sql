@@ -0,0 +1,10 @@
+-- Caller-supplied external IDs on runs and tasks, set by StartRun and
+-- CreateTask so records can be found by an orchestrator's own identifiers.
+-- They are unique per project; older rows keep empty ones, which the indexes
+-- leave out.
+
+ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_runs_external_id ON agent_runs (project, external_id) WHERE external_id <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_external_id ON tasks (project, external_id) WHERE external_id <> '';
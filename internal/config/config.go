@@ -3,36 +3,177 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	GRPCAddr          string
-	HTTPAddr          string
-	StoreDriver       string
-	DataFile          string
-	DatabaseURL       string
-	AuthToken         string
-	AllowLegacyAuth   bool
-	EnableReflection  bool
-	BootstrapAgentID  string
-	BootstrapAgentKey string
+	GRPCAddr               string
+	HTTPAddr               string
+	HTTPAllowRunCancel     bool
+	HTTPAuthToken          string
+	HTTPAuthAgentKeys      bool
+	HTTPBasicAuthUser      string
+	HTTPBasicAuthPassword  string
+	HTTPCORSOrigins        []string
+	HTTPCORSHeaders        []string
+	HTTPCORSMaxAge         time.Duration
+	DashboardTitle         string
+	DashboardRefresh       time.Duration
+	StoreDriver            string
+	DataFile               string
+	DatabaseURL            string
+	DatabaseReadURL        string
+	DatabaseReadMethods    []string
+	DatabaseQueryTimeout   time.Duration
+	ProjectShards          []string
+	AuthToken              string
+	AllowLegacyAuth        bool
+	EnableReflection       bool
+	Environment            string
+	ReflectionAllow        []string
+	ReflectionDeny         []string
+	BootstrapAgentID       string
+	BootstrapAgentKey      string
+	ArtifactDir            string
+	ArtifactMaxBytes       int64
+	ShareLinkSecret        string
+	ShareLinkMaxTTL        time.Duration
+	RunIDPrefix            string
+	TaskIDPrefix           string
+	PolicyScheduleInterval time.Duration
+	RunEventsRetentionDays int64
+	AttemptsRetentionDays  int64
+	PruneInterval          time.Duration
+	ArchiveAfterDays       int64
+	ListMaxLimit           int64
+	ListMaxWindowDays      int64
+	CompressAfterDays      int64
+	AutoMigrate            bool
+	SchemaCompat           bool
+	IngestBufferSize       int64
+	IngestBatchSize        int64
+	IngestFlushInterval    time.Duration
+	AlertWebhookURL        string
+	AlertWindow            time.Duration
+	PolicyWebhookURL       string
+	PolicyWebhookSecret    string
+	PolicyWebhookTimeout   time.Duration
+	PolicyWebhookFailOpen  bool
+	SelfMetricsInterval    time.Duration
+	TLSCertFile            string
+	TLSKeyFile             string
+	TLSClientCAFile        string
+	JWTIssuer              string
+	JWTAudience            string
+	JWTJWKSURL             string
+	JWTAgentIDClaim        string
+	JWTScopesClaim         string
+	JWTScopePrefix         string
+	OTLPTracesEndpoint     string
+	OTLPHeaders            []string
+	OTLPProtocol           string
+	OTELServiceName        string
+	OTELResourceAttributes []string
+	TraceSampleRatio       float64
+	LogLevel               string
+	LogFormat              string
 }
 
 func Load() Config {
 	return Config{
-		GRPCAddr:          envOrDefault("GRPC_ADDR", "127.0.0.1:50051"),
-		HTTPAddr:          envOrDefault("HTTP_ADDR", "127.0.0.1:8080"),
-		StoreDriver:       envOrDefault("STORE_DRIVER", "file"),
-		DataFile:          envOrDefault("DATA_FILE", "./data/modeloman.db.json"),
-		DatabaseURL:       os.Getenv("DATABASE_URL"),
-		AuthToken:         os.Getenv("AUTH_TOKEN"),
-		AllowLegacyAuth:   envBoolOrDefault("ALLOW_LEGACY_AUTH_TOKEN", false),
-		EnableReflection:  envBoolOrDefault("ENABLE_REFLECTION", false),
-		BootstrapAgentID:  envOrDefault("BOOTSTRAP_AGENT_ID", "orchestrator"),
-		BootstrapAgentKey: os.Getenv("BOOTSTRAP_AGENT_KEY"),
+		GRPCAddr:               envOrDefault("GRPC_ADDR", "127.0.0.1:50051"),
+		HTTPAddr:               envOrDefault("HTTP_ADDR", "127.0.0.1:8080"),
+		HTTPAllowRunCancel:     envBoolOrDefault("HTTP_ALLOW_RUN_CANCEL", false),
+		HTTPAuthToken:          strings.TrimSpace(os.Getenv("HTTP_AUTH_TOKEN")),
+		HTTPAuthAgentKeys:      envBoolOrDefault("HTTP_AUTH_AGENT_KEYS", false),
+		HTTPBasicAuthUser:      strings.TrimSpace(os.Getenv("HTTP_BASIC_AUTH_USER")),
+		HTTPBasicAuthPassword:  os.Getenv("HTTP_BASIC_AUTH_PASSWORD"),
+		HTTPCORSOrigins:        envList("HTTP_CORS_ALLOWED_ORIGINS"),
+		HTTPCORSHeaders:        envList("HTTP_CORS_ALLOWED_HEADERS"),
+		HTTPCORSMaxAge:         time.Duration(envInt64OrDefault("HTTP_CORS_MAX_AGE_SECONDS", 600)) * time.Second,
+		DashboardTitle:         envOrDefault("HTTP_DASHBOARD_TITLE", "ModeloMan"),
+		DashboardRefresh:       time.Duration(envInt64OrDefault("HTTP_DASHBOARD_REFRESH_SECONDS", 10)) * time.Second,
+		StoreDriver:            envOrDefault("STORE_DRIVER", "file"),
+		DataFile:               envOrDefault("DATA_FILE", "./data/modeloman.db.json"),
+		DatabaseURL:            os.Getenv("DATABASE_URL"),
+		DatabaseReadURL:        os.Getenv("DATABASE_READ_URL"),
+		DatabaseReadMethods:    envList("DATABASE_READ_METHODS"),
+		DatabaseQueryTimeout:   time.Duration(envInt64OrDefault("DATABASE_QUERY_TIMEOUT_SECONDS", 30)) * time.Second,
+		ProjectShards:          envList("PROJECT_SHARDS"),
+		AuthToken:              os.Getenv("AUTH_TOKEN"),
+		AllowLegacyAuth:        envBoolOrDefault("ALLOW_LEGACY_AUTH_TOKEN", false),
+		EnableReflection:       envBoolOrDefault("ENABLE_REFLECTION", false),
+		Environment:            strings.ToLower(envOrDefault("MODELOMAN_ENV", "production")),
+		ReflectionAllow:        envList("REFLECTION_ALLOW"),
+		ReflectionDeny:         envList("REFLECTION_DENY"),
+		BootstrapAgentID:       envOrDefault("BOOTSTRAP_AGENT_ID", "orchestrator"),
+		BootstrapAgentKey:      os.Getenv("BOOTSTRAP_AGENT_KEY"),
+		ArtifactDir:            envOrDefault("ARTIFACT_DIR", "./data/artifacts"),
+		ArtifactMaxBytes:       envInt64OrDefault("ARTIFACT_MAX_BYTES", 512*1024),
+		ShareLinkSecret:        os.Getenv("SHARE_LINK_SECRET"),
+		ShareLinkMaxTTL:        time.Duration(envInt64OrDefault("SHARE_LINK_MAX_TTL_HOURS", 168)) * time.Hour,
+		RunIDPrefix:            strings.TrimSpace(os.Getenv("RUN_ID_PREFIX")),
+		TaskIDPrefix:           strings.TrimSpace(os.Getenv("TASK_ID_PREFIX")),
+		PolicyScheduleInterval: time.Duration(envInt64OrDefault("POLICY_SCHEDULE_INTERVAL_SECONDS", 30)) * time.Second,
+		RunEventsRetentionDays: envInt64OrDefault("RUN_EVENTS_RETENTION_DAYS", 0),
+		AttemptsRetentionDays:  envInt64OrDefault("ATTEMPTS_RETENTION_DAYS", 0),
+		PruneInterval:          time.Duration(envInt64OrDefault("PRUNE_INTERVAL_SECONDS", 3600)) * time.Second,
+		ArchiveAfterDays:       envInt64OrDefault("ARCHIVE_AFTER_DAYS", 0),
+		ListMaxLimit:           envInt64OrDefault("LIST_MAX_LIMIT", 1000),
+		ListMaxWindowDays:      envInt64OrDefault("LIST_MAX_WINDOW_DAYS", 0),
+		CompressAfterDays:      envInt64OrDefault("COMPRESS_AFTER_DAYS", 0),
+		AutoMigrate:            envBoolOrDefault("AUTO_MIGRATE", false),
+		SchemaCompat:           envBoolOrDefault("SCHEMA_COMPAT", false),
+		IngestBufferSize:       envInt64OrDefault("INGEST_BUFFER_SIZE", 0),
+		IngestBatchSize:        envInt64OrDefault("INGEST_BATCH_SIZE", 200),
+		IngestFlushInterval:    time.Duration(envInt64OrDefault("INGEST_FLUSH_INTERVAL_MS", 250)) * time.Millisecond,
+		AlertWebhookURL:        os.Getenv("ALERT_WEBHOOK_URL"),
+		AlertWindow:            time.Duration(envInt64OrDefault("ALERT_WINDOW_SECONDS", 600)) * time.Second,
+		PolicyWebhookURL:       os.Getenv("POLICY_VALIDATION_WEBHOOK_URL"),
+		PolicyWebhookSecret:    os.Getenv("POLICY_VALIDATION_WEBHOOK_SECRET"),
+		PolicyWebhookTimeout:   time.Duration(envInt64OrDefault("POLICY_VALIDATION_TIMEOUT_MS", 3000)) * time.Millisecond,
+		PolicyWebhookFailOpen:  envBoolOrDefault("POLICY_VALIDATION_FAIL_OPEN", false),
+		SelfMetricsInterval:    time.Duration(envInt64OrDefault("SELF_METRICS_INTERVAL_SECONDS", 0)) * time.Second,
+		TLSCertFile:            os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:             os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile:        os.Getenv("TLS_CLIENT_CA_FILE"),
+		JWTIssuer:              os.Getenv("JWT_ISSUER"),
+		JWTAudience:            os.Getenv("JWT_AUDIENCE"),
+		JWTJWKSURL:             os.Getenv("JWT_JWKS_URL"),
+		JWTAgentIDClaim:        envOrDefault("JWT_AGENT_ID_CLAIM", "sub"),
+		JWTScopesClaim:         envOrDefault("JWT_SCOPES_CLAIM", "scope"),
+		JWTScopePrefix:         os.Getenv("JWT_SCOPE_PREFIX"),
+		OTLPTracesEndpoint:     tracesEndpoint(),
+		OTLPHeaders:            envList("OTEL_EXPORTER_OTLP_HEADERS"),
+		OTLPProtocol:           envOrDefault("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json"),
+		OTELServiceName:        envOrDefault("OTEL_SERVICE_NAME", "modeloman"),
+		OTELResourceAttributes: envList("OTEL_RESOURCE_ATTRIBUTES"),
+		TraceSampleRatio:       envFloat64OrDefault("OTEL_TRACES_SAMPLER_ARG", 1),
+		LogLevel:               envOrDefault("LOG_LEVEL", "info"),
+		LogFormat:              envOrDefault("LOG_FORMAT", "text"),
 	}
 }
 
+// IsProduction reports whether MODELOMAN_ENV is production, its default.
+// Development-only features such as reflection stay off in production.
+func (c Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// tracesEndpoint resolves the OTLP traces URL as OTel SDKs do: the
+// traces-specific variable as is, else the base endpoint plus /v1/traces.
+// Empty leaves tracing off.
+func tracesEndpoint() string {
+	if endpoint := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")); endpoint != "" {
+		return strings.TrimRight(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
 func envOrDefault(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -40,6 +181,17 @@ func envOrDefault(key, fallback string) string {
 	return fallback
 }
 
+// envList splits a comma-separated variable, dropping empty entries.
+func envList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 func envBoolOrDefault(key string, fallback bool) bool {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -51,3 +203,27 @@ func envBoolOrDefault(key string, fallback bool) bool {
 	}
 	return value
 }
+
+func envInt64OrDefault(key string, fallback int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func envFloat64OrDefault(key string, fallback float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
@@ -1,13 +1,22 @@
 package domain
 
+// DefaultProject is assigned to records created without an explicit project.
+const DefaultProject = "default"
+
+// TaskStatusArchived marks a soft-deleted task. Archived tasks are left out
+// of task lists unless asked for.
+const TaskStatusArchived = "archived"
+
 type Task struct {
-	ID        string   `json:"id"`
-	Title     string   `json:"title"`
-	Details   string   `json:"details"`
-	Status    string   `json:"status"`
-	Tags      []string `json:"tags"`
-	CreatedAt string   `json:"created_at"`
-	UpdatedAt string   `json:"updated_at"`
+	ID         string   `json:"id"`
+	Project    string   `json:"project"`
+	ExternalID string   `json:"external_id"`
+	Title      string   `json:"title"`
+	Details    string   `json:"details"`
+	Status     string   `json:"status"`
+	Tags       []string `json:"tags"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
 }
 
 type Note struct {
@@ -43,46 +52,57 @@ type Benchmark struct {
 }
 
 type AgentRun struct {
-	ID              string  `json:"id"`
-	TaskID          string  `json:"task_id"`
-	Workflow        string  `json:"workflow"`
-	AgentID         string  `json:"agent_id"`
-	PromptVersion   string  `json:"prompt_version"`
-	ModelPolicy     string  `json:"model_policy"`
-	Status          string  `json:"status"`
-	MaxRetries      int64   `json:"max_retries"`
-	TotalAttempts   int64   `json:"total_attempts"`
-	SuccessAttempts int64   `json:"success_attempts"`
-	FailedAttempts  int64   `json:"failed_attempts"`
-	TotalTokensIn   int64   `json:"total_tokens_in"`
-	TotalTokensOut  int64   `json:"total_tokens_out"`
-	TotalCostUSD    float64 `json:"total_cost_usd"`
-	DurationMS      int64   `json:"duration_ms"`
-	LastError       string  `json:"last_error"`
-	StartedAt       string  `json:"started_at"`
-	FinishedAt      string  `json:"finished_at"`
+	ID              string            `json:"id"`
+	ExternalID      string            `json:"external_id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Project         string            `json:"project"`
+	TaskID          string            `json:"task_id"`
+	Workflow        string            `json:"workflow"`
+	AgentID         string            `json:"agent_id"`
+	PromptVersion   string            `json:"prompt_version"`
+	ModelPolicy     string            `json:"model_policy"`
+	Status          string            `json:"status"`
+	MaxRetries      int64             `json:"max_retries"`
+	TotalAttempts   int64             `json:"total_attempts"`
+	SuccessAttempts int64             `json:"success_attempts"`
+	FailedAttempts  int64             `json:"failed_attempts"`
+	TotalTokensIn   int64             `json:"total_tokens_in"`
+	TotalTokensOut  int64             `json:"total_tokens_out"`
+	TotalCostUSD    float64           `json:"total_cost_usd"`
+	DurationMS      int64             `json:"duration_ms"`
+	LastError       string            `json:"last_error"`
+	Metadata        map[string]string `json:"metadata"`
+	StartedAt       string            `json:"started_at"`
+	FinishedAt      string            `json:"finished_at"`
+	// Starred bookmarks the run for later review; StarredAt is when it was
+	// starred, empty while it is not.
+	Starred   bool   `json:"starred"`
+	StarredAt string `json:"starred_at"`
 }
 
 type PromptAttempt struct {
-	ID            string  `json:"id"`
-	RunID         string  `json:"run_id"`
-	AttemptNumber int64   `json:"attempt_number"`
-	Workflow      string  `json:"workflow"`
-	AgentID       string  `json:"agent_id"`
-	ProviderType  string  `json:"provider_type"`
-	Provider      string  `json:"provider"`
-	Model         string  `json:"model"`
-	PromptVersion string  `json:"prompt_version"`
-	PromptHash    string  `json:"prompt_hash"`
-	Outcome       string  `json:"outcome"`
-	ErrorType     string  `json:"error_type"`
-	ErrorMessage  string  `json:"error_message"`
-	TokensIn      int64   `json:"tokens_in"`
-	TokensOut     int64   `json:"tokens_out"`
-	CostUSD       float64 `json:"cost_usd"`
-	LatencyMS     int64   `json:"latency_ms"`
-	QualityScore  float64 `json:"quality_score"`
-	CreatedAt     string  `json:"created_at"`
+	ID            string            `json:"id"`
+	Project       string            `json:"project"`
+	RunID         string            `json:"run_id"`
+	AttemptNumber int64             `json:"attempt_number"`
+	Workflow      string            `json:"workflow"`
+	AgentID       string            `json:"agent_id"`
+	ProviderType  string            `json:"provider_type"`
+	Provider      string            `json:"provider"`
+	Model         string            `json:"model"`
+	PromptVersion string            `json:"prompt_version"`
+	PromptHash    string            `json:"prompt_hash"`
+	Outcome       string            `json:"outcome"`
+	ErrorType     string            `json:"error_type"`
+	ErrorMessage  string            `json:"error_message"`
+	TokensIn      int64             `json:"tokens_in"`
+	TokensOut     int64             `json:"tokens_out"`
+	CostUSD       float64           `json:"cost_usd"`
+	LatencyMS     int64             `json:"latency_ms"`
+	QualityScore  float64           `json:"quality_score"`
+	Metadata      map[string]string `json:"metadata"`
+	CreatedAt     string            `json:"created_at"`
 }
 
 type RunEvent struct {
@@ -92,9 +112,62 @@ type RunEvent struct {
 	Level     string `json:"level"`
 	Message   string `json:"message"`
 	DataJSON  string `json:"data_json"`
+	RequestID string `json:"request_id,omitempty"`
 	CreatedAt string `json:"created_at"`
 }
 
+type Artifact struct {
+	ID          string `json:"id"`
+	RunID       string `json:"run_id"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	SHA256      string `json:"sha256"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ArtifactContent struct {
+	Artifact
+	ContentBase64 string `json:"content_base64"`
+}
+
+// PromptRelease is one entry in a workflow's prompt version history. The most
+// recent release for a project/workflow is the active (pinned) version. While
+// CanaryVersion is set, CanaryPercent of new runs use it instead of
+// PromptVersion until it is promoted or rolled back.
+type PromptRelease struct {
+	ID              string  `json:"id"`
+	Project         string  `json:"project"`
+	Workflow        string  `json:"workflow"`
+	PromptVersion   string  `json:"prompt_version"`
+	PreviousVersion string  `json:"previous_version"`
+	CanaryVersion   string  `json:"canary_version"`
+	CanaryPercent   int64   `json:"canary_percent"`
+	CanaryMargin    float64 `json:"canary_margin"`
+	CanaryMinRuns   int64   `json:"canary_min_runs"`
+	Action          string  `json:"action"`
+	Actor           string  `json:"actor"`
+	Reason          string  `json:"reason"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+// PolicyAuditEntry records one mutation of the orchestration policy or a
+// policy cap. Before/After hold the JSON of the record around the change and
+// are empty for creates and deletes respectively.
+type PolicyAuditEntry struct {
+	ID           string `json:"id"`
+	Project      string `json:"project"`
+	TargetType   string `json:"target_type"`
+	TargetID     string `json:"target_id"`
+	Action       string `json:"action"`
+	ActorAgentID string `json:"actor_agent_id"`
+	ActorKeyID   string `json:"actor_key_id"`
+	BeforeJSON   string `json:"before_json"`
+	AfterJSON    string `json:"after_json"`
+	CreatedAt    string `json:"created_at"`
+}
+
 type OrchestrationPolicy struct {
 	KillSwitch             bool    `json:"kill_switch"`
 	KillSwitchReason       string  `json:"kill_switch_reason"`
@@ -102,29 +175,96 @@ type OrchestrationPolicy struct {
 	MaxAttemptsPerRun      int64   `json:"max_attempts_per_run"`
 	MaxTokensPerRun        int64   `json:"max_tokens_per_run"`
 	MaxLatencyPerAttemptMS int64   `json:"max_latency_per_attempt_ms"`
-	UpdatedAt              string  `json:"updated_at"`
+	// MaxCostPerHourUSD and MaxCostPerDayUSD bound hub-wide attempt spend over
+	// rolling 1h and 24h windows.
+	MaxCostPerHourUSD  float64             `json:"max_cost_per_hour_usd"`
+	MaxCostPerDayUSD   float64             `json:"max_cost_per_day_usd"`
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows"`
+	// AlertMaintenanceWindows silence webhook alerts while open, e.g. for a
+	// planned load test, without blocking runs. Suppressed alerts are still
+	// logged and recorded.
+	AlertMaintenanceWindows []MaintenanceWindow `json:"alert_maintenance_windows"`
+	// ScheduledKillSwitch is maintained by the server's schedule evaluator and
+	// is true while one of MaintenanceWindows is open.
+	ScheduledKillSwitch       bool   `json:"scheduled_kill_switch"`
+	ScheduledKillSwitchReason string `json:"scheduled_kill_switch_reason"`
+	UpdatedAt                 string `json:"updated_at"`
+}
+
+// MaintenanceWindow engages the kill switch on a schedule, or silences alerts
+// when listed in AlertMaintenanceWindows, either for a fixed StartsAt..EndsAt
+// range or for DurationMinutes after each Cron match (UTC).
+type MaintenanceWindow struct {
+	Name            string `json:"name"`
+	StartsAt        string `json:"starts_at"`
+	EndsAt          string `json:"ends_at"`
+	Cron            string `json:"cron"`
+	DurationMinutes int64  `json:"duration_minutes"`
+	Reason          string `json:"reason"`
 }
 
 type PolicyCap struct {
 	ID                     string  `json:"id"`
+	Project                string  `json:"project"`
 	Name                   string  `json:"name"`
 	ProviderType           string  `json:"provider_type"`
 	Provider               string  `json:"provider"`
 	Model                  string  `json:"model"`
+	Workflow               string  `json:"workflow"`
+	AgentID                string  `json:"agent_id"`
 	MaxCostPerRunUSD       float64 `json:"max_cost_per_run_usd"`
 	MaxAttemptsPerRun      int64   `json:"max_attempts_per_run"`
 	MaxTokensPerRun        int64   `json:"max_tokens_per_run"`
 	MaxCostPerAttemptUSD   float64 `json:"max_cost_per_attempt_usd"`
 	MaxTokensPerAttempt    int64   `json:"max_tokens_per_attempt"`
 	MaxLatencyPerAttemptMS int64   `json:"max_latency_per_attempt_ms"`
+	MaxCostPerDayUSD       float64 `json:"max_cost_per_day_usd"`
+	MaxCostPerMonthUSD     float64 `json:"max_cost_per_month_usd"`
 	Priority               int64   `json:"priority"`
 	DryRun                 bool    `json:"dry_run"`
 	IsActive               bool    `json:"is_active"`
-	UpdatedAt              string  `json:"updated_at"`
+	// ValidFrom and ValidUntil bound when the cap applies (RFC3339, empty
+	// means unbounded); outside the window the cap is ignored.
+	ValidFrom  string `json:"valid_from"`
+	ValidUntil string `json:"valid_until"`
+	// Kind is "limit" (the default) for caps enforced on attempts, or
+	// "routing" for caps that only carry Routing and are read by
+	// RecommendModel.
+	Kind      string             `json:"kind"`
+	Routing   *RoutingPreference `json:"routing,omitempty"`
+	UpdatedAt string             `json:"updated_at"`
+}
+
+const (
+	PolicyCapKindLimit   = "limit"
+	PolicyCapKindRouting = "routing"
+)
+
+// RoutingPreference prefers PreferProviderType models whose average attempt
+// cost is under PreferUnderCostUSD (0 means any cost), and allows
+// FallbackProviderType models only when no preferred model meets
+// QualityTarget (0 means any success).
+type RoutingPreference struct {
+	PreferProviderType   string  `json:"prefer_provider_type"`
+	PreferUnderCostUSD   float64 `json:"prefer_under_cost_usd"`
+	FallbackProviderType string  `json:"fallback_provider_type"`
+	QualityTarget        float64 `json:"quality_target"`
+}
+
+type TaskFilter struct {
+	Project         string
+	ExternalID      string
+	Status          string
+	Tags            []string
+	Query           string
+	IncludeArchived bool
+	Limit           int64
 }
 
 type RunFilter struct {
+	Project       string
 	RunID         string
+	ExternalID    string
 	TaskID        string
 	Workflow      string
 	AgentID       string
@@ -132,10 +272,14 @@ type RunFilter struct {
 	PromptVersion string
 	StartedAfter  string
 	StartedBefore string
+	Labels        map[string]string
 	Limit         int64
+	// Starred keeps starred runs only.
+	Starred bool
 }
 
 type AttemptFilter struct {
+	Project       string
 	RunID         string
 	Workflow      string
 	AgentID       string
@@ -145,15 +289,52 @@ type AttemptFilter struct {
 	CreatedAfter  string
 	CreatedBefore string
 	Limit         int64
+	// IncludeArchived also returns attempts moved to cold storage.
+	IncludeArchived bool
+}
+
+// RollupFilter selects daily rollups. FromDay and ToDay are inclusive UTC
+// dates (YYYY-MM-DD); empty fields match everything.
+type RollupFilter struct {
+	Project       string
+	Workflow      string
+	Model         string
+	PromptVersion string
+	FromDay       string
+	ToDay         string
 }
 
 type EventFilter struct {
+	Project       string
 	RunID         string
 	EventType     string
 	Level         string
 	CreatedAfter  string
 	CreatedBefore string
 	Limit         int64
+	// IncludeArchived also returns events moved to cold storage.
+	IncludeArchived bool
+}
+
+type PromptReleaseFilter struct {
+	Project  string
+	Workflow string
+	Limit    int64
+}
+
+type PolicyAuditFilter struct {
+	Project    string
+	TargetType string
+	TargetID   string
+	Limit      int64
+}
+
+type ArtifactFilter struct {
+	Project string
+	ID      string
+	RunID   string
+	Kind    string
+	Limit   int64
 }
 
 type LeaderboardEntry struct {
@@ -169,6 +350,71 @@ type LeaderboardEntry struct {
 	Score            float64 `json:"score"`
 }
 
+// AttemptBucket totals the prompt attempts of one workflow and model that
+// were created in one time bucket. Start is the bucket's UTC start, in
+// RFC 3339.
+type AttemptBucket struct {
+	Start           string  `json:"start"`
+	Workflow        string  `json:"workflow"`
+	Model           string  `json:"model"`
+	Attempts        int64   `json:"attempts"`
+	SuccessAttempts int64   `json:"success_attempts"`
+	CostUSD         float64 `json:"cost_usd"`
+}
+
+// DailyRollup totals one UTC day's prompt attempts for a project, workflow,
+// provider, model and prompt version. Leaderboards and cost series read
+// rollups instead of the attempts themselves.
+type DailyRollup struct {
+	Day             string  `json:"day"`
+	Project         string  `json:"project"`
+	Workflow        string  `json:"workflow"`
+	ProviderType    string  `json:"provider_type"`
+	Provider        string  `json:"provider"`
+	Model           string  `json:"model"`
+	PromptVersion   string  `json:"prompt_version"`
+	Attempts        int64   `json:"attempts"`
+	SuccessAttempts int64   `json:"success_attempts"`
+	TokensIn        int64   `json:"tokens_in"`
+	TokensOut       int64   `json:"tokens_out"`
+	CostUSD         float64 `json:"cost_usd"`
+	LatencyMS       int64   `json:"latency_ms"`
+}
+
+type CostSeriesPoint struct {
+	Bucket       string  `json:"bucket"`
+	ProviderType string  `json:"provider_type"`
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Attempts     int64   `json:"attempts"`
+	TokensIn     int64   `json:"tokens_in"`
+	TokensOut    int64   `json:"tokens_out"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// CostBucket totals one time bucket's prompt attempts for a workflow and
+// provider.
+type CostBucket struct {
+	Bucket          string  `json:"bucket"`
+	Workflow        string  `json:"workflow"`
+	ProviderType    string  `json:"provider_type"`
+	Provider        string  `json:"provider"`
+	Attempts        int64   `json:"attempts"`
+	SuccessAttempts int64   `json:"success_attempts"`
+	TokensIn        int64   `json:"tokens_in"`
+	TokensOut       int64   `json:"tokens_out"`
+	CostUSD         float64 `json:"cost_usd"`
+}
+
+// LiveRun is a running run with the last time it showed activity: a run
+// event, such as a heartbeat, or a prompt attempt.
+type LiveRun struct {
+	AgentRun
+	LastHeartbeatAt       string `json:"last_heartbeat_at"`
+	SecondsSinceHeartbeat int64  `json:"seconds_since_heartbeat"`
+	Stuck                 bool   `json:"stuck"`
+}
+
 type State struct {
 	Tasks      []Task              `json:"tasks"`
 	Notes      []Note              `json:"notes"`
@@ -177,6 +423,9 @@ type State struct {
 	Runs       []AgentRun          `json:"runs"`
 	Attempts   []PromptAttempt     `json:"attempts"`
 	RunEvents  []RunEvent          `json:"run_events"`
+	Artifacts  []Artifact          `json:"artifacts"`
+	Releases   []PromptRelease     `json:"prompt_releases"`
+	Audit      []PolicyAuditEntry  `json:"policy_audit"`
 	Policy     OrchestrationPolicy `json:"policy"`
 	PolicyCaps []PolicyCap         `json:"policy_caps"`
 }
@@ -226,6 +475,39 @@ type TelemetrySummary struct {
 		CostPerAttempt   float64 `json:"cost_per_attempt"`
 		SuccessRate      float64 `json:"success_rate"`
 	} `json:"averages"`
+	// Distinct and DistinctByDay are HyperLogLog estimates, within a few
+	// percent, over every attempt the store has counted; retention drops
+	// whole days from them.
+	Distinct      DistinctCounts   `json:"distinct"`
+	DistinctByDay []DistinctCounts `json:"distinct_by_day"`
+}
+
+// DistinctCounts is the approximate number of distinct models, prompt hashes
+// and agents among prompt attempts, for one UTC day when Day is set.
+type DistinctCounts struct {
+	Day          string `json:"day,omitempty"`
+	Models       int64  `json:"models"`
+	PromptHashes int64  `json:"prompt_hashes"`
+	Agents       int64  `json:"agents"`
+}
+
+// PruneResult reports what a retention pass removed. Postgres drops whole
+// hypertable chunks past the cutoff first, then deletes the older rows left
+// in the boundary chunk; the row counts cover only those deletes.
+type PruneResult struct {
+	RunEventsBefore  string `json:"run_events_before,omitempty"`
+	AttemptsBefore   string `json:"attempts_before,omitempty"`
+	RunEventsDeleted int64  `json:"run_events_deleted"`
+	AttemptsDeleted  int64  `json:"attempts_deleted"`
+	ChunksDropped    int64  `json:"chunks_dropped"`
+}
+
+// ArchiveResult reports what a cold storage pass moved out of the hot
+// attempt and run event tables.
+type ArchiveResult struct {
+	Before            string `json:"before,omitempty"`
+	AttemptsArchived  int64  `json:"attempts_archived"`
+	RunEventsArchived int64  `json:"run_events_archived"`
 }
 
 func EmptyState() State {
@@ -237,6 +519,9 @@ func EmptyState() State {
 		Runs:       []AgentRun{},
 		Attempts:   []PromptAttempt{},
 		RunEvents:  []RunEvent{},
+		Artifacts:  []Artifact{},
+		Releases:   []PromptRelease{},
+		Audit:      []PolicyAuditEntry{},
 		Policy:     DefaultPolicy(),
 		PolicyCaps: []PolicyCap{},
 	}
@@ -244,11 +529,15 @@ func EmptyState() State {
 
 func DefaultPolicy() OrchestrationPolicy {
 	return OrchestrationPolicy{
-		KillSwitch:             false,
-		KillSwitchReason:       "",
-		MaxCostPerRunUSD:       0,
-		MaxAttemptsPerRun:      0,
-		MaxTokensPerRun:        0,
-		MaxLatencyPerAttemptMS: 0,
+		KillSwitch:              false,
+		KillSwitchReason:        "",
+		MaxCostPerRunUSD:        0,
+		MaxAttemptsPerRun:       0,
+		MaxTokensPerRun:         0,
+		MaxLatencyPerAttemptMS:  0,
+		MaxCostPerHourUSD:       0,
+		MaxCostPerDayUSD:        0,
+		MaintenanceWindows:      []MaintenanceWindow{},
+		AlertMaintenanceWindows: []MaintenanceWindow{},
 	}
 }
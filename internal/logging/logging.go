@@ -0,0 +1,67 @@
+// Package logging installs the process-wide slog logger from LOG_LEVEL and
+// LOG_FORMAT. Once Setup runs, the standard log package writes through the
+// same handler, so output from dependencies keeps the chosen format.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ParseLevel reads debug, info, warn (or warning) and error, ignoring case.
+// Empty is info.
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("LOG_LEVEL %q is not one of debug, info, warn, error", value)
+	}
+}
+
+// NewHandler returns a handler writing records at level and above to w, as
+// JSON objects for format json or logfmt-style key=value lines for text.
+func NewHandler(w io.Writer, level, format string) (slog.Handler, error) {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: parsed}
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "text":
+		return slog.NewTextHandler(w, options), nil
+	case "json":
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT %q is not one of json, text", format)
+	}
+}
+
+// Setup makes a NewHandler logger on stderr the slog default. An empty
+// format keeps the standard log package's plain lines and only applies
+// level, which suits interactive commands such as mm.
+func Setup(level, format string) error {
+	if strings.TrimSpace(format) == "" {
+		parsed, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		slog.SetLogLoggerLevel(parsed)
+		return nil
+	}
+	handler, err := NewHandler(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
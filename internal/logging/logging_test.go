@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"DEBUG":   slog.LevelDebug,
+		" info ":  slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for value, want := range cases {
+		got, err := ParseLevel(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseLevel(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatalf("expected an error for an unknown level")
+	}
+}
+
+func TestNewHandlerFormatsAndFiltersByLevel(t *testing.T) {
+	var out bytes.Buffer
+	handler, err := NewHandler(&out, "warn", "json")
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	logger := slog.New(handler)
+	logger.Info("dropped")
+	logger.Warn("kept", "method", "/modeloman.v1.ModeloManHub/StartRun", "run_id", "run_1")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the warn record, got %q", out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("expected a JSON record: %v", err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["run_id"] != "run_1" {
+		t.Fatalf("unexpected record: %v", record)
+	}
+
+	out.Reset()
+	handler, err = NewHandler(&out, "info", "text")
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	slog.New(handler).Info("started", "agent_id", "a")
+	if !strings.Contains(out.String(), "msg=started agent_id=a") {
+		t.Fatalf("unexpected text record: %q", out.String())
+	}
+	if _, err := NewHandler(&out, "info", "xml"); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bcrosbie/modeloman/internal/logging"
 	mmconfig "github.com/bcrosbie/modeloman/internal/mm/config"
 	mmcontext "github.com/bcrosbie/modeloman/internal/mm/context"
 	"github.com/bcrosbie/modeloman/internal/mm/gitutil"
@@ -29,6 +31,9 @@ func (s *stringList) Set(value string) error {
 
 func Run(args []string, commandName string) error {
 	log.SetFlags(0)
+	if err := logging.Setup(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")); err != nil {
+		return fmt.Errorf("config error: %w", err)
+	}
 
 	cfg, cfgPath, err := mmconfig.Load()
 	if err != nil {
@@ -46,13 +51,21 @@ func Run(args []string, commandName string) error {
 	case "tui":
 		return ui.Run(cfg)
 	case "add":
-		return addCommand(args[1:])
+		return addCommand(cfg, args[1:])
 	case "drop":
 		return dropCommand(args[1:])
 	case "list":
 		return listCommand()
 	case "clear":
 		return clearCommand()
+	case "bundle":
+		return bundleCommand(args[1:])
+	case "objective":
+		return objectiveCommand(args[1:])
+	case "pipeline":
+		return pipelineCommand(args[1:])
+	case "stats":
+		return statsCommand(cfg, args[1:])
 	default:
 		usage(commandName, cfgPath)
 		return nil
@@ -75,18 +88,43 @@ func runCommand(cfg mmconfig.Config, args []string) error {
 	skill := flags.String("skill", "", "skill name")
 	var addList stringList
 	flags.Var(&addList, "add", "additional path or glob for this run")
+	var bundleList stringList
+	flags.Var(&bundleList, "bundle", "saved bundle manifest to include for this run")
 	budget := flags.Int("budget", 0, "optional token budget")
 	dryRun := flags.Bool("dry-run", false, "render and log only")
+	expandImports := flags.Bool("expand-imports", cfg.ExpandImports, "also include files imported by the selection (one hop)")
 	ptyMode := flags.Bool("pty", true, "run backend with PTY for interactive tools")
 	objective := flags.String("objective", "", "objective prompt text")
+	template := flags.String("template", "", "saved objective template to fill instead of --objective")
+	var varList stringList
+	flags.Var(&varList, "var", "template placeholder value as key=value")
+	var verifyList stringList
+	flags.Var(&verifyList, "verify", "verification command to run after the backend (replaces .modeloman/verify.json)")
+	noVerify := flags.Bool("no-verify", false, "skip post-run verification commands")
+	pipelineName := flags.String("pipeline", "", "run the stages of a pipeline from .modeloman/pipelines.json")
+	noEscalate := flags.Bool("no-escalate", false, "do not retry a failed attempt with the next backend on the escalation ladder")
+	var envList stringList
+	flags.Var(&envList, "env", "extra backend environment variable as KEY=VALUE (redacted from telemetry)")
+	name := flags.String("name", "", "run name shown in hub run lists and the dashboard")
+	description := flags.String("description", "", "run description shown on the dashboard")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
+	if strings.TrimSpace(*template) != "" {
+		if strings.TrimSpace(*objective) != "" {
+			return fmt.Errorf("use either --objective or --template, not both")
+		}
+		filled, err := fillObjectiveTemplate(*template, varList)
+		if err != nil {
+			return err
+		}
+		*objective = filled
+	}
 	if strings.TrimSpace(*objective) == "" {
 		*objective = askLine("Objective: ")
 	}
 
-	result, err := workflow.Run(context.Background(), cfg, workflow.RunParams{
+	params := workflow.RunParams{
 		Backend:         backend,
 		TaskType:        strings.TrimSpace(*taskType),
 		Skill:           strings.TrimSpace(*skill),
@@ -96,8 +134,25 @@ func runCommand(cfg mmconfig.Config, args []string) error {
 		UsePTY:          *ptyMode,
 		ForwardInput:    true,
 		AdditionalEntry: addList,
+		Bundles:         bundleList,
+		ExpandImports:   *expandImports,
+		Env:             envList,
+		VerifyCommands:  verifyList,
+		NoVerify:        *noVerify,
 		OutputWriter:    os.Stdout,
-	})
+		Name:            strings.TrimSpace(*name),
+		Description:     strings.TrimSpace(*description),
+	}
+	if strings.TrimSpace(*pipelineName) != "" {
+		return runPipelineCommand(cfg, *pipelineName, params)
+	}
+	if !*noEscalate {
+		cfg.EscalationLadder = workflow.HubEscalationLadder(context.Background(), cfg, params.TaskType)
+	}
+	if !*noEscalate && len(cfg.EscalationFrom(backend)) > 0 {
+		return runEscalationCommand(cfg, params)
+	}
+	result, err := workflow.Run(context.Background(), cfg, params)
 	if err != nil {
 		return err
 	}
@@ -108,6 +163,18 @@ func runCommand(cfg mmconfig.Config, args []string) error {
 		len(result.DiffSummary.ChangedFiles),
 		result.RunID,
 	)
+	for _, item := range result.Verification {
+		fmt.Printf("verify: %s\n", item.Summary())
+	}
+	if len(result.Verification) > 0 {
+		fmt.Printf("outcome=%s quality=%.2f\n", result.Outcome, result.QualityScore)
+	}
+	if result.PolicyAbort != "" {
+		fmt.Printf("aborted: %s\n", result.PolicyAbort)
+	}
+	if result.PolicyWarning != "" {
+		fmt.Printf("warning: likely over cap per cached policy: %s\n", result.PolicyWarning)
+	}
 
 	rating, notes := askFeedback()
 	if rating > 0 && strings.TrimSpace(result.RunID) != "" {
@@ -116,19 +183,206 @@ func runCommand(cfg mmconfig.Config, args []string) error {
 	return nil
 }
 
-func addCommand(args []string) error {
+func runPipelineCommand(cfg mmconfig.Config, name string, params workflow.RunParams) error {
+	repoRoot, err := gitutil.DetectRepoRoot()
+	if err != nil {
+		return err
+	}
+	pipeline, err := mmcontext.GetPipeline(repoRoot, name)
+	if err != nil {
+		return err
+	}
+	result, err := workflow.RunPipeline(context.Background(), cfg, pipeline, params)
+	if err != nil {
+		return err
+	}
+
+	for _, stage := range result.Stages {
+		printStageResult("stage", stage)
+	}
+	if len(result.Stages) < len(pipeline.Stages) {
+		fmt.Printf("pipeline %s stopped after %d of %d stages\n", pipeline.Name, len(result.Stages), len(pipeline.Stages))
+	}
+	fmt.Printf("pipeline=%s outcome=%s run_id=%s\n", pipeline.Name, result.Outcome(), result.RunID)
+
+	rating, notes := askFeedback()
+	if rating > 0 && strings.TrimSpace(result.RunID) != "" {
+		_ = workflow.SendFeedback(context.Background(), cfg, result.RunID, rating, notes)
+	}
+	return nil
+}
+
+func runEscalationCommand(cfg mmconfig.Config, params workflow.RunParams) error {
+	result, err := workflow.RunEscalating(context.Background(), cfg, params)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range result.Steps {
+		printStageResult("backend", step)
+	}
+	if len(result.Steps) > 1 {
+		fmt.Printf("escalations=%d\n", len(result.Steps)-1)
+	}
+	fmt.Printf("outcome=%s run_id=%s\n", result.Outcome(), result.RunID)
+
+	rating, notes := askFeedback()
+	if rating > 0 && strings.TrimSpace(result.RunID) != "" {
+		_ = workflow.SendFeedback(context.Background(), cfg, result.RunID, rating, notes)
+	}
+	return nil
+}
+
+func printStageResult(kind string, stage workflow.StageResult) {
+	fmt.Printf("%s %s: outcome=%s exit=%d duration=%s changed_files=%d\n",
+		kind,
+		stage.Stage,
+		stage.Result.Outcome,
+		stage.Result.Runner.ExitCode,
+		stage.Result.Runner.Duration.Round(time.Millisecond),
+		len(stage.Result.DiffSummary.ChangedFiles),
+	)
+	for _, item := range stage.Result.Verification {
+		fmt.Printf("  verify: %s\n", item.Summary())
+	}
+	if stage.Result.PolicyAbort != "" {
+		fmt.Printf("  aborted: %s\n", stage.Result.PolicyAbort)
+	}
+}
+
+func pipelineCommand(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: mm pipeline list")
+	}
+	repoRoot, err := gitutil.DetectRepoRoot()
+	if err != nil {
+		return err
+	}
+	pipelines, err := mmcontext.LoadPipelines(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(pipelines) == 0 {
+		fmt.Println("no pipelines in .modeloman/pipelines.json")
+		return nil
+	}
+	for _, pipeline := range pipelines {
+		stages := make([]string, 0, len(pipeline.Stages))
+		for _, stage := range pipeline.Stages {
+			label := stage.Name
+			if stage.Backend != "" {
+				label += "(" + stage.Backend + ")"
+			}
+			stages = append(stages, label)
+		}
+		fmt.Printf("%s - %s\n", pipeline.Name, strings.Join(stages, " -> "))
+	}
+	return nil
+}
+
+func statsCommand(cfg mmconfig.Config, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	days := flags.Int("days", 30, "look back this many days")
+	allRepos := flags.Bool("all-repos", false, "include runs from every repository, not just this one")
+	asJSON := flags.Bool("json", false, "print the scorecard as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("--days must be positive")
+	}
+	params := workflow.StatsParams{Since: time.Duration(*days) * 24 * time.Hour}
+	if !*allRepos {
+		repoRoot, err := gitutil.DetectRepoRoot()
+		if err != nil {
+			return err
+		}
+		params.RepoRoot = repoRoot
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stats, err := workflow.CollectStats(ctx, cfg, params)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoded, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+		return nil
+	}
+
+	scope := "all repositories"
+	if stats.Repo != "" {
+		scope = "repo " + stats.Repo
+	}
+	fmt.Printf("%s, agent %s, last %d days\n", scope, stats.AgentID, *days)
+	fmt.Printf("runs launched: %d (%d running)\n", stats.Runs, stats.Running)
+	if stats.Finished > 0 {
+		fmt.Printf("success rate:  %.0f%% (%d/%d finished)\n", stats.SuccessRate*100, stats.Succeeded, stats.Finished)
+	} else {
+		fmt.Println("success rate:  -")
+	}
+	fmt.Printf("average cost:  $%.4f (total $%.4f)\n", stats.AverageCost, stats.TotalCost)
+	if stats.TopBackend != "" {
+		fmt.Printf("top backend:   %s (%d runs)\n", stats.TopBackend, stats.TopRuns)
+	} else {
+		fmt.Println("top backend:   -")
+	}
+	if stats.Ratings > 0 {
+		fmt.Printf("rating:        %.1f/5 (%d rated)\n", stats.RatingAvg, stats.Ratings)
+	} else {
+		fmt.Println("rating:        -")
+	}
+	if stats.Truncated {
+		fmt.Println("note: only the newest runs were read; narrow --days for exact numbers")
+	}
+	return nil
+}
+
+func fillObjectiveTemplate(name string, vars []string) (string, error) {
+	repoRoot, err := gitutil.DetectRepoRoot()
+	if err != nil {
+		return "", err
+	}
+	template, err := mmcontext.GetObjectiveTemplate(repoRoot, name)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]string{}
+	for _, item := range vars {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return "", fmt.Errorf("invalid --var %q (use key=value)", item)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return mmcontext.FillTemplate(template.Text, values)
+}
+
+func addCommand(cfg mmconfig.Config, args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("usage: mm add PATH|GLOB ...")
+		return fmt.Errorf("usage: mm add PATH|GLOB|@REPO/PATH ...")
 	}
 	repoRoot, err := gitutil.DetectRepoRoot()
 	if err != nil {
 		return err
 	}
-	cfg, err := mmcontext.Add(repoRoot, args)
+	workspaces, err := mmcontext.Workspaces(cfg.WorkspaceRoots)
+	if err != nil {
+		return err
+	}
+	if err := mmcontext.ValidateWorkspaceEntries(args, workspaces); err != nil {
+		return err
+	}
+	saved, err := mmcontext.Add(repoRoot, args)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("saved %d context entries to %s\n", len(cfg.Entries), filepath.Join(repoRoot, ".modeloman/context.json"))
+	fmt.Printf("saved %d context entries to %s\n", len(saved.Entries), filepath.Join(repoRoot, ".modeloman/context.json"))
 	return nil
 }
 
@@ -179,6 +433,172 @@ func clearCommand() error {
 	return nil
 }
 
+func bundleCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mm bundle save|list|show|use|delete ...")
+	}
+	repoRoot, err := gitutil.DetectRepoRoot()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "save":
+		flags := flag.NewFlagSet("bundle save", flag.ContinueOnError)
+		flags.SetOutput(os.Stderr)
+		description := flags.String("description", "", "what the bundle is for")
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			return fmt.Errorf("usage: mm bundle save NAME [--description TEXT] [PATH|GLOB ...]")
+		}
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		entries := flags.Args()
+		if len(entries) == 0 {
+			current, err := mmcontext.Load(repoRoot)
+			if err != nil {
+				return err
+			}
+			entries = current.Entries
+		}
+		manifest, err := mmcontext.SaveManifest(repoRoot, mmcontext.Manifest{
+			Name:        args[1],
+			Description: *description,
+			Entries:     entries,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("saved bundle %s with %d entries\n", manifest.Name, len(manifest.Entries))
+		return nil
+	case "list":
+		manifests, err := mmcontext.ListManifests(repoRoot)
+		if err != nil {
+			return err
+		}
+		if len(manifests) == 0 {
+			fmt.Println("no bundles saved")
+			return nil
+		}
+		for _, manifest := range manifests {
+			line := fmt.Sprintf("%s (%d entries)", manifest.Name, len(manifest.Entries))
+			if manifest.Description != "" {
+				line += " - " + manifest.Description
+			}
+			fmt.Println(line)
+		}
+		return nil
+	case "show", "use", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: mm bundle %s NAME", args[0])
+		}
+		name := args[1]
+		if args[0] == "delete" {
+			if err := mmcontext.DeleteManifest(repoRoot, name); err != nil {
+				return err
+			}
+			fmt.Printf("deleted bundle %s\n", name)
+			return nil
+		}
+		manifest, err := mmcontext.LoadManifest(repoRoot, name)
+		if err != nil {
+			return err
+		}
+		if args[0] == "show" {
+			if manifest.Description != "" {
+				fmt.Println("# " + manifest.Description)
+			}
+			for _, item := range manifest.Entries {
+				fmt.Println(item)
+			}
+			return nil
+		}
+		cfg, err := mmcontext.Add(repoRoot, manifest.Entries)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added bundle %s; %d context entries saved\n", name, len(cfg.Entries))
+		return nil
+	default:
+		return fmt.Errorf("unknown bundle command %q (use save, list, show, use or delete)", args[0])
+	}
+}
+
+func objectiveCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mm objective save|list|history|delete ...")
+	}
+	repoRoot, err := gitutil.DetectRepoRoot()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "save":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: mm objective save NAME TEXT...")
+		}
+		template, err := mmcontext.SaveObjectiveTemplate(repoRoot, args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		line := "saved template " + template.Name
+		if placeholders := mmcontext.Placeholders(template.Text); len(placeholders) > 0 {
+			line += " (placeholders: " + strings.Join(placeholders, ", ") + ")"
+		}
+		fmt.Println(line)
+		return nil
+	case "list":
+		templates, err := mmcontext.ListObjectiveTemplates(repoRoot)
+		if err != nil {
+			return err
+		}
+		if len(templates) == 0 {
+			fmt.Println("no objective templates saved")
+			return nil
+		}
+		for _, template := range templates {
+			fmt.Printf("%s - %s\n", template.Name, template.Text)
+		}
+		return nil
+	case "history":
+		flags := flag.NewFlagSet("objective history", flag.ContinueOnError)
+		flags.SetOutput(os.Stderr)
+		limit := flags.Int("limit", 20, "number of objectives to show")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		entries, err := mmcontext.LoadObjectiveHistory(repoRoot)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("no objectives recorded")
+			return nil
+		}
+		if *limit > 0 && len(entries) > *limit {
+			entries = entries[:*limit]
+		}
+		for _, entry := range entries {
+			fmt.Printf("%3dx  %s\n", entry.Uses, entry.Text)
+		}
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: mm objective delete NAME")
+		}
+		removed, err := mmcontext.DeleteObjectiveTemplate(repoRoot, args[1])
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("objective template %q not found", args[1])
+		}
+		fmt.Printf("deleted template %s\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown objective command %q (use save, list, history or delete)", args[0])
+	}
+}
+
 func askLine(label string) string {
 	fmt.Print(label)
 	reader := bufio.NewReader(os.Stdin)
@@ -203,14 +623,20 @@ func usage(commandName, configPath string) {
 	fmt.Printf(`%s - ModeloMan workflow wrapper
 
 Usage:
-  %s run <backend> [--task TYPE] [--skill NAME] [--add PATH|GLOB ...] [--budget TOKENS] [--dry-run] [--pty=true] [--objective "text"]
+  %s run <backend> [--task TYPE] [--skill NAME] [--name TEXT] [--description TEXT] [--add PATH|GLOB ...] [--bundle NAME ...] [--expand-imports] [--budget TOKENS] [--env KEY=VALUE ...] [--verify CMD ... | --no-verify] [--pipeline NAME] [--no-escalate] [--dry-run] [--pty=true] [--objective "text" | --template NAME --var KEY=VALUE ...]
   %s tui
-  %s add PATH|GLOB ...
+  %s add PATH|GLOB|@REPO/PATH ...
   %s drop PATH|GLOB ...
   %s list
   %s clear
+  %s bundle save NAME [--description TEXT] [PATH|GLOB ...]
+  %s bundle list|show NAME|use NAME|delete NAME
+  %s objective save NAME TEXT...
+  %s objective list|history [--limit N]|delete NAME
+  %s pipeline list
+  %s stats [--days N] [--all-repos] [--json]
 
 Config file:
   %s
-`, commandName, commandName, commandName, commandName, commandName, commandName, commandName, configPath)
+`, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, commandName, configPath)
 }
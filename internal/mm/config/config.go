@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,19 +16,37 @@ const (
 	defaultConfigRelPath = ".config/modeloman/mm.yaml"
 )
 
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type Config struct {
-	GRPCAddr            string        `yaml:"grpc_addr"`
-	GRPCInsecure        bool          `yaml:"grpc_insecure"`
-	TokenEnvVar         string        `yaml:"token_env_var"`
-	DefaultBackend      string        `yaml:"default_backend"`
-	RedactionEnabled    bool          `yaml:"redaction"`
-	MaxContextBytes     int           `yaml:"max_context_bytes"`
-	MaxTranscriptBytes  int           `yaml:"max_transcript_bytes"`
-	AllowRawTranscript  bool          `yaml:"allow_raw_transcript"`
-	CustomRedactRegexes []string      `yaml:"custom_redaction_regex"`
-	ConnectTimeout      time.Duration `yaml:"-"`
-	RequestTimeout      time.Duration `yaml:"-"`
-	RetryAttempts       int           `yaml:"-"`
+	GRPCAddr             string              `yaml:"grpc_addr"`
+	GRPCInsecure         bool                `yaml:"grpc_insecure"`
+	GRPCCAFile           string              `yaml:"grpc_ca_file"`
+	GRPCClientCert       string              `yaml:"grpc_client_cert"`
+	GRPCClientKey        string              `yaml:"grpc_client_key"`
+	GRPCServerName       string              `yaml:"grpc_server_name"`
+	TokenEnvVar          string              `yaml:"token_env_var"`
+	DefaultBackend       string              `yaml:"default_backend"`
+	RedactionEnabled     bool                `yaml:"redaction"`
+	MaxContextBytes      int                 `yaml:"max_context_bytes"`
+	MaxTranscriptBytes   int                 `yaml:"max_transcript_bytes"`
+	AllowRawTranscript   bool                `yaml:"allow_raw_transcript"`
+	CustomRedactRegexes  []string            `yaml:"custom_redaction_regex"`
+	CostPerMillionUSD    float64             `yaml:"cost_per_million_tokens"`
+	AbortOnCap           bool                `yaml:"abort_on_cap"`
+	ExpandImports        bool                `yaml:"expand_imports"`
+	MaxFileKB            int                 `yaml:"max_file_kb"`
+	ExcludePatterns      []string            `yaml:"exclude_patterns"`
+	WorkspaceRoots       []string            `yaml:"workspace_roots"`
+	Env                  []string            `yaml:"env"`
+	BackendEnv           map[string][]string `yaml:"backend_env"`
+	EscalationLadder     []string            `yaml:"escalation_ladder"`
+	EscalationFromHub    bool                `yaml:"escalation_from_hub"`
+	BackendProviderTypes map[string]string   `yaml:"backend_provider_type"`
+	ConnectTimeout       time.Duration       `yaml:"-"`
+	RequestTimeout       time.Duration       `yaml:"-"`
+	RetryAttempts        int                 `yaml:"-"`
+	PolicyPollInterval   time.Duration       `yaml:"-"`
 }
 
 func Default() Config {
@@ -40,9 +59,12 @@ func Default() Config {
 		MaxContextBytes:    350000,
 		MaxTranscriptBytes: 200000,
 		AllowRawTranscript: false,
+		AbortOnCap:         true,
+		MaxFileKB:          1024,
 		ConnectTimeout:     8 * time.Second,
 		RequestTimeout:     10 * time.Second,
 		RetryAttempts:      3,
+		PolicyPollInterval: 15 * time.Second,
 	}
 }
 
@@ -85,6 +107,9 @@ func Load() (Config, string, error) {
 	if cfg.RequestTimeout <= 0 {
 		cfg.RequestTimeout = Default().RequestTimeout
 	}
+	if cfg.PolicyPollInterval <= 0 {
+		cfg.PolicyPollInterval = Default().PolicyPollInterval
+	}
 
 	return cfg, path, nil
 }
@@ -111,6 +136,44 @@ func ResolveToken(cfg Config) string {
 	return ""
 }
 
+// EscalationFrom returns the ladder rungs above backend, tried in order
+// when an attempt with it fails. A backend off the ladder never escalates.
+func (c Config) EscalationFrom(backend string) []string {
+	for i, rung := range c.EscalationLadder {
+		if rung == strings.TrimSpace(backend) {
+			return append([]string{}, c.EscalationLadder[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// ProviderTypeFor is the provider type attempts with backend are recorded
+// under: its backend_provider_type entry, or "api".
+func (c Config) ProviderTypeFor(backend string) string {
+	if providerType := c.BackendProviderTypes[strings.TrimSpace(backend)]; providerType != "" {
+		return providerType
+	}
+	return "api"
+}
+
+// EnvFor returns the KEY=VALUE entries injected into backend's process: env
+// first, then backend_env for that backend, so later entries win.
+func (c Config) EnvFor(backend string) []string {
+	out := append([]string{}, c.Env...)
+	return append(out, c.BackendEnv[strings.TrimSpace(backend)]...)
+}
+
+// ValidateEnv checks that every entry is KEY=VALUE with a shell-style name.
+func ValidateEnv(entries []string) error {
+	for _, entry := range entries {
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok || !envNamePattern.MatchString(key) {
+			return fmt.Errorf("invalid environment entry %q (use KEY=VALUE)", entry)
+		}
+	}
+	return nil
+}
+
 func EnsureConfigDir(path string) error {
 	dir := filepath.Dir(path)
 	if dir == "" {
@@ -133,6 +196,49 @@ func parseConfig(raw string, cfg *Config) error {
 			if currentListKey == "custom_redaction_regex" && value != "" {
 				cfg.CustomRedactRegexes = append(cfg.CustomRedactRegexes, value)
 			}
+			if currentListKey == "exclude_patterns" && value != "" {
+				cfg.ExcludePatterns = append(cfg.ExcludePatterns, value)
+			}
+			if currentListKey == "workspace_roots" && value != "" {
+				cfg.WorkspaceRoots = append(cfg.WorkspaceRoots, expandHome(value))
+			}
+			if currentListKey == "env" && value != "" {
+				if err := ValidateEnv([]string{value}); err != nil {
+					return fmt.Errorf("env: %w", err)
+				}
+				cfg.Env = append(cfg.Env, value)
+			}
+			if currentListKey == "escalation_ladder" && value != "" {
+				cfg.EscalationLadder = append(cfg.EscalationLadder, value)
+			}
+			if currentListKey == "backend_provider_type" && value != "" {
+				backend, providerType, ok := strings.Cut(value, ":")
+				backend, providerType = strings.TrimSpace(backend), strings.TrimSpace(providerType)
+				switch {
+				case !ok || backend == "":
+					return fmt.Errorf("backend_provider_type: %q must look like BACKEND:TYPE", value)
+				case providerType != "api" && providerType != "subscription" && providerType != "opensource":
+					return fmt.Errorf("backend_provider_type: %q: type must be api, subscription or opensource", value)
+				}
+				if cfg.BackendProviderTypes == nil {
+					cfg.BackendProviderTypes = map[string]string{}
+				}
+				cfg.BackendProviderTypes[backend] = providerType
+			}
+			if currentListKey == "backend_env" && value != "" {
+				backend, entry, ok := strings.Cut(value, ":")
+				backend = strings.TrimSpace(backend)
+				if !ok || backend == "" || strings.Contains(backend, "=") {
+					return fmt.Errorf("backend_env: %q must look like BACKEND:KEY=VALUE", value)
+				}
+				if err := ValidateEnv([]string{entry}); err != nil {
+					return fmt.Errorf("backend_env: %w", err)
+				}
+				if cfg.BackendEnv == nil {
+					cfg.BackendEnv = map[string][]string{}
+				}
+				cfg.BackendEnv[backend] = append(cfg.BackendEnv[backend], entry)
+			}
 			continue
 		}
 
@@ -157,6 +263,14 @@ func parseConfig(raw string, cfg *Config) error {
 				return fmt.Errorf("grpc_insecure: %w", err)
 			}
 			cfg.GRPCInsecure = parsed
+		case "grpc_ca_file":
+			cfg.GRPCCAFile = expandHome(value)
+		case "grpc_client_cert":
+			cfg.GRPCClientCert = expandHome(value)
+		case "grpc_client_key":
+			cfg.GRPCClientKey = expandHome(value)
+		case "grpc_server_name":
+			cfg.GRPCServerName = value
 		case "token_env_var":
 			cfg.TokenEnvVar = value
 		case "default_backend":
@@ -185,6 +299,36 @@ func parseConfig(raw string, cfg *Config) error {
 				return fmt.Errorf("max_transcript_bytes: %w", err)
 			}
 			cfg.MaxTranscriptBytes = parsed
+		case "abort_on_cap":
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("abort_on_cap: %w", err)
+			}
+			cfg.AbortOnCap = parsed
+		case "expand_imports":
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("expand_imports: %w", err)
+			}
+			cfg.ExpandImports = parsed
+		case "escalation_from_hub":
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("escalation_from_hub: %w", err)
+			}
+			cfg.EscalationFromHub = parsed
+		case "max_file_kb":
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("max_file_kb: must be a non-negative integer")
+			}
+			cfg.MaxFileKB = parsed
+		case "cost_per_million_tokens":
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("cost_per_million_tokens: must be a non-negative number")
+			}
+			cfg.CostPerMillionUSD = parsed
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -202,3 +346,16 @@ func trimQuotes(value string) string {
 	}
 	return value
 }
+
+// expandHome resolves a leading "~/" so certificate paths can be written
+// relative to the home directory.
+func expandHome(value string) string {
+	if !strings.HasPrefix(value, "~/") {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, value[2:])
+}
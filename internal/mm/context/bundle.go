@@ -28,19 +28,31 @@ type BuildOptions struct {
 	MaxFileBytes  int
 	MaxGrepHits   int
 	GitDiffBudget int
+	// Exclude drops matching or oversized files before they are read; they are
+	// listed in Bundle.SkippedFiles instead.
+	Exclude ExcludeRules
+	// Workspaces maps sibling repo names to their roots (see Workspaces), for
+	// "@NAME/path" entries.
+	Workspaces map[string]string
+	// ExpandImports adds files imported by the selected Go, TS/JS and Python
+	// files (one hop) while they fit in what the selection leaves of MaxBytes.
+	ExpandImports bool
 }
 
 type Bundle struct {
-	RepoMeta       gitutil.RepoMeta `json:"repo_meta"`
-	SelectedFiles  []string         `json:"selected_files"`
-	TreeOutline    []string         `json:"tree_outline"`
-	GitStatus      string           `json:"git_status"`
-	GitDiff        string           `json:"git_diff"`
-	SymbolHits     []string         `json:"symbol_hits"`
-	Rendered       string           `json:"rendered"`
-	RenderedBytes  int              `json:"rendered_bytes"`
-	EstimatedToken int              `json:"estimated_tokens"`
-	Hash           string           `json:"hash"`
+	RepoMeta       gitutil.RepoMeta     `json:"repo_meta"`
+	SelectedFiles  []string             `json:"selected_files"`
+	ImportedFiles  []string             `json:"imported_files"`
+	SkippedFiles   []SkippedFile        `json:"skipped_files"`
+	WorkspaceRepos []WorkspaceSelection `json:"workspace_repos"`
+	TreeOutline    []string             `json:"tree_outline"`
+	GitStatus      string               `json:"git_status"`
+	GitDiff        string               `json:"git_diff"`
+	SymbolHits     []string             `json:"symbol_hits"`
+	Rendered       string               `json:"rendered"`
+	RenderedBytes  int                  `json:"rendered_bytes"`
+	EstimatedToken int                  `json:"estimated_tokens"`
+	Hash           string               `json:"hash"`
 }
 
 var ignoredDirs = map[string]struct{}{
@@ -85,10 +97,16 @@ func BuildBundle(opts BuildOptions) (Bundle, error) {
 	if err != nil {
 		return Bundle{}, err
 	}
-	selected, err := ResolveEntries(opts.RepoRoot, opts.Entries)
+	localEntries, remoteEntries := splitWorkspaceEntries(opts.Entries)
+	selected, skipped, err := ResolveEntriesExcluding(opts.RepoRoot, localEntries, opts.Exclude)
 	if err != nil {
 		return Bundle{}, err
 	}
+	workspaces, workspaceSkipped, err := resolveWorkspaces(remoteEntries, opts.Workspaces, opts.Exclude)
+	if err != nil {
+		return Bundle{}, err
+	}
+	skipped = append(skipped, workspaceSkipped...)
 	status, err := gitutil.StatusPorcelain(opts.RepoRoot)
 	if err != nil {
 		return Bundle{}, err
@@ -102,13 +120,30 @@ func BuildBundle(opts BuildOptions) (Bundle, error) {
 		return Bundle{}, err
 	}
 	symbolHits := grepPromptSymbols(opts.RepoRoot, opts.Prompt, opts.MaxGrepHits)
+	imported := []string{}
+	if opts.ExpandImports {
+		budget := opts.MaxBytes - len(status) - len(diff) - selectionBytes(opts.RepoRoot, selected, opts.MaxFileBytes)
+		for _, workspace := range workspaces {
+			budget -= selectionBytes(workspace.RepoMeta.Root, workspace.Files, opts.MaxFileBytes)
+		}
+		for _, rel := range expandImports(opts.RepoRoot, selected, budget, opts.MaxFileBytes) {
+			// expandImports only returns files that exist, so the stat is safe.
+			info, _ := os.Stat(filepath.Join(opts.RepoRoot, filepath.FromSlash(rel)))
+			if info != nil && opts.Exclude.Skip(rel, info.Size()) == "" {
+				imported = append(imported, rel)
+			}
+		}
+	}
 
-	rendered := renderBundle(meta, selected, tree, status, diff, symbolHits, opts.MaxBytes, opts.MaxFileBytes, opts.RepoRoot)
+	rendered := renderBundle(meta, selected, imported, skipped, workspaces, tree, status, diff, symbolHits, opts.MaxBytes, opts.MaxFileBytes, opts.RepoRoot)
 	hash := sha256.Sum256([]byte(rendered))
 
 	return Bundle{
 		RepoMeta:       meta,
 		SelectedFiles:  selected,
+		ImportedFiles:  imported,
+		SkippedFiles:   skipped,
+		WorkspaceRepos: workspaces,
 		TreeOutline:    tree,
 		GitStatus:      status,
 		GitDiff:        diff,
@@ -121,43 +156,72 @@ func BuildBundle(opts BuildOptions) (Bundle, error) {
 }
 
 func ResolveEntries(repoRoot string, entries []string) ([]string, error) {
+	selected, _, err := ResolveEntriesExcluding(repoRoot, entries, ExcludeRules{})
+	return selected, err
+}
+
+// ResolveEntriesExcluding expands entries to repo files, dropping those the
+// rules exclude and reporting them as skipped.
+func ResolveEntriesExcluding(repoRoot string, entries []string, rules ExcludeRules) ([]string, []SkippedFile, error) {
 	normalized := normalizeEntries(repoRoot, entries)
-	found := map[string]struct{}{}
+	res := &resolver{repoRoot: repoRoot, rules: rules, found: map[string]struct{}{}, skipped: map[string]string{}}
 
 	for _, entry := range normalized {
 		if strings.Contains(entry, "**") {
-			if err := resolveDoubleStar(repoRoot, entry, found); err != nil {
-				return nil, err
+			if err := res.resolveDoubleStar(entry); err != nil {
+				return nil, nil, err
 			}
 			continue
 		}
 		if hasGlob(entry) {
 			matches, err := filepath.Glob(filepath.Join(repoRoot, filepath.FromSlash(entry)))
 			if err != nil {
-				return nil, fmt.Errorf("glob %q: %w", entry, err)
+				return nil, nil, fmt.Errorf("glob %q: %w", entry, err)
 			}
 			for _, match := range matches {
-				if err := addPath(repoRoot, match, found); err != nil {
-					return nil, err
+				if err := res.addPath(match); err != nil {
+					return nil, nil, err
 				}
 			}
 			continue
 		}
 		path := filepath.Join(repoRoot, filepath.FromSlash(entry))
-		if err := addPath(repoRoot, path, found); err != nil {
-			return nil, err
+		if err := res.addPath(path); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	out := make([]string, 0, len(found))
-	for file := range found {
+	out := make([]string, 0, len(res.found))
+	for file := range res.found {
 		out = append(out, file)
 	}
 	sort.Strings(out)
-	return out, nil
+	skipped := make([]SkippedFile, 0, len(res.skipped))
+	for file, reason := range res.skipped {
+		skipped = append(skipped, SkippedFile{Path: file, Reason: reason})
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Path < skipped[j].Path })
+	return out, skipped, nil
+}
+
+type resolver struct {
+	repoRoot string
+	rules    ExcludeRules
+	found    map[string]struct{}
+	skipped  map[string]string
+}
+
+// add records a regular file unless the rules exclude it. size comes from the
+// walk, so excluded files are never opened.
+func (r *resolver) add(rel string, size int64) {
+	if reason := r.rules.Skip(rel, size); reason != "" {
+		r.skipped[rel] = reason
+		return
+	}
+	r.found[rel] = struct{}{}
 }
 
-func addPath(repoRoot, absPath string, found map[string]struct{}) error {
+func (r *resolver) addPath(absPath string) error {
 	info, err := os.Stat(absPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -179,28 +243,36 @@ func addPath(repoRoot, absPath string, found map[string]struct{}) error {
 			if !entry.Type().IsRegular() {
 				return nil
 			}
-			rel, relErr := filepath.Rel(repoRoot, path)
-			if relErr != nil {
-				return relErr
-			}
-			found[filepath.ToSlash(rel)] = struct{}{}
-			return nil
+			return r.addWalked(path, entry)
 		})
 	}
-	rel, err := filepath.Rel(repoRoot, absPath)
+	rel, err := filepath.Rel(r.repoRoot, absPath)
 	if err != nil {
 		return err
 	}
-	found[filepath.ToSlash(rel)] = struct{}{}
+	r.add(filepath.ToSlash(rel), info.Size())
 	return nil
 }
 
-func resolveDoubleStar(repoRoot, pattern string, found map[string]struct{}) error {
+func (r *resolver) addWalked(path string, entry os.DirEntry) error {
+	rel, err := filepath.Rel(r.repoRoot, path)
+	if err != nil {
+		return err
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return nil
+	}
+	r.add(filepath.ToSlash(rel), info.Size())
+	return nil
+}
+
+func (r *resolver) resolveDoubleStar(pattern string) error {
 	re, err := doublestarRegex(pattern)
 	if err != nil {
 		return err
 	}
-	return filepath.WalkDir(repoRoot, func(path string, entry os.DirEntry, walkErr error) error {
+	return filepath.WalkDir(r.repoRoot, func(path string, entry os.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
@@ -213,15 +285,14 @@ func resolveDoubleStar(repoRoot, pattern string, found map[string]struct{}) erro
 		if !entry.Type().IsRegular() {
 			return nil
 		}
-		rel, relErr := filepath.Rel(repoRoot, path)
+		rel, relErr := filepath.Rel(r.repoRoot, path)
 		if relErr != nil {
 			return relErr
 		}
-		rel = filepath.ToSlash(rel)
-		if re.MatchString(rel) {
-			found[rel] = struct{}{}
+		if !re.MatchString(filepath.ToSlash(rel)) {
+			return nil
 		}
-		return nil
+		return r.addWalked(path, entry)
 	})
 }
 
@@ -268,7 +339,10 @@ func buildTreeOutline(repoRoot string, maxLines int) ([]string, error) {
 
 func renderBundle(
 	meta gitutil.RepoMeta,
-	selected, tree []string,
+	selected, imported []string,
+	skipped []SkippedFile,
+	workspaces []WorkspaceSelection,
+	tree []string,
 	gitStatus, gitDiff string,
 	symbolHits []string,
 	maxBytes int,
@@ -305,6 +379,26 @@ func renderBundle(
 			break
 		}
 	}
+	if len(imported) > 0 {
+		appendLimited("\n## Imported Files (one hop from selection)\n")
+		for _, item := range imported {
+			if !appendLimited("- " + item + "\n") {
+				break
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		appendLimited(fmt.Sprintf("\n## Skipped Files (%d excluded by size/type rules)\n", len(skipped)))
+		for i, item := range skipped {
+			if i == maxSkippedReport {
+				appendLimited(fmt.Sprintf("- ...and %d more\n", len(skipped)-i))
+				break
+			}
+			if !appendLimited("- " + item.Path + " (" + item.Reason + ")\n") {
+				break
+			}
+		}
+	}
 	appendLimited("\n## Tree Outline\n")
 	for _, item := range tree {
 		if !appendLimited("- " + item + "\n") {
@@ -327,40 +421,90 @@ func renderBundle(
 		}
 	}
 
-	appendLimited("\n## Selected File Contents\n")
-	for _, rel := range selected {
-		if remaining <= 0 {
-			break
-		}
-		abs := filepath.Join(repoRoot, filepath.FromSlash(rel))
-		content, truncated := readFileSnippet(abs, maxFileBytes)
-		header := "### " + rel + "\n"
-		if truncated {
-			header += "(truncated)\n"
+	appendFiles := func(root, label string, files []string) {
+		for _, rel := range files {
+			if remaining <= 0 {
+				return
+			}
+			abs := filepath.Join(root, filepath.FromSlash(rel))
+			content, note := readFileSnippet(abs, rel, maxFileBytes)
+			header := "### " + label + rel + "\n"
+			if note != "" {
+				header += "(" + note + ")\n"
+			}
+			if !appendLimited(header) {
+				return
+			}
+			if !appendLimited(content + "\n") {
+				return
+			}
 		}
-		if !appendLimited(header) {
-			break
+	}
+
+	appendLimited("\n## Selected File Contents\n")
+	appendFiles(repoRoot, "", append(append([]string{}, selected...), imported...))
+
+	// Sibling repos get their own header and metadata so the model can tell
+	// which repository each file belongs to.
+	for _, workspace := range workspaces {
+		appendLimited("\n## Workspace Repo: " + workspace.Name + "\n")
+		appendLimited("root: " + workspace.RepoMeta.Root + "\n")
+		if workspace.RepoMeta.Commit != "" {
+			appendLimited("branch: " + workspace.RepoMeta.Branch + "\n")
+			appendLimited("commit: " + workspace.RepoMeta.Commit + "\n")
+			appendLimited("dirty: " + strconv.FormatBool(workspace.RepoMeta.Dirty) + "\n")
 		}
-		if !appendLimited(content + "\n") {
-			break
+		appendLimited("files:\n")
+		for _, item := range workspace.Files {
+			if !appendLimited("- " + workspaceEntryPrefix + workspace.Name + "/" + item + "\n") {
+				break
+			}
 		}
+		appendLimited("\n")
+		appendFiles(workspace.RepoMeta.Root, workspaceEntryPrefix+workspace.Name+"/", workspace.Files)
 	}
 
 	return buf.String()
 }
 
-func readFileSnippet(path string, maxBytes int) (string, bool) {
+// selectionBytes estimates how much of the bundle the selected files take,
+// counting each at most maxFileBytes.
+func selectionBytes(repoRoot string, selected []string, maxFileBytes int) int {
+	total := 0
+	for _, rel := range selected {
+		info, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		total += min(int(info.Size()), maxFileBytes)
+	}
+	return total
+}
+
+// readFileSnippet returns a file's content for the bundle plus a note for the
+// section header when it is not the full file. Oversized source files are
+// replaced by a declaration outline when their language is understood, and
+// prefix-truncated otherwise.
+func readFileSnippet(path, rel string, maxBytes int) (string, string) {
 	raw, err := os.ReadFile(path)
 	if err != nil {
-		return "[read error: " + err.Error() + "]", false
+		return "[read error: " + err.Error() + "]", ""
 	}
 	if bytes.IndexByte(raw, 0) >= 0 {
-		return "[binary file omitted]", false
+		return "[binary file omitted]", ""
 	}
-	if maxBytes > 0 && len(raw) > maxBytes {
-		return string(raw[:maxBytes]), true
+	if maxBytes <= 0 || len(raw) <= maxBytes {
+		return string(raw), ""
+	}
+	if outline, ok := summarizeFile(rel, raw); ok {
+		note := fmt.Sprintf("summarized: %d bytes, bodies omitted", len(raw))
+		if len(outline) > maxBytes {
+			outline = outline[:maxBytes]
+			note += ", outline truncated"
+		}
+		return outline, note
 	}
-	return string(raw), false
+	return string(raw[:maxBytes]), "truncated"
 }
 
 func grepPromptSymbols(repoRoot, prompt string, maxHits int) []string {
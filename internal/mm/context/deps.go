@@ -0,0 +1,215 @@
+package context
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	tsImportPattern     = regexp.MustCompile(`(?:\bfrom\s+|\bimport\s+|\brequire\(\s*|\bimport\(\s*)['"](\.{1,2}/[^'"]+)['"]`)
+	pyFromImportPattern = regexp.MustCompile(`^\s*from\s+(\.*[\w.]*)\s+import\b`)
+	pyImportPattern     = regexp.MustCompile(`^\s*import\s+([\w.]+(?:\s*,\s*[\w.]+)*)`)
+	goModulePattern     = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+)
+
+var tsResolveSuffixes = []string{"", ".ts", ".tsx", ".js", ".jsx", ".mjs", "/index.ts", "/index.tsx", "/index.js"}
+
+// expandImports returns repo files imported by the selected Go, TS/JS and
+// Python files, one hop only. Files are added in a stable order until their
+// combined size reaches byteBudget, counting each file at most maxFileBytes
+// since larger ones are summarized; already-selected files are skipped.
+func expandImports(repoRoot string, selected []string, byteBudget, maxFileBytes int) []string {
+	if byteBudget <= 0 {
+		return nil
+	}
+	have := map[string]struct{}{}
+	for _, rel := range selected {
+		have[rel] = struct{}{}
+	}
+	goModule := readGoModule(repoRoot)
+
+	candidates := []string{}
+	seen := map[string]struct{}{}
+	for _, rel := range selected {
+		for _, dep := range fileImports(repoRoot, rel, goModule) {
+			if _, ok := have[dep]; ok {
+				continue
+			}
+			if _, ok := seen[dep]; ok {
+				continue
+			}
+			seen[dep] = struct{}{}
+			candidates = append(candidates, dep)
+		}
+	}
+	sort.Strings(candidates)
+
+	out := []string{}
+	used := 0
+	for _, rel := range candidates {
+		info, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		size := min(int(info.Size()), maxFileBytes)
+		if used+size > byteBudget {
+			continue
+		}
+		used += size
+		out = append(out, rel)
+	}
+	return out
+}
+
+func fileImports(repoRoot, rel, goModule string) []string {
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".go":
+		return goImports(repoRoot, rel, goModule)
+	case ".ts", ".tsx", ".js", ".jsx", ".mjs":
+		return tsImports(repoRoot, rel)
+	case ".py":
+		return pyImports(repoRoot, rel)
+	}
+	return nil
+}
+
+// goImports maps imports under the repo's own module to the non-test files of
+// the imported package directory.
+func goImports(repoRoot, rel, goModule string) []string {
+	if goModule == "" {
+		return nil
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(repoRoot, filepath.FromSlash(rel)), nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	out := []string{}
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var dir string
+		switch {
+		case importPath == goModule:
+			dir = "."
+		case strings.HasPrefix(importPath, goModule+"/"):
+			dir = strings.TrimPrefix(importPath, goModule+"/")
+		default:
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(repoRoot, filepath.FromSlash(dir)))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			out = append(out, path.Clean(path.Join(dir, name)))
+		}
+	}
+	return out
+}
+
+func tsImports(repoRoot, rel string) []string {
+	out := []string{}
+	dir := path.Dir(rel)
+	scanLines(repoRoot, rel, func(line string) {
+		for _, match := range tsImportPattern.FindAllStringSubmatch(line, -1) {
+			base := path.Join(dir, match[1])
+			for _, suffix := range tsResolveSuffixes {
+				if candidate := base + suffix; isRepoFile(repoRoot, candidate) {
+					out = append(out, candidate)
+					break
+				}
+			}
+		}
+	})
+	return out
+}
+
+// pyImports resolves relative imports against the importing file and absolute
+// ones against the repo root, as module.py or package/__init__.py.
+func pyImports(repoRoot, rel string) []string {
+	out := []string{}
+	dir := path.Dir(rel)
+	resolve := func(module string) {
+		base := ""
+		trimmed := strings.TrimLeft(module, ".")
+		if dots := len(module) - len(trimmed); dots > 0 {
+			base = dir
+			for i := 1; i < dots; i++ {
+				base = path.Dir(base)
+			}
+		}
+		if trimmed == "" {
+			if candidate := path.Join(base, "__init__.py"); isRepoFile(repoRoot, candidate) {
+				out = append(out, candidate)
+			}
+			return
+		}
+		modulePath := path.Join(base, strings.ReplaceAll(trimmed, ".", "/"))
+		for _, candidate := range []string{modulePath + ".py", modulePath + "/__init__.py"} {
+			if isRepoFile(repoRoot, candidate) {
+				out = append(out, candidate)
+				return
+			}
+		}
+	}
+	scanLines(repoRoot, rel, func(line string) {
+		if match := pyFromImportPattern.FindStringSubmatch(line); match != nil {
+			resolve(match[1])
+			return
+		}
+		if match := pyImportPattern.FindStringSubmatch(line); match != nil {
+			for _, module := range strings.Split(match[1], ",") {
+				resolve(strings.TrimSpace(module))
+			}
+		}
+	})
+	return out
+}
+
+func scanLines(repoRoot, rel string, fn func(string)) {
+	file, err := os.Open(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+}
+
+func isRepoFile(repoRoot, rel string) bool {
+	rel = path.Clean(rel)
+	if rel == "." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+	return err == nil && info.Mode().IsRegular()
+}
+
+func readGoModule(repoRoot string) string {
+	raw, err := os.ReadFile(filepath.Join(repoRoot, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	match := goModulePattern.FindSubmatch(raw)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
@@ -0,0 +1,43 @@
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandImportsOneHop(t *testing.T) {
+	repo := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("go.mod", "module example.com/demo\n\ngo 1.22\n")
+	write("cmd/app/main.go", "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/demo/lib\"\n)\n\nfunc main() { fmt.Println(lib.X) }\n")
+	write("lib/lib.go", "package lib\n\nimport \"example.com/demo/deep\"\n\nvar X = deep.Y\n")
+	write("lib/lib_test.go", "package lib\n")
+	write("deep/deep.go", "package deep\n\nvar Y = 1\n")
+	write("web/app.ts", "import { h } from './util';\nconst x = require(\"../shared/index\");\n")
+	write("web/util.ts", "export const h = 1;\n")
+	write("shared/index.js", "module.exports = {};\n")
+	write("py/pkg/main.py", "from . import helpers\nfrom .models import User\nimport os, py.pkg.config\n")
+	write("py/pkg/__init__.py", "")
+	write("py/pkg/models.py", "class User: pass\n")
+	write("py/pkg/config.py", "DEBUG = False\n")
+
+	got := expandImports(repo, []string{"cmd/app/main.go", "py/pkg/main.py", "web/app.ts"}, 1<<20, 64000)
+	want := []string{"lib/lib.go", "py/pkg/__init__.py", "py/pkg/config.py", "py/pkg/models.py", "shared/index.js", "web/util.ts"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if limited := expandImports(repo, []string{"cmd/app/main.go"}, 5, 64000); len(limited) != 0 {
+		t.Fatalf("expected budget to exclude imports, got %v", limited)
+	}
+}
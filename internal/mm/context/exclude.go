@@ -0,0 +1,71 @@
+package context
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// defaultExcludePatterns covers files that are large, generated or binary and
+// rarely help a model: lockfiles, images, archives, fonts and compiled output.
+var defaultExcludePatterns = []string{
+	"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "go.sum", "Cargo.lock", "poetry.lock", "Pipfile.lock", "composer.lock", "Gemfile.lock",
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.webp", "*.ico", "*.bmp", "*.tiff", "*.psd",
+	"*.pdf", "*.zip", "*.tar", "*.gz", "*.tgz", "*.bz2", "*.xz", "*.7z", "*.jar",
+	"*.woff", "*.woff2", "*.ttf", "*.otf", "*.eot",
+	"*.mp3", "*.mp4", "*.mov", "*.wav",
+	"*.exe", "*.dll", "*.so", "*.dylib", "*.a", "*.o", "*.class", "*.pyc", "*.wasm",
+	"*.min.js", "*.min.css", "*.map",
+}
+
+// maxSkippedReport bounds how many skipped files a bundle lists.
+const maxSkippedReport = 50
+
+// ExcludeRules decide which resolved files are left out of a bundle, using only
+// the path and the size from the directory walk so excluded files are never
+// read.
+type ExcludeRules struct {
+	// MaxFileBytes skips files larger than this; 0 disables the size check.
+	MaxFileBytes int64
+	// Patterns match a file's base name, or its repo-relative path when the
+	// pattern contains a "/".
+	Patterns []string
+}
+
+// DefaultExcludeRules returns the built-in patterns plus extra, with files over
+// maxFileKB kilobytes skipped.
+func DefaultExcludeRules(maxFileKB int, extra []string) ExcludeRules {
+	patterns := append(append([]string{}, defaultExcludePatterns...), extra...)
+	return ExcludeRules{
+		MaxFileBytes: int64(maxFileKB) * 1024,
+		Patterns:     patterns,
+	}
+}
+
+// SkippedFile is a file the rules excluded, with the reason shown in reports.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Skip reports why rel should be excluded, or "" to keep it.
+func (r ExcludeRules) Skip(rel string, size int64) string {
+	base := path.Base(rel)
+	for _, pattern := range r.Patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		target := base
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return "matches " + pattern
+		}
+	}
+	if r.MaxFileBytes > 0 && size > r.MaxFileBytes {
+		return fmt.Sprintf("%d KB exceeds %d KB limit", size/1024, r.MaxFileBytes/1024)
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveEntriesExcludingReportsSkipped(t *testing.T) {
+	repo := t.TempDir()
+	write := func(rel string, size int) {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("src/main.go", 10)
+	write("src/big.go", 3*1024)
+	write("src/logo.png", 10)
+	write("go.sum", 10)
+	write("testdata/fixtures/a.json", 10)
+
+	rules := DefaultExcludeRules(2, []string{"testdata/fixtures/*"})
+	selected, skipped, err := ResolveEntriesExcluding(repo, []string{"**"}, rules)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if strings.Join(selected, ",") != "src/main.go" {
+		t.Fatalf("unexpected selection %v", selected)
+	}
+	reasons := map[string]string{}
+	for _, item := range skipped {
+		reasons[item.Path] = item.Reason
+	}
+	want := map[string]string{
+		"go.sum":                   "matches go.sum",
+		"src/big.go":               "3 KB exceeds 2 KB limit",
+		"src/logo.png":             "matches *.png",
+		"testdata/fixtures/a.json": "matches testdata/fixtures/*",
+	}
+	if len(reasons) != len(want) {
+		t.Fatalf("expected %d skipped files, got %v", len(want), skipped)
+	}
+	for path, reason := range want {
+		if reasons[path] != reason {
+			t.Fatalf("%s: expected reason %q, got %q", path, reason, reasons[path])
+		}
+	}
+
+	if all, _, _ := ResolveEntriesExcluding(repo, []string{"**"}, ExcludeRules{}); len(all) != 5 {
+		t.Fatalf("zero rules should keep every file, got %v", all)
+	}
+}
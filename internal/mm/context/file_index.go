@@ -0,0 +1,189 @@
+package context
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/mm/gitutil"
+)
+
+const (
+	fileIndexRelPath = ".modeloman/file_index.json"
+	fileIndexVersion = 1
+)
+
+// fileIndex caches the repo file list with sizes, so the context picker does
+// not re-walk the tree on every launch. Key is gitutil.WorktreeKey for git
+// repos; outside git, DirMtimes records every walked directory and the index
+// is reused while none of them changed.
+type fileIndex struct {
+	Version   int              `json:"version"`
+	Source    string           `json:"source"`
+	Key       string           `json:"key,omitempty"`
+	DirMtimes map[string]int64 `json:"dir_mtimes,omitempty"`
+	Files     []indexedFile    `json:"files"`
+}
+
+type indexedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// RepoFiles lists candidate files for the context picker, leaving out those the
+// exclusion rules would skip anyway. It uses `git ls-files` when repoRoot is a
+// git work tree and a directory walk otherwise, and reuses the cached index
+// at .modeloman/file_index.json until git status or directory mtimes change.
+func RepoFiles(repoRoot string, rules ExcludeRules) ([]string, error) {
+	index, err := loadOrRebuildIndex(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(index.Files))
+	for _, file := range index.Files {
+		if rules.Skip(file.Path, file.Size) == "" {
+			files = append(files, file.Path)
+		}
+	}
+	return files, nil
+}
+
+func loadOrRebuildIndex(repoRoot string) (fileIndex, error) {
+	cached, cachedOK := readFileIndex(repoRoot)
+
+	if key, err := gitutil.WorktreeKey(repoRoot, ".modeloman"); err == nil {
+		if cachedOK && cached.Source == "git" && cached.Key == key {
+			return cached, nil
+		}
+		paths, err := gitutil.ListFiles(repoRoot)
+		if err == nil {
+			index := fileIndex{Version: fileIndexVersion, Source: "git", Key: key, Files: statFiles(repoRoot, paths)}
+			writeFileIndex(repoRoot, index)
+			return index, nil
+		}
+	}
+
+	if cachedOK && cached.Source == "walk" && dirsUnchanged(repoRoot, cached.DirMtimes) {
+		return cached, nil
+	}
+	// Create the state dir before recording mtimes so writing the index does
+	// not invalidate the root directory's entry.
+	_ = os.MkdirAll(filepath.Join(repoRoot, filepath.Dir(fileIndexRelPath)), 0o755)
+	index, err := walkIndex(repoRoot)
+	if err != nil {
+		return fileIndex{}, err
+	}
+	writeFileIndex(repoRoot, index)
+	return index, nil
+}
+
+// statFiles sizes the listed files, dropping deleted ones, non-regular files,
+// ignored directories and mm's own state.
+func statFiles(repoRoot string, paths []string) []indexedFile {
+	files := make([]indexedFile, 0, len(paths))
+	for _, rel := range paths {
+		rel = filepath.ToSlash(rel)
+		if indexIgnored(rel) {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, indexedFile{Path: rel, Size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+func indexIgnored(rel string) bool {
+	if strings.HasPrefix(rel, ".modeloman/") {
+		return true
+	}
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if _, skip := ignoredDirs[part]; skip {
+			return true
+		}
+	}
+	return false
+}
+
+func walkIndex(repoRoot string) (fileIndex, error) {
+	index := fileIndex{Version: fileIndexVersion, Source: "walk", DirMtimes: map[string]int64{}, Files: []indexedFile{}}
+	err := filepath.WalkDir(repoRoot, func(path string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(repoRoot, path)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if _, skip := ignoredDirs[entry.Name()]; skip || rel == ".modeloman" {
+				return filepath.SkipDir
+			}
+			index.DirMtimes[rel] = info.ModTime().UnixNano()
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			index.Files = append(index.Files, indexedFile{Path: rel, Size: info.Size()})
+		}
+		return nil
+	})
+	sort.Slice(index.Files, func(i, j int) bool { return index.Files[i].Path < index.Files[j].Path })
+	return index, err
+}
+
+// dirsUnchanged stats each recorded directory; adding, removing or renaming a
+// file updates its parent's mtime, so this catches list changes without
+// reading any directory.
+func dirsUnchanged(repoRoot string, mtimes map[string]int64) bool {
+	if len(mtimes) == 0 {
+		return false
+	}
+	for rel, mtime := range mtimes {
+		info, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(rel)))
+		if err != nil || !info.IsDir() || info.ModTime().UnixNano() != mtime {
+			return false
+		}
+	}
+	return true
+}
+
+func readFileIndex(repoRoot string) (fileIndex, bool) {
+	raw, err := os.ReadFile(filepath.Join(repoRoot, fileIndexRelPath))
+	if err != nil {
+		return fileIndex{}, false
+	}
+	var index fileIndex
+	if err := json.Unmarshal(raw, &index); err != nil || index.Version != fileIndexVersion {
+		return fileIndex{}, false
+	}
+	return index, true
+}
+
+// writeFileIndex is best effort: a read-only checkout still lists files, it
+// just rebuilds the index next time.
+func writeFileIndex(repoRoot string, index fileIndex) {
+	path := filepath.Join(repoRoot, fileIndexRelPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, raw, 0o644); err != nil {
+		return
+	}
+	_ = os.Rename(tempPath, path)
+}
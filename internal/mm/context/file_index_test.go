@@ -0,0 +1,106 @@
+package context
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepoFilesWalkIndexInvalidatesOnDirChange(t *testing.T) {
+	repo := t.TempDir()
+	write := func(rel string) {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("a.go")
+	write("pkg/b.go")
+	write("node_modules/dep/index.js")
+	write("img/logo.png")
+
+	rules := DefaultExcludeRules(0, nil)
+	files, err := RepoFiles(repo, rules)
+	if err != nil {
+		t.Fatalf("repo files: %v", err)
+	}
+	if strings.Join(files, ",") != "a.go,pkg/b.go" {
+		t.Fatalf("unexpected files %v", files)
+	}
+	index, ok := readFileIndex(repo)
+	if !ok || index.Source != "walk" {
+		t.Fatalf("expected a cached walk index, got %+v", index)
+	}
+
+	// A cached index is served as long as no directory changed.
+	if files, _ := RepoFiles(repo, rules); strings.Join(files, ",") != "a.go,pkg/b.go" {
+		t.Fatalf("unexpected cached files %v", files)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	write("pkg/c.go")
+	files, err = RepoFiles(repo, rules)
+	if err != nil {
+		t.Fatalf("repo files: %v", err)
+	}
+	if strings.Join(files, ",") != "a.go,pkg/b.go,pkg/c.go" {
+		t.Fatalf("expected new file after dir change, got %v", files)
+	}
+}
+
+func TestRepoFilesUsesGitListing(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, out)
+		}
+	}
+	run("init", "-q")
+	for rel, content := range map[string]string{"main.go": "package main\n", "build/out.txt": "x", ".gitignore": "build/\n"} {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	files, err := RepoFiles(repo, ExcludeRules{})
+	if err != nil {
+		t.Fatalf("repo files: %v", err)
+	}
+	if strings.Join(files, ",") != ".gitignore,main.go" {
+		t.Fatalf("expected git-visible files only, got %v", files)
+	}
+	first, ok := readFileIndex(repo)
+	if !ok || first.Source != "git" {
+		t.Fatalf("expected a cached git index, got %+v", first)
+	}
+
+	// Writing the index must not change the worktree key it was stored under.
+	if _, err := RepoFiles(repo, ExcludeRules{}); err != nil {
+		t.Fatalf("repo files: %v", err)
+	}
+	if second, _ := readFileIndex(repo); second.Key != first.Key {
+		t.Fatalf("index key changed without a worktree change")
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "util.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if files, _ := RepoFiles(repo, ExcludeRules{}); strings.Join(files, ",") != ".gitignore,main.go,util.go" {
+		t.Fatalf("expected untracked file after status change, got %v", files)
+	}
+}
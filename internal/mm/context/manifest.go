@@ -0,0 +1,143 @@
+package context
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle manifests live outside the per-user context.json so they can be
+// committed and shared across a team.
+const manifestRelDir = ".modeloman/bundles"
+
+var manifestNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Manifest is a named, reusable context selection of paths and globs.
+type Manifest struct {
+	Version     int      `json:"version"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Entries     []string `json:"entries"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+func LoadManifest(repoRoot, name string) (Manifest, error) {
+	path, err := manifestPath(repoRoot, name)
+	if err != nil {
+		return Manifest{}, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Manifest{}, fmt.Errorf("bundle %q not found", name)
+		}
+		return Manifest{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if manifest.Version == 0 {
+		manifest.Version = 1
+	}
+	manifest.Name = name
+	manifest.Entries = normalizeEntries(repoRoot, manifest.Entries)
+	return manifest, nil
+}
+
+func SaveManifest(repoRoot string, manifest Manifest) (Manifest, error) {
+	path, err := manifestPath(repoRoot, manifest.Name)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest.Version = 1
+	manifest.Description = strings.TrimSpace(manifest.Description)
+	manifest.Entries = normalizeEntries(repoRoot, manifest.Entries)
+	if len(manifest.Entries) == 0 {
+		return Manifest{}, errors.New("bundle needs at least one path or glob")
+	}
+	manifest.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Manifest{}, fmt.Errorf("mkdir bundle dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("encode bundle: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return Manifest{}, fmt.Errorf("write bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// ListManifests returns every saved manifest sorted by name. Files that fail
+// to decode are skipped so one bad manifest does not hide the rest.
+func ListManifests(repoRoot string) ([]Manifest, error) {
+	dir := filepath.Join(repoRoot, manifestRelDir)
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Manifest{}, nil
+		}
+		return nil, fmt.Errorf("read bundle dir: %w", err)
+	}
+	out := make([]Manifest, 0, len(items))
+	for _, item := range items {
+		if item.IsDir() || filepath.Ext(item.Name()) != ".json" {
+			continue
+		}
+		manifest, err := LoadManifest(repoRoot, strings.TrimSuffix(item.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		out = append(out, manifest)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func DeleteManifest(repoRoot, name string) error {
+	path, err := manifestPath(repoRoot, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("bundle %q not found", name)
+		}
+		return fmt.Errorf("delete bundle: %w", err)
+	}
+	return nil
+}
+
+// ManifestEntries loads the named manifests and returns their combined
+// entries.
+func ManifestEntries(repoRoot string, names []string) ([]string, error) {
+	out := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		manifest, err := LoadManifest(repoRoot, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, manifest.Entries...)
+	}
+	return normalizeEntries(repoRoot, out), nil
+}
+
+func manifestPath(repoRoot, name string) (string, error) {
+	if !manifestNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid bundle name %q (use letters, digits, '.', '_' or '-')", name)
+	}
+	return filepath.Join(repoRoot, manifestRelDir, name+".json"), nil
+}
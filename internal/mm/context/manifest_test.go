@@ -0,0 +1,46 @@
+package context
+
+import "testing"
+
+func TestManifestSaveListEntries(t *testing.T) {
+	repo := t.TempDir()
+
+	if _, err := SaveManifest(repo, Manifest{Name: "../escape", Entries: []string{"a.go"}}); err == nil {
+		t.Fatalf("expected invalid name to be rejected")
+	}
+	if _, err := SaveManifest(repo, Manifest{Name: "auth-refactor", Entries: []string{"./internal/auth/*.go", "README.md"}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := SaveManifest(repo, Manifest{Name: "docs", Entries: []string{"README.md", "docs/**/*.md"}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	manifests, err := ListManifests(repo)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(manifests) != 2 || manifests[0].Name != "auth-refactor" || manifests[1].Name != "docs" {
+		t.Fatalf("unexpected manifests: %+v", manifests)
+	}
+
+	entries, err := ManifestEntries(repo, []string{"auth-refactor", "docs"})
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	want := []string{"README.md", "docs/**/*.md", "internal/auth/*.go"}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %v, got %v", want, entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, entries)
+		}
+	}
+
+	if err := DeleteManifest(repo, "docs"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := ManifestEntries(repo, []string{"docs"}); err == nil {
+		t.Fatalf("expected missing bundle error")
+	}
+}
@@ -0,0 +1,221 @@
+package context
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	objectiveHistoryRelPath   = ".modeloman/objective_history.json"
+	objectiveTemplatesRelPath = ".modeloman/objective_templates.json"
+	// maxObjectiveHistory bounds the history file; the least recently used
+	// objectives are dropped first.
+	maxObjectiveHistory = 200
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+type ObjectiveEntry struct {
+	Text       string `json:"text"`
+	Uses       int    `json:"uses"`
+	LastUsedAt string `json:"last_used_at"`
+}
+
+// ObjectiveTemplate is a named objective with {placeholders}, e.g.
+// "{file}: fix failing test {test}".
+type ObjectiveTemplate struct {
+	Name      string `json:"name"`
+	Text      string `json:"text"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type objectiveHistoryFile struct {
+	Version int              `json:"version"`
+	Entries []ObjectiveEntry `json:"entries"`
+}
+
+type objectiveTemplatesFile struct {
+	Version   int                 `json:"version"`
+	Templates []ObjectiveTemplate `json:"templates"`
+}
+
+// LoadObjectiveHistory returns past objectives, most recently used first.
+func LoadObjectiveHistory(repoRoot string) ([]ObjectiveEntry, error) {
+	var file objectiveHistoryFile
+	if err := readJSONState(repoRoot, objectiveHistoryRelPath, &file); err != nil {
+		return nil, fmt.Errorf("read objective history: %w", err)
+	}
+	if file.Entries == nil {
+		return []ObjectiveEntry{}, nil
+	}
+	return file.Entries, nil
+}
+
+// RecordObjective moves text to the front of the history, counting the use.
+func RecordObjective(repoRoot, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	entries, err := LoadObjectiveHistory(repoRoot)
+	if err != nil {
+		return err
+	}
+	entry := ObjectiveEntry{Text: text}
+	next := make([]ObjectiveEntry, 0, len(entries)+1)
+	for _, item := range entries {
+		if item.Text == text {
+			entry.Uses = item.Uses
+			continue
+		}
+		next = append(next, item)
+	}
+	entry.Uses++
+	entry.LastUsedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	next = append([]ObjectiveEntry{entry}, next...)
+	if len(next) > maxObjectiveHistory {
+		next = next[:maxObjectiveHistory]
+	}
+	if err := writeJSONState(repoRoot, objectiveHistoryRelPath, objectiveHistoryFile{Version: 1, Entries: next}); err != nil {
+		return fmt.Errorf("write objective history: %w", err)
+	}
+	return nil
+}
+
+// ListObjectiveTemplates returns templates sorted by name.
+func ListObjectiveTemplates(repoRoot string) ([]ObjectiveTemplate, error) {
+	var file objectiveTemplatesFile
+	if err := readJSONState(repoRoot, objectiveTemplatesRelPath, &file); err != nil {
+		return nil, fmt.Errorf("read objective templates: %w", err)
+	}
+	if file.Templates == nil {
+		return []ObjectiveTemplate{}, nil
+	}
+	sort.Slice(file.Templates, func(i, j int) bool { return file.Templates[i].Name < file.Templates[j].Name })
+	return file.Templates, nil
+}
+
+func GetObjectiveTemplate(repoRoot, name string) (ObjectiveTemplate, error) {
+	templates, err := ListObjectiveTemplates(repoRoot)
+	if err != nil {
+		return ObjectiveTemplate{}, err
+	}
+	for _, item := range templates {
+		if item.Name == strings.TrimSpace(name) {
+			return item, nil
+		}
+	}
+	return ObjectiveTemplate{}, fmt.Errorf("objective template %q not found", name)
+}
+
+// SaveObjectiveTemplate creates or replaces a template. Names follow the
+// bundle manifest rules.
+func SaveObjectiveTemplate(repoRoot, name, text string) (ObjectiveTemplate, error) {
+	name = strings.TrimSpace(name)
+	if !manifestNamePattern.MatchString(name) {
+		return ObjectiveTemplate{}, fmt.Errorf("invalid template name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ObjectiveTemplate{}, errors.New("template text is required")
+	}
+	templates, err := ListObjectiveTemplates(repoRoot)
+	if err != nil {
+		return ObjectiveTemplate{}, err
+	}
+	template := ObjectiveTemplate{Name: name, Text: text, UpdatedAt: time.Now().UTC().Format(time.RFC3339Nano)}
+	next := []ObjectiveTemplate{template}
+	for _, item := range templates {
+		if item.Name != name {
+			next = append(next, item)
+		}
+	}
+	sort.Slice(next, func(i, j int) bool { return next[i].Name < next[j].Name })
+	if err := writeJSONState(repoRoot, objectiveTemplatesRelPath, objectiveTemplatesFile{Version: 1, Templates: next}); err != nil {
+		return ObjectiveTemplate{}, fmt.Errorf("write objective templates: %w", err)
+	}
+	return template, nil
+}
+
+// DeleteObjectiveTemplate reports whether a template was removed.
+func DeleteObjectiveTemplate(repoRoot, name string) (bool, error) {
+	templates, err := ListObjectiveTemplates(repoRoot)
+	if err != nil {
+		return false, err
+	}
+	next := make([]ObjectiveTemplate, 0, len(templates))
+	for _, item := range templates {
+		if item.Name != strings.TrimSpace(name) {
+			next = append(next, item)
+		}
+	}
+	if len(next) == len(templates) {
+		return false, nil
+	}
+	if err := writeJSONState(repoRoot, objectiveTemplatesRelPath, objectiveTemplatesFile{Version: 1, Templates: next}); err != nil {
+		return false, fmt.Errorf("write objective templates: %w", err)
+	}
+	return true, nil
+}
+
+// Placeholders lists the distinct {name} placeholders in text, in order of
+// first appearance.
+func Placeholders(text string) []string {
+	seen := map[string]struct{}{}
+	out := []string{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		if _, ok := seen[match[1]]; ok {
+			continue
+		}
+		seen[match[1]] = struct{}{}
+		out = append(out, match[1])
+	}
+	return out
+}
+
+// FillTemplate substitutes values into text and fails listing any
+// placeholder left without a value.
+func FillTemplate(text string, values map[string]string) (string, error) {
+	missing := []string{}
+	for _, name := range Placeholders(text) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template needs values for: %s", strings.Join(missing, ", "))
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(token string) string {
+		return values[token[1:len(token)-1]]
+	}), nil
+}
+
+func readJSONState(repoRoot, rel string, out any) error {
+	raw, err := os.ReadFile(filepath.Join(repoRoot, rel))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func writeJSONState(repoRoot, rel string, value any) error {
+	path := filepath.Join(repoRoot, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
@@ -0,0 +1,54 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestObjectiveHistoryAndTemplates(t *testing.T) {
+	repo := t.TempDir()
+	for _, text := range []string{"fix login bug", "add retries", "fix login bug"} {
+		if err := RecordObjective(repo, text); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	history, err := LoadObjectiveHistory(repo)
+	if err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	if len(history) != 2 || history[0].Text != "fix login bug" || history[0].Uses != 2 || history[1].Text != "add retries" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+
+	if _, err := SaveObjectiveTemplate(repo, "bad name", "x"); err == nil {
+		t.Fatalf("expected invalid template name to be rejected")
+	}
+	if _, err := SaveObjectiveTemplate(repo, "fix-test", "{file}: fix failing test {test} in {file}"); err != nil {
+		t.Fatalf("save template: %v", err)
+	}
+	template, err := GetObjectiveTemplate(repo, "fix-test")
+	if err != nil {
+		t.Fatalf("get template: %v", err)
+	}
+	if got := strings.Join(Placeholders(template.Text), ","); got != "file,test" {
+		t.Fatalf("unexpected placeholders %q", got)
+	}
+	if _, err := FillTemplate(template.Text, map[string]string{"file": "auth.go"}); err == nil || !strings.Contains(err.Error(), "test") {
+		t.Fatalf("expected missing placeholder error, got %v", err)
+	}
+	filled, err := FillTemplate(template.Text, map[string]string{"file": "auth.go", "test": "TestLogin"})
+	if err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	if filled != "auth.go: fix failing test TestLogin in auth.go" {
+		t.Fatalf("unexpected fill %q", filled)
+	}
+
+	removed, err := DeleteObjectiveTemplate(repo, "fix-test")
+	if err != nil || !removed {
+		t.Fatalf("delete: removed=%v err=%v", removed, err)
+	}
+	if templates, _ := ListObjectiveTemplates(repo); len(templates) != 0 {
+		t.Fatalf("expected no templates, got %+v", templates)
+	}
+}
@@ -0,0 +1,97 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const pipelinesRelPath = ".modeloman/pipelines.json"
+
+// Pipeline runs its stages in order under one hub run, e.g. plan → implement
+// → verify. Commit .modeloman/pipelines.json to share pipelines.
+type Pipeline struct {
+	Name   string          `json:"name"`
+	Stages []PipelineStage `json:"stages"`
+}
+
+// PipelineStage is one backend session of a pipeline. Empty Backend and
+// Skill fall back to the run's.
+type PipelineStage struct {
+	Name         string `json:"name"`
+	Backend      string `json:"backend,omitempty"`
+	Skill        string `json:"skill,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	// Verify runs the verification commands after this stage. When no
+	// stage sets it, only the last stage verifies.
+	Verify bool `json:"verify,omitempty"`
+}
+
+type pipelinesFile struct {
+	Pipelines []Pipeline `json:"pipelines"`
+}
+
+// LoadPipelines returns the repo's pipelines, validated, in file order.
+func LoadPipelines(repoRoot string) ([]Pipeline, error) {
+	var file pipelinesFile
+	if err := readJSONState(repoRoot, pipelinesRelPath, &file); err != nil {
+		return nil, fmt.Errorf("read %s: %w", pipelinesRelPath, err)
+	}
+	seen := map[string]struct{}{}
+	for _, pipeline := range file.Pipelines {
+		if err := pipeline.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", pipelinesRelPath, err)
+		}
+		if _, ok := seen[pipeline.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate pipeline %q", pipelinesRelPath, pipeline.Name)
+		}
+		seen[pipeline.Name] = struct{}{}
+	}
+	if file.Pipelines == nil {
+		return []Pipeline{}, nil
+	}
+	return file.Pipelines, nil
+}
+
+func GetPipeline(repoRoot, name string) (Pipeline, error) {
+	pipelines, err := LoadPipelines(repoRoot)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	for _, pipeline := range pipelines {
+		if pipeline.Name == strings.TrimSpace(name) {
+			return pipeline, nil
+		}
+	}
+	return Pipeline{}, fmt.Errorf("pipeline %q not found in %s", name, pipelinesRelPath)
+}
+
+// VerifiesAt reports whether stage index i runs the verification commands.
+func (p Pipeline) VerifiesAt(i int) bool {
+	for _, stage := range p.Stages {
+		if stage.Verify {
+			return p.Stages[i].Verify
+		}
+	}
+	return i == len(p.Stages)-1
+}
+
+func (p Pipeline) validate() error {
+	if !manifestNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid pipeline name %q: use letters, digits, '.', '_' or '-'", p.Name)
+	}
+	if len(p.Stages) == 0 {
+		return errors.New("pipeline " + p.Name + " has no stages")
+	}
+	seen := map[string]struct{}{}
+	for _, stage := range p.Stages {
+		if !manifestNamePattern.MatchString(stage.Name) {
+			return fmt.Errorf("pipeline %s: invalid stage name %q", p.Name, stage.Name)
+		}
+		if _, ok := seen[stage.Name]; ok {
+			return fmt.Errorf("pipeline %s: duplicate stage %q", p.Name, stage.Name)
+		}
+		seen[stage.Name] = struct{}{}
+	}
+	return nil
+}
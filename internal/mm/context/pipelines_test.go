@@ -0,0 +1,49 @@
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPipelines(t *testing.T) {
+	repo := t.TempDir()
+	write := func(raw string) {
+		t.Helper()
+		path := filepath.Join(repo, pipelinesRelPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"pipelines": [
+		{"name": "piv", "stages": [{"name": "plan", "backend": "claude"}, {"name": "implement"}, {"name": "verify"}]},
+		{"name": "checked", "stages": [{"name": "implement", "verify": true}, {"name": "review"}]}
+	]}`)
+	pipeline, err := GetPipeline(repo, "piv")
+	if err != nil {
+		t.Fatalf("get pipeline: %v", err)
+	}
+	if pipeline.VerifiesAt(0) || pipeline.VerifiesAt(1) || !pipeline.VerifiesAt(2) {
+		t.Fatalf("expected only the last stage to verify by default")
+	}
+	checked, err := GetPipeline(repo, "checked")
+	if err != nil {
+		t.Fatalf("get pipeline: %v", err)
+	}
+	if !checked.VerifiesAt(0) || checked.VerifiesAt(1) {
+		t.Fatalf("expected only the marked stage to verify")
+	}
+	if _, err := GetPipeline(repo, "missing"); err == nil {
+		t.Fatalf("expected unknown pipeline to fail")
+	}
+
+	write(`{"pipelines": [{"name": "dup", "stages": [{"name": "plan"}, {"name": "plan"}]}]}`)
+	if _, err := LoadPipelines(repo); err == nil || !strings.Contains(err.Error(), "duplicate stage") {
+		t.Fatalf("expected duplicate stage error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package context
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Declaration patterns for languages without a parser in the standard
+// library. They are line-oriented, so multi-line signatures only keep their
+// first line.
+var outlinePatterns = map[string]*regexp.Regexp{
+	".py":   regexp.MustCompile(`^\s*(?:async\s+)?def\s+\w+|^\s*class\s+\w+`),
+	".js":   regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:function\*?|class)\s+\w+|^(?:export\s+)?(?:const|let)\s+\w+\s*=\s*(?:async\s*)?(?:\([^)]*\)|\w+)\s*=>`),
+	".ts":   regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(?:function\*?|class|interface|type|enum)\s+\w+|^(?:export\s+)?(?:const|let)\s+\w+\s*=\s*(?:async\s*)?(?:\([^)]*\)|\w+)\s*=>`),
+	".rs":   regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?(?:fn|struct|enum|trait|impl|mod|type)\b`),
+	".java": regexp.MustCompile(`^\s*(?:(?:public|protected|private|static|final|abstract|sealed)\s+)*(?:class|interface|enum|record)\s+\w+|^\s*(?:public|protected|private)\s+[^;=(]*\w+\s*\(`),
+	".rb":   regexp.MustCompile(`^\s*(?:def|class|module)\s+`),
+}
+
+func init() {
+	outlinePatterns[".jsx"] = outlinePatterns[".js"]
+	outlinePatterns[".mjs"] = outlinePatterns[".js"]
+	outlinePatterns[".tsx"] = outlinePatterns[".ts"]
+	outlinePatterns[".kt"] = outlinePatterns[".java"]
+	outlinePatterns[".cs"] = outlinePatterns[".java"]
+}
+
+// summarizeFile returns a structural outline of an oversized source file:
+// declarations and signatures with line numbers, bodies omitted. ok is false
+// for languages it does not understand, so callers can fall back to prefix
+// truncation.
+func summarizeFile(rel string, raw []byte) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(rel))
+	if ext == ".go" {
+		return summarizeGo(rel, raw)
+	}
+	pattern, ok := outlinePatterns[ext]
+	if !ok {
+		return "", false
+	}
+	lines := []string{}
+	for i, line := range strings.Split(string(raw), "\n") {
+		if !pattern.MatchString(line) {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r{:")
+		lines = append(lines, "L"+strconv.Itoa(i+1)+": "+line)
+	}
+	if len(lines) == 0 {
+		return "", false
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func summarizeGo(rel string, raw []byte) (string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, rel, raw, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", false
+	}
+
+	lines := []string{"package " + file.Name.Name}
+	if len(file.Imports) > 0 {
+		paths := make([]string, 0, len(file.Imports))
+		for _, spec := range file.Imports {
+			paths = append(paths, spec.Path.Value)
+		}
+		lines = append(lines, "import "+strings.Join(paths, ", "))
+	}
+	for _, decl := range file.Decls {
+		line := fset.Position(decl.Pos()).Line
+		switch typed := decl.(type) {
+		case *ast.FuncDecl:
+			lines = append(lines, docLines(typed.Doc)...)
+			typed.Body = nil
+			typed.Doc = nil
+			lines = append(lines, fmt.Sprintf("L%d: %s", line, printNode(fset, typed)))
+		case *ast.GenDecl:
+			if typed.Tok == token.IMPORT {
+				continue
+			}
+			lines = append(lines, docLines(typed.Doc)...)
+			for _, spec := range typed.Specs {
+				line = fset.Position(spec.Pos()).Line
+				switch specTyped := spec.(type) {
+				case *ast.TypeSpec:
+					lines = append(lines, fmt.Sprintf("L%d: type %s", line, printNode(fset, specTyped)))
+				case *ast.ValueSpec:
+					lines = append(lines, fmt.Sprintf("L%d: %s %s", line, typed.Tok, valueSpecSignature(fset, specTyped)))
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// valueSpecSignature renders a const/var spec without its initializer.
+func valueSpecSignature(fset *token.FileSet, spec *ast.ValueSpec) string {
+	names := make([]string, 0, len(spec.Names))
+	for _, name := range spec.Names {
+		names = append(names, name.Name)
+	}
+	out := strings.Join(names, ", ")
+	if spec.Type != nil {
+		out += " " + printNode(fset, spec.Type)
+	}
+	if len(spec.Values) > 0 {
+		out += " = ..."
+	}
+	return out
+}
+
+func docLines(group *ast.CommentGroup) []string {
+	if group == nil {
+		return nil
+	}
+	text := strings.TrimSpace(group.Text())
+	if text == "" {
+		return nil
+	}
+	first, _, _ := strings.Cut(text, "\n")
+	return []string{"// " + first}
+}
+
+func printNode(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return "[unprintable]"
+	}
+	return buf.String()
+}
@@ -0,0 +1,61 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarizeGo(t *testing.T) {
+	src := `package demo
+
+import "fmt"
+
+// Greeter says hello.
+// It has a second doc line.
+type Greeter struct {
+	Name string
+}
+
+const defaultName = "world"
+
+var registry = map[string]Greeter{}
+
+// Greet returns a greeting.
+func (g Greeter) Greet(prefix string) (string, error) {
+	return fmt.Sprintf("%s %s", prefix, g.Name), nil
+}
+`
+	outline, ok := summarizeFile("demo/greeter.go", []byte(src))
+	if !ok {
+		t.Fatalf("expected go outline")
+	}
+	for _, want := range []string{
+		"package demo",
+		`import "fmt"`,
+		"// Greeter says hello.",
+		"L7: type Greeter struct {",
+		"L11: const defaultName = ...",
+		"L13: var registry = ...",
+		"L16: func (g Greeter) Greet(prefix string) (string, error)",
+	} {
+		if !strings.Contains(outline, want) {
+			t.Fatalf("outline missing %q:\n%s", want, outline)
+		}
+	}
+	if strings.Contains(outline, "Sprintf") || strings.Contains(outline, "second doc line") {
+		t.Fatalf("outline should omit bodies and extra doc lines:\n%s", outline)
+	}
+}
+
+func TestSummarizeFallsBack(t *testing.T) {
+	if _, ok := summarizeFile("notes.txt", []byte("plain text")); ok {
+		t.Fatalf("expected no outline for unknown extension")
+	}
+	if _, ok := summarizeFile("broken.go", []byte("package {")); ok {
+		t.Fatalf("expected no outline for unparsable go")
+	}
+	outline, ok := summarizeFile("app.py", []byte("import os\n\nclass App:\n    def run(self):\n        pass\n"))
+	if !ok || !strings.Contains(outline, "L3: class App") || !strings.Contains(outline, "L4:     def run(self)") {
+		t.Fatalf("unexpected python outline: %q", outline)
+	}
+}
@@ -20,6 +20,7 @@ type UIState struct {
 	Objective  string   `json:"objective"`
 	LastScreen string   `json:"last_screen"`
 	LastFiles  []string `json:"last_files"`
+	Bundle     string   `json:"bundle,omitempty"`
 	UpdatedAt  string   `json:"updated_at"`
 }
 
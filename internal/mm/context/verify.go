@@ -0,0 +1,58 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	verifyConfigRelPath  = ".modeloman/verify.json"
+	defaultVerifyTimeout = 10 * time.Minute
+)
+
+// VerifyConfig lists the commands mm runs in the repo root after the backend
+// exits, e.g. "go test ./..." or "npm test". Commit .modeloman/verify.json to
+// share them with the team.
+type VerifyConfig struct {
+	// Commands apply to every task type without its own entry in TaskTypes.
+	Commands []string `json:"commands"`
+	// TaskTypes replaces Commands for the named task types (workflows).
+	TaskTypes map[string][]string `json:"task_types,omitempty"`
+	// TimeoutSeconds bounds each command; 0 means ten minutes.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// LoadVerifyConfig returns an empty config when the repo has no verify.json.
+func LoadVerifyConfig(repoRoot string) (VerifyConfig, error) {
+	var cfg VerifyConfig
+	if err := readJSONState(repoRoot, verifyConfigRelPath, &cfg); err != nil {
+		return VerifyConfig{}, fmt.Errorf("read %s: %w", verifyConfigRelPath, err)
+	}
+	if cfg.TimeoutSeconds < 0 {
+		return VerifyConfig{}, fmt.Errorf("%s: timeout_seconds must not be negative", verifyConfigRelPath)
+	}
+	return cfg, nil
+}
+
+// CommandsFor returns the non-empty commands configured for taskType.
+func (c VerifyConfig) CommandsFor(taskType string) []string {
+	commands := c.Commands
+	if specific, ok := c.TaskTypes[strings.TrimSpace(taskType)]; ok {
+		commands = specific
+	}
+	out := make([]string, 0, len(commands))
+	for _, command := range commands {
+		if strings.TrimSpace(command) != "" {
+			out = append(out, strings.TrimSpace(command))
+		}
+	}
+	return out
+}
+
+func (c VerifyConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds > 0 {
+		return time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	return defaultVerifyTimeout
+}
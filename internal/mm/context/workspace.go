@@ -0,0 +1,113 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/mm/gitutil"
+)
+
+// workspaceEntryPrefix marks a context entry that belongs to a sibling repo
+// from workspace_roots: "@NAME/path/or/glob", or "@NAME" for the whole repo.
+const workspaceEntryPrefix = "@"
+
+// WorkspaceSelection is the part of a bundle drawn from one sibling repo.
+type WorkspaceSelection struct {
+	Name     string           `json:"name"`
+	RepoMeta gitutil.RepoMeta `json:"repo_meta"`
+	Files    []string         `json:"files"`
+}
+
+// Workspaces maps each configured workspace root to its name, the base name
+// of the directory. Two roots with the same base name are rejected since
+// entries could not tell them apart.
+func Workspaces(roots []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, root := range roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("workspace root %q: %w", root, err)
+		}
+		name := filepath.Base(abs)
+		if existing, ok := out[name]; ok && existing != abs {
+			return nil, fmt.Errorf("workspace roots %s and %s share the name %q", existing, abs, name)
+		}
+		out[name] = abs
+	}
+	return out, nil
+}
+
+// ValidateWorkspaceEntries reports the first "@NAME" entry whose repo is not
+// configured.
+func ValidateWorkspaceEntries(entries []string, workspaces map[string]string) error {
+	_, remote := splitWorkspaceEntries(entries)
+	for name := range remote {
+		if _, ok := workspaces[name]; !ok {
+			return fmt.Errorf("unknown workspace repo %q; add its path to workspace_roots in the mm config", name)
+		}
+	}
+	return nil
+}
+
+// splitWorkspaceEntries separates entries for the current repo from those
+// for sibling repos, keyed by workspace name with the prefix removed.
+func splitWorkspaceEntries(entries []string) ([]string, map[string][]string) {
+	local := []string{}
+	remote := map[string][]string{}
+	for _, entry := range entries {
+		trimmed := strings.TrimSpace(entry)
+		if !strings.HasPrefix(trimmed, workspaceEntryPrefix) {
+			local = append(local, entry)
+			continue
+		}
+		name, rest, _ := strings.Cut(strings.TrimPrefix(trimmed, workspaceEntryPrefix), "/")
+		if name == "" {
+			continue
+		}
+		if strings.TrimSpace(rest) == "" {
+			rest = "**"
+		}
+		remote[name] = append(remote[name], rest)
+	}
+	return local, remote
+}
+
+// resolveWorkspaces resolves sibling-repo entries in name order. Skipped files
+// are returned with their "@NAME/" prefix so one report covers every repo.
+func resolveWorkspaces(remote map[string][]string, workspaces map[string]string, rules ExcludeRules) ([]WorkspaceSelection, []SkippedFile, error) {
+	names := make([]string, 0, len(remote))
+	for name := range remote {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	selections := []WorkspaceSelection{}
+	skipped := []SkippedFile{}
+	for _, name := range names {
+		root, ok := workspaces[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown workspace repo %q; add its path to workspace_roots in the mm config", name)
+		}
+		meta, err := gitutil.Metadata(root)
+		if err != nil {
+			// A sibling checkout does not have to be a git repo.
+			meta = gitutil.RepoMeta{Root: root}
+		}
+		files, repoSkipped, err := ResolveEntriesExcluding(root, remote[name], rules)
+		if err != nil {
+			return nil, nil, fmt.Errorf("workspace repo %s: %w", name, err)
+		}
+		for _, item := range repoSkipped {
+			item.Path = workspaceEntryPrefix + name + "/" + item.Path
+			skipped = append(skipped, item)
+		}
+		selections = append(selections, WorkspaceSelection{Name: name, RepoMeta: meta, Files: files})
+	}
+	return selections, skipped, nil
+}
@@ -0,0 +1,70 @@
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bcrosbie/modeloman/internal/mm/gitutil"
+)
+
+func TestWorkspaceEntriesRenderPerRepoSections(t *testing.T) {
+	base := t.TempDir()
+	service := filepath.Join(base, "service")
+	client := filepath.Join(base, "client-lib")
+	write := func(root, rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write(service, "api/handler.go", "package api\n")
+	write(client, "src/client.ts", "export const call = 1;\n")
+	write(client, "src/logo.png", "x")
+	write(client, "README.md", "# client\n")
+
+	workspaces, err := Workspaces([]string{client})
+	if err != nil {
+		t.Fatalf("workspaces: %v", err)
+	}
+	if workspaces["client-lib"] != client {
+		t.Fatalf("unexpected workspaces %v", workspaces)
+	}
+	if _, err := Workspaces([]string{client, filepath.Join(base, "other", "client-lib")}); err == nil {
+		t.Fatalf("expected duplicate workspace names to be rejected")
+	}
+
+	entries := []string{"api/handler.go", "@client-lib/src/*", "@client-lib/README.md"}
+	if err := ValidateWorkspaceEntries(entries, workspaces); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := ValidateWorkspaceEntries([]string{"@missing/x"}, workspaces); err == nil {
+		t.Fatalf("expected unknown workspace to be rejected")
+	}
+
+	local, remote := splitWorkspaceEntries(entries)
+	if strings.Join(local, ",") != "api/handler.go" {
+		t.Fatalf("unexpected local entries %v", local)
+	}
+	selections, skipped, err := resolveWorkspaces(remote, workspaces, DefaultExcludeRules(0, nil))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(selections) != 1 || strings.Join(selections[0].Files, ",") != "README.md,src/client.ts" {
+		t.Fatalf("unexpected selections %+v", selections)
+	}
+	if len(skipped) != 1 || skipped[0].Path != "@client-lib/src/logo.png" {
+		t.Fatalf("unexpected skipped %+v", skipped)
+	}
+
+	rendered := renderBundle(gitutil.RepoMeta{Root: service}, local, nil, skipped, selections, nil, "", "", nil, 1<<20, 64000, service)
+	for _, want := range []string{"### api/handler.go", "## Workspace Repo: client-lib", "root: " + client, "### @client-lib/src/client.ts", "export const call"} {
+		if !strings.Contains(rendered, want) {
+			t.Fatalf("rendered bundle missing %q:\n%s", want, rendered)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package gitutil
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os/exec"
 	"sort"
@@ -59,6 +61,88 @@ func StatusPorcelain(repoRoot string) (string, error) {
 	return out, nil
 }
 
+// ListFiles returns tracked and untracked-but-not-ignored files, as git sees
+// them, relative to repoRoot.
+func ListFiles(repoRoot string) ([]string, error) {
+	out, err := runGit(repoRoot, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %w", err)
+	}
+	seen := map[string]struct{}{}
+	files := []string{}
+	for _, file := range strings.Split(out, "\x00") {
+		if file == "" {
+			continue
+		}
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// WorktreeKey digests HEAD and the porcelain status of everything outside the
+// excluded paths. It changes whenever a commit, checkout, edit, add or delete
+// could change the output of ListFiles.
+func WorktreeKey(repoRoot string, exclude ...string) (string, error) {
+	// A repo without commits has no HEAD yet; the status alone still tracks it.
+	head, _ := runGit(repoRoot, "rev-parse", "--verify", "-q", "HEAD")
+	args := []string{"status", "--porcelain", "-z", "--untracked-files=all", "--", "."}
+	for _, path := range exclude {
+		args = append(args, ":(exclude)"+path)
+	}
+	status, err := runGit(repoRoot, args...)
+	if err != nil {
+		return "", fmt.Errorf("git status: %w", err)
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(head) + "\n" + status))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// BranchChangedFiles lists files that differ from where the current branch
+// forked off the default branch (origin/HEAD, else main or master): committed,
+// staged, unstaged and untracked changes. Without a base it falls back to
+// uncommitted changes against HEAD.
+func BranchChangedFiles(repoRoot string) ([]string, error) {
+	base := "HEAD"
+	candidates := []string{}
+	if upstream, err := runGit(repoRoot, "rev-parse", "--abbrev-ref", "origin/HEAD"); err == nil {
+		candidates = append(candidates, strings.TrimSpace(upstream))
+	}
+	candidates = append(candidates, "main", "master")
+	for _, candidate := range candidates {
+		if mergeBase, err := runGit(repoRoot, "merge-base", "HEAD", candidate); err == nil {
+			base = strings.TrimSpace(mergeBase)
+			break
+		}
+	}
+
+	changed, err := runGit(repoRoot, "diff", "--name-only", "-z", base)
+	if err != nil {
+		return nil, fmt.Errorf("git diff --name-only: %w", err)
+	}
+	untracked, err := runGit(repoRoot, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files --others: %w", err)
+	}
+	seen := map[string]struct{}{}
+	files := []string{}
+	for _, file := range strings.Split(changed+"\x00"+untracked, "\x00") {
+		if file == "" {
+			continue
+		}
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
 func CombinedDiff(repoRoot string, maxBytes int) (string, error) {
 	unstaged, err := runGit(repoRoot, "diff", "--no-color")
 	if err != nil {
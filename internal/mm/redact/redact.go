@@ -2,14 +2,20 @@ package redact
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type Redactor struct {
 	enabled bool
 	rules   []redactionRule
+	secrets []string
 }
 
+// minSecretLength keeps short values such as "1" or "dev" from being scrubbed
+// out of every line of output.
+const minSecretLength = 4
+
 type redactionRule struct {
 	re    *regexp.Regexp
 	label string
@@ -40,7 +46,31 @@ func New(enabled bool, custom []string) *Redactor {
 	}
 }
 
+// AddSecrets registers literal values, such as injected environment
+// variables, that are always removed, even when pattern redaction is off.
+func (r *Redactor) AddSecrets(values ...string) {
+	for _, value := range values {
+		if len(strings.TrimSpace(value)) >= minSecretLength {
+			r.secrets = append(r.secrets, value)
+		}
+	}
+	// Longest first, so a secret containing another is not left half-masked.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// ApplySecrets removes only the registered literal values.
+func (r *Redactor) ApplySecrets(input string) string {
+	if r == nil || input == "" {
+		return input
+	}
+	for _, secret := range r.secrets {
+		input = strings.ReplaceAll(input, secret, "[REDACTED_ENV_VALUE]")
+	}
+	return input
+}
+
 func (r *Redactor) Apply(input string) string {
+	input = r.ApplySecrets(input)
 	if r == nil || !r.enabled || input == "" {
 		return input
 	}
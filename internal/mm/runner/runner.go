@@ -48,6 +48,9 @@ type Options struct {
 	OutputWriter       io.Writer
 	OnOutput           func(string)
 	OnEvent            func(Event)
+	// Env holds extra KEY=VALUE entries added to the inherited environment;
+	// later entries override earlier ones and the parent's.
+	Env []string
 }
 
 func Run(ctx context.Context, opts Options) Result {
@@ -148,6 +151,7 @@ func Run(ctx context.Context, opts Options) Result {
 func runInjected(ctx context.Context, opts Options, transcript io.Writer, stream io.Writer) (int, []Event, error) {
 	cmd := exec.CommandContext(ctx, opts.Backend)
 	cmd.Dir = opts.RepoDir
+	cmd.Env = commandEnv(opts.Env)
 	cmd.Stdout = io.MultiWriter(stream, transcript)
 	cmd.Stderr = io.MultiWriter(stream, transcript)
 
@@ -199,6 +203,7 @@ func runInjected(ctx context.Context, opts Options, transcript io.Writer, stream
 func runAttached(ctx context.Context, opts Options, stream io.Writer) (int, []Event, error) {
 	cmd := exec.CommandContext(ctx, opts.Backend)
 	cmd.Dir = opts.RepoDir
+	cmd.Env = commandEnv(opts.Env)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = stream
 	cmd.Stderr = stream
@@ -217,6 +222,14 @@ func runAttached(ctx context.Context, opts Options, stream io.Writer) (int, []Ev
 	return code, events, err
 }
 
+// commandEnv returns nil, meaning "inherit", when there is nothing to add.
+func commandEnv(extra []string) []string {
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
 func exitCode(cmd *exec.Cmd, err error) int {
 	if cmd != nil && cmd.ProcessState != nil {
 		return cmd.ProcessState.ExitCode()
@@ -16,6 +16,7 @@ import (
 func runWithPTY(ctx context.Context, opts Options, transcript io.Writer, stream io.Writer) (int, []Event, error) {
 	cmd := exec.CommandContext(ctx, opts.Backend)
 	cmd.Dir = opts.RepoDir
+	cmd.Env = commandEnv(opts.Env)
 
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
@@ -2,14 +2,17 @@ package telemetry
 
 import (
 	"context"
-	"crypto/tls"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	mmconfig "github.com/bcrosbie/modeloman/internal/mm/config"
 	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/tlsconfig"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -17,6 +20,7 @@ import (
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -32,6 +36,9 @@ type StartRunInput struct {
 	AgentID       string
 	PromptVersion string
 	ModelPolicy   string
+	Metadata      map[string]string
+	Name          string
+	Description   string
 }
 
 type AttemptInput struct {
@@ -39,12 +46,19 @@ type AttemptInput struct {
 	AttemptNumber int64
 	Workflow      string
 	AgentID       string
+	ProviderType  string
 	Model         string
 	PromptVersion string
 	PromptHash    string
 	Outcome       string
+	ErrorType     string
 	ErrorMessage  string
+	TokensIn      int64
+	TokensOut     int64
+	CostUSD       float64
 	LatencyMS     int64
+	QualityScore  float64
+	Metadata      map[string]string
 }
 
 type EventInput struct {
@@ -61,9 +75,113 @@ type FinishRunInput struct {
 	LastError string
 }
 
+// PolicyStatus is the slice of the hub's orchestration policy mm surfaces.
+// KillSwitch is the effective state, including scheduled maintenance windows.
+type PolicyStatus struct {
+	KillSwitch       bool    `json:"kill_switch"`
+	KillSwitchReason string  `json:"kill_switch_reason"`
+	MaxCostPerRunUSD float64 `json:"max_cost_per_run_usd"`
+	MaxTokensPerRun  int64   `json:"max_tokens_per_run"`
+	UpdatedAt        string  `json:"updated_at"`
+}
+
+// PolicyCap mirrors the hub cap fields mm needs to resolve limits offline.
+type PolicyCap struct {
+	ID               string  `json:"id"`
+	ProviderType     string  `json:"provider_type"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Workflow         string  `json:"workflow"`
+	AgentID          string  `json:"agent_id"`
+	MaxCostPerRunUSD float64 `json:"max_cost_per_run_usd"`
+	MaxTokensPerRun  int64   `json:"max_tokens_per_run"`
+	Priority         int64   `json:"priority"`
+	DryRun           bool    `json:"dry_run"`
+	IsActive         bool    `json:"is_active"`
+	ValidFrom        string  `json:"valid_from"`
+	ValidUntil       string  `json:"valid_until"`
+	Kind             string  `json:"kind"`
+}
+
+// RunRecord is the slice of a hub run mm reports on.
+type RunRecord struct {
+	ID            string  `json:"id"`
+	Workflow      string  `json:"workflow"`
+	AgentID       string  `json:"agent_id"`
+	ModelPolicy   string  `json:"model_policy"`
+	Status        string  `json:"status"`
+	TotalAttempts int64   `json:"total_attempts"`
+	TotalCostUSD  float64 `json:"total_cost_usd"`
+	StartedAt     string  `json:"started_at"`
+}
+
+type RunsInput struct {
+	AgentID      string
+	Labels       map[string]string
+	StartedAfter string
+	Limit        int64
+}
+
+// EventRecord is the slice of a hub run event mm reports on.
+type EventRecord struct {
+	RunID     string `json:"run_id"`
+	EventType string `json:"event_type"`
+	DataJSON  string `json:"data_json"`
+	CreatedAt string `json:"created_at"`
+}
+
+type EventsInput struct {
+	EventType    string
+	CreatedAfter string
+	Limit        int64
+}
+
+type LimitsInput struct {
+	Workflow     string
+	AgentID      string
+	ProviderType string
+	Model        string
+}
+
+type RecommendInput struct {
+	Workflow string
+	AgentID  string
+}
+
+// Recommendation is the hub's pick among the backends mm has recorded
+// attempts for, and the ladder to escalate along, cheapest first.
+type Recommendation struct {
+	Model  string
+	Reason string
+	Source string
+	Ladder []string
+}
+
+// Limits is the per-run budget the hub enforces for this agent and backend.
+// Cached is set when it was resolved from the local policy cache.
+type Limits struct {
+	MaxCostPerRunUSD float64
+	MaxTokensPerRun  int64
+	Source           string
+	DryRun           bool
+	Cached           bool
+}
+
 func New(cfg mmconfig.Config, token string) (*Client, error) {
-	cred := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
-	if cfg.GRPCInsecure || strings.HasPrefix(cfg.GRPCAddr, "127.0.0.1:") || strings.HasPrefix(cfg.GRPCAddr, "localhost:") {
+	tlsOpts := tlsconfig.ClientOptions{
+		CAFile:     cfg.GRPCCAFile,
+		CertFile:   cfg.GRPCClientCert,
+		KeyFile:    cfg.GRPCClientKey,
+		ServerName: cfg.GRPCServerName,
+	}
+	tlsCfg, err := tlsconfig.Client(tlsOpts)
+	if err != nil {
+		return nil, fmt.Errorf("tls config: %w", err)
+	}
+	cred := grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))
+	// Loopback addresses default to plaintext unless TLS files are configured.
+	loopback := strings.HasPrefix(cfg.GRPCAddr, "127.0.0.1:") || strings.HasPrefix(cfg.GRPCAddr, "localhost:")
+	if cfg.GRPCInsecure || (loopback && !tlsOpts.Configured()) {
 		cred = grpc.WithTransportCredentials(insecure.NewCredentials())
 	}
 
@@ -104,6 +222,9 @@ func (c *Client) StartRun(ctx context.Context, input StartRunInput) (string, err
 		"agent_id":       strings.TrimSpace(input.AgentID),
 		"prompt_version": strings.TrimSpace(input.PromptVersion),
 		"model_policy":   strings.TrimSpace(input.ModelPolicy),
+		"metadata":       stringMap(input.Metadata),
+		"name":           strings.TrimSpace(input.Name),
+		"description":    strings.TrimSpace(input.Description),
 	})
 	if err != nil {
 		return "", err
@@ -121,19 +242,20 @@ func (c *Client) RecordPromptAttempt(ctx context.Context, input AttemptInput) er
 		"attempt_number": input.AttemptNumber,
 		"workflow":       strings.TrimSpace(input.Workflow),
 		"agent_id":       strings.TrimSpace(input.AgentID),
-		"provider_type":  "api",
+		"provider_type":  providerType(input.ProviderType),
 		"provider":       "wrapped-cli",
 		"model":          strings.TrimSpace(input.Model),
 		"prompt_version": strings.TrimSpace(input.PromptVersion),
 		"prompt_hash":    strings.TrimSpace(input.PromptHash),
 		"outcome":        strings.TrimSpace(input.Outcome),
-		"error_type":     "",
+		"error_type":     strings.TrimSpace(input.ErrorType),
 		"error_message":  strings.TrimSpace(input.ErrorMessage),
-		"tokens_in":      int64(0),
-		"tokens_out":     int64(0),
-		"cost_usd":       0.0,
+		"tokens_in":      input.TokensIn,
+		"tokens_out":     input.TokensOut,
+		"cost_usd":       input.CostUSD,
 		"latency_ms":     input.LatencyMS,
-		"quality_score":  0.0,
+		"quality_score":  input.QualityScore,
+		"metadata":       stringMap(input.Metadata),
 	})
 	return err
 }
@@ -167,11 +289,169 @@ func (c *Client) FinishRun(ctx context.Context, input FinishRunInput) error {
 	return err
 }
 
+func (c *Client) GetPolicy(ctx context.Context) (PolicyStatus, error) {
+	response, err := c.invokeStruct(ctx, rpccontract.MethodGetPolicy, map[string]any{})
+	if err != nil {
+		return PolicyStatus{}, err
+	}
+	killSwitch, _ := response["kill_switch"].(bool)
+	reason, _ := response["kill_switch_reason"].(string)
+	updatedAt, _ := response["updated_at"].(string)
+	maxCost, _ := response["max_cost_per_run_usd"].(float64)
+	maxTokens, _ := response["max_tokens_per_run"].(float64)
+	if scheduled, _ := response["scheduled_kill_switch"].(bool); scheduled && !killSwitch {
+		killSwitch = true
+		reason, _ = response["scheduled_kill_switch_reason"].(string)
+	}
+	return PolicyStatus{
+		KillSwitch:       killSwitch,
+		KillSwitchReason: strings.TrimSpace(reason),
+		MaxCostPerRunUSD: maxCost,
+		MaxTokensPerRun:  int64(maxTokens),
+		UpdatedAt:        updatedAt,
+	}, nil
+}
+
+func (c *Client) ListPolicyCaps(ctx context.Context) ([]PolicyCap, error) {
+	response, err := c.invokeList(ctx, rpccontract.MethodListPolicyCaps, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	caps := []PolicyCap{}
+	if err := json.Unmarshal(raw, &caps); err != nil {
+		return nil, fmt.Errorf("decode policy caps: %w", err)
+	}
+	return caps, nil
+}
+
+// ListRuns lists the hub's runs matching input, newest first.
+func (c *Client) ListRuns(ctx context.Context, input RunsInput) ([]RunRecord, error) {
+	response, err := c.invokeList(ctx, rpccontract.MethodListRuns, map[string]any{
+		"agent_id":      strings.TrimSpace(input.AgentID),
+		"labels":        stringMap(input.Labels),
+		"started_after": strings.TrimSpace(input.StartedAfter),
+		"limit":         input.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	runs := []RunRecord{}
+	if err := json.Unmarshal(raw, &runs); err != nil {
+		return nil, fmt.Errorf("decode runs: %w", err)
+	}
+	return runs, nil
+}
+
+// ListRunEvents lists the hub's run events matching input, newest first.
+func (c *Client) ListRunEvents(ctx context.Context, input EventsInput) ([]EventRecord, error) {
+	response, err := c.invokeList(ctx, rpccontract.MethodListRunEvents, map[string]any{
+		"event_type":    strings.TrimSpace(input.EventType),
+		"created_after": strings.TrimSpace(input.CreatedAfter),
+		"limit":         input.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	events := []EventRecord{}
+	if err := json.Unmarshal(raw, &events); err != nil {
+		return nil, fmt.Errorf("decode run events: %w", err)
+	}
+	return events, nil
+}
+
+// GetEffectiveLimits asks the hub which cap applies to attempts mm records
+// for the given workflow, agent, and model.
+func (c *Client) GetEffectiveLimits(ctx context.Context, input LimitsInput) (Limits, error) {
+	response, err := c.invokeStruct(ctx, rpccontract.MethodGetEffectiveLimits, map[string]any{
+		"workflow":      strings.TrimSpace(input.Workflow),
+		"agent_id":      strings.TrimSpace(input.AgentID),
+		"provider_type": providerType(input.ProviderType),
+		"provider":      "wrapped-cli",
+		"model":         strings.TrimSpace(input.Model),
+	})
+	if err != nil {
+		return Limits{}, err
+	}
+	maxCost, _ := response["max_cost_per_run_usd"].(float64)
+	maxTokens, _ := response["max_tokens_per_run"].(float64)
+	source, _ := response["source"].(string)
+	dryRun, _ := response["dry_run"].(bool)
+	return Limits{
+		MaxCostPerRunUSD: maxCost,
+		MaxTokensPerRun:  int64(maxTokens),
+		Source:           source,
+		DryRun:           dryRun,
+	}, nil
+}
+
+// RecommendModel asks the hub which backend to use for the workflow, applying
+// any routing cap, from the attempts mm has recorded.
+func (c *Client) RecommendModel(ctx context.Context, input RecommendInput) (Recommendation, error) {
+	response, err := c.invokeStruct(ctx, rpccontract.MethodRecommendModel, map[string]any{
+		"workflow": strings.TrimSpace(input.Workflow),
+		"agent_id": strings.TrimSpace(input.AgentID),
+		"provider": "wrapped-cli",
+	})
+	if err != nil {
+		return Recommendation{}, err
+	}
+	out := Recommendation{}
+	out.Model, _ = response["model"].(string)
+	out.Reason, _ = response["reason"].(string)
+	out.Source, _ = response["source"].(string)
+	ladder, _ := response["ladder"].([]any)
+	for _, raw := range ladder {
+		entry, _ := raw.(map[string]any)
+		if model, _ := entry["model"].(string); model != "" {
+			out.Ladder = append(out.Ladder, model)
+		}
+	}
+	return out, nil
+}
+
+func providerType(value string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return "api"
+}
+
 func (c *Client) invokeStruct(ctx context.Context, method string, payload map[string]any) (map[string]any, error) {
+	response := &structpb.Struct{}
+	if err := c.invoke(ctx, method, payload, response); err != nil {
+		return nil, err
+	}
+	return response.AsMap(), nil
+}
+
+func (c *Client) invokeList(ctx context.Context, method string, payload map[string]any) ([]any, error) {
+	response := &structpb.ListValue{}
+	if err := c.invoke(ctx, method, payload, response); err != nil {
+		return nil, err
+	}
+	return response.AsSlice(), nil
+}
+
+// invoke sends one x-request-id with every retry of a call and names it in
+// the final error, so a failure in the mm logs can be found in the hub's.
+func (c *Client) invoke(ctx context.Context, method string, payload map[string]any, response proto.Message) error {
 	request, err := structpb.NewStruct(payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	requestID := newRequestID()
 
 	attempts := c.retryAttempts
 	if attempts < 1 {
@@ -180,13 +460,13 @@ func (c *Client) invokeStruct(ctx context.Context, method string, payload map[st
 	var lastErr error
 	for attempt := 1; attempt <= attempts; attempt++ {
 		callCtx, cancel := context.WithTimeout(ctx, c.requestTO)
-		callCtx = c.withAuth(callCtx)
+		callCtx = metadata.AppendToOutgoingContext(c.withAuth(callCtx), "x-request-id", requestID, rpccontract.APIVersionHeader, rpccontract.APIVersion)
 
-		response := &structpb.Struct{}
+		proto.Reset(response)
 		invokeErr := c.conn.Invoke(callCtx, method, request, response)
 		cancel()
 		if invokeErr == nil {
-			return response.AsMap(), nil
+			return nil
 		}
 		lastErr = invokeErr
 		if !isRetryable(invokeErr) || attempt == attempts {
@@ -194,7 +474,18 @@ func (c *Client) invokeStruct(ctx context.Context, method string, payload map[st
 		}
 		time.Sleep(time.Duration(attempt) * 250 * time.Millisecond)
 	}
-	return nil, lastErr
+	if strings.Contains(lastErr.Error(), "request_id=") {
+		return lastErr
+	}
+	return fmt.Errorf("%w (request_id=%s)", lastErr, requestID)
+}
+
+func newRequestID() string {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "mm_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "mm_" + hex.EncodeToString(raw)
 }
 
 func (c *Client) withAuth(ctx context.Context) context.Context {
@@ -204,6 +495,13 @@ func (c *Client) withAuth(ctx context.Context) context.Context {
 	return metadata.AppendToOutgoingContext(ctx, "x-modeloman-token", c.token)
 }
 
+// IsAccessDenied reports whether the hub refused the call for auth reasons,
+// which callers treat as "feature unavailable" rather than a transient error.
+func IsAccessDenied(err error) bool {
+	code := status.Code(err)
+	return code == codes.PermissionDenied || code == codes.Unauthenticated
+}
+
 func isRetryable(err error) bool {
 	code := status.Code(err)
 	switch code {
@@ -213,3 +511,12 @@ func isRetryable(err error) bool {
 		return false
 	}
 }
+
+// stringMap converts labels for structpb, which only accepts map[string]any.
+func stringMap(values map[string]string) map[string]any {
+	out := make(map[string]any, len(values))
+	for key, value := range values {
+		out[key] = value
+	}
+	return out
+}
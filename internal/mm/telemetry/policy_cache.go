@@ -0,0 +1,149 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const policyCacheRelPath = ".modeloman/policy_cache.json"
+
+// PolicyCache is the last policy and cap set fetched from the hub, kept so
+// mm can still warn about likely cap violations while the hub is unreachable.
+type PolicyCache struct {
+	Version   int          `json:"version"`
+	Policy    PolicyStatus `json:"policy"`
+	Caps      []PolicyCap  `json:"caps"`
+	FetchedAt string       `json:"fetched_at"`
+}
+
+// LoadPolicyCache reads the repo's policy cache; ok is false when none has
+// been written yet.
+func LoadPolicyCache(repoRoot string) (PolicyCache, bool, error) {
+	path := filepath.Join(repoRoot, policyCacheRelPath)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return PolicyCache{}, false, nil
+		}
+		return PolicyCache{}, false, fmt.Errorf("read policy cache: %w", err)
+	}
+	var cache PolicyCache
+	if err := json.Unmarshal(raw, &cache); err != nil {
+		return PolicyCache{}, false, fmt.Errorf("decode policy cache: %w", err)
+	}
+	if cache.Caps == nil {
+		cache.Caps = []PolicyCap{}
+	}
+	return cache, true, nil
+}
+
+// SavePolicyCache writes cache to the repo's .modeloman directory.
+func SavePolicyCache(repoRoot string, cache PolicyCache) error {
+	cache.Version = 1
+	if cache.Caps == nil {
+		cache.Caps = []PolicyCap{}
+	}
+	path := filepath.Join(repoRoot, policyCacheRelPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("mkdir policy cache dir: %w", err)
+	}
+	raw, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode policy cache: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return fmt.Errorf("write policy cache: %w", err)
+	}
+	return nil
+}
+
+// SyncPolicyCache fetches the current policy and caps and rewrites the
+// repo's cache with them.
+func (c *Client) SyncPolicyCache(ctx context.Context, repoRoot string) (PolicyCache, error) {
+	policy, err := c.GetPolicy(ctx)
+	if err != nil {
+		return PolicyCache{}, err
+	}
+	caps, err := c.ListPolicyCaps(ctx)
+	if err != nil {
+		return PolicyCache{}, err
+	}
+	cache := PolicyCache{
+		Policy:    policy,
+		Caps:      caps,
+		FetchedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err := SavePolicyCache(repoRoot, cache); err != nil {
+		return PolicyCache{}, err
+	}
+	return cache, nil
+}
+
+// Limits resolves per-run limits from the cache the way the hub would for an
+// attempt mm records. It mirrors the hub's cap selection: every non-empty
+// match field must agree, more match fields win, then higher priority.
+func (c PolicyCache) Limits(input LimitsInput, now time.Time) Limits {
+	out := Limits{
+		MaxCostPerRunUSD: c.Policy.MaxCostPerRunUSD,
+		MaxTokensPerRun:  c.Policy.MaxTokensPerRun,
+		Source:           "global-policy",
+		Cached:           true,
+	}
+	var selected PolicyCap
+	found := false
+	bestSpecificity := -1
+	for _, cap := range c.Caps {
+		if cap.Kind == "routing" || !cap.IsActive || !capInEffect(cap, now) {
+			continue
+		}
+		if (cap.ProviderType != "" && cap.ProviderType != providerType(input.ProviderType)) ||
+			(cap.Provider != "" && cap.Provider != "wrapped-cli") ||
+			(cap.Model != "" && cap.Model != input.Model) ||
+			(cap.Workflow != "" && cap.Workflow != input.Workflow) ||
+			(cap.AgentID != "" && cap.AgentID != input.AgentID) {
+			continue
+		}
+		specificity := 0
+		for _, field := range []string{cap.ProviderType, cap.Provider, cap.Model, cap.Workflow, cap.AgentID} {
+			if field != "" {
+				specificity++
+			}
+		}
+		if !found || specificity > bestSpecificity || (specificity == bestSpecificity && cap.Priority > selected.Priority) {
+			selected = cap
+			found = true
+			bestSpecificity = specificity
+		}
+	}
+	if !found {
+		return out
+	}
+	out.Source = "policy-cap:" + selected.ID
+	out.DryRun = selected.DryRun
+	if selected.MaxCostPerRunUSD > 0 {
+		out.MaxCostPerRunUSD = selected.MaxCostPerRunUSD
+	}
+	if selected.MaxTokensPerRun > 0 {
+		out.MaxTokensPerRun = selected.MaxTokensPerRun
+	}
+	return out
+}
+
+func capInEffect(cap PolicyCap, now time.Time) bool {
+	if cap.ValidFrom != "" {
+		if validFrom, err := time.Parse(time.RFC3339, cap.ValidFrom); err == nil && now.Before(validFrom) {
+			return false
+		}
+	}
+	if cap.ValidUntil != "" {
+		if validUntil, err := time.Parse(time.RFC3339, cap.ValidUntil); err == nil && !now.Before(validUntil) {
+			return false
+		}
+	}
+	return true
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/bcrosbie/modeloman/internal/mm/gitutil"
 	"github.com/bcrosbie/modeloman/internal/mm/prompt"
 	"github.com/bcrosbie/modeloman/internal/mm/runner"
+	"github.com/bcrosbie/modeloman/internal/mm/telemetry"
+	"github.com/bcrosbie/modeloman/internal/mm/usage"
 	"github.com/bcrosbie/modeloman/internal/mm/workflow"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -38,6 +40,33 @@ type filesLoadedMsg struct {
 	err   error
 }
 
+// quickFilter narrows the context picker to a common selection before the
+// fuzzy filter applies.
+type quickFilter int
+
+const (
+	quickAll quickFilter = iota
+	quickChanged
+	quickRecent
+	quickSelected
+)
+
+// recentFilesLimit caps the "recently edited" quick filter.
+const recentFilesLimit = 50
+
+var quickFilterLabels = map[quickFilter]string{
+	quickAll:      "all",
+	quickChanged:  "changed on branch",
+	quickRecent:   "recently edited",
+	quickSelected: "selected",
+}
+
+type quickFilterMsg struct {
+	mode  quickFilter
+	files []string
+	err   error
+}
+
 type runOutputMsg string
 type runEventMsg runner.Event
 
@@ -52,6 +81,20 @@ type feedbackSavedMsg struct {
 	err error
 }
 
+type hubPolicyMsg struct {
+	status telemetry.PolicyStatus
+	err    error
+}
+
+type runLimitsMsg struct {
+	limits telemetry.Limits
+	err    error
+}
+
+// budgetWarnFraction is the share of a run budget at which the run screen
+// starts warning.
+const budgetWarnFraction = 0.8
+
 type model struct {
 	cfg        mmconfig.Config
 	repoRoot   string
@@ -68,15 +111,29 @@ type model struct {
 	taskInput      textinput.Model
 	skillInput     textinput.Model
 	budgetInput    textinput.Model
+	nameInput      textinput.Model
 	objectiveInput textarea.Model
 	homeFocus      int
 
+	// recall holds the history entries matching recallQuery while ctrl+r
+	// cycles through them; recallIndex is -1 when no recall is active.
+	recall      []string
+	recallQuery string
+	recallIndex int
+	templates   []mmcontext.ObjectiveTemplate
+	template    int
+
 	filterInput textinput.Model
 	allFiles    []string
 	filtered    []string
 	selected    map[string]struct{}
 	cursor      int
 	filesReady  bool
+	bundleNames []string
+	bundle      string
+	expandDeps  bool
+	quick       quickFilter
+	quickFiles  map[string]struct{}
 
 	previewBundle mmcontext.Bundle
 	previewPrompt string
@@ -94,11 +151,21 @@ type model struct {
 	runEventCh     chan runner.Event
 	runDoneCh      chan runCompleteMsg
 	lastEventLines []string
+	runMeter       *usage.Meter
+	runLimits      telemetry.Limits
+	runTokenBudget int64
+	runBudgetLevel int
 
 	ratingInput textinput.Model
 	notesInput  textarea.Model
 	postFocus   int
 	coach       coachOutput
+
+	hubClient     *telemetry.Client
+	hubPolicy     telemetry.PolicyStatus
+	hubPolicySeen bool
+	hubBanner     string
+	hubBannerWarn bool
 }
 
 type coachOutput struct {
@@ -113,6 +180,8 @@ var (
 	mutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	errStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
 	okStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	bannerStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15")).Background(lipgloss.Color("1")).Padding(0, 1)
+	noticeStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
 )
 
 func Run(cfg mmconfig.Config) error {
@@ -146,6 +215,11 @@ func Run(cfg mmconfig.Config) error {
 	}
 	budgetInput.Prompt = "Budget: "
 
+	nameInput := textinput.New()
+	nameInput.Placeholder = "run name shown on the hub (optional)"
+	nameInput.Prompt = "Name: "
+	nameInput.CharLimit = 200
+
 	objectiveInput := textarea.New()
 	objectiveInput.Placeholder = "Describe objective..."
 	objectiveInput.SetValue(uiState.Objective)
@@ -179,9 +253,13 @@ func Run(cfg mmconfig.Config) error {
 		taskInput:      taskInput,
 		skillInput:     skillInput,
 		budgetInput:    budgetInput,
+		nameInput:      nameInput,
 		objectiveInput: objectiveInput,
+		recallIndex:    -1,
+		template:       -1,
 		filterInput:    filterInput,
 		selected:       map[string]struct{}{},
+		expandDeps:     cfg.ExpandImports,
 		ratingInput:    ratingInput,
 		notesInput:     notesInput,
 		statusLine:     "Tab through fields. Enter for context picker.",
@@ -196,12 +274,30 @@ func Run(cfg mmconfig.Config) error {
 	for _, entry := range ctxStore.Entries {
 		m.selected[entry] = struct{}{}
 	}
+	if manifests, err := mmcontext.ListManifests(repoRoot); err == nil {
+		for _, manifest := range manifests {
+			m.bundleNames = append(m.bundleNames, manifest.Name)
+			if manifest.Name == uiState.Bundle {
+				m.bundle = manifest.Name
+			}
+		}
+	}
 	for _, item := range uiState.LastFiles {
 		m.selected[item] = struct{}{}
 	}
 	m.applyHomeFocus()
 	m.applyPostFocus()
 
+	// Watch hub policy so kill-switch changes show up as a banner instead of
+	// surfacing later as failed telemetry calls.
+	if token := mmconfig.ResolveToken(cfg); strings.TrimSpace(token) != "" {
+		client, err := telemetry.New(cfg, token)
+		if err == nil {
+			m.hubClient = client
+			defer client.Close()
+		}
+	}
+
 	program := tea.NewProgram(m, tea.WithAltScreen())
 	_, err = program.Run()
 	return err
@@ -209,8 +305,9 @@ func Run(cfg mmconfig.Config) error {
 
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
-		loadFilesCmd(m.repoRoot),
+		loadFilesCmd(m.repoRoot, mmcontext.DefaultExcludeRules(m.cfg.MaxFileKB, m.cfg.ExcludePatterns)),
 		tickCmd(),
+		pollHubPolicyCmd(m.hubClient, 0),
 	)
 }
 
@@ -240,10 +337,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.filesReady = true
 		m.allFiles = typed.files
-		m.filtered = applyFilter(typed.files, m.filterInput.Value())
-		if m.cursor >= len(m.filtered) {
-			m.cursor = maxInt(0, len(m.filtered)-1)
+		m.refilter()
+		return m, nil
+	case quickFilterMsg:
+		if typed.err != nil {
+			m.statusLine = "quick filter error: " + typed.err.Error()
+			return m, nil
 		}
+		m.quick = typed.mode
+		m.quickFiles = map[string]struct{}{}
+		for _, file := range typed.files {
+			m.quickFiles[file] = struct{}{}
+		}
+		m.refilter()
+		m.statusLine = fmt.Sprintf("showing %s files (%d)", quickFilterLabels[typed.mode], len(m.filtered))
 		return m, nil
 	case runOutputMsg:
 		if m.runOutput.Len() < m.cfg.MaxTranscriptBytes {
@@ -274,6 +381,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.screen = screenPost
 		if m.runErr != nil {
 			m.statusLine = "run failed: " + m.runErr.Error()
+		} else if typed.result.PolicyAbort != "" {
+			m.statusLine = "run stopped by mm: " + typed.result.PolicyAbort
+		} else if typed.result.PolicyWarning != "" {
+			m.statusLine = "run complete; likely over cap per cached policy: " + typed.result.PolicyWarning
 		} else {
 			m.statusLine = "run complete"
 		}
@@ -282,9 +393,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case tickMsg:
 		if m.runInProgress {
+			m.checkRunBudget()
 			return m, tickCmd()
 		}
 		return m, nil
+	case runLimitsMsg:
+		if typed.err == nil {
+			m.runLimits = typed.limits
+		}
+		return m, nil
 	case feedbackSavedMsg:
 		if typed.err != nil {
 			m.statusLine = "feedback failed: " + typed.err.Error()
@@ -292,6 +409,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.statusLine = "feedback saved"
 		}
 		return m, nil
+	case hubPolicyMsg:
+		if typed.err != nil {
+			if telemetry.IsAccessDenied(typed.err) {
+				// The token cannot read policy; stop polling quietly.
+				return m, nil
+			}
+			m.applyCachedPolicy()
+			return m, pollHubPolicyCmd(m.hubClient, m.cfg.PolicyPollInterval)
+		}
+		m.applyHubPolicy(typed.status)
+		return m, pollHubPolicyCmd(m.hubClient, m.cfg.PolicyPollInterval)
 	}
 
 	switch m.screen {
@@ -324,10 +452,18 @@ func (m model) View() string {
 	case screenPost:
 		body = m.viewPost()
 	}
+	banner := ""
+	if m.hubBanner != "" {
+		if m.hubBannerWarn {
+			banner = bannerStyle.Render(m.hubBanner)
+		} else {
+			banner = noticeStyle.Render(m.hubBanner)
+		}
+	}
 	return lipgloss.JoinVertical(lipgloss.Left,
 		titleStyle.Render("ModeloMan TUI"),
 		mutedStyle.Render("Repo: "+m.repoRoot),
-		"",
+		banner,
 		body,
 		"",
 		mutedStyle.Render(m.statusLine),
@@ -337,13 +473,31 @@ func (m model) View() string {
 func (m model) updateHome(msg tea.Msg) (model, tea.Cmd) {
 	switch typed := msg.(type) {
 	case tea.KeyMsg:
-		switch typed.String() {
+		key := typed.String()
+		if key != "ctrl+r" && m.recallIndex >= 0 {
+			m.recallIndex = -1
+			if key == "esc" {
+				m.objectiveInput.SetValue(m.recallQuery)
+				m.statusLine = "recall cancelled"
+				return m, nil
+			}
+		}
+		if key != "ctrl+t" {
+			m.template = -1
+		}
+		switch key {
+		case "ctrl+r":
+			m.recallObjective()
+			return m, nil
+		case "ctrl+t":
+			m.cycleTemplate()
+			return m, nil
 		case "tab":
-			m.homeFocus = (m.homeFocus + 1) % 4
+			m.homeFocus = (m.homeFocus + 1) % 5
 			m.applyHomeFocus()
 			return m, nil
 		case "shift+tab":
-			m.homeFocus = (m.homeFocus + 3) % 4
+			m.homeFocus = (m.homeFocus + 4) % 5
 			m.applyHomeFocus()
 			return m, nil
 		case "[":
@@ -353,6 +507,12 @@ func (m model) updateHome(msg tea.Msg) (model, tea.Cmd) {
 			m.backend = (m.backend + 1) % len(m.backends)
 			return m, nil
 		case "enter":
+			if pending := mmcontext.Placeholders(m.objectiveInput.Value()); len(pending) > 0 {
+				m.homeFocus = 4
+				m.applyHomeFocus()
+				m.statusLine = "fill template placeholders first: " + strings.Join(pending, ", ")
+				return m, nil
+			}
 			m.persistHomeState()
 			m.screen = screenContext
 			m.statusLine = "Context picker: / filter, space toggle, enter preview"
@@ -369,11 +529,68 @@ func (m model) updateHome(msg tea.Msg) (model, tea.Cmd) {
 	case 2:
 		m.budgetInput, cmd = m.budgetInput.Update(msg)
 	case 3:
+		m.nameInput, cmd = m.nameInput.Update(msg)
+	case 4:
 		m.objectiveInput, cmd = m.objectiveInput.Update(msg)
 	}
 	return m, cmd
 }
 
+// recallObjective steps through past objectives that fuzzy-match what was
+// typed before the first ctrl+r, most recently used first.
+func (m *model) recallObjective() {
+	if m.recallIndex < 0 {
+		entries, err := mmcontext.LoadObjectiveHistory(m.repoRoot)
+		if err != nil {
+			m.statusLine = "objective history: " + err.Error()
+			return
+		}
+		m.recallQuery = m.objectiveInput.Value()
+		texts := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			texts = append(texts, entry.Text)
+		}
+		m.recall = applyFilter(texts, m.recallQuery)
+		if len(m.recall) == 0 {
+			m.statusLine = "no past objectives match"
+			return
+		}
+		m.recallIndex = 0
+	} else {
+		m.recallIndex = (m.recallIndex + 1) % len(m.recall)
+	}
+	m.homeFocus = 4
+	m.applyHomeFocus()
+	m.objectiveInput.SetValue(m.recall[m.recallIndex])
+	m.statusLine = fmt.Sprintf("history %d/%d | ctrl+r: older | esc: restore", m.recallIndex+1, len(m.recall))
+}
+
+// cycleTemplate replaces the objective with the next saved template; its
+// {placeholders} must be edited before leaving the home screen.
+func (m *model) cycleTemplate() {
+	if m.template < 0 {
+		templates, err := mmcontext.ListObjectiveTemplates(m.repoRoot)
+		if err != nil {
+			m.statusLine = "objective templates: " + err.Error()
+			return
+		}
+		if len(templates) == 0 {
+			m.statusLine = "no objective templates saved (mm objective save NAME TEXT)"
+			return
+		}
+		m.templates = templates
+	}
+	m.template = (m.template + 1) % len(m.templates)
+	item := m.templates[m.template]
+	m.homeFocus = 4
+	m.applyHomeFocus()
+	m.objectiveInput.SetValue(item.Text)
+	m.statusLine = "template " + item.Name
+	if pending := mmcontext.Placeholders(item.Text); len(pending) > 0 {
+		m.statusLine += ": fill " + strings.Join(pending, ", ")
+	}
+}
+
 func (m model) updateContext(msg tea.Msg) (model, tea.Cmd) {
 	switch typed := msg.(type) {
 	case tea.KeyMsg:
@@ -406,6 +623,47 @@ func (m model) updateContext(msg tea.Msg) (model, tea.Cmd) {
 			m.persistSelections()
 			m.statusLine = "saved context selection"
 			return m, nil
+		case "ctrl+e":
+			m.expandDeps = !m.expandDeps
+			if m.expandDeps {
+				m.statusLine = "including files imported by the selection"
+			} else {
+				m.statusLine = "imported files excluded"
+			}
+			return m, nil
+		case "ctrl+g", "ctrl+r", "ctrl+t":
+			mode := map[string]quickFilter{"ctrl+g": quickChanged, "ctrl+r": quickRecent, "ctrl+t": quickSelected}[typed.String()]
+			if m.quick == mode {
+				m.quick = quickAll
+				m.quickFiles = nil
+				m.refilter()
+				m.statusLine = "showing all files"
+				return m, nil
+			}
+			switch mode {
+			case quickChanged:
+				return m, branchChangedFilesCmd(m.repoRoot)
+			case quickRecent:
+				return m, recentFilesCmd(m.repoRoot, m.allFiles)
+			}
+			m.quick = quickSelected
+			m.quickFiles = nil
+			m.refilter()
+			m.statusLine = fmt.Sprintf("showing selected files (%d)", len(m.filtered))
+			return m, nil
+		case "ctrl+b":
+			if len(m.bundleNames) == 0 {
+				m.statusLine = "no bundles saved (mm bundle save NAME)"
+				return m, nil
+			}
+			m.bundle = nextBundle(m.bundleNames, m.bundle)
+			m.persistSelections()
+			if m.bundle == "" {
+				m.statusLine = "bundle cleared"
+			} else {
+				m.statusLine = "using bundle " + m.bundle
+			}
+			return m, nil
 		case "enter":
 			m.persistSelections()
 			bundle, preview, err := m.buildPreview()
@@ -422,11 +680,31 @@ func (m model) updateContext(msg tea.Msg) (model, tea.Cmd) {
 
 	var cmd tea.Cmd
 	m.filterInput, cmd = m.filterInput.Update(msg)
-	m.filtered = applyFilter(m.allFiles, m.filterInput.Value())
+	m.refilter()
+	return m, cmd
+}
+
+// refilter applies the quick filter, then the fuzzy filter, to the file list.
+func (m *model) refilter() {
+	var base []string
+	switch m.quick {
+	case quickAll:
+		base = m.allFiles
+	case quickSelected:
+		base = m.selectedEntries()
+	default:
+		// Changed and recent lists can name files the picker does not offer,
+		// such as deletions or excluded files; keep only those it does.
+		for _, file := range m.allFiles {
+			if _, ok := m.quickFiles[file]; ok {
+				base = append(base, file)
+			}
+		}
+	}
+	m.filtered = applyFilter(base, m.filterInput.Value())
 	if m.cursor >= len(m.filtered) {
 		m.cursor = maxInt(0, len(m.filtered)-1)
 	}
-	return m, cmd
 }
 
 func (m model) updatePreview(msg tea.Msg) (model, tea.Cmd) {
@@ -536,10 +814,12 @@ func (m model) viewHome() string {
 		focusPrefix(m.homeFocus == 0) + m.taskInput.View(),
 		focusPrefix(m.homeFocus == 1) + m.skillInput.View(),
 		focusPrefix(m.homeFocus == 2) + m.budgetInput.View(),
-		focusPrefix(m.homeFocus == 3) + "Objective:",
+		focusPrefix(m.homeFocus == 3) + m.nameInput.View(),
+		focusPrefix(m.homeFocus == 4) + "Objective:",
 		m.objectiveInput.View(),
 		"",
 		mutedStyle.Render("Enter: Context Picker | Tab: next field | Ctrl+C: quit"),
+		mutedStyle.Render("Ctrl+R: recall past objective | Ctrl+T: cycle templates"),
 	}
 	return strings.Join(lines, "\n")
 }
@@ -549,6 +829,7 @@ func (m model) viewContext() string {
 		sectionStyle.Render("Context Picker"),
 		m.filterInput.View(),
 		fmt.Sprintf("Files: %d filtered / %d total | Selected: %d", len(m.filtered), len(m.allFiles), len(m.selected)),
+		"Bundle: " + defaultString(m.bundle, "none") + " | Imports: " + onOff(m.expandDeps) + " | Showing: " + quickFilterLabels[m.quick],
 		"",
 	}
 	start := maxInt(0, m.cursor-10)
@@ -565,7 +846,9 @@ func (m model) viewContext() string {
 		}
 		lines = append(lines, fmt.Sprintf("%s %s %s", cursor, mark, item))
 	}
-	lines = append(lines, "", mutedStyle.Render("space: toggle | enter: preview | ctrl+s: save | esc: back"))
+	lines = append(lines, "",
+		mutedStyle.Render("space: toggle | enter: preview | ctrl+s: save | ctrl+b: cycle bundle | ctrl+e: toggle imports | esc: back"),
+		mutedStyle.Render("ctrl+g: changed on branch | ctrl+r: recently edited | ctrl+t: selected (press again for all)"))
 	return strings.Join(lines, "\n")
 }
 
@@ -577,6 +860,9 @@ func (m model) viewPreview() string {
 	lines := []string{
 		sectionStyle.Render("Preview"),
 		fmt.Sprintf("Selected files: %d", len(m.previewBundle.SelectedFiles)),
+		fmt.Sprintf("Imported files: %d", len(m.previewBundle.ImportedFiles)),
+		fmt.Sprintf("Workspace repo files: %s", workspaceSummary(m.previewBundle.WorkspaceRepos)),
+		fmt.Sprintf("Skipped files: %d", len(m.previewBundle.SkippedFiles)),
 		fmt.Sprintf("Context bytes: %d", m.previewBundle.RenderedBytes),
 		fmt.Sprintf("Estimated tokens: %d", m.previewBundle.EstimatedToken),
 		fmt.Sprintf("Context hash: %s", m.previewBundle.Hash),
@@ -597,7 +883,8 @@ func (m model) viewRun() string {
 	}
 	lines := []string{
 		sectionStyle.Render("Run"),
-		fmt.Sprintf("Elapsed: %s | In progress: %v | RunID: %s", elapsed, m.runInProgress, m.runResult.RunID),
+		fmt.Sprintf("Elapsed: %s | In progress: %v | Run: %s", elapsed, m.runInProgress, m.runLabel()),
+		m.viewRunUsage(),
 		mutedStyle.Render("i: toggle passthrough | ctrl+g: exit passthrough | q: cancel"),
 		mutedStyle.Render(fmt.Sprintf("Passthrough: %v", m.runPassthrough)),
 		"",
@@ -617,7 +904,7 @@ func (m model) viewPost() string {
 	}
 	lines := []string{
 		sectionStyle.Render("Post-run"),
-		fmt.Sprintf("RunID: %s", m.runResult.RunID),
+		fmt.Sprintf("Run: %s", m.runLabel()),
 		fmt.Sprintf("Status: %s", status),
 		fmt.Sprintf("Exit code: %d", m.runResult.Runner.ExitCode),
 		fmt.Sprintf("Duration: %s", m.runResult.Runner.Duration.Round(time.Millisecond)),
@@ -630,7 +917,7 @@ func (m model) viewPost() string {
 		sectionStyle.Render("Changed Files"),
 		strings.Join(m.runResult.DiffSummary.ChangedFiles, "\n"),
 		"",
-		sectionStyle.Render("Rating + Notes"),
+		m.viewVerification() + sectionStyle.Render("Rating + Notes"),
 		focusPrefix(m.postFocus == 0) + m.ratingInput.View(),
 		focusPrefix(m.postFocus == 1) + "Notes:",
 		m.notesInput.View(),
@@ -647,6 +934,23 @@ func (m model) viewPost() string {
 	return strings.Join(lines, "\n")
 }
 
+// viewVerification renders the post-run checks as a section ending in a blank
+// line, or "" when none ran.
+func (m model) viewVerification() string {
+	if len(m.runResult.Verification) == 0 {
+		return ""
+	}
+	lines := []string{sectionStyle.Render("Verification") + mutedStyle.Render(fmt.Sprintf("  outcome %s, quality %.2f", m.runResult.Outcome, m.runResult.QualityScore))}
+	for _, item := range m.runResult.Verification {
+		if item.Passed {
+			lines = append(lines, okStyle.Render("PASS ")+item.Summary())
+		} else {
+			lines = append(lines, errStyle.Render("FAIL ")+item.Summary())
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
 func (m model) startRun() (model, tea.Cmd) {
 	m.screen = screenRun
 	m.runOutput.Reset()
@@ -655,7 +959,13 @@ func (m model) startRun() (model, tea.Cmd) {
 	m.runInProgress = true
 	m.runPassthrough = false
 	m.runStartedAt = time.Now().UTC()
+	m.runMeter = usage.NewMeter(int64(len(m.previewPrompt)+m.previewBundle.RenderedBytes)/4, m.cfg.CostPerMillionUSD)
+	m.runLimits = telemetry.Limits{}
+	m.runBudgetLevel = 0
 	m.statusLine = "run started"
+	if m.hubPolicy.KillSwitch {
+		m.statusLine = "run started locally; hub kill switch is on, so telemetry will be rejected"
+	}
 
 	runCtx, cancel := context.WithCancel(context.Background())
 	m.runCtxCancel = cancel
@@ -670,7 +980,10 @@ func (m model) startRun() (model, tea.Cmd) {
 	budget, _ := strconv.Atoi(strings.TrimSpace(m.budgetInput.Value()))
 	objective := strings.TrimSpace(m.objectiveInput.Value())
 	skill := strings.TrimSpace(m.skillInput.Value())
+	name := strings.TrimSpace(m.nameInput.Value())
 	selectedEntries := m.selectedEntries()
+	m.runTokenBudget = int64(budget)
+	meter := m.runMeter
 	m.persistSelections()
 	m.persistHomeState()
 
@@ -680,15 +993,19 @@ func (m model) startRun() (model, tea.Cmd) {
 			TaskType:        taskType,
 			Skill:           skill,
 			Objective:       objective,
+			Name:            name,
 			BudgetTokens:    budget,
 			DryRun:          false,
 			UsePTY:          true,
 			ForwardInput:    true,
 			InputReader:     inputReader,
 			AdditionalEntry: selectedEntries,
+			Bundles:         m.activeBundles(),
+			ExpandImports:   m.expandDeps,
 			RepoRoot:        m.repoRoot,
 			OutputWriter:    io.Discard,
 			OnOutput: func(chunk string) {
+				meter.Observe(chunk)
 				select {
 				case m.runOutputCh <- chunk:
 				default:
@@ -712,13 +1029,125 @@ func (m model) startRun() (model, tea.Cmd) {
 		waitRunEventCmd(m.runEventCh),
 		waitRunDoneCmd(m.runDoneCh),
 		tickCmd(),
+		fetchRunLimitsCmd(m.hubClient, m.repoRoot, telemetry.LimitsInput{
+			Workflow:     taskType,
+			AgentID:      workflow.LocalAgentID(),
+			ProviderType: m.cfg.ProviderTypeFor(backend),
+			Model:        backend,
+		}),
 	)
 }
 
+// viewRunUsage renders the running token/cost estimate against the tightest
+// known budget.
+func (m model) viewRunUsage() string {
+	snapshot := m.runMeter.Snapshot()
+	source := "estimated"
+	if snapshot.Reported {
+		source = "reported"
+	}
+	parts := []string{fmt.Sprintf("Tokens: %s (%s)", formatTokens(snapshot.Tokens()), source)}
+	if maxTokens := m.runTokenLimit(); maxTokens > 0 {
+		parts[0] += " / " + formatTokens(maxTokens)
+	}
+	if snapshot.CostUSD > 0 || m.runLimits.MaxCostPerRunUSD > 0 {
+		cost := fmt.Sprintf("Cost: $%.4f", snapshot.CostUSD)
+		if !snapshot.CostReported && m.cfg.CostPerMillionUSD <= 0 {
+			cost = "Cost: unknown (set cost_per_million_tokens)"
+		}
+		if m.runLimits.MaxCostPerRunUSD > 0 {
+			cost += fmt.Sprintf(" / $%.2f", m.runLimits.MaxCostPerRunUSD)
+		}
+		parts = append(parts, cost)
+	}
+	if m.runLimits.Source != "" {
+		capLabel := "Cap: " + m.runLimits.Source
+		if m.runLimits.Cached {
+			capLabel += " (cached)"
+		}
+		parts = append(parts, capLabel)
+	}
+	line := strings.Join(parts, " | ")
+	switch m.runBudgetLevel {
+	case 2:
+		return errStyle.Render(line)
+	case 1:
+		return noticeStyle.Render(line)
+	default:
+		return mutedStyle.Render(line)
+	}
+}
+
+// runTokenLimit is the smaller of the hub's per-run token cap and the
+// budget entered on the home screen.
+func (m model) runTokenLimit() int64 {
+	limit := m.runLimits.MaxTokensPerRun
+	if m.runTokenBudget > 0 && (limit <= 0 || m.runTokenBudget < limit) {
+		limit = m.runTokenBudget
+	}
+	return limit
+}
+
+// checkRunBudget raises a status warning once when the run nears and again
+// when it passes its budget.
+func (m *model) checkRunBudget() {
+	snapshot := m.runMeter.Snapshot()
+	hubUsed := 0.0
+	if m.runLimits.MaxTokensPerRun > 0 {
+		hubUsed = float64(snapshot.Tokens()) / float64(m.runLimits.MaxTokensPerRun)
+	}
+	if m.runLimits.MaxCostPerRunUSD > 0 && snapshot.CostUSD > 0 {
+		hubUsed = max(hubUsed, snapshot.CostUSD/m.runLimits.MaxCostPerRunUSD)
+	}
+	used := hubUsed
+	if m.runTokenBudget > 0 {
+		used = max(used, float64(snapshot.Tokens())/float64(m.runTokenBudget))
+	}
+
+	level := 0
+	switch {
+	case used >= 1:
+		level = 2
+	case used >= budgetWarnFraction:
+		level = 1
+	}
+	if level <= m.runBudgetLevel {
+		return
+	}
+	m.runBudgetLevel = level
+	if level == 2 {
+		switch {
+		case hubUsed < 1:
+			m.statusLine = "token budget exhausted"
+		case m.runLimits.Cached:
+			m.statusLine = "run budget likely exhausted per cached policy (" + m.runLimits.Source + ")"
+		case m.runLimits.DryRun:
+			m.statusLine = "run budget exhausted; " + m.runLimits.Source + " is dry-run, so the hub will only log it"
+		default:
+			m.statusLine = "run budget exhausted; the hub will reject this run's attempt (" + m.runLimits.Source + ")"
+		}
+		return
+	}
+	m.statusLine = fmt.Sprintf("run budget %.0f%% used", used*100)
+}
+
+// runLabel is the run's name, if it was given one, and its hub run ID.
+func (m model) runLabel() string {
+	name := strings.TrimSpace(m.nameInput.Value())
+	switch {
+	case name == "":
+		return m.runResult.RunID
+	case m.runResult.RunID == "":
+		return name
+	}
+	return name + " (" + m.runResult.RunID + ")"
+}
+
 func (m *model) applyHomeFocus() {
 	m.taskInput.Blur()
 	m.skillInput.Blur()
 	m.budgetInput.Blur()
+	m.nameInput.Blur()
 	m.objectiveInput.Blur()
 	switch m.homeFocus {
 	case 0:
@@ -728,6 +1157,8 @@ func (m *model) applyHomeFocus() {
 	case 2:
 		m.budgetInput.Focus()
 	case 3:
+		m.nameInput.Focus()
+	case 4:
 		m.objectiveInput.Focus()
 	}
 }
@@ -749,6 +1180,7 @@ func (m *model) persistSelections() {
 		Entries: entries,
 	})
 	m.uiState.LastFiles = entries
+	m.uiState.Bundle = m.bundle
 	_ = mmcontext.SaveUIState(m.repoRoot, m.uiState)
 }
 
@@ -771,14 +1203,46 @@ func (m model) selectedEntries() []string {
 	return out
 }
 
+func (m model) activeBundles() []string {
+	if m.bundle == "" {
+		return nil
+	}
+	return []string{m.bundle}
+}
+
+// nextBundle cycles through saved bundle names, with "" (no bundle) between
+// the last and the first.
+func nextBundle(names []string, current string) string {
+	if current == "" {
+		return names[0]
+	}
+	for i, name := range names {
+		if name == current && i+1 < len(names) {
+			return names[i+1]
+		}
+	}
+	return ""
+}
+
 func (m model) buildPreview() (mmcontext.Bundle, string, error) {
 	budget, _ := strconv.Atoi(strings.TrimSpace(m.budgetInput.Value()))
+	bundleEntries, err := mmcontext.ManifestEntries(m.repoRoot, m.activeBundles())
+	if err != nil {
+		return mmcontext.Bundle{}, "", err
+	}
+	workspaces, err := mmcontext.Workspaces(m.cfg.WorkspaceRoots)
+	if err != nil {
+		return mmcontext.Bundle{}, "", err
+	}
 	bundle, err := mmcontext.BuildBundle(mmcontext.BuildOptions{
-		RepoRoot:    m.repoRoot,
-		Entries:     m.selectedEntries(),
-		Prompt:      strings.TrimSpace(m.objectiveInput.Value()),
-		MaxBytes:    m.cfg.MaxContextBytes,
-		TokenBudget: budget,
+		RepoRoot:      m.repoRoot,
+		Workspaces:    workspaces,
+		Entries:       append(bundleEntries, m.selectedEntries()...),
+		ExpandImports: m.expandDeps,
+		Exclude:       mmcontext.DefaultExcludeRules(m.cfg.MaxFileKB, m.cfg.ExcludePatterns),
+		Prompt:        strings.TrimSpace(m.objectiveInput.Value()),
+		MaxBytes:      m.cfg.MaxContextBytes,
+		TokenBudget:   budget,
 	})
 	if err != nil {
 		return mmcontext.Bundle{}, "", err
@@ -795,13 +1259,44 @@ func (m model) buildPreview() (mmcontext.Bundle, string, error) {
 	return bundle, template, nil
 }
 
-func loadFilesCmd(repoRoot string) tea.Cmd {
+func loadFilesCmd(repoRoot string, rules mmcontext.ExcludeRules) tea.Cmd {
 	return func() tea.Msg {
-		files, err := scanRepoFiles(repoRoot)
+		files, err := mmcontext.RepoFiles(repoRoot, rules)
 		return filesLoadedMsg{files: files, err: err}
 	}
 }
 
+func branchChangedFilesCmd(repoRoot string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := gitutil.BranchChangedFiles(repoRoot)
+		return quickFilterMsg{mode: quickChanged, files: files, err: err}
+	}
+}
+
+// recentFilesCmd picks the most recently modified of the picker's files.
+func recentFilesCmd(repoRoot string, files []string) tea.Cmd {
+	return func() tea.Msg {
+		type stamped struct {
+			path    string
+			modTime time.Time
+		}
+		items := make([]stamped, 0, len(files))
+		for _, file := range files {
+			info, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(file)))
+			if err != nil {
+				continue
+			}
+			items = append(items, stamped{path: file, modTime: info.ModTime()})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].modTime.After(items[j].modTime) })
+		recent := make([]string, 0, recentFilesLimit)
+		for i := 0; i < len(items) && i < recentFilesLimit; i++ {
+			recent = append(recent, items[i].path)
+		}
+		return quickFilterMsg{mode: quickRecent, files: recent}
+	}
+}
+
 func waitRunOutputCmd(ch <-chan string) tea.Cmd {
 	if ch == nil {
 		return nil
@@ -847,6 +1342,87 @@ func tickCmd() tea.Cmd {
 	})
 }
 
+// pollHubPolicyCmd fetches hub policy after delay; a nil client disables polling.
+func pollHubPolicyCmd(client *telemetry.Client, delay time.Duration) tea.Cmd {
+	if client == nil {
+		return nil
+	}
+	fetch := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		status, err := client.GetPolicy(ctx)
+		return hubPolicyMsg{status: status, err: err}
+	}
+	if delay <= 0 {
+		return fetch
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return fetch()
+	})
+}
+
+// fetchRunLimitsCmd looks up the cap the hub will apply to this run's attempt,
+// falling back to the local policy cache when the hub cannot be reached.
+func fetchRunLimitsCmd(client *telemetry.Client, repoRoot string, input telemetry.LimitsInput) tea.Cmd {
+	if client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		limits, err := client.GetEffectiveLimits(ctx, input)
+		if err != nil && !telemetry.IsAccessDenied(err) {
+			if cache, ok, cacheErr := telemetry.LoadPolicyCache(repoRoot); cacheErr == nil && ok {
+				return runLimitsMsg{limits: cache.Limits(input, time.Now().UTC())}
+			}
+		}
+		return runLimitsMsg{limits: limits, err: err}
+	}
+}
+
+// applyCachedPolicy keeps the kill-switch banner meaningful while the hub is
+// unreachable by falling back to the last cached policy.
+func (m *model) applyCachedPolicy() {
+	if m.hubPolicySeen {
+		return
+	}
+	cache, ok, err := telemetry.LoadPolicyCache(m.repoRoot)
+	if err != nil || !ok || !cache.Policy.KillSwitch {
+		return
+	}
+	reason := defaultString(cache.Policy.KillSwitchReason, "kill switch is enabled")
+	m.hubBanner = "Hub unreachable; last known policy (" + cache.FetchedAt + ") blocks runs: " + reason
+	m.hubBannerWarn = true
+}
+
+// applyHubPolicy updates the banner when the kill switch flips or the policy
+// changes while the TUI is open.
+func (m *model) applyHubPolicy(status telemetry.PolicyStatus) {
+	previous := m.hubPolicy
+	first := !m.hubPolicySeen
+	m.hubPolicy = status
+	m.hubPolicySeen = true
+
+	switch {
+	case status.KillSwitch:
+		reason := defaultString(status.KillSwitchReason, "kill switch is enabled")
+		m.hubBanner = "Hub is blocking runs: " + reason
+		if m.runInProgress {
+			m.hubBanner += " (telemetry for this run will be rejected)"
+		}
+		m.hubBannerWarn = true
+	case first:
+		m.hubBanner = ""
+		m.hubBannerWarn = false
+	case previous.KillSwitch:
+		m.hubBanner = "Hub kill switch cleared; runs are allowed again."
+		m.hubBannerWarn = false
+	case status.UpdatedAt != previous.UpdatedAt:
+		m.hubBanner = "Hub policy updated at " + status.UpdatedAt
+		m.hubBannerWarn = false
+	}
+}
+
 func saveFeedbackCmd(cfg mmconfig.Config, runID string, rating int, notes string) tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -871,33 +1447,6 @@ func applyFilter(files []string, query string) []string {
 	return out
 }
 
-func scanRepoFiles(repoRoot string) ([]string, error) {
-	files := make([]string, 0, 8192)
-	err := filepath.WalkDir(repoRoot, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			switch d.Name() {
-			case ".git", "node_modules", "dist", "vendor", "bin", ".next", "target", ".idea", ".vscode":
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if !d.Type().IsRegular() {
-			return nil
-		}
-		rel, relErr := filepath.Rel(repoRoot, path)
-		if relErr != nil {
-			return nil
-		}
-		files = append(files, filepath.ToSlash(rel))
-		return nil
-	})
-	sort.Strings(files)
-	return files, err
-}
-
 func fuzzyContains(value, query string) bool {
 	if query == "" {
 		return true
@@ -977,6 +1526,13 @@ func buildCoach(result workflow.RunResult) coachOutput {
 	}
 }
 
+func formatTokens(tokens int64) string {
+	if tokens >= 1000 {
+		return fmt.Sprintf("%.1fk", float64(tokens)/1000)
+	}
+	return strconv.FormatInt(tokens, 10)
+}
+
 func focusPrefix(active bool) string {
 	if active {
 		return "> "
@@ -991,6 +1547,24 @@ func defaultString(value, fallback string) string {
 	return value
 }
 
+func workspaceSummary(repos []mmcontext.WorkspaceSelection) string {
+	if len(repos) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		parts = append(parts, fmt.Sprintf("%s=%d", repo.Name, len(repo.Files)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Backends print usage in different shapes; each pattern captures one number.
+// Reported figures are treated as running totals, so the meter keeps the
+// largest value seen rather than summing.
+var (
+	totalTokensPattern  = regexp.MustCompile(`(?i)(?:tokens used|total[ _]tokens)["']?\s*[:=]?\s*([0-9][0-9,]*)`)
+	inputTokensPattern  = regexp.MustCompile(`(?i)(?:input|prompt)[ _]tokens["']?\s*[:=]\s*([0-9][0-9,]*)`)
+	outputTokensPattern = regexp.MustCompile(`(?i)(?:output|completion)[ _]tokens["']?\s*[:=]\s*([0-9][0-9,]*)`)
+	costPattern         = regexp.MustCompile(`(?i)(?:(?:total[ _]cost(?:_usd)?|cost_usd)["']?\s*[:=]\s*\$?|\bcost\s*:\s*\$)\s*([0-9]+(?:\.[0-9]+)?)`)
+	ansiPattern         = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+)
+
+// maxLineBytes bounds the partial-line buffer for output without newlines.
+const maxLineBytes = 8192
+
+// Snapshot is a point-in-time view of a run's token and cost usage.
+type Snapshot struct {
+	TokensIn  int64
+	TokensOut int64
+	CostUSD   float64
+	// Reported is true when the numbers came from the backend's own usage
+	// output rather than the bytes/4 estimate.
+	Reported     bool
+	CostReported bool
+}
+
+func (s Snapshot) Tokens() int64 {
+	return s.TokensIn + s.TokensOut
+}
+
+// Add sums two snapshots, e.g. the attempts of one run; the result counts
+// as reported if either side was.
+func (s Snapshot) Add(other Snapshot) Snapshot {
+	return Snapshot{
+		TokensIn:     s.TokensIn + other.TokensIn,
+		TokensOut:    s.TokensOut + other.TokensOut,
+		CostUSD:      s.CostUSD + other.CostUSD,
+		Reported:     s.Reported || other.Reported,
+		CostReported: s.CostReported || other.CostReported,
+	}
+}
+
+// Meter accumulates usage from streamed backend output. It is safe for
+// concurrent use.
+type Meter struct {
+	mu sync.Mutex
+
+	promptTokens    int64
+	outputBytes     int64
+	pricePerMillion float64
+	partial         string
+	reported        reportedUsage
+}
+
+type reportedUsage struct {
+	total     int64
+	in        int64
+	out       int64
+	cost      float64
+	sawTokens bool
+	sawCost   bool
+}
+
+// NewMeter starts a meter for a run whose prompt is estimated at
+// promptTokens. pricePerMillion prices estimated tokens; zero leaves cost
+// unknown unless the backend reports it.
+func NewMeter(promptTokens int64, pricePerMillion float64) *Meter {
+	if promptTokens < 0 {
+		promptTokens = 0
+	}
+	return &Meter{promptTokens: promptTokens, pricePerMillion: pricePerMillion}
+}
+
+// Observe feeds a chunk of backend output to the meter.
+func (m *Meter) Observe(chunk string) {
+	if m == nil || chunk == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.outputBytes += int64(len(chunk))
+	text := m.partial + chunk
+	lines := strings.Split(text, "\n")
+	m.partial = lines[len(lines)-1]
+	if len(m.partial) > maxLineBytes {
+		m.partial = m.partial[len(m.partial)-maxLineBytes:]
+	}
+	for _, line := range lines[:len(lines)-1] {
+		m.reported.parseLine(line)
+	}
+}
+
+// Snapshot returns current usage, preferring backend-reported figures.
+func (m *Meter) Snapshot() Snapshot {
+	if m == nil {
+		return Snapshot{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reported := m.reported
+	if m.partial != "" {
+		// Usage lines are often the last thing printed, before any newline.
+		reported.parseLine(m.partial)
+	}
+
+	out := Snapshot{
+		TokensIn:  m.promptTokens,
+		TokensOut: m.outputBytes / 4,
+	}
+	if reported.sawTokens {
+		out.Reported = true
+		out.TokensIn = reported.in
+		out.TokensOut = reported.out
+		if reported.total > out.TokensIn+out.TokensOut {
+			// Only a combined total was printed; attribute the remainder to output.
+			out.TokensOut = reported.total - out.TokensIn
+		}
+	}
+	if reported.sawCost {
+		out.CostReported = true
+		out.CostUSD = reported.cost
+	} else if m.pricePerMillion > 0 {
+		out.CostUSD = float64(out.Tokens()) * m.pricePerMillion / 1_000_000
+	}
+	return out
+}
+
+func (r *reportedUsage) parseLine(line string) {
+	line = ansiPattern.ReplaceAllString(line, "")
+	if value, ok := matchInt(totalTokensPattern, line); ok && value > r.total {
+		r.total = value
+		r.sawTokens = true
+	}
+	if value, ok := matchInt(inputTokensPattern, line); ok && value > r.in {
+		r.in = value
+		r.sawTokens = true
+	}
+	if value, ok := matchInt(outputTokensPattern, line); ok && value > r.out {
+		r.out = value
+		r.sawTokens = true
+	}
+	if match := costPattern.FindStringSubmatch(line); match != nil {
+		if value, err := strconv.ParseFloat(match[1], 64); err == nil && value > r.cost {
+			r.cost = value
+			r.sawCost = true
+		}
+	}
+}
+
+func matchInt(pattern *regexp.Regexp, line string) (int64, bool) {
+	match := pattern.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.ReplaceAll(match[1], ",", ""), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
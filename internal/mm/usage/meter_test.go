@@ -0,0 +1,42 @@
+package usage
+
+import "testing"
+
+func TestMeterEstimatesFromOutputBytes(t *testing.T) {
+	meter := NewMeter(100, 2.0)
+	meter.Observe("0123456789abcdef0123456789abcdef0123456789")
+
+	snapshot := meter.Snapshot()
+	if snapshot.Reported {
+		t.Fatalf("expected estimate, got reported usage")
+	}
+	if snapshot.TokensIn != 100 || snapshot.TokensOut != 10 {
+		t.Fatalf("unexpected tokens: in=%d out=%d", snapshot.TokensIn, snapshot.TokensOut)
+	}
+	if want := 110 * 2.0 / 1_000_000; snapshot.CostUSD != want {
+		t.Fatalf("expected cost %v, got %v", want, snapshot.CostUSD)
+	}
+}
+
+func TestMeterPrefersReportedUsage(t *testing.T) {
+	meter := NewMeter(100, 2.0)
+	meter.Observe("working...\n{\"input_tokens\": 1,200, \"output")
+	meter.Observe("_tokens\": 300}\n")
+	meter.Observe("\x1b[2mTotal cost: $0.0420\x1b[0m")
+
+	snapshot := meter.Snapshot()
+	if !snapshot.Reported || !snapshot.CostReported {
+		t.Fatalf("expected reported usage, got %+v", snapshot)
+	}
+	if snapshot.TokensIn != 1200 || snapshot.TokensOut != 300 {
+		t.Fatalf("unexpected tokens: in=%d out=%d", snapshot.TokensIn, snapshot.TokensOut)
+	}
+	if snapshot.CostUSD != 0.042 {
+		t.Fatalf("expected cost 0.042, got %v", snapshot.CostUSD)
+	}
+
+	meter.Observe("\ntokens used: 2,000\n")
+	if got := meter.Snapshot(); got.Tokens() != 2000 {
+		t.Fatalf("expected combined total 2000, got %d", got.Tokens())
+	}
+}
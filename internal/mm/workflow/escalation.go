@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	mmconfig "github.com/bcrosbie/modeloman/internal/mm/config"
+	"github.com/bcrosbie/modeloman/internal/mm/telemetry"
+	"github.com/bcrosbie/modeloman/internal/mm/usage"
+)
+
+type EscalationResult struct {
+	RunID string
+	// Steps are keyed by backend, starting with params.Backend.
+	Steps []StageResult
+}
+
+// Outcome is the outcome of the last step that ran.
+func (r EscalationResult) Outcome() string {
+	if len(r.Steps) == 0 {
+		return ""
+	}
+	return r.Steps[len(r.Steps)-1].Result.Outcome
+}
+
+// RunEscalating runs params.Backend and, while attempts fail, each stronger
+// backend from the config's escalation ladder in turn, as attempts of one hub
+// run. Each step sees the failure and the tail of the previous step's output,
+// and starts from the working tree it left. Usage accumulates across steps,
+// so the hub's per-run caps apply to the whole ladder; a step stopped on a cap
+// ends the escalation.
+func RunEscalating(ctx context.Context, cfg mmconfig.Config, params RunParams) (EscalationResult, error) {
+	first := strings.TrimSpace(params.Backend)
+	if first == "" {
+		first = strings.TrimSpace(cfg.DefaultBackend)
+	}
+	ladder := append([]string{first}, cfg.EscalationFrom(first)...)
+	out := EscalationResult{}
+	spent := usage.Snapshot{}
+	for i, backend := range ladder {
+		stepParams := params
+		stepParams.Backend = backend
+		stage := &stageContext{
+			number:     i + 1,
+			final:      i == len(ladder)-1,
+			runID:      out.RunID,
+			metadata:   map[string]string{"escalation_step": strconv.Itoa(i + 1)},
+			escalation: true,
+			spent:      spent,
+		}
+		if i > 0 {
+			prior := out.Steps[i-1]
+			stage.metadata["escalated_from"] = prior.Stage
+			stage.eventType = "mm_escalation"
+			stage.eventMessage = fmt.Sprintf("mm escalated from %s to %s", prior.Stage, backend)
+			stage.instructions = fmt.Sprintf(
+				"An attempt with %s failed (%s). Its changes, if any, are still in the working tree: continue from them and fix what it got wrong.",
+				prior.Stage, escalationReason(prior.Result),
+			)
+			stage.priorLabel = "failed attempt (" + prior.Stage + ")"
+			stage.priorOutput = stageOutput(prior.Result)
+		}
+		stepParams.stage = stage
+		result, err := Run(ctx, cfg, stepParams)
+		if err != nil {
+			abandonRun(cfg, out.RunID, fmt.Sprintf("escalation to %s: %v", backend, err))
+			return out, fmt.Errorf("escalation to %s: %w", backend, err)
+		}
+		if out.RunID == "" {
+			out.RunID = result.RunID
+		}
+		out.Steps = append(out.Steps, StageResult{Stage: backend, Result: result})
+		spent = spent.Add(result.Usage)
+		if stage.finishesRun(result.Outcome, result.PolicyAbort) {
+			break
+		}
+		if ctx.Err() != nil {
+			abandonRun(cfg, out.RunID, "escalation cancelled after "+backend)
+			break
+		}
+	}
+	return out, nil
+}
+
+// HubEscalationLadder returns the escalation ladder the hub recommends for
+// taskType (see RecommendModel), following any routing cap, when the config
+// sets escalation_from_hub. It falls back to the configured ladder when the
+// option is off, the hub is unreachable, or it has no history yet.
+func HubEscalationLadder(ctx context.Context, cfg mmconfig.Config, taskType string) []string {
+	token := mmconfig.ResolveToken(cfg)
+	if !cfg.EscalationFromHub || strings.TrimSpace(token) == "" {
+		return cfg.EscalationLadder
+	}
+	client, err := telemetry.New(cfg, token)
+	if err != nil {
+		slog.Warn("hub escalation ladder unavailable", "err", err)
+		return cfg.EscalationLadder
+	}
+	defer client.Close()
+
+	recommendCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	recommendation, err := client.RecommendModel(recommendCtx, telemetry.RecommendInput{
+		Workflow: taskType,
+		AgentID:  LocalAgentID(),
+	})
+	if err != nil {
+		slog.Warn("hub escalation ladder unavailable", "err", err)
+		return cfg.EscalationLadder
+	}
+	if len(recommendation.Ladder) == 0 {
+		return cfg.EscalationLadder
+	}
+	return recommendation.Ladder
+}
+
+func escalationReason(result RunResult) string {
+	if strings.TrimSpace(result.LastError) != "" {
+		return result.LastError
+	}
+	return "outcome " + result.Outcome
+}
@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	mmconfig "github.com/bcrosbie/modeloman/internal/mm/config"
+	mmcontext "github.com/bcrosbie/modeloman/internal/mm/context"
+	"github.com/bcrosbie/modeloman/internal/mm/redact"
+	"github.com/bcrosbie/modeloman/internal/mm/telemetry"
+	"github.com/bcrosbie/modeloman/internal/mm/usage"
+)
+
+// maxStageOutputBytes bounds how much of a stage's transcript is handed to
+// the next stage; the tail holds the backend's conclusion.
+const maxStageOutputBytes = 16 * 1024
+
+var stageANSIPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+type StageResult struct {
+	Stage  string
+	Result RunResult
+}
+
+type PipelineResult struct {
+	Pipeline string
+	RunID    string
+	Stages   []StageResult
+}
+
+// Outcome is the outcome of the last stage that ran; a pipeline stops at
+// the first stage that does not succeed.
+func (r PipelineResult) Outcome() string {
+	if len(r.Stages) == 0 {
+		return ""
+	}
+	return r.Stages[len(r.Stages)-1].Result.Outcome
+}
+
+// stageContext places one Run inside a multi-attempt run (a pipeline stage
+// or an escalation step): it joins the hub run started by the first attempt,
+// records its attempt under its number and counts earlier usage toward the
+// per-run caps.
+type stageContext struct {
+	number int
+	final  bool
+	runID  string
+	// metadata labels the attempt and the started event.
+	metadata     map[string]string
+	eventType    string
+	eventMessage string
+	instructions string
+	priorLabel   string
+	priorOutput  string
+	// escalation keeps the run going after a failure instead of after a
+	// success.
+	escalation bool
+	spent      usage.Snapshot
+}
+
+// RunPipeline runs the pipeline's stages in order with params as the base,
+// each stage overriding backend and skill when it sets them. Every stage is
+// an attempt of the same hub run, labelled with its stage; the run is
+// finished by the last stage or by the first one that does not succeed.
+func RunPipeline(ctx context.Context, cfg mmconfig.Config, pipeline mmcontext.Pipeline, params RunParams) (PipelineResult, error) {
+	out := PipelineResult{Pipeline: pipeline.Name}
+	prior := StageResult{}
+	spent := usage.Snapshot{}
+	for i, stage := range pipeline.Stages {
+		stageParams := params
+		if strings.TrimSpace(stage.Backend) != "" {
+			stageParams.Backend = stage.Backend
+		}
+		if strings.TrimSpace(stage.Skill) != "" {
+			stageParams.Skill = stage.Skill
+		}
+		if !pipeline.VerifiesAt(i) {
+			stageParams.NoVerify = true
+		}
+		instructions := fmt.Sprintf("Pipeline %s, stage %d: %s.", pipeline.Name, i+1, stage.Name)
+		if text := strings.TrimSpace(stage.Instructions); text != "" {
+			instructions += "\n\n" + text
+		}
+		stageParams.stage = &stageContext{
+			number:       i + 1,
+			final:        i == len(pipeline.Stages)-1,
+			runID:        out.RunID,
+			metadata:     map[string]string{"pipeline": pipeline.Name, "stage": stage.Name},
+			eventType:    "mm_stage_started",
+			eventMessage: "mm pipeline started stage " + stage.Name,
+			instructions: instructions,
+			priorLabel:   "previous stage (" + prior.Stage + ")",
+			priorOutput:  stageOutput(prior.Result),
+			spent:        spent,
+		}
+		result, err := Run(ctx, cfg, stageParams)
+		if err != nil {
+			abandonRun(cfg, out.RunID, fmt.Sprintf("pipeline %s stage %s: %v", pipeline.Name, stage.Name, err))
+			return out, fmt.Errorf("stage %s: %w", stage.Name, err)
+		}
+		if out.RunID == "" {
+			out.RunID = result.RunID
+		}
+		prior = StageResult{Stage: stage.Name, Result: result}
+		out.Stages = append(out.Stages, prior)
+		spent = spent.Add(result.Usage)
+		if stageParams.stage.finishesRun(result.Outcome, result.PolicyAbort) {
+			break
+		}
+	}
+	return out, nil
+}
+
+// abandonRun fails a hub run left open when a stage could not start.
+func abandonRun(cfg mmconfig.Config, runID, reason string) {
+	token := mmconfig.ResolveToken(cfg)
+	if strings.TrimSpace(runID) == "" || strings.TrimSpace(token) == "" {
+		return
+	}
+	client, err := telemetry.New(cfg, token)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	_ = client.FinishRun(context.Background(), telemetry.FinishRunInput{
+		RunID:     runID,
+		Status:    "failed",
+		LastError: redact.New(cfg.RedactionEnabled, cfg.CustomRedactRegexes).Apply(reason),
+	})
+}
+
+func stageOutput(result RunResult) string {
+	output := strings.TrimSpace(stageANSIPattern.ReplaceAllString(result.Runner.Transcript, ""))
+	if len(output) > maxStageOutputBytes {
+		output = output[len(output)-maxStageOutputBytes:]
+	}
+	return output
+}
+
+// The methods below are nil-safe so Run can call them for plain runs.
+
+func (s *stageContext) hubRunID() string {
+	if s == nil {
+		return ""
+	}
+	return s.runID
+}
+
+func (s *stageContext) attemptNumber() int64 {
+	if s == nil {
+		return 1
+	}
+	return int64(s.number)
+}
+
+func (s *stageContext) attemptMetadata() map[string]string {
+	if s == nil {
+		return nil
+	}
+	return s.metadata
+}
+
+func (s *stageContext) priorUsage() usage.Snapshot {
+	if s == nil {
+		return usage.Snapshot{}
+	}
+	return s.spent
+}
+
+func (s *stageContext) eventData(data map[string]any) map[string]any {
+	if s != nil {
+		for key, value := range s.metadata {
+			data[key] = value
+		}
+		data["attempt_number"] = s.number
+	}
+	return data
+}
+
+// finishesRun reports whether this attempt is the run's last: pipelines
+// stop at the first stage that does not succeed, escalation at the first
+// step that does or that hit a policy cap.
+func (s *stageContext) finishesRun(outcome, policyAbort string) bool {
+	switch {
+	case s == nil || s.final:
+		return true
+	case s.escalation:
+		return outcome == "success" || policyAbort != ""
+	default:
+		return outcome != "success"
+	}
+}
+
+// hint is the attempt's addition to the prompt: its instructions and the
+// tail of the previous attempt's output, redacted.
+func (s *stageContext) hint(redactor *redact.Redactor) string {
+	parts := []string{s.instructions}
+	if s.priorOutput != "" {
+		parts = append(parts, "Output of the "+s.priorLabel+":\n"+s.priorOutput)
+	}
+	return redactor.Apply(strings.Join(parts, "\n\n"))
+}
@@ -0,0 +1,142 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mmconfig "github.com/bcrosbie/modeloman/internal/mm/config"
+	"github.com/bcrosbie/modeloman/internal/mm/telemetry"
+)
+
+// RepoLabel is the run label mm tags hub runs with, so they can be filtered
+// by the repository they ran in.
+const RepoLabel = "repo"
+
+// statsLimit bounds the runs and feedback events CollectStats reads,
+// matching the hub's default LIST_MAX_LIMIT.
+const statsLimit = 1000
+
+// RepoLabelValue is the RepoLabel value for a repository root: its
+// directory name. Runs are also filtered by LocalAgentID, which already pins
+// the machine and user.
+func RepoLabelValue(repoRoot string) string {
+	return filepath.Base(filepath.Clean(repoRoot))
+}
+
+type StatsParams struct {
+	// RepoRoot limits the stats to runs started in that repository; empty
+	// covers every repository.
+	RepoRoot string
+	Since    time.Duration
+}
+
+// Stats is a personal scorecard over the runs mm started for this agent.
+type Stats struct {
+	AgentID  string `json:"agent_id"`
+	Repo     string `json:"repo,omitempty"`
+	Since    string `json:"since"`
+	Runs     int    `json:"runs"`
+	Running  int    `json:"running"`
+	Finished int    `json:"finished"`
+	// Succeeded counts finished runs that completed.
+	Succeeded   int     `json:"succeeded"`
+	SuccessRate float64 `json:"success_rate"`
+	TotalCost   float64 `json:"total_cost_usd"`
+	AverageCost float64 `json:"average_cost_usd"`
+	TopBackend  string  `json:"top_backend,omitempty"`
+	TopRuns     int     `json:"top_backend_runs"`
+	Ratings     int     `json:"ratings"`
+	RatingAvg   float64 `json:"rating_average"`
+	// Truncated is set when the hub returned the newest statsLimit runs
+	// only.
+	Truncated bool `json:"truncated"`
+}
+
+// CollectStats reads the hub's runs for LocalAgentID, and the post-run
+// ratings given for them, since params.Since ago. Runs started before the
+// repo label was recorded only show up without a RepoRoot.
+func CollectStats(ctx context.Context, cfg mmconfig.Config, params StatsParams) (Stats, error) {
+	token := mmconfig.ResolveToken(cfg)
+	if strings.TrimSpace(token) == "" {
+		return Stats{}, fmt.Errorf("no hub token configured; stats are read from the hub")
+	}
+	client, err := telemetry.New(cfg, token)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer client.Close()
+
+	since := time.Now().UTC().Add(-params.Since).Format(time.RFC3339Nano)
+	stats := Stats{AgentID: LocalAgentID(), Since: since}
+	input := telemetry.RunsInput{AgentID: stats.AgentID, StartedAfter: since, Limit: statsLimit}
+	if strings.TrimSpace(params.RepoRoot) != "" {
+		stats.Repo = RepoLabelValue(params.RepoRoot)
+		input.Labels = map[string]string{RepoLabel: stats.Repo}
+	}
+	runs, err := client.ListRuns(ctx, input)
+	if err != nil {
+		return Stats{}, fmt.Errorf("list runs: %w", err)
+	}
+	feedback, err := client.ListRunEvents(ctx, telemetry.EventsInput{EventType: "mm_feedback", CreatedAfter: since, Limit: statsLimit})
+	if err != nil {
+		return Stats{}, fmt.Errorf("list feedback: %w", err)
+	}
+	summarizeStats(&stats, runs, feedback)
+	stats.Truncated = len(runs) >= statsLimit
+	return stats, nil
+}
+
+func summarizeStats(stats *Stats, runs []telemetry.RunRecord, feedback []telemetry.EventRecord) {
+	backends := map[string]int{}
+	ids := make(map[string]struct{}, len(runs))
+	for _, run := range runs {
+		ids[run.ID] = struct{}{}
+		stats.Runs++
+		stats.TotalCost += run.TotalCostUSD
+		switch run.Status {
+		case "running":
+			stats.Running++
+		case "completed":
+			stats.Finished++
+			stats.Succeeded++
+		default:
+			stats.Finished++
+		}
+		if backend := strings.TrimSpace(run.ModelPolicy); backend != "" {
+			backends[backend]++
+		}
+	}
+	if stats.Finished > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(stats.Finished)
+	}
+	if stats.Runs > 0 {
+		stats.AverageCost = stats.TotalCost / float64(stats.Runs)
+	}
+	for backend, count := range backends {
+		if count > stats.TopRuns || (count == stats.TopRuns && backend < stats.TopBackend) {
+			stats.TopBackend, stats.TopRuns = backend, count
+		}
+	}
+
+	total := 0.0
+	for _, event := range feedback {
+		if _, ok := ids[event.RunID]; !ok {
+			continue
+		}
+		var data struct {
+			Rating float64 `json:"rating"`
+		}
+		if json.Unmarshal([]byte(event.DataJSON), &data) != nil || data.Rating <= 0 {
+			continue
+		}
+		stats.Ratings++
+		total += data.Rating
+	}
+	if stats.Ratings > 0 {
+		stats.RatingAvg = total / float64(stats.Ratings)
+	}
+}
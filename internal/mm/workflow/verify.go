@@ -0,0 +1,181 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// maxVerifyOutputBytes keeps the tail of each command's output, where test
+// runners print their failures and summary.
+const maxVerifyOutputBytes = 32 * 1024
+
+// VerifyResult is the outcome of one post-run verification command.
+type VerifyResult struct {
+	Command         string
+	ExitCode        int
+	Passed          bool
+	TimedOut        bool
+	Duration        time.Duration
+	Output          string
+	OutputTruncated bool
+}
+
+// runVerification runs each command through the shell in repoRoot, in order,
+// and runs all of them even after a failure so every result is reported.
+func runVerification(ctx context.Context, repoRoot string, commands []string, timeout time.Duration) []VerifyResult {
+	results := make([]VerifyResult, 0, len(commands))
+	for _, command := range commands {
+		results = append(results, runVerifyCommand(ctx, repoRoot, command, timeout))
+	}
+	return results
+}
+
+func runVerifyCommand(ctx context.Context, repoRoot, command string, timeout time.Duration) VerifyResult {
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(cmdCtx, shell, flag, command)
+	cmd.Dir = repoRoot
+	started := time.Now()
+	output, err := cmd.CombinedOutput()
+	result := VerifyResult{
+		Command:  command,
+		ExitCode: 0,
+		Duration: time.Since(started),
+	}
+	if err != nil {
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			output = append(output, []byte("\n"+err.Error())...)
+		}
+	}
+	result.TimedOut = errors.Is(cmdCtx.Err(), context.DeadlineExceeded)
+	result.Passed = err == nil
+	if len(output) > maxVerifyOutputBytes {
+		output = output[len(output)-maxVerifyOutputBytes:]
+		result.OutputTruncated = true
+	}
+	result.Output = string(output)
+	return result
+}
+
+// Summary is a one-line description such as "go test ./... failed (exit 1) in 4.2s".
+func (result VerifyResult) Summary() string {
+	state := "passed"
+	switch {
+	case result.TimedOut:
+		state = "timed out"
+	case !result.Passed:
+		state = fmt.Sprintf("failed (exit %d)", result.ExitCode)
+	}
+	return fmt.Sprintf("%s %s in %s", result.Command, state, result.Duration.Round(time.Millisecond))
+}
+
+var (
+	goPackageOK   = regexp.MustCompile(`(?m)^ok\s+\S+`)
+	goPackageFail = regexp.MustCompile(`(?m)^FAIL\s+\S+`)
+	// Summary lines from pytest ("3 failed, 10 passed in 1.2s") and jest
+	// ("Tests: 1 failed, 5 passed, 6 total").
+	summaryPassed = regexp.MustCompile(`(\d+) passed`)
+	summaryFailed = regexp.MustCompile(`(\d+) failed`)
+)
+
+// verificationVerdict is the attempt outcome derived from verification.
+type verificationVerdict struct {
+	Outcome      string
+	ErrorType    string
+	Message      string
+	QualityScore float64
+}
+
+// classifyVerification turns verification results into an attempt outcome:
+// failed when any check fails, tool_error when checks could not run at all
+// (missing command, not executable), success otherwise. QualityScore is the
+// mean share of passing tests per runnable command, using test counts from
+// go test, pytest or jest output when present and pass/fail otherwise.
+func classifyVerification(results []VerifyResult) verificationVerdict {
+	verdict := verificationVerdict{Outcome: "success"}
+	scored := 0
+	total := 0.0
+	for _, result := range results {
+		if isToolError(result) {
+			if verdict.Outcome == "success" {
+				verdict.Outcome = "tool_error"
+				verdict.ErrorType = "verification_tool_error"
+				verdict.Message = "verification could not run: " + result.Summary()
+			}
+			continue
+		}
+		scored++
+		total += commandScore(result)
+		if !result.Passed && verdict.Outcome != "failed" {
+			verdict.Outcome = "failed"
+			verdict.ErrorType = "verification_failed"
+			verdict.Message = "verification failed: " + result.Summary()
+		}
+	}
+	if scored > 0 {
+		verdict.QualityScore = total / float64(scored)
+	}
+	return verdict
+}
+
+// isToolError reports a command the shell could not start: 126 is "not
+// executable", 127 "not found", 9009 is cmd.exe's "not recognized", and -1
+// means the shell itself failed.
+func isToolError(result VerifyResult) bool {
+	if result.Passed || result.TimedOut {
+		return false
+	}
+	switch result.ExitCode {
+	case -1, 126, 127, 9009:
+		return true
+	}
+	return false
+}
+
+func commandScore(result VerifyResult) float64 {
+	passed, failed, ok := testCounts(result.Output)
+	if ok && passed+failed > 0 {
+		return float64(passed) / float64(passed+failed)
+	}
+	if result.Passed {
+		return 1
+	}
+	return 0
+}
+
+// testCounts reads passed/failed counts from test runner output: go test
+// package lines first, then the last pytest/jest style summary.
+func testCounts(output string) (passed, failed int, ok bool) {
+	passed = len(goPackageOK.FindAllString(output, -1))
+	failed = len(goPackageFail.FindAllString(output, -1))
+	if passed+failed > 0 {
+		return passed, failed, true
+	}
+	passed, passedOK := lastCount(summaryPassed, output)
+	failed, failedOK := lastCount(summaryFailed, output)
+	return passed, failed, passedOK || failedOK
+}
+
+func lastCount(pattern *regexp.Regexp, output string) (int, bool) {
+	matches := pattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	count, err := strconv.Atoi(matches[len(matches)-1][1])
+	return count, err == nil
+}
@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClassifyVerification(t *testing.T) {
+	goOutput := "ok  \tgithub.com/x/a\t0.1s\nFAIL\tgithub.com/x/b\t0.2s\nok  \tgithub.com/x/c\t0.1s\nFAIL\n"
+	cases := []struct {
+		name      string
+		results   []VerifyResult
+		outcome   string
+		errorType string
+		quality   float64
+	}{
+		{
+			name:    "all pass",
+			results: []VerifyResult{{Command: "go vet ./...", Passed: true}, {Command: "npm test", Passed: true, Output: "Tests: 4 passed, 4 total"}},
+			outcome: "success",
+			quality: 1,
+		},
+		{
+			name:      "go test package counts",
+			results:   []VerifyResult{{Command: "go test ./...", ExitCode: 1, Output: goOutput}},
+			outcome:   "failed",
+			errorType: "verification_failed",
+			quality:   2.0 / 3.0,
+		},
+		{
+			name:      "pytest summary",
+			results:   []VerifyResult{{Command: "pytest", ExitCode: 1, Output: "==== 1 failed, 3 passed in 0.5s ===="}},
+			outcome:   "failed",
+			errorType: "verification_failed",
+			quality:   0.75,
+		},
+		{
+			name:      "missing tool",
+			results:   []VerifyResult{{Command: "make check", ExitCode: 127}, {Command: "go vet ./...", Passed: true}},
+			outcome:   "tool_error",
+			errorType: "verification_tool_error",
+			quality:   1,
+		},
+		{
+			name:      "failure outranks tool error",
+			results:   []VerifyResult{{Command: "make check", ExitCode: 127}, {Command: "go vet ./...", ExitCode: 1}},
+			outcome:   "failed",
+			errorType: "verification_failed",
+			quality:   0,
+		},
+	}
+	for _, tc := range cases {
+		verdict := classifyVerification(tc.results)
+		if verdict.Outcome != tc.outcome || verdict.ErrorType != tc.errorType {
+			t.Fatalf("%s: got outcome=%s error_type=%s", tc.name, verdict.Outcome, verdict.ErrorType)
+		}
+		if math.Abs(verdict.QualityScore-tc.quality) > 1e-9 {
+			t.Fatalf("%s: quality %.3f, want %.3f", tc.name, verdict.QualityScore, tc.quality)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -21,6 +21,18 @@ import (
 	"github.com/bcrosbie/modeloman/internal/mm/redact"
 	"github.com/bcrosbie/modeloman/internal/mm/runner"
 	"github.com/bcrosbie/modeloman/internal/mm/telemetry"
+	"github.com/bcrosbie/modeloman/internal/mm/usage"
+)
+
+// budgetCheckInterval is how often a running backend's usage is compared
+// against the hub's per-run caps.
+const budgetCheckInterval = time.Second
+
+// The hub rejects StartRun with a longer run name or description, which
+// would leave the run without telemetry.
+const (
+	maxRunNameBytes        = 200
+	maxRunDescriptionBytes = 4000
 )
 
 type RunParams struct {
@@ -34,10 +46,26 @@ type RunParams struct {
 	ForwardInput    bool
 	InputReader     io.Reader
 	AdditionalEntry []string
+	Bundles         []string
+	ExpandImports   bool
 	RepoRoot        string
 	OutputWriter    io.Writer
 	OnOutput        func(string)
 	OnRunnerEvent   func(runner.Event)
+	// Env adds KEY=VALUE entries to the backend's environment for this run,
+	// after the config's env and backend_env.
+	Env []string
+	// VerifyCommands replace the commands from .modeloman/verify.json for
+	// this run; NoVerify skips verification entirely.
+	VerifyCommands []string
+	NoVerify       bool
+	// Name and Description label the hub run in run lists and the
+	// dashboard. They are redacted like the prompt; the objective itself is
+	// never sent to the hub.
+	Name        string
+	Description string
+
+	stage *stageContext
 }
 
 type RunResult struct {
@@ -53,6 +81,11 @@ type RunResult struct {
 	LastError     string
 	AgentID       string
 	SelectedEntry []string
+	Usage         usage.Snapshot
+	PolicyAbort   string
+	PolicyWarning string
+	Verification  []VerifyResult
+	QualityScore  float64
 }
 
 func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult, error) {
@@ -71,6 +104,12 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 	if objective == "" {
 		return RunResult{}, fmt.Errorf("objective is required")
 	}
+	if len(strings.TrimSpace(params.Name)) > maxRunNameBytes {
+		return RunResult{}, fmt.Errorf("run name must be at most %d bytes", maxRunNameBytes)
+	}
+	if len(strings.TrimSpace(params.Description)) > maxRunDescriptionBytes {
+		return RunResult{}, fmt.Errorf("run description must be at most %d bytes", maxRunDescriptionBytes)
+	}
 
 	repoRoot := strings.TrimSpace(params.RepoRoot)
 	if repoRoot == "" {
@@ -85,18 +124,44 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 	if err != nil {
 		return RunResult{}, err
 	}
-	entries := mergeEntries(storedCtx.Entries, params.AdditionalEntry)
+	bundleEntries, err := mmcontext.ManifestEntries(repoRoot, params.Bundles)
+	if err != nil {
+		return RunResult{}, err
+	}
+	entries := mergeEntries(storedCtx.Entries, append(bundleEntries, params.AdditionalEntry...))
+	workspaces, err := mmcontext.Workspaces(cfg.WorkspaceRoots)
+	if err != nil {
+		return RunResult{}, err
+	}
 
 	bundle, err := mmcontext.BuildBundle(mmcontext.BuildOptions{
-		RepoRoot:    repoRoot,
-		Entries:     entries,
-		Prompt:      objective,
-		MaxBytes:    cfg.MaxContextBytes,
-		TokenBudget: params.BudgetTokens,
+		RepoRoot:      repoRoot,
+		Entries:       entries,
+		Prompt:        objective,
+		MaxBytes:      cfg.MaxContextBytes,
+		TokenBudget:   params.BudgetTokens,
+		ExpandImports: params.ExpandImports,
+		Exclude:       mmcontext.DefaultExcludeRules(cfg.MaxFileKB, cfg.ExcludePatterns),
+		Workspaces:    workspaces,
 	})
 	if err != nil {
 		return RunResult{}, err
 	}
+	if len(bundle.SkippedFiles) > 0 {
+		slog.Info("context: skipped files by size/type rules", "files", len(bundle.SkippedFiles))
+	}
+	if params.stage == nil || params.stage.number == 1 {
+		if err := mmcontext.RecordObjective(repoRoot, objective); err != nil {
+			slog.Warn("objective history not recorded", "err", err)
+		}
+	}
+
+	if err := mmconfig.ValidateEnv(params.Env); err != nil {
+		return RunResult{}, err
+	}
+	backendEnv := append(cfg.EnvFor(backend), params.Env...)
+	redactor := redact.New(cfg.RedactionEnabled, cfg.CustomRedactRegexes)
+	redactor.AddSecrets(envValues(backendEnv)...)
 
 	snippet := loadSkillSnippet(repoRoot, params.Skill)
 	houseRules := strings.Join([]string{
@@ -104,6 +169,9 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 		"- Keep the change set minimal and verifiable.",
 		"- Prioritize compile/test pass and explicit next steps.",
 	}, "\n")
+	if params.stage != nil {
+		houseRules += "\n\n" + params.stage.hint(redactor)
+	}
 	finalPrompt := prompt.Build(prompt.TemplateInput{
 		Objective:      objective,
 		TaskType:       taskType,
@@ -115,7 +183,17 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 		AdditionalHint: houseRules,
 	})
 
-	redactor := redact.New(cfg.RedactionEnabled, cfg.CustomRedactRegexes)
+	verifyCfg, err := mmcontext.LoadVerifyConfig(repoRoot)
+	if err != nil {
+		return RunResult{}, err
+	}
+	verifyCommands := verifyCfg.CommandsFor(taskType)
+	if len(params.VerifyCommands) > 0 {
+		verifyCommands = params.VerifyCommands
+	}
+	if params.NoVerify {
+		verifyCommands = nil
+	}
 	safeBundle := redactor.Apply(bundle.Rendered)
 	safePrompt := redactor.Apply(finalPrompt)
 	promptHash := digestString(safePrompt)
@@ -125,46 +203,110 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 	if strings.TrimSpace(token) != "" {
 		client, err = telemetry.New(cfg, token)
 		if err != nil {
-			log.Printf("telemetry disabled: %v", err)
+			slog.Warn("telemetry disabled", "err", err)
 		}
 	}
 	if client != nil {
 		defer client.Close()
 	}
 
-	runID := ""
-	agentID := localAgentID()
+	runID := params.stage.hubRunID()
+	agentID := LocalAgentID()
+	startedRun := false
 	if client != nil {
-		startCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
-		runID, err = client.StartRun(startCtx, telemetry.StartRunInput{
-			Workflow:      taskType,
-			AgentID:       agentID,
-			PromptVersion: strings.TrimSpace(params.Skill),
-			ModelPolicy:   backend,
-		})
+		if runID == "" {
+			startedRun = true
+			startCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			runID, err = client.StartRun(startCtx, telemetry.StartRunInput{
+				Workflow:      taskType,
+				AgentID:       agentID,
+				PromptVersion: strings.TrimSpace(params.Skill),
+				ModelPolicy:   backend,
+				Metadata:      map[string]string{RepoLabel: RepoLabelValue(repoRoot)},
+				Name:          redactor.Apply(params.Name),
+				Description:   redactor.Apply(params.Description),
+			})
+			cancel()
+		}
+
+		syncCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if _, syncErr := client.SyncPolicyCache(syncCtx, repoRoot); syncErr != nil && !telemetry.IsAccessDenied(syncErr) {
+			slog.Warn("policy cache sync failed", "agent_id", agentID, "err", syncErr)
+			warnFromPolicyCache(repoRoot)
+		}
 		cancel()
+
 		if err != nil {
-			log.Printf("start run failed: %v", err)
+			slog.Warn("start run failed", "workflow", taskType, "agent_id", agentID, "err", err)
 		} else {
-			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
-				RunID:     runID,
-				EventType: "mm_run_started",
-				Level:     "info",
-				Message:   "mm wrapper started backend session",
-				Data: map[string]any{
-					"backend":          backend,
-					"task_type":        taskType,
-					"budget_tokens":    params.BudgetTokens,
-					"repo_root":        bundle.RepoMeta.Root,
-					"branch":           bundle.RepoMeta.Branch,
-					"commit":           bundle.RepoMeta.Commit,
-					"dirty":            bundle.RepoMeta.Dirty,
-					"context_hash":     bundle.Hash,
-					"prompt_hash":      promptHash,
-					"selected_entries": entries,
-					"selected_files":   bundle.SelectedFiles,
-				},
+			startData := params.stage.eventData(map[string]any{
+				"backend":          backend,
+				"task_type":        taskType,
+				"budget_tokens":    params.BudgetTokens,
+				"repo_root":        bundle.RepoMeta.Root,
+				"branch":           bundle.RepoMeta.Branch,
+				"commit":           bundle.RepoMeta.Commit,
+				"dirty":            bundle.RepoMeta.Dirty,
+				"context_hash":     bundle.Hash,
+				"prompt_hash":      promptHash,
+				"selected_entries": entries,
+				"bundles":          params.Bundles,
+				"selected_files":   bundle.SelectedFiles,
+				"imported_files":   bundle.ImportedFiles,
+				"skipped_files":    len(bundle.SkippedFiles),
+				"workspace_repos":  workspaceFileCounts(bundle.WorkspaceRepos),
+				"env_keys":         envKeys(backendEnv),
 			})
+			if startedRun {
+				_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
+					RunID:     runID,
+					EventType: "mm_run_started",
+					Level:     "info",
+					Message:   "mm wrapper started backend session",
+					Data:      startData,
+				})
+			}
+			if params.stage != nil && params.stage.eventType != "" {
+				_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
+					RunID:     runID,
+					EventType: params.stage.eventType,
+					Level:     "info",
+					Message:   params.stage.eventMessage,
+					Data:      startData,
+				})
+			}
+		}
+	}
+
+	// Fetch the cap the hub will apply so the backend can be stopped as soon
+	// as local estimates pass it, instead of the attempt being rejected later.
+	// When the hub is unreachable the cached caps only produce a warning.
+	var limits telemetry.Limits
+	if client != nil && cfg.AbortOnCap {
+		limitsInput := telemetry.LimitsInput{
+			Workflow:     taskType,
+			AgentID:      agentID,
+			ProviderType: cfg.ProviderTypeFor(backend),
+			Model:        backend,
+		}
+		limitsCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		limits, err = client.GetEffectiveLimits(limitsCtx, limitsInput)
+		cancel()
+		if err != nil {
+			slog.Warn("effective limits unavailable", "agent_id", agentID, "run_id", runID, "err", err)
+			limits = telemetry.Limits{}
+			if cache, ok, cacheErr := telemetry.LoadPolicyCache(repoRoot); cacheErr == nil && ok {
+				limits = cache.Limits(limitsInput, time.Now().UTC())
+				slog.Info("using cached policy for cap warnings", "fetched_at", cache.FetchedAt)
+			}
+		}
+	}
+	fullPrompt := finalPrompt + "\n\nContext bundle:\n" + safeBundle
+	meter := usage.NewMeter(int64(len(fullPrompt))/4, cfg.CostPerMillionUSD)
+	onOutput := func(chunk string) {
+		meter.Observe(chunk)
+		if params.OnOutput != nil {
+			params.OnOutput(chunk)
 		}
 	}
 
@@ -175,31 +317,103 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 		Duration:  0,
 		Events:    []runner.Event{},
 	}
+	abortReason := ""
+	capWarning := ""
 	if !params.DryRun {
-		runResult = runner.Run(ctx, runner.Options{
+		runCtx, cancelRun := context.WithCancel(ctx)
+		exceededCh := make(chan string, 1)
+		stopWatch := make(chan struct{})
+		if !limits.DryRun && (limits.MaxCostPerRunUSD > 0 || limits.MaxTokensPerRun > 0) {
+			onExceeded := func(reason string) {
+				exceededCh <- reason
+				if limits.Cached {
+					// Stale limits are not worth killing a run over; say so instead.
+					if params.OnRunnerEvent != nil {
+						params.OnRunnerEvent(runner.Event{
+							Type:    "policy_cap_warning",
+							At:      time.Now().UTC().Format(time.RFC3339Nano),
+							Message: "likely over cap per cached policy: " + reason,
+						})
+					}
+					return
+				}
+				cancelRun()
+			}
+			go watchRunBudget(meter, params.stage.priorUsage(), limits, onExceeded, stopWatch)
+		}
+		runResult = runner.Run(runCtx, runner.Options{
 			Backend:            backend,
 			RepoDir:            repoRoot,
-			Prompt:             finalPrompt + "\n\nContext bundle:\n" + safeBundle,
+			Prompt:             fullPrompt,
 			UsePTY:             params.UsePTY,
 			CaptureTranscript:  true,
 			MaxTranscriptBytes: cfg.MaxTranscriptBytes,
 			ForwardInput:       params.ForwardInput,
 			InputReader:        params.InputReader,
 			OutputWriter:       params.OutputWriter,
-			OnOutput:           params.OnOutput,
+			OnOutput:           onOutput,
 			OnEvent:            params.OnRunnerEvent,
+			Env:                backendEnv,
 		})
+		close(stopWatch)
+		cancelRun()
+		select {
+		case reason := <-exceededCh:
+			if limits.Cached {
+				capWarning = reason
+			} else {
+				abortReason = reason
+			}
+		default:
+		}
+	}
+	snapshot := meter.Snapshot()
+	if abortReason != "" {
+		event := runner.Event{
+			Type:    "policy_abort",
+			At:      time.Now().UTC().Format(time.RFC3339Nano),
+			Message: "backend stopped: " + abortReason,
+			Data: map[string]any{
+				"tokens":   snapshot.Tokens(),
+				"cost_usd": snapshot.CostUSD,
+				"source":   limits.Source,
+			},
+		}
+		runResult.Events = append(runResult.Events, event)
+		if params.OnRunnerEvent != nil {
+			params.OnRunnerEvent(event)
+		}
 	}
 
 	diffSummary, diffErr := gitutil.SummarizeDiff(repoRoot)
 	if diffErr != nil {
-		log.Printf("diff summary warning: %v", diffErr)
+		slog.Warn("diff summary failed", "err", diffErr)
+	}
+
+	var verification []VerifyResult
+	if !params.DryRun && abortReason == "" && len(verifyCommands) > 0 && ctx.Err() == nil {
+		verification = runVerification(ctx, repoRoot, verifyCommands, verifyCfg.Timeout())
+		if params.OnRunnerEvent != nil {
+			for _, result := range verification {
+				params.OnRunnerEvent(runner.Event{
+					Type:    "verification",
+					At:      time.Now().UTC().Format(time.RFC3339Nano),
+					Message: result.Summary(),
+				})
+			}
+		}
 	}
 
 	outcome := "success"
 	status := "completed"
 	lastErr := ""
-	if runResult.Err != nil || runResult.ExitCode != 0 {
+	errorType := ""
+	if abortReason != "" {
+		outcome = "failed"
+		status = "cancelled"
+		lastErr = "aborted by mm: " + abortReason
+		errorType = "policy_abort"
+	} else if runResult.Err != nil || runResult.ExitCode != 0 {
 		outcome = "failed"
 		status = "failed"
 		if runResult.Err != nil {
@@ -208,8 +422,53 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 			lastErr = fmt.Sprintf("backend exited with status %d", runResult.ExitCode)
 		}
 	}
+	qualityScore := 0.0
+	attemptErr := lastErr
+	if len(verification) > 0 && runResult.Err == nil {
+		// The repo's checks judge the work better than the backend's exit
+		// code, which interactive CLIs often set on quit. A backend that
+		// could not run at all (runResult.Err) stays failed.
+		verdict := classifyVerification(verification)
+		outcome = verdict.Outcome
+		errorType = verdict.ErrorType
+		attemptErr = verdict.Message
+		qualityScore = verdict.QualityScore
+		if verdict.Message != "" {
+			lastErr = verdict.Message
+		}
+	}
 
 	if client != nil && strings.TrimSpace(runID) != "" {
+		if capWarning != "" {
+			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
+				RunID:     runID,
+				EventType: "policy_cap_warning",
+				Level:     "warn",
+				Message:   "run likely exceeded a cap per mm's cached policy: " + capWarning,
+				Data: map[string]any{
+					"tokens":   snapshot.Tokens(),
+					"cost_usd": snapshot.CostUSD,
+					"source":   limits.Source,
+				},
+			})
+		}
+		if abortReason != "" {
+			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
+				RunID:     runID,
+				EventType: "policy_abort",
+				Level:     "warn",
+				Message:   "mm stopped the backend: " + abortReason,
+				Data: map[string]any{
+					"tokens_in":            snapshot.TokensIn,
+					"tokens_out":           snapshot.TokensOut,
+					"cost_usd":             snapshot.CostUSD,
+					"usage_reported":       snapshot.Reported,
+					"max_cost_per_run_usd": limits.MaxCostPerRunUSD,
+					"max_tokens_per_run":   limits.MaxTokensPerRun,
+					"source":               limits.Source,
+				},
+			})
+		}
 		for _, event := range runResult.Events {
 			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
 				RunID:     runID,
@@ -224,6 +483,28 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 			})
 		}
 
+		for _, result := range verification {
+			level := "info"
+			if !result.Passed {
+				level = "warn"
+			}
+			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
+				RunID:     runID,
+				EventType: "mm_verification",
+				Level:     level,
+				Message:   result.Summary(),
+				Data: map[string]any{
+					"command":          result.Command,
+					"exit_code":        result.ExitCode,
+					"passed":           result.Passed,
+					"timed_out":        result.TimedOut,
+					"duration_ms":      result.Duration.Milliseconds(),
+					"output":           redactor.Apply(result.Output),
+					"output_truncated": result.OutputTruncated,
+				},
+			})
+		}
+
 		if transcript := strings.TrimSpace(runResult.Transcript); transcript != "" {
 			data := map[string]any{
 				"redacted_transcript": redactor.Apply(transcript),
@@ -231,7 +512,8 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 				"truncated":           runResult.TranscriptTruncated,
 			}
 			if cfg.AllowRawTranscript {
-				data["raw_transcript"] = transcript
+				// Raw still means unpatterned; injected env values never leave.
+				data["raw_transcript"] = redactor.ApplySecrets(transcript)
 			}
 			_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
 				RunID:     runID,
@@ -244,15 +526,22 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 
 		_ = client.RecordPromptAttempt(context.Background(), telemetry.AttemptInput{
 			RunID:         runID,
-			AttemptNumber: 1,
+			AttemptNumber: params.stage.attemptNumber(),
 			Workflow:      taskType,
 			AgentID:       agentID,
+			ProviderType:  cfg.ProviderTypeFor(backend),
 			Model:         backend,
 			PromptVersion: strings.TrimSpace(params.Skill),
 			PromptHash:    promptHash,
 			Outcome:       outcome,
-			ErrorMessage:  redactor.Apply(lastErr),
+			ErrorType:     errorType,
+			ErrorMessage:  redactor.Apply(attemptErr),
+			TokensIn:      snapshot.TokensIn,
+			TokensOut:     snapshot.TokensOut,
+			CostUSD:       snapshot.CostUSD,
 			LatencyMS:     runResult.Duration.Milliseconds(),
+			QualityScore:  qualityScore,
+			Metadata:      params.stage.attemptMetadata(),
 		})
 		_ = client.RecordRunEvent(context.Background(), telemetry.EventInput{
 			RunID:     runID,
@@ -265,11 +554,13 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 				"deleted_lines": diffSummary.DeletedLines,
 			},
 		})
-		_ = client.FinishRun(context.Background(), telemetry.FinishRunInput{
-			RunID:     runID,
-			Status:    status,
-			LastError: redactor.Apply(lastErr),
-		})
+		if params.stage.finishesRun(outcome, abortReason) {
+			_ = client.FinishRun(context.Background(), telemetry.FinishRunInput{
+				RunID:     runID,
+				Status:    status,
+				LastError: redactor.Apply(lastErr),
+			})
+		}
 	}
 
 	return RunResult{
@@ -285,9 +576,54 @@ func Run(ctx context.Context, cfg mmconfig.Config, params RunParams) (RunResult,
 		LastError:     lastErr,
 		AgentID:       agentID,
 		SelectedEntry: entries,
+		Usage:         snapshot,
+		PolicyAbort:   abortReason,
+		PolicyWarning: capWarning,
+		Verification:  verification,
+		QualityScore:  qualityScore,
 	}, nil
 }
 
+// watchRunBudget calls onExceeded once when metered usage, on top of what
+// earlier attempts of the run spent, passes a per-run cap.
+func watchRunBudget(meter *usage.Meter, spent usage.Snapshot, limits telemetry.Limits, onExceeded func(reason string), stop <-chan struct{}) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if reason, exceeded := capExceeded(spent.Add(meter.Snapshot()), limits); exceeded {
+				onExceeded(reason)
+				return
+			}
+		}
+	}
+}
+
+// warnFromPolicyCache logs what the cached policy says when the hub cannot be
+// reached at run start.
+func warnFromPolicyCache(repoRoot string) {
+	cache, ok, err := telemetry.LoadPolicyCache(repoRoot)
+	if err != nil || !ok {
+		return
+	}
+	if cache.Policy.KillSwitch {
+		slog.Warn("hub unreachable; cached policy has the kill switch on", "fetched_at", cache.FetchedAt, "reason", cache.Policy.KillSwitchReason)
+	}
+}
+
+func capExceeded(snapshot usage.Snapshot, limits telemetry.Limits) (string, bool) {
+	if limits.MaxTokensPerRun > 0 && snapshot.Tokens() > limits.MaxTokensPerRun {
+		return fmt.Sprintf("run exceeded max tokens cap %d (%s)", limits.MaxTokensPerRun, limits.Source), true
+	}
+	if limits.MaxCostPerRunUSD > 0 && snapshot.CostUSD > limits.MaxCostPerRunUSD {
+		return fmt.Sprintf("run exceeded max cost cap $%.2f (%s)", limits.MaxCostPerRunUSD, limits.Source), true
+	}
+	return "", false
+}
+
 func SendFeedback(ctx context.Context, cfg mmconfig.Config, runID string, rating int, notes string) error {
 	runID = strings.TrimSpace(runID)
 	if runID == "" || rating <= 0 {
@@ -316,6 +652,30 @@ func SendFeedback(ctx context.Context, cfg mmconfig.Config, runID string, rating
 	})
 }
 
+// envKeys lists the names of injected variables for telemetry; values stay
+// local.
+func envKeys(env []string) []string {
+	keys := []string{}
+	seen := map[string]struct{}{}
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func envValues(env []string) []string {
+	values := make([]string, 0, len(env))
+	for _, entry := range env {
+		_, value, _ := strings.Cut(entry, "=")
+		values = append(values, value)
+	}
+	return values
+}
+
 func loadSkillSnippet(repoRoot, skill string) string {
 	skill = strings.TrimSpace(skill)
 	if skill == "" {
@@ -336,7 +696,8 @@ func loadSkillSnippet(repoRoot, skill string) string {
 	return ""
 }
 
-func localAgentID() string {
+// LocalAgentID is the agent id mm reports runs under: mm@host:user.
+func LocalAgentID() string {
 	host, _ := os.Hostname()
 	currentUser, _ := user.Current()
 	u := "unknown-user"
@@ -354,6 +715,14 @@ func digestString(value string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+func workspaceFileCounts(repos []mmcontext.WorkspaceSelection) map[string]int {
+	out := map[string]int{}
+	for _, repo := range repos {
+		out[repo.Name] = len(repo.Files)
+	}
+	return out
+}
+
 func mergeEntries(base, extra []string) []string {
 	seen := map[string]struct{}{}
 	out := make([]string, 0, len(base)+len(extra))
@@ -0,0 +1,217 @@
+// Package notify sends hub alerts, such as the kill switch engaging or a
+// policy cap rejecting attempts, to an incident channel.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize   = 256
+	sendTimeout = 5 * time.Second
+)
+
+// Alert is one notification. Alerts with the same Kind and Key are
+// duplicates of each other for deduplication.
+type Alert struct {
+	Kind    string         `json:"kind"`
+	Key     string         `json:"key,omitempty"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+	At      string         `json:"at"`
+	// Repeats counts the duplicates collapsed into a digest alert.
+	Repeats int `json:"repeats,omitempty"`
+	// Window is how long the digest's duplicates were collected over.
+	Window string `json:"window,omitempty"`
+}
+
+// Text is a one-line rendering for chat channels.
+func (a Alert) Text() string {
+	if a.Repeats > 0 {
+		return fmt.Sprintf("[modeloman] %s: %s (%d more in the last %s)", a.Kind, a.Message, a.Repeats, a.Window)
+	}
+	return fmt.Sprintf("[modeloman] %s: %s", a.Kind, a.Message)
+}
+
+// Sender delivers one alert.
+type Sender interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// WebhookSender posts alerts as JSON. The body carries a "text" field, so
+// Slack-compatible incoming webhooks can take it as is, and the alert
+// itself under "alert".
+type WebhookSender struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookSender(url string) *WebhookSender {
+	return &WebhookSender{URL: url, Client: &http.Client{Timeout: sendTimeout}}
+}
+
+func (s *WebhookSender) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]any{"text": alert.Text(), "alert": alert})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", response.Status)
+	}
+	return nil
+}
+
+// Notifier sends alerts in the background and deduplicates them: the first
+// alert of a kind and key goes out at once, duplicates within the window
+// after it are held back, and when the window closes one digest reports how
+// many were collapsed. A digest opens a new window, so a steady stream of
+// duplicates produces at most one message per window.
+type Notifier struct {
+	sender Sender
+	window time.Duration
+
+	queue     chan Alert
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// windows is owned by the run goroutine.
+	windows map[string]*alertWindow
+}
+
+type alertWindow struct {
+	closesAt time.Time
+	repeats  int
+	last     Alert
+}
+
+// New starts a notifier that deduplicates over window.
+func New(sender Sender, window time.Duration) *Notifier {
+	n := newNotifier(sender, window)
+	go n.run()
+	return n
+}
+
+func newNotifier(sender Sender, window time.Duration) *Notifier {
+	return &Notifier{
+		sender:  sender,
+		window:  window,
+		queue:   make(chan Alert, queueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		windows: map[string]*alertWindow{},
+	}
+}
+
+// Notify queues alert without blocking; when the queue is full the alert is
+// dropped and logged. It is safe on a nil Notifier.
+func (n *Notifier) Notify(alert Alert) {
+	if n == nil {
+		return
+	}
+	if alert.At == "" {
+		alert.At = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	select {
+	case <-n.done:
+		return
+	default:
+	}
+	select {
+	case n.queue <- alert:
+	default:
+		slog.Warn("notify: queue full, dropped alert", "kind", alert.Kind, "message", alert.Message)
+	}
+}
+
+// Close sends what is queued, then digests for any open windows with
+// collapsed duplicates, and stops.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.closeOnce.Do(func() {
+		close(n.done)
+		<-n.stopped
+	})
+}
+
+func (n *Notifier) run() {
+	defer close(n.stopped)
+	ticker := time.NewTicker(max(n.window/10, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case alert := <-n.queue:
+			n.handle(alert, time.Now())
+		case now := <-ticker.C:
+			n.flushDigests(now, false)
+		case <-n.done:
+			for {
+				select {
+				case alert := <-n.queue:
+					n.handle(alert, time.Now())
+				default:
+					n.flushDigests(time.Now(), true)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (n *Notifier) handle(alert Alert, now time.Time) {
+	key := alert.Kind + "\x00" + alert.Key
+	if open, ok := n.windows[key]; ok && now.Before(open.closesAt) {
+		open.repeats++
+		open.last = alert
+		return
+	}
+	n.windows[key] = &alertWindow{closesAt: now.Add(n.window)}
+	n.send(alert)
+}
+
+// flushDigests sends a digest for each closed window that collapsed
+// duplicates, or for every such window when all is set, and forgets quiet
+// ones.
+func (n *Notifier) flushDigests(now time.Time, all bool) {
+	for key, open := range n.windows {
+		if !all && now.Before(open.closesAt) {
+			continue
+		}
+		if open.repeats == 0 {
+			delete(n.windows, key)
+			continue
+		}
+		digest := open.last
+		digest.Repeats = open.repeats
+		digest.Window = n.window.String()
+		n.windows[key] = &alertWindow{closesAt: now.Add(n.window)}
+		n.send(digest)
+	}
+}
+
+func (n *Notifier) send(alert Alert) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := n.sender.Send(ctx, alert); err != nil {
+		slog.Error("notify: failed to send alert", "kind", alert.Kind, "err", err)
+	}
+}
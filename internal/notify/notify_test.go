@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	sent []Alert
+}
+
+func (s *recordingSender) Send(_ context.Context, alert Alert) error {
+	s.sent = append(s.sent, alert)
+	return nil
+}
+
+func TestNotifierCollapsesRepeatsIntoOneDigestPerWindow(t *testing.T) {
+	sender := &recordingSender{}
+	n := newNotifier(sender, 10*time.Minute)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range 5 {
+		n.handle(Alert{Kind: "kill_switch", Message: "StartRun rejected"}, start.Add(time.Duration(i)*time.Minute))
+	}
+	n.handle(Alert{Kind: "policy_cap_violation", Key: "cap-a", Message: "cap a"}, start)
+	n.handle(Alert{Kind: "policy_cap_violation", Key: "cap-b", Message: "cap b"}, start)
+	n.handle(Alert{Kind: "policy_cap_violation", Key: "cap-a", Message: "cap a"}, start.Add(time.Minute))
+	if len(sender.sent) != 3 {
+		t.Fatalf("expected the first alert per kind and key, got %d: %+v", len(sender.sent), sender.sent)
+	}
+
+	n.flushDigests(start.Add(5*time.Minute), false)
+	if len(sender.sent) != 3 {
+		t.Fatalf("expected no digest before the window closes, got %d", len(sender.sent))
+	}
+
+	n.flushDigests(start.Add(10*time.Minute), false)
+	digests := sender.sent[3:]
+	if len(digests) != 2 {
+		t.Fatalf("expected digests for the two repeated keys, got %+v", digests)
+	}
+	repeats := map[string]int{}
+	for _, digest := range digests {
+		repeats[digest.Kind+"/"+digest.Key] = digest.Repeats
+	}
+	if repeats["kill_switch/"] != 4 || repeats["policy_cap_violation/cap-a"] != 1 {
+		t.Fatalf("unexpected digest repeat counts: %v", repeats)
+	}
+
+	// The digest opened a new window, so another repeat is held again.
+	n.handle(Alert{Kind: "kill_switch", Message: "StartRun rejected"}, start.Add(11*time.Minute))
+	if len(sender.sent) != 5 {
+		t.Fatalf("expected the repeat after a digest to be held, got %d", len(sender.sent))
+	}
+	// A quiet window is forgotten, and the next alert goes out at once.
+	n.flushDigests(start.Add(30*time.Minute), false)
+	n.flushDigests(start.Add(45*time.Minute), false)
+	sentBefore := len(sender.sent)
+	n.handle(Alert{Kind: "policy_cap_violation", Key: "cap-b", Message: "cap b"}, start.Add(45*time.Minute))
+	if len(sender.sent) != sentBefore+1 {
+		t.Fatalf("expected an alert after a quiet window to be sent immediately")
+	}
+}
+
+func TestNotifierCloseSendsQueuedAlertsAndPendingDigests(t *testing.T) {
+	sender := &recordingSender{}
+	n := New(sender, time.Hour)
+	n.Notify(Alert{Kind: "kill_switch", Message: "engaged"})
+	n.Notify(Alert{Kind: "kill_switch", Message: "StartRun rejected"})
+	n.Close()
+	n.Notify(Alert{Kind: "kill_switch", Message: "after close"})
+
+	if len(sender.sent) != 2 {
+		t.Fatalf("expected the first alert and a digest, got %+v", sender.sent)
+	}
+	if sender.sent[1].Repeats != 1 || sender.sent[1].Message != "StartRun rejected" {
+		t.Fatalf("unexpected digest: %+v", sender.sent[1])
+	}
+}
@@ -1,37 +1,57 @@
 package rpccontract
 
+import "strings"
+
 const (
 	ServiceName = "modeloman.v1.ModeloManHub"
 )
 
 const (
-	MethodGetHealth           = "/" + ServiceName + "/GetHealth"
-	MethodGetSummary          = "/" + ServiceName + "/GetSummary"
-	MethodExportState         = "/" + ServiceName + "/ExportState"
-	MethodCreateTask          = "/" + ServiceName + "/CreateTask"
-	MethodUpdateTask          = "/" + ServiceName + "/UpdateTask"
-	MethodDeleteTask          = "/" + ServiceName + "/DeleteTask"
-	MethodListTasks           = "/" + ServiceName + "/ListTasks"
-	MethodCreateNote          = "/" + ServiceName + "/CreateNote"
-	MethodListNotes           = "/" + ServiceName + "/ListNotes"
-	MethodAppendChangelog     = "/" + ServiceName + "/AppendChangelog"
-	MethodListChangelog       = "/" + ServiceName + "/ListChangelog"
-	MethodRecordBenchmark     = "/" + ServiceName + "/RecordBenchmark"
-	MethodListBenchmarks      = "/" + ServiceName + "/ListBenchmarks"
-	MethodStartRun            = "/" + ServiceName + "/StartRun"
-	MethodFinishRun           = "/" + ServiceName + "/FinishRun"
-	MethodListRuns            = "/" + ServiceName + "/ListRuns"
-	MethodRecordPromptAttempt = "/" + ServiceName + "/RecordPromptAttempt"
-	MethodListPromptAttempts  = "/" + ServiceName + "/ListPromptAttempts"
-	MethodRecordRunEvent      = "/" + ServiceName + "/RecordRunEvent"
-	MethodListRunEvents       = "/" + ServiceName + "/ListRunEvents"
-	MethodGetTelemetrySummary = "/" + ServiceName + "/GetTelemetrySummary"
-	MethodGetPolicy           = "/" + ServiceName + "/GetPolicy"
-	MethodSetPolicy           = "/" + ServiceName + "/SetPolicy"
-	MethodGetLeaderboard      = "/" + ServiceName + "/GetLeaderboard"
-	MethodListPolicyCaps      = "/" + ServiceName + "/ListPolicyCaps"
-	MethodUpsertPolicyCap     = "/" + ServiceName + "/UpsertPolicyCap"
-	MethodDeletePolicyCap     = "/" + ServiceName + "/DeletePolicyCap"
+	MethodGetHealth              = "/" + ServiceName + "/GetHealth"
+	MethodGetAPIInfo             = "/" + ServiceName + "/GetAPIInfo"
+	MethodGetSummary             = "/" + ServiceName + "/GetSummary"
+	MethodExportState            = "/" + ServiceName + "/ExportState"
+	MethodCreateTask             = "/" + ServiceName + "/CreateTask"
+	MethodUpdateTask             = "/" + ServiceName + "/UpdateTask"
+	MethodDeleteTask             = "/" + ServiceName + "/DeleteTask"
+	MethodListTasks              = "/" + ServiceName + "/ListTasks"
+	MethodCreateNote             = "/" + ServiceName + "/CreateNote"
+	MethodListNotes              = "/" + ServiceName + "/ListNotes"
+	MethodAppendChangelog        = "/" + ServiceName + "/AppendChangelog"
+	MethodListChangelog          = "/" + ServiceName + "/ListChangelog"
+	MethodRecordBenchmark        = "/" + ServiceName + "/RecordBenchmark"
+	MethodListBenchmarks         = "/" + ServiceName + "/ListBenchmarks"
+	MethodStartRun               = "/" + ServiceName + "/StartRun"
+	MethodFinishRun              = "/" + ServiceName + "/FinishRun"
+	MethodCancelRun              = "/" + ServiceName + "/CancelRun"
+	MethodStarRun                = "/" + ServiceName + "/StarRun"
+	MethodShareRun               = "/" + ServiceName + "/ShareRun"
+	MethodListRuns               = "/" + ServiceName + "/ListRuns"
+	MethodGetRun                 = "/" + ServiceName + "/GetRun"
+	MethodRecordPromptAttempt    = "/" + ServiceName + "/RecordPromptAttempt"
+	MethodListPromptAttempts     = "/" + ServiceName + "/ListPromptAttempts"
+	MethodRecordRunEvent         = "/" + ServiceName + "/RecordRunEvent"
+	MethodRecordRunEvents        = "/" + ServiceName + "/RecordRunEvents"
+	MethodImportTelemetry        = "/" + ServiceName + "/ImportTelemetry"
+	MethodListRunEvents          = "/" + ServiceName + "/ListRunEvents"
+	MethodGetTelemetrySummary    = "/" + ServiceName + "/GetTelemetrySummary"
+	MethodGetPolicy              = "/" + ServiceName + "/GetPolicy"
+	MethodSetPolicy              = "/" + ServiceName + "/SetPolicy"
+	MethodGetLeaderboard         = "/" + ServiceName + "/GetLeaderboard"
+	MethodListPolicyCaps         = "/" + ServiceName + "/ListPolicyCaps"
+	MethodUpsertPolicyCap        = "/" + ServiceName + "/UpsertPolicyCap"
+	MethodDeletePolicyCap        = "/" + ServiceName + "/DeletePolicyCap"
+	MethodRecordArtifact         = "/" + ServiceName + "/RecordArtifact"
+	MethodGetArtifact            = "/" + ServiceName + "/GetArtifact"
+	MethodListArtifacts          = "/" + ServiceName + "/ListArtifacts"
+	MethodSetActivePromptVersion = "/" + ServiceName + "/SetActivePromptVersion"
+	MethodRollbackPromptVersion  = "/" + ServiceName + "/RollbackPromptVersion"
+	MethodListPromptReleases     = "/" + ServiceName + "/ListPromptReleases"
+	MethodListPolicyAudit        = "/" + ServiceName + "/ListPolicyAudit"
+	MethodGetEffectiveLimits     = "/" + ServiceName + "/GetEffectiveLimits"
+	MethodRecommendModel         = "/" + ServiceName + "/RecommendModel"
+	MethodGetRetryReport         = "/" + ServiceName + "/GetRetryReport"
+	MethodPrune                  = "/" + ServiceName + "/Prune"
 )
 
 const (
@@ -39,26 +59,41 @@ const (
 	ScopeTelemetryWrite = "telemetry:write"
 	ScopePolicyWrite    = "policy:write"
 	ScopeAdminRead      = "admin:read"
+	// ScopeExport allows ExportState with prompt hashes, error messages and
+	// note bodies redacted; ScopeExportFull returns them and implies
+	// ScopeExport. Neither is in DefaultAgentKeyScopes.
+	ScopeExport     = "export"
+	ScopeExportFull = "export:full"
 )
 
 var WriteMethods = map[string]struct{}{
-	MethodCreateTask:          {},
-	MethodUpdateTask:          {},
-	MethodDeleteTask:          {},
-	MethodCreateNote:          {},
-	MethodAppendChangelog:     {},
-	MethodRecordBenchmark:     {},
-	MethodStartRun:            {},
-	MethodFinishRun:           {},
-	MethodRecordPromptAttempt: {},
-	MethodRecordRunEvent:      {},
-	MethodSetPolicy:           {},
-	MethodUpsertPolicyCap:     {},
-	MethodDeletePolicyCap:     {},
+	MethodCreateTask:             {},
+	MethodUpdateTask:             {},
+	MethodDeleteTask:             {},
+	MethodCreateNote:             {},
+	MethodAppendChangelog:        {},
+	MethodRecordBenchmark:        {},
+	MethodStartRun:               {},
+	MethodFinishRun:              {},
+	MethodCancelRun:              {},
+	MethodStarRun:                {},
+	MethodShareRun:               {},
+	MethodRecordPromptAttempt:    {},
+	MethodRecordRunEvent:         {},
+	MethodRecordRunEvents:        {},
+	MethodImportTelemetry:        {},
+	MethodSetPolicy:              {},
+	MethodUpsertPolicyCap:        {},
+	MethodDeletePolicyCap:        {},
+	MethodRecordArtifact:         {},
+	MethodSetActivePromptVersion: {},
+	MethodRollbackPromptVersion:  {},
+	MethodPrune:                  {},
 }
 
 var PublicReadMethods = map[string]struct{}{
 	MethodGetHealth:           {},
+	MethodGetAPIInfo:          {},
 	MethodGetLeaderboard:      {},
 	MethodGetTelemetrySummary: {},
 }
@@ -71,24 +106,42 @@ var PrivateReadMethods = map[string]struct{}{
 	MethodListChangelog:      {},
 	MethodListBenchmarks:     {},
 	MethodListRuns:           {},
+	MethodGetRun:             {},
 	MethodListPromptAttempts: {},
 	MethodListRunEvents:      {},
 	MethodGetPolicy:          {},
 	MethodListPolicyCaps:     {},
+	MethodGetArtifact:        {},
+	MethodListArtifacts:      {},
+	MethodListPromptReleases: {},
+	MethodListPolicyAudit:    {},
+	MethodGetEffectiveLimits: {},
+	MethodRecommendModel:     {},
+	MethodGetRetryReport:     {},
 }
 
 var MethodScopes = map[string]string{
 	MethodGetSummary:         ScopeAdminRead,
-	MethodExportState:        ScopeAdminRead,
+	MethodExportState:        ScopeExport,
 	MethodListTasks:          ScopeAdminRead,
 	MethodListNotes:          ScopeAdminRead,
 	MethodListChangelog:      ScopeAdminRead,
 	MethodListBenchmarks:     ScopeAdminRead,
 	MethodListRuns:           ScopeAdminRead,
+	MethodGetRun:             ScopeAdminRead,
 	MethodListPromptAttempts: ScopeAdminRead,
 	MethodListRunEvents:      ScopeAdminRead,
 	MethodGetPolicy:          ScopeAdminRead,
 	MethodListPolicyCaps:     ScopeAdminRead,
+	MethodGetArtifact:        ScopeAdminRead,
+	MethodListArtifacts:      ScopeAdminRead,
+	MethodListPromptReleases: ScopeAdminRead,
+	MethodListPolicyAudit:    ScopeAdminRead,
+	MethodGetEffectiveLimits: ScopeAdminRead,
+	MethodRecommendModel:     ScopeAdminRead,
+	MethodGetRetryReport:     ScopeAdminRead,
+	// A share link hands out what the caller could already read.
+	MethodShareRun: ScopeAdminRead,
 
 	MethodCreateTask:      ScopeTasksWrite,
 	MethodUpdateTask:      ScopeTasksWrite,
@@ -99,12 +152,58 @@ var MethodScopes = map[string]string{
 	MethodRecordBenchmark:     ScopeTelemetryWrite,
 	MethodStartRun:            ScopeTelemetryWrite,
 	MethodFinishRun:           ScopeTelemetryWrite,
+	MethodCancelRun:           ScopeTelemetryWrite,
+	MethodStarRun:             ScopeTelemetryWrite,
 	MethodRecordPromptAttempt: ScopeTelemetryWrite,
 	MethodRecordRunEvent:      ScopeTelemetryWrite,
+	MethodRecordRunEvents:     ScopeTelemetryWrite,
+	MethodImportTelemetry:     ScopeTelemetryWrite,
+	MethodRecordArtifact:      ScopeTelemetryWrite,
+
+	MethodSetPolicy:              ScopePolicyWrite,
+	MethodUpsertPolicyCap:        ScopePolicyWrite,
+	MethodDeletePolicyCap:        ScopePolicyWrite,
+	MethodSetActivePromptVersion: ScopePolicyWrite,
+	MethodRollbackPromptVersion:  ScopePolicyWrite,
+	MethodPrune:                  ScopePolicyWrite,
+}
 
-	MethodSetPolicy:       ScopePolicyWrite,
-	MethodUpsertPolicyCap: ScopePolicyWrite,
-	MethodDeletePolicyCap: ScopePolicyWrite,
+// ProjectScopePrefix marks key scopes that pin a key to specific projects
+// (for example "project:payments"). "project:*" grants every project; keys
+// without any project scope are unrestricted.
+const ProjectScopePrefix = "project:"
+
+// ProjectScopedMethods accept a "project" request field. Keys pinned to
+// projects may only call these (plus public reads); hub-wide methods such as
+// ExportState, SetPolicy, notes, and changelog require an unrestricted key.
+var ProjectScopedMethods = map[string]struct{}{
+	MethodCreateTask:             {},
+	MethodUpdateTask:             {},
+	MethodDeleteTask:             {},
+	MethodListTasks:              {},
+	MethodStartRun:               {},
+	MethodFinishRun:              {},
+	MethodCancelRun:              {},
+	MethodStarRun:                {},
+	MethodShareRun:               {},
+	MethodListRuns:               {},
+	MethodGetRun:                 {},
+	MethodRecordPromptAttempt:    {},
+	MethodListPromptAttempts:     {},
+	MethodRecordRunEvent:         {},
+	MethodRecordRunEvents:        {},
+	MethodListRunEvents:          {},
+	MethodListPolicyCaps:         {},
+	MethodUpsertPolicyCap:        {},
+	MethodDeletePolicyCap:        {},
+	MethodRecordArtifact:         {},
+	MethodGetArtifact:            {},
+	MethodListArtifacts:          {},
+	MethodSetActivePromptVersion: {},
+	MethodRollbackPromptVersion:  {},
+	MethodListPromptReleases:     {},
+	MethodRecommendModel:         {},
+	MethodGetRetryReport:         {},
 }
 
 var DefaultAgentKeyScopes = []string{
@@ -126,3 +225,34 @@ func RequiredScope(fullMethod string) (string, bool) {
 	scope, ok := MethodScopes[fullMethod]
 	return scope, ok
 }
+
+// GrantsScope reports whether a key holding scope may call methods that
+// require required.
+func GrantsScope(scope, required string) bool {
+	scope = strings.TrimSpace(scope)
+	return scope == required || (scope == ScopeExportFull && required == ScopeExport)
+}
+
+// AllowedProjects extracts project grants from key scopes. restricted is false
+// when the key carries no project scopes or holds the "project:*" wildcard.
+func AllowedProjects(scopes []string) (projects []string, restricted bool) {
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !strings.HasPrefix(scope, ProjectScopePrefix) {
+			continue
+		}
+		project := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(scope, ProjectScopePrefix)))
+		if project == "*" {
+			return nil, false
+		}
+		if project != "" {
+			projects = append(projects, project)
+		}
+	}
+	return projects, len(projects) > 0
+}
+
+func IsProjectScoped(fullMethod string) bool {
+	_, ok := ProjectScopedMethods[fullMethod]
+	return ok
+}
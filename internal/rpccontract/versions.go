@@ -0,0 +1,29 @@
+package rpccontract
+
+import "slices"
+
+// APIVersion is the contract version this build serves. It changes only
+// when a method is removed or its request or response shape changes in a
+// way old clients cannot read.
+const APIVersion = "1"
+
+// SupportedAPIVersions are the versions a client may ask for, oldest first.
+var SupportedAPIVersions = []string{APIVersion}
+
+const (
+	// APIVersionHeader carries the version a client was built against on
+	// requests and the version the server speaks on every response.
+	APIVersionHeader = "x-modeloman-api-version"
+	// DeprecationHeader is set on responses from DeprecatedMethods to the
+	// method's deprecation note.
+	DeprecationHeader = "x-modeloman-deprecated"
+)
+
+// DeprecatedMethods maps methods scheduled for removal to a note naming the
+// replacement and the API version that drops them. Deprecated methods keep
+// working until then.
+var DeprecatedMethods = map[string]string{}
+
+func SupportsAPIVersion(version string) bool {
+	return slices.Contains(SupportedAPIVersions, version)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/notify"
+)
+
+// notifyKillSwitch alerts that the kill switch is blocking work. All
+// kill-switch alerts share one key, so the notifier sends at most one per
+// window however many calls are rejected.
+func (h *HubService) notifyKillSwitch(ctx context.Context, policy domain.OrchestrationPolicy, runID, message string, fields map[string]any) {
+	h.sendAlert(ctx, policy, runID, notify.Alert{Kind: "kill_switch", Message: message, Fields: fields})
+}
+
+// notifyKillSwitchEngaged alerts when a policy change turns the kill switch on.
+func (h *HubService) notifyKillSwitchEngaged(ctx context.Context, before, after domain.OrchestrationPolicy) {
+	if _, was := killSwitchEngaged(before); was {
+		return
+	}
+	if reason, now := killSwitchEngaged(after); now {
+		h.notifyKillSwitch(ctx, after, "", "kill switch engaged: "+reason, nil)
+	}
+}
+
+// notifyCapViolation alerts on an enforced cap rejecting an attempt. The
+// message names the limit and its source cap, so repeats against the same
+// cap collapse into one alert per window.
+func (h *HubService) notifyCapViolation(ctx context.Context, policy domain.OrchestrationPolicy, err error, runID, agentID, model string) {
+	var appErr *domain.AppError
+	if !errors.As(err, &appErr) || appErr.Code != domain.CodeResourceExhausted {
+		return
+	}
+	h.sendAlert(ctx, policy, runID, notify.Alert{
+		Kind:    "policy_cap_violation",
+		Key:     appErr.Message,
+		Message: appErr.Message,
+		Fields:  map[string]any{"run_id": runID, "agent_id": agentID, "model": model},
+	})
+}
+
+// sendAlert hands alert to the notifier unless one of the policy's alert
+// maintenance windows is open. A suppressed alert is logged instead and, when
+// it concerns a run, recorded as an alert_suppressed run event.
+func (h *HubService) sendAlert(ctx context.Context, policy domain.OrchestrationPolicy, runID string, alert notify.Alert) {
+	if h.notifier == nil {
+		return
+	}
+	window, open := activeMaintenanceWindow(policy.AlertMaintenanceWindows, time.Now())
+	if !open {
+		h.notifier.Notify(alert)
+		return
+	}
+	slog.InfoContext(ctx, "alert suppressed", "reason", maintenanceWindowReason(window), "kind", alert.Kind, "run_id", runID, "message", alert.Message)
+	if runID == "" {
+		return
+	}
+	serialized, _ := json.Marshal(map[string]any{
+		"kind":               alert.Kind,
+		"key":                alert.Key,
+		"fields":             alert.Fields,
+		"maintenance_window": window.Name,
+	})
+	_ = h.store.InsertRunEvent(ctx, domain.RunEvent{
+		ID:        newID("evt"),
+		RunID:     runID,
+		EventType: "alert_suppressed",
+		Level:     "info",
+		Message:   alert.Message,
+		DataJSON:  string(serialized),
+		RequestID: RequestIDFromContext(ctx),
+		CreatedAt: timeNow(),
+	})
+}
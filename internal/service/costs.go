@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const defaultCostsWindowDays = 30
+
+// Cost buckets: a UTC day, an ISO week keyed by its Monday, or a calendar
+// month keyed by its first day.
+const (
+	CostBucketDay   = "day"
+	CostBucketWeek  = "week"
+	CostBucketMonth = "month"
+)
+
+type CostsRequest struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	Provider string `json:"provider"`
+	// WindowDays counts back from today, including it; default 30.
+	WindowDays int64 `json:"window_days"`
+	// Bucket is day (the default), week or month.
+	Bucket string `json:"bucket"`
+}
+
+// CostReport is spend per time bucket, workflow and provider. Buckets lists
+// every bucket in the window, oldest first, including those without spend,
+// so charts can draw gaps.
+type CostReport struct {
+	Bucket  string              `json:"bucket"`
+	FromDay string              `json:"from_day"`
+	ToDay   string              `json:"to_day"`
+	Buckets []string            `json:"buckets"`
+	Points  []domain.CostBucket `json:"points"`
+}
+
+// Costs buckets attempt cost and tokens by workflow and provider. It reads
+// the daily rollups, so it costs the same however many attempts the window
+// holds.
+func (h *HubService) Costs(ctx context.Context, request CostsRequest) (CostReport, error) {
+	windowDays := request.WindowDays
+	if windowDays == 0 {
+		windowDays = defaultCostsWindowDays
+	}
+	if windowDays < 0 || windowDays > maxCostSeriesWindowDays {
+		return CostReport{}, domain.InvalidArgument(fmt.Sprintf("window_days must be between 1 and %d", maxCostSeriesWindowDays))
+	}
+	bucket := strings.ToLower(strings.TrimSpace(request.Bucket))
+	if bucket == "" {
+		bucket = CostBucketDay
+	}
+	if bucket != CostBucketDay && bucket != CostBucketWeek && bucket != CostBucketMonth {
+		return CostReport{}, domain.InvalidArgument("bucket must be day, week or month")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return CostReport{}, err
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -int(windowDays-1))
+	rollups, err := h.store.ListDailyRollups(ctx, domain.RollupFilter{
+		Project:  project,
+		Workflow: strings.TrimSpace(request.Workflow),
+		FromDay:  start.Format(time.DateOnly),
+	})
+	if err != nil {
+		return CostReport{}, err
+	}
+
+	provider := strings.TrimSpace(request.Provider)
+	grouped := map[string]*domain.CostBucket{}
+	for _, item := range rollups {
+		if provider != "" && item.Provider != provider {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, item.Day)
+		if err != nil {
+			continue
+		}
+		key := costBucketOf(day, bucket)
+		groupKey := strings.Join([]string{key, item.Workflow, item.ProviderType, item.Provider}, "|")
+		point, ok := grouped[groupKey]
+		if !ok {
+			point = &domain.CostBucket{
+				Bucket:       key,
+				Workflow:     item.Workflow,
+				ProviderType: item.ProviderType,
+				Provider:     item.Provider,
+			}
+			grouped[groupKey] = point
+		}
+		point.Attempts += item.Attempts
+		point.SuccessAttempts += item.SuccessAttempts
+		point.TokensIn += item.TokensIn
+		point.TokensOut += item.TokensOut
+		point.CostUSD += item.CostUSD
+	}
+
+	points := make([]domain.CostBucket, 0, len(grouped))
+	for _, point := range grouped {
+		points = append(points, *point)
+	}
+	slices.SortFunc(points, func(a, b domain.CostBucket) int {
+		if a.Bucket != b.Bucket {
+			return strings.Compare(a.Bucket, b.Bucket)
+		}
+		if a.Workflow != b.Workflow {
+			return strings.Compare(a.Workflow, b.Workflow)
+		}
+		if a.Provider != b.Provider {
+			return strings.Compare(a.Provider, b.Provider)
+		}
+		return strings.Compare(a.ProviderType, b.ProviderType)
+	})
+
+	buckets := []string{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if key := costBucketOf(day, bucket); len(buckets) == 0 || buckets[len(buckets)-1] != key {
+			buckets = append(buckets, key)
+		}
+	}
+	return CostReport{
+		Bucket:  bucket,
+		FromDay: start.Format(time.DateOnly),
+		ToDay:   end.Format(time.DateOnly),
+		Buckets: buckets,
+		Points:  points,
+	}, nil
+}
+
+// costBucketOf is the key of the bucket holding day: the day itself, the
+// Monday of its week, or the first of its month.
+func costBucketOf(day time.Time, bucket string) string {
+	switch bucket {
+	case CostBucketWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset).Format(time.DateOnly)
+	case CostBucketMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+	}
+	return day.Format(time.DateOnly)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// maxRunEventWait bounds ListRunEvents wait_seconds, so a watcher's call
+// never outlives typical proxy idle timeouts.
+const maxRunEventWait = 60 * time.Second
+
+// eventIDTimeLayout is the UTC time newID puts after "evt_", which makes
+// event IDs sort in the order they were recorded.
+const eventIDTimeLayout = "20060102T150405.000000000"
+
+// eventCursor is a ListRunEvents since_id/since_time position: it matches
+// events recorded after since_id and created after since_time.
+type eventCursor struct {
+	afterID   string
+	afterTime time.Time
+	// lowerBound is the created_after the store can apply for the cursor.
+	lowerBound time.Time
+}
+
+func parseEventCursor(sinceID, sinceTime string) (eventCursor, error) {
+	cursor := eventCursor{afterID: strings.TrimSpace(sinceID)}
+	if cursor.afterID != "" {
+		// Every event recorded after since_id is created no earlier than the
+		// time in its ID.
+		raw, ok := strings.CutPrefix(cursor.afterID, "evt_")
+		if !ok || len(raw) < len(eventIDTimeLayout) {
+			return eventCursor{}, domain.InvalidArgument("since_id must be a run event id")
+		}
+		recorded, err := time.Parse(eventIDTimeLayout, raw[:len(eventIDTimeLayout)])
+		if err != nil {
+			return eventCursor{}, domain.InvalidArgument("since_id must be a run event id")
+		}
+		cursor.lowerBound = recorded
+	}
+	if raw := strings.TrimSpace(sinceTime); raw != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return eventCursor{}, domain.InvalidArgument("since_time must be RFC3339 timestamp")
+		}
+		cursor.afterTime = parsed
+		if parsed.After(cursor.lowerBound) {
+			cursor.lowerBound = parsed
+		}
+	}
+	return cursor, nil
+}
+
+func (c eventCursor) set() bool {
+	return c.afterID != "" || !c.afterTime.IsZero()
+}
+
+// createdAfter narrows the request's created_after to the cursor.
+func (c eventCursor) createdAfter(createdAfter string) string {
+	if c.lowerBound.IsZero() {
+		return createdAfter
+	}
+	if createdAfter != "" {
+		if parsed, err := time.Parse(time.RFC3339Nano, createdAfter); err == nil && !parsed.Before(c.lowerBound) {
+			return createdAfter
+		}
+	}
+	return c.lowerBound.UTC().Format(time.RFC3339Nano)
+}
+
+func (c eventCursor) matches(event domain.RunEvent) bool {
+	if c.afterID != "" && event.ID <= c.afterID {
+		return false
+	}
+	if !c.afterTime.IsZero() {
+		created, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err != nil || !created.After(c.afterTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForRunEvents blocks until updates delivers events that filter and
+// cursor accept, or wait passes, and returns them newest first; filter's
+// limit is left to the caller. It returns an empty list on timeout. If the
+// subscription is dropped for falling behind, requery asks the store
+// instead.
+func (h *HubService) waitForRunEvents(ctx context.Context, updates <-chan LiveUpdate, wait time.Duration, filter domain.EventFilter, cursor eventCursor, requery func() ([]domain.RunEvent, error)) ([]domain.RunEvent, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	created := newCreatedRange(filter.CreatedAfter, filter.CreatedBefore)
+	accept := func(update LiveUpdate) bool {
+		event := update.Event
+		return event != nil &&
+			(filter.EventType == "" || event.EventType == filter.EventType) &&
+			(filter.Level == "" || event.Level == filter.Level) &&
+			created.contains(event.CreatedAt) &&
+			cursor.matches(*event)
+	}
+	var found []domain.RunEvent
+	for len(found) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return []domain.RunEvent{}, nil
+		case update, ok := <-updates:
+			if !ok {
+				return requery()
+			}
+			if accept(update) {
+				found = append(found, *update.Event)
+			}
+		}
+	}
+	// Take whatever else of the burst has already arrived.
+	for drained := false; !drained; {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				drained = true
+			} else if accept(update) {
+				found = append(found, *update.Event)
+			}
+		default:
+			drained = true
+		}
+	}
+	sortEventsNewestFirst(found)
+	return found, nil
+}
+
+func sortEventsNewestFirst(items []domain.RunEvent) {
+	slices.SortFunc(items, func(a, b domain.RunEvent) int {
+		if a.CreatedAt == b.CreatedAt {
+			return strings.Compare(b.ID, a.ID)
+		}
+		return strings.Compare(b.CreatedAt, a.CreatedAt)
+	})
+}
+
+// createdRange is an inclusive created_at range; zero ends are open.
+type createdRange struct {
+	after, before time.Time
+}
+
+func newCreatedRange(after, before string) createdRange {
+	var r createdRange
+	r.after, _ = time.Parse(time.RFC3339Nano, after)
+	r.before, _ = time.Parse(time.RFC3339Nano, before)
+	return r
+}
+
+func (r createdRange) contains(createdAt string) bool {
+	created, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return false
+	}
+	return (r.after.IsZero() || !created.Before(r.after)) && (r.before.IsZero() || !created.After(r.before))
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// External IDs are the caller's own identifiers for runs and tasks, such as
+// an orchestrator's job or ticket IDs. They are unique per project, so a
+// record can be found by them with ListRuns, ListTasks or GetRun without a
+// mapping table on the caller's side. The stores enforce the uniqueness;
+// the checks here only name the record that already holds the ID.
+
+const maxExternalIDLength = 200
+
+var idPrefixPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// idPrefixes are what generated run and task IDs start with, before the
+// timestamp and random suffix.
+type idPrefixes struct {
+	run  string
+	task string
+}
+
+// SetIDPrefixes makes generated run and task IDs start with run and task
+// instead of "run" and "task"; an empty prefix keeps the default. Prefixes
+// are lower-case letters, digits and dashes, starting with a letter.
+// Existing records keep their IDs.
+func (h *HubService) SetIDPrefixes(run, task string) error {
+	for _, prefix := range []string{run, task} {
+		if prefix != "" && !idPrefixPattern.MatchString(prefix) {
+			return domain.InvalidArgument(fmt.Sprintf("id prefix %q must match %s", prefix, idPrefixPattern))
+		}
+	}
+	h.idPrefixes = idPrefixes{run: run, task: task}
+	return nil
+}
+
+func (h *HubService) newRunID() string {
+	if h.idPrefixes.run != "" {
+		return newID(h.idPrefixes.run)
+	}
+	return newID("run")
+}
+
+func (h *HubService) newTaskID() string {
+	if h.idPrefixes.task != "" {
+		return newID(h.idPrefixes.task)
+	}
+	return newID("task")
+}
+
+// normalizeExternalID trims raw and rejects IDs too long or holding
+// control characters; "" means none.
+func normalizeExternalID(raw string) (string, error) {
+	externalID := strings.TrimSpace(raw)
+	if len(externalID) > maxExternalIDLength {
+		return "", domain.InvalidArgument(fmt.Sprintf("external_id must be at most %d bytes", maxExternalIDLength))
+	}
+	if strings.ContainsFunc(externalID, unicode.IsControl) {
+		return "", domain.InvalidArgument("external_id must not contain control characters")
+	}
+	return externalID, nil
+}
+
+// checkRunExternalID fails with Conflict when another run in project holds
+// externalID.
+func (h *HubService) checkRunExternalID(ctx context.Context, project, externalID string) error {
+	if externalID == "" {
+		return nil
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, ExternalID: externalID, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(runs) > 0 {
+		return domain.Conflict(fmt.Sprintf("external_id %q is already used by run %s", externalID, runs[0].ID))
+	}
+	return nil
+}
+
+// checkTaskExternalID fails with Conflict when a task other than taskID in
+// project holds externalID.
+func (h *HubService) checkTaskExternalID(ctx context.Context, project, externalID, taskID string) error {
+	if externalID == "" {
+		return nil
+	}
+	tasks, err := h.store.ListTasksFiltered(ctx, domain.TaskFilter{Project: project, ExternalID: externalID, IncludeArchived: true, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 0 && tasks[0].ID != taskID {
+		return domain.Conflict(fmt.Sprintf("external_id %q is already used by task %s", externalID, tasks[0].ID))
+	}
+	return nil
+}
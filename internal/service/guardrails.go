@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ListGuardrails bounds what one list request may read. MaxLimit caps the
+// rows returned, including for requests that send no limit; MaxWindow caps
+// how far back an unscoped runs, attempts or events listing reaches. Zero
+// turns either off.
+type ListGuardrails struct {
+	MaxLimit  int64
+	MaxWindow time.Duration
+}
+
+// SetListGuardrails applies g to every list method. Clamped requests still
+// succeed; the adjustment is reported through the warnings on ctx.
+func (h *HubService) SetListGuardrails(g ListGuardrails) {
+	h.guardrails = g
+}
+
+type warningsKey struct{}
+
+type warningSink struct {
+	mu    sync.Mutex
+	items []string
+}
+
+// WithWarnings returns a context that collects the warnings a request
+// raises, and a function returning them once the request is done.
+func WithWarnings(ctx context.Context) (context.Context, func() []string) {
+	sink := &warningSink{}
+	return context.WithValue(ctx, warningsKey{}, sink), func() []string {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return append([]string(nil), sink.items...)
+	}
+}
+
+// addWarning records message on ctx's collector. Without one, as for calls
+// made inside the server, the warning is dropped.
+func addWarning(ctx context.Context, message string) {
+	sink, ok := ctx.Value(warningsKey{}).(*warningSink)
+	if !ok {
+		return
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.items = append(sink.items, message)
+}
+
+// storeLimit is the limit to pass to the store for requested. Over the cap,
+// or with no limit, it asks for one row more than MaxLimit so clampItems can
+// tell whether anything was cut.
+func (h *HubService) storeLimit(requested int64) int64 {
+	maxLimit := h.guardrails.MaxLimit
+	if maxLimit <= 0 || (requested > 0 && requested <= maxLimit) {
+		return requested
+	}
+	return maxLimit + 1
+}
+
+// clampItems keeps the first MaxLimit of the sorted items and warns when
+// that drops any.
+func clampItems[T any](ctx context.Context, h *HubService, items []T, noun string) []T {
+	maxLimit := h.guardrails.MaxLimit
+	if maxLimit <= 0 || int64(len(items)) <= maxLimit {
+		return items
+	}
+	addWarning(ctx, fmt.Sprintf("results truncated to the server maximum of %d %s; narrow the filters or page with a time range", maxLimit, noun))
+	return items[:maxLimit]
+}
+
+// clampWindow moves after forward so the range ends no more than MaxWindow
+// after it, counting back from before or from now. field names the request
+// field in the warning.
+func (h *HubService) clampWindow(ctx context.Context, after, before, field string) string {
+	window := h.guardrails.MaxWindow
+	if window <= 0 {
+		return after
+	}
+	end := time.Now().UTC()
+	if before != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			return after
+		}
+		end = parsed.UTC()
+	}
+	earliest := end.Add(-window)
+	if after != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil || !parsed.Before(earliest) {
+			return after
+		}
+	}
+	clamped := earliest.Format(time.RFC3339Nano)
+	addWarning(ctx, fmt.Sprintf("%s raised to %s, the server maximum window of %s", field, clamped, formatWindow(window)))
+	return clamped
+}
+
+func formatWindow(window time.Duration) string {
+	if window%(24*time.Hour) == 0 {
+		days := int64(window / (24 * time.Hour))
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return window.String()
+}
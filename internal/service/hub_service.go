@@ -1,23 +1,54 @@
 package service
 
 import (
+	"cmp"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/notify"
 	"github.com/bcrosbie/modeloman/internal/store"
+	"github.com/bcrosbie/modeloman/internal/tracing"
+)
+
+const (
+	maxMetadataEntries     = 32
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 256
+
+	maxRunNameLength        = 200
+	maxRunDescriptionLength = 4000
+
+	defaultCostSeriesWindowDays = 14
+	maxCostSeriesWindowDays     = 366
+
+	defaultLiveRunStaleSeconds = 300
+	defaultLiveRunLimit        = 100
+	maxLiveRunLimit            = 500
+
+	maxProjectNameLength = 64
+
+	defaultCanaryRollbackMargin = 0.1
+	defaultCanaryMinRuns        = 10
 )
 
 var (
-	validTaskStatuses     = map[string]struct{}{"todo": {}, "in_progress": {}, "done": {}, "blocked": {}}
+	validTaskStatuses     = map[string]struct{}{"todo": {}, "in_progress": {}, "done": {}, "blocked": {}, domain.TaskStatusArchived: {}}
 	validProviderTypes    = map[string]struct{}{"api": {}, "subscription": {}, "opensource": {}}
 	validRunStatuses      = map[string]struct{}{"running": {}, "completed": {}, "failed": {}, "cancelled": {}}
 	validAttemptOutcomes  = map[string]struct{}{"success": {}, "failed": {}, "timeout": {}, "retryable_error": {}, "tool_error": {}}
 	validEventLevels      = map[string]struct{}{"info": {}, "warn": {}, "error": {}}
+	validArtifactKinds    = map[string]struct{}{"diff": {}, "transcript": {}, "file": {}, "log": {}, "other": {}}
 	validChangeCategories = map[string]struct{}{
 		"platform": {},
 		"policy":   {},
@@ -28,8 +59,30 @@ var (
 )
 
 type HubService struct {
-	store      store.HubStore
-	dataSource string
+	store            store.HubStore
+	dataSource       string
+	artifacts        store.ArtifactBlobStore
+	maxArtifactBytes int64
+	retention        RetentionPolicy
+	archiveAfterDays int64
+	ingest           *ingestStore
+	notifier         *notify.Notifier
+	guardrails       ListGuardrails
+	panics           panicCounts
+	live             liveHub
+	shares           *shareSigner
+	idPrefixes       idPrefixes
+	policyValidation PolicyValidation
+	// policyMu serializes policy and cap writes, each of which checks the
+	// record it read is still current; see policyLock.
+	policyMu sync.Mutex
+}
+
+// RetentionPolicy is how many days of run events and prompt attempts to keep;
+// 0 keeps them forever.
+type RetentionPolicy struct {
+	RunEventsDays int64
+	AttemptsDays  int64
 }
 
 func NewHubService(store store.HubStore, dataSource string) *HubService {
@@ -39,30 +92,69 @@ func NewHubService(store store.HubStore, dataSource string) *HubService {
 	}
 }
 
+// EnableArtifacts attaches blob storage for RecordArtifact/GetArtifact.
+// Artifact RPCs fail with FailedPrecondition until this is called.
+func (h *HubService) EnableArtifacts(blobs store.ArtifactBlobStore, maxBytes int64) {
+	h.artifacts = blobs
+	h.maxArtifactBytes = maxBytes
+}
+
+// EnableNotifications sends kill-switch and policy-cap alerts to n.
+func (h *HubService) EnableNotifications(n *notify.Notifier) {
+	h.notifier = n
+}
+
+// SetRetention sets the defaults for Prune and PruneExpired.
+func (h *HubService) SetRetention(policy RetentionPolicy) {
+	h.retention = policy
+}
+
+// minArchiveAfterDays keeps the 30-day budget window, the longest one policy
+// checks read, in the hot tables.
+const minArchiveAfterDays = 31
+
+// SetArchiving moves attempts and run events older than days to cold storage
+// on each ArchiveExpired; 0 turns it off.
+func (h *HubService) SetArchiving(days int64) error {
+	if days != 0 && days < minArchiveAfterDays {
+		return domain.InvalidArgument(fmt.Sprintf("archive_after_days must be 0 or at least %d so budget checks still see a full 30-day window", minArchiveAfterDays))
+	}
+	h.archiveAfterDays = days
+	return nil
+}
+
 type writeRequest struct {
 	IdempotencyKey string `json:"idempotency_key"`
 }
 
 type CreateTaskRequest struct {
 	writeRequest
-	Title   string   `json:"title"`
-	Details string   `json:"details"`
-	Status  string   `json:"status"`
-	Tags    []string `json:"tags"`
+	Project    string   `json:"project"`
+	Title      string   `json:"title"`
+	Details    string   `json:"details"`
+	Status     string   `json:"status"`
+	Tags       []string `json:"tags"`
+	ExternalID string   `json:"external_id"`
 }
 
 type UpdateTaskRequest struct {
 	writeRequest
+	Project string   `json:"project"`
 	ID      string   `json:"id"`
 	Title   string   `json:"title"`
 	Details string   `json:"details"`
 	Status  string   `json:"status"`
 	Tags    []string `json:"tags"`
+	// ExternalID, when set, replaces the task's external ID.
+	ExternalID string `json:"external_id"`
 }
 
+// DeleteTaskRequest archives a task; Purge removes it for good instead.
 type DeleteTaskRequest struct {
 	writeRequest
-	ID string `json:"id"`
+	Project string `json:"project"`
+	ID      string `json:"id"`
+	Purge   bool   `json:"purge"`
 }
 
 type CreateNoteRequest struct {
@@ -96,44 +188,71 @@ type RecordBenchmarkRequest struct {
 
 type StartRunRequest struct {
 	writeRequest
-	TaskID        string `json:"task_id"`
-	Workflow      string `json:"workflow"`
-	AgentID       string `json:"agent_id"`
-	PromptVersion string `json:"prompt_version"`
-	ModelPolicy   string `json:"model_policy"`
-	MaxRetries    int64  `json:"max_retries"`
+	Project       string            `json:"project"`
+	TaskID        string            `json:"task_id"`
+	Workflow      string            `json:"workflow"`
+	AgentID       string            `json:"agent_id"`
+	PromptVersion string            `json:"prompt_version"`
+	ModelPolicy   string            `json:"model_policy"`
+	MaxRetries    int64             `json:"max_retries"`
+	Metadata      map[string]string `json:"metadata"`
+	// Name and Description are free text shown in run lists and the
+	// dashboard in place of the bare run ID.
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// ExternalID is the caller's own ID for the run, unique per project.
+	ExternalID string `json:"external_id"`
 }
 
 type FinishRunRequest struct {
 	writeRequest
+	Project   string `json:"project"`
 	RunID     string `json:"run_id"`
 	Status    string `json:"status"`
 	LastError string `json:"last_error"`
 }
 
+type CancelRunRequest struct {
+	writeRequest
+	Project string `json:"project"`
+	RunID   string `json:"run_id"`
+	Reason  string `json:"reason"`
+}
+
+type StarRunRequest struct {
+	writeRequest
+	Project string `json:"project"`
+	RunID   string `json:"run_id"`
+	// Starred defaults to true; false removes the star.
+	Starred *bool `json:"starred"`
+}
+
 type RecordPromptAttemptRequest struct {
 	writeRequest
-	RunID         string  `json:"run_id"`
-	AttemptNumber int64   `json:"attempt_number"`
-	Workflow      string  `json:"workflow"`
-	AgentID       string  `json:"agent_id"`
-	ProviderType  string  `json:"provider_type"`
-	Provider      string  `json:"provider"`
-	Model         string  `json:"model"`
-	PromptVersion string  `json:"prompt_version"`
-	PromptHash    string  `json:"prompt_hash"`
-	Outcome       string  `json:"outcome"`
-	ErrorType     string  `json:"error_type"`
-	ErrorMessage  string  `json:"error_message"`
-	TokensIn      int64   `json:"tokens_in"`
-	TokensOut     int64   `json:"tokens_out"`
-	CostUSD       float64 `json:"cost_usd"`
-	LatencyMS     int64   `json:"latency_ms"`
-	QualityScore  float64 `json:"quality_score"`
+	Project       string            `json:"project"`
+	RunID         string            `json:"run_id"`
+	AttemptNumber int64             `json:"attempt_number"`
+	Workflow      string            `json:"workflow"`
+	AgentID       string            `json:"agent_id"`
+	ProviderType  string            `json:"provider_type"`
+	Provider      string            `json:"provider"`
+	Model         string            `json:"model"`
+	PromptVersion string            `json:"prompt_version"`
+	PromptHash    string            `json:"prompt_hash"`
+	Outcome       string            `json:"outcome"`
+	ErrorType     string            `json:"error_type"`
+	ErrorMessage  string            `json:"error_message"`
+	TokensIn      int64             `json:"tokens_in"`
+	TokensOut     int64             `json:"tokens_out"`
+	CostUSD       float64           `json:"cost_usd"`
+	LatencyMS     int64             `json:"latency_ms"`
+	QualityScore  float64           `json:"quality_score"`
+	Metadata      map[string]string `json:"metadata"`
 }
 
 type RecordRunEventRequest struct {
 	writeRequest
+	Project   string `json:"project"`
 	RunID     string `json:"run_id"`
 	EventType string `json:"event_type"`
 	Level     string `json:"level"`
@@ -141,52 +260,185 @@ type RecordRunEventRequest struct {
 	DataJSON  string `json:"data_json"`
 }
 
+// RecordRunEventsRequest records up to maxRunEventBatch events in one call.
+// Events take the batch's project; their own project field is ignored.
+// CreatedAt, for backfills, keeps an event's original RFC3339 time.
+type RecordRunEventsRequest struct {
+	writeRequest
+	Project string          `json:"project"`
+	Events  []RunEventInput `json:"events"`
+}
+
+type RunEventInput struct {
+	RecordRunEventRequest
+	CreatedAt string `json:"created_at"`
+}
+
+type RecordRunEventsResult struct {
+	Recorded int `json:"recorded"`
+}
+
+type RecordArtifactRequest struct {
+	writeRequest
+	Project       string `json:"project"`
+	RunID         string `json:"run_id"`
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`
+	ContentType   string `json:"content_type"`
+	Content       string `json:"content"`
+	ContentBase64 string `json:"content_base64"`
+}
+
+type GetArtifactRequest struct {
+	Project string `json:"project"`
+	ID      string `json:"id"`
+}
+
+type ListArtifactsRequest struct {
+	Project string `json:"project"`
+	RunID   string `json:"run_id"`
+	Kind    string `json:"kind"`
+	Limit   int64  `json:"limit"`
+}
+
+type SetActivePromptVersionRequest struct {
+	writeRequest
+	Project       string `json:"project"`
+	Workflow      string `json:"workflow"`
+	PromptVersion string `json:"prompt_version"`
+	Actor         string `json:"actor"`
+	Reason        string `json:"reason"`
+	// CanaryPercent between 1 and 99 starts a gradual rollout instead of an
+	// immediate switch; 0 or 100 pins the version for every run.
+	CanaryPercent  int64   `json:"canary_percent"`
+	RollbackMargin float64 `json:"rollback_margin"`
+	MinRuns        int64   `json:"min_runs"`
+}
+
+type RollbackPromptVersionRequest struct {
+	writeRequest
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	Actor    string `json:"actor"`
+	Reason   string `json:"reason"`
+}
+
+type ListPromptReleasesRequest struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	Limit    int64  `json:"limit"`
+}
+
+// AuditActor identifies who made a policy change. Transports fill it from the
+// authenticated principal; it is never decoded from the request body.
+type AuditActor struct {
+	AgentID string
+	KeyID   string
+}
+
 type SetPolicyRequest struct {
 	writeRequest
-	KillSwitch             *bool    `json:"kill_switch"`
-	KillSwitchReason       *string  `json:"kill_switch_reason"`
-	MaxCostPerRunUSD       *float64 `json:"max_cost_per_run_usd"`
-	MaxAttemptsPerRun      *int64   `json:"max_attempts_per_run"`
-	MaxTokensPerRun        *int64   `json:"max_tokens_per_run"`
-	MaxLatencyPerAttemptMS *int64   `json:"max_latency_per_attempt_ms"`
+	Actor                  AuditActor `json:"-"`
+	KillSwitch             *bool      `json:"kill_switch"`
+	KillSwitchReason       *string    `json:"kill_switch_reason"`
+	MaxCostPerRunUSD       *float64   `json:"max_cost_per_run_usd"`
+	MaxAttemptsPerRun      *int64     `json:"max_attempts_per_run"`
+	MaxTokensPerRun        *int64     `json:"max_tokens_per_run"`
+	MaxLatencyPerAttemptMS *int64     `json:"max_latency_per_attempt_ms"`
+	MaxCostPerHourUSD      *float64   `json:"max_cost_per_hour_usd"`
+	MaxCostPerDayUSD       *float64   `json:"max_cost_per_day_usd"`
+	// MaintenanceWindows replaces the whole schedule when present.
+	MaintenanceWindows *[]domain.MaintenanceWindow `json:"maintenance_windows"`
+	// AlertMaintenanceWindows replaces the alert silence schedule when present.
+	AlertMaintenanceWindows *[]domain.MaintenanceWindow `json:"alert_maintenance_windows"`
 }
 
 type UpsertPolicyCapRequest struct {
 	writeRequest
-	ID                     string   `json:"id"`
-	Name                   string   `json:"name"`
-	ProviderType           string   `json:"provider_type"`
-	Provider               string   `json:"provider"`
-	Model                  string   `json:"model"`
-	MaxCostPerRunUSD       *float64 `json:"max_cost_per_run_usd"`
-	MaxAttemptsPerRun      *int64   `json:"max_attempts_per_run"`
-	MaxTokensPerRun        *int64   `json:"max_tokens_per_run"`
-	MaxCostPerAttemptUSD   *float64 `json:"max_cost_per_attempt_usd"`
-	MaxTokensPerAttempt    *int64   `json:"max_tokens_per_attempt"`
-	MaxLatencyPerAttemptMS *int64   `json:"max_latency_per_attempt_ms"`
-	Priority               *int64   `json:"priority"`
-	DryRun                 *bool    `json:"dry_run"`
-	IsActive               *bool    `json:"is_active"`
+	Actor                  AuditActor `json:"-"`
+	Project                string     `json:"project"`
+	ID                     string     `json:"id"`
+	Name                   string     `json:"name"`
+	ProviderType           string     `json:"provider_type"`
+	Provider               string     `json:"provider"`
+	Model                  string     `json:"model"`
+	Workflow               string     `json:"workflow"`
+	AgentID                string     `json:"agent_id"`
+	MaxCostPerRunUSD       *float64   `json:"max_cost_per_run_usd"`
+	MaxAttemptsPerRun      *int64     `json:"max_attempts_per_run"`
+	MaxTokensPerRun        *int64     `json:"max_tokens_per_run"`
+	MaxCostPerAttemptUSD   *float64   `json:"max_cost_per_attempt_usd"`
+	MaxTokensPerAttempt    *int64     `json:"max_tokens_per_attempt"`
+	MaxLatencyPerAttemptMS *int64     `json:"max_latency_per_attempt_ms"`
+	MaxCostPerDayUSD       *float64   `json:"max_cost_per_day_usd"`
+	MaxCostPerMonthUSD     *float64   `json:"max_cost_per_month_usd"`
+	Priority               *int64     `json:"priority"`
+	DryRun                 *bool      `json:"dry_run"`
+	IsActive               *bool      `json:"is_active"`
+	ValidFrom              *string    `json:"valid_from"`
+	ValidUntil             *string    `json:"valid_until"`
+	// Kind is "limit" or "routing"; Routing applies only to routing caps.
+	Kind    *string                   `json:"kind"`
+	Routing *domain.RoutingPreference `json:"routing"`
 }
 
 type DeletePolicyCapRequest struct {
 	writeRequest
-	ID string `json:"id"`
+	Actor   AuditActor `json:"-"`
+	Project string     `json:"project"`
+	ID      string     `json:"id"`
+}
+
+// PruneRequest overrides the server's retention days for one pass; omitted
+// fields use the configured policy and 0 skips that table.
+type PruneRequest struct {
+	writeRequest
+	RunEventsRetentionDays *int64 `json:"run_events_retention_days"`
+	AttemptsRetentionDays  *int64 `json:"attempts_retention_days"`
+}
+
+type ListPolicyAuditRequest struct {
+	Project    string `json:"project"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Limit      int64  `json:"limit"`
+}
+
+type ListPolicyCapsRequest struct {
+	Project string `json:"project"`
+}
+
+type ListTasksRequest struct {
+	Project         string   `json:"project"`
+	Status          string   `json:"status"`
+	Tags            []string `json:"tags"`
+	Query           string   `json:"query"`
+	IncludeArchived bool     `json:"include_archived"`
+	Limit           int64    `json:"limit"`
+	// ExternalID finds the task with that external ID, archived or not.
+	ExternalID string `json:"external_id"`
 }
 
 type ListRunsRequest struct {
-	RunID         string `json:"run_id"`
-	TaskID        string `json:"task_id"`
-	Workflow      string `json:"workflow"`
-	AgentID       string `json:"agent_id"`
-	Status        string `json:"status"`
-	PromptVersion string `json:"prompt_version"`
-	StartedAfter  string `json:"started_after"`
-	StartedBefore string `json:"started_before"`
-	Limit         int64  `json:"limit"`
+	Project       string            `json:"project"`
+	RunID         string            `json:"run_id"`
+	ExternalID    string            `json:"external_id"`
+	TaskID        string            `json:"task_id"`
+	Workflow      string            `json:"workflow"`
+	AgentID       string            `json:"agent_id"`
+	Status        string            `json:"status"`
+	PromptVersion string            `json:"prompt_version"`
+	StartedAfter  string            `json:"started_after"`
+	StartedBefore string            `json:"started_before"`
+	Labels        map[string]string `json:"labels"`
+	Limit         int64             `json:"limit"`
+	// Starred lists starred runs only, from any time unless started_after
+	// is given.
+	Starred bool `json:"starred"`
 }
 
 type ListPromptAttemptsRequest struct {
+	Project       string `json:"project"`
 	RunID         string `json:"run_id"`
 	Workflow      string `json:"workflow"`
 	AgentID       string `json:"agent_id"`
@@ -196,18 +448,30 @@ type ListPromptAttemptsRequest struct {
 	CreatedAfter  string `json:"created_after"`
 	CreatedBefore string `json:"created_before"`
 	Limit         int64  `json:"limit"`
+	// IncludeArchived also returns attempts moved to cold storage.
+	IncludeArchived bool `json:"include_archived"`
 }
 
 type ListRunEventsRequest struct {
+	Project       string `json:"project"`
 	RunID         string `json:"run_id"`
 	EventType     string `json:"event_type"`
 	Level         string `json:"level"`
 	CreatedAfter  string `json:"created_after"`
 	CreatedBefore string `json:"created_before"`
 	Limit         int64  `json:"limit"`
+	// IncludeArchived also returns events moved to cold storage.
+	IncludeArchived bool `json:"include_archived"`
+	// SinceID and SinceTime return only events recorded after the event
+	// SinceID and created after SinceTime. With WaitSeconds, a call that
+	// finds none waits up to that long (at most 60) for new ones.
+	SinceID     string `json:"since_id"`
+	SinceTime   string `json:"since_time"`
+	WaitSeconds int64  `json:"wait_seconds"`
 }
 
 type LeaderboardRequest struct {
+	Project       string `json:"project"`
 	Workflow      string `json:"workflow"`
 	Model         string `json:"model"`
 	PromptVersion string `json:"prompt_version"`
@@ -215,37 +479,102 @@ type LeaderboardRequest struct {
 	Limit         int64  `json:"limit"`
 }
 
-type effectiveLimits struct {
-	MaxCostPerRunUSD       float64
-	MaxAttemptsPerRun      int64
-	MaxTokensPerRun        int64
-	MaxLatencyPerAttemptMS int64
-	MaxCostPerAttemptUSD   float64
-	MaxTokensPerAttempt    int64
-	Source                 string
+type ListLiveRunsRequest struct {
+	Project           string `json:"project"`
+	StaleAfterSeconds int64  `json:"stale_after_seconds"`
+	Limit             int64  `json:"limit"`
+}
+
+type CostSeriesRequest struct {
+	Project    string `json:"project"`
+	Workflow   string `json:"workflow"`
+	WindowDays int64  `json:"window_days"`
+}
+
+type GetEffectiveLimitsRequest struct {
+	Project      string `json:"project"`
+	Workflow     string `json:"workflow"`
+	AgentID      string `json:"agent_id"`
+	ProviderType string `json:"provider_type"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+}
+
+// EffectiveLimits is the global policy merged with the cap that would apply
+// to an attempt; Source names the cap or "global-policy".
+type EffectiveLimits struct {
+	MaxCostPerRunUSD       float64 `json:"max_cost_per_run_usd"`
+	MaxAttemptsPerRun      int64   `json:"max_attempts_per_run"`
+	MaxTokensPerRun        int64   `json:"max_tokens_per_run"`
+	MaxLatencyPerAttemptMS int64   `json:"max_latency_per_attempt_ms"`
+	MaxCostPerAttemptUSD   float64 `json:"max_cost_per_attempt_usd"`
+	MaxTokensPerAttempt    int64   `json:"max_tokens_per_attempt"`
+	MaxCostPerDayUSD       float64 `json:"max_cost_per_day_usd"`
+	MaxCostPerMonthUSD     float64 `json:"max_cost_per_month_usd"`
+	DryRun                 bool    `json:"dry_run"`
+	Source                 string  `json:"source"`
 }
 
 func (h *HubService) Health() map[string]any {
-	return map[string]any{
+	out := map[string]any{
 		"status":      "ok",
 		"data_source": h.dataSource,
 		"time_utc":    time.Now().UTC().Format(time.RFC3339Nano),
 	}
+	backing := h.store
+	if h.ingest != nil {
+		backing = h.ingest.HubStore
+	}
+	if reporter, ok := backing.(store.PoolStatsReporter); ok {
+		out["database_pools"] = reporter.PoolStats()
+	}
+	return out
+}
+
+// redactedValue replaces sensitive fields in exports made without full
+// access. Empty fields stay empty.
+const redactedValue = "[redacted]"
+
+// ExportState returns every record in the hub. Unless full is set, attempt
+// prompt hashes, attempt and run error messages, and note bodies are
+// replaced with redactedValue.
+func (h *HubService) ExportState(ctx context.Context, full bool) (domain.State, error) {
+	state, err := h.store.ExportState(ctx)
+	if err != nil || full {
+		return state, err
+	}
+	for i := range state.Attempts {
+		state.Attempts[i].PromptHash = redact(state.Attempts[i].PromptHash)
+		state.Attempts[i].ErrorMessage = redact(state.Attempts[i].ErrorMessage)
+	}
+	for i := range state.Runs {
+		state.Runs[i].LastError = redact(state.Runs[i].LastError)
+	}
+	for i := range state.Notes {
+		state.Notes[i].Body = redact(state.Notes[i].Body)
+	}
+	return state, nil
 }
 
-func (h *HubService) ExportState() (domain.State, error) {
-	return h.store.ExportState()
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
 }
 
-func (h *HubService) GetPolicy() (domain.OrchestrationPolicy, error) {
-	return h.store.GetPolicy()
+func (h *HubService) GetPolicy(ctx context.Context) (domain.OrchestrationPolicy, error) {
+	return h.store.GetPolicy(ctx)
 }
 
-func (h *HubService) SetPolicy(request SetPolicyRequest) (domain.OrchestrationPolicy, error) {
-	policy, err := h.store.GetPolicy()
+func (h *HubService) SetPolicy(ctx context.Context, request SetPolicyRequest) (domain.OrchestrationPolicy, error) {
+	lock := h.lockPolicyChange()
+	defer lock.unlock()
+	policy, err := h.store.GetPolicy(ctx)
 	if err != nil {
 		return domain.OrchestrationPolicy{}, err
 	}
+	before := policy
 
 	if request.KillSwitch != nil {
 		policy.KillSwitch = *request.KillSwitch
@@ -277,19 +606,93 @@ func (h *HubService) SetPolicy(request SetPolicyRequest) (domain.OrchestrationPo
 		}
 		policy.MaxLatencyPerAttemptMS = *request.MaxLatencyPerAttemptMS
 	}
+	if request.MaxCostPerHourUSD != nil {
+		if *request.MaxCostPerHourUSD < 0 {
+			return domain.OrchestrationPolicy{}, domain.InvalidArgument("max_cost_per_hour_usd must be non-negative")
+		}
+		policy.MaxCostPerHourUSD = *request.MaxCostPerHourUSD
+	}
+	if request.MaxCostPerDayUSD != nil {
+		if *request.MaxCostPerDayUSD < 0 {
+			return domain.OrchestrationPolicy{}, domain.InvalidArgument("max_cost_per_day_usd must be non-negative")
+		}
+		policy.MaxCostPerDayUSD = *request.MaxCostPerDayUSD
+	}
+	if request.MaintenanceWindows != nil {
+		windows, err := normalizeMaintenanceWindows(*request.MaintenanceWindows)
+		if err != nil {
+			return domain.OrchestrationPolicy{}, err
+		}
+		policy.MaintenanceWindows = windows
+	}
+	if request.AlertMaintenanceWindows != nil {
+		windows, err := normalizeMaintenanceWindows(*request.AlertMaintenanceWindows)
+		if err != nil {
+			return domain.OrchestrationPolicy{}, err
+		}
+		policy.AlertMaintenanceWindows = windows
+	}
+	applyScheduledKillSwitch(&policy, time.Now())
 
 	policy.UpdatedAt = timeNow()
-	if err := h.store.SetPolicy(policy); err != nil {
+	if err := h.validatePolicyChange(ctx, lock, "policy", "", "", "set", request.Actor, before, policy, h.currentPolicy); err != nil {
+		return domain.OrchestrationPolicy{}, err
+	}
+	if err := h.store.SetPolicy(ctx, policy); err != nil {
 		return domain.OrchestrationPolicy{}, err
 	}
-	return h.store.GetPolicy()
+	after, err := h.store.GetPolicy(ctx)
+	if err != nil {
+		return domain.OrchestrationPolicy{}, err
+	}
+	if err := h.recordPolicyAudit(ctx, "policy", "", "", "set", request.Actor, before, after); err != nil {
+		return domain.OrchestrationPolicy{}, err
+	}
+	h.notifyKillSwitchEngaged(ctx, before, after)
+	return after, nil
+}
+
+// EvaluatePolicySchedule opens or closes the scheduled kill switch according
+// to the policy's maintenance windows. The server calls it periodically; it
+// only writes when the effective state changes.
+func (h *HubService) EvaluatePolicySchedule(ctx context.Context, now time.Time) (domain.OrchestrationPolicy, bool, error) {
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	policy, err := h.store.GetPolicy(ctx)
+	if err != nil {
+		return domain.OrchestrationPolicy{}, false, err
+	}
+	before := policy
+	if !applyScheduledKillSwitch(&policy, now) {
+		return policy, false, nil
+	}
+	policy.UpdatedAt = timeNow()
+	if err := h.store.SetPolicy(ctx, policy); err != nil {
+		return domain.OrchestrationPolicy{}, false, err
+	}
+	if err := h.recordPolicyAudit(ctx, "policy", "", "", "schedule", AuditActor{AgentID: "policy-scheduler"}, before, policy); err != nil {
+		return domain.OrchestrationPolicy{}, false, err
+	}
+	h.notifyKillSwitchEngaged(ctx, before, policy)
+	return policy, true, nil
 }
 
-func (h *HubService) ListPolicyCaps() ([]domain.PolicyCap, error) {
-	items, err := h.store.ListPolicyCaps()
+// ListPolicyCaps returns caps visible to the project: its own plus hub-wide caps.
+func (h *HubService) ListPolicyCaps(ctx context.Context, request ListPolicyCapsRequest) ([]domain.PolicyCap, error) {
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	all, err := h.store.ListPolicyCaps(ctx)
 	if err != nil {
 		return nil, err
 	}
+	items := make([]domain.PolicyCap, 0, len(all))
+	for _, item := range all {
+		if project == "" || item.Project == "" || item.Project == project {
+			items = append(items, item)
+		}
+	}
 	slices.SortFunc(items, func(a, b domain.PolicyCap) int {
 		if a.Priority == b.Priority {
 			return strings.Compare(a.ID, b.ID)
@@ -302,7 +705,13 @@ func (h *HubService) ListPolicyCaps() ([]domain.PolicyCap, error) {
 	return items, nil
 }
 
-func (h *HubService) UpsertPolicyCap(request UpsertPolicyCapRequest) (domain.PolicyCap, error) {
+func (h *HubService) UpsertPolicyCap(ctx context.Context, request UpsertPolicyCapRequest) (domain.PolicyCap, error) {
+	lock := h.lockPolicyChange()
+	defer lock.unlock()
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.PolicyCap{}, err
+	}
 	id := strings.TrimSpace(request.ID)
 	if id == "" {
 		id = newID("cap")
@@ -316,22 +725,30 @@ func (h *HubService) UpsertPolicyCap(request UpsertPolicyCapRequest) (domain.Pol
 
 	current := domain.PolicyCap{
 		ID:           id,
+		Project:      project,
 		Name:         strings.TrimSpace(request.Name),
 		ProviderType: providerType,
 		Provider:     strings.TrimSpace(request.Provider),
 		Model:        strings.TrimSpace(request.Model),
+		Workflow:     strings.TrimSpace(request.Workflow),
+		AgentID:      strings.TrimSpace(request.AgentID),
 		DryRun:       false,
 		IsActive:     true,
 		UpdatedAt:    timeNow(),
 	}
 
-	existing, err := h.store.ListPolicyCaps()
+	existing, err := h.store.ListPolicyCaps(ctx)
 	if err != nil {
 		return domain.PolicyCap{}, err
 	}
+	var before any
 	for _, item := range existing {
 		if item.ID == id {
+			if !inProject(item.Project, project) {
+				return domain.PolicyCap{}, domain.NotFound("policy cap not found")
+			}
 			current = item
+			before = item
 			break
 		}
 	}
@@ -348,6 +765,12 @@ func (h *HubService) UpsertPolicyCap(request UpsertPolicyCapRequest) (domain.Pol
 	if request.Model != "" {
 		current.Model = strings.TrimSpace(request.Model)
 	}
+	if request.Workflow != "" {
+		current.Workflow = strings.TrimSpace(request.Workflow)
+	}
+	if request.AgentID != "" {
+		current.AgentID = strings.TrimSpace(request.AgentID)
+	}
 	if request.MaxCostPerRunUSD != nil {
 		if *request.MaxCostPerRunUSD < 0 {
 			return domain.PolicyCap{}, domain.InvalidArgument("max_cost_per_run_usd must be non-negative")
@@ -384,6 +807,18 @@ func (h *HubService) UpsertPolicyCap(request UpsertPolicyCapRequest) (domain.Pol
 		}
 		current.MaxLatencyPerAttemptMS = *request.MaxLatencyPerAttemptMS
 	}
+	if request.MaxCostPerDayUSD != nil {
+		if *request.MaxCostPerDayUSD < 0 {
+			return domain.PolicyCap{}, domain.InvalidArgument("max_cost_per_day_usd must be non-negative")
+		}
+		current.MaxCostPerDayUSD = *request.MaxCostPerDayUSD
+	}
+	if request.MaxCostPerMonthUSD != nil {
+		if *request.MaxCostPerMonthUSD < 0 {
+			return domain.PolicyCap{}, domain.InvalidArgument("max_cost_per_month_usd must be non-negative")
+		}
+		current.MaxCostPerMonthUSD = *request.MaxCostPerMonthUSD
+	}
 	if request.Priority != nil {
 		current.Priority = *request.Priority
 	}
@@ -393,68 +828,185 @@ func (h *HubService) UpsertPolicyCap(request UpsertPolicyCapRequest) (domain.Pol
 	if request.IsActive != nil {
 		current.IsActive = *request.IsActive
 	}
+	if request.ValidFrom != nil {
+		validFrom, err := normalizeOptionalTimestamp(*request.ValidFrom, "valid_from")
+		if err != nil {
+			return domain.PolicyCap{}, err
+		}
+		current.ValidFrom = validFrom
+	}
+	if request.ValidUntil != nil {
+		validUntil, err := normalizeOptionalTimestamp(*request.ValidUntil, "valid_until")
+		if err != nil {
+			return domain.PolicyCap{}, err
+		}
+		current.ValidUntil = validUntil
+	}
+	if current.ValidFrom != "" && current.ValidUntil != "" && current.ValidUntil <= current.ValidFrom {
+		return domain.PolicyCap{}, domain.InvalidArgument("valid_until must be after valid_from")
+	}
+	if request.Kind != nil {
+		current.Kind = strings.TrimSpace(*request.Kind)
+	}
+	if current.Kind == "" {
+		current.Kind = domain.PolicyCapKindLimit
+	}
+	if request.Routing != nil {
+		routing := *request.Routing
+		routing.PreferProviderType = strings.TrimSpace(routing.PreferProviderType)
+		routing.FallbackProviderType = strings.TrimSpace(routing.FallbackProviderType)
+		current.Routing = &routing
+	}
+	switch current.Kind {
+	case domain.PolicyCapKindLimit:
+		current.Routing = nil
+	case domain.PolicyCapKindRouting:
+		if err := validateRoutingCap(current); err != nil {
+			return domain.PolicyCap{}, err
+		}
+	default:
+		return domain.PolicyCap{}, domain.InvalidArgument("kind must be one of: limit, routing")
+	}
 	current.UpdatedAt = timeNow()
 
-	if err := h.store.UpsertPolicyCap(current); err != nil {
+	if err := h.validatePolicyChange(ctx, lock, "policy_cap", current.ID, current.Project, "upsert", request.Actor, before, current, h.currentPolicyCap(current.ID)); err != nil {
+		return domain.PolicyCap{}, err
+	}
+	if err := h.store.UpsertPolicyCap(ctx, current); err != nil {
+		return domain.PolicyCap{}, err
+	}
+	if err := h.recordPolicyAudit(ctx, "policy_cap", current.ID, current.Project, "upsert", request.Actor, before, current); err != nil {
 		return domain.PolicyCap{}, err
 	}
 	return current, nil
 }
 
-func (h *HubService) DeletePolicyCap(request DeletePolicyCapRequest) error {
+func (h *HubService) DeletePolicyCap(ctx context.Context, request DeletePolicyCapRequest) error {
+	lock := h.lockPolicyChange()
+	defer lock.unlock()
 	id := strings.TrimSpace(request.ID)
 	if id == "" {
 		return domain.InvalidArgument("id is required")
 	}
-	deleted, err := h.store.DeletePolicyCap(id)
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return err
+	}
+	caps, err := h.store.ListPolicyCaps(ctx)
+	if err != nil {
+		return err
+	}
+	var before domain.PolicyCap
+	for _, item := range caps {
+		if item.ID != id {
+			continue
+		}
+		if project != "" && item.Project != project {
+			return domain.NotFound("policy cap not found")
+		}
+		before = item
+	}
+	if before.ID != "" {
+		if err := h.validatePolicyChange(ctx, lock, "policy_cap", id, before.Project, "delete", request.Actor, before, nil, h.currentPolicyCap(id)); err != nil {
+			return err
+		}
+	}
+	lock.lock()
+	deleted, err := h.store.DeletePolicyCap(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !deleted {
 		return domain.NotFound("policy cap not found")
 	}
-	return nil
+	return h.recordPolicyAudit(ctx, "policy_cap", id, before.Project, "delete", request.Actor, before, nil)
+}
+
+// ListPolicyAudit returns policy and cap mutations newest first.
+func (h *HubService) ListPolicyAudit(ctx context.Context, request ListPolicyAuditRequest) ([]domain.PolicyAuditEntry, error) {
+	if request.Limit < 0 {
+		return nil, domain.InvalidArgument("limit must be non-negative")
+	}
+	targetType := strings.TrimSpace(request.TargetType)
+	if targetType != "" && targetType != "policy" && targetType != "policy_cap" && targetType != "run_share" {
+		return nil, domain.InvalidArgument("target_type must be one of: policy, policy_cap, run_share")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.store.ListPolicyAudit(ctx, domain.PolicyAuditFilter{
+		Project:    project,
+		TargetType: targetType,
+		TargetID:   strings.TrimSpace(request.TargetID),
+		Limit:      h.storeLimit(request.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(items, func(a, b domain.PolicyAuditEntry) int {
+		if a.CreatedAt == b.CreatedAt {
+			return strings.Compare(b.ID, a.ID)
+		}
+		return strings.Compare(b.CreatedAt, a.CreatedAt)
+	})
+	return clampItems(ctx, h, items, "audit entries"), nil
+}
+
+func (h *HubService) recordPolicyAudit(ctx context.Context, targetType, targetID, project, action string, actor AuditActor, before, after any) error {
+	entry := domain.PolicyAuditEntry{
+		ID:           newID("aud"),
+		Project:      project,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		Action:       action,
+		ActorAgentID: actor.AgentID,
+		ActorKeyID:   actor.KeyID,
+		CreatedAt:    timeNow(),
+	}
+	if before != nil {
+		serialized, err := json.Marshal(before)
+		if err != nil {
+			return domain.Internal("failed to encode policy audit entry", err)
+		}
+		entry.BeforeJSON = string(serialized)
+	}
+	if after != nil {
+		serialized, err := json.Marshal(after)
+		if err != nil {
+			return domain.Internal("failed to encode policy audit entry", err)
+		}
+		entry.AfterJSON = string(serialized)
+	}
+	return h.store.InsertPolicyAudit(ctx, entry)
 }
 
-func (h *HubService) Summary() (domain.Summary, error) {
-	benchmarks, err := h.store.ListBenchmarks()
+func (h *HubService) Summary(ctx context.Context) (domain.Summary, error) {
+	benchmarks, err := h.store.ListBenchmarks(ctx)
 	if err != nil {
 		return domain.Summary{}, err
 	}
 
 	summary := domain.Summary{}
-	tasks, err := h.store.ListTasks()
-	if err != nil {
+	summary.Counts.Benchmarks = len(benchmarks)
+	if summary.Counts.Tasks, err = h.store.CountTasks(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-	notes, err := h.store.ListNotes()
-	if err != nil {
+	if summary.Counts.Notes, err = h.store.CountNotes(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-	changelog, err := h.store.ListChangelog()
-	if err != nil {
+	if summary.Counts.Changelog, err = h.store.CountChangelog(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-
-	summary.Counts.Tasks = len(tasks)
-	summary.Counts.Notes = len(notes)
-	summary.Counts.Changelog = len(changelog)
-	summary.Counts.Benchmarks = len(benchmarks)
-	runs, err := h.store.ListRuns()
-	if err != nil {
+	if summary.Counts.Runs, err = h.store.CountRuns(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-	attempts, err := h.store.ListPromptAttempts("")
-	if err != nil {
+	if summary.Counts.Attempts, err = h.store.CountPromptAttempts(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-	events, err := h.store.ListRunEvents("")
-	if err != nil {
+	if summary.Counts.RunEvents, err = h.store.CountRunEvents(ctx); err != nil {
 		return domain.Summary{}, err
 	}
-	summary.Counts.Runs = len(runs)
-	summary.Counts.Attempts = len(attempts)
-	summary.Counts.RunEvents = len(events)
 	summary.Totals.ByProvider = map[string]struct {
 		Count   int     `json:"count"`
 		CostUSD float64 `json:"cost_usd"`
@@ -476,43 +1028,129 @@ func (h *HubService) Summary() (domain.Summary, error) {
 	return summary, nil
 }
 
-func (h *HubService) CreateTask(request CreateTaskRequest) (domain.Task, error) {
+func (h *HubService) Prune(ctx context.Context, request PruneRequest) (domain.PruneResult, error) {
+	policy := h.retention
+	if request.RunEventsRetentionDays != nil {
+		policy.RunEventsDays = *request.RunEventsRetentionDays
+	}
+	if request.AttemptsRetentionDays != nil {
+		policy.AttemptsDays = *request.AttemptsRetentionDays
+	}
+	if policy.RunEventsDays < 0 || policy.AttemptsDays < 0 {
+		return domain.PruneResult{}, domain.InvalidArgument("retention days must not be negative")
+	}
+	if policy.RunEventsDays == 0 && policy.AttemptsDays == 0 {
+		return domain.PruneResult{}, domain.InvalidArgument("no retention configured; set run_events_retention_days or attempts_retention_days")
+	}
+	return h.pruneWith(ctx, policy, time.Now().UTC())
+}
+
+// PruneExpired applies the configured retention; ok is false when none is set.
+func (h *HubService) PruneExpired(ctx context.Context, now time.Time) (domain.PruneResult, bool, error) {
+	if h.retention.RunEventsDays <= 0 && h.retention.AttemptsDays <= 0 {
+		return domain.PruneResult{}, false, nil
+	}
+	result, err := h.pruneWith(ctx, h.retention, now.UTC())
+	return result, true, err
+}
+
+func (h *HubService) pruneWith(ctx context.Context, policy RetentionPolicy, now time.Time) (domain.PruneResult, error) {
+	var runEventsBefore, attemptsBefore time.Time
+	if policy.RunEventsDays > 0 {
+		runEventsBefore = now.Add(-time.Duration(policy.RunEventsDays) * 24 * time.Hour)
+	}
+	if policy.AttemptsDays > 0 {
+		attemptsBefore = now.Add(-time.Duration(policy.AttemptsDays) * 24 * time.Hour)
+	}
+	result, err := h.store.PruneBefore(ctx, runEventsBefore, attemptsBefore)
+	if err != nil {
+		return result, err
+	}
+	if !runEventsBefore.IsZero() {
+		result.RunEventsBefore = runEventsBefore.Format(time.RFC3339Nano)
+	}
+	if !attemptsBefore.IsZero() {
+		result.AttemptsBefore = attemptsBefore.Format(time.RFC3339Nano)
+	}
+	return result, nil
+}
+
+// ArchiveExpired moves attempts and run events past the configured archive
+// age to cold storage; ok is false when archiving is off.
+func (h *HubService) ArchiveExpired(ctx context.Context, now time.Time) (domain.ArchiveResult, bool, error) {
+	if h.archiveAfterDays <= 0 {
+		return domain.ArchiveResult{}, false, nil
+	}
+	before := now.UTC().Add(-time.Duration(h.archiveAfterDays) * 24 * time.Hour)
+	result, err := h.store.ArchiveBefore(ctx, before)
+	result.Before = before.Format(time.RFC3339Nano)
+	return result, true, err
+}
+
+// RebuildDailyRollups recounts the daily rollups for day's UTC date from
+// the attempts, correcting totals that drifted from them.
+func (h *HubService) RebuildDailyRollups(ctx context.Context, day time.Time) error {
+	return h.store.RebuildDailyRollups(ctx, day)
+}
+
+func (h *HubService) CreateTask(ctx context.Context, request CreateTaskRequest) (domain.Task, error) {
 	title := strings.TrimSpace(request.Title)
 	if title == "" {
 		return domain.Task{}, domain.InvalidArgument("title is required")
 	}
+	project, err := projectForCreate(request.Project)
+	if err != nil {
+		return domain.Task{}, err
+	}
 
 	status := strings.TrimSpace(request.Status)
 	if status == "" {
 		status = "todo"
 	}
 	if _, ok := validTaskStatuses[status]; !ok {
-		return domain.Task{}, domain.InvalidArgument("status must be one of: todo, in_progress, done, blocked")
+		return domain.Task{}, domain.InvalidArgument("status must be one of: todo, in_progress, done, blocked, archived")
 	}
-
-	task := domain.Task{
-		ID:        newID("task"),
-		Title:     title,
-		Details:   strings.TrimSpace(request.Details),
-		Status:    status,
-		Tags:      normalizeTags(request.Tags),
-		CreatedAt: timeNow(),
-		UpdatedAt: timeNow(),
+	externalID, err := normalizeExternalID(request.ExternalID)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := h.checkTaskExternalID(ctx, project, externalID, ""); err != nil {
+		return domain.Task{}, err
 	}
 
-	if err := h.store.UpsertTask(task); err != nil {
+	task := domain.Task{
+		ID:         h.newTaskID(),
+		Project:    project,
+		ExternalID: externalID,
+		Title:      title,
+		Details:    strings.TrimSpace(request.Details),
+		Status:     status,
+		Tags:       normalizeTags(request.Tags),
+		CreatedAt:  timeNow(),
+		UpdatedAt:  timeNow(),
+	}
+
+	if err := h.store.UpsertTask(ctx, task); err != nil {
 		return domain.Task{}, err
 	}
 	return task, nil
 }
 
-func (h *HubService) UpdateTask(request UpdateTaskRequest) (domain.Task, error) {
+func (h *HubService) UpdateTask(ctx context.Context, request UpdateTaskRequest) (domain.Task, error) {
 	id := strings.TrimSpace(request.ID)
 	if id == "" {
 		return domain.Task{}, domain.InvalidArgument("id is required")
 	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	externalID, err := normalizeExternalID(request.ExternalID)
+	if err != nil {
+		return domain.Task{}, err
+	}
 
-	items, err := h.store.ListTasks()
+	items, err := h.store.ListTasksFiltered(ctx, domain.TaskFilter{Project: project, IncludeArchived: true})
 	if err != nil {
 		return domain.Task{}, err
 	}
@@ -531,15 +1169,21 @@ func (h *HubService) UpdateTask(request UpdateTaskRequest) (domain.Task, error)
 		if request.Status != "" {
 			status := strings.TrimSpace(request.Status)
 			if _, ok := validTaskStatuses[status]; !ok {
-				return domain.Task{}, domain.InvalidArgument("status must be one of: todo, in_progress, done, blocked")
+				return domain.Task{}, domain.InvalidArgument("status must be one of: todo, in_progress, done, blocked, archived")
 			}
 			items[i].Status = status
 		}
 		if request.Tags != nil {
 			items[i].Tags = normalizeTags(request.Tags)
 		}
+		if externalID != "" && externalID != items[i].ExternalID {
+			if err := h.checkTaskExternalID(ctx, items[i].Project, externalID, id); err != nil {
+				return domain.Task{}, err
+			}
+			items[i].ExternalID = externalID
+		}
 		items[i].UpdatedAt = timeNow()
-		if err := h.store.UpsertTask(items[i]); err != nil {
+		if err := h.store.UpsertTask(ctx, items[i]); err != nil {
 			return domain.Task{}, err
 		}
 		return items[i], nil
@@ -548,13 +1192,46 @@ func (h *HubService) UpdateTask(request UpdateTaskRequest) (domain.Task, error)
 	return domain.Task{}, domain.NotFound("task not found")
 }
 
-func (h *HubService) DeleteTask(request DeleteTaskRequest) error {
+// DeleteTask archives the task, which keeps it for reporting but drops it
+// from default task lists; setting another status with UpdateTask restores
+// it. Archiving an archived task is a no-op. With Purge the task is deleted.
+func (h *HubService) DeleteTask(ctx context.Context, request DeleteTaskRequest) error {
 	id := strings.TrimSpace(request.ID)
 	if id == "" {
 		return domain.InvalidArgument("id is required")
 	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return err
+	}
+	if !request.Purge {
+		items, err := h.store.ListTasksFiltered(ctx, domain.TaskFilter{Project: project, IncludeArchived: true})
+		if err != nil {
+			return err
+		}
+		index := slices.IndexFunc(items, func(item domain.Task) bool { return item.ID == id })
+		if index < 0 {
+			return domain.NotFound("task not found")
+		}
+		task := items[index]
+		if task.Status == domain.TaskStatusArchived {
+			return nil
+		}
+		task.Status = domain.TaskStatusArchived
+		task.UpdatedAt = timeNow()
+		return h.store.UpsertTask(ctx, task)
+	}
+	if project != "" {
+		items, err := h.store.ListTasksFiltered(ctx, domain.TaskFilter{Project: project, IncludeArchived: true})
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(items, func(item domain.Task) bool { return item.ID == id }) {
+			return domain.NotFound("task not found")
+		}
+	}
 
-	deleted, err := h.store.DeleteTask(id)
+	deleted, err := h.store.DeleteTask(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -564,10 +1241,35 @@ func (h *HubService) DeleteTask(request DeleteTaskRequest) error {
 	return nil
 }
 
-func (h *HubService) ListTasks() ([]domain.Task, error) {
-	items, err := h.store.ListTasks()
-	if err != nil {
-		return nil, err
+func (h *HubService) ListTasks(ctx context.Context, request ListTasksRequest) ([]domain.Task, error) {
+	if request.Limit < 0 {
+		return nil, domain.InvalidArgument("limit must be non-negative")
+	}
+	status := strings.TrimSpace(request.Status)
+	if status != "" {
+		if _, ok := validTaskStatuses[status]; !ok {
+			return nil, domain.InvalidArgument("status must be one of: todo, in_progress, done, blocked, archived")
+		}
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	externalID := strings.TrimSpace(request.ExternalID)
+	// Asking for archived tasks by status, or for one by external ID,
+	// includes them.
+	filter := domain.TaskFilter{
+		Project:         project,
+		ExternalID:      externalID,
+		Status:          status,
+		Tags:            normalizeTags(request.Tags),
+		Query:           strings.TrimSpace(request.Query),
+		IncludeArchived: request.IncludeArchived || status == domain.TaskStatusArchived || externalID != "",
+		Limit:           h.storeLimit(request.Limit),
+	}
+	items, err := h.store.ListTasksFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 	slices.SortFunc(items, func(a, b domain.Task) int {
 		if a.UpdatedAt == b.UpdatedAt {
@@ -575,10 +1277,10 @@ func (h *HubService) ListTasks() ([]domain.Task, error) {
 		}
 		return strings.Compare(b.UpdatedAt, a.UpdatedAt)
 	})
-	return items, nil
+	return clampItems(ctx, h, items, "tasks"), nil
 }
 
-func (h *HubService) CreateNote(request CreateNoteRequest) (domain.Note, error) {
+func (h *HubService) CreateNote(ctx context.Context, request CreateNoteRequest) (domain.Note, error) {
 	title := strings.TrimSpace(request.Title)
 	if title == "" {
 		return domain.Note{}, domain.InvalidArgument("title is required")
@@ -592,14 +1294,14 @@ func (h *HubService) CreateNote(request CreateNoteRequest) (domain.Note, error)
 		CreatedAt: timeNow(),
 	}
 
-	if err := h.store.InsertNote(note); err != nil {
+	if err := h.store.InsertNote(ctx, note); err != nil {
 		return domain.Note{}, err
 	}
 	return note, nil
 }
 
-func (h *HubService) ListNotes() ([]domain.Note, error) {
-	items, err := h.store.ListNotes()
+func (h *HubService) ListNotes(ctx context.Context) ([]domain.Note, error) {
+	items, err := h.store.ListNotes(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -612,7 +1314,7 @@ func (h *HubService) ListNotes() ([]domain.Note, error) {
 	return items, nil
 }
 
-func (h *HubService) AppendChangelog(request AppendChangelogRequest) (domain.ChangelogEntry, error) {
+func (h *HubService) AppendChangelog(ctx context.Context, request AppendChangelogRequest) (domain.ChangelogEntry, error) {
 	summary := strings.TrimSpace(request.Summary)
 	if summary == "" {
 		return domain.ChangelogEntry{}, domain.InvalidArgument("summary is required")
@@ -635,14 +1337,14 @@ func (h *HubService) AppendChangelog(request AppendChangelogRequest) (domain.Cha
 		CreatedAt: timeNow(),
 	}
 
-	if err := h.store.InsertChangelog(entry); err != nil {
+	if err := h.store.InsertChangelog(ctx, entry); err != nil {
 		return domain.ChangelogEntry{}, err
 	}
 	return entry, nil
 }
 
-func (h *HubService) ListChangelog() ([]domain.ChangelogEntry, error) {
-	items, err := h.store.ListChangelog()
+func (h *HubService) ListChangelog(ctx context.Context) ([]domain.ChangelogEntry, error) {
+	items, err := h.store.ListChangelog(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -655,7 +1357,19 @@ func (h *HubService) ListChangelog() ([]domain.ChangelogEntry, error) {
 	return items, nil
 }
 
-func (h *HubService) RecordBenchmark(request RecordBenchmarkRequest) (domain.Benchmark, error) {
+func (h *HubService) RecordBenchmark(ctx context.Context, request RecordBenchmarkRequest) (domain.Benchmark, error) {
+	record, err := newBenchmark(request, timeNow())
+	if err != nil {
+		return domain.Benchmark{}, err
+	}
+	if err := h.store.InsertBenchmark(ctx, record); err != nil {
+		return domain.Benchmark{}, err
+	}
+	return record, nil
+}
+
+// newBenchmark validates request and builds the benchmark row it records.
+func newBenchmark(request RecordBenchmarkRequest, createdAt string) (domain.Benchmark, error) {
 	workflow := strings.TrimSpace(request.Workflow)
 	providerType := strings.TrimSpace(request.ProviderType)
 	model := strings.TrimSpace(request.Model)
@@ -682,17 +1396,13 @@ func (h *HubService) RecordBenchmark(request RecordBenchmarkRequest) (domain.Ben
 		LatencyMS:    request.LatencyMS,
 		QualityScore: request.QualityScore,
 		Notes:        strings.TrimSpace(request.Notes),
-		CreatedAt:    timeNow(),
-	}
-
-	if err := h.store.InsertBenchmark(record); err != nil {
-		return domain.Benchmark{}, err
+		CreatedAt:    createdAt,
 	}
 	return record, nil
 }
 
-func (h *HubService) ListBenchmarks() ([]domain.Benchmark, error) {
-	items, err := h.store.ListBenchmarks()
+func (h *HubService) ListBenchmarks(ctx context.Context) ([]domain.Benchmark, error) {
+	items, err := h.store.ListBenchmarks(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -705,7 +1415,7 @@ func (h *HubService) ListBenchmarks() ([]domain.Benchmark, error) {
 	return items, nil
 }
 
-func (h *HubService) StartRun(request StartRunRequest) (domain.AgentRun, error) {
+func (h *HubService) StartRun(ctx context.Context, request StartRunRequest) (domain.AgentRun, error) {
 	workflow := strings.TrimSpace(request.Workflow)
 	agentID := strings.TrimSpace(request.AgentID)
 	if workflow == "" || agentID == "" {
@@ -714,36 +1424,78 @@ func (h *HubService) StartRun(request StartRunRequest) (domain.AgentRun, error)
 	if request.MaxRetries < 0 {
 		return domain.AgentRun{}, domain.InvalidArgument("max_retries must be non-negative")
 	}
-	policy, err := h.store.GetPolicy()
+	name := strings.TrimSpace(request.Name)
+	description := strings.TrimSpace(request.Description)
+	if len(name) > maxRunNameLength {
+		return domain.AgentRun{}, domain.InvalidArgument(fmt.Sprintf("name must be at most %d bytes", maxRunNameLength))
+	}
+	if len(description) > maxRunDescriptionLength {
+		return domain.AgentRun{}, domain.InvalidArgument(fmt.Sprintf("description must be at most %d bytes", maxRunDescriptionLength))
+	}
+	externalID, err := normalizeExternalID(request.ExternalID)
 	if err != nil {
 		return domain.AgentRun{}, err
 	}
-	if policy.KillSwitch {
-		reason := strings.TrimSpace(policy.KillSwitchReason)
-		if reason == "" {
-			reason = "kill switch is enabled"
-		}
+	metadata, err := normalizeMetadata(request.Metadata)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	project, err := projectForCreate(request.Project)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	policy, err := h.store.GetPolicy(ctx)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	if reason, blocked := killSwitchEngaged(policy); blocked {
+		h.notifyKillSwitch(ctx, policy, "", "StartRun rejected: "+reason, map[string]any{"workflow": workflow, "agent_id": strings.TrimSpace(request.AgentID)})
 		return domain.AgentRun{}, domain.FailedPrecondition(reason)
 	}
+	if err := h.checkRunExternalID(ctx, project, externalID); err != nil {
+		return domain.AgentRun{}, err
+	}
+
+	runID := h.newRunID()
+	promptVersion := strings.TrimSpace(request.PromptVersion)
+	if promptVersion == "" {
+		// Fall back to the workflow's pinned release, if any.
+		active, err := h.store.ListPromptReleases(ctx, domain.PromptReleaseFilter{Project: project, Workflow: workflow, Limit: 1})
+		if err != nil {
+			return domain.AgentRun{}, err
+		}
+		if len(active) > 0 {
+			promptVersion = active[0].PromptVersion
+			if active[0].CanaryVersion != "" && canaryBucket(runID) < active[0].CanaryPercent {
+				promptVersion = active[0].CanaryVersion
+			}
+		}
+	}
 
 	run := domain.AgentRun{
-		ID:            newID("run"),
+		ID:            runID,
+		ExternalID:    externalID,
+		Name:          name,
+		Description:   description,
+		Project:       project,
 		TaskID:        strings.TrimSpace(request.TaskID),
 		Workflow:      workflow,
 		AgentID:       agentID,
-		PromptVersion: strings.TrimSpace(request.PromptVersion),
+		PromptVersion: promptVersion,
 		ModelPolicy:   strings.TrimSpace(request.ModelPolicy),
 		Status:        "running",
 		MaxRetries:    request.MaxRetries,
+		Metadata:      metadata,
 		StartedAt:     timeNow(),
 	}
-	if err := h.store.InsertRun(run); err != nil {
+	if err := h.store.InsertRun(ctx, run); err != nil {
 		return domain.AgentRun{}, err
 	}
+	h.publishRun(run)
 	return run, nil
 }
 
-func (h *HubService) FinishRun(request FinishRunRequest) (domain.AgentRun, error) {
+func (h *HubService) FinishRun(ctx context.Context, request FinishRunRequest) (domain.AgentRun, error) {
 	runID := strings.TrimSpace(request.RunID)
 	if runID == "" {
 		return domain.AgentRun{}, domain.InvalidArgument("run_id is required")
@@ -755,8 +1507,12 @@ func (h *HubService) FinishRun(request FinishRunRequest) (domain.AgentRun, error
 	if _, ok := validRunStatuses[status]; !ok || status == "running" {
 		return domain.AgentRun{}, domain.InvalidArgument("status must be one of: completed, failed, cancelled")
 	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
 
-	runs, err := h.store.ListRuns()
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
 	if err != nil {
 		return domain.AgentRun{}, err
 	}
@@ -776,32 +1532,119 @@ func (h *HubService) FinishRun(request FinishRunRequest) (domain.AgentRun, error
 			run.DurationMS = time.Since(startedAt).Milliseconds()
 		}
 
-		attempts, err := h.store.ListPromptAttempts(runID)
+		run, err = h.store.FinalizeRun(ctx, run)
 		if err != nil {
 			return domain.AgentRun{}, err
 		}
-		for _, attempt := range attempts {
-			run.TotalAttempts++
-			run.TotalTokensIn += attempt.TokensIn
-			run.TotalTokensOut += attempt.TokensOut
-			run.TotalCostUSD += attempt.CostUSD
-			if attempt.Outcome == "success" {
-				run.SuccessAttempts++
-			} else {
-				run.FailedAttempts++
-			}
-		}
-
-		if err := h.store.UpdateRun(run); err != nil {
-			return domain.AgentRun{}, err
-		}
+		h.publishRun(run)
+		h.evaluatePromptCanary(ctx, run)
 		return run, nil
 	}
 
 	return domain.AgentRun{}, domain.NotFound("run not found")
 }
 
-func (h *HubService) RecordPromptAttempt(request RecordPromptAttemptRequest) (domain.PromptAttempt, error) {
+// CancelRun finishes a running run as cancelled, with the reason as its last
+// error. Unlike FinishRun, it refuses runs that already finished.
+func (h *HubService) CancelRun(ctx context.Context, request CancelRunRequest) (domain.AgentRun, error) {
+	runID := strings.TrimSpace(request.RunID)
+	if runID == "" {
+		return domain.AgentRun{}, domain.InvalidArgument("run_id is required")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	if len(runs) == 0 || runs[0].ID != runID {
+		return domain.AgentRun{}, domain.NotFound("run not found")
+	}
+	if runs[0].Status != "running" {
+		return domain.AgentRun{}, domain.FailedPrecondition("run is not in running state")
+	}
+	return h.FinishRun(ctx, FinishRunRequest{
+		Project:   project,
+		RunID:     runID,
+		Status:    "cancelled",
+		LastError: strings.TrimSpace(request.Reason),
+	})
+}
+
+// StarRun stars or unstars a run, in any state, so it can be found again
+// with ListRuns starred=true. Starring an already starred run keeps its
+// StarredAt.
+func (h *HubService) StarRun(ctx context.Context, request StarRunRequest) (domain.AgentRun, error) {
+	runID := strings.TrimSpace(request.RunID)
+	if runID == "" {
+		return domain.AgentRun{}, domain.InvalidArgument("run_id is required")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+	if err != nil {
+		return domain.AgentRun{}, err
+	}
+	if len(runs) == 0 || runs[0].ID != runID {
+		return domain.AgentRun{}, domain.NotFound("run not found")
+	}
+	run := runs[0]
+	starred := request.Starred == nil || *request.Starred
+	if run.Starred == starred {
+		return run, nil
+	}
+	run.Starred = starred
+	run.StarredAt = ""
+	if starred {
+		run.StarredAt = timeNow()
+	}
+	if err := h.store.SetRunStarred(ctx, run); err != nil {
+		return domain.AgentRun{}, err
+	}
+	h.publishRun(run)
+	return run, nil
+}
+
+// GetEffectiveLimits reports the limits RecordPromptAttempt would enforce for
+// the given agent and model, so clients can track spend against them.
+func (h *HubService) GetEffectiveLimits(ctx context.Context, request GetEffectiveLimitsRequest) (EffectiveLimits, error) {
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return EffectiveLimits{}, err
+	}
+	providerType := strings.TrimSpace(request.ProviderType)
+	if providerType == "" {
+		providerType = "api"
+	}
+	policy, err := h.store.GetPolicy(ctx)
+	if err != nil {
+		return EffectiveLimits{}, err
+	}
+	caps, err := h.store.ListPolicyCaps(ctx)
+	if err != nil {
+		return EffectiveLimits{}, err
+	}
+	selectedCap, hasCap := selectPolicyCap(caps, project, strings.TrimSpace(request.Workflow), strings.TrimSpace(request.AgentID), providerType, strings.TrimSpace(request.Provider), strings.TrimSpace(request.Model))
+	return resolveEffectiveLimits(policy, selectedCap, hasCap), nil
+}
+
+// RecordPromptAttempt checks an attempt against the run, policy and caps
+// and stores it. Its trace splits into load_policy, check_budgets and insert
+// spans, so a slow call shows whether the budget scans or the write took
+// the time.
+func (h *HubService) RecordPromptAttempt(ctx context.Context, request RecordPromptAttemptRequest) (domain.PromptAttempt, error) {
+	ctx, span := tracing.Start(ctx, "HubService.RecordPromptAttempt", tracing.String("modeloman.run_id", strings.TrimSpace(request.RunID)))
+	defer span.End()
+	attempt, err := h.recordPromptAttempt(ctx, request)
+	span.RecordError(err)
+	return attempt, err
+}
+
+func (h *HubService) recordPromptAttempt(ctx context.Context, request RecordPromptAttemptRequest) (domain.PromptAttempt, error) {
 	runID := strings.TrimSpace(request.RunID)
 	outcome := strings.TrimSpace(request.Outcome)
 	model := strings.TrimSpace(request.Model)
@@ -822,25 +1665,25 @@ func (h *HubService) RecordPromptAttempt(request RecordPromptAttemptRequest) (do
 	if request.TokensIn < 0 || request.TokensOut < 0 || request.CostUSD < 0 || request.LatencyMS < 0 {
 		return domain.PromptAttempt{}, domain.InvalidArgument("tokens, cost, and latency must be non-negative")
 	}
-	policy, err := h.store.GetPolicy()
+	metadata, err := normalizeMetadata(request.Metadata)
 	if err != nil {
 		return domain.PromptAttempt{}, err
 	}
-	if policy.KillSwitch {
-		reason := strings.TrimSpace(policy.KillSwitchReason)
-		if reason == "" {
-			reason = "kill switch is enabled"
-		}
-		return domain.PromptAttempt{}, domain.FailedPrecondition(reason)
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.PromptAttempt{}, err
 	}
-	caps, err := h.store.ListPolicyCaps()
+	lookupCtx, lookup := tracing.Start(ctx, "RecordPromptAttempt.load_policy")
+	defer lookup.End()
+	policy, err := h.store.GetPolicy(lookupCtx)
 	if err != nil {
 		return domain.PromptAttempt{}, err
 	}
-	selectedCap, hasCap := selectPolicyCap(caps, providerType, provider, model)
-	limits := resolveEffectiveLimits(policy, selectedCap, hasCap)
-
-	runs, err := h.store.ListRunsFiltered(domain.RunFilter{RunID: runID, Limit: 1})
+	if reason, blocked := killSwitchEngaged(policy); blocked {
+		h.notifyKillSwitch(ctx, policy, runID, "RecordPromptAttempt rejected: "+reason, map[string]any{"run_id": runID})
+		return domain.PromptAttempt{}, domain.FailedPrecondition(reason)
+	}
+	runs, err := h.store.ListRunsFiltered(lookupCtx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
 	if err != nil {
 		return domain.PromptAttempt{}, err
 	}
@@ -850,6 +1693,21 @@ func (h *HubService) RecordPromptAttempt(request RecordPromptAttemptRequest) (do
 	if runs[0].Status != "running" {
 		return domain.PromptAttempt{}, domain.FailedPrecondition("run is not in running state")
 	}
+	caps, err := h.store.ListPolicyCaps(lookupCtx)
+	if err != nil {
+		return domain.PromptAttempt{}, err
+	}
+	lookup.End()
+	agentID := strings.TrimSpace(request.AgentID)
+	if agentID == "" {
+		agentID = runs[0].AgentID
+	}
+	workflow := strings.TrimSpace(request.Workflow)
+	if workflow == "" {
+		workflow = runs[0].Workflow
+	}
+	selectedCap, hasCap := selectPolicyCap(caps, runs[0].Project, workflow, agentID, providerType, provider, model)
+	limits := resolveEffectiveLimits(policy, selectedCap, hasCap)
 
 	capOverridesAttemptLatency := hasCap && selectedCap.MaxLatencyPerAttemptMS > 0
 	capOverridesRunCost := hasCap && selectedCap.MaxCostPerRunUSD > 0
@@ -858,68 +1716,38 @@ func (h *HubService) RecordPromptAttempt(request RecordPromptAttemptRequest) (do
 	attemptTokens := request.TokensIn + request.TokensOut
 	if limits.MaxLatencyPerAttemptMS > 0 && request.LatencyMS > limits.MaxLatencyPerAttemptMS {
 		if capOverridesAttemptLatency && selectedCap.DryRun {
-			h.logPolicyCapDryRunViolation(runID, selectedCap, "attempt latency exceeds cap limit")
+			h.logPolicyCapDryRunViolation(ctx, runID, selectedCap, "attempt latency exceeds cap limit")
 		} else {
-			return domain.PromptAttempt{}, domain.ResourceExhausted("attempt latency exceeds policy cap (" + limits.Source + ")")
+			err := domain.ResourceExhausted("attempt latency exceeds policy cap (" + limits.Source + ")")
+			h.notifyCapViolation(ctx, policy, err, runID, agentID, model)
+			return domain.PromptAttempt{}, err
 		}
 	}
 	if limits.MaxCostPerAttemptUSD > 0 && request.CostUSD > limits.MaxCostPerAttemptUSD {
 		if hasCap && selectedCap.DryRun {
-			h.logPolicyCapDryRunViolation(runID, selectedCap, "attempt cost exceeds cap limit")
+			h.logPolicyCapDryRunViolation(ctx, runID, selectedCap, "attempt cost exceeds cap limit")
 		} else {
-			return domain.PromptAttempt{}, domain.ResourceExhausted("attempt cost exceeds policy cap (" + limits.Source + ")")
+			err := domain.ResourceExhausted("attempt cost exceeds policy cap (" + limits.Source + ")")
+			h.notifyCapViolation(ctx, policy, err, runID, agentID, model)
+			return domain.PromptAttempt{}, err
 		}
 	}
 	if limits.MaxTokensPerAttempt > 0 && attemptTokens > limits.MaxTokensPerAttempt {
 		if hasCap && selectedCap.DryRun {
-			h.logPolicyCapDryRunViolation(runID, selectedCap, "attempt tokens exceed cap limit")
-		} else {
-			return domain.PromptAttempt{}, domain.ResourceExhausted("attempt tokens exceed policy cap (" + limits.Source + ")")
-		}
-	}
-	existingAttempts, err := h.store.ListPromptAttemptsFiltered(domain.AttemptFilter{RunID: runID})
-	if err != nil {
-		return domain.PromptAttempt{}, err
-	}
-	if limits.MaxAttemptsPerRun > 0 && int64(len(existingAttempts))+1 > limits.MaxAttemptsPerRun {
-		if capOverridesRunAttempts && selectedCap.DryRun {
-			h.logPolicyCapDryRunViolation(runID, selectedCap, "run exceeds max attempts cap")
+			h.logPolicyCapDryRunViolation(ctx, runID, selectedCap, "attempt tokens exceed cap limit")
 		} else {
-			return domain.PromptAttempt{}, domain.ResourceExhausted("run exceeds max attempts cap (" + limits.Source + ")")
+			err := domain.ResourceExhausted("attempt tokens exceed policy cap (" + limits.Source + ")")
+			h.notifyCapViolation(ctx, policy, err, runID, agentID, model)
+			return domain.PromptAttempt{}, err
 		}
 	}
-	if limits.MaxCostPerRunUSD > 0 || limits.MaxTokensPerRun > 0 {
-		var totalCost float64
-		var totalTokens int64
-		for _, item := range existingAttempts {
-			totalCost += item.CostUSD
-			totalTokens += item.TokensIn + item.TokensOut
-		}
-		totalCost += request.CostUSD
-		totalTokens += request.TokensIn + request.TokensOut
-
-		if limits.MaxCostPerRunUSD > 0 && totalCost > limits.MaxCostPerRunUSD {
-			if capOverridesRunCost && selectedCap.DryRun {
-				h.logPolicyCapDryRunViolation(runID, selectedCap, "run exceeds max cost cap")
-			} else {
-				return domain.PromptAttempt{}, domain.ResourceExhausted("run exceeds max cost cap (" + limits.Source + ")")
-			}
-		}
-		if limits.MaxTokensPerRun > 0 && totalTokens > limits.MaxTokensPerRun {
-			if capOverridesRunTokens && selectedCap.DryRun {
-				h.logPolicyCapDryRunViolation(runID, selectedCap, "run exceeds max tokens cap")
-			} else {
-				return domain.PromptAttempt{}, domain.ResourceExhausted("run exceeds max tokens cap (" + limits.Source + ")")
-			}
-		}
-	}
-
 	attempt := domain.PromptAttempt{
 		ID:            newID("pat"),
+		Project:       runs[0].Project,
 		RunID:         runID,
 		AttemptNumber: request.AttemptNumber,
-		Workflow:      strings.TrimSpace(request.Workflow),
-		AgentID:       strings.TrimSpace(request.AgentID),
+		Workflow:      workflow,
+		AgentID:       agentID,
 		ProviderType:  providerType,
 		Provider:      provider,
 		Model:         model,
@@ -933,16 +1761,375 @@ func (h *HubService) RecordPromptAttempt(request RecordPromptAttemptRequest) (do
 		CostUSD:       request.CostUSD,
 		LatencyMS:     request.LatencyMS,
 		QualityScore:  request.QualityScore,
+		Metadata:      metadata,
 		CreatedAt:     timeNow(),
 	}
 
-	if err := h.store.InsertPromptAttempt(attempt); err != nil {
+	// The checks below read stored attempts. Stores that support it run them
+	// in the insert's transaction so concurrent attempts cannot both fit
+	// under the same budget; dry-run violations are logged afterwards.
+	var dryRunViolations []string
+	budgetCheck := func(ctx context.Context, reader store.AttemptReader) error {
+		dryRunViolations = dryRunViolations[:0]
+		existingAttempts, err := reader.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{RunID: runID})
+		if err != nil {
+			return err
+		}
+		tracing.SpanFromContext(ctx).SetAttributes(tracing.Int64("modeloman.run_attempts_scanned", int64(len(existingAttempts))))
+		if limits.MaxAttemptsPerRun > 0 && int64(len(existingAttempts))+1 > limits.MaxAttemptsPerRun {
+			if capOverridesRunAttempts && selectedCap.DryRun {
+				dryRunViolations = append(dryRunViolations, "run exceeds max attempts cap")
+			} else {
+				return domain.ResourceExhausted("run exceeds max attempts cap (" + limits.Source + ")")
+			}
+		}
+		if limits.MaxCostPerRunUSD > 0 || limits.MaxTokensPerRun > 0 {
+			var totalCost float64
+			var totalTokens int64
+			for _, item := range existingAttempts {
+				totalCost += item.CostUSD
+				totalTokens += item.TokensIn + item.TokensOut
+			}
+			totalCost += request.CostUSD
+			totalTokens += request.TokensIn + request.TokensOut
+
+			if limits.MaxCostPerRunUSD > 0 && totalCost > limits.MaxCostPerRunUSD {
+				if capOverridesRunCost && selectedCap.DryRun {
+					dryRunViolations = append(dryRunViolations, "run exceeds max cost cap")
+				} else {
+					return domain.ResourceExhausted("run exceeds max cost cap (" + limits.Source + ")")
+				}
+			}
+			if limits.MaxTokensPerRun > 0 && totalTokens > limits.MaxTokensPerRun {
+				if capOverridesRunTokens && selectedCap.DryRun {
+					dryRunViolations = append(dryRunViolations, "run exceeds max tokens cap")
+				} else {
+					return domain.ResourceExhausted("run exceeds max tokens cap (" + limits.Source + ")")
+				}
+			}
+		}
+		if limits.MaxCostPerDayUSD > 0 || limits.MaxCostPerMonthUSD > 0 {
+			daySpend, monthSpend, err := agentWindowSpend(ctx, reader, selectedCap, agentID)
+			if err != nil {
+				return err
+			}
+			if limits.MaxCostPerDayUSD > 0 && daySpend+request.CostUSD > limits.MaxCostPerDayUSD {
+				if selectedCap.DryRun {
+					dryRunViolations = append(dryRunViolations, "agent exceeds daily cost cap")
+				} else {
+					return domain.ResourceExhausted("agent exceeds daily cost cap (" + limits.Source + ")")
+				}
+			}
+			if limits.MaxCostPerMonthUSD > 0 && monthSpend+request.CostUSD > limits.MaxCostPerMonthUSD {
+				if selectedCap.DryRun {
+					dryRunViolations = append(dryRunViolations, "agent exceeds monthly cost cap")
+				} else {
+					return domain.ResourceExhausted("agent exceeds monthly cost cap (" + limits.Source + ")")
+				}
+			}
+		}
+		if policy.MaxCostPerHourUSD > 0 || policy.MaxCostPerDayUSD > 0 {
+			hourSpend, daySpend, err := hubWindowSpend(ctx, reader)
+			if err != nil {
+				return err
+			}
+			if policy.MaxCostPerHourUSD > 0 && hourSpend+request.CostUSD > policy.MaxCostPerHourUSD {
+				return domain.ResourceExhausted("hub exceeds hourly cost limit (global-policy)")
+			}
+			if policy.MaxCostPerDayUSD > 0 && daySpend+request.CostUSD > policy.MaxCostPerDayUSD {
+				return domain.ResourceExhausted("hub exceeds daily cost limit (global-policy)")
+			}
+		}
+		return nil
+	}
+	checkBudgets := func(reader store.AttemptReader) error {
+		ctx, span := tracing.Start(ctx, "RecordPromptAttempt.check_budgets")
+		defer span.End()
+		err := budgetCheck(ctx, reader)
+		span.RecordError(err)
+		return err
+	}
+	if guarded, ok := h.store.(store.GuardedAttemptInserter); ok {
+		locks := store.AttemptLocks{Hub: policy.MaxCostPerHourUSD > 0 || policy.MaxCostPerDayUSD > 0}
+		if limits.MaxCostPerDayUSD > 0 || limits.MaxCostPerMonthUSD > 0 {
+			locks.AgentID = agentID
+		}
+		// The budget checks run inside the guarded insert, so their span
+		// nests under this one.
+		insertCtx, insert := tracing.Start(ctx, "RecordPromptAttempt.insert", tracing.Bool("modeloman.guarded", true))
+		err = guarded.InsertPromptAttemptGuarded(insertCtx, attempt, locks, checkBudgets)
+		insert.RecordError(err)
+		insert.End()
+	} else if err = checkBudgets(h.store); err == nil {
+		insertCtx, insert := tracing.Start(ctx, "RecordPromptAttempt.insert", tracing.Bool("modeloman.guarded", false))
+		err = h.store.InsertPromptAttempt(insertCtx, attempt)
+		insert.RecordError(err)
+		insert.End()
+	}
+	for _, violation := range dryRunViolations {
+		h.logPolicyCapDryRunViolation(ctx, runID, selectedCap, violation)
+	}
+	if err != nil {
+		h.notifyCapViolation(ctx, policy, err, runID, agentID, model)
 		return domain.PromptAttempt{}, err
 	}
+	h.publishAttempt(attempt)
 	return attempt, nil
 }
 
-func (h *HubService) RecordRunEvent(request RecordRunEventRequest) (domain.RunEvent, error) {
+// SetActivePromptVersion pins the prompt version StartRun adopts for a
+// workflow and appends the change to the release history. A canary_percent
+// between 1 and 99 instead routes that share of new runs to the version while
+// the current one stays active; see evaluatePromptCanary for auto rollback.
+func (h *HubService) SetActivePromptVersion(ctx context.Context, request SetActivePromptVersionRequest) (domain.PromptRelease, error) {
+	workflow := strings.TrimSpace(request.Workflow)
+	version := strings.TrimSpace(request.PromptVersion)
+	if workflow == "" || version == "" {
+		return domain.PromptRelease{}, domain.InvalidArgument("workflow and prompt_version are required")
+	}
+	if request.CanaryPercent < 0 || request.CanaryPercent > 100 {
+		return domain.PromptRelease{}, domain.InvalidArgument("canary_percent must be between 0 and 100")
+	}
+	if request.RollbackMargin < 0 || request.RollbackMargin > 1 {
+		return domain.PromptRelease{}, domain.InvalidArgument("rollback_margin must be between 0 and 1")
+	}
+	if request.MinRuns < 0 {
+		return domain.PromptRelease{}, domain.InvalidArgument("min_runs must be non-negative")
+	}
+	project, err := projectForCreate(request.Project)
+	if err != nil {
+		return domain.PromptRelease{}, err
+	}
+	current, err := h.store.ListPromptReleases(ctx, domain.PromptReleaseFilter{Project: project, Workflow: workflow, Limit: 1})
+	if err != nil {
+		return domain.PromptRelease{}, err
+	}
+
+	release := domain.PromptRelease{
+		ID:            newID("rel"),
+		Project:       project,
+		Workflow:      workflow,
+		PromptVersion: version,
+		Action:        "set",
+		Actor:         strings.TrimSpace(request.Actor),
+		Reason:        strings.TrimSpace(request.Reason),
+		CreatedAt:     timeNow(),
+	}
+	if request.CanaryPercent > 0 && request.CanaryPercent < 100 {
+		if len(current) == 0 || current[0].PromptVersion == version {
+			return domain.PromptRelease{}, domain.FailedPrecondition("canary rollout needs a different active prompt version to compare against")
+		}
+		margin := request.RollbackMargin
+		if margin == 0 {
+			margin = defaultCanaryRollbackMargin
+		}
+		minRuns := request.MinRuns
+		if minRuns == 0 {
+			minRuns = defaultCanaryMinRuns
+		}
+		active := current[0]
+		if active.CanaryVersion == version && active.CanaryPercent == request.CanaryPercent &&
+			active.CanaryMargin == margin && active.CanaryMinRuns == minRuns {
+			return active, nil
+		}
+		release.PromptVersion = active.PromptVersion
+		release.PreviousVersion = active.PreviousVersion
+		release.CanaryVersion = version
+		release.CanaryPercent = request.CanaryPercent
+		release.CanaryMargin = margin
+		release.CanaryMinRuns = minRuns
+		release.Action = "canary"
+	} else if len(current) > 0 {
+		active := current[0]
+		switch {
+		case active.PromptVersion == version && active.CanaryVersion == "":
+			return active, nil
+		case active.PromptVersion == version:
+			// Re-pinning the incumbent cancels its canary without moving history.
+			release.PreviousVersion = active.PreviousVersion
+		default:
+			release.PreviousVersion = active.PromptVersion
+		}
+	}
+	if err := h.store.InsertPromptRelease(ctx, release); err != nil {
+		return domain.PromptRelease{}, err
+	}
+	return release, nil
+}
+
+// RollbackPromptVersion re-pins the version that was active before the
+// current one. Repeated rollbacks keep walking back through history. While a
+// canary is running, rollback only cancels the canary.
+func (h *HubService) RollbackPromptVersion(ctx context.Context, request RollbackPromptVersionRequest) (domain.PromptRelease, error) {
+	workflow := strings.TrimSpace(request.Workflow)
+	if workflow == "" {
+		return domain.PromptRelease{}, domain.InvalidArgument("workflow is required")
+	}
+	project, err := projectForCreate(request.Project)
+	if err != nil {
+		return domain.PromptRelease{}, err
+	}
+	history, err := h.store.ListPromptReleases(ctx, domain.PromptReleaseFilter{Project: project, Workflow: workflow})
+	if err != nil {
+		return domain.PromptRelease{}, err
+	}
+	if len(history) == 0 {
+		return domain.PromptRelease{}, domain.NotFound("workflow has no pinned prompt version")
+	}
+	current := history[0]
+	target := current.PreviousVersion
+	targetPrevious := ""
+	if current.CanaryVersion != "" {
+		target = current.PromptVersion
+		targetPrevious = current.PreviousVersion
+	} else {
+		if target == "" {
+			return domain.PromptRelease{}, domain.FailedPrecondition("no previous prompt version to roll back to")
+		}
+		// The release that originally pinned the target tells us what preceded it.
+		for _, item := range history[1:] {
+			if item.PromptVersion == target {
+				targetPrevious = item.PreviousVersion
+				break
+			}
+		}
+	}
+
+	release := domain.PromptRelease{
+		ID:              newID("rel"),
+		Project:         project,
+		Workflow:        workflow,
+		PromptVersion:   target,
+		PreviousVersion: targetPrevious,
+		Action:          "rollback",
+		Actor:           strings.TrimSpace(request.Actor),
+		Reason:          strings.TrimSpace(request.Reason),
+		CreatedAt:       timeNow(),
+	}
+	if err := h.store.InsertPromptRelease(ctx, release); err != nil {
+		return domain.PromptRelease{}, err
+	}
+	return release, nil
+}
+
+// evaluatePromptCanary compares finished-run success rates of a workflow's
+// canary and incumbent prompt versions since the canary started, and rolls the
+// canary back once it trails the incumbent by more than its margin. Both sides
+// need at least CanaryMinRuns finished runs before a decision is made.
+func (h *HubService) evaluatePromptCanary(ctx context.Context, run domain.AgentRun) {
+	if run.PromptVersion == "" || run.Status == "cancelled" {
+		return
+	}
+	current, err := h.store.ListPromptReleases(ctx, domain.PromptReleaseFilter{Project: run.Project, Workflow: run.Workflow, Limit: 1})
+	if err != nil || len(current) == 0 {
+		return
+	}
+	active := current[0]
+	if active.CanaryVersion == "" || (run.PromptVersion != active.CanaryVersion && run.PromptVersion != active.PromptVersion) {
+		return
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: run.Project, Workflow: run.Workflow, StartedAfter: active.CreatedAt})
+	if err != nil {
+		return
+	}
+	var canaryRuns, canarySuccess, incumbentRuns, incumbentSuccess int64
+	for _, item := range runs {
+		if item.Status != "completed" && item.Status != "failed" {
+			continue
+		}
+		switch item.PromptVersion {
+		case active.CanaryVersion:
+			canaryRuns++
+			if item.Status == "completed" {
+				canarySuccess++
+			}
+		case active.PromptVersion:
+			incumbentRuns++
+			if item.Status == "completed" {
+				incumbentSuccess++
+			}
+		}
+	}
+	if canaryRuns < active.CanaryMinRuns || incumbentRuns < active.CanaryMinRuns || canaryRuns == 0 || incumbentRuns == 0 {
+		return
+	}
+	canaryRate := float64(canarySuccess) / float64(canaryRuns)
+	incumbentRate := float64(incumbentSuccess) / float64(incumbentRuns)
+	if canaryRate >= incumbentRate-active.CanaryMargin {
+		return
+	}
+
+	reason := fmt.Sprintf("canary %s success rate %.2f trails %s at %.2f by more than %.2f",
+		active.CanaryVersion, canaryRate, active.PromptVersion, incumbentRate, active.CanaryMargin)
+	release := domain.PromptRelease{
+		ID:              newID("rel"),
+		Project:         active.Project,
+		Workflow:        active.Workflow,
+		PromptVersion:   active.PromptVersion,
+		PreviousVersion: active.PreviousVersion,
+		Action:          "auto_rollback",
+		Actor:           "modeloman",
+		Reason:          reason,
+		CreatedAt:       timeNow(),
+	}
+	if err := h.store.InsertPromptRelease(ctx, release); err != nil {
+		return
+	}
+	serialized, _ := json.Marshal(map[string]any{
+		"release_id":      release.ID,
+		"canary_version":  active.CanaryVersion,
+		"canary_rate":     canaryRate,
+		"incumbent":       active.PromptVersion,
+		"incumbent_rate":  incumbentRate,
+		"rollback_margin": active.CanaryMargin,
+	})
+	_ = h.store.InsertRunEvent(ctx, domain.RunEvent{
+		ID:        newID("evt"),
+		RunID:     run.ID,
+		EventType: "prompt_canary_rolled_back",
+		Level:     "warn",
+		Message:   reason,
+		DataJSON:  string(serialized),
+		RequestID: RequestIDFromContext(ctx),
+		CreatedAt: timeNow(),
+	})
+}
+
+// canaryBucket maps a run ID onto 0-99 so canary routing is stable per run.
+func canaryBucket(runID string) int64 {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(runID))
+	return int64(hasher.Sum32() % 100)
+}
+
+// ListPromptReleases returns release history newest first; the first entry
+// per workflow is the active version.
+func (h *HubService) ListPromptReleases(ctx context.Context, request ListPromptReleasesRequest) ([]domain.PromptRelease, error) {
+	if request.Limit < 0 {
+		return nil, domain.InvalidArgument("limit must be non-negative")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.store.ListPromptReleases(ctx, domain.PromptReleaseFilter{
+		Project:  project,
+		Workflow: strings.TrimSpace(request.Workflow),
+		Limit:    h.storeLimit(request.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(items, func(a, b domain.PromptRelease) int {
+		if a.CreatedAt == b.CreatedAt {
+			return strings.Compare(b.ID, a.ID)
+		}
+		return strings.Compare(b.CreatedAt, a.CreatedAt)
+	})
+	return clampItems(ctx, h, items, "prompt releases"), nil
+}
+
+func (h *HubService) RecordRunEvent(ctx context.Context, request RecordRunEventRequest) (domain.RunEvent, error) {
 	runID := strings.TrimSpace(request.RunID)
 	eventType := strings.TrimSpace(request.EventType)
 	if runID == "" || eventType == "" {
@@ -957,18 +2144,15 @@ func (h *HubService) RecordRunEvent(request RecordRunEventRequest) (domain.RunEv
 		return domain.RunEvent{}, domain.InvalidArgument("level must be one of: info, warn, error")
 	}
 
-	runs, err := h.store.ListRuns()
+	project, err := normalizeProject(request.Project)
 	if err != nil {
 		return domain.RunEvent{}, err
 	}
-	runExists := false
-	for _, run := range runs {
-		if run.ID == runID {
-			runExists = true
-			break
-		}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+	if err != nil {
+		return domain.RunEvent{}, err
 	}
-	if !runExists {
+	if len(runs) == 0 {
 		return domain.RunEvent{}, domain.NotFound("run not found")
 	}
 
@@ -979,15 +2163,107 @@ func (h *HubService) RecordRunEvent(request RecordRunEventRequest) (domain.RunEv
 		Level:     level,
 		Message:   strings.TrimSpace(request.Message),
 		DataJSON:  strings.TrimSpace(request.DataJSON),
+		RequestID: RequestIDFromContext(ctx),
 		CreatedAt: timeNow(),
 	}
-	if err := h.store.InsertRunEvent(event); err != nil {
+	if err := h.store.InsertRunEvent(ctx, event); err != nil {
 		return domain.RunEvent{}, err
 	}
+	h.publishRunEvent(runs[0].Project, event)
 	return event, nil
 }
 
-func (h *HubService) ListRuns(request ListRunsRequest) ([]domain.AgentRun, error) {
+// maxRunEventBatch bounds RecordRunEvents so one call stays well under the
+// gRPC message limit.
+const maxRunEventBatch = 500
+
+// RecordRunEvents validates every event before writing any, so a bad line
+// rejects the whole batch with its index instead of leaving a partial
+// backfill.
+func (h *HubService) RecordRunEvents(ctx context.Context, request RecordRunEventsRequest) (RecordRunEventsResult, error) {
+	if len(request.Events) == 0 {
+		return RecordRunEventsResult{}, domain.InvalidArgument("events are required")
+	}
+	if len(request.Events) > maxRunEventBatch {
+		return RecordRunEventsResult{}, domain.InvalidArgument(fmt.Sprintf("at most %d events per call", maxRunEventBatch))
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return RecordRunEventsResult{}, err
+	}
+
+	now := timeNow()
+	// Projects of the runs checked so far; "" for runs that do not exist.
+	knownRuns := map[string]string{}
+	events := make([]domain.RunEvent, 0, len(request.Events))
+	for i, input := range request.Events {
+		prefix := fmt.Sprintf("events[%d]: ", i)
+		runID := strings.TrimSpace(input.RunID)
+		eventType := strings.TrimSpace(input.EventType)
+		if runID == "" || eventType == "" {
+			return RecordRunEventsResult{}, domain.InvalidArgument(prefix + "run_id and event_type are required")
+		}
+		level := strings.TrimSpace(input.Level)
+		if level == "" {
+			level = "info"
+		}
+		if _, ok := validEventLevels[level]; !ok {
+			return RecordRunEventsResult{}, domain.InvalidArgument(prefix + "level must be one of: info, warn, error")
+		}
+		createdAt := now
+		if raw := strings.TrimSpace(input.CreatedAt); raw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return RecordRunEventsResult{}, domain.InvalidArgument(prefix + "created_at must be RFC3339 timestamp")
+			}
+			if parsed.After(time.Now()) {
+				return RecordRunEventsResult{}, domain.InvalidArgument(prefix + "created_at must not be in the future")
+			}
+			createdAt = parsed.UTC().Format(time.RFC3339Nano)
+		}
+		if _, checked := knownRuns[runID]; !checked {
+			runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+			if err != nil {
+				return RecordRunEventsResult{}, err
+			}
+			knownRuns[runID] = ""
+			if len(runs) > 0 {
+				knownRuns[runID] = runs[0].Project
+			}
+		}
+		if knownRuns[runID] == "" {
+			return RecordRunEventsResult{}, domain.NotFound(prefix + "run not found")
+		}
+		events = append(events, domain.RunEvent{
+			ID:        newID("evt"),
+			RunID:     runID,
+			EventType: eventType,
+			Level:     level,
+			Message:   strings.TrimSpace(input.Message),
+			DataJSON:  strings.TrimSpace(input.DataJSON),
+			RequestID: RequestIDFromContext(ctx),
+			CreatedAt: createdAt,
+		})
+	}
+
+	if batch, ok := h.store.(store.BatchInserter); ok {
+		if err := batch.InsertRunEvents(ctx, events); err != nil {
+			return RecordRunEventsResult{}, err
+		}
+	} else {
+		for i, event := range events {
+			if err := h.store.InsertRunEvent(ctx, event); err != nil {
+				return RecordRunEventsResult{Recorded: i}, err
+			}
+		}
+	}
+	for _, event := range events {
+		h.publishRunEvent(knownRuns[event.RunID], event)
+	}
+	return RecordRunEventsResult{Recorded: len(events)}, nil
+}
+
+func (h *HubService) ListRuns(ctx context.Context, request ListRunsRequest) ([]domain.AgentRun, error) {
 	if request.Limit < 0 {
 		return nil, domain.InvalidArgument("limit must be non-negative")
 	}
@@ -1001,18 +2277,38 @@ func (h *HubService) ListRuns(request ListRunsRequest) ([]domain.AgentRun, error
 			return nil, domain.InvalidArgument("started_before must be RFC3339 timestamp")
 		}
 	}
+	labels, err := normalizeMetadata(request.Labels)
+	if err != nil {
+		return nil, err
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	runID := strings.TrimSpace(request.RunID)
+	externalID := strings.TrimSpace(request.ExternalID)
+	taskID := strings.TrimSpace(request.TaskID)
+	startedAfter := strings.TrimSpace(request.StartedAfter)
+	startedBefore := strings.TrimSpace(request.StartedBefore)
+	if runID == "" && externalID == "" && taskID == "" && !request.Starred {
+		startedAfter = h.clampWindow(ctx, startedAfter, startedBefore, "started_after")
+	}
 	filter := domain.RunFilter{
-		RunID:         strings.TrimSpace(request.RunID),
-		TaskID:        strings.TrimSpace(request.TaskID),
+		Project:       project,
+		RunID:         runID,
+		ExternalID:    externalID,
+		TaskID:        taskID,
 		Workflow:      strings.TrimSpace(request.Workflow),
 		AgentID:       strings.TrimSpace(request.AgentID),
 		Status:        strings.TrimSpace(request.Status),
 		PromptVersion: strings.TrimSpace(request.PromptVersion),
-		StartedAfter:  strings.TrimSpace(request.StartedAfter),
-		StartedBefore: strings.TrimSpace(request.StartedBefore),
-		Limit:         request.Limit,
+		StartedAfter:  startedAfter,
+		StartedBefore: startedBefore,
+		Labels:        labels,
+		Limit:         h.storeLimit(request.Limit),
+		Starred:       request.Starred,
 	}
-	items, err := h.store.ListRunsFiltered(filter)
+	items, err := h.store.ListRunsFiltered(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -1022,10 +2318,69 @@ func (h *HubService) ListRuns(request ListRunsRequest) ([]domain.AgentRun, error
 		}
 		return strings.Compare(b.StartedAt, a.StartedAt)
 	})
-	return items, nil
+	return clampItems(ctx, h, items, "runs"), nil
+}
+
+// ListLiveRuns returns running runs, least recently active first. A run's
+// heartbeat is its newest event or attempt, or its start when it has none;
+// runs quiet for longer than StaleAfterSeconds are marked stuck.
+func (h *HubService) ListLiveRuns(ctx context.Context, request ListLiveRunsRequest) ([]domain.LiveRun, error) {
+	staleAfter := request.StaleAfterSeconds
+	if staleAfter == 0 {
+		staleAfter = defaultLiveRunStaleSeconds
+	}
+	if staleAfter < 0 {
+		return nil, domain.InvalidArgument("stale_after_seconds must be non-negative")
+	}
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultLiveRunLimit
+	}
+	if limit < 0 || limit > maxLiveRunLimit {
+		return nil, domain.InvalidArgument(fmt.Sprintf("limit must be between 1 and %d", maxLiveRunLimit))
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, Status: "running", Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	activity, err := h.store.LastRunActivity(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	out := make([]domain.LiveRun, 0, len(runs))
+	heartbeats := make(map[string]time.Time, len(runs))
+	for _, run := range runs {
+		heartbeat, _ := time.Parse(time.RFC3339Nano, run.StartedAt)
+		if at, err := time.Parse(time.RFC3339Nano, activity[run.ID]); err == nil && at.After(heartbeat) {
+			heartbeat = at
+		}
+		heartbeats[run.ID] = heartbeat
+		live := domain.LiveRun{AgentRun: run}
+		if !heartbeat.IsZero() {
+			live.LastHeartbeatAt = heartbeat.UTC().Format(time.RFC3339Nano)
+			live.SecondsSinceHeartbeat = max(0, int64(now.Sub(heartbeat).Seconds()))
+			live.Stuck = live.SecondsSinceHeartbeat > staleAfter
+		}
+		out = append(out, live)
+	}
+	slices.SortFunc(out, func(a, b domain.LiveRun) int {
+		return cmp.Or(heartbeats[a.ID].Compare(heartbeats[b.ID]), strings.Compare(a.ID, b.ID))
+	})
+	return out, nil
 }
 
-func (h *HubService) ListPromptAttempts(request ListPromptAttemptsRequest) ([]domain.PromptAttempt, error) {
+func (h *HubService) ListPromptAttempts(ctx context.Context, request ListPromptAttemptsRequest) ([]domain.PromptAttempt, error) {
 	if request.Limit < 0 {
 		return nil, domain.InvalidArgument("limit must be non-negative")
 	}
@@ -1039,18 +2394,30 @@ func (h *HubService) ListPromptAttempts(request ListPromptAttemptsRequest) ([]do
 			return nil, domain.InvalidArgument("created_before must be RFC3339 timestamp")
 		}
 	}
-	filter := domain.AttemptFilter{
-		RunID:         strings.TrimSpace(request.RunID),
-		Workflow:      strings.TrimSpace(request.Workflow),
-		AgentID:       strings.TrimSpace(request.AgentID),
-		Model:         strings.TrimSpace(request.Model),
-		Outcome:       strings.TrimSpace(request.Outcome),
-		PromptVersion: strings.TrimSpace(request.PromptVersion),
-		CreatedAfter:  strings.TrimSpace(request.CreatedAfter),
-		CreatedBefore: strings.TrimSpace(request.CreatedBefore),
-		Limit:         request.Limit,
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	runID := strings.TrimSpace(request.RunID)
+	createdAfter := strings.TrimSpace(request.CreatedAfter)
+	createdBefore := strings.TrimSpace(request.CreatedBefore)
+	if runID == "" {
+		createdAfter = h.clampWindow(ctx, createdAfter, createdBefore, "created_after")
 	}
-	items, err := h.store.ListPromptAttemptsFiltered(filter)
+	filter := domain.AttemptFilter{
+		Project:         project,
+		RunID:           runID,
+		Workflow:        strings.TrimSpace(request.Workflow),
+		AgentID:         strings.TrimSpace(request.AgentID),
+		Model:           strings.TrimSpace(request.Model),
+		Outcome:         strings.TrimSpace(request.Outcome),
+		PromptVersion:   strings.TrimSpace(request.PromptVersion),
+		CreatedAfter:    createdAfter,
+		CreatedBefore:   createdBefore,
+		Limit:           h.storeLimit(request.Limit),
+		IncludeArchived: request.IncludeArchived,
+	}
+	items, err := h.store.ListPromptAttemptsFiltered(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -1060,13 +2427,19 @@ func (h *HubService) ListPromptAttempts(request ListPromptAttemptsRequest) ([]do
 		}
 		return strings.Compare(b.CreatedAt, a.CreatedAt)
 	})
-	return items, nil
+	return clampItems(ctx, h, items, "attempts"), nil
 }
 
-func (h *HubService) ListRunEvents(request ListRunEventsRequest) ([]domain.RunEvent, error) {
+// ListRunEvents lists run events newest first. With since_id or since_time
+// and wait_seconds it long-polls: when nothing newer exists yet it waits for
+// the next matching event this server records.
+func (h *HubService) ListRunEvents(ctx context.Context, request ListRunEventsRequest) ([]domain.RunEvent, error) {
 	if request.Limit < 0 {
 		return nil, domain.InvalidArgument("limit must be non-negative")
 	}
+	if request.WaitSeconds < 0 {
+		return nil, domain.InvalidArgument("wait_seconds must be non-negative")
+	}
 	if request.CreatedAfter != "" {
 		if _, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(request.CreatedAfter)); err != nil {
 			return nil, domain.InvalidArgument("created_after must be RFC3339 timestamp")
@@ -1077,86 +2450,212 @@ func (h *HubService) ListRunEvents(request ListRunEventsRequest) ([]domain.RunEv
 			return nil, domain.InvalidArgument("created_before must be RFC3339 timestamp")
 		}
 	}
+	cursor, err := parseEventCursor(request.SinceID, request.SinceTime)
+	if err != nil {
+		return nil, err
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	runID := strings.TrimSpace(request.RunID)
+	createdAfter := strings.TrimSpace(request.CreatedAfter)
+	createdBefore := strings.TrimSpace(request.CreatedBefore)
+	if runID == "" {
+		createdAfter = h.clampWindow(ctx, createdAfter, createdBefore, "created_after")
+	}
 	filter := domain.EventFilter{
-		RunID:         strings.TrimSpace(request.RunID),
-		EventType:     strings.TrimSpace(request.EventType),
-		Level:         strings.TrimSpace(request.Level),
-		CreatedAfter:  strings.TrimSpace(request.CreatedAfter),
-		CreatedBefore: strings.TrimSpace(request.CreatedBefore),
-		Limit:         request.Limit,
+		Project:         project,
+		RunID:           runID,
+		EventType:       strings.TrimSpace(request.EventType),
+		Level:           strings.TrimSpace(request.Level),
+		CreatedAfter:    cursor.createdAfter(createdAfter),
+		CreatedBefore:   createdBefore,
+		Limit:           h.storeLimit(request.Limit),
+		IncludeArchived: request.IncludeArchived,
+	}
+	query := func() ([]domain.RunEvent, error) {
+		items, err := h.store.ListRunEventsFiltered(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		items = slices.DeleteFunc(items, func(event domain.RunEvent) bool { return !cursor.matches(event) })
+		sortEventsNewestFirst(items)
+		return items, nil
+	}
+
+	wait := min(time.Duration(request.WaitSeconds)*time.Second, maxRunEventWait)
+	if wait <= 0 || !cursor.set() {
+		items, err := query()
+		if err != nil {
+			return nil, err
+		}
+		return clampItems(ctx, h, items, "events"), nil
 	}
-	items, err := h.store.ListRunEventsFiltered(filter)
+	// Subscribe before reading so an event recorded in between is not lost.
+	updates, unsubscribe := h.SubscribeLive(LiveFilter{Project: project, RunID: runID})
+	defer unsubscribe()
+	items, err := query()
 	if err != nil {
 		return nil, err
 	}
-	slices.SortFunc(items, func(a, b domain.RunEvent) int {
-		if a.CreatedAt == b.CreatedAt {
-			return strings.Compare(b.ID, a.ID)
+	if len(items) == 0 {
+		items, err = h.waitForRunEvents(ctx, updates, wait, filter, cursor, query)
+		if err != nil {
+			return nil, err
 		}
-		return strings.Compare(b.CreatedAt, a.CreatedAt)
-	})
-	return items, nil
+		if request.Limit > 0 && int64(len(items)) > request.Limit {
+			items = items[:request.Limit]
+		}
+	}
+	return clampItems(ctx, h, items, "events"), nil
 }
 
-func (h *HubService) TelemetrySummary() (domain.TelemetrySummary, error) {
-	summary := domain.TelemetrySummary{}
+func (h *HubService) RecordArtifact(ctx context.Context, request RecordArtifactRequest) (domain.Artifact, error) {
+	if h.artifacts == nil {
+		return domain.Artifact{}, domain.FailedPrecondition("artifact storage is not configured")
+	}
+	runID := strings.TrimSpace(request.RunID)
+	name := strings.TrimSpace(request.Name)
+	if runID == "" || name == "" {
+		return domain.Artifact{}, domain.InvalidArgument("run_id and name are required")
+	}
+	kind := strings.TrimSpace(request.Kind)
+	if kind == "" {
+		kind = "file"
+	}
+	if _, ok := validArtifactKinds[kind]; !ok {
+		return domain.Artifact{}, domain.InvalidArgument("kind must be one of: diff, transcript, file, log, other")
+	}
 
-	runs, err := h.store.ListRuns()
-	if err != nil {
-		return summary, err
+	var content []byte
+	contentType := strings.TrimSpace(request.ContentType)
+	switch {
+	case request.Content != "" && request.ContentBase64 != "":
+		return domain.Artifact{}, domain.InvalidArgument("only one of content or content_base64 may be set")
+	case request.ContentBase64 != "":
+		decoded, err := base64.StdEncoding.DecodeString(request.ContentBase64)
+		if err != nil {
+			return domain.Artifact{}, domain.InvalidArgument("content_base64 must be valid base64")
+		}
+		content = decoded
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	case request.Content != "":
+		content = []byte(request.Content)
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+	default:
+		return domain.Artifact{}, domain.InvalidArgument("content or content_base64 is required")
 	}
-	attempts, err := h.store.ListPromptAttempts("")
+	if h.maxArtifactBytes > 0 && int64(len(content)) > h.maxArtifactBytes {
+		return domain.Artifact{}, domain.ResourceExhausted(fmt.Sprintf("artifact exceeds max size of %d bytes", h.maxArtifactBytes))
+	}
+
+	project, err := normalizeProject(request.Project)
 	if err != nil {
-		return summary, err
+		return domain.Artifact{}, err
 	}
-	events, err := h.store.ListRunEvents("")
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
 	if err != nil {
-		return summary, err
+		return domain.Artifact{}, err
+	}
+	if len(runs) == 0 {
+		return domain.Artifact{}, domain.NotFound("run not found")
 	}
 
-	summary.Counts.Runs = int64(len(runs))
-	summary.Counts.Events = int64(len(events))
+	digest := sha256.Sum256(content)
+	artifact := domain.Artifact{
+		ID:          newID("art"),
+		RunID:       runID,
+		Name:        name,
+		Kind:        kind,
+		ContentType: contentType,
+		SizeBytes:   int64(len(content)),
+		SHA256:      hex.EncodeToString(digest[:]),
+		CreatedAt:   timeNow(),
+	}
+	if err := h.artifacts.PutArtifactBlob(artifact.ID, content); err != nil {
+		return domain.Artifact{}, err
+	}
+	if err := h.store.InsertArtifact(ctx, artifact); err != nil {
+		_ = h.artifacts.DeleteArtifactBlob(artifact.ID)
+		return domain.Artifact{}, err
+	}
+	return artifact, nil
+}
 
-	for _, run := range runs {
-		switch run.Status {
-		case "running":
-			summary.Counts.RunningRuns++
-		case "completed":
-			summary.Counts.CompletedRuns++
-		case "failed":
-			summary.Counts.FailedRuns++
-		case "cancelled":
-			summary.Counts.CancelledRuns++
-		}
-	}
-
-	for _, attempt := range attempts {
-		summary.Counts.Attempts++
-		summary.Totals.TokensIn += attempt.TokensIn
-		summary.Totals.TokensOut += attempt.TokensOut
-		summary.Totals.CostUSD += attempt.CostUSD
-		summary.Totals.LatencyMS += attempt.LatencyMS
-
-		if attempt.Outcome == "success" {
-			summary.Counts.SuccessAttempts++
-		} else {
-			summary.Counts.FailedAttempts++
-		}
-		if attempt.AttemptNumber > 1 {
-			summary.Counts.Retries++
-		}
+func (h *HubService) GetArtifact(ctx context.Context, request GetArtifactRequest) (domain.ArtifactContent, error) {
+	if h.artifacts == nil {
+		return domain.ArtifactContent{}, domain.FailedPrecondition("artifact storage is not configured")
+	}
+	id := strings.TrimSpace(request.ID)
+	if id == "" {
+		return domain.ArtifactContent{}, domain.InvalidArgument("id is required")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return domain.ArtifactContent{}, err
+	}
+	items, err := h.store.ListArtifacts(ctx, domain.ArtifactFilter{Project: project, ID: id, Limit: 1})
+	if err != nil {
+		return domain.ArtifactContent{}, err
+	}
+	if len(items) == 0 {
+		return domain.ArtifactContent{}, domain.NotFound("artifact not found")
+	}
+	content, err := h.artifacts.GetArtifactBlob(id)
+	if err != nil {
+		return domain.ArtifactContent{}, err
+	}
+	return domain.ArtifactContent{
+		Artifact:      items[0],
+		ContentBase64: base64.StdEncoding.EncodeToString(content),
+	}, nil
+}
+
+func (h *HubService) ListArtifacts(ctx context.Context, request ListArtifactsRequest) ([]domain.Artifact, error) {
+	if request.Limit < 0 {
+		return nil, domain.InvalidArgument("limit must be non-negative")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
 	}
+	items, err := h.store.ListArtifacts(ctx, domain.ArtifactFilter{
+		Project: project,
+		RunID:   strings.TrimSpace(request.RunID),
+		Kind:    strings.TrimSpace(request.Kind),
+		Limit:   h.storeLimit(request.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(items, func(a, b domain.Artifact) int {
+		if a.CreatedAt == b.CreatedAt {
+			return strings.Compare(b.ID, a.ID)
+		}
+		return strings.Compare(b.CreatedAt, a.CreatedAt)
+	})
+	return clampItems(ctx, h, items, "artifacts"), nil
+}
 
+func (h *HubService) TelemetrySummary(ctx context.Context) (domain.TelemetrySummary, error) {
+	summary, err := h.store.SummarizeTelemetry(ctx)
+	if err != nil {
+		return domain.TelemetrySummary{}, err
+	}
 	if summary.Counts.Attempts > 0 {
 		summary.Averages.AttemptLatencyMS = float64(summary.Totals.LatencyMS) / float64(summary.Counts.Attempts)
 		summary.Averages.CostPerAttempt = summary.Totals.CostUSD / float64(summary.Counts.Attempts)
 		summary.Averages.SuccessRate = float64(summary.Counts.SuccessAttempts) / float64(summary.Counts.Attempts)
 	}
-
 	return summary, nil
 }
 
-func (h *HubService) Leaderboard(request LeaderboardRequest) ([]domain.LeaderboardEntry, error) {
+func (h *HubService) Leaderboard(ctx context.Context, request LeaderboardRequest) ([]domain.LeaderboardEntry, error) {
 	if request.Limit < 0 {
 		return nil, domain.InvalidArgument("limit must be non-negative")
 	}
@@ -1164,73 +2663,47 @@ func (h *HubService) Leaderboard(request LeaderboardRequest) ([]domain.Leaderboa
 		return nil, domain.InvalidArgument("window_days must be non-negative")
 	}
 
-	filter := domain.AttemptFilter{
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+	rollupFilter := domain.RollupFilter{
+		Project:       project,
 		Workflow:      strings.TrimSpace(request.Workflow),
 		Model:         strings.TrimSpace(request.Model),
 		PromptVersion: strings.TrimSpace(request.PromptVersion),
 	}
+	// Whole days come from the daily rollups. A window that starts partway
+	// through a day aggregates that day's remaining attempts directly.
+	var partial []domain.LeaderboardEntry
 	if request.WindowDays > 0 {
-		filter.CreatedAfter = time.Now().UTC().Add(-time.Duration(request.WindowDays) * 24 * time.Hour).Format(time.RFC3339Nano)
+		cutoff := time.Now().UTC().Add(-time.Duration(request.WindowDays) * 24 * time.Hour)
+		firstFullDay := cutoff.Truncate(24 * time.Hour)
+		if firstFullDay.Before(cutoff) {
+			firstFullDay = firstFullDay.Add(24 * time.Hour)
+			partial, err = h.store.LeaderboardAggregate(ctx, domain.AttemptFilter{
+				Project:       rollupFilter.Project,
+				Workflow:      rollupFilter.Workflow,
+				Model:         rollupFilter.Model,
+				PromptVersion: rollupFilter.PromptVersion,
+				CreatedAfter:  cutoff.Format(time.RFC3339Nano),
+				// Postgres keeps microseconds, so stop a microsecond short
+				// of the first whole day.
+				CreatedBefore: firstFullDay.Add(-time.Microsecond).Format(time.RFC3339Nano),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		rollupFilter.FromDay = firstFullDay.Format(time.DateOnly)
 	}
-	attempts, err := h.store.ListPromptAttemptsFiltered(filter)
+	rollups, err := h.store.ListDailyRollups(ctx, rollupFilter)
 	if err != nil {
 		return nil, err
 	}
-
-	type aggregate struct {
-		workflow      string
-		promptVersion string
-		model         string
-		attempts      int64
-		successes     int64
-		failures      int64
-		totalCost     float64
-		totalLatency  int64
-	}
-	grouped := map[string]*aggregate{}
-	for _, item := range attempts {
-		key := strings.Join([]string{item.Workflow, item.PromptVersion, item.Model}, "|")
-		entry, ok := grouped[key]
-		if !ok {
-			entry = &aggregate{
-				workflow:      item.Workflow,
-				promptVersion: item.PromptVersion,
-				model:         item.Model,
-			}
-			grouped[key] = entry
-		}
-		entry.attempts++
-		entry.totalCost += item.CostUSD
-		entry.totalLatency += item.LatencyMS
-		if item.Outcome == "success" {
-			entry.successes++
-		} else {
-			entry.failures++
-		}
-	}
-
-	out := make([]domain.LeaderboardEntry, 0, len(grouped))
-	for _, item := range grouped {
-		if item.attempts == 0 {
-			continue
-		}
-		successRate := float64(item.successes) / float64(item.attempts)
-		avgCost := item.totalCost / float64(item.attempts)
-		avgLatency := float64(item.totalLatency) / float64(item.attempts)
-		score := (successRate * 100.0) - (avgCost * 100.0) - (avgLatency / 1000.0)
-
-		out = append(out, domain.LeaderboardEntry{
-			Workflow:         item.workflow,
-			PromptVersion:    item.promptVersion,
-			Model:            item.model,
-			Attempts:         item.attempts,
-			SuccessAttempts:  item.successes,
-			FailedAttempts:   item.failures,
-			SuccessRate:      successRate,
-			AverageCostUSD:   avgCost,
-			AverageLatencyMS: avgLatency,
-			Score:            score,
-		})
+	out := leaderboardFromRollups(rollups, partial)
+	for i := range out {
+		out[i].Score = (out[i].SuccessRate * 100.0) - (out[i].AverageCostUSD * 100.0) - (out[i].AverageLatencyMS / 1000.0)
 	}
 
 	slices.SortFunc(out, func(a, b domain.LeaderboardEntry) int {
@@ -1256,8 +2729,122 @@ func (h *HubService) Leaderboard(request LeaderboardRequest) ([]domain.Leaderboa
 	return out, nil
 }
 
-func resolveEffectiveLimits(policy domain.OrchestrationPolicy, cap domain.PolicyCap, hasCap bool) effectiveLimits {
-	out := effectiveLimits{
+// leaderboardFromRollups totals rollups and partial entries by workflow,
+// prompt version and model.
+func leaderboardFromRollups(rollups []domain.DailyRollup, partial []domain.LeaderboardEntry) []domain.LeaderboardEntry {
+	type groupKey struct{ workflow, promptVersion, model string }
+	type aggregate struct {
+		attempts     int64
+		successes    int64
+		totalCost    float64
+		totalLatency float64
+	}
+	grouped := map[groupKey]*aggregate{}
+	order := []groupKey{}
+	add := func(key groupKey, attempts, successes int64, cost, latency float64) {
+		entry, ok := grouped[key]
+		if !ok {
+			entry = &aggregate{}
+			grouped[key] = entry
+			order = append(order, key)
+		}
+		entry.attempts += attempts
+		entry.successes += successes
+		entry.totalCost += cost
+		entry.totalLatency += latency
+	}
+	for _, item := range partial {
+		add(groupKey{item.Workflow, item.PromptVersion, item.Model}, item.Attempts, item.SuccessAttempts,
+			item.AverageCostUSD*float64(item.Attempts), item.AverageLatencyMS*float64(item.Attempts))
+	}
+	for _, item := range rollups {
+		add(groupKey{item.Workflow, item.PromptVersion, item.Model}, item.Attempts, item.SuccessAttempts,
+			item.CostUSD, float64(item.LatencyMS))
+	}
+
+	out := make([]domain.LeaderboardEntry, 0, len(order))
+	for _, key := range order {
+		item := grouped[key]
+		if item.attempts == 0 {
+			continue
+		}
+		out = append(out, domain.LeaderboardEntry{
+			Workflow:         key.workflow,
+			PromptVersion:    key.promptVersion,
+			Model:            key.model,
+			Attempts:         item.attempts,
+			SuccessAttempts:  item.successes,
+			FailedAttempts:   item.attempts - item.successes,
+			SuccessRate:      float64(item.successes) / float64(item.attempts),
+			AverageCostUSD:   item.totalCost / float64(item.attempts),
+			AverageLatencyMS: item.totalLatency / float64(item.attempts),
+		})
+	}
+	return out
+}
+
+// CostSeries buckets attempt spend by UTC day and provider/model for charting.
+func (h *HubService) CostSeries(ctx context.Context, request CostSeriesRequest) ([]domain.CostSeriesPoint, error) {
+	windowDays := request.WindowDays
+	if windowDays == 0 {
+		windowDays = defaultCostSeriesWindowDays
+	}
+	if windowDays < 0 || windowDays > maxCostSeriesWindowDays {
+		return nil, domain.InvalidArgument(fmt.Sprintf("window_days must be between 1 and %d", maxCostSeriesWindowDays))
+	}
+
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -int(windowDays-1))
+	rollups, err := h.store.ListDailyRollups(ctx, domain.RollupFilter{
+		Project:  project,
+		Workflow: strings.TrimSpace(request.Workflow),
+		FromDay:  start.Format(time.DateOnly),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := map[string]*domain.CostSeriesPoint{}
+	for _, item := range rollups {
+		key := strings.Join([]string{item.Day, item.ProviderType, item.Provider, item.Model}, "|")
+		point, ok := grouped[key]
+		if !ok {
+			point = &domain.CostSeriesPoint{
+				Bucket:       item.Day,
+				ProviderType: item.ProviderType,
+				Provider:     item.Provider,
+				Model:        item.Model,
+			}
+			grouped[key] = point
+		}
+		point.Attempts += item.Attempts
+		point.TokensIn += item.TokensIn
+		point.TokensOut += item.TokensOut
+		point.CostUSD += item.CostUSD
+	}
+
+	out := make([]domain.CostSeriesPoint, 0, len(grouped))
+	for _, point := range grouped {
+		out = append(out, *point)
+	}
+	slices.SortFunc(out, func(a, b domain.CostSeriesPoint) int {
+		if a.Bucket != b.Bucket {
+			return strings.Compare(a.Bucket, b.Bucket)
+		}
+		if a.Provider != b.Provider {
+			return strings.Compare(a.Provider, b.Provider)
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return out, nil
+}
+
+func resolveEffectiveLimits(policy domain.OrchestrationPolicy, cap domain.PolicyCap, hasCap bool) EffectiveLimits {
+	out := EffectiveLimits{
 		MaxCostPerRunUSD:       policy.MaxCostPerRunUSD,
 		MaxAttemptsPerRun:      policy.MaxAttemptsPerRun,
 		MaxTokensPerRun:        policy.MaxTokensPerRun,
@@ -1288,16 +2875,77 @@ func resolveEffectiveLimits(policy domain.OrchestrationPolicy, cap domain.Policy
 	if cap.MaxTokensPerAttempt > 0 {
 		out.MaxTokensPerAttempt = cap.MaxTokensPerAttempt
 	}
+	out.MaxCostPerDayUSD = cap.MaxCostPerDayUSD
+	out.MaxCostPerMonthUSD = cap.MaxCostPerMonthUSD
+	out.DryRun = cap.DryRun
 	return out
 }
 
-func selectPolicyCap(caps []domain.PolicyCap, providerType, provider, model string) (domain.PolicyCap, bool) {
+// agentWindowSpend sums an agent's attempt cost over the rolling 24h and 30d
+// windows, counting only attempts the cap itself would match.
+func agentWindowSpend(ctx context.Context, reader store.AttemptReader, cap domain.PolicyCap, agentID string) (float64, float64, error) {
+	now := time.Now().UTC()
+	dayStart := now.Add(-24 * time.Hour).Format(time.RFC3339Nano)
+	monthStart := now.Add(-30 * 24 * time.Hour).Format(time.RFC3339Nano)
+	attempts, err := reader.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{
+		Project:      cap.Project,
+		Workflow:     cap.Workflow,
+		AgentID:      agentID,
+		Model:        cap.Model,
+		CreatedAfter: monthStart,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	day := newCreatedRange(dayStart, "")
+	var daySpend, monthSpend float64
+	for _, item := range attempts {
+		if cap.ProviderType != "" && item.ProviderType != cap.ProviderType {
+			continue
+		}
+		if cap.Provider != "" && item.Provider != cap.Provider {
+			continue
+		}
+		monthSpend += item.CostUSD
+		if day.contains(item.CreatedAt) {
+			daySpend += item.CostUSD
+		}
+	}
+	return daySpend, monthSpend, nil
+}
+
+// hubWindowSpend sums attempt cost across all agents over the rolling 1h and
+// 24h windows used by the global policy's spend limits.
+func hubWindowSpend(ctx context.Context, reader store.AttemptReader) (float64, float64, error) {
+	now := time.Now().UTC()
+	hourStart := now.Add(-time.Hour).Format(time.RFC3339Nano)
+	dayStart := now.Add(-24 * time.Hour).Format(time.RFC3339Nano)
+	attempts, err := reader.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{CreatedAfter: dayStart})
+	if err != nil {
+		return 0, 0, err
+	}
+	hour := newCreatedRange(hourStart, "")
+	var hourSpend, daySpend float64
+	for _, item := range attempts {
+		daySpend += item.CostUSD
+		if hour.contains(item.CreatedAt) {
+			hourSpend += item.CostUSD
+		}
+	}
+	return hourSpend, daySpend, nil
+}
+
+func selectPolicyCap(caps []domain.PolicyCap, project, workflow, agentID, providerType, provider, model string) (domain.PolicyCap, bool) {
 	var selected domain.PolicyCap
 	found := false
 	bestSpecificity := int64(-1)
 	bestPriority := int64(-1 << 62)
+	now := time.Now().UTC()
 	for _, cap := range caps {
-		if !cap.IsActive {
+		if cap.Kind == domain.PolicyCapKindRouting || !cap.IsActive || !policyCapInEffect(cap, now) {
+			continue
+		}
+		if cap.Project != "" && cap.Project != project {
 			continue
 		}
 		if cap.ProviderType != "" && cap.ProviderType != providerType {
@@ -1309,6 +2957,12 @@ func selectPolicyCap(caps []domain.PolicyCap, providerType, provider, model stri
 		if cap.Model != "" && cap.Model != model {
 			continue
 		}
+		if cap.Workflow != "" && cap.Workflow != workflow {
+			continue
+		}
+		if cap.AgentID != "" && cap.AgentID != agentID {
+			continue
+		}
 
 		specificity := int64(0)
 		if cap.ProviderType != "" {
@@ -1320,6 +2974,12 @@ func selectPolicyCap(caps []domain.PolicyCap, providerType, provider, model stri
 		if cap.Model != "" {
 			specificity++
 		}
+		if cap.Workflow != "" {
+			specificity++
+		}
+		if cap.AgentID != "" {
+			specificity++
+		}
 
 		if !found || specificity > bestSpecificity || (specificity == bestSpecificity && cap.Priority > bestPriority) {
 			selected = cap
@@ -1331,24 +2991,56 @@ func selectPolicyCap(caps []domain.PolicyCap, providerType, provider, model stri
 	return selected, found
 }
 
-func (h *HubService) logPolicyCapDryRunViolation(runID string, cap domain.PolicyCap, message string) {
+// policyCapInEffect reports whether now falls inside the cap's validity window.
+func policyCapInEffect(cap domain.PolicyCap, now time.Time) bool {
+	if cap.ValidFrom != "" {
+		if validFrom, err := time.Parse(time.RFC3339, cap.ValidFrom); err == nil && now.Before(validFrom) {
+			return false
+		}
+	}
+	if cap.ValidUntil != "" {
+		if validUntil, err := time.Parse(time.RFC3339, cap.ValidUntil); err == nil && !now.Before(validUntil) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOptionalTimestamp validates an RFC3339 request field and rewrites
+// it to UTC; empty clears the value.
+func normalizeOptionalTimestamp(raw, field string) (string, error) {
+	clean := strings.TrimSpace(raw)
+	if clean == "" {
+		return "", nil
+	}
+	parsed, err := time.Parse(time.RFC3339, clean)
+	if err != nil {
+		return "", domain.InvalidArgument(field + " must be RFC3339")
+	}
+	return parsed.UTC().Format(time.RFC3339), nil
+}
+
+func (h *HubService) logPolicyCapDryRunViolation(ctx context.Context, runID string, cap domain.PolicyCap, message string) {
 	payload := map[string]any{
 		"cap_id":        cap.ID,
 		"cap_name":      cap.Name,
 		"provider_type": cap.ProviderType,
 		"provider":      cap.Provider,
 		"model":         cap.Model,
+		"workflow":      cap.Workflow,
+		"agent_id":      cap.AgentID,
 		"priority":      cap.Priority,
 		"dry_run":       cap.DryRun,
 	}
 	serialized, _ := json.Marshal(payload)
-	_ = h.store.InsertRunEvent(domain.RunEvent{
+	_ = h.store.InsertRunEvent(ctx, domain.RunEvent{
 		ID:        newID("evt"),
 		RunID:     runID,
 		EventType: "policy_cap_violation_dry_run",
 		Level:     "warn",
 		Message:   message,
 		DataJSON:  string(serialized),
+		RequestID: RequestIDFromContext(ctx),
 		CreatedAt: timeNow(),
 	})
 }
@@ -1374,6 +3066,59 @@ func normalizeTags(tags []string) []string {
 	return out
 }
 
+func normalizeMetadata(metadata map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		cleanKey := strings.TrimSpace(key)
+		if cleanKey == "" {
+			return nil, domain.InvalidArgument("metadata keys must be non-empty")
+		}
+		if len(cleanKey) > maxMetadataKeyLength || len(value) > maxMetadataValueLength {
+			return nil, domain.InvalidArgument(fmt.Sprintf("metadata keys must be at most %d bytes and values at most %d bytes", maxMetadataKeyLength, maxMetadataValueLength))
+		}
+		out[cleanKey] = strings.TrimSpace(value)
+	}
+	if len(out) > maxMetadataEntries {
+		return nil, domain.InvalidArgument(fmt.Sprintf("metadata supports at most %d entries", maxMetadataEntries))
+	}
+	return out, nil
+}
+
+// normalizeProject validates an optional project name. An empty result means
+// "unscoped": list calls span every project and by-id calls skip the check.
+func normalizeProject(raw string) (string, error) {
+	project := strings.ToLower(strings.TrimSpace(raw))
+	if project == "" {
+		return "", nil
+	}
+	if len(project) > maxProjectNameLength {
+		return "", domain.InvalidArgument(fmt.Sprintf("project must be at most %d characters", maxProjectNameLength))
+	}
+	for _, r := range project {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return "", domain.InvalidArgument("project may only contain lowercase letters, digits, '-' and '_'")
+		}
+	}
+	return project, nil
+}
+
+// projectForCreate resolves the project stamped on new records.
+func projectForCreate(raw string) (string, error) {
+	project, err := normalizeProject(raw)
+	if err != nil {
+		return "", err
+	}
+	if project == "" {
+		return domain.DefaultProject, nil
+	}
+	return project, nil
+}
+
+// inProject reports whether a record belongs to the requested project scope.
+func inProject(recordProject, requested string) bool {
+	return requested == "" || recordProject == requested
+}
+
 func timeNow() string {
 	return time.Now().UTC().Format(time.RFC3339Nano)
 }
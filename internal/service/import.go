@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+const maxImportBatch = 500
+
+// ImportTelemetryRequest loads historical prompt attempts and benchmarks
+// exported from other tools, up to maxImportBatch records per call.
+// Attempts without a run_id are attached to synthetic runs, one per import,
+// workflow, agent, prompt version and source run, so later calls with the
+// same ImportID add to the same runs.
+type ImportTelemetryRequest struct {
+	writeRequest
+	Project    string                 `json:"project"`
+	ImportID   string                 `json:"import_id"`
+	Attempts   []ImportAttemptInput   `json:"attempts"`
+	Benchmarks []ImportBenchmarkInput `json:"benchmarks"`
+}
+
+// ImportAttemptInput is an attempt as RecordPromptAttempt takes it, plus its
+// original time and, for attempts without run_id, the other tool's run ID to
+// group them by. A missing attempt_number continues the run's numbering.
+type ImportAttemptInput struct {
+	RecordPromptAttemptRequest
+	SourceRunID string `json:"source_run_id"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ImportBenchmarkInput struct {
+	RecordBenchmarkRequest
+	CreatedAt string `json:"created_at"`
+}
+
+type ImportTelemetryResult struct {
+	Attempts      int      `json:"attempts"`
+	Benchmarks    int      `json:"benchmarks"`
+	SyntheticRuns []string `json:"synthetic_runs"`
+}
+
+// importRun is a run the batch's attempts go to and what the batch adds to
+// it.
+type importRun struct {
+	run       domain.AgentRun
+	exists    bool
+	synthetic bool
+	// status is what the run is finished as after the call: completed for
+	// synthetic runs, the status it had for the others.
+	status string
+	// stored counts the run's attempts before this call, to number new ones.
+	stored   int64
+	attempts int64
+	earliest string
+	latest   string
+}
+
+// ImportTelemetry validates every record before writing any, so one bad
+// record rejects the call with its index, as RecordRunEvents does. Imported
+// rows keep their created_at and skip the kill switch and policy caps, which
+// guard live spend, so attempts with a run_id need that run to be finished:
+// a running one has to record through RecordPromptAttempt. The call reopens
+// the runs it writes to and finishes them again afterwards, synthetic runs
+// as completed and the others with the status they had, with totals and
+// timing covering every attempt imported into them so far. If a write
+// fails, the reopened runs are finished again before the error is returned.
+func (h *HubService) ImportTelemetry(ctx context.Context, request ImportTelemetryRequest) (ImportTelemetryResult, error) {
+	total := len(request.Attempts) + len(request.Benchmarks)
+	if total == 0 {
+		return ImportTelemetryResult{}, domain.InvalidArgument("attempts or benchmarks are required")
+	}
+	if total > maxImportBatch {
+		return ImportTelemetryResult{}, domain.InvalidArgument(fmt.Sprintf("at most %d records per call", maxImportBatch))
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return ImportTelemetryResult{}, err
+	}
+	importID := strings.TrimSpace(request.ImportID)
+
+	now := time.Now()
+	runs := map[string]*importRun{}
+	attempts := make([]domain.PromptAttempt, 0, len(request.Attempts))
+	for i, input := range request.Attempts {
+		prefix := fmt.Sprintf("attempts[%d]: ", i)
+		attempt, err := importedAttempt(input, now)
+		if err != nil {
+			return ImportTelemetryResult{}, withRecordIndex(prefix, err)
+		}
+		run, err := h.importRunFor(ctx, runs, project, importID, input, attempt)
+		if err != nil {
+			return ImportTelemetryResult{}, withRecordIndex(prefix, err)
+		}
+		run.attempts++
+		if attempt.AttemptNumber == 0 {
+			attempt.AttemptNumber = run.stored + run.attempts
+		}
+		attempt.RunID = run.run.ID
+		attempt.Project = run.run.Project
+		if attempt.Workflow == "" {
+			attempt.Workflow = run.run.Workflow
+		}
+		if attempt.AgentID == "" {
+			attempt.AgentID = run.run.AgentID
+		}
+		if run.earliest == "" || laterTimestamp(run.earliest, attempt.CreatedAt) {
+			run.earliest = attempt.CreatedAt
+		}
+		if run.latest == "" || laterTimestamp(attempt.CreatedAt, run.latest) {
+			run.latest = attempt.CreatedAt
+		}
+		attempts = append(attempts, attempt)
+	}
+	benchmarks := make([]domain.Benchmark, 0, len(request.Benchmarks))
+	for i, input := range request.Benchmarks {
+		prefix := fmt.Sprintf("benchmarks[%d]: ", i)
+		createdAt, err := importedTimestamp(input.CreatedAt, now)
+		if err != nil {
+			return ImportTelemetryResult{}, withRecordIndex(prefix, err)
+		}
+		benchmark, err := newBenchmark(input.RecordBenchmarkRequest, createdAt)
+		if err != nil {
+			return ImportTelemetryResult{}, withRecordIndex(prefix, err)
+		}
+		benchmarks = append(benchmarks, benchmark)
+	}
+
+	result := ImportTelemetryResult{SyntheticRuns: []string{}}
+	opened := make([]*importRun, 0, len(runs))
+	fail := func(err error) (ImportTelemetryResult, error) {
+		for _, run := range opened {
+			if finishErr := h.finishImportRun(ctx, run); finishErr != nil {
+				slog.Error("import could not finish a reopened run", "run_id", run.run.ID, "err", finishErr)
+			}
+		}
+		return result, err
+	}
+	for _, run := range runs {
+		if err := h.openImportRun(ctx, run); err != nil {
+			return fail(err)
+		}
+		opened = append(opened, run)
+		if run.synthetic {
+			result.SyntheticRuns = append(result.SyntheticRuns, run.run.ID)
+		}
+	}
+	if batch, ok := h.store.(store.BatchInserter); ok {
+		if err := batch.InsertPromptAttempts(ctx, attempts); err != nil {
+			return fail(err)
+		}
+		result.Attempts = len(attempts)
+	} else {
+		for _, attempt := range attempts {
+			if err := h.store.InsertPromptAttempt(ctx, attempt); err != nil {
+				return fail(err)
+			}
+			result.Attempts++
+		}
+	}
+	for len(opened) > 0 {
+		run := opened[0]
+		if err := h.finishImportRun(ctx, run); err != nil {
+			return fail(err)
+		}
+		opened = opened[1:]
+	}
+	for _, benchmark := range benchmarks {
+		if err := h.store.InsertBenchmark(ctx, benchmark); err != nil {
+			return result, err
+		}
+		result.Benchmarks++
+	}
+	return result, nil
+}
+
+// importedAttempt validates input the way RecordPromptAttempt does and
+// builds its row; the caller fills in the run.
+func importedAttempt(input ImportAttemptInput, now time.Time) (domain.PromptAttempt, error) {
+	outcome := strings.TrimSpace(input.Outcome)
+	model := strings.TrimSpace(input.Model)
+	if outcome == "" || model == "" {
+		return domain.PromptAttempt{}, domain.InvalidArgument("outcome and model are required")
+	}
+	if _, ok := validAttemptOutcomes[outcome]; !ok {
+		return domain.PromptAttempt{}, domain.InvalidArgument("outcome must be one of: success, failed, timeout, retryable_error, tool_error")
+	}
+	providerType := strings.TrimSpace(input.ProviderType)
+	if providerType == "" {
+		providerType = "api"
+	}
+	if _, ok := validProviderTypes[providerType]; !ok {
+		return domain.PromptAttempt{}, domain.InvalidArgument("provider_type must be one of: api, subscription, opensource")
+	}
+	if input.AttemptNumber < 0 {
+		return domain.PromptAttempt{}, domain.InvalidArgument("attempt_number must be positive when set")
+	}
+	if input.TokensIn < 0 || input.TokensOut < 0 || input.CostUSD < 0 || input.LatencyMS < 0 {
+		return domain.PromptAttempt{}, domain.InvalidArgument("tokens, cost, and latency must be non-negative")
+	}
+	metadata, err := normalizeMetadata(input.Metadata)
+	if err != nil {
+		return domain.PromptAttempt{}, err
+	}
+	createdAt, err := importedTimestamp(input.CreatedAt, now)
+	if err != nil {
+		return domain.PromptAttempt{}, err
+	}
+	return domain.PromptAttempt{
+		ID:            newID("pat"),
+		AttemptNumber: input.AttemptNumber,
+		Workflow:      strings.TrimSpace(input.Workflow),
+		AgentID:       strings.TrimSpace(input.AgentID),
+		ProviderType:  providerType,
+		Provider:      strings.TrimSpace(input.Provider),
+		Model:         model,
+		PromptVersion: strings.TrimSpace(input.PromptVersion),
+		PromptHash:    strings.TrimSpace(input.PromptHash),
+		Outcome:       outcome,
+		ErrorType:     strings.TrimSpace(input.ErrorType),
+		ErrorMessage:  strings.TrimSpace(input.ErrorMessage),
+		TokensIn:      input.TokensIn,
+		TokensOut:     input.TokensOut,
+		CostUSD:       input.CostUSD,
+		LatencyMS:     input.LatencyMS,
+		QualityScore:  input.QualityScore,
+		Metadata:      metadata,
+		CreatedAt:     createdAt,
+	}, nil
+}
+
+// importedTimestamp is raw normalized to UTC RFC3339, or now when raw is
+// empty. Times in the future are refused.
+func importedTimestamp(raw string, now time.Time) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return now.UTC().Format(time.RFC3339Nano), nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return "", domain.InvalidArgument("created_at must be RFC3339 timestamp")
+	}
+	if parsed.After(now) {
+		return "", domain.InvalidArgument("created_at must not be in the future")
+	}
+	return parsed.UTC().Format(time.RFC3339Nano), nil
+}
+
+// importRunFor returns the run input's attempt goes to: its run_id's run,
+// which must be finished, or the synthetic run for its group.
+func (h *HubService) importRunFor(ctx context.Context, runs map[string]*importRun, project, importID string, input ImportAttemptInput, attempt domain.PromptAttempt) (*importRun, error) {
+	runID := strings.TrimSpace(input.RunID)
+	synthetic := runID == ""
+	runProject := ""
+	if synthetic {
+		if attempt.Workflow == "" || attempt.AgentID == "" {
+			return nil, domain.InvalidArgument("workflow and agent_id are required without run_id")
+		}
+		if importID == "" {
+			return nil, domain.InvalidArgument("import_id is required for attempts without run_id")
+		}
+		var err error
+		if runProject, err = projectForCreate(project); err != nil {
+			return nil, err
+		}
+		runID = importRunID(runProject, importID, attempt.Workflow, attempt.AgentID, attempt.PromptVersion, strings.TrimSpace(input.SourceRunID))
+	}
+	if run, ok := runs[runID]; ok {
+		return run, nil
+	}
+	found, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	run := &importRun{synthetic: synthetic, status: "completed"}
+	switch {
+	case len(found) > 0:
+		run.run, run.exists = found[0], true
+		if !synthetic {
+			if run.run.Status == "running" {
+				return nil, domain.FailedPrecondition("run is still running; record its attempts with RecordPromptAttempt")
+			}
+			run.status = run.run.Status
+		}
+		attempts, err := h.store.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{RunID: runID})
+		if err != nil {
+			return nil, err
+		}
+		run.stored = int64(len(attempts))
+	case !synthetic:
+		return nil, domain.NotFound("run not found")
+	default:
+		metadata := map[string]string{"import_id": importID}
+		if source := strings.TrimSpace(input.SourceRunID); source != "" {
+			metadata["source_run_id"] = source
+		}
+		run.run = domain.AgentRun{
+			ID:            runID,
+			Project:       runProject,
+			Workflow:      attempt.Workflow,
+			AgentID:       attempt.AgentID,
+			PromptVersion: attempt.PromptVersion,
+			Status:        "running",
+			Metadata:      metadata,
+		}
+	}
+	runs[runID] = run
+	return run, nil
+}
+
+// importRunID derives a synthetic run's ID from its group, so every call of
+// an import finds the same run.
+func importRunID(project, importID, workflow, agentID, promptVersion, sourceRunID string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{project, importID, workflow, agentID, promptVersion, sourceRunID}, "\x00")))
+	return "run_import_" + hex.EncodeToString(sum[:12])
+}
+
+// openImportRun creates a synthetic run, or reopens a finished one, so the
+// store accepts its new attempts.
+func (h *HubService) openImportRun(ctx context.Context, run *importRun) error {
+	if !run.exists {
+		run.run.StartedAt = run.earliest
+		return h.store.InsertRun(ctx, run.run)
+	}
+	if laterTimestamp(run.run.StartedAt, run.earliest) {
+		run.run.StartedAt = run.earliest
+	}
+	run.run.Status = "running"
+	return h.store.UpdateRun(ctx, run.run)
+}
+
+// finishImportRun finishes a run again as run.status over the attempts
+// imported into it so far.
+func (h *HubService) finishImportRun(ctx context.Context, run *importRun) error {
+	finished := run.latest
+	if run.exists && laterTimestamp(run.run.FinishedAt, finished) {
+		finished = run.run.FinishedAt
+	}
+	run.run.Status = run.status
+	run.run.FinishedAt = finished
+	run.run.DurationMS = 0
+	startedAt, startErr := time.Parse(time.RFC3339Nano, run.run.StartedAt)
+	finishedAt, finishErr := time.Parse(time.RFC3339Nano, finished)
+	if startErr == nil && finishErr == nil {
+		run.run.DurationMS = finishedAt.Sub(startedAt).Milliseconds()
+	}
+	_, err := h.store.FinalizeRun(ctx, run.run)
+	return err
+}
+
+// withRecordIndex prefixes a validation error with the record it is about,
+// keeping its code.
+func withRecordIndex(prefix string, err error) error {
+	appErr, ok := domain.AsAppError(err)
+	if !ok || appErr.Code == domain.CodeInternal {
+		return err
+	}
+	return &domain.AppError{Code: appErr.Code, Message: prefix + appErr.Message, Cause: appErr.Cause}
+}
+
+// laterTimestamp reports whether RFC3339 timestamp a is after b. An
+// unparsable timestamp is never later.
+func laterTimestamp(a, b string) bool {
+	at, errA := time.Parse(time.RFC3339Nano, a)
+	bt, errB := time.Parse(time.RFC3339Nano, b)
+	return errA == nil && errB == nil && at.After(bt)
+}
@@ -0,0 +1,469 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+// ingestMaxFailures is how many flushes a record may fail before it is
+// dropped, so one bad row cannot hold back the rest of the buffer.
+const ingestMaxFailures = 5
+
+// IngestBufferConfig bounds the telemetry write buffer. Attempts and run
+// events are queued and written in batches of BatchSize, at least every
+// FlushInterval; once MaxPending records are queued, the next write flushes
+// inline before it returns.
+type IngestBufferConfig struct {
+	MaxPending    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// EnableIngestBuffer makes RecordPromptAttempt and RecordRunEvent return
+// before their rows reach the store. Over a store with guarded inserts
+// (Postgres, sharded) attempts are still written synchronously so budget
+// checks stay atomic; elsewhere queued attempts count toward cap checks.
+// Batch writes (RecordRunEvents, ImportTelemetry) go straight to the store.
+// Reads whose results depend on every row (counts, aggregates, limited
+// lists, FinishRun totals, exports) flush the buffer first. Records queued
+// when the process dies are lost; call StopIngestBuffer on shutdown to
+// write them out.
+func (h *HubService) EnableIngestBuffer(cfg IngestBufferConfig) {
+	if h.ingest != nil || cfg.MaxPending <= 0 {
+		return
+	}
+	if cfg.BatchSize <= 0 || cfg.BatchSize > cfg.MaxPending {
+		cfg.BatchSize = cfg.MaxPending
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	h.ingest = newIngestStore(h.store, cfg)
+	h.store = h.ingest
+}
+
+// StopIngestBuffer stops background flushing and writes out everything still
+// queued. Writes after it go straight to the store.
+func (h *HubService) StopIngestBuffer() error {
+	if h.ingest == nil {
+		return nil
+	}
+	return h.ingest.stop()
+}
+
+type pendingRecord[T any] struct {
+	record   T
+	failures int
+}
+
+// ingestQueue is one record type's pending rows, oldest first.
+type ingestQueue[T any] struct {
+	kind        string
+	items       []pendingRecord[T]
+	insertOne   func(context.Context, T) error
+	insertBatch func(context.Context, []T) error
+}
+
+// ingestStore wraps a HubStore, queueing attempt and run event inserts and
+// flushing them in the background.
+type ingestStore struct {
+	store.HubStore
+	cfg IngestBufferConfig
+
+	mu       sync.Mutex
+	attempts ingestQueue[domain.PromptAttempt]
+	events   ingestQueue[domain.RunEvent]
+
+	// flushMu serializes flushes so rows reach the store in queue order.
+	flushMu  sync.Mutex
+	kick     chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	closed   bool
+}
+
+func newIngestStore(inner store.HubStore, cfg IngestBufferConfig) *ingestStore {
+	s := &ingestStore{
+		HubStore: inner,
+		cfg:      cfg,
+		attempts: ingestQueue[domain.PromptAttempt]{kind: "prompt attempt", insertOne: inner.InsertPromptAttempt},
+		events:   ingestQueue[domain.RunEvent]{kind: "run event", insertOne: inner.InsertRunEvent},
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if batch, ok := inner.(store.BatchInserter); ok {
+		s.attempts.insertBatch = batch.InsertPromptAttempts
+		s.events.insertBatch = batch.InsertRunEvents
+	}
+	go s.run()
+	return s
+}
+
+func (s *ingestStore) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		if err := s.flush(); err != nil {
+			slog.Error("ingest buffer flush failed", "err", err)
+		}
+	}
+}
+
+func (s *ingestStore) stop() error {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+		<-s.stopped
+	})
+	return s.flush()
+}
+
+func (s *ingestStore) Close() error {
+	if err := s.stop(); err != nil {
+		slog.Error("ingest buffer flush on close failed", "err", err)
+	}
+	return s.HubStore.Close()
+}
+
+func (s *ingestStore) InsertPromptAttempt(ctx context.Context, attempt domain.PromptAttempt) error {
+	return enqueue(ctx, s, &s.attempts, attempt)
+}
+
+func (s *ingestStore) InsertRunEvent(ctx context.Context, event domain.RunEvent) error {
+	return enqueue(ctx, s, &s.events, event)
+}
+
+// InsertPromptAttemptGuarded keeps budget checks atomic with the buffer on:
+// it writes what is queued, then runs the inner store's guarded insert, so
+// the check sees every earlier attempt and the attempt itself skips the
+// queue. Over a store without guarded inserts the check reads through the
+// wrapper, queued attempts included, and the attempt is queued as usual.
+func (s *ingestStore) InsertPromptAttemptGuarded(ctx context.Context, attempt domain.PromptAttempt, locks store.AttemptLocks, check func(store.AttemptReader) error) error {
+	guarded, ok := s.HubStore.(store.GuardedAttemptInserter)
+	if !ok {
+		if err := check(s); err != nil {
+			return err
+		}
+		return s.InsertPromptAttempt(ctx, attempt)
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return guarded.InsertPromptAttemptGuarded(ctx, attempt, locks, check)
+}
+
+// InsertPromptAttempts and InsertRunEvents write a caller's batch straight
+// to the store after what is queued, using its batch insert when it has
+// one, rather than queueing the rows one by one.
+func (s *ingestStore) InsertPromptAttempts(ctx context.Context, attempts []domain.PromptAttempt) error {
+	return writeThrough(ctx, s, &s.attempts, attempts)
+}
+
+func (s *ingestStore) InsertRunEvents(ctx context.Context, events []domain.RunEvent) error {
+	return writeThrough(ctx, s, &s.events, events)
+}
+
+func writeThrough[T any](ctx context.Context, s *ingestStore, queue *ingestQueue[T], records []T) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if queue.insertBatch != nil {
+		return queue.insertBatch(ctx, records)
+	}
+	for _, record := range records {
+		if err := queue.insertOne(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func enqueue[T any](ctx context.Context, s *ingestStore, queue *ingestQueue[T], record T) error {
+	s.mu.Lock()
+	closed := s.closed
+	full := s.pendingLocked() >= s.cfg.MaxPending
+	s.mu.Unlock()
+	if closed {
+		return queue.insertOne(ctx, record)
+	}
+	if full {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	// stop may have run its final flush while this one did; appending after
+	// it would leave the record queued with nothing left to write it.
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return queue.insertOne(ctx, record)
+	}
+	queue.items = append(queue.items, pendingRecord[T]{record: record})
+	ready := len(queue.items) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if ready {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *ingestStore) pendingLocked() int {
+	return len(s.attempts.items) + len(s.events.items)
+}
+
+// flush writes every queued record, stopping at the first batch that leaves
+// records behind. The queue holds other callers' rows too, so a flush does
+// not stop when the request that triggered it is cancelled.
+func (s *ingestStore) flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	ctx := context.Background()
+	if err := flushQueue(ctx, s, &s.attempts); err != nil {
+		return err
+	}
+	return flushQueue(ctx, s, &s.events)
+}
+
+func flushQueue[T any](ctx context.Context, s *ingestStore, queue *ingestQueue[T]) error {
+	for {
+		// Rows stay queued until written, so reads keep seeing them; only
+		// this flush removes from the front while writers append behind it.
+		s.mu.Lock()
+		count := min(len(queue.items), s.cfg.BatchSize)
+		batch := append([]pendingRecord[T]{}, queue.items[:count]...)
+		s.mu.Unlock()
+		if count == 0 {
+			return nil
+		}
+
+		failed, err := writeBatch(ctx, queue, batch)
+		kept := []pendingRecord[T]{}
+		for _, item := range failed {
+			item.failures++
+			if item.failures >= ingestMaxFailures {
+				slog.Error("ingest buffer dropped a write after repeated failures", "kind", queue.kind, "failures", item.failures)
+				continue
+			}
+			kept = append(kept, item)
+		}
+		s.mu.Lock()
+		queue.items = append(kept, queue.items[count:]...)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeBatch writes batch in one call when the store supports it, and falls
+// back to row by row so one bad row does not fail the others. It returns the
+// records that were not written.
+func writeBatch[T any](ctx context.Context, queue *ingestQueue[T], batch []pendingRecord[T]) ([]pendingRecord[T], error) {
+	if queue.insertBatch != nil {
+		records := make([]T, len(batch))
+		for i, item := range batch {
+			records[i] = item.record
+		}
+		if err := queue.insertBatch(ctx, records); err == nil {
+			return nil, nil
+		}
+	}
+	var failed []pendingRecord[T]
+	var firstErr error
+	for _, item := range batch {
+		if err := queue.insertOne(ctx, item.record); err != nil {
+			if rejected(err) {
+				// Retrying cannot help, e.g. an attempt queued just before
+				// its run finished.
+				slog.Warn("ingest buffer dropped a write rejected by the store", "kind", queue.kind, "err", err)
+				continue
+			}
+			failed = append(failed, item)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return failed, firstErr
+}
+
+// rejected reports whether the store refused a record outright rather than
+// failing to write it.
+func rejected(err error) bool {
+	appErr, ok := domain.AsAppError(err)
+	return ok && (appErr.Code == domain.CodeNotFound || appErr.Code == domain.CodeFailedPrecondition)
+}
+
+// ListPromptAttemptsFiltered answers unlimited queries, which the service
+// uses for cap checks, from the store plus matching queued attempts without
+// waiting on a flush. Limited queries flush first so the limit applies to
+// the full set.
+func (s *ingestStore) ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	if filter.Limit > 0 {
+		if err := s.flush(); err != nil {
+			return nil, err
+		}
+		return s.HubStore.ListPromptAttemptsFiltered(ctx, filter)
+	}
+	// Snapshot the queue before reading the store: a flush in between moves
+	// rows into the store, and the ID check drops those duplicates.
+	created := newCreatedRange(filter.CreatedAfter, filter.CreatedBefore)
+	s.mu.Lock()
+	pending := make([]domain.PromptAttempt, 0, len(s.attempts.items))
+	for _, item := range s.attempts.items {
+		if attemptMatchesFilter(item.record, filter, created) {
+			pending = append(pending, item.record)
+		}
+	}
+	s.mu.Unlock()
+
+	items, err := s.HubStore.ListPromptAttemptsFiltered(ctx, filter)
+	if err != nil || len(pending) == 0 {
+		return items, err
+	}
+	stored := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		stored[item.ID] = struct{}{}
+	}
+	for _, item := range pending {
+		if _, ok := stored[item.ID]; !ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *ingestStore) ListPromptAttempts(ctx context.Context, runID string) ([]domain.PromptAttempt, error) {
+	return s.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{RunID: runID})
+}
+
+func (s *ingestStore) CountPromptAttempts(ctx context.Context) (int, error) {
+	if err := s.flush(); err != nil {
+		return 0, err
+	}
+	return s.HubStore.CountPromptAttempts(ctx)
+}
+
+func (s *ingestStore) LeaderboardAggregate(ctx context.Context, filter domain.AttemptFilter) ([]domain.LeaderboardEntry, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return s.HubStore.LeaderboardAggregate(ctx, filter)
+}
+
+func (s *ingestStore) AttemptTimeSeries(ctx context.Context, filter domain.AttemptFilter, bucket time.Duration) ([]domain.AttemptBucket, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return s.HubStore.AttemptTimeSeries(ctx, filter, bucket)
+}
+
+func (s *ingestStore) SummarizeTelemetry(ctx context.Context) (domain.TelemetrySummary, error) {
+	if err := s.flush(); err != nil {
+		return domain.TelemetrySummary{}, err
+	}
+	return s.HubStore.SummarizeTelemetry(ctx)
+}
+
+func (s *ingestStore) ListDailyRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.DailyRollup, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return s.HubStore.ListDailyRollups(ctx, filter)
+}
+
+func (s *ingestStore) RebuildDailyRollups(ctx context.Context, day time.Time) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return s.HubStore.RebuildDailyRollups(ctx, day)
+}
+
+func (s *ingestStore) PruneBefore(ctx context.Context, runEventsBefore, attemptsBefore time.Time) (domain.PruneResult, error) {
+	if err := s.flush(); err != nil {
+		return domain.PruneResult{}, err
+	}
+	return s.HubStore.PruneBefore(ctx, runEventsBefore, attemptsBefore)
+}
+
+func (s *ingestStore) ArchiveBefore(ctx context.Context, cutoff time.Time) (domain.ArchiveResult, error) {
+	if err := s.flush(); err != nil {
+		return domain.ArchiveResult{}, err
+	}
+	return s.HubStore.ArchiveBefore(ctx, cutoff)
+}
+
+func (s *ingestStore) FinalizeRun(ctx context.Context, run domain.AgentRun) (domain.AgentRun, error) {
+	if err := s.flush(); err != nil {
+		return domain.AgentRun{}, err
+	}
+	return s.HubStore.FinalizeRun(ctx, run)
+}
+
+func (s *ingestStore) LastRunActivity(ctx context.Context, runIDs []string) (map[string]string, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return s.HubStore.LastRunActivity(ctx, runIDs)
+}
+
+func (s *ingestStore) ExportState(ctx context.Context) (domain.State, error) {
+	if err := s.flush(); err != nil {
+		return domain.State{}, err
+	}
+	return s.HubStore.ExportState(ctx)
+}
+
+func (s *ingestStore) ListRunEventsFiltered(ctx context.Context, filter domain.EventFilter) ([]domain.RunEvent, error) {
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return s.HubStore.ListRunEventsFiltered(ctx, filter)
+}
+
+func (s *ingestStore) ListRunEvents(ctx context.Context, runID string) ([]domain.RunEvent, error) {
+	return s.ListRunEventsFiltered(ctx, domain.EventFilter{RunID: runID})
+}
+
+func (s *ingestStore) CountRunEvents(ctx context.Context) (int, error) {
+	if err := s.flush(); err != nil {
+		return 0, err
+	}
+	return s.HubStore.CountRunEvents(ctx)
+}
+
+// attemptMatchesFilter applies an AttemptFilter the way the stores do;
+// created is the filter's created_at range.
+func attemptMatchesFilter(item domain.PromptAttempt, filter domain.AttemptFilter, created createdRange) bool {
+	switch {
+	case filter.Project != "" && item.Project != filter.Project,
+		filter.RunID != "" && item.RunID != filter.RunID,
+		filter.Workflow != "" && item.Workflow != filter.Workflow,
+		filter.AgentID != "" && item.AgentID != filter.AgentID,
+		filter.Model != "" && item.Model != filter.Model,
+		filter.Outcome != "" && item.Outcome != filter.Outcome,
+		filter.PromptVersion != "" && item.PromptVersion != filter.PromptVersion,
+		(filter.CreatedAfter != "" || filter.CreatedBefore != "") && !created.contains(item.CreatedAt):
+		return false
+	}
+	return true
+}
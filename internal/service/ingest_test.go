@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+func newTestIngestStore(t *testing.T, cfg IngestBufferConfig) (*ingestStore, *store.MemoryStore) {
+	t.Helper()
+	inner := store.NewMemoryStore()
+	if err := inner.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	run := domain.AgentRun{ID: "run1", Project: domain.DefaultProject, Workflow: "wf", AgentID: "agent", Status: "running", Metadata: map[string]string{}}
+	if err := inner.InsertRun(context.Background(), run); err != nil {
+		t.Fatalf("insert run: %v", err)
+	}
+	s := newIngestStore(inner, cfg)
+	t.Cleanup(func() { _ = s.stop() })
+	return s, inner
+}
+
+func testAttempt(id, createdAt string) domain.PromptAttempt {
+	return domain.PromptAttempt{
+		ID:        id,
+		Project:   domain.DefaultProject,
+		RunID:     "run1",
+		Workflow:  "wf",
+		AgentID:   "agent",
+		Model:     "model",
+		Outcome:   "success",
+		Metadata:  map[string]string{},
+		CreatedAt: createdAt,
+	}
+}
+
+func storedAttempts(t *testing.T, s store.AttemptReader, filter domain.AttemptFilter) int {
+	t.Helper()
+	items, err := s.ListPromptAttemptsFiltered(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("list attempts: %v", err)
+	}
+	return len(items)
+}
+
+func waitForStored(t *testing.T, inner *store.MemoryStore, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for storedAttempts(t, inner, domain.AttemptFilter{}) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d attempts in the store, got %d", want, storedAttempts(t, inner, domain.AttemptFilter{}))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIngestBufferFlushesFullBatches(t *testing.T) {
+	s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 100, BatchSize: 3, FlushInterval: time.Hour})
+	ctx := context.Background()
+	insert := func(id string) {
+		t.Helper()
+		if err := s.InsertPromptAttempt(ctx, testAttempt(id, "2026-01-01T00:00:00Z")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	insert("a1")
+	insert("a2")
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 0 {
+		t.Fatalf("expected a part batch to stay queued, got %d in the store", got)
+	}
+	insert("a3")
+	waitForStored(t, inner, 3)
+
+	insert("a4")
+	if got := storedAttempts(t, s, domain.AttemptFilter{}); got != 4 {
+		t.Fatalf("expected reads through the buffer to see all 4 attempts, got %d", got)
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 3 {
+		t.Fatalf("expected a4 to be queued still, got %d in the store", got)
+	}
+	if got := storedAttempts(t, s, domain.AttemptFilter{Limit: 10}); got != 4 {
+		t.Fatalf("expected a limited read to flush and see all 4 attempts, got %d", got)
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 4 {
+		t.Fatalf("expected the limited read to have flushed a4, got %d in the store", got)
+	}
+}
+
+func TestIngestBufferFlushesOnInterval(t *testing.T) {
+	s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 100, BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	if err := s.InsertPromptAttempt(context.Background(), testAttempt("a1", "2026-01-01T00:00:00Z")); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	waitForStored(t, inner, 1)
+}
+
+func TestIngestBufferFlushesInlineWhenFull(t *testing.T) {
+	// A batch larger than the buffer never kicks the background flush, so
+	// only the inline one writes.
+	s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 2, BatchSize: 10, FlushInterval: time.Hour})
+	ctx := context.Background()
+	for i := range 3 {
+		if err := s.InsertPromptAttempt(ctx, testAttempt(fmt.Sprintf("a%d", i), "2026-01-01T00:00:00Z")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 2 {
+		t.Fatalf("expected the third insert to flush the full buffer first, got %d in the store", got)
+	}
+}
+
+func TestIngestBufferFiltersQueuedAttemptsLikeTheStore(t *testing.T) {
+	s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 100, BatchSize: 100, FlushInterval: time.Hour})
+	ctx := context.Background()
+	for i, createdAt := range []string{"2026-01-01T00:00:01Z", "2026-01-01T00:00:01.5Z", "2026-01-01T00:00:02Z"} {
+		if err := s.InsertPromptAttempt(ctx, testAttempt(fmt.Sprintf("a%d", i), createdAt)); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	filters := []domain.AttemptFilter{
+		{CreatedAfter: "2026-01-01T00:00:01.5Z"},
+		{CreatedBefore: "2026-01-01T00:00:01.5Z"},
+		{CreatedAfter: "2026-01-01T00:00:01Z", CreatedBefore: "2026-01-01T00:00:02Z"},
+		{CreatedAfter: "2026-01-01T00:00:01.000000001Z"},
+	}
+	queued := make([]int, len(filters))
+	for i, filter := range filters {
+		queued[i] = storedAttempts(t, s, filter)
+	}
+	if err := s.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for i, filter := range filters {
+		if stored := storedAttempts(t, inner, filter); stored != queued[i] {
+			t.Fatalf("filter %+v: %d queued matches but %d stored", filter, queued[i], stored)
+		}
+	}
+	if fmt.Sprint(queued) != "[2 2 3 2]" {
+		t.Fatalf("expected inclusive parsed bounds to match [2 2 3 2], got %v", queued)
+	}
+}
+
+func TestIngestBufferStopDrainsAndWritesThrough(t *testing.T) {
+	s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 100, BatchSize: 100, FlushInterval: time.Hour})
+	ctx := context.Background()
+	for i := range 5 {
+		if err := s.InsertPromptAttempt(ctx, testAttempt(fmt.Sprintf("a%d", i), "2026-01-01T00:00:00Z")); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 0 {
+		t.Fatalf("expected nothing written before stop, got %d", got)
+	}
+	if err := s.stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 5 {
+		t.Fatalf("expected stop to write all 5 queued attempts, got %d", got)
+	}
+	if err := s.InsertPromptAttempt(ctx, testAttempt("late", "2026-01-01T00:00:00Z")); err != nil {
+		t.Fatalf("insert after stop: %v", err)
+	}
+	if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != 6 {
+		t.Fatalf("expected an insert after stop to go straight to the store, got %d", got)
+	}
+}
+
+func TestIngestBufferKeepsInsertsRacingStop(t *testing.T) {
+	for round := range 50 {
+		// A one-record buffer makes every insert flush inline just before it
+		// queues, which is the window stop's final flush can fall into.
+		s, inner := newTestIngestStore(t, IngestBufferConfig{MaxPending: 1, BatchSize: 1, FlushInterval: time.Hour})
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		accepted := 0
+		for worker := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 10 {
+					id := fmt.Sprintf("r%d-w%d-%d", round, worker, i)
+					if err := s.InsertPromptAttempt(context.Background(), testAttempt(id, "2026-01-01T00:00:00Z")); err == nil {
+						mu.Lock()
+						accepted++
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		if err := s.stop(); err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+		wg.Wait()
+		if got := storedAttempts(t, inner, domain.AttemptFilter{}); got != accepted {
+			t.Fatalf("round %d: %d inserts accepted but %d stored", round, accepted, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// Live update kinds.
+const (
+	LiveRunEvent = "run_event"
+	LiveRun      = "run"
+	LiveAttempt  = "attempt"
+)
+
+// liveBuffer is how many updates a subscriber may fall behind by before it
+// is dropped.
+const liveBuffer = 256
+
+// LiveUpdate is one write pushed to live subscribers: a recorded run event
+// or prompt attempt, or a run that started or finished.
+type LiveUpdate struct {
+	Kind    string                `json:"kind"`
+	Project string                `json:"project"`
+	RunID   string                `json:"run_id"`
+	Event   *domain.RunEvent      `json:"event,omitempty"`
+	Run     *domain.AgentRun      `json:"run,omitempty"`
+	Attempt *domain.PromptAttempt `json:"attempt,omitempty"`
+}
+
+// LiveFilter narrows a subscription; empty fields match everything.
+type LiveFilter struct {
+	Project string
+	RunID   string
+}
+
+func (f LiveFilter) matches(update LiveUpdate) bool {
+	return (f.Project == "" || f.Project == update.Project) && (f.RunID == "" || f.RunID == update.RunID)
+}
+
+type liveSubscriber struct {
+	filter  LiveFilter
+	updates chan LiveUpdate
+}
+
+// liveHub fans updates out to subscribers. Publishing never blocks a write:
+// a subscriber whose buffer is full is dropped and its channel closed, so it
+// can reconnect and reload rather than silently miss updates.
+type liveHub struct {
+	mu          sync.Mutex
+	subscribers map[*liveSubscriber]struct{}
+}
+
+// SubscribeLive streams the run events, prompt attempts and run status
+// changes this server records from now on that match filter. It returns the
+// channel and a function that ends the subscription; the channel is closed
+// when either the subscription ends or the subscriber falls too far behind.
+// Writes handled by other server replicas are not seen.
+func (h *HubService) SubscribeLive(filter LiveFilter) (<-chan LiveUpdate, func()) {
+	subscriber := &liveSubscriber{filter: filter, updates: make(chan LiveUpdate, liveBuffer)}
+	h.live.mu.Lock()
+	if h.live.subscribers == nil {
+		h.live.subscribers = map[*liveSubscriber]struct{}{}
+	}
+	h.live.subscribers[subscriber] = struct{}{}
+	h.live.mu.Unlock()
+	return subscriber.updates, func() {
+		h.live.mu.Lock()
+		defer h.live.mu.Unlock()
+		h.live.removeLocked(subscriber)
+	}
+}
+
+func (l *liveHub) removeLocked(subscriber *liveSubscriber) {
+	if _, ok := l.subscribers[subscriber]; ok {
+		delete(l.subscribers, subscriber)
+		close(subscriber.updates)
+	}
+}
+
+func (h *HubService) publishLive(update LiveUpdate) {
+	h.live.mu.Lock()
+	defer h.live.mu.Unlock()
+	for subscriber := range h.live.subscribers {
+		if !subscriber.filter.matches(update) {
+			continue
+		}
+		select {
+		case subscriber.updates <- update:
+		default:
+			h.live.removeLocked(subscriber)
+		}
+	}
+}
+
+func (h *HubService) publishRun(run domain.AgentRun) {
+	h.publishLive(LiveUpdate{Kind: LiveRun, Project: run.Project, RunID: run.ID, Run: &run})
+}
+
+func (h *HubService) publishRunEvent(project string, event domain.RunEvent) {
+	h.publishLive(LiveUpdate{Kind: LiveRunEvent, Project: project, RunID: event.RunID, Event: &event})
+}
+
+func (h *HubService) publishAttempt(attempt domain.PromptAttempt) {
+	h.publishLive(LiveUpdate{Kind: LiveAttempt, Project: attempt.Project, RunID: attempt.RunID, Attempt: &attempt})
+}
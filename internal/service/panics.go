@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// panicMessageLimit bounds the panic value quoted in a changelog summary.
+const panicMessageLimit = 200
+
+// PanicReport describes one recovered RPC panic. Fingerprint identifies the
+// code path, so the same bug hit with different values shares it.
+type PanicReport struct {
+	Method      string
+	RequestID   string
+	Fingerprint string
+	Message     string
+	Stack       string
+}
+
+// panicCounts counts recovered panics by fingerprint for the life of the
+// process.
+type panicCounts struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *panicCounts) add(fingerprint string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int64{}
+	}
+	c.counts[fingerprint]++
+	return c.counts[fingerprint]
+}
+
+// RecordPanic counts report under its fingerprint and writes a platform
+// changelog entry with the stack. A fingerprint that keeps firing is
+// recorded on its 1st, 2nd, 4th, 8th... occurrence so a crash loop cannot
+// flood the changelog; the entry carries the running count.
+func (h *HubService) RecordPanic(ctx context.Context, report PanicReport) error {
+	count := h.panics.add(report.Fingerprint)
+	if count&(count-1) != 0 {
+		return nil
+	}
+	message := strings.TrimSpace(report.Message)
+	if len(message) > panicMessageLimit {
+		message = message[:panicMessageLimit] + "..."
+	}
+	method := report.Method[strings.LastIndex(report.Method, "/")+1:]
+	return h.store.InsertChangelog(ctx, domain.ChangelogEntry{
+		ID:       newID("chg"),
+		Category: "platform",
+		Summary:  fmt.Sprintf("panic in %s: %s", method, message),
+		Details: fmt.Sprintf("fingerprint=%s count=%d method=%s request_id=%s\n\n%s",
+			report.Fingerprint, count, report.Method, report.RequestID, report.Stack),
+		Actor:     "modeloman-server",
+		CreatedAt: timeNow(),
+	})
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// Policy validation lets an external service veto SetPolicy,
+// UpsertPolicyCap and DeletePolicyCap before they are stored, for
+// org-specific guardrails such as "only on-call may disable the kill
+// switch". The validator sees who is asking and the record before and
+// after; a rejection fails the call with its reason and is written to the
+// policy audit trail with action "reject". Scheduled kill-switch changes
+// are the hub's own and skip validation.
+
+const maxPolicyRejectionReason = 500
+
+// PolicyChange is a policy mutation awaiting validation. Before is nil for
+// a new cap and After is nil for a deleted one.
+type PolicyChange struct {
+	TargetType string      `json:"target_type"`
+	TargetID   string      `json:"target_id,omitempty"`
+	Project    string      `json:"project,omitempty"`
+	Action     string      `json:"action"`
+	Actor      ChangeActor `json:"actor"`
+	Before     any         `json:"before"`
+	After      any         `json:"after"`
+	At         string      `json:"at"`
+}
+
+// ChangeActor is AuditActor as validators see it.
+type ChangeActor struct {
+	AgentID string `json:"agent_id,omitempty"`
+	KeyID   string `json:"key_id,omitempty"`
+}
+
+// PolicyDecision is a validator's verdict; Reason is shown to the caller
+// when Allow is false.
+type PolicyDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// PolicyValidator approves or rejects policy changes. An error means no
+// verdict was reached.
+type PolicyValidator interface {
+	ValidatePolicyChange(ctx context.Context, change PolicyChange) (PolicyDecision, error)
+}
+
+// PolicyValidation configures EnablePolicyValidation.
+type PolicyValidation struct {
+	Validator PolicyValidator
+	// FailOpen applies changes when the validator errors instead of
+	// refusing them.
+	FailOpen bool
+}
+
+// EnablePolicyValidation asks v.Validator about every policy change.
+func (h *HubService) EnablePolicyValidation(v PolicyValidation) {
+	h.policyValidation = v
+}
+
+// policyRejection is the audit record of a refused change.
+type policyRejection struct {
+	Proposed any    `json:"proposed"`
+	Reason   string `json:"reason"`
+}
+
+// policyLock is policyMu as held by one policy or cap change. Without a
+// validator the change holds it from its first read, since nothing slow
+// happens before the write. With one, it is only taken once the validator
+// has decided, so a slow webhook holds up neither EvaluatePolicySchedule
+// nor other changes; checkUnchanged then catches anything written in the
+// meantime.
+type policyLock struct {
+	mu   *sync.Mutex
+	held bool
+}
+
+func (h *HubService) lockPolicyChange() *policyLock {
+	lock := &policyLock{mu: &h.policyMu}
+	if h.policyValidation.Validator == nil {
+		lock.lock()
+	}
+	return lock
+}
+
+func (l *policyLock) lock() {
+	if !l.held {
+		l.mu.Lock()
+		l.held = true
+	}
+}
+
+func (l *policyLock) unlock() {
+	if l.held {
+		l.held = false
+		l.mu.Unlock()
+	}
+}
+
+// validatePolicyChange returns nil, with lock held, when the change may be
+// stored, and a FailedPrecondition carrying the validator's reason when it
+// may not. The validator is asked without the lock; reload then reads the
+// record back under it, so a change made by this or another hub while the
+// validator decided fails this one with Conflict rather than storing an
+// after the validator approved against a stale before.
+func (h *HubService) validatePolicyChange(ctx context.Context, lock *policyLock, targetType, targetID, project, action string, actor AuditActor, before, after any, reload func(context.Context) (any, error)) error {
+	validator := h.policyValidation.Validator
+	if validator == nil {
+		lock.lock()
+		return nil
+	}
+	decision, err := validator.ValidatePolicyChange(ctx, PolicyChange{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Project:    project,
+		Action:     action,
+		Actor:      ChangeActor{AgentID: actor.AgentID, KeyID: actor.KeyID},
+		Before:     before,
+		After:      after,
+		At:         timeNow(),
+	})
+	if err != nil {
+		if !h.policyValidation.FailOpen {
+			slog.ErrorContext(ctx, "policy validation failed", "target_type", targetType, "target_id", targetID, "err", err)
+			return domain.FailedPrecondition("policy validation is unavailable; the change was not applied")
+		}
+		slog.WarnContext(ctx, "policy validation failed; applying the change anyway", "target_type", targetType, "target_id", targetID, "err", err)
+		decision.Allow = true
+	}
+	if decision.Allow {
+		lock.lock()
+		return h.checkUnchanged(ctx, before, reload)
+	}
+	reason := strings.TrimSpace(decision.Reason)
+	if reason == "" {
+		reason = "no reason given"
+	}
+	if len(reason) > maxPolicyRejectionReason {
+		reason = reason[:maxPolicyRejectionReason]
+	}
+	if err := h.recordPolicyAudit(ctx, targetType, targetID, project, "reject", actor, before, policyRejection{Proposed: after, Reason: reason}); err != nil {
+		return err
+	}
+	return domain.FailedPrecondition("policy change rejected: " + reason)
+}
+
+func (h *HubService) checkUnchanged(ctx context.Context, before any, reload func(context.Context) (any, error)) error {
+	current, err := reload(ctx)
+	if err != nil {
+		return err
+	}
+	was, err := json.Marshal(before)
+	if err != nil {
+		return domain.Internal("failed to encode policy", err)
+	}
+	now, err := json.Marshal(current)
+	if err != nil {
+		return domain.Internal("failed to encode policy", err)
+	}
+	if !bytes.Equal(was, now) {
+		return domain.Conflict("the policy changed while this change was being validated; retry it")
+	}
+	return nil
+}
+
+// currentPolicy and currentPolicyCap are validatePolicyChange reloads. A
+// cap that does not exist reloads as nil, matching a new cap's before.
+func (h *HubService) currentPolicy(ctx context.Context) (any, error) {
+	return h.store.GetPolicy(ctx)
+}
+
+func (h *HubService) currentPolicyCap(id string) func(context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		caps, err := h.store.ListPolicyCaps(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range caps {
+			if item.ID == id {
+				return item, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// PolicyWebhook asks an HTTP endpoint about policy changes. It POSTs the
+// PolicyChange as JSON and expects a 2xx with a PolicyDecision body. With a
+// secret, the body's hex HMAC-SHA256 is sent as
+// x-modeloman-signature: sha256=<hex>, so the endpoint can check the call
+// came from the hub.
+type PolicyWebhook struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+}
+
+func NewPolicyWebhook(url string, secret []byte, timeout time.Duration) *PolicyWebhook {
+	return &PolicyWebhook{URL: url, Secret: secret, Client: &http.Client{Timeout: timeout}}
+}
+
+func (w *PolicyWebhook) ValidatePolicyChange(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(w.Secret) > 0 {
+		mac := hmac.New(sha256.New, w.Secret)
+		mac.Write(body)
+		request.Header.Set("x-modeloman-signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	response, err := w.Client.Do(request)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return PolicyDecision{}, fmt.Errorf("policy webhook returned %s", response.Status)
+	}
+	var decision PolicyDecision
+	if err := json.NewDecoder(io.LimitReader(response.Body, 64<<10)).Decode(&decision); err != nil {
+		return PolicyDecision{}, fmt.Errorf("policy webhook sent an unreadable decision: %w", err)
+	}
+	return decision, nil
+}
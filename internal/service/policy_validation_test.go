@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// validatorFunc adapts a function to PolicyValidator.
+type validatorFunc func(ctx context.Context, change PolicyChange) (PolicyDecision, error)
+
+func (f validatorFunc) ValidatePolicyChange(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+	return f(ctx, change)
+}
+
+func TestPolicyValidationConflictsWhenTheRecordChangesMeanwhile(t *testing.T) {
+	hub := newTestHub(t)
+	ctx := context.Background()
+	// Stands in for another hub sharing the store, writing while the
+	// validator decides.
+	hub.EnablePolicyValidation(PolicyValidation{Validator: validatorFunc(func(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+		var err error
+		switch change.TargetType {
+		case "policy":
+			err = hub.store.SetPolicy(ctx, domain.OrchestrationPolicy{MaxAttemptsPerRun: 7, UpdatedAt: timeNow()})
+		case "policy_cap":
+			err = hub.store.UpsertPolicyCap(ctx, domain.PolicyCap{ID: "cap1", Name: "theirs", Kind: domain.PolicyCapKindLimit, UpdatedAt: timeNow()})
+		}
+		return PolicyDecision{Allow: true}, err
+	})})
+
+	engaged := true
+	_, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged})
+	expectCode(t, err, domain.CodeConflict, "set policy")
+	policy, err := hub.GetPolicy(ctx)
+	if err != nil {
+		t.Fatalf("get policy: %v", err)
+	}
+	if policy.KillSwitch || policy.MaxAttemptsPerRun != 7 {
+		t.Fatalf("expected the other write to stand, got %+v", policy)
+	}
+
+	_, err = hub.UpsertPolicyCap(ctx, UpsertPolicyCapRequest{ID: "cap1", Name: "ours"})
+	expectCode(t, err, domain.CodeConflict, "upsert cap")
+	caps, err := hub.store.ListPolicyCaps(ctx)
+	if err != nil {
+		t.Fatalf("list caps: %v", err)
+	}
+	if len(caps) != 1 || caps[0].Name != "theirs" {
+		t.Fatalf("expected the other cap write to stand, got %+v", caps)
+	}
+}
+
+func TestPolicyValidationDoesNotHoldUpTheScheduler(t *testing.T) {
+	hub := newTestHub(t)
+	ctx := context.Background()
+	asked := make(chan struct{})
+	release := make(chan struct{})
+	hub.EnablePolicyValidation(PolicyValidation{Validator: validatorFunc(func(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+		close(asked)
+		<-release
+		return PolicyDecision{Allow: true}, nil
+	})})
+
+	engaged := true
+	done := make(chan error, 1)
+	go func() {
+		_, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged})
+		done <- err
+	}()
+	<-asked
+	evaluated := make(chan error, 1)
+	go func() {
+		_, _, err := hub.EvaluatePolicySchedule(ctx, time.Now())
+		evaluated <- err
+	}()
+	select {
+	case err := <-evaluated:
+		if err != nil {
+			t.Fatalf("evaluate schedule: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the schedule to be evaluated while the validator decides")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the validated change to apply: %v", err)
+	}
+	policy, err := hub.GetPolicy(ctx)
+	if err != nil || !policy.KillSwitch {
+		t.Fatalf("expected the kill switch to be engaged, got %+v %v", policy, err)
+	}
+}
+
+func TestPolicyValidationRejectsAndAudits(t *testing.T) {
+	hub := newTestHub(t)
+	ctx := context.Background()
+	hub.EnablePolicyValidation(PolicyValidation{Validator: validatorFunc(func(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+		if change.Actor.AgentID == "oncall" {
+			return PolicyDecision{Allow: true}, nil
+		}
+		return PolicyDecision{Reason: "only on-call may change the kill switch"}, nil
+	})})
+
+	engaged := true
+	_, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged, Actor: AuditActor{AgentID: "intern"}})
+	expectCode(t, err, domain.CodeFailedPrecondition, "rejected change")
+	entries, err := hub.store.ListPolicyAudit(ctx, domain.PolicyAuditFilter{TargetType: "policy"})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != "reject" || entries[0].ActorAgentID != "intern" {
+		t.Fatalf("expected one reject entry by intern, got %+v", entries)
+	}
+
+	if _, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged, Actor: AuditActor{AgentID: "oncall"}}); err != nil {
+		t.Fatalf("expected an approved change to apply: %v", err)
+	}
+	// Without a validator the change holds the lock from its first read.
+	hub.EnablePolicyValidation(PolicyValidation{})
+	if _, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: new(bool)}); err != nil {
+		t.Fatalf("expected an unvalidated change to apply: %v", err)
+	}
+}
+
+func TestPolicyValidationFailsClosedUnlessFailOpen(t *testing.T) {
+	hub := newTestHub(t)
+	ctx := context.Background()
+	down := validatorFunc(func(ctx context.Context, change PolicyChange) (PolicyDecision, error) {
+		return PolicyDecision{}, errors.New("webhook down")
+	})
+	engaged := true
+
+	hub.EnablePolicyValidation(PolicyValidation{Validator: down})
+	_, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged})
+	expectCode(t, err, domain.CodeFailedPrecondition, "fail closed")
+
+	hub.EnablePolicyValidation(PolicyValidation{Validator: down, FailOpen: true})
+	policy, err := hub.SetPolicy(ctx, SetPolicyRequest{KillSwitch: &engaged})
+	if err != nil || !policy.KillSwitch {
+		t.Fatalf("expected fail open to apply the change, got %+v %v", policy, err)
+	}
+}
@@ -0,0 +1,18 @@
+package service
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID tags ctx with the ID of the call it serves. Run events the
+// service records under ctx carry it, so an event can be traced back to the
+// server log lines of the call that wrote it.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the ID set by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
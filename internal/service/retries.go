@@ -0,0 +1,201 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const (
+	defaultRetryWindowDays     = 30
+	defaultRetryMinSuccessRate = 0.2
+	// minRetrySample is how many runs must reach an attempt number before
+	// its success rate counts towards a suggested max_retries.
+	minRetrySample = 5
+)
+
+type RetryReportRequest struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	// Model keeps runs whose first attempt used this model.
+	Model      string `json:"model"`
+	WindowDays int64  `json:"window_days"`
+	// MinSuccessRate is the success rate an extra attempt has to reach to
+	// count as worth making in SuggestedMaxRetries; default 0.2.
+	MinSuccessRate float64 `json:"min_success_rate"`
+}
+
+// RetryAttemptStats is how runs fared at their nth attempt: Reached runs
+// made it, Succeeded of them succeeded there. Attempts after a run's first
+// success are left out, so SuccessRate is the chance attempt n succeeds
+// given the ones before it failed.
+type RetryAttemptStats struct {
+	AttemptNumber int64   `json:"attempt_number"`
+	Reached       int64   `json:"reached"`
+	Succeeded     int64   `json:"succeeded"`
+	SuccessRate   float64 `json:"success_rate"`
+	CostUSD       float64 `json:"cost_usd"`
+}
+
+// RetryStats is retry effectiveness for the runs whose first attempt used
+// one workflow and model. Retry costs cover every attempt after the first;
+// WastedRetryCostUSD is the part spent in runs no retry rescued, and
+// CostPerRecoveryUSD spreads all retry spend over the rescued runs.
+// SuggestedMaxRetries counts the extra attempts, in order, that reached
+// MinSuccessRate in at least five runs each.
+type RetryStats struct {
+	Workflow              string              `json:"workflow"`
+	ProviderType          string              `json:"provider_type"`
+	Provider              string              `json:"provider"`
+	Model                 string              `json:"model"`
+	Runs                  int64               `json:"runs"`
+	FirstAttemptFailures  int64               `json:"first_attempt_failures"`
+	Recovered             int64               `json:"recovered"`
+	RecoveredOnOtherModel int64               `json:"recovered_on_other_model"`
+	RecoveryRate          float64             `json:"recovery_rate"`
+	FirstAttemptCostUSD   float64             `json:"first_attempt_cost_usd"`
+	RetryCostUSD          float64             `json:"retry_cost_usd"`
+	WastedRetryCostUSD    float64             `json:"wasted_retry_cost_usd"`
+	CostPerRecoveryUSD    float64             `json:"cost_per_recovery_usd"`
+	SuggestedMaxRetries   int64               `json:"suggested_max_retries"`
+	ByAttempt             []RetryAttemptStats `json:"by_attempt"`
+}
+
+// RetryReport lists RetryStats by retry spend, highest first.
+type RetryReport struct {
+	WindowDays     int64        `json:"window_days"`
+	MinSuccessRate float64      `json:"min_success_rate"`
+	Rows           []RetryStats `json:"rows"`
+}
+
+// RetryReport groups the window's attempts into runs and measures how
+// often attempts after a failed first one succeed and what they cost.
+// Runs whose first attempt falls before the window are skipped, since
+// their retries would look like first attempts.
+func (h *HubService) RetryReport(ctx context.Context, request RetryReportRequest) (RetryReport, error) {
+	windowDays := request.WindowDays
+	if windowDays == 0 {
+		windowDays = defaultRetryWindowDays
+	}
+	if windowDays < 0 || windowDays > maxCostSeriesWindowDays {
+		return RetryReport{}, domain.InvalidArgument(fmt.Sprintf("window_days must be between 1 and %d", maxCostSeriesWindowDays))
+	}
+	minSuccessRate := request.MinSuccessRate
+	if minSuccessRate == 0 {
+		minSuccessRate = defaultRetryMinSuccessRate
+	}
+	if minSuccessRate < 0 || minSuccessRate > 1 {
+		return RetryReport{}, domain.InvalidArgument("min_success_rate must be between 0 and 1")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return RetryReport{}, err
+	}
+	model := strings.TrimSpace(request.Model)
+
+	attempts, err := h.store.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{
+		Project:      project,
+		Workflow:     strings.TrimSpace(request.Workflow),
+		CreatedAfter: time.Now().UTC().Add(-time.Duration(windowDays) * 24 * time.Hour).Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return RetryReport{}, err
+	}
+	byRun := map[string][]domain.PromptAttempt{}
+	for _, attempt := range attempts {
+		if attempt.RunID != "" {
+			byRun[attempt.RunID] = append(byRun[attempt.RunID], attempt)
+		}
+	}
+
+	rows := map[string]*RetryStats{}
+	for _, runAttempts := range byRun {
+		slices.SortFunc(runAttempts, func(a, b domain.PromptAttempt) int {
+			return cmp.Or(cmp.Compare(a.AttemptNumber, b.AttemptNumber), strings.Compare(a.CreatedAt, b.CreatedAt))
+		})
+		first := runAttempts[0]
+		if first.AttemptNumber != 1 || (model != "" && first.Model != model) {
+			continue
+		}
+		key := strings.Join([]string{first.Workflow, first.ProviderType, first.Provider, first.Model}, "|")
+		row, ok := rows[key]
+		if !ok {
+			row = &RetryStats{Workflow: first.Workflow, ProviderType: first.ProviderType, Provider: first.Provider, Model: first.Model}
+			rows[key] = row
+		}
+		row.Runs++
+		row.FirstAttemptCostUSD += first.CostUSD
+
+		retryCost := 0.0
+		var success *domain.PromptAttempt
+		for i := range runAttempts {
+			attempt := &runAttempts[i]
+			if i > 0 {
+				retryCost += attempt.CostUSD
+			}
+			if success != nil {
+				continue
+			}
+			for len(row.ByAttempt) <= i {
+				row.ByAttempt = append(row.ByAttempt, RetryAttemptStats{AttemptNumber: int64(len(row.ByAttempt) + 1)})
+			}
+			stats := &row.ByAttempt[i]
+			stats.Reached++
+			stats.CostUSD += attempt.CostUSD
+			if attempt.Outcome == "success" {
+				stats.Succeeded++
+				success = attempt
+			}
+		}
+		row.RetryCostUSD += retryCost
+		if first.Outcome == "success" {
+			continue
+		}
+		row.FirstAttemptFailures++
+		switch {
+		case success == nil:
+			row.WastedRetryCostUSD += retryCost
+		case success.Model != first.Model || success.Provider != first.Provider:
+			row.Recovered++
+			row.RecoveredOnOtherModel++
+		default:
+			row.Recovered++
+		}
+	}
+
+	report := RetryReport{WindowDays: windowDays, MinSuccessRate: minSuccessRate, Rows: make([]RetryStats, 0, len(rows))}
+	for _, row := range rows {
+		for i := range row.ByAttempt {
+			stats := &row.ByAttempt[i]
+			stats.SuccessRate = float64(stats.Succeeded) / float64(stats.Reached)
+		}
+		if row.FirstAttemptFailures > 0 {
+			row.RecoveryRate = float64(row.Recovered) / float64(row.FirstAttemptFailures)
+		}
+		if row.Recovered > 0 {
+			row.CostPerRecoveryUSD = row.RetryCostUSD / float64(row.Recovered)
+		}
+		for _, stats := range row.ByAttempt[1:] {
+			if stats.Reached < minRetrySample || stats.SuccessRate < minSuccessRate {
+				break
+			}
+			row.SuggestedMaxRetries++
+		}
+		report.Rows = append(report.Rows, *row)
+	}
+	slices.SortFunc(report.Rows, func(a, b RetryStats) int {
+		return cmp.Or(
+			cmp.Compare(b.RetryCostUSD, a.RetryCostUSD),
+			strings.Compare(a.Workflow, b.Workflow),
+			strings.Compare(a.Provider, b.Provider),
+			strings.Compare(a.Model, b.Model),
+			strings.Compare(a.ProviderType, b.ProviderType),
+		)
+	})
+	return report, nil
+}
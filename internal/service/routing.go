@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const defaultRecommendWindowDays = 30
+
+type RecommendModelRequest struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	AgentID  string `json:"agent_id"`
+	// Provider restricts candidates to one provider, e.g. "wrapped-cli" for
+	// the backends mm can launch.
+	Provider   string `json:"provider"`
+	WindowDays int64  `json:"window_days"`
+}
+
+// ModelCandidate is one provider/model's record for the workflow over the
+// window. Quality is the mean quality_score when attempts report one and the
+// success rate otherwise.
+type ModelCandidate struct {
+	ProviderType   string  `json:"provider_type"`
+	Provider       string  `json:"provider"`
+	Model          string  `json:"model"`
+	Attempts       int64   `json:"attempts"`
+	SuccessRate    float64 `json:"success_rate"`
+	AverageCostUSD float64 `json:"average_cost_usd"`
+	Quality        float64 `json:"quality"`
+	Fallback       bool    `json:"fallback"`
+}
+
+// ModelRecommendation is the model to start a workflow with and the ladder
+// to escalate along, cheapest first. Source names the routing cap or
+// "history" when none applies.
+type ModelRecommendation struct {
+	Workflow     string           `json:"workflow"`
+	ProviderType string           `json:"provider_type"`
+	Provider     string           `json:"provider"`
+	Model        string           `json:"model"`
+	Reason       string           `json:"reason"`
+	Source       string           `json:"source"`
+	Ladder       []ModelCandidate `json:"ladder"`
+}
+
+// RecommendModel picks a model for the workflow from recorded attempts. With
+// a routing cap in effect the ladder holds the preferred provider type's
+// models under its cost, then the fallback type's; the recommendation is the
+// cheapest preferred model meeting the quality target, else the cheapest
+// fallback meeting it, else the best quality on the ladder.
+func (h *HubService) RecommendModel(ctx context.Context, request RecommendModelRequest) (ModelRecommendation, error) {
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return ModelRecommendation{}, err
+	}
+	windowDays := request.WindowDays
+	if windowDays == 0 {
+		windowDays = defaultRecommendWindowDays
+	}
+	if windowDays < 0 || windowDays > maxCostSeriesWindowDays {
+		return ModelRecommendation{}, domain.InvalidArgument(fmt.Sprintf("window_days must be between 1 and %d", maxCostSeriesWindowDays))
+	}
+	workflow := strings.TrimSpace(request.Workflow)
+	provider := strings.TrimSpace(request.Provider)
+
+	caps, err := h.store.ListPolicyCaps(ctx)
+	if err != nil {
+		return ModelRecommendation{}, err
+	}
+	attempts, err := h.store.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{
+		Project:      project,
+		Workflow:     workflow,
+		CreatedAfter: time.Now().UTC().Add(-time.Duration(windowDays) * 24 * time.Hour).Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return ModelRecommendation{}, err
+	}
+
+	candidates := modelCandidates(attempts, provider)
+	out := ModelRecommendation{Workflow: workflow, Source: "history", Ladder: []ModelCandidate{}}
+	routingCap, hasRouting := selectRoutingCap(caps, project, workflow, strings.TrimSpace(request.AgentID))
+	if !hasRouting {
+		out.Ladder = candidates
+		best, ok := bestQuality(candidates)
+		if !ok {
+			out.Reason = "no attempts recorded for this workflow in the window"
+			return out, nil
+		}
+		out.setModel(best, "highest quality over the window")
+		return out, nil
+	}
+
+	routing := routingCap.Routing
+	out.Source = "policy-cap:" + routingCap.ID
+	var preferred, fallback []ModelCandidate
+	for _, candidate := range candidates {
+		switch {
+		case candidate.ProviderType == routing.PreferProviderType:
+			if routing.PreferUnderCostUSD <= 0 || candidate.AverageCostUSD < routing.PreferUnderCostUSD {
+				preferred = append(preferred, candidate)
+			}
+		case routing.FallbackProviderType != "" && candidate.ProviderType == routing.FallbackProviderType:
+			candidate.Fallback = true
+			fallback = append(fallback, candidate)
+		}
+	}
+	out.Ladder = append(append(out.Ladder, preferred...), fallback...)
+
+	for _, candidate := range preferred {
+		if candidate.Quality >= routing.QualityTarget {
+			out.setModel(candidate, fmt.Sprintf("cheapest %s model meeting quality target %.2f", routing.PreferProviderType, routing.QualityTarget))
+			return out, nil
+		}
+	}
+	for _, candidate := range fallback {
+		if candidate.Quality >= routing.QualityTarget {
+			out.setModel(candidate, fmt.Sprintf("no %s model meets quality target %.2f; cheapest %s fallback that does", routing.PreferProviderType, routing.QualityTarget, routing.FallbackProviderType))
+			return out, nil
+		}
+	}
+	best, ok := bestQuality(out.Ladder)
+	if !ok {
+		out.Reason = "no attempts recorded for the routing cap's provider types in the window"
+		return out, nil
+	}
+	out.setModel(best, fmt.Sprintf("no model meets quality target %.2f; highest quality allowed", routing.QualityTarget))
+	return out, nil
+}
+
+func (r *ModelRecommendation) setModel(candidate ModelCandidate, reason string) {
+	r.ProviderType = candidate.ProviderType
+	r.Provider = candidate.Provider
+	r.Model = candidate.Model
+	r.Reason = reason
+}
+
+// modelCandidates groups attempts by provider type, provider and model,
+// ordered by average cost and then model name.
+func modelCandidates(attempts []domain.PromptAttempt, provider string) []ModelCandidate {
+	type tally struct {
+		candidate             ModelCandidate
+		successes             int64
+		costUSD, qualityTotal float64
+		scored                bool
+	}
+	grouped := map[string]*tally{}
+	for _, item := range attempts {
+		if provider != "" && item.Provider != provider {
+			continue
+		}
+		key := strings.Join([]string{item.ProviderType, item.Provider, item.Model}, "|")
+		entry, ok := grouped[key]
+		if !ok {
+			entry = &tally{candidate: ModelCandidate{ProviderType: item.ProviderType, Provider: item.Provider, Model: item.Model}}
+			grouped[key] = entry
+		}
+		entry.candidate.Attempts++
+		if item.Outcome == "success" {
+			entry.successes++
+		}
+		entry.costUSD += item.CostUSD
+		entry.qualityTotal += item.QualityScore
+		if item.QualityScore > 0 {
+			entry.scored = true
+		}
+	}
+
+	out := make([]ModelCandidate, 0, len(grouped))
+	for _, entry := range grouped {
+		candidate := entry.candidate
+		attempts := float64(candidate.Attempts)
+		candidate.SuccessRate = float64(entry.successes) / attempts
+		candidate.AverageCostUSD = entry.costUSD / attempts
+		candidate.Quality = candidate.SuccessRate
+		if entry.scored {
+			candidate.Quality = entry.qualityTotal / attempts
+		}
+		out = append(out, candidate)
+	}
+	slices.SortFunc(out, func(a, b ModelCandidate) int {
+		if a.AverageCostUSD != b.AverageCostUSD {
+			if a.AverageCostUSD < b.AverageCostUSD {
+				return -1
+			}
+			return 1
+		}
+		if a.Model != b.Model {
+			return strings.Compare(a.Model, b.Model)
+		}
+		return strings.Compare(a.Provider, b.Provider)
+	})
+	return out
+}
+
+// bestQuality returns the highest-quality candidate; candidates are sorted by
+// cost, so the cheaper one wins a tie.
+func bestQuality(candidates []ModelCandidate) (ModelCandidate, bool) {
+	if len(candidates) == 0 {
+		return ModelCandidate{}, false
+	}
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Quality > best.Quality {
+			best = candidate
+		}
+	}
+	return best, true
+}
+
+// selectRoutingCap picks the routing cap for an agent and workflow with the
+// same rules as selectPolicyCap: more match fields win, then higher priority.
+func selectRoutingCap(caps []domain.PolicyCap, project, workflow, agentID string) (domain.PolicyCap, bool) {
+	var selected domain.PolicyCap
+	found := false
+	bestSpecificity := -1
+	now := time.Now().UTC()
+	for _, cap := range caps {
+		if cap.Kind != domain.PolicyCapKindRouting || cap.Routing == nil {
+			continue
+		}
+		if !cap.IsActive || !policyCapInEffect(cap, now) {
+			continue
+		}
+		if (cap.Project != "" && cap.Project != project) ||
+			(cap.Workflow != "" && cap.Workflow != workflow) ||
+			(cap.AgentID != "" && cap.AgentID != agentID) {
+			continue
+		}
+		specificity := 0
+		if cap.Workflow != "" {
+			specificity++
+		}
+		if cap.AgentID != "" {
+			specificity++
+		}
+		if !found || specificity > bestSpecificity || (specificity == bestSpecificity && cap.Priority > selected.Priority) {
+			selected = cap
+			found = true
+			bestSpecificity = specificity
+		}
+	}
+	return selected, found
+}
+
+// validateRoutingCap checks a routing cap: it matches by workflow and agent
+// only, sets no limits, and names valid, distinct provider types.
+func validateRoutingCap(cap domain.PolicyCap) error {
+	if cap.Routing == nil {
+		return domain.InvalidArgument("routing caps require routing.prefer_provider_type")
+	}
+	if cap.ProviderType != "" || cap.Provider != "" || cap.Model != "" {
+		return domain.InvalidArgument("routing caps match by workflow and agent_id only; clear provider_type, provider and model")
+	}
+	if cap.MaxCostPerRunUSD != 0 || cap.MaxAttemptsPerRun != 0 || cap.MaxTokensPerRun != 0 ||
+		cap.MaxCostPerAttemptUSD != 0 || cap.MaxTokensPerAttempt != 0 || cap.MaxLatencyPerAttemptMS != 0 ||
+		cap.MaxCostPerDayUSD != 0 || cap.MaxCostPerMonthUSD != 0 {
+		return domain.InvalidArgument("routing caps cannot set limits; use a separate limit cap")
+	}
+	routing := cap.Routing
+	if _, ok := validProviderTypes[routing.PreferProviderType]; !ok {
+		return domain.InvalidArgument("routing.prefer_provider_type must be one of: api, subscription, opensource")
+	}
+	if routing.FallbackProviderType != "" {
+		if _, ok := validProviderTypes[routing.FallbackProviderType]; !ok {
+			return domain.InvalidArgument("routing.fallback_provider_type must be one of: api, subscription, opensource")
+		}
+		if routing.FallbackProviderType == routing.PreferProviderType {
+			return domain.InvalidArgument("routing.fallback_provider_type must differ from prefer_provider_type")
+		}
+	}
+	if routing.PreferUnderCostUSD < 0 {
+		return domain.InvalidArgument("routing.prefer_under_cost_usd must be non-negative")
+	}
+	if routing.QualityTarget < 0 || routing.QualityTarget > 1 {
+		return domain.InvalidArgument("routing.quality_target must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+type GetRunRequest struct {
+	Project string `json:"project"`
+	RunID   string `json:"run_id"`
+	// ExternalID finds the run by its external ID instead. External IDs are
+	// unique per project only, so without project it must match one run.
+	ExternalID string `json:"external_id"`
+	// IncludeArchived also returns attempts and events moved to cold storage.
+	IncludeArchived bool `json:"include_archived"`
+}
+
+// RunDetail is one run with everything recorded against it, each list
+// oldest first so it reads as a timeline.
+type RunDetail struct {
+	Run       domain.AgentRun        `json:"run"`
+	Attempts  []domain.PromptAttempt `json:"attempts"`
+	Events    []domain.RunEvent      `json:"events"`
+	Artifacts []domain.Artifact      `json:"artifacts"`
+}
+
+// GetRun aggregates a run with its attempts, events and artifact metadata in
+// one call. When LIST_MAX_LIMIT truncates a list, the newest rows are kept,
+// since the end of a run is usually what is being debugged.
+func (h *HubService) GetRun(ctx context.Context, request GetRunRequest) (RunDetail, error) {
+	runID := strings.TrimSpace(request.RunID)
+	externalID := strings.TrimSpace(request.ExternalID)
+	if runID == "" && externalID == "" {
+		return RunDetail{}, domain.InvalidArgument("run_id or external_id is required")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return RunDetail{}, err
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, ExternalID: externalID, Limit: 2})
+	if err != nil {
+		return RunDetail{}, err
+	}
+	if len(runs) == 0 {
+		return RunDetail{}, domain.NotFound("run not found")
+	}
+	if len(runs) > 1 {
+		return RunDetail{}, domain.InvalidArgument("external_id matches runs in several projects; set project")
+	}
+	run := runs[0]
+
+	attempts, err := h.store.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{
+		Project:         run.Project,
+		RunID:           run.ID,
+		Limit:           h.storeLimit(0),
+		IncludeArchived: request.IncludeArchived,
+	})
+	if err != nil {
+		return RunDetail{}, err
+	}
+	events, err := h.store.ListRunEventsFiltered(ctx, domain.EventFilter{
+		Project:         run.Project,
+		RunID:           run.ID,
+		Limit:           h.storeLimit(0),
+		IncludeArchived: request.IncludeArchived,
+	})
+	if err != nil {
+		return RunDetail{}, err
+	}
+	artifacts, err := h.store.ListArtifacts(ctx, domain.ArtifactFilter{
+		Project: run.Project,
+		RunID:   run.ID,
+		Limit:   h.storeLimit(0),
+	})
+	if err != nil {
+		return RunDetail{}, err
+	}
+
+	slices.SortFunc(attempts, func(a, b domain.PromptAttempt) int {
+		return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	sortEventsNewestFirst(events)
+	slices.SortFunc(artifacts, func(a, b domain.Artifact) int {
+		return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	attempts = clampItems(ctx, h, attempts, "attempts")
+	events = clampItems(ctx, h, events, "events")
+	artifacts = clampItems(ctx, h, artifacts, "artifacts")
+	slices.Reverse(attempts)
+	slices.Reverse(events)
+	slices.Reverse(artifacts)
+	// Empty lists encode as [] rather than null.
+	return RunDetail{
+		Run:       run,
+		Attempts:  append([]domain.PromptAttempt{}, attempts...),
+		Events:    append([]domain.RunEvent{}, events...),
+		Artifacts: append([]domain.Artifact{}, artifacts...),
+	}, nil
+}
+
+func newestFirst(aCreated, aID, bCreated, bID string) int {
+	if aCreated == bCreated {
+		return strings.Compare(bID, aID)
+	}
+	return strings.Compare(bCreated, aCreated)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// Share links are tokens naming one run and an expiry, signed with an HMAC
+// key, that open the run's detail page read-only without hub credentials.
+// Nothing is stored per link, so a link works until it expires or the key
+// changes. Creating and opening links is written to the policy audit trail
+// with target_type run_share.
+
+const (
+	defaultShareTTL = 24 * time.Hour
+	minShareSecret  = 32
+)
+
+type shareSigner struct {
+	secret []byte
+	maxTTL time.Duration
+}
+
+// EnableShareLinks signs run share links with secret and caps their
+// lifetime at maxTTL. ShareRun and OpenRunShare fail with
+// FailedPrecondition until this is called.
+func (h *HubService) EnableShareLinks(secret []byte, maxTTL time.Duration) error {
+	if len(secret) < minShareSecret {
+		return domain.InvalidArgument(fmt.Sprintf("share link secret must be at least %d bytes", minShareSecret))
+	}
+	if maxTTL <= 0 {
+		return domain.InvalidArgument("share link max ttl must be positive")
+	}
+	h.shares = &shareSigner{secret: append([]byte(nil), secret...), maxTTL: maxTTL}
+	return nil
+}
+
+type ShareRunRequest struct {
+	writeRequest
+	Project    string     `json:"project"`
+	RunID      string     `json:"run_id"`
+	TTLSeconds int64      `json:"ttl_seconds"`
+	Actor      AuditActor `json:"-"`
+}
+
+// RunShare is a created share link. Token is a bearer credential for the
+// run; Path is where the dashboard serves it.
+type RunShare struct {
+	ShareID   string `json:"share_id"`
+	Project   string `json:"project"`
+	RunID     string `json:"run_id"`
+	Token     string `json:"token"`
+	Path      string `json:"path"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type OpenRunShareRequest struct {
+	Token      string
+	RemoteAddr string
+	UserAgent  string
+}
+
+// SharedRun is what a share link opens: the run's GetRun detail and when
+// the link stops working.
+type SharedRun struct {
+	RunDetail
+	ShareID   string `json:"share_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type sharePayload struct {
+	ShareID   string `json:"s"`
+	Project   string `json:"p"`
+	RunID     string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// ShareRun signs a read-only link to a run, valid for ttl_seconds (default
+// 24h, at most SHARE_LINK_MAX_TTL_HOURS).
+func (h *HubService) ShareRun(ctx context.Context, request ShareRunRequest) (RunShare, error) {
+	if h.shares == nil {
+		return RunShare{}, domain.FailedPrecondition("run share links are disabled; set SHARE_LINK_SECRET")
+	}
+	runID := strings.TrimSpace(request.RunID)
+	if runID == "" {
+		return RunShare{}, domain.InvalidArgument("run_id is required")
+	}
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return RunShare{}, err
+	}
+	if request.TTLSeconds < 0 {
+		return RunShare{}, domain.InvalidArgument("ttl_seconds must be non-negative")
+	}
+	// Checked in seconds, before converting, so a huge value cannot wrap.
+	maxTTLSeconds := int64(h.shares.maxTTL / time.Second)
+	if request.TTLSeconds > maxTTLSeconds || (request.TTLSeconds == 0 && defaultShareTTL > h.shares.maxTTL) {
+		return RunShare{}, domain.InvalidArgument(fmt.Sprintf("ttl_seconds must be at most %d", maxTTLSeconds))
+	}
+	ttl := defaultShareTTL
+	if request.TTLSeconds > 0 {
+		ttl = time.Duration(request.TTLSeconds) * time.Second
+	}
+	runs, err := h.store.ListRunsFiltered(ctx, domain.RunFilter{Project: project, RunID: runID, Limit: 1})
+	if err != nil {
+		return RunShare{}, err
+	}
+	if len(runs) == 0 {
+		return RunShare{}, domain.NotFound("run not found")
+	}
+	run := runs[0]
+
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	payload := sharePayload{ShareID: newID("shr"), Project: run.Project, RunID: run.ID, ExpiresAt: expiresAt.Unix()}
+	token, err := h.shares.sign(payload)
+	if err != nil {
+		return RunShare{}, err
+	}
+	share := RunShare{
+		ShareID:   payload.ShareID,
+		Project:   run.Project,
+		RunID:     run.ID,
+		Token:     token,
+		Path:      "/share/runs/" + token,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	// The token is a credential, so the audit entry records only its ID.
+	if err := h.recordPolicyAudit(ctx, "run_share", run.ID, run.Project, "create", request.Actor, nil, map[string]string{
+		"share_id":   share.ShareID,
+		"expires_at": share.ExpiresAt,
+	}); err != nil {
+		return RunShare{}, err
+	}
+	return share, nil
+}
+
+// OpenRunShare checks a share token and returns the run it names, writing
+// the access to the audit trail. Tampered and expired tokens are NotFound.
+func (h *HubService) OpenRunShare(ctx context.Context, request OpenRunShareRequest) (SharedRun, error) {
+	if h.shares == nil {
+		return SharedRun{}, domain.FailedPrecondition("run share links are disabled")
+	}
+	payload, ok := h.shares.verify(strings.TrimSpace(request.Token))
+	if !ok {
+		return SharedRun{}, domain.NotFound("share link is invalid")
+	}
+	expiresAt := time.Unix(payload.ExpiresAt, 0).UTC()
+	if !time.Now().Before(expiresAt) {
+		return SharedRun{}, domain.NotFound("share link has expired")
+	}
+	detail, err := h.GetRun(ctx, GetRunRequest{Project: payload.Project, RunID: payload.RunID})
+	if err != nil {
+		return SharedRun{}, err
+	}
+	// Failing closed: an access that cannot be audited is not served.
+	if err := h.recordPolicyAudit(ctx, "run_share", payload.RunID, payload.Project, "access", AuditActor{AgentID: "share-link", KeyID: payload.ShareID}, nil, map[string]string{
+		"share_id":    payload.ShareID,
+		"remote_addr": request.RemoteAddr,
+		"user_agent":  request.UserAgent,
+	}); err != nil {
+		return SharedRun{}, err
+	}
+	return SharedRun{RunDetail: detail, ShareID: payload.ShareID, ExpiresAt: expiresAt.Format(time.RFC3339)}, nil
+}
+
+// sign encodes payload as base64url JSON followed by its base64url
+// HMAC-SHA256, which keeps the token safe in a URL path.
+func (s *shareSigner) sign(payload sharePayload) (string, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", domain.Internal("failed to encode share link", err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(encoded)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body)), nil
+}
+
+func (s *shareSigner) verify(token string) (sharePayload, bool) {
+	body, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return sharePayload{}, false
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, s.mac(body)) {
+		return sharePayload{}, false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return sharePayload{}, false
+	}
+	var payload sharePayload
+	if err := json.Unmarshal(decoded, &payload); err != nil || payload.RunID == "" {
+		return sharePayload{}, false
+	}
+	return payload, true
+}
+
+func (s *shareSigner) mac(body string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+func newTestHub(t *testing.T) *HubService {
+	t.Helper()
+	hubStore := store.NewMemoryStore()
+	if err := hubStore.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	run := domain.AgentRun{ID: "run1", Project: domain.DefaultProject, Workflow: "wf", AgentID: "agent", Status: "running", Metadata: map[string]string{}, StartedAt: "2026-01-01T00:00:00Z"}
+	if err := hubStore.InsertRun(context.Background(), run); err != nil {
+		t.Fatalf("insert run: %v", err)
+	}
+	return NewHubService(hubStore, "memory")
+}
+
+func newShareHub(t *testing.T, maxTTL time.Duration) *HubService {
+	t.Helper()
+	hub := newTestHub(t)
+	if err := hub.EnableShareLinks([]byte(strings.Repeat("k", minShareSecret)), maxTTL); err != nil {
+		t.Fatalf("enable share links: %v", err)
+	}
+	return hub
+}
+
+func expectCode(t *testing.T, err error, code domain.ErrorCode, what string) {
+	t.Helper()
+	appErr, ok := domain.AsAppError(err)
+	if !ok || appErr.Code != code {
+		t.Fatalf("%s: expected %s, got %v", what, code, err)
+	}
+}
+
+func TestRunShareOpensAndAudits(t *testing.T) {
+	hub := newShareHub(t, 48*time.Hour)
+	ctx := context.Background()
+	share, err := hub.ShareRun(ctx, ShareRunRequest{RunID: "run1", TTLSeconds: 3600, Actor: AuditActor{AgentID: "alice", KeyID: "key1"}})
+	if err != nil {
+		t.Fatalf("share run: %v", err)
+	}
+	if share.Path != "/share/runs/"+share.Token {
+		t.Fatalf("unexpected share path %q", share.Path)
+	}
+	shared, err := hub.OpenRunShare(ctx, OpenRunShareRequest{Token: share.Token, RemoteAddr: "10.0.0.1:1234", UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("open share: %v", err)
+	}
+	if shared.Run.ID != "run1" || shared.ShareID != share.ShareID {
+		t.Fatalf("expected run1 under share %s, got %s under %s", share.ShareID, shared.Run.ID, shared.ShareID)
+	}
+
+	entries, err := hub.store.ListPolicyAudit(ctx, domain.PolicyAuditFilter{TargetType: "run_share"})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	actions := map[string]domain.PolicyAuditEntry{}
+	for _, entry := range entries {
+		actions[entry.Action] = entry
+		if strings.Contains(entry.AfterJSON, share.Token) {
+			t.Fatalf("audit entry %s leaks the token", entry.Action)
+		}
+	}
+	if created, ok := actions["create"]; !ok || created.ActorAgentID != "alice" || !strings.Contains(created.AfterJSON, share.ShareID) {
+		t.Fatalf("expected a create entry by alice naming the share, got %+v", created)
+	}
+	if accessed, ok := actions["access"]; !ok || accessed.ActorKeyID != share.ShareID || !strings.Contains(accessed.AfterJSON, "10.0.0.1:1234") {
+		t.Fatalf("expected an access entry for the share with the remote address, got %+v", accessed)
+	}
+}
+
+func TestRunShareRejectsTamperedAndExpiredTokens(t *testing.T) {
+	hub := newShareHub(t, 48*time.Hour)
+	ctx := context.Background()
+	share, err := hub.ShareRun(ctx, ShareRunRequest{RunID: "run1"})
+	if err != nil {
+		t.Fatalf("share run: %v", err)
+	}
+	body, signature, _ := strings.Cut(share.Token, ".")
+
+	forged, err := hub.shares.sign(sharePayload{ShareID: "shr_x", Project: domain.DefaultProject, RunID: "run2", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	forgedBody, _, _ := strings.Cut(forged, ".")
+	other := newShareHub(t, 48*time.Hour)
+	other.shares.secret = []byte(strings.Repeat("z", minShareSecret))
+	otherToken, err := other.shares.sign(sharePayload{ShareID: "shr_y", Project: domain.DefaultProject, RunID: "run1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	for name, token := range map[string]string{
+		"swapped body":  forgedBody + "." + signature,
+		"cut signature": body + "." + signature[:len(signature)-2],
+		"no signature":  body,
+		"other key":     otherToken,
+		"garbage":       "not-a-token",
+		"empty":         "",
+	} {
+		_, err := hub.OpenRunShare(ctx, OpenRunShareRequest{Token: token})
+		expectCode(t, err, domain.CodeNotFound, name)
+	}
+
+	expired, err := hub.shares.sign(sharePayload{ShareID: "shr_old", Project: domain.DefaultProject, RunID: "run1", ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	_, err = hub.OpenRunShare(ctx, OpenRunShareRequest{Token: expired})
+	expectCode(t, err, domain.CodeNotFound, "expired")
+
+	entries, err := hub.store.ListPolicyAudit(ctx, domain.PolicyAuditFilter{TargetType: "run_share"})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Action == "access" {
+			t.Fatalf("expected refused tokens not to be audited as accesses, got %+v", entry)
+		}
+	}
+}
+
+func TestRunShareBoundsTTL(t *testing.T) {
+	hub := newShareHub(t, 48*time.Hour)
+	ctx := context.Background()
+	for name, ttl := range map[string]int64{
+		"negative":       -1,
+		"over max":       48*3600 + 1,
+		"would overflow": math.MaxInt64,
+		"wraps negative": math.MaxInt64/int64(time.Second) + 1,
+	} {
+		_, err := hub.ShareRun(ctx, ShareRunRequest{RunID: "run1", TTLSeconds: ttl})
+		expectCode(t, err, domain.CodeInvalidArgument, name)
+	}
+	share, err := hub.ShareRun(ctx, ShareRunRequest{RunID: "run1", TTLSeconds: 48 * 3600})
+	if err != nil {
+		t.Fatalf("expected the max ttl to be allowed: %v", err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, share.ExpiresAt)
+	if err != nil || expiresAt.Before(time.Now().Add(47*time.Hour)) {
+		t.Fatalf("expected the link to last 48h, expires %s", share.ExpiresAt)
+	}
+
+	short := newShareHub(t, time.Hour)
+	_, err = short.ShareRun(ctx, ShareRunRequest{RunID: "run1"})
+	expectCode(t, err, domain.CodeInvalidArgument, "default ttl over a shorter max")
+	if _, err := short.ShareRun(ctx, ShareRunRequest{RunID: "run1", TTLSeconds: 600}); err != nil {
+		t.Fatalf("expected a ttl under a shorter max to be allowed: %v", err)
+	}
+	_, err = short.ShareRun(ctx, ShareRunRequest{RunID: "missing", TTLSeconds: 600})
+	expectCode(t, err, domain.CodeNotFound, "unknown run")
+}
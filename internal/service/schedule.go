@@ -0,0 +1,241 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const maxMaintenanceWindowMinutes = 7 * 24 * 60
+
+// cronSchedule is a parsed five-field cron expression
+// (minute hour day-of-month month day-of-week), evaluated in UTC.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]struct{}
+	daysRestricted, weekdaysRestricted     bool
+}
+
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron must have 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+	var schedule cronSchedule
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("minute: %w", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("hour: %w", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("day of month: %w", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("month: %w", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("day of week: %w", err)
+	}
+	// 7 is an alias for Sunday.
+	if _, ok := schedule.weekdays[7]; ok {
+		schedule.weekdays[0] = struct{}{}
+	}
+	schedule.daysRestricted = fields[2] != "*"
+	schedule.weekdaysRestricted = fields[4] != "*"
+	return schedule, nil
+}
+
+// parseCronField accepts *, N, N-M, and /step forms, comma separated.
+func parseCronField(field string, minValue, maxValue int) (map[int]struct{}, error) {
+	out := map[int]struct{}{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+			step = parsed
+		}
+
+		low, high := minValue, maxValue
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			lowRaw, highRaw, _ := strings.Cut(rangePart, "-")
+			var errLow, errHigh error
+			low, errLow = strconv.Atoi(lowRaw)
+			high, errHigh = strconv.Atoi(highRaw)
+			if errLow != nil || errHigh != nil || low > high {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			low, high = value, value
+			if hasStep {
+				high = maxValue
+			}
+		}
+		if low < minValue || high > maxValue {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, minValue, maxValue)
+		}
+		for value := low; value <= high; value += step {
+			out[value] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+func (c cronSchedule) matches(at time.Time) bool {
+	if _, ok := c.minutes[at.Minute()]; !ok {
+		return false
+	}
+	if _, ok := c.hours[at.Hour()]; !ok {
+		return false
+	}
+	if _, ok := c.months[int(at.Month())]; !ok {
+		return false
+	}
+	_, dayOK := c.days[at.Day()]
+	_, weekdayOK := c.weekdays[int(at.Weekday())]
+	// Standard cron: when both day fields are restricted, either may match.
+	if c.daysRestricted && c.weekdaysRestricted {
+		return dayOK || weekdayOK
+	}
+	return dayOK && weekdayOK
+}
+
+// normalizeMaintenanceWindows validates windows from SetPolicy and rewrites
+// fixed timestamps to UTC RFC3339.
+func normalizeMaintenanceWindows(windows []domain.MaintenanceWindow) ([]domain.MaintenanceWindow, error) {
+	out := make([]domain.MaintenanceWindow, 0, len(windows))
+	for i, window := range windows {
+		window.Name = strings.TrimSpace(window.Name)
+		window.Reason = strings.TrimSpace(window.Reason)
+		window.Cron = strings.Join(strings.Fields(window.Cron), " ")
+		window.StartsAt = strings.TrimSpace(window.StartsAt)
+		window.EndsAt = strings.TrimSpace(window.EndsAt)
+		label := window.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+
+		if window.Cron != "" {
+			if window.StartsAt != "" || window.EndsAt != "" {
+				return nil, domain.InvalidArgument("maintenance window " + label + ": use either cron or starts_at/ends_at")
+			}
+			if _, err := parseCron(window.Cron); err != nil {
+				return nil, domain.InvalidArgument("maintenance window " + label + ": " + err.Error())
+			}
+			if window.DurationMinutes <= 0 || window.DurationMinutes > maxMaintenanceWindowMinutes {
+				return nil, domain.InvalidArgument(fmt.Sprintf("maintenance window %s: duration_minutes must be between 1 and %d", label, maxMaintenanceWindowMinutes))
+			}
+			out = append(out, window)
+			continue
+		}
+
+		if window.StartsAt == "" || window.EndsAt == "" {
+			return nil, domain.InvalidArgument("maintenance window " + label + ": cron or both starts_at and ends_at are required")
+		}
+		startsAt, err := time.Parse(time.RFC3339, window.StartsAt)
+		if err != nil {
+			return nil, domain.InvalidArgument("maintenance window " + label + ": starts_at must be RFC3339")
+		}
+		endsAt, err := time.Parse(time.RFC3339, window.EndsAt)
+		if err != nil {
+			return nil, domain.InvalidArgument("maintenance window " + label + ": ends_at must be RFC3339")
+		}
+		if !endsAt.After(startsAt) {
+			return nil, domain.InvalidArgument("maintenance window " + label + ": ends_at must be after starts_at")
+		}
+		window.StartsAt = startsAt.UTC().Format(time.RFC3339)
+		window.EndsAt = endsAt.UTC().Format(time.RFC3339)
+		window.DurationMinutes = 0
+		out = append(out, window)
+	}
+	return out, nil
+}
+
+// activeMaintenanceWindow returns the first window open at now. Cron windows
+// are open for DurationMinutes after any matching minute.
+func activeMaintenanceWindow(windows []domain.MaintenanceWindow, now time.Time) (domain.MaintenanceWindow, bool) {
+	now = now.UTC()
+	for _, window := range windows {
+		if window.Cron != "" {
+			schedule, err := parseCron(window.Cron)
+			if err != nil {
+				continue
+			}
+			minute := now.Truncate(time.Minute)
+			for offset := int64(0); offset < window.DurationMinutes; offset++ {
+				if schedule.matches(minute.Add(-time.Duration(offset) * time.Minute)) {
+					return window, true
+				}
+			}
+			continue
+		}
+		startsAt, errStart := time.Parse(time.RFC3339, window.StartsAt)
+		endsAt, errEnd := time.Parse(time.RFC3339, window.EndsAt)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+		if !now.Before(startsAt) && now.Before(endsAt) {
+			return window, true
+		}
+	}
+	return domain.MaintenanceWindow{}, false
+}
+
+func maintenanceWindowReason(window domain.MaintenanceWindow) string {
+	if window.Reason != "" {
+		return window.Reason
+	}
+	if window.Name != "" {
+		return "maintenance window " + window.Name + " is open"
+	}
+	return "maintenance window is open"
+}
+
+// applyScheduledKillSwitch sets the scheduled kill-switch fields for now and
+// reports whether they changed.
+func applyScheduledKillSwitch(policy *domain.OrchestrationPolicy, now time.Time) bool {
+	window, active := activeMaintenanceWindow(policy.MaintenanceWindows, now)
+	reason := ""
+	if active {
+		reason = maintenanceWindowReason(window)
+	}
+	if policy.ScheduledKillSwitch == active && policy.ScheduledKillSwitchReason == reason {
+		return false
+	}
+	policy.ScheduledKillSwitch = active
+	policy.ScheduledKillSwitchReason = reason
+	return true
+}
+
+// killSwitchEngaged reports whether runs are blocked, by the manual kill
+// switch or an open maintenance window, and why.
+func killSwitchEngaged(policy domain.OrchestrationPolicy) (string, bool) {
+	if policy.KillSwitch {
+		reason := strings.TrimSpace(policy.KillSwitchReason)
+		if reason == "" {
+			reason = "kill switch is enabled"
+		}
+		return reason, true
+	}
+	if policy.ScheduledKillSwitch {
+		reason := strings.TrimSpace(policy.ScheduledKillSwitchReason)
+		if reason == "" {
+			reason = "maintenance window is open"
+		}
+		return reason, true
+	}
+	return "", false
+}
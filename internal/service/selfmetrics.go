@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const (
+	// SelfMetricsWorkflow is the benchmark workflow the server's own health
+	// metrics are recorded under.
+	SelfMetricsWorkflow = "modeloman-self-metrics"
+	// selfMetricsMaxSamples bounds each series between recordings; past it,
+	// samples are kept by reservoir sampling.
+	selfMetricsMaxSamples = 4096
+)
+
+// SelfMetrics collects RPC latencies, store probe latencies and recovered
+// panics between RecordSelfMetrics calls. It is safe for concurrent use.
+type SelfMetrics struct {
+	mu      sync.Mutex
+	started time.Time
+	rpc     latencySamples
+	store   latencySamples
+	panics  map[string]int64
+}
+
+type latencySamples struct {
+	seen    int
+	samples []time.Duration
+}
+
+func (s *latencySamples) add(elapsed time.Duration) {
+	s.seen++
+	if len(s.samples) < selfMetricsMaxSamples {
+		s.samples = append(s.samples, elapsed)
+		return
+	}
+	if i := rand.IntN(s.seen); i < selfMetricsMaxSamples {
+		s.samples[i] = elapsed
+	}
+}
+
+func NewSelfMetrics() *SelfMetrics {
+	return &SelfMetrics{started: time.Now()}
+}
+
+// ObserveRPC records one RPC's server-side duration.
+func (m *SelfMetrics) ObserveRPC(_ string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rpc.add(elapsed)
+}
+
+// RecordPanic counts one recovered panic under its fingerprint.
+func (m *SelfMetrics) RecordPanic(_ context.Context, report PanicReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.panics == nil {
+		m.panics = map[string]int64{}
+	}
+	m.panics[report.Fingerprint]++
+	return nil
+}
+
+func (m *SelfMetrics) observeStore(elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store.add(elapsed)
+}
+
+// drain returns the samples and panic counts collected since the last drain
+// and the window they cover, and starts a new window.
+func (m *SelfMetrics) drain(now time.Time) (rpc, store latencySamples, panics map[string]int64, window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rpc, store, panics, window = m.rpc, m.store, m.panics, now.Sub(m.started)
+	m.rpc, m.store, m.panics, m.started = latencySamples{}, latencySamples{}, nil, now
+	return rpc, store, panics, window
+}
+
+// ProbeStoreLatency times one policy read, the cheapest query every store
+// serves from its primary, as a store latency sample.
+func (h *HubService) ProbeStoreLatency(ctx context.Context, metrics *SelfMetrics) error {
+	started := time.Now()
+	if _, err := h.store.GetPolicy(ctx); err != nil {
+		return err
+	}
+	metrics.observeStore(time.Since(started))
+	return nil
+}
+
+// RecordSelfMetrics writes the RPC and store latencies collected since the
+// last call as benchmark rows under SelfMetricsWorkflow, with model "rpc" or
+// "store", latency_ms set to the p99 and the distribution in notes. Series
+// without samples are skipped. Recovered panics, if any, add a "panics" row
+// with the total and the count per fingerprint in notes.
+func (h *HubService) RecordSelfMetrics(ctx context.Context, metrics *SelfMetrics) ([]domain.Benchmark, error) {
+	rpc, store, panics, window := metrics.drain(time.Now())
+	recorded := []domain.Benchmark{}
+	for _, series := range []struct {
+		model   string
+		samples latencySamples
+	}{{"rpc", rpc}, {"store", store}} {
+		if series.samples.seen == 0 {
+			continue
+		}
+		sorted := slices.Clone(series.samples.samples)
+		slices.Sort(sorted)
+		p99 := latencyPercentile(sorted, 0.99)
+		record := domain.Benchmark{
+			ID:           newID("bm"),
+			Workflow:     SelfMetricsWorkflow,
+			ProviderType: "internal",
+			Provider:     "modeloman",
+			Model:        series.model,
+			LatencyMS:    int64(math.Ceil(float64(p99) / float64(time.Millisecond))),
+			Notes: fmt.Sprintf("p50_ms=%.3f p99_ms=%.3f max_ms=%.3f count=%d window=%s",
+				durationMS(latencyPercentile(sorted, 0.50)), durationMS(p99), durationMS(sorted[len(sorted)-1]),
+				series.samples.seen, window.Round(time.Second)),
+			CreatedAt: timeNow(),
+		}
+		if err := h.store.InsertBenchmark(ctx, record); err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, record)
+	}
+	if len(panics) > 0 {
+		fingerprints := slices.Sorted(maps.Keys(panics))
+		total := int64(0)
+		counts := make([]string, 0, len(fingerprints))
+		for _, fingerprint := range fingerprints {
+			total += panics[fingerprint]
+			counts = append(counts, fmt.Sprintf("%s:%d", fingerprint, panics[fingerprint]))
+		}
+		record := domain.Benchmark{
+			ID:           newID("bm"),
+			Workflow:     SelfMetricsWorkflow,
+			ProviderType: "internal",
+			Provider:     "modeloman",
+			Model:        "panics",
+			Notes:        fmt.Sprintf("count=%d fingerprints=%s window=%s", total, strings.Join(counts, ","), window.Round(time.Second)),
+			CreatedAt:    timeNow(),
+		}
+		if err := h.store.InsertBenchmark(ctx, record); err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, record)
+	}
+	return recorded, nil
+}
+
+// latencyPercentile is the nearest-rank percentile of sorted, which must not
+// be empty.
+func latencyPercentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const (
+	defaultTimeSeriesBucket = time.Hour
+	defaultTimeSeriesWindow = 7 * 24 * time.Hour
+	minTimeSeriesBucket     = time.Minute
+	// maxTimeSeriesPoints bounds the buckets in one series, window over
+	// bucket, so a narrow bucket cannot ask for a huge response.
+	maxTimeSeriesPoints = 2000
+)
+
+var validTimeSeriesMetrics = map[string]struct{}{"cost": {}, "attempts": {}, "success_rate": {}}
+
+type TimeSeriesRequest struct {
+	Project  string `json:"project"`
+	Workflow string `json:"workflow"`
+	Model    string `json:"model"`
+	// Metric is cost (USD, the default), attempts or success_rate.
+	Metric string `json:"metric"`
+	// BucketSeconds defaults to an hour and WindowSeconds to 7 days.
+	BucketSeconds int64 `json:"bucket_seconds"`
+	WindowSeconds int64 `json:"window_seconds"`
+}
+
+// TimeSeriesPoint is one bucket of a series. Attempts is given for every
+// metric, so a success_rate of 0 in a bucket without attempts can be told
+// from one where all of them failed.
+type TimeSeriesPoint struct {
+	Start    string  `json:"start"`
+	Value    float64 `json:"value"`
+	Attempts int64   `json:"attempts"`
+}
+
+// TimeSeries is one workflow and model's metric over the window, with a
+// point for every bucket. Total is the metric over the whole window.
+type TimeSeries struct {
+	Workflow string            `json:"workflow"`
+	Model    string            `json:"model"`
+	Total    float64           `json:"total"`
+	Points   []TimeSeriesPoint `json:"points"`
+}
+
+// TimeSeriesReport holds the series with attempts in the window, ordered by
+// workflow and model. From is the first bucket's start and To the end of
+// the last.
+type TimeSeriesReport struct {
+	Metric        string       `json:"metric"`
+	BucketSeconds int64        `json:"bucket_seconds"`
+	From          string       `json:"from"`
+	To            string       `json:"to"`
+	Series        []TimeSeries `json:"series"`
+}
+
+// TimeSeries charts attempt cost, count or success rate per workflow and
+// model in fixed buckets counted from the Unix epoch in UTC, so repeated
+// polls line up. The store does the bucketing; this fills the empty
+// buckets and computes the metric.
+func (h *HubService) TimeSeries(ctx context.Context, request TimeSeriesRequest) (TimeSeriesReport, error) {
+	metric := strings.TrimSpace(request.Metric)
+	if metric == "" {
+		metric = "cost"
+	}
+	if _, ok := validTimeSeriesMetrics[metric]; !ok {
+		return TimeSeriesReport{}, domain.InvalidArgument("metric must be one of: cost, attempts, success_rate")
+	}
+	// Bounds are checked in seconds, before converting to a Duration, which
+	// would overflow for very large inputs.
+	maxWindowSeconds := int64(maxCostSeriesWindowDays * 24 * 60 * 60)
+	bucketSeconds := int64(defaultTimeSeriesBucket / time.Second)
+	if request.BucketSeconds != 0 {
+		bucketSeconds = request.BucketSeconds
+	}
+	if bucketSeconds < int64(minTimeSeriesBucket/time.Second) || bucketSeconds > maxWindowSeconds {
+		return TimeSeriesReport{}, domain.InvalidArgument(fmt.Sprintf("bucket must be at least %s and at most %d days", minTimeSeriesBucket, maxCostSeriesWindowDays))
+	}
+	windowSeconds := int64(defaultTimeSeriesWindow / time.Second)
+	if request.WindowSeconds != 0 {
+		windowSeconds = request.WindowSeconds
+	}
+	if windowSeconds <= 0 || windowSeconds > maxWindowSeconds {
+		return TimeSeriesReport{}, domain.InvalidArgument(fmt.Sprintf("window must be positive and at most %d days", maxCostSeriesWindowDays))
+	}
+	if windowSeconds/bucketSeconds > maxTimeSeriesPoints {
+		return TimeSeriesReport{}, domain.InvalidArgument(fmt.Sprintf("window over bucket must be at most %d points; widen the bucket", maxTimeSeriesPoints))
+	}
+	bucket := time.Duration(bucketSeconds) * time.Second
+	window := time.Duration(windowSeconds) * time.Second
+	project, err := normalizeProject(request.Project)
+	if err != nil {
+		return TimeSeriesReport{}, err
+	}
+
+	width := bucketSeconds
+	now := time.Now().UTC()
+	to := now.Unix() - now.Unix()%width + width
+	from := now.Add(-window).Unix()
+	from -= from % width
+	buckets, err := h.store.AttemptTimeSeries(ctx, domain.AttemptFilter{
+		Project:      project,
+		Workflow:     strings.TrimSpace(request.Workflow),
+		Model:        strings.TrimSpace(request.Model),
+		CreatedAfter: time.Unix(from, 0).UTC().Format(time.RFC3339Nano),
+	}, bucket)
+	if err != nil {
+		return TimeSeriesReport{}, err
+	}
+
+	type seriesKey struct{ workflow, model string }
+	type slot struct {
+		attempts, successes int64
+		cost                float64
+	}
+	count := int((to - from) / width)
+	slots := map[seriesKey][]slot{}
+	for _, item := range buckets {
+		start, err := time.Parse(time.RFC3339, item.Start)
+		if err != nil {
+			continue
+		}
+		index := int((start.Unix() - from) / width)
+		if index < 0 || index >= count {
+			continue
+		}
+		key := seriesKey{item.Workflow, item.Model}
+		if slots[key] == nil {
+			slots[key] = make([]slot, count)
+		}
+		slots[key][index].attempts += item.Attempts
+		slots[key][index].successes += item.SuccessAttempts
+		slots[key][index].cost += item.CostUSD
+	}
+
+	value := func(s slot) float64 {
+		switch metric {
+		case "attempts":
+			return float64(s.attempts)
+		case "success_rate":
+			if s.attempts == 0 {
+				return 0
+			}
+			return float64(s.successes) / float64(s.attempts)
+		}
+		return s.cost
+	}
+	report := TimeSeriesReport{
+		Metric:        metric,
+		BucketSeconds: width,
+		From:          time.Unix(from, 0).UTC().Format(time.RFC3339),
+		To:            time.Unix(to, 0).UTC().Format(time.RFC3339),
+		Series:        make([]TimeSeries, 0, len(slots)),
+	}
+	for key, series := range slots {
+		row := TimeSeries{Workflow: key.workflow, Model: key.model, Points: make([]TimeSeriesPoint, count)}
+		var total slot
+		for i, s := range series {
+			row.Points[i] = TimeSeriesPoint{
+				Start:    time.Unix(from+int64(i)*width, 0).UTC().Format(time.RFC3339),
+				Value:    value(s),
+				Attempts: s.attempts,
+			}
+			total.attempts += s.attempts
+			total.successes += s.successes
+			total.cost += s.cost
+		}
+		row.Total = value(total)
+		report.Series = append(report.Series, row)
+	}
+	slices.SortFunc(report.Series, func(a, b TimeSeries) int {
+		return cmp.Or(strings.Compare(a.Workflow, b.Workflow), strings.Compare(a.Model, b.Model))
+	})
+	return report, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+func TestTimeSeriesBoundsSpansBeforeConverting(t *testing.T) {
+	hub := newTestHub(t)
+	ctx := context.Background()
+	wraps := math.MaxInt64/int64(time.Second) + 1
+	for name, request := range map[string]TimeSeriesRequest{
+		"huge bucket":           {BucketSeconds: math.MaxInt64},
+		"bucket wraps negative": {BucketSeconds: wraps},
+		"bucket too narrow":     {BucketSeconds: 59},
+		"bucket over max":       {BucketSeconds: maxCostSeriesWindowDays*24*3600 + 1},
+		"huge window":           {WindowSeconds: math.MaxInt64},
+		"window wraps negative": {WindowSeconds: wraps},
+		"negative window":       {WindowSeconds: -1},
+		"too many points":       {BucketSeconds: 60, WindowSeconds: 60 * (maxTimeSeriesPoints + 1)},
+	} {
+		_, err := hub.TimeSeries(ctx, request)
+		expectCode(t, err, domain.CodeInvalidArgument, name)
+	}
+
+	report, err := hub.TimeSeries(ctx, TimeSeriesRequest{BucketSeconds: maxCostSeriesWindowDays * 24 * 3600, WindowSeconds: 3600})
+	if err != nil {
+		t.Fatalf("expected a bucket as wide as the max window to be allowed: %v", err)
+	}
+	if report.BucketSeconds != maxCostSeriesWindowDays*24*3600 {
+		t.Fatalf("unexpected bucket %d", report.BucketSeconds)
+	}
+}
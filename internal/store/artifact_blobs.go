@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// DiskArtifactBlobStore keeps one file per artifact under a root directory.
+type DiskArtifactBlobStore struct {
+	root string
+}
+
+func NewDiskArtifactBlobStore(root string) *DiskArtifactBlobStore {
+	return &DiskArtifactBlobStore{root: root}
+}
+
+func (s *DiskArtifactBlobStore) PutArtifactBlob(id string, content []byte) error {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.root, 0o755); err != nil {
+		return domain.Internal("failed to create artifact directory", err)
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o600); err != nil {
+		return domain.Internal("failed to write temporary artifact file", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return domain.Internal("failed to persist artifact file", err)
+	}
+	return nil
+}
+
+func (s *DiskArtifactBlobStore) GetArtifactBlob(id string) ([]byte, error) {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, domain.NotFound("artifact content not found")
+		}
+		return nil, domain.Internal("failed to read artifact file", err)
+	}
+	return raw, nil
+}
+
+func (s *DiskArtifactBlobStore) DeleteArtifactBlob(id string) error {
+	path, err := s.blobPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return domain.Internal("failed to delete artifact file", err)
+	}
+	return nil
+}
+
+func (s *DiskArtifactBlobStore) blobPath(id string) (string, error) {
+	clean := strings.TrimSpace(id)
+	if clean == "" || clean != filepath.Base(clean) || strings.HasPrefix(clean, ".") {
+		return "", domain.InvalidArgument("artifact id is invalid")
+	}
+	return filepath.Join(s.root, clean+".bin"), nil
+}
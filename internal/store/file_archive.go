@@ -0,0 +1,261 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const (
+	archiveKindAttempt  = "attempt"
+	archiveKindRunEvent = "run_event"
+)
+
+// fileArchive is the cold tier of a FileStore: prompt attempts and run events
+// moved out of the state by ArchiveBefore. On disk it is a JSONL file of
+// archiveEntry lines, one appended gzip member per archiving pass; a
+// MemoryStore keeps it in memory instead.
+type fileArchive struct {
+	Attempts  []domain.PromptAttempt
+	RunEvents []domain.RunEvent
+}
+
+type archiveEntry struct {
+	Kind   string          `json:"kind"`
+	Record json.RawMessage `json:"record"`
+}
+
+func (s *FileStore) archivePath() string {
+	return s.path + ".archive.jsonl.gz"
+}
+
+// ArchiveBefore moves attempts and run events created before cutoff from the
+// state into the archive file. The archive is appended before the snapshot
+// drops the records, so a crash in between leaves them in both, and reads
+// prefer the hot copy.
+func (s *FileStore) ArchiveBefore(ctx context.Context, cutoff time.Time) (domain.ArchiveResult, error) {
+	result := domain.ArchiveResult{}
+	err := s.Mutate(ctx, func(state *domain.State) error {
+		moved := fileArchive{}
+		keptAttempts := state.Attempts[:0]
+		for _, item := range state.Attempts {
+			if createdBefore(item.CreatedAt, cutoff) {
+				moved.Attempts = append(moved.Attempts, item)
+				continue
+			}
+			keptAttempts = append(keptAttempts, item)
+		}
+		state.Attempts = keptAttempts
+		keptEvents := state.RunEvents[:0]
+		for _, item := range state.RunEvents {
+			if createdBefore(item.CreatedAt, cutoff) {
+				moved.RunEvents = append(moved.RunEvents, item)
+				continue
+			}
+			keptEvents = append(keptEvents, item)
+		}
+		state.RunEvents = keptEvents
+		if len(moved.Attempts) == 0 && len(moved.RunEvents) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.appendArchiveLocked(moved); err != nil {
+			return err
+		}
+		result.AttemptsArchived = int64(len(moved.Attempts))
+		result.RunEventsArchived = int64(len(moved.RunEvents))
+		return nil
+	})
+	return result, err
+}
+
+// readArchive loads the whole archive under the read lock.
+func (s *FileStore) readArchive() (fileArchive, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readArchiveLocked()
+}
+
+// readArchiveLocked loads the whole archive; s.mu must be held.
+func (s *FileStore) readArchiveLocked() (fileArchive, error) {
+	if s.ephemeral {
+		return cloneRecord(s.archived), nil
+	}
+	archive := fileArchive{}
+	file, err := os.Open(s.archivePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return archive, nil
+		}
+		return archive, domain.Internal("failed to open archive file", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return archive, nil
+		}
+		return archive, domain.Internal("failed to read archive file", err)
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry archiveEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return archive, domain.Internal("failed to parse archive file", err)
+		}
+		switch entry.Kind {
+		case archiveKindAttempt:
+			var attempt domain.PromptAttempt
+			if err := json.Unmarshal(entry.Record, &attempt); err != nil {
+				return archive, domain.Internal("failed to parse archived attempt", err)
+			}
+			if attempt.Project == "" {
+				attempt.Project = domain.DefaultProject
+			}
+			archive.Attempts = append(archive.Attempts, attempt)
+		case archiveKindRunEvent:
+			var event domain.RunEvent
+			if err := json.Unmarshal(entry.Record, &event); err != nil {
+				return archive, domain.Internal("failed to parse archived run event", err)
+			}
+			archive.RunEvents = append(archive.RunEvents, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return archive, domain.Internal("failed to read archive file", err)
+	}
+	return archive, nil
+}
+
+// appendArchiveLocked adds records to the archive as one gzip member; s.mu
+// must be held for writing.
+func (s *FileStore) appendArchiveLocked(moved fileArchive) error {
+	if s.ephemeral {
+		s.archived.Attempts = append(s.archived.Attempts, cloneRecord(moved.Attempts)...)
+		s.archived.RunEvents = append(s.archived.RunEvents, cloneRecord(moved.RunEvents)...)
+		return nil
+	}
+	content, err := encodeArchive(moved)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.archivePath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return domain.Internal("failed to open archive file", err)
+	}
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return domain.Internal("failed to write archive file", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return domain.Internal("failed to sync archive file", err)
+	}
+	return file.Close()
+}
+
+// pruneArchiveLocked drops archived records past the retention cutoffs,
+// rewriting the archive file when anything was removed; s.mu must be held
+// for writing.
+func (s *FileStore) pruneArchiveLocked(runEventsBefore, attemptsBefore time.Time, result *domain.PruneResult) error {
+	archive, err := s.readArchiveLocked()
+	if err != nil {
+		return err
+	}
+	removed := false
+	if !attemptsBefore.IsZero() {
+		kept := archive.Attempts[:0]
+		for _, item := range archive.Attempts {
+			if createdBefore(item.CreatedAt, attemptsBefore) {
+				result.AttemptsDeleted++
+				removed = true
+				continue
+			}
+			kept = append(kept, item)
+		}
+		archive.Attempts = kept
+	}
+	if !runEventsBefore.IsZero() {
+		kept := archive.RunEvents[:0]
+		for _, item := range archive.RunEvents {
+			if createdBefore(item.CreatedAt, runEventsBefore) {
+				result.RunEventsDeleted++
+				removed = true
+				continue
+			}
+			kept = append(kept, item)
+		}
+		archive.RunEvents = kept
+	}
+	if !removed {
+		return nil
+	}
+	if s.ephemeral {
+		s.archived = archive
+		return nil
+	}
+	content, err := encodeArchive(archive)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.archivePath(), content); err != nil {
+		return domain.Internal("failed to write archive file", err)
+	}
+	return nil
+}
+
+// encodeArchive writes records as one gzip member of archiveEntry lines.
+func encodeArchive(archive fileArchive) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(writer)
+	write := func(kind string, record any) error {
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return domain.Internal("failed to encode archived record", err)
+		}
+		return encoder.Encode(archiveEntry{Kind: kind, Record: raw})
+	}
+	for _, attempt := range archive.Attempts {
+		if err := write(archiveKindAttempt, attempt); err != nil {
+			return nil, err
+		}
+	}
+	for _, event := range archive.RunEvents {
+		if err := write(archiveKindRunEvent, event); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, domain.Internal("failed to compress archive", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// withArchived puts the archived records not also in hot ahead of hot, so
+// the newest-first scans in the list methods reach them last.
+func withArchived[T any](archived, hot []T, id func(T) string) []T {
+	seen := make(map[string]struct{}, len(hot))
+	for _, item := range hot {
+		seen[id(item)] = struct{}{}
+	}
+	out := make([]T, 0, len(archived)+len(hot))
+	for _, item := range archived {
+		if _, ok := seen[id(item)]; !ok {
+			out = append(out, item)
+		}
+	}
+	return append(out, hot...)
+}
@@ -0,0 +1,412 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// journalCompactEntries is how many journal records accumulate before the
+// state is rewritten as a fresh snapshot.
+const journalCompactEntries = 1000
+
+const (
+	journalAppend = "append"
+	journalUpsert = "upsert"
+)
+
+// fileSnapshot is the on-disk state file: the state, completed idempotency
+// keys, and the ID of the journal that continues it. Files written before the
+// journal have no ID.
+type fileSnapshot struct {
+	domain.State
+	Idempotency []fileIdempotencyRecord `json:"idempotency_keys,omitempty"`
+	JournalID   string                  `json:"journal_id,omitempty"`
+	Sketches    []fileSketch            `json:"telemetry_sketches,omitempty"`
+	Rollups     []domain.DailyRollup    `json:"daily_rollups,omitempty"`
+}
+
+// fileSketch is one telemetry sketch in the snapshot. A sketch with few
+// registers set, as most days of a small hub have, is stored as Sparse
+// index<<8|value entries instead of all of its registers.
+type fileSketch struct {
+	Day       string   `json:"day"`
+	Dimension string   `json:"dimension"`
+	Registers []byte   `json:"registers,omitempty"`
+	Sparse    []uint32 `json:"sparse,omitempty"`
+}
+
+func (s *FileStore) persistedSketchesLocked() []fileSketch {
+	out := make([]fileSketch, 0, len(s.sketches))
+	for key, sketch := range s.sketches {
+		item := fileSketch{Day: key.day, Dimension: key.dimension}
+		for i, register := range sketch {
+			if register != 0 {
+				item.Sparse = append(item.Sparse, uint32(i)<<8|uint32(register))
+			}
+		}
+		if len(item.Sparse) > hllRegisters/8 {
+			item.Sparse, item.Registers = nil, sketch[:]
+		}
+		out = append(out, item)
+	}
+	slices.SortFunc(out, func(a, b fileSketch) int {
+		return cmp.Or(cmp.Compare(a.Day, b.Day), cmp.Compare(a.Dimension, b.Dimension))
+	})
+	return out
+}
+
+func (f fileSketch) sketch() (*hllSketch, error) {
+	sketch := &hllSketch{}
+	if f.Registers != nil {
+		if len(f.Registers) != hllRegisters {
+			return nil, errors.New("telemetry sketch has the wrong size")
+		}
+		copy(sketch[:], f.Registers)
+		return sketch, nil
+	}
+	for _, entry := range f.Sparse {
+		index := entry >> 8
+		if index >= hllRegisters {
+			return nil, errors.New("telemetry sketch register is out of range")
+		}
+		sketch[index] = uint8(entry)
+	}
+	return sketch, nil
+}
+
+// journalHeader is the first line of the journal. A journal whose ID does not
+// match the snapshot was already folded into it by a compaction that did not
+// get to replace the journal, and is ignored.
+type journalHeader struct {
+	JournalID string `json:"journal_id"`
+}
+
+// journalEntry is one JSONL record: an appended or upserted row of kind.
+type journalEntry struct {
+	Op     string          `json:"op"`
+	Kind   string          `json:"kind"`
+	Record json.RawMessage `json:"record"`
+}
+
+func (s *FileStore) journalPath() string {
+	return s.path + ".journal"
+}
+
+// write journals one record and applies it to the in-memory state, instead
+// of rewriting the whole file as Mutate does.
+func (s *FileStore) write(ctx context.Context, op, kind string, record any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(ctx, op, kind, record)
+}
+
+func (s *FileStore) writeLocked(ctx context.Context, op, kind string, record any) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return domain.Internal("failed to serialize "+kind, err)
+	}
+	return s.writeEntriesLocked(ctx, []journalEntry{{Op: op, Kind: kind, Record: raw}})
+}
+
+// writeRecords journals records of one kind with a single sync.
+func writeRecords[T any](ctx context.Context, s *FileStore, op, kind string, records []T) error {
+	entries, err := recordEntries(op, kind, records)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeEntriesLocked(ctx, entries)
+}
+
+func recordEntries[T any](op, kind string, records []T) ([]journalEntry, error) {
+	entries := make([]journalEntry, 0, len(records))
+	for _, record := range records {
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return nil, domain.Internal("failed to serialize "+kind, err)
+		}
+		entries = append(entries, journalEntry{Op: op, Kind: kind, Record: raw})
+	}
+	return entries, nil
+}
+
+// writeEntriesLocked is where a write commits, so a caller that has already
+// given up gets its context error instead of a write it will never see.
+func (s *FileStore) writeEntriesLocked(ctx context.Context, entries []journalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !s.ephemeral {
+		if err := s.appendJournalLocked(entries); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := s.applyLocked(entry); err != nil {
+			return err
+		}
+	}
+	if s.journalEntries >= journalCompactEntries {
+		// The record is already durable in the journal; a failed compaction
+		// is retried on the next write.
+		_ = s.compactLocked()
+	}
+	return nil
+}
+
+func (s *FileStore) appendJournalLocked(entries []journalEntry) error {
+	if s.journal == nil {
+		if err := s.compactLocked(); err != nil {
+			return err
+		}
+	}
+	var lines []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return domain.Internal("failed to serialize journal entry", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	offset, err := s.journal.Seek(0, io.SeekEnd)
+	if err != nil {
+		s.closeJournalLocked()
+		return domain.Internal("failed to append to journal", err)
+	}
+	if _, err := s.journal.Write(lines); err != nil {
+		s.rewindJournalLocked(offset)
+		return domain.Internal("failed to append to journal", err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		// The caller is told the write failed and it is not applied in
+		// memory, so it must not come back on the next start either.
+		s.rewindJournalLocked(offset)
+		return domain.Internal("failed to sync journal", err)
+	}
+	s.journalEntries += len(entries)
+	return nil
+}
+
+// rewindJournalLocked cuts a failed append off the journal at offset, so a
+// torn line is not followed by more. If the journal cannot be cut it starts
+// over from a snapshot, and if that fails too it drops the handle, so the
+// next write has to compact first; a torn final line is skipped on replay.
+// Only then can whole lines of the failed append survive to be replayed.
+func (s *FileStore) rewindJournalLocked(offset int64) {
+	if err := s.journal.Truncate(offset); err == nil {
+		if err := s.journal.Sync(); err == nil {
+			return
+		}
+	}
+	if err := s.compactLocked(); err == nil {
+		return
+	}
+	s.closeJournalLocked()
+}
+
+func (s *FileStore) closeJournalLocked() {
+	if s.journal != nil {
+		_ = s.journal.Close()
+		s.journal = nil
+	}
+}
+
+// applyLocked applies a journal entry to s.state and keeps s.index current.
+func (s *FileStore) applyLocked(entry journalEntry) error {
+	state := &s.state
+	var err error
+	switch entry.Kind {
+	case "note":
+		err = appendRecord(entry.Record, &state.Notes)
+	case "changelog":
+		err = appendRecord(entry.Record, &state.Changelog)
+	case "benchmark":
+		err = appendRecord(entry.Record, &state.Benchmarks)
+	case "artifact":
+		err = appendRecord(entry.Record, &state.Artifacts)
+	case "prompt_release":
+		err = appendRecord(entry.Record, &state.Releases)
+	case "policy_audit":
+		err = appendRecord(entry.Record, &state.Audit)
+	case "attempt":
+		if err = appendRecord(entry.Record, &state.Attempts); err == nil {
+			last := len(state.Attempts) - 1
+			if state.Attempts[last].Project == "" {
+				state.Attempts[last].Project = domain.DefaultProject
+			}
+			runID := state.Attempts[last].RunID
+			s.index.attemptsByRun[runID] = append(s.index.attemptsByRun[runID], last)
+			s.sketches.addAttempt(state.Attempts[last])
+			s.rollups.addAttempt(state.Attempts[last])
+		}
+	case "run_event":
+		if err = appendRecord(entry.Record, &state.RunEvents); err == nil {
+			last := len(state.RunEvents) - 1
+			runID := state.RunEvents[last].RunID
+			s.index.eventsByRun[runID] = append(s.index.eventsByRun[runID], last)
+		}
+	case "idempotency":
+		var record fileIdempotencyRecord
+		if err = json.Unmarshal(entry.Record, &record); err == nil {
+			s.idempotency[fileIdempotencyRecordKey(record.Method, record.Key)] = record
+		}
+	case "run":
+		var run domain.AgentRun
+		if err = json.Unmarshal(entry.Record, &run); err != nil {
+			break
+		}
+		if run.Project == "" {
+			run.Project = domain.DefaultProject
+		}
+		if i, ok := s.index.runs[run.ID]; ok && entry.Op == journalUpsert {
+			state.Runs[i] = run
+			break
+		}
+		state.Runs = append(state.Runs, run)
+		s.index.runs[run.ID] = len(state.Runs) - 1
+	default:
+		return domain.Internal("unknown journal record kind "+entry.Kind, nil)
+	}
+	if err != nil {
+		return domain.Internal("failed to decode journal "+entry.Kind, err)
+	}
+	return nil
+}
+
+func appendRecord[T any](raw json.RawMessage, items *[]T) error {
+	var item T
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return err
+	}
+	*items = append(*items, item)
+	return nil
+}
+
+// replayJournalLocked applies the journal continuing the snapshot with
+// journalID. A final line without a newline is a write cut short by a crash
+// and is dropped; the caller compacts afterwards.
+func (s *FileStore) replayJournalLocked(journalID string) error {
+	file, err := os.Open(s.journalPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return domain.Internal("failed to open journal", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first := true
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return domain.Internal("failed to read journal", readErr)
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		line = bytes.TrimSpace(line)
+		if first {
+			first = false
+			var header journalHeader
+			if err := json.Unmarshal(line, &header); err != nil || journalID == "" || header.JournalID != journalID {
+				return nil
+			}
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return domain.Internal("failed to parse journal", err)
+		}
+		if err := s.applyLocked(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// compactLocked writes the state as a new snapshot and starts an empty
+// journal for it. The snapshot is renamed into place first, so a crash in
+// between leaves a journal whose ID no longer matches and is skipped.
+func (s *FileStore) compactLocked() error {
+	if s.ephemeral {
+		return nil
+	}
+	journalID, err := newJournalID()
+	if err != nil {
+		return domain.Internal("failed to create journal id", err)
+	}
+	snapshot := fileSnapshot{
+		State:       s.state,
+		Idempotency: s.persistedIdempotencyLocked(time.Now()),
+		JournalID:   journalID,
+		Sketches:    s.persistedSketchesLocked(),
+		Rollups:     s.rollups.sorted(),
+	}
+	serialized, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return domain.Internal("failed to serialize state", err)
+	}
+	if err := writeFileAtomic(s.path, append(serialized, '\n')); err != nil {
+		return domain.Internal("failed to persist state file", err)
+	}
+
+	header, _ := json.Marshal(journalHeader{JournalID: journalID})
+	if err := writeFileAtomic(s.journalPath(), append(header, '\n')); err != nil {
+		return domain.Internal("failed to start journal", err)
+	}
+	s.closeJournalLocked()
+	journal, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return domain.Internal("failed to open journal", err)
+	}
+	s.journal = journal
+	s.journalEntries = 0
+	return nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tempPath := path + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func newJournalID() (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
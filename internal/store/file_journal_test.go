@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s := NewFileStore(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func insertTestNotes(t *testing.T, s *FileStore, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := s.InsertNote(context.Background(), domain.Note{ID: id, Title: id, Tags: []string{}, CreatedAt: "2026-01-01T00:00:00Z"}); err != nil {
+			t.Fatalf("insert note %s: %v", id, err)
+		}
+	}
+}
+
+func noteIDs(t *testing.T, s *FileStore) []string {
+	t.Helper()
+	notes, err := s.ListNotes(context.Background())
+	if err != nil {
+		t.Fatalf("list notes: %v", err)
+	}
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+// journalLines returns the journal's lines and fails if any of them, other
+// than the header, is not a whole entry.
+func journalLines(t *testing.T, path string) []string {
+	t.Helper()
+	raw, err := os.ReadFile(path + ".journal")
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if len(raw) > 0 && raw[len(raw)-1] != '\n' {
+		t.Fatalf("journal ends in a torn line: %q", raw)
+	}
+	lines := []string{}
+	for i, line := range bytes.Split(bytes.TrimSuffix(raw, []byte("\n")), []byte("\n")) {
+		if i > 0 {
+			var entry journalEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				t.Fatalf("journal line %d is not an entry: %q", i, line)
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func TestFileJournalReplaysAndDropsTornFinalLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	s := NewFileStore(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	insertTestNotes(t, s, "n1", "n2")
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	journal, err := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if _, err := journal.WriteString(`{"op":"append","kind":"note","record":{"id":"n3"`); err != nil {
+		t.Fatalf("tear journal: %v", err)
+	}
+	_ = journal.Close()
+
+	reopened := openTestFileStore(t, path)
+	if got := fmt.Sprint(noteIDs(t, reopened)); got != "[n1 n2]" {
+		t.Fatalf("expected replay to keep n1 and n2 and drop the torn line, got %s", got)
+	}
+	insertTestNotes(t, reopened, "n4")
+	if lines := journalLines(t, path); len(lines) != 2 {
+		t.Fatalf("expected the compacted journal to hold its header and n4, got %q", lines)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := fmt.Sprint(noteIDs(t, openTestFileStore(t, path))); got != "[n1 n2 n4]" {
+		t.Fatalf("expected n1, n2 and n4 after a second reload, got %s", got)
+	}
+}
+
+func TestFileJournalFailedAppendLeavesNoRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	s := openTestFileStore(t, path)
+	insertTestNotes(t, s, "n1")
+
+	// A read-only handle fails both the write and the truncate, so the
+	// store has to fall back to compacting.
+	readOnly, err := os.Open(path + ".journal")
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	s.mu.Lock()
+	_ = s.journal.Close()
+	s.journal = readOnly
+	s.mu.Unlock()
+
+	if err := s.InsertNote(context.Background(), domain.Note{ID: "lost", Tags: []string{}}); err == nil {
+		t.Fatalf("expected the append to fail")
+	}
+	if got := fmt.Sprint(noteIDs(t, s)); got != "[n1]" {
+		t.Fatalf("expected the failed note not to be applied, got %s", got)
+	}
+	journalLines(t, path)
+	insertTestNotes(t, s, "n2")
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := fmt.Sprint(noteIDs(t, openTestFileStore(t, path))); got != "[n1 n2]" {
+		t.Fatalf("expected n1 and n2 after reload, got %s", got)
+	}
+}
+
+func TestFileJournalRewindCutsPartialAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	s := openTestFileStore(t, path)
+	insertTestNotes(t, s, "n1")
+	before := journalLines(t, path)
+
+	s.mu.Lock()
+	offset, err := s.journal.Seek(0, io.SeekEnd)
+	if err != nil {
+		s.mu.Unlock()
+		t.Fatalf("seek: %v", err)
+	}
+	if _, err := s.journal.WriteString(`{"op":"append","kind":"no`); err != nil {
+		s.mu.Unlock()
+		t.Fatalf("partial write: %v", err)
+	}
+	s.rewindJournalLocked(offset)
+	kept := s.journal != nil
+	s.mu.Unlock()
+
+	if !kept {
+		t.Fatalf("expected a successful truncate to keep the journal open")
+	}
+	if after := journalLines(t, path); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Fatalf("expected the journal back at %q, got %q", before, after)
+	}
+	insertTestNotes(t, s, "n2")
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := fmt.Sprint(noteIDs(t, openTestFileStore(t, path))); got != "[n1 n2]" {
+		t.Fatalf("expected n1 and n2 after reload, got %s", got)
+	}
+}
+
+func TestFileJournalCompactionStartsMatchingJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	s := openTestFileStore(t, path)
+	insertTestNotes(t, s, "n1")
+	s.mu.Lock()
+	err := s.compactLocked()
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	insertTestNotes(t, s, "n2")
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		t.Fatalf("parse snapshot: %v", err)
+	}
+	if len(snapshot.Notes) != 1 || snapshot.JournalID == "" {
+		t.Fatalf("expected the snapshot to hold n1 and a journal id, got %d notes and id %q", len(snapshot.Notes), snapshot.JournalID)
+	}
+	lines := journalLines(t, path)
+	var header journalHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil || header.JournalID != snapshot.JournalID {
+		t.Fatalf("expected the journal header to name snapshot %q, got %q", snapshot.JournalID, lines[0])
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected the new journal to hold only n2, got %q", lines)
+	}
+
+	// A journal left behind by an earlier snapshot is not replayed again.
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	stale := append([]byte(`{"journal_id":"stale"}`+"\n"), []byte(lines[1]+"\n")...)
+	if err := os.WriteFile(path+".journal", stale, 0o600); err != nil {
+		t.Fatalf("write stale journal: %v", err)
+	}
+	if got := fmt.Sprint(noteIDs(t, openTestFileStore(t, path))); got != "[n1]" {
+		t.Fatalf("expected a mismatched journal to be skipped, got %s", got)
+	}
+}
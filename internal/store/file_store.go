@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bcrosbie/modeloman/internal/domain"
 )
@@ -16,14 +18,67 @@ type FileStore struct {
 	path        string
 	mu          sync.RWMutex
 	state       domain.State
-	idempotency map[string]IdempotencyRecord
+	idempotency map[string]fileIdempotencyRecord
+	index       fileIndex
+	// journal is the append handle for inserts since the last snapshot; see
+	// file_journal.go.
+	journal        *os.File
+	journalEntries int
+	// ephemeral keeps state in memory only; see NewMemoryStore.
+	ephemeral bool
+	// archived is the cold tier of an ephemeral store; file-backed stores
+	// keep it in archivePath. See file_archive.go.
+	archived fileArchive
+	// sketches counts distinct attempt values per day; see hll.go. They are
+	// kept in the snapshot, so archived and pruned attempts stay counted
+	// until retention drops their day.
+	sketches telemetrySketches
+	// rollups are the per-day attempt totals behind ListDailyRollups; see
+	// rollup.go. Like the sketches they are kept in the snapshot.
+	rollups dailyRollups
+}
+
+// fileIndex maps run IDs to positions in FileStore.state so per-run reads
+// copy only the matching records instead of scanning and deep-copying the
+// whole state. It is rebuilt whenever state is replaced, under the write lock.
+type fileIndex struct {
+	runs          map[string]int
+	attemptsByRun map[string][]int
+	eventsByRun   map[string][]int
+}
+
+func buildIndex(state *domain.State) fileIndex {
+	index := fileIndex{
+		runs:          make(map[string]int, len(state.Runs)),
+		attemptsByRun: map[string][]int{},
+		eventsByRun:   map[string][]int{},
+	}
+	for i, run := range state.Runs {
+		index.runs[run.ID] = i
+	}
+	for i, attempt := range state.Attempts {
+		index.attemptsByRun[attempt.RunID] = append(index.attemptsByRun[attempt.RunID], i)
+	}
+	for i, event := range state.RunEvents {
+		index.eventsByRun[event.RunID] = append(index.eventsByRun[event.RunID], i)
+	}
+	return index
+}
+
+// setStateLocked installs state and rebuilds the index; s.mu must be held
+// for writing.
+func (s *FileStore) setStateLocked(state domain.State) {
+	s.state = withDefaults(state)
+	s.index = buildIndex(&s.state)
 }
 
 func NewFileStore(path string) *FileStore {
 	return &FileStore{
 		path:        path,
 		state:       domain.EmptyState(),
-		idempotency: map[string]IdempotencyRecord{},
+		idempotency: map[string]fileIdempotencyRecord{},
+		sketches:    telemetrySketches{},
+		rollups:     dailyRollups{},
 	}
 }
 
@@ -31,6 +86,10 @@ func (s *FileStore) Load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.ephemeral {
+		s.setStateLocked(s.state)
+		return nil
+	}
 	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
 		return domain.Internal("failed to create data directory", err)
 	}
@@ -38,26 +97,69 @@ func (s *FileStore) Load() error {
 	raw, err := os.ReadFile(s.path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			s.state = domain.EmptyState()
-			return s.persistLocked()
+			s.setStateLocked(domain.EmptyState())
+			return s.compactLocked()
 		}
 		return domain.Internal("failed to read data file", err)
 	}
 
-	var parsed domain.State
+	var parsed fileSnapshot
 	if err := json.Unmarshal(raw, &parsed); err != nil {
 		return domain.Internal("failed to parse data file", err)
 	}
 
-	s.state = withDefaults(parsed)
-	if s.idempotency == nil {
-		s.idempotency = map[string]IdempotencyRecord{}
+	s.setStateLocked(parsed.State)
+	s.sketches = telemetrySketches{}
+	if parsed.Sketches == nil {
+		// Snapshots written before sketches existed count what they hold.
+		for _, attempt := range s.state.Attempts {
+			s.sketches.addAttempt(attempt)
+		}
 	}
-	return nil
+	for _, item := range parsed.Sketches {
+		sketch, err := item.sketch()
+		if err != nil {
+			return domain.Internal("failed to parse data file", err)
+		}
+		s.sketches[sketchKey{day: item.Day, dimension: item.Dimension}] = sketch
+	}
+	s.rollups = dailyRollups{}
+	if parsed.Rollups == nil {
+		// Snapshots written before rollups existed count what they hold,
+		// archive included.
+		archive, err := s.readArchiveLocked()
+		if err != nil {
+			return err
+		}
+		for _, attempt := range append(archive.Attempts, s.state.Attempts...) {
+			s.rollups.addAttempt(attempt)
+		}
+	}
+	for _, item := range parsed.Rollups {
+		rollup := item
+		s.rollups[rollupKeyOf(rollup)] = &rollup
+	}
+	s.idempotency = make(map[string]fileIdempotencyRecord, len(parsed.Idempotency))
+	for _, record := range parsed.Idempotency {
+		s.idempotency[fileIdempotencyRecordKey(record.Method, record.Key)] = record
+	}
+	if err := s.replayJournalLocked(parsed.JournalID); err != nil {
+		return err
+	}
+	// Fold the replayed journal into a fresh snapshot, which also drops a
+	// torn final line.
+	return s.compactLocked()
 }
 
 func (s *FileStore) Close() error {
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.Close()
+	s.journal = nil
+	return err
 }
 
 func (s *FileStore) Snapshot() domain.State {
@@ -66,7 +168,7 @@ func (s *FileStore) Snapshot() domain.State {
 	return cloneState(s.state)
 }
 
-func (s *FileStore) Mutate(mutate func(*domain.State) error) error {
+func (s *FileStore) Mutate(ctx context.Context, mutate func(*domain.State) error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -74,25 +176,28 @@ func (s *FileStore) Mutate(mutate func(*domain.State) error) error {
 	if err := mutate(&next); err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	s.state = withDefaults(next)
-	return s.persistLocked()
+	s.countNewAttemptsLocked(next.Attempts)
+	s.setStateLocked(next)
+	return s.compactLocked()
 }
 
-func (s *FileStore) persistLocked() error {
-	serialized, err := json.MarshalIndent(s.state, "", "  ")
-	if err != nil {
-		return domain.Internal("failed to serialize state", err)
+// countNewAttemptsLocked adds the attempts a Mutate inserted to the
+// sketches and rollups; s.mu must be held for writing.
+func (s *FileStore) countNewAttemptsLocked(next []domain.PromptAttempt) {
+	known := make(map[string]struct{}, len(s.state.Attempts))
+	for _, attempt := range s.state.Attempts {
+		known[attempt.ID] = struct{}{}
 	}
-
-	tempPath := s.path + ".tmp"
-	if err := os.WriteFile(tempPath, append(serialized, '\n'), 0o600); err != nil {
-		return domain.Internal("failed to write temporary state file", err)
-	}
-	if err := os.Rename(tempPath, s.path); err != nil {
-		return domain.Internal("failed to atomically persist state file", err)
+	for _, attempt := range next {
+		if _, ok := known[attempt.ID]; !ok {
+			s.sketches.addAttempt(attempt)
+			s.rollups.addAttempt(attempt)
+		}
 	}
-	return nil
 }
 
 func withDefaults(state domain.State) domain.State {
@@ -117,12 +222,43 @@ func withDefaults(state domain.State) domain.State {
 	if state.RunEvents == nil {
 		state.RunEvents = []domain.RunEvent{}
 	}
+	if state.Artifacts == nil {
+		state.Artifacts = []domain.Artifact{}
+	}
+	if state.Releases == nil {
+		state.Releases = []domain.PromptRelease{}
+	}
+	if state.Audit == nil {
+		state.Audit = []domain.PolicyAuditEntry{}
+	}
 	if state.Policy.UpdatedAt == "" && !state.Policy.KillSwitch {
 		state.Policy = domain.DefaultPolicy()
 	}
+	if state.Policy.MaintenanceWindows == nil {
+		state.Policy.MaintenanceWindows = []domain.MaintenanceWindow{}
+	}
+	if state.Policy.AlertMaintenanceWindows == nil {
+		state.Policy.AlertMaintenanceWindows = []domain.MaintenanceWindow{}
+	}
 	if state.PolicyCaps == nil {
 		state.PolicyCaps = []domain.PolicyCap{}
 	}
+	// Records written before projects existed belong to the default project.
+	for i := range state.Tasks {
+		if state.Tasks[i].Project == "" {
+			state.Tasks[i].Project = domain.DefaultProject
+		}
+	}
+	for i := range state.Runs {
+		if state.Runs[i].Project == "" {
+			state.Runs[i].Project = domain.DefaultProject
+		}
+	}
+	for i := range state.Attempts {
+		if state.Attempts[i].Project == "" {
+			state.Attempts[i].Project = domain.DefaultProject
+		}
+	}
 	return state
 }
 
@@ -133,27 +269,49 @@ func cloneState(in domain.State) domain.State {
 	return withDefaults(out)
 }
 
-func (s *FileStore) ExportState() (domain.State, error) {
+// cloneRecord deep-copies one record the way cloneState copies the state, so
+// callers never share maps or slices with s.state.
+func cloneRecord[T any](in T) T {
+	raw, _ := json.Marshal(in)
+	var out T
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+// indexed copies the records at positions, in order, under the read lock.
+func indexed[T any](s *FileStore, items func(*domain.State) []T, positions func(*fileIndex) []int) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := items(&s.state)
+	at := positions(&s.index)
+	out := make([]T, 0, len(at))
+	for _, i := range at {
+		out = append(out, cloneRecord(all[i]))
+	}
+	return out
+}
+
+func (s *FileStore) ExportState(ctx context.Context) (domain.State, error) {
 	return s.Snapshot(), nil
 }
 
-func (s *FileStore) GetPolicy() (domain.OrchestrationPolicy, error) {
+func (s *FileStore) GetPolicy(ctx context.Context) (domain.OrchestrationPolicy, error) {
 	return s.Snapshot().Policy, nil
 }
 
-func (s *FileStore) SetPolicy(policy domain.OrchestrationPolicy) error {
-	return s.Mutate(func(state *domain.State) error {
+func (s *FileStore) SetPolicy(ctx context.Context, policy domain.OrchestrationPolicy) error {
+	return s.Mutate(ctx, func(state *domain.State) error {
 		state.Policy = policy
 		return nil
 	})
 }
 
-func (s *FileStore) ListPolicyCaps() ([]domain.PolicyCap, error) {
+func (s *FileStore) ListPolicyCaps(ctx context.Context) ([]domain.PolicyCap, error) {
 	return s.Snapshot().PolicyCaps, nil
 }
 
-func (s *FileStore) UpsertPolicyCap(cap domain.PolicyCap) error {
-	return s.Mutate(func(state *domain.State) error {
+func (s *FileStore) UpsertPolicyCap(ctx context.Context, cap domain.PolicyCap) error {
+	return s.Mutate(ctx, func(state *domain.State) error {
 		for i := range state.PolicyCaps {
 			if state.PolicyCaps[i].ID != cap.ID {
 				continue
@@ -166,9 +324,9 @@ func (s *FileStore) UpsertPolicyCap(cap domain.PolicyCap) error {
 	})
 }
 
-func (s *FileStore) DeletePolicyCap(id string) (bool, error) {
+func (s *FileStore) DeletePolicyCap(ctx context.Context, id string) (bool, error) {
 	deleted := false
-	err := s.Mutate(func(state *domain.State) error {
+	err := s.Mutate(ctx, func(state *domain.State) error {
 		for index, item := range state.PolicyCaps {
 			if item.ID != id {
 				continue
@@ -185,12 +343,60 @@ func (s *FileStore) DeletePolicyCap(id string) (bool, error) {
 	return deleted, nil
 }
 
-func (s *FileStore) ListTasks() ([]domain.Task, error) {
+func (s *FileStore) ListTasks(ctx context.Context) ([]domain.Task, error) {
 	return s.Snapshot().Tasks, nil
 }
 
-func (s *FileStore) UpsertTask(task domain.Task) error {
-	return s.Mutate(func(state *domain.State) error {
+func (s *FileStore) CountTasks(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.Tasks) }), nil
+}
+
+func (s *FileStore) ListTasksFiltered(ctx context.Context, filter domain.TaskFilter) ([]domain.Task, error) {
+	items := s.Snapshot().Tasks
+	query := strings.ToLower(filter.Query)
+	out := make([]domain.Task, 0, len(items))
+	for _, item := range items {
+		if filter.Project != "" && item.Project != filter.Project {
+			continue
+		}
+		if filter.ExternalID != "" && item.ExternalID != filter.ExternalID {
+			continue
+		}
+		if filter.Status != "" && item.Status != filter.Status {
+			continue
+		}
+		if !filter.IncludeArchived && item.Status == domain.TaskStatusArchived {
+			continue
+		}
+		if !containsAllTags(item.Tags, filter.Tags) {
+			continue
+		}
+		if query != "" &&
+			!strings.Contains(strings.ToLower(item.Title), query) &&
+			!strings.Contains(strings.ToLower(item.Details), query) {
+			continue
+		}
+		out = append(out, item)
+	}
+	slices.SortFunc(out, func(a, b domain.Task) int {
+		if a.UpdatedAt == b.UpdatedAt {
+			return strings.Compare(b.ID, a.ID)
+		}
+		return strings.Compare(b.UpdatedAt, a.UpdatedAt)
+	})
+	if filter.Limit > 0 && int64(len(out)) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
+
+func (s *FileStore) UpsertTask(ctx context.Context, task domain.Task) error {
+	return s.Mutate(ctx, func(state *domain.State) error {
+		if task.ExternalID != "" && slices.ContainsFunc(state.Tasks, func(item domain.Task) bool {
+			return item.ID != task.ID && item.Project == task.Project && item.ExternalID == task.ExternalID
+		}) {
+			return domain.Conflict("a task with this external_id already exists in the project")
+		}
 		for i := range state.Tasks {
 			if state.Tasks[i].ID == task.ID {
 				state.Tasks[i] = task
@@ -202,9 +408,9 @@ func (s *FileStore) UpsertTask(task domain.Task) error {
 	})
 }
 
-func (s *FileStore) DeleteTask(id string) (bool, error) {
+func (s *FileStore) DeleteTask(ctx context.Context, id string) (bool, error) {
 	deleted := false
-	err := s.Mutate(func(state *domain.State) error {
+	err := s.Mutate(ctx, func(state *domain.State) error {
 		for index, task := range state.Tasks {
 			if task.ID != id {
 				continue
@@ -221,48 +427,67 @@ func (s *FileStore) DeleteTask(id string) (bool, error) {
 	return deleted, nil
 }
 
-func (s *FileStore) ListNotes() ([]domain.Note, error) {
+func (s *FileStore) ListNotes(ctx context.Context) ([]domain.Note, error) {
 	return s.Snapshot().Notes, nil
 }
 
-func (s *FileStore) InsertNote(note domain.Note) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.Notes = append(state.Notes, note)
-		return nil
-	})
+func (s *FileStore) CountNotes(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.Notes) }), nil
+}
+
+func (s *FileStore) InsertNote(ctx context.Context, note domain.Note) error {
+	return s.write(ctx, journalAppend, "note", note)
 }
 
-func (s *FileStore) ListChangelog() ([]domain.ChangelogEntry, error) {
+func (s *FileStore) ListChangelog(ctx context.Context) ([]domain.ChangelogEntry, error) {
 	return s.Snapshot().Changelog, nil
 }
 
-func (s *FileStore) InsertChangelog(entry domain.ChangelogEntry) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.Changelog = append(state.Changelog, entry)
-		return nil
-	})
+func (s *FileStore) CountChangelog(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.Changelog) }), nil
+}
+
+func (s *FileStore) InsertChangelog(ctx context.Context, entry domain.ChangelogEntry) error {
+	return s.write(ctx, journalAppend, "changelog", entry)
 }
 
-func (s *FileStore) ListBenchmarks() ([]domain.Benchmark, error) {
+func (s *FileStore) ListBenchmarks(ctx context.Context) ([]domain.Benchmark, error) {
 	return s.Snapshot().Benchmarks, nil
 }
 
-func (s *FileStore) InsertBenchmark(benchmark domain.Benchmark) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.Benchmarks = append(state.Benchmarks, benchmark)
-		return nil
-	})
+func (s *FileStore) InsertBenchmark(ctx context.Context, benchmark domain.Benchmark) error {
+	return s.write(ctx, journalAppend, "benchmark", benchmark)
 }
 
-func (s *FileStore) ListRuns() ([]domain.AgentRun, error) {
+func (s *FileStore) ListRuns(ctx context.Context) ([]domain.AgentRun, error) {
 	return s.Snapshot().Runs, nil
 }
 
-func (s *FileStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.AgentRun, error) {
-	items := s.Snapshot().Runs
+func (s *FileStore) CountRuns(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.Runs) }), nil
+}
+
+func (s *FileStore) ListRunsFiltered(ctx context.Context, filter domain.RunFilter) ([]domain.AgentRun, error) {
+	var items []domain.AgentRun
+	if filter.RunID != "" {
+		items = indexed(s, func(state *domain.State) []domain.AgentRun { return state.Runs }, func(index *fileIndex) []int {
+			if i, ok := index.runs[filter.RunID]; ok {
+				return []int{i}
+			}
+			return nil
+		})
+	} else {
+		items = s.Snapshot().Runs
+	}
+	started := newTimeRange(filter.StartedAfter, filter.StartedBefore)
 	out := make([]domain.AgentRun, 0, len(items))
-	for _, item := range items {
-		if filter.RunID != "" && item.ID != filter.RunID {
+	// Newest first, so Limit keeps the latest matches as Postgres does.
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if filter.Project != "" && item.Project != filter.Project {
+			continue
+		}
+		if filter.ExternalID != "" && item.ExternalID != filter.ExternalID {
 			continue
 		}
 		if filter.TaskID != "" && item.TaskID != filter.TaskID {
@@ -280,10 +505,13 @@ func (s *FileStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.AgentRun
 		if filter.PromptVersion != "" && item.PromptVersion != filter.PromptVersion {
 			continue
 		}
-		if filter.StartedAfter != "" && item.StartedAt <= filter.StartedAfter {
+		if !started.contains(item.StartedAt) {
+			continue
+		}
+		if !containsAllLabels(item.Metadata, filter.Labels) {
 			continue
 		}
-		if filter.StartedBefore != "" && item.StartedAt >= filter.StartedBefore {
+		if filter.Starred && !item.Starred {
 			continue
 		}
 		out = append(out, item)
@@ -294,38 +522,103 @@ func (s *FileStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.AgentRun
 	return out, nil
 }
 
-func (s *FileStore) InsertRun(run domain.AgentRun) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.Runs = append(state.Runs, run)
-		return nil
-	})
+func (s *FileStore) InsertRun(ctx context.Context, run domain.AgentRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if run.ExternalID != "" && slices.ContainsFunc(s.state.Runs, func(item domain.AgentRun) bool {
+		return item.Project == run.Project && item.ExternalID == run.ExternalID
+	}) {
+		return domain.Conflict("a run with this external_id already exists in the project")
+	}
+	return s.writeLocked(ctx, journalAppend, "run", run)
 }
 
-func (s *FileStore) UpdateRun(run domain.AgentRun) error {
-	return s.Mutate(func(state *domain.State) error {
-		for i := range state.Runs {
-			if state.Runs[i].ID != run.ID {
-				continue
-			}
-			state.Runs[i] = run
-			return nil
+func (s *FileStore) UpdateRun(ctx context.Context, run domain.AgentRun) error {
+	return s.write(ctx, journalUpsert, "run", run)
+}
+
+func (s *FileStore) FinalizeRun(ctx context.Context, run domain.AgentRun) (domain.AgentRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.index.runs[run.ID]
+	if !ok {
+		return domain.AgentRun{}, domain.NotFound("run not found")
+	}
+	next := cloneRecord(s.state.Runs[i])
+	next.Status = run.Status
+	next.LastError = run.LastError
+	next.DurationMS = run.DurationMS
+	next.FinishedAt = run.FinishedAt
+	next.TotalAttempts, next.SuccessAttempts, next.FailedAttempts = 0, 0, 0
+	next.TotalTokensIn, next.TotalTokensOut, next.TotalCostUSD = 0, 0, 0
+	for _, position := range s.index.attemptsByRun[run.ID] {
+		attempt := s.state.Attempts[position]
+		next.TotalAttempts++
+		next.TotalTokensIn += attempt.TokensIn
+		next.TotalTokensOut += attempt.TokensOut
+		next.TotalCostUSD += attempt.CostUSD
+		if attempt.Outcome == "success" {
+			next.SuccessAttempts++
+		} else {
+			next.FailedAttempts++
 		}
-		state.Runs = append(state.Runs, run)
-		return nil
-	})
+	}
+	if err := s.writeLocked(ctx, journalUpsert, "run", next); err != nil {
+		return domain.AgentRun{}, err
+	}
+	return next, nil
+}
+
+func (s *FileStore) SetRunStarred(ctx context.Context, run domain.AgentRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.index.runs[run.ID]
+	if !ok {
+		return domain.NotFound("run not found")
+	}
+	next := cloneRecord(s.state.Runs[i])
+	next.Starred = run.Starred
+	next.StarredAt = run.StarredAt
+	return s.writeLocked(ctx, journalUpsert, "run", next)
+}
+
+func (s *FileStore) ListPromptAttempts(ctx context.Context, runID string) ([]domain.PromptAttempt, error) {
+	return s.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{RunID: runID})
 }
 
-func (s *FileStore) ListPromptAttempts(runID string) ([]domain.PromptAttempt, error) {
-	return s.ListPromptAttemptsFiltered(domain.AttemptFilter{RunID: runID})
+func (s *FileStore) CountPromptAttempts(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.Attempts) }), nil
 }
 
-func (s *FileStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
-	items := s.Snapshot().Attempts
+func (s *FileStore) ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	var items []domain.PromptAttempt
+	if filter.RunID != "" {
+		items = indexed(s, func(state *domain.State) []domain.PromptAttempt { return state.Attempts }, func(index *fileIndex) []int {
+			return index.attemptsByRun[filter.RunID]
+		})
+	} else {
+		items = s.Snapshot().Attempts
+	}
+	if filter.IncludeArchived {
+		archive, err := s.readArchive()
+		if err != nil {
+			return nil, err
+		}
+		items = withArchived(archive.Attempts, items, func(item domain.PromptAttempt) string { return item.ID })
+	}
+	created := newTimeRange(filter.CreatedAfter, filter.CreatedBefore)
 	out := make([]domain.PromptAttempt, 0, len(items))
-	for _, item := range items {
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
 		if filter.RunID != "" && item.RunID != filter.RunID {
 			continue
 		}
+		if filter.Project != "" && item.Project != filter.Project {
+			continue
+		}
 		if filter.Workflow != "" && item.Workflow != filter.Workflow {
 			continue
 		}
@@ -341,10 +634,7 @@ func (s *FileStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter) ([]d
 		if filter.PromptVersion != "" && item.PromptVersion != filter.PromptVersion {
 			continue
 		}
-		if filter.CreatedAfter != "" && item.CreatedAt <= filter.CreatedAfter {
-			continue
-		}
-		if filter.CreatedBefore != "" && item.CreatedAt >= filter.CreatedBefore {
+		if !created.contains(item.CreatedAt) {
 			continue
 		}
 		out = append(out, item)
@@ -355,34 +645,391 @@ func (s *FileStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter) ([]d
 	return out, nil
 }
 
-func (s *FileStore) InsertPromptAttempt(attempt domain.PromptAttempt) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.Attempts = append(state.Attempts, attempt)
-		return nil
+func (s *FileStore) LeaderboardAggregate(ctx context.Context, filter domain.AttemptFilter) ([]domain.LeaderboardEntry, error) {
+	filter.Limit = 0
+	attempts, err := s.ListPromptAttemptsFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	type groupKey struct{ workflow, promptVersion, model string }
+	type aggregate struct {
+		attempts     int64
+		successes    int64
+		totalCost    float64
+		totalLatency int64
+	}
+	grouped := map[groupKey]*aggregate{}
+	order := []groupKey{}
+	for _, item := range attempts {
+		key := groupKey{item.Workflow, item.PromptVersion, item.Model}
+		entry, ok := grouped[key]
+		if !ok {
+			entry = &aggregate{}
+			grouped[key] = entry
+			order = append(order, key)
+		}
+		entry.attempts++
+		entry.totalCost += item.CostUSD
+		entry.totalLatency += item.LatencyMS
+		if item.Outcome == "success" {
+			entry.successes++
+		}
+	}
+
+	out := make([]domain.LeaderboardEntry, 0, len(order))
+	for _, key := range order {
+		item := grouped[key]
+		out = append(out, domain.LeaderboardEntry{
+			Workflow:         key.workflow,
+			PromptVersion:    key.promptVersion,
+			Model:            key.model,
+			Attempts:         item.attempts,
+			SuccessAttempts:  item.successes,
+			FailedAttempts:   item.attempts - item.successes,
+			SuccessRate:      float64(item.successes) / float64(item.attempts),
+			AverageCostUSD:   item.totalCost / float64(item.attempts),
+			AverageLatencyMS: float64(item.totalLatency) / float64(item.attempts),
+		})
+	}
+	return out, nil
+}
+
+func (s *FileStore) AttemptTimeSeries(ctx context.Context, filter domain.AttemptFilter, bucket time.Duration) ([]domain.AttemptBucket, error) {
+	if bucket < time.Second {
+		return nil, domain.InvalidArgument("bucket must be at least one second")
+	}
+	filter.Limit = 0
+	attempts, err := s.ListPromptAttemptsFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	width := int64(bucket / time.Second)
+	type groupKey struct {
+		start           int64
+		workflow, model string
+	}
+	grouped := map[groupKey]*domain.AttemptBucket{}
+	order := []groupKey{}
+	for _, item := range attempts {
+		created, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+		if err != nil {
+			continue
+		}
+		// Truncate would count from year 1, not the epoch the SQL uses.
+		unix := created.Unix()
+		start := time.Unix(unix-((unix%width)+width)%width, 0)
+		key := groupKey{start.Unix(), item.Workflow, item.Model}
+		entry, ok := grouped[key]
+		if !ok {
+			entry = &domain.AttemptBucket{Start: start.UTC().Format(time.RFC3339), Workflow: item.Workflow, Model: item.Model}
+			grouped[key] = entry
+			order = append(order, key)
+		}
+		entry.Attempts++
+		entry.CostUSD += item.CostUSD
+		if item.Outcome == "success" {
+			entry.SuccessAttempts++
+		}
+	}
+
+	out := make([]domain.AttemptBucket, 0, len(order))
+	for _, key := range order {
+		out = append(out, *grouped[key])
+	}
+	return out, nil
+}
+
+// InsertPromptAttempt checks the run under the write lock, so an attempt
+// racing FinalizeRun is either counted or rejected.
+func (s *FileStore) InsertPromptAttempt(ctx context.Context, attempt domain.PromptAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.runAcceptsAttemptsLocked(attempt.RunID); err != nil {
+		return err
+	}
+	return s.writeLocked(ctx, journalAppend, "attempt", attempt)
+}
+
+func (s *FileStore) InsertPromptAttempts(ctx context.Context, attempts []domain.PromptAttempt) error {
+	entries, err := recordEntries(journalAppend, "attempt", attempts)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attempt := range attempts {
+		if err := s.runAcceptsAttemptsLocked(attempt.RunID); err != nil {
+			return err
+		}
+	}
+	return s.writeEntriesLocked(ctx, entries)
+}
+
+func (s *FileStore) runAcceptsAttemptsLocked(runID string) error {
+	i, ok := s.index.runs[runID]
+	if !ok {
+		return domain.NotFound("run not found")
+	}
+	if s.state.Runs[i].Status != "running" {
+		return domain.FailedPrecondition("run is not in running state")
+	}
+	return nil
+}
+
+func (s *FileStore) SummarizeTelemetry(ctx context.Context) (domain.TelemetrySummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := domain.TelemetrySummary{}
+	summary.Counts.Runs = int64(len(s.state.Runs))
+	summary.Counts.Events = int64(len(s.state.RunEvents))
+	for _, run := range s.state.Runs {
+		countRunStatus(&summary, run.Status, 1)
+	}
+	for _, attempt := range s.state.Attempts {
+		summary.Counts.Attempts++
+		summary.Totals.TokensIn += attempt.TokensIn
+		summary.Totals.TokensOut += attempt.TokensOut
+		summary.Totals.CostUSD += attempt.CostUSD
+		summary.Totals.LatencyMS += attempt.LatencyMS
+		if attempt.Outcome == "success" {
+			summary.Counts.SuccessAttempts++
+		} else {
+			summary.Counts.FailedAttempts++
+		}
+		if attempt.AttemptNumber > 1 {
+			summary.Counts.Retries++
+		}
+	}
+	s.sketches.summarize(&summary)
+	return summary, nil
+}
+
+func (s *FileStore) telemetrySketches(ctx context.Context) (telemetrySketches, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(telemetrySketches, len(s.sketches))
+	out.merge(s.sketches)
+	return out, nil
+}
+
+func (s *FileStore) ListDailyRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.DailyRollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rollups.list(filter), nil
+}
+
+func (s *FileStore) RebuildDailyRollups(ctx context.Context, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	archive, err := s.readArchiveLocked()
+	if err != nil {
+		return err
+	}
+	start := day.UTC().Truncate(24 * time.Hour)
+	created := timeRange{after: start, before: start.Add(24*time.Hour - time.Nanosecond)}
+	attempts := []domain.PromptAttempt{}
+	for _, attempt := range withArchived(archive.Attempts, s.state.Attempts, func(item domain.PromptAttempt) string { return item.ID }) {
+		if created.contains(attempt.CreatedAt) {
+			attempts = append(attempts, attempt)
+		}
+	}
+	s.rollups.replaceDay(start.Format(time.DateOnly), attempts)
+	return s.compactLocked()
+}
+
+func (s *FileStore) PruneBefore(ctx context.Context, runEventsBefore, attemptsBefore time.Time) (domain.PruneResult, error) {
+	result := domain.PruneResult{}
+	err := s.Mutate(ctx, func(state *domain.State) error {
+		if !runEventsBefore.IsZero() {
+			kept := state.RunEvents[:0]
+			for _, item := range state.RunEvents {
+				if createdBefore(item.CreatedAt, runEventsBefore) {
+					result.RunEventsDeleted++
+					continue
+				}
+				kept = append(kept, item)
+			}
+			state.RunEvents = kept
+		}
+		if !attemptsBefore.IsZero() {
+			kept := state.Attempts[:0]
+			for _, item := range state.Attempts {
+				if createdBefore(item.CreatedAt, attemptsBefore) {
+					result.AttemptsDeleted++
+					continue
+				}
+				kept = append(kept, item)
+			}
+			state.Attempts = kept
+			s.sketches.pruneBefore(attemptsBefore)
+			s.rollups.pruneBefore(attemptsBefore)
+		}
+		return s.pruneArchiveLocked(runEventsBefore, attemptsBefore, &result)
 	})
+	return result, err
+}
+
+// timeRange is an inclusive after/before filter on RFC3339 timestamps, as
+// Postgres applies it. RFC3339Nano drops trailing zeros, so the bounds are
+// compared as parsed times rather than as strings; an empty or unparseable
+// bound is open.
+type timeRange struct {
+	after, before time.Time
+}
+
+func newTimeRange(after, before string) timeRange {
+	var r timeRange
+	r.after, _ = time.Parse(time.RFC3339Nano, after)
+	r.before, _ = time.Parse(time.RFC3339Nano, before)
+	return r
+}
+
+func (r timeRange) contains(at string) bool {
+	if r.after.IsZero() && r.before.IsZero() {
+		return true
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return false
+	}
+	return !parsed.Before(r.after) && (r.before.IsZero() || !parsed.After(r.before))
+}
+
+// createdBefore keeps records whose timestamp does not parse.
+func createdBefore(createdAt string, cutoff time.Time) bool {
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	return err == nil && parsed.Before(cutoff)
+}
+
+func countRunStatus(summary *domain.TelemetrySummary, status string, count int64) {
+	switch status {
+	case "running":
+		summary.Counts.RunningRuns += count
+	case "completed":
+		summary.Counts.CompletedRuns += count
+	case "failed":
+		summary.Counts.FailedRuns += count
+	case "cancelled":
+		summary.Counts.CancelledRuns += count
+	}
+}
+
+func (s *FileStore) ListRunEvents(ctx context.Context, runID string) ([]domain.RunEvent, error) {
+	return s.ListRunEventsFiltered(ctx, domain.EventFilter{RunID: runID})
+}
+
+func (s *FileStore) CountRunEvents(ctx context.Context) (int, error) {
+	return s.count(func(state *domain.State) int { return len(state.RunEvents) }), nil
 }
 
-func (s *FileStore) ListRunEvents(runID string) ([]domain.RunEvent, error) {
-	return s.ListRunEventsFiltered(domain.EventFilter{RunID: runID})
+// count reads a collection size under the read lock, skipping the deep copy
+// Snapshot makes.
+func (s *FileStore) count(size func(*domain.State) int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return size(&s.state)
 }
 
-func (s *FileStore) ListRunEventsFiltered(filter domain.EventFilter) ([]domain.RunEvent, error) {
-	items := s.Snapshot().RunEvents
+func (s *FileStore) LastRunActivity(ctx context.Context, runIDs []string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := map[string]string{}
+	for _, runID := range runIDs {
+		// RFC3339Nano drops trailing zeros, so compare parsed times.
+		var latest time.Time
+		observe := func(createdAt string) {
+			if at, err := time.Parse(time.RFC3339Nano, createdAt); err == nil && at.After(latest) {
+				latest = at
+			}
+		}
+		for _, i := range s.index.eventsByRun[runID] {
+			observe(s.state.RunEvents[i].CreatedAt)
+		}
+		for _, i := range s.index.attemptsByRun[runID] {
+			observe(s.state.Attempts[i].CreatedAt)
+		}
+		if !latest.IsZero() {
+			out[runID] = latest.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) ListRunEventsFiltered(ctx context.Context, filter domain.EventFilter) ([]domain.RunEvent, error) {
+	var items []domain.RunEvent
+	var runProjects map[string]string
+	if filter.RunID != "" {
+		items = indexed(s, func(state *domain.State) []domain.RunEvent { return state.RunEvents }, func(index *fileIndex) []int {
+			return index.eventsByRun[filter.RunID]
+		})
+		runs, _ := s.ListRunsFiltered(ctx, domain.RunFilter{RunID: filter.RunID, Limit: 1})
+		runProjects = projectsByRunID(runs)
+	} else {
+		snapshot := s.Snapshot()
+		items = snapshot.RunEvents
+		runProjects = projectsByRunID(snapshot.Runs)
+	}
+	if filter.IncludeArchived {
+		archive, err := s.readArchive()
+		if err != nil {
+			return nil, err
+		}
+		items = withArchived(archive.RunEvents, items, func(item domain.RunEvent) string { return item.ID })
+	}
+	created := newTimeRange(filter.CreatedAfter, filter.CreatedBefore)
 	out := make([]domain.RunEvent, 0, len(items))
-	for _, item := range items {
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
 		if filter.RunID != "" && item.RunID != filter.RunID {
 			continue
 		}
+		if filter.Project != "" && runProjects[item.RunID] != filter.Project {
+			continue
+		}
 		if filter.EventType != "" && item.EventType != filter.EventType {
 			continue
 		}
 		if filter.Level != "" && item.Level != filter.Level {
 			continue
 		}
-		if filter.CreatedAfter != "" && item.CreatedAt <= filter.CreatedAfter {
+		if !created.contains(item.CreatedAt) {
 			continue
 		}
-		if filter.CreatedBefore != "" && item.CreatedAt >= filter.CreatedBefore {
+		out = append(out, item)
+		if filter.Limit > 0 && int64(len(out)) >= filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) InsertRunEvent(ctx context.Context, event domain.RunEvent) error {
+	return s.write(ctx, journalAppend, "run_event", event)
+}
+
+func (s *FileStore) InsertRunEvents(ctx context.Context, events []domain.RunEvent) error {
+	return writeRecords(ctx, s, journalAppend, "run_event", events)
+}
+
+func (s *FileStore) ListArtifacts(ctx context.Context, filter domain.ArtifactFilter) ([]domain.Artifact, error) {
+	snapshot := s.Snapshot()
+	items := snapshot.Artifacts
+	runProjects := projectsByRunID(snapshot.Runs)
+	out := make([]domain.Artifact, 0, len(items))
+	for _, item := range items {
+		if filter.Project != "" && runProjects[item.RunID] != filter.Project {
+			continue
+		}
+		if filter.ID != "" && item.ID != filter.ID {
+			continue
+		}
+		if filter.RunID != "" && item.RunID != filter.RunID {
+			continue
+		}
+		if filter.Kind != "" && item.Kind != filter.Kind {
 			continue
 		}
 		out = append(out, item)
@@ -393,14 +1040,60 @@ func (s *FileStore) ListRunEventsFiltered(filter domain.EventFilter) ([]domain.R
 	return out, nil
 }
 
-func (s *FileStore) InsertRunEvent(event domain.RunEvent) error {
-	return s.Mutate(func(state *domain.State) error {
-		state.RunEvents = append(state.RunEvents, event)
-		return nil
-	})
+func (s *FileStore) InsertArtifact(ctx context.Context, artifact domain.Artifact) error {
+	return s.write(ctx, journalAppend, "artifact", artifact)
 }
 
-func (s *FileStore) ReserveIdempotencyKey(method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error) {
+func (s *FileStore) ListPromptReleases(ctx context.Context, filter domain.PromptReleaseFilter) ([]domain.PromptRelease, error) {
+	items := slices.Clone(s.Snapshot().Releases)
+	slices.Reverse(items)
+	out := make([]domain.PromptRelease, 0, len(items))
+	for _, item := range items {
+		if filter.Project != "" && item.Project != filter.Project {
+			continue
+		}
+		if filter.Workflow != "" && item.Workflow != filter.Workflow {
+			continue
+		}
+		out = append(out, item)
+		if filter.Limit > 0 && int64(len(out)) >= filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) InsertPromptRelease(ctx context.Context, release domain.PromptRelease) error {
+	return s.write(ctx, journalAppend, "prompt_release", release)
+}
+
+func (s *FileStore) ListPolicyAudit(ctx context.Context, filter domain.PolicyAuditFilter) ([]domain.PolicyAuditEntry, error) {
+	items := slices.Clone(s.Snapshot().Audit)
+	slices.Reverse(items)
+	out := make([]domain.PolicyAuditEntry, 0, len(items))
+	for _, item := range items {
+		if filter.Project != "" && item.Project != filter.Project {
+			continue
+		}
+		if filter.TargetType != "" && item.TargetType != filter.TargetType {
+			continue
+		}
+		if filter.TargetID != "" && item.TargetID != filter.TargetID {
+			continue
+		}
+		out = append(out, item)
+		if filter.Limit > 0 && int64(len(out)) >= filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) InsertPolicyAudit(ctx context.Context, entry domain.PolicyAuditEntry) error {
+	return s.write(ctx, journalAppend, "policy_audit", entry)
+}
+
+func (s *FileStore) ReserveIdempotencyKey(ctx context.Context, method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error) {
 	method = normalizeIdempotencyToken(method)
 	idempotencyKey = normalizeIdempotencyToken(idempotencyKey)
 	if method == "" || idempotencyKey == "" || requestHash == "" {
@@ -412,18 +1105,21 @@ func (s *FileStore) ReserveIdempotencyKey(method, idempotencyKey, requestHash st
 
 	key := fileIdempotencyRecordKey(method, idempotencyKey)
 	record, exists := s.idempotency[key]
-	if exists {
-		return record, false, nil
+	if exists && !record.expired(time.Now()) {
+		return record.toRecord(), false, nil
 	}
 
-	s.idempotency[key] = IdempotencyRecord{
+	s.idempotency[key] = fileIdempotencyRecord{
+		Method:      method,
+		Key:         idempotencyKey,
 		RequestHash: requestHash,
-		Completed:   false,
 	}
 	return IdempotencyRecord{}, true, nil
 }
 
-func (s *FileStore) CompleteIdempotencyKey(method, idempotencyKey, responseJSON string) error {
+// CompleteIdempotencyKey journals the completed record before returning, so
+// a retry after a restart still replays the response.
+func (s *FileStore) CompleteIdempotencyKey(ctx context.Context, method, idempotencyKey, responseJSON string) error {
 	method = normalizeIdempotencyToken(method)
 	idempotencyKey = normalizeIdempotencyToken(idempotencyKey)
 	if method == "" || idempotencyKey == "" {
@@ -439,12 +1135,11 @@ func (s *FileStore) CompleteIdempotencyKey(method, idempotencyKey, responseJSON
 		return domain.NotFound("idempotency key not found")
 	}
 	record.ResponseJSON = responseJSON
-	record.Completed = true
-	s.idempotency[key] = record
-	return nil
+	record.CompletedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	return s.writeLocked(ctx, journalUpsert, "idempotency", record)
 }
 
-func (s *FileStore) ReleaseIdempotencyKey(method, idempotencyKey string) error {
+func (s *FileStore) ReleaseIdempotencyKey(ctx context.Context, method, idempotencyKey string) error {
 	method = normalizeIdempotencyToken(method)
 	idempotencyKey = normalizeIdempotencyToken(idempotencyKey)
 	if method == "" || idempotencyKey == "" {
@@ -459,13 +1154,63 @@ func (s *FileStore) ReleaseIdempotencyKey(method, idempotencyKey string) error {
 	if !exists {
 		return nil
 	}
-	if record.Completed {
+	if record.completed() {
 		return nil
 	}
 	delete(s.idempotency, key)
 	return nil
 }
 
+// fileIdempotencyTTL is how long a completed key replays its response. Older
+// records are treated as unused and dropped at the next compaction.
+const fileIdempotencyTTL = 24 * time.Hour
+
+// fileIdempotencyRecord is an idempotency key as FileStore holds it. Only
+// completed records are written to the state file and journal; a key still
+// in progress when the process stops is released by the restart.
+type fileIdempotencyRecord struct {
+	Method       string `json:"method"`
+	Key          string `json:"idempotency_key"`
+	RequestHash  string `json:"request_hash"`
+	ResponseJSON string `json:"response_json"`
+	CompletedAt  string `json:"completed_at,omitempty"`
+}
+
+func (r fileIdempotencyRecord) completed() bool {
+	return r.CompletedAt != ""
+}
+
+func (r fileIdempotencyRecord) expired(now time.Time) bool {
+	if !r.completed() {
+		return false
+	}
+	completedAt, err := time.Parse(time.RFC3339Nano, r.CompletedAt)
+	return err != nil || now.Sub(completedAt) > fileIdempotencyTTL
+}
+
+func (r fileIdempotencyRecord) toRecord() IdempotencyRecord {
+	return IdempotencyRecord{RequestHash: r.RequestHash, ResponseJSON: r.ResponseJSON, Completed: r.completed()}
+}
+
+// persistedIdempotencyLocked drops expired records and returns the completed
+// ones for a snapshot, in key order.
+func (s *FileStore) persistedIdempotencyLocked(now time.Time) []fileIdempotencyRecord {
+	records := make([]fileIdempotencyRecord, 0, len(s.idempotency))
+	for key, record := range s.idempotency {
+		if record.expired(now) {
+			delete(s.idempotency, key)
+			continue
+		}
+		if record.completed() {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b fileIdempotencyRecord) int {
+		return strings.Compare(fileIdempotencyRecordKey(a.Method, a.Key), fileIdempotencyRecordKey(b.Method, b.Key))
+	})
+	return records
+}
+
 func fileIdempotencyRecordKey(method, idempotencyKey string) string {
 	return method + "::" + idempotencyKey
 }
@@ -473,3 +1218,29 @@ func fileIdempotencyRecordKey(method, idempotencyKey string) string {
 func normalizeIdempotencyToken(value string) string {
 	return strings.TrimSpace(value)
 }
+
+func containsAllTags(have, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAllLabels(have, want map[string]string) bool {
+	for key, value := range want {
+		if current, ok := have[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+func projectsByRunID(runs []domain.AgentRun) map[string]string {
+	out := make(map[string]string, len(runs))
+	for _, run := range runs {
+		out[run.ID] = run.Project
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+const (
+	// hllPrecision gives 4096 one-byte registers per sketch, for a standard
+	// error of about 1.6%.
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+	// distinctDays is how many recent days TelemetrySummary breaks down.
+	distinctDays = 7
+)
+
+const (
+	sketchModels       = "models"
+	sketchPromptHashes = "prompt_hashes"
+	sketchAgents       = "agents"
+)
+
+// hllSketch is a HyperLogLog counter. Merging two sketches keeps the larger
+// register of each pair, so merges can be repeated and applied in any order.
+type hllSketch [hllRegisters]uint8
+
+func (h *hllSketch) add(value string) {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(value))
+	hash := mix64(hasher.Sum64())
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h[index] {
+		h[index] = rank
+	}
+}
+
+func (h *hllSketch) merge(other *hllSketch) {
+	for i, register := range other {
+		if register > h[i] {
+			h[i] = register
+		}
+	}
+}
+
+// estimate is the HyperLogLog cardinality estimate, with linear counting for
+// small sets where it is more accurate.
+func (h *hllSketch) estimate() int64 {
+	sum := 0.0
+	zeros := 0
+	for _, register := range h {
+		sum += math.Ldexp(1, -int(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// mix64 is the splitmix64 finalizer; FNV alone leaves the high bits, which
+// pick the register, poorly mixed for short inputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// sketchKey names one sketch: a dimension counted over one UTC day.
+type sketchKey struct {
+	day       string
+	dimension string
+}
+
+// telemetrySketches holds the per-day distinct-value sketches behind
+// TelemetrySummary.Distinct.
+type telemetrySketches map[sketchKey]*hllSketch
+
+func (t telemetrySketches) sketch(key sketchKey) *hllSketch {
+	sketch, ok := t[key]
+	if !ok {
+		sketch = &hllSketch{}
+		t[key] = sketch
+	}
+	return sketch
+}
+
+// addAttempt counts an attempt's model, prompt hash and agent on the day it
+// was created. Attempts whose created_at does not parse are skipped.
+func (t telemetrySketches) addAttempt(attempt domain.PromptAttempt) {
+	createdAt, err := time.Parse(time.RFC3339Nano, attempt.CreatedAt)
+	if err != nil {
+		return
+	}
+	day := createdAt.UTC().Format(time.DateOnly)
+	if attempt.Model != "" {
+		t.sketch(sketchKey{day: day, dimension: sketchModels}).add(attempt.Model)
+	}
+	if attempt.PromptHash != "" {
+		t.sketch(sketchKey{day: day, dimension: sketchPromptHashes}).add(attempt.PromptHash)
+	}
+	if attempt.AgentID != "" {
+		t.sketch(sketchKey{day: day, dimension: sketchAgents}).add(attempt.AgentID)
+	}
+}
+
+func (t telemetrySketches) merge(other telemetrySketches) {
+	for key, sketch := range other {
+		t.sketch(key).merge(sketch)
+	}
+}
+
+// pruneBefore drops the days that end at or before cutoff, as attempt
+// retention does; a zero cutoff keeps everything.
+func (t telemetrySketches) pruneBefore(cutoff time.Time) {
+	if cutoff.IsZero() {
+		return
+	}
+	for key := range t {
+		if day, err := time.Parse(time.DateOnly, key.day); err == nil && !day.Add(24*time.Hour).After(cutoff) {
+			delete(t, key)
+		}
+	}
+}
+
+// summarize sets summary.Distinct across every day held and
+// summary.DistinctByDay for the most recent distinctDays days with data.
+func (t telemetrySketches) summarize(summary *domain.TelemetrySummary) {
+	total := map[string]*hllSketch{}
+	byDay := map[string]*domain.DistinctCounts{}
+	for key, sketch := range t {
+		merged, ok := total[key.dimension]
+		if !ok {
+			merged = &hllSketch{}
+			total[key.dimension] = merged
+		}
+		merged.merge(sketch)
+		day, ok := byDay[key.day]
+		if !ok {
+			day = &domain.DistinctCounts{Day: key.day}
+			byDay[key.day] = day
+		}
+		setDistinct(day, key.dimension, sketch.estimate())
+	}
+	summary.Distinct = domain.DistinctCounts{}
+	for dimension, sketch := range total {
+		setDistinct(&summary.Distinct, dimension, sketch.estimate())
+	}
+	days := make([]string, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	slices.Sort(days)
+	slices.Reverse(days)
+	summary.DistinctByDay = []domain.DistinctCounts{}
+	for _, day := range days[:min(len(days), distinctDays)] {
+		summary.DistinctByDay = append(summary.DistinctByDay, *byDay[day])
+	}
+}
+
+func setDistinct(counts *domain.DistinctCounts, dimension string, estimate int64) {
+	switch dimension {
+	case sketchModels:
+		counts.Models = estimate
+	case sketchPromptHashes:
+		counts.PromptHashes = estimate
+	case sketchAgents:
+		counts.Agents = estimate
+	}
+}
+
+// sketchSource is implemented by stores that keep telemetry sketches, so a
+// ShardedStore can merge them instead of adding up distinct counts.
+type sketchSource interface {
+	telemetrySketches(ctx context.Context) (telemetrySketches, error)
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// MemoryStore is a FileStore without a backing file: state and idempotency
+// keys live for the life of the process. It backs STORE_DRIVER=memory for
+// integration tests and demo environments.
+type MemoryStore struct {
+	*FileStore
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{FileStore: &FileStore{
+		state:       domain.EmptyState(),
+		idempotency: map[string]fileIdempotencyRecord{},
+		ephemeral:   true,
+		sketches:    telemetrySketches{},
+		rollups:     dailyRollups{},
+	}}
+}
+
+// MemoryArtifactBlobStore keeps artifact bytes in a map, for use alongside
+// MemoryStore.
+type MemoryArtifactBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemoryArtifactBlobStore() *MemoryArtifactBlobStore {
+	return &MemoryArtifactBlobStore{blobs: map[string][]byte{}}
+}
+
+func (s *MemoryArtifactBlobStore) PutArtifactBlob(id string, content []byte) error {
+	clean := strings.TrimSpace(id)
+	if clean == "" {
+		return domain.InvalidArgument("artifact id is invalid")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[clean] = append([]byte(nil), content...)
+	return nil
+}
+
+func (s *MemoryArtifactBlobStore) GetArtifactBlob(id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	raw, ok := s.blobs[strings.TrimSpace(id)]
+	if !ok {
+		return nil, domain.NotFound("artifact content not found")
+	}
+	return append([]byte(nil), raw...), nil
+}
+
+func (s *MemoryArtifactBlobStore) DeleteArtifactBlob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, strings.TrimSpace(id))
+	return nil
+}
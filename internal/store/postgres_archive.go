@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// archiveBatchSize bounds the rows one ArchiveBefore statement moves, so each
+// batch is a short transaction that replicas and compression jobs can
+// interleave with.
+const archiveBatchSize = 5000
+
+// archiveTables pairs each hot hypertable with its cold tier from
+// 017_cold_storage.sql. A migration adding a column to a hot table must add
+// it to the archive table too.
+var archiveTables = []struct {
+	table   string
+	archive string
+	columns []string
+}{
+	{table: "prompt_attempts", archive: "prompt_attempts_archive", columns: promptAttemptColumns},
+	{table: "run_events", archive: "run_events_archive", columns: runEventColumns},
+}
+
+// ArchiveBefore moves attempts and run events created before cutoff into
+// the archive hypertables in batches of archiveBatchSize, deleting and
+// inserting each batch in one statement.
+func (s *PostgresStore) ArchiveBefore(ctx context.Context, cutoff time.Time) (domain.ArchiveResult, error) {
+	result := domain.ArchiveResult{}
+	if err := s.requireArchive(ctx); err != nil {
+		return result, err
+	}
+	for _, target := range archiveTables {
+		columns := strings.Join(target.columns, ", ")
+		query := `
+			WITH moved AS (
+				DELETE FROM ` + target.table + `
+				WHERE (id, created_at) IN (
+					SELECT id, created_at FROM ` + target.table + `
+					WHERE created_at < $1
+					ORDER BY created_at
+					LIMIT $2
+				)
+				RETURNING ` + columns + `
+			)
+			INSERT INTO ` + target.archive + ` (` + columns + `)
+			SELECT ` + columns + ` FROM moved
+			ON CONFLICT DO NOTHING
+		`
+		for {
+			moved, err := s.archiveBatch(ctx, query, cutoff)
+			if target.table == "prompt_attempts" {
+				result.AttemptsArchived += moved
+			} else {
+				result.RunEventsArchived += moved
+			}
+			if err != nil {
+				return result, domain.Internal("failed to archive "+target.table, err)
+			}
+			if moved < archiveBatchSize {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *PostgresStore) archiveBatch(ctx context.Context, query string, cutoff time.Time) (int64, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, query, cutoff.UTC(), archiveBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// archiveExists reports whether 017_cold_storage.sql has been applied. Only a
+// server in compatibility mode starts without it.
+func (s *PostgresStore) archiveExists(ctx context.Context) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL AND to_regclass($2) IS NOT NULL`,
+		s.Schema()+".prompt_attempts_archive", s.Schema()+".run_events_archive").Scan(&exists)
+	return exists, err
+}
+
+func (s *PostgresStore) requireArchive(ctx context.Context) error {
+	exists, err := s.archiveExists(ctx)
+	if err != nil {
+		return domain.Internal("failed to verify archive tables", err)
+	}
+	if !exists {
+		return domain.FailedPrecondition("cold storage needs migration 017, which has not been applied yet; run `modeloman-server migrate`")
+	}
+	return nil
+}
+
+// attemptsSource is the FROM target for attempt lists: prompt_attempts, or
+// with includeArchived the hot and archived rows under the same name.
+func attemptsSource(includeArchived bool) string {
+	return archiveSource("prompt_attempts", "prompt_attempts_archive", promptAttemptColumns, includeArchived)
+}
+
+// eventsSource is attemptsSource for run events.
+func eventsSource(includeArchived bool) string {
+	return archiveSource("run_events", "run_events_archive", runEventColumns, includeArchived)
+}
+
+func archiveSource(table, archive string, columns []string, includeArchived bool) string {
+	if !includeArchived {
+		return table
+	}
+	list := strings.Join(columns, ", ")
+	return "(SELECT " + list + " FROM " + table + " UNION ALL SELECT " + list + " FROM " + archive + ") AS " + table
+}
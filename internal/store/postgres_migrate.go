@@ -0,0 +1,282 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID is the advisory lock held while migrating, so servers
+// starting together with AUTO_MIGRATE apply each migration once.
+const migrationLockID = 7_146_853_001
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_[a-z0-9_]+\.sql$`)
+
+// psqlVariablePattern matches psql's :'name' interpolation.
+var psqlVariablePattern = regexp.MustCompile(`:'([a-z_]+)'`)
+
+// migrationPhasePattern matches the header line that marks a migration's
+// phase. Migrations without one are expand migrations.
+var migrationPhasePattern = regexp.MustCompile(`(?m)^--\s*modeloman:phase\s+(expand|contract)\s*$`)
+
+// destructiveStatementPattern matches statements that break servers still
+// reading the old schema, which only contract migrations may run.
+var destructiveStatementPattern = regexp.MustCompile(`(?i)\b(DROP\s+(COLUMN|TABLE)|RENAME\s+(COLUMN|TO)|ALTER\s+COLUMN\s+\S+\s+(TYPE|SET\s+NOT\s+NULL))\b`)
+
+type migration struct {
+	version string
+	name    string
+	// contract migrations remove or rewrite what the previous release still
+	// uses, and only run with MigrateOptions.Contract.
+	contract bool
+}
+
+// MigrateOptions controls a Migrate run.
+type MigrateOptions struct {
+	// Baseline records every migration up to and including this version as
+	// applied without running it, for a database migrated by hand. It is
+	// ignored once history exists.
+	Baseline string
+	// Contract also applies contract migrations. Leave it unset until every
+	// replica runs the release that stopped using what they remove.
+	Contract bool
+}
+
+// MigrateResult lists the migrations a Migrate run applied and the pending
+// ones it held back at the first contract migration.
+type MigrateResult struct {
+	Applied []string
+	Held    []string
+}
+
+// Migrate applies the migrations in dir (NNN_name.sql files) that are not yet
+// recorded in schema_migrations, in order and each in its own transaction.
+// psql meta-commands are skipped and :'compress_after' is filled from
+// SetCompressAfterDays (default 7 days), so the files stay runnable with
+// psql as well.
+//
+// Migrations follow expand/contract: expand migrations only add schema the
+// running release ignores, so they can run while old replicas serve, and a
+// migration that drops, renames or retypes columns must be marked
+// "-- modeloman:phase contract". Without options.Contract the run stops at
+// the first pending contract migration and reports it and the rest as held.
+func (s *PostgresStore) Migrate(dir fs.FS, options MigrateOptions) (MigrateResult, error) {
+	result := MigrateResult{}
+	migrations, err := listMigrations(dir)
+	if err != nil {
+		return result, err
+	}
+	baseline := strings.TrimSpace(options.Baseline)
+	if baseline != "" && !slices.ContainsFunc(migrations, func(m migration) bool { return m.version == baseline }) {
+		return result, domain.InvalidArgument(fmt.Sprintf("baseline %q does not match any migration", baseline))
+	}
+
+	ctx := context.Background()
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return result, domain.Internal("failed to connect to postgres", err)
+	}
+	defer conn.Release()
+	// Migrations may rewrite large tables; lift the per-statement timeout for
+	// this connection and restore it before it goes back to the pool.
+	if _, err := conn.Exec(ctx, `SET statement_timeout = 0`); err != nil {
+		return result, domain.Internal("failed to prepare migration connection", err)
+	}
+	defer conn.Exec(ctx, `RESET statement_timeout`)
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return result, domain.Internal("failed to acquire migration lock", err)
+	}
+	defer conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if s.dbSchema != "" {
+		if _, err := conn.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS `+s.dbSchema); err != nil {
+			return result, domain.Internal("failed to create shard schema "+s.dbSchema, err)
+		}
+	}
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`); err != nil {
+		return result, domain.Internal("failed to create schema_migrations", err)
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return result, err
+	}
+
+	if len(applied) == 0 {
+		if baseline != "" {
+			for _, m := range migrations {
+				if err := recordMigration(ctx, conn, m); err != nil {
+					return result, err
+				}
+				applied[m.version] = struct{}{}
+				if m.version == baseline {
+					break
+				}
+			}
+		} else {
+			var existing bool
+			if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, s.Schema()+".tasks").Scan(&existing); err != nil {
+				return result, domain.Internal("failed to inspect database schema", err)
+			}
+			if existing {
+				return result, domain.FailedPrecondition("database has a schema but no migration history; run `modeloman-server migrate -baseline <version>` with the last migration applied by hand")
+			}
+		}
+	}
+
+	vars := map[string]string{"compress_after": "7 days"}
+	if s.compressAfterDays > 0 {
+		vars["compress_after"] = fmt.Sprintf("%d days", s.compressAfterDays)
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if len(result.Held) > 0 || (m.contract && !options.Contract) {
+			result.Held = append(result.Held, m.name)
+			continue
+		}
+		raw, err := fs.ReadFile(dir, m.name)
+		if err != nil {
+			return result, domain.Internal("failed to read migration "+m.name, err)
+		}
+		if err := applyMigration(ctx, conn, m, psqlScript(string(raw), vars)); err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, m.name)
+	}
+	return result, nil
+}
+
+func listMigrations(dir fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, domain.Internal("failed to list migrations", err)
+	}
+	var out []migration
+	seen := map[string]string{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		if other, ok := seen[match[1]]; ok {
+			return nil, domain.Internal(fmt.Sprintf("migrations %s and %s share version %s", other, entry.Name(), match[1]), nil)
+		}
+		seen[match[1]] = entry.Name()
+		raw, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return nil, domain.Internal("failed to read migration "+entry.Name(), err)
+		}
+		m := migration{version: match[1], name: entry.Name()}
+		if phase := migrationPhasePattern.FindStringSubmatch(string(raw)); phase != nil {
+			m.contract = phase[1] == "contract"
+		}
+		if !m.contract {
+			if statement := destructiveStatementPattern.FindString(sqlWithoutComments(string(raw))); statement != "" {
+				return nil, domain.Internal(fmt.Sprintf("migration %s runs %q, which breaks servers on the previous schema; mark it \"-- modeloman:phase contract\"", entry.Name(), statement), nil)
+			}
+		}
+		out = append(out, m)
+	}
+	// ReadDir sorts by name, and versions are zero-padded.
+	return out, nil
+}
+
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[string]struct{}, error) {
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, domain.Internal("failed to read schema_migrations", err)
+	}
+	defer rows.Close()
+	applied := map[string]struct{}{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, domain.Internal("failed to read schema_migrations", err)
+		}
+		applied[version] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to read schema_migrations", err)
+	}
+	return applied, nil
+}
+
+func recordMigration(ctx context.Context, exec interface {
+	Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
+}, m migration) error {
+	if _, err := exec.Exec(ctx, `
+		INSERT INTO schema_migrations (version, name)
+		VALUES ($1, $2)
+		ON CONFLICT (version) DO NOTHING
+	`, m.version, m.name); err != nil {
+		return domain.Internal("failed to record migration "+m.name, err)
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, m migration, script string) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to begin migration "+m.name, err)
+	}
+	defer tx.Rollback(ctx)
+	// Without arguments pgx sends the script over the simple protocol, which
+	// allows several statements in one call.
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return domain.Internal("migration "+m.name+" failed", err)
+	}
+	if err := recordMigration(ctx, tx, m); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit migration "+m.name, err)
+	}
+	return nil
+}
+
+// sqlWithoutComments drops "--" comments, so prose in a migration's header
+// is not mistaken for a statement.
+func sqlWithoutComments(script string) string {
+	lines := strings.Split(script, "\n")
+	for i, line := range lines {
+		if at := strings.Index(line, "--"); at >= 0 {
+			lines[i] = line[:at]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// psqlScript drops psql meta-command lines (\if, \set, ...) and substitutes
+// :'name' variables as quoted literals.
+func psqlScript(script string, vars map[string]string) string {
+	lines := strings.Split(script, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return psqlVariablePattern.ReplaceAllStringFunc(strings.Join(kept, "\n"), func(match string) string {
+		value, ok := vars[psqlVariablePattern.FindStringSubmatch(match)[1]]
+		if !ok {
+			return match
+		}
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	})
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaMethods are the PostgresStore methods that may read from the
+// replica. None of them feeds a write, so replication lag only delays what
+// dashboards and list calls show.
+var replicaMethods = []string{
+	"AttemptTimeSeries",
+	"LeaderboardAggregate",
+	"ListArtifacts",
+	"ListBenchmarks",
+	"ListChangelog",
+	"ListDailyRollups",
+	"ListNotes",
+	"ListPolicyAudit",
+	"ListPromptAttemptsFiltered",
+	"ListPromptReleases",
+	"ListRunEventsFiltered",
+	"ListRunsFiltered",
+	"ListTasksFiltered",
+	"SummarizeTelemetry",
+}
+
+// DefaultReplicaMethods are read from the replica when SetReadReplica is
+// given no method list. The service reads runs, attempts, tasks, artifacts
+// and releases back right after writing them, and checks budgets against
+// attempts, so those stay on the primary unless listed explicitly.
+var DefaultReplicaMethods = []string{
+	"AttemptTimeSeries",
+	"LeaderboardAggregate",
+	"ListBenchmarks",
+	"ListChangelog",
+	"ListDailyRollups",
+	"ListNotes",
+	"ListPolicyAudit",
+	"ListRunEventsFiltered",
+	"SummarizeTelemetry",
+}
+
+// SetReadReplica opens dsn and sends the named methods' queries to it;
+// everything else, and every write, stays on the primary. An empty methods
+// list uses DefaultReplicaMethods.
+func (s *PostgresStore) SetReadReplica(dsn string, methods []string) error {
+	if strings.TrimSpace(dsn) == "" {
+		return nil
+	}
+	if len(methods) == 0 {
+		methods = DefaultReplicaMethods
+	}
+	routed := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		if !slices.Contains(replicaMethods, method) {
+			return domain.InvalidArgument("DATABASE_READ_METHODS: unknown method " + method + "; expected one of " + strings.Join(replicaMethods, ", "))
+		}
+		routed[method] = true
+	}
+
+	replica, err := s.newPool(dsn)
+	if err != nil {
+		return domain.Internal("failed to open postgres read replica connection", err)
+	}
+	if s.replica != nil {
+		s.replica.Close()
+	}
+	s.replica = replica
+	s.replicaReads = routed
+	return nil
+}
+
+// readDB returns the pool method reads from.
+func (s *PostgresStore) readDB(method string) *pgxpool.Pool {
+	if s.replica != nil && s.replicaReads[method] {
+		return s.replica
+	}
+	return s.db
+}
+
+func (s *PostgresStore) pingReplica() error {
+	if s.replica == nil {
+		return nil
+	}
+	pingCtx, cancel := context.WithTimeout(context.Background(), defaultDBPingTimeout)
+	defer cancel()
+	if err := s.replica.Ping(pingCtx); err != nil {
+		return domain.Internal("failed to connect to postgres read replica", err)
+	}
+	return nil
+}
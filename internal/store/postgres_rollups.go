@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// rollupState caches whether attempt_daily_rollups exists. Only a server in
+// compatibility mode starts without it; it then checks again at most every
+// schemaRecheckInterval.
+type rollupState struct {
+	mu        sync.Mutex
+	ready     bool
+	checkedAt time.Time
+}
+
+const upsertRollupsSQL = `
+	INSERT INTO attempt_daily_rollups AS r (
+		day, project, workflow, provider_type, provider, model, prompt_version,
+		attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+	)
+	SELECT day::date, project, workflow, provider_type, provider, model, prompt_version,
+	       attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+	FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::text[],
+	            $8::bigint[], $9::bigint[], $10::bigint[], $11::bigint[], $12::double precision[], $13::bigint[])
+	     AS u(day, project, workflow, provider_type, provider, model, prompt_version,
+	          attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms)
+	ON CONFLICT (day, project, workflow, provider_type, provider, model, prompt_version) DO UPDATE SET
+		attempts = r.attempts + EXCLUDED.attempts,
+		success_attempts = r.success_attempts + EXCLUDED.success_attempts,
+		tokens_in = r.tokens_in + EXCLUDED.tokens_in,
+		tokens_out = r.tokens_out + EXCLUDED.tokens_out,
+		cost_usd = r.cost_usd + EXCLUDED.cost_usd,
+		latency_ms = r.latency_ms + EXCLUDED.latency_ms,
+		updated_at = NOW()
+`
+
+// rollupColumns is the select list that aggregates attempts into rollup
+// rows, grouped by rollupGroupBy.
+const (
+	rollupColumns = `(created_at AT TIME ZONE 'UTC')::date::text, project, workflow, provider_type, provider, model, prompt_version,
+		COUNT(*), COUNT(*) FILTER (WHERE outcome = 'success'),
+		COALESCE(SUM(tokens_in), 0)::BIGINT, COALESCE(SUM(tokens_out), 0)::BIGINT,
+		COALESCE(SUM(cost_usd), 0)::DOUBLE PRECISION, COALESCE(SUM(latency_ms), 0)::BIGINT`
+	rollupGroupBy = ` GROUP BY 1, 2, 3, 4, 5, 6, 7`
+)
+
+// addRollups counts attempts into their rollups inside the transaction that
+// inserts them, so the totals commit or roll back with the attempts. Each
+// day's shared advisory lock keeps a concurrent RebuildDailyRollups from
+// recounting the day between the insert and its rollup update.
+func (s *PostgresStore) addRollups(ctx context.Context, tx pgx.Tx, attempts ...domain.PromptAttempt) error {
+	ready, err := s.rollupTableReady(ctx)
+	if err != nil || !ready {
+		return err
+	}
+	pending := dailyRollups{}
+	for _, attempt := range attempts {
+		pending.addAttempt(attempt)
+	}
+	rows := pending.sorted()
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := make([][]string, 7)
+	var counts, successes, tokensIn, tokensOut, latency []int64
+	var cost []float64
+	lockedDay := ""
+	for _, row := range rows {
+		if row.Day != lockedDay {
+			if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared(hashtextextended($1, 0))`, rollupLockKey(row.Day)); err != nil {
+				return domain.Internal("failed to lock daily rollup", err)
+			}
+			lockedDay = row.Day
+		}
+		for i, value := range []string{row.Day, row.Project, row.Workflow, row.ProviderType, row.Provider, row.Model, row.PromptVersion} {
+			columns[i] = append(columns[i], value)
+		}
+		counts = append(counts, row.Attempts)
+		successes = append(successes, row.SuccessAttempts)
+		tokensIn = append(tokensIn, row.TokensIn)
+		tokensOut = append(tokensOut, row.TokensOut)
+		cost = append(cost, row.CostUSD)
+		latency = append(latency, row.LatencyMS)
+	}
+	if _, err := tx.Exec(ctx, upsertRollupsSQL,
+		columns[0], columns[1], columns[2], columns[3], columns[4], columns[5], columns[6],
+		counts, successes, tokensIn, tokensOut, cost, latency,
+	); err != nil {
+		return domain.Internal("failed to update daily rollups", err)
+	}
+	return nil
+}
+
+func rollupLockKey(day string) string {
+	return "modeloman:daily-rollup:" + day
+}
+
+// ListDailyRollups reads attempt_daily_rollups. Before migration 019 is
+// applied it aggregates the attempts instead, which is slower but
+// returns the same rows.
+func (s *PostgresStore) ListDailyRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.DailyRollup, error) {
+	ready, err := s.rollupTableReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := rollupFilterConditions(filter, ready)
+	var query string
+	if ready {
+		query = `
+			SELECT day::text, project, workflow, provider_type, provider, model, prompt_version,
+			       attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+			FROM attempt_daily_rollups`
+		if len(conditions) > 0 {
+			query += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		query += ` ORDER BY day, project, workflow, provider_type, provider, model, prompt_version`
+	} else {
+		archived, err := s.archiveExists(ctx)
+		if err != nil {
+			return nil, domain.Internal("failed to verify archive tables", err)
+		}
+		query = `SELECT ` + rollupColumns + ` FROM ` + attemptsSource(archived)
+		if len(conditions) > 0 {
+			query += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		query += rollupGroupBy + ` ORDER BY 1, 2, 3, 4, 5, 6, 7`
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.readDB("ListDailyRollups").Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to list daily rollups", err)
+	}
+	defer rows.Close()
+	items := []domain.DailyRollup{}
+	for rows.Next() {
+		var item domain.DailyRollup
+		if err := rows.Scan(
+			&item.Day,
+			&item.Project,
+			&item.Workflow,
+			&item.ProviderType,
+			&item.Provider,
+			&item.Model,
+			&item.PromptVersion,
+			&item.Attempts,
+			&item.SuccessAttempts,
+			&item.TokensIn,
+			&item.TokensOut,
+			&item.CostUSD,
+			&item.LatencyMS,
+		); err != nil {
+			return nil, domain.Internal("failed to scan daily rollup", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate daily rollups", err)
+	}
+	return items, nil
+}
+
+// rollupFilterConditions builds the WHERE clause for the rollup table, or
+// for the attempts when the table is missing.
+func rollupFilterConditions(filter domain.RollupFilter, table bool) ([]string, []any) {
+	conditions := []string{}
+	args := []any{}
+	for _, match := range []struct{ column, value string }{
+		{"project", filter.Project},
+		{"workflow", filter.Workflow},
+		{"model", filter.Model},
+		{"prompt_version", filter.PromptVersion},
+	} {
+		if strings.TrimSpace(match.value) != "" {
+			args = append(args, match.value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", match.column, len(args)))
+		}
+	}
+	if filter.FromDay != "" {
+		args = append(args, filter.FromDay)
+		if table {
+			conditions = append(conditions, fmt.Sprintf("day >= $%d::date", len(args)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("created_at >= ($%d::date)::timestamp AT TIME ZONE 'UTC'", len(args)))
+		}
+	}
+	if filter.ToDay != "" {
+		args = append(args, filter.ToDay)
+		if table {
+			conditions = append(conditions, fmt.Sprintf("day <= $%d::date", len(args)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("created_at < ($%d::date + 1)::timestamp AT TIME ZONE 'UTC'", len(args)))
+		}
+	}
+	return conditions, args
+}
+
+// RebuildDailyRollups recounts day from the hot and archived attempts under
+// the day's exclusive advisory lock, which waits for inserts already
+// counting into that day and holds new ones back until it commits.
+func (s *PostgresStore) RebuildDailyRollups(ctx context.Context, day time.Time) error {
+	ready, err := s.rollupTableReady(ctx)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return domain.FailedPrecondition("daily rollups need migration 019, which has not been applied yet; run `modeloman-server migrate`")
+	}
+	archived, err := s.archiveExists(ctx)
+	if err != nil {
+		return domain.Internal("failed to verify archive tables", err)
+	}
+	start := day.UTC().Truncate(24 * time.Hour)
+	label := start.Format(time.DateOnly)
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to start daily rollup rebuild", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, rollupLockKey(label)); err != nil {
+		return domain.Internal("failed to lock daily rollup", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM attempt_daily_rollups WHERE day = $1::date`, label); err != nil {
+		return domain.Internal("failed to clear daily rollup", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO attempt_daily_rollups (
+			day, project, workflow, provider_type, provider, model, prompt_version,
+			attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+		)
+		SELECT day::date, project, workflow, provider_type, provider, model, prompt_version,
+		       attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms
+		FROM (
+			SELECT `+rollupColumns+`
+			FROM `+attemptsSource(archived)+`
+			WHERE created_at >= $1 AND created_at < $2`+rollupGroupBy+`
+		) AS recounted (day, project, workflow, provider_type, provider, model, prompt_version,
+		                attempts, success_attempts, tokens_in, tokens_out, cost_usd, latency_ms)
+	`, start, start.Add(24*time.Hour)); err != nil {
+		return domain.Internal("failed to rebuild daily rollup", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit daily rollup rebuild", err)
+	}
+	return nil
+}
+
+// pruneRollups drops the rollup days that end by cutoff, as retention does
+// for the sketches.
+func (s *PostgresStore) pruneRollups(ctx context.Context, cutoff time.Time) error {
+	ready, err := s.rollupTableReady(ctx)
+	if err != nil || !ready {
+		return err
+	}
+	if _, err := s.db.Exec(ctx, `DELETE FROM attempt_daily_rollups WHERE day < $1::date`, cutoff.UTC().Format(time.DateOnly)); err != nil {
+		return domain.Internal("failed to prune daily rollups", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) rollupTableReady(ctx context.Context) (bool, error) {
+	s.rollups.mu.Lock()
+	defer s.rollups.mu.Unlock()
+	if s.rollups.ready || (!s.rollups.checkedAt.IsZero() && time.Since(s.rollups.checkedAt) < schemaRecheckInterval) {
+		return s.rollups.ready, nil
+	}
+	var ready bool
+	if err := s.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, s.Schema()+".attempt_daily_rollups").Scan(&ready); err != nil {
+		return false, domain.Internal("failed to verify daily rollups table", err)
+	}
+	s.rollups.ready = ready
+	s.rollups.checkedAt = time.Now()
+	return ready, nil
+}
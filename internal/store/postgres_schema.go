@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// schemaRecheckInterval is how often a server in compatibility mode looks
+// for the columns it started without.
+const schemaRecheckInterval = 30 * time.Second
+
+// schemaColumn is a column added by a migration after 001_init.sql. Columns
+// marked optional have a fallback in every query that uses them, so a server
+// in compatibility mode can run before their migration is applied.
+type schemaColumn struct {
+	table     string
+	column    string
+	migration string
+	optional  bool
+}
+
+var schemaColumns = []schemaColumn{
+	{table: "agent_runs", column: "metadata", migration: "003"},
+	{table: "prompt_attempts", column: "metadata", migration: "003"},
+	{table: "tasks", column: "project", migration: "005"},
+	{table: "agent_runs", column: "project", migration: "005"},
+	{table: "prompt_attempts", column: "project", migration: "005"},
+	{table: "policy_caps", column: "project", migration: "005"},
+	{table: "prompt_releases", column: "canary_version", migration: "007"},
+	{table: "policy_caps", column: "agent_id", migration: "008"},
+	{table: "orchestration_policy", column: "maintenance_windows", migration: "009"},
+	{table: "policy_caps", column: "valid_until", migration: "011"},
+	{table: "policy_caps", column: "workflow", migration: "012"},
+	{table: "orchestration_policy", column: "max_cost_per_hour_usd", migration: "013", optional: true},
+	{table: "orchestration_policy", column: "max_cost_per_day_usd", migration: "013", optional: true},
+	{table: "policy_caps", column: "routing", migration: "015"},
+	{table: "orchestration_policy", column: "alert_maintenance_windows", migration: "016", optional: true},
+	{table: "run_events", column: "request_id", migration: "020"},
+	{table: "agent_runs", column: "name", migration: "021"},
+	{table: "agent_runs", column: "description", migration: "021"},
+	{table: "agent_runs", column: "starred", migration: "022"},
+	{table: "agent_runs", column: "starred_at", migration: "022"},
+	{table: "agent_runs", column: "external_id", migration: "023"},
+	{table: "tasks", column: "external_id", migration: "023"},
+}
+
+// schemaState tracks the optional columns a compatibility-mode server is
+// running without.
+type schemaState struct {
+	mu        sync.Mutex
+	compat    bool
+	missing   []schemaColumn
+	checkedAt time.Time
+}
+
+// SetSchemaCompat lets Load succeed when optional columns from migrations
+// not yet applied are missing, for rolling upgrades where replicas of the
+// new release start before the expand migration runs. The store then reads
+// those columns as their defaults, refuses writes that would need them, and
+// picks them up once the migration lands. Call it before Load.
+func (s *PostgresStore) SetSchemaCompat(enabled bool) {
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+	s.schema.compat = enabled
+}
+
+// MissingColumns lists the optional columns the store is running without,
+// as table.column (migration NNN).
+func (s *PostgresStore) MissingColumns() []string {
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+	out := make([]string, 0, len(s.schema.missing))
+	for _, column := range s.schema.missing {
+		out = append(out, fmt.Sprintf("%s.%s (migration %s)", column.table, column.column, column.migration))
+	}
+	return out
+}
+
+// verifyColumns fails on any missing column from schemaColumns, except
+// optional ones in compatibility mode, which it records.
+func (s *PostgresStore) verifyColumns(ctx context.Context) error {
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+	s.schema.missing = nil
+	for _, required := range schemaColumns {
+		exists, err := s.columnExists(ctx, required)
+		if err != nil {
+			return domain.Internal("failed to verify database schema", err)
+		}
+		if exists {
+			continue
+		}
+		if s.schema.compat && required.optional {
+			s.schema.missing = append(s.schema.missing, required)
+			continue
+		}
+		return domain.FailedPrecondition(fmt.Sprintf("required column %s.%s is missing; run `modeloman-server migrate` (or set AUTO_MIGRATE=true) before starting modeloman", required.table, required.column))
+	}
+	s.schema.checkedAt = time.Now()
+	return nil
+}
+
+func (s *PostgresStore) columnExists(ctx context.Context, column schemaColumn) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM information_schema.columns
+			WHERE table_schema = $3 AND table_name = $1 AND column_name = $2
+		)
+	`, column.table, column.column, s.Schema()).Scan(&exists)
+	return exists, err
+}
+
+// hasColumn reports whether table.column can be used. While columns are
+// missing it checks for them again at most every schemaRecheckInterval; a
+// failed check keeps the last answer.
+func (s *PostgresStore) hasColumn(ctx context.Context, table, column string) bool {
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+	if len(s.schema.missing) == 0 {
+		return true
+	}
+	if time.Since(s.schema.checkedAt) >= schemaRecheckInterval {
+		s.schema.checkedAt = time.Now()
+		s.schema.missing = slices.DeleteFunc(s.schema.missing, func(missing schemaColumn) bool {
+			exists, err := s.columnExists(ctx, missing)
+			return err == nil && exists
+		})
+	}
+	return !slices.ContainsFunc(s.schema.missing, func(missing schemaColumn) bool {
+		return missing.table == table && missing.column == column
+	})
+}
+
+// columnOr is column when it exists, else fallback, as a select-list
+// expression.
+func (s *PostgresStore) columnOr(ctx context.Context, table, column, fallback string) string {
+	if s.hasColumn(ctx, table, column) {
+		return column
+	}
+	return fallback
+}
+
+// missingColumnError refuses a write that needs a column the schema does
+// not have yet.
+func missingColumnError(table, column string) error {
+	for _, known := range schemaColumns {
+		if known.table == table && known.column == column {
+			return domain.FailedPrecondition(fmt.Sprintf("%s.%s needs migration %s, which has not been applied yet; run `modeloman-server migrate`", table, column, known.migration))
+		}
+	}
+	return domain.FailedPrecondition(fmt.Sprintf("%s.%s is missing; run `modeloman-server migrate`", table, column))
+}
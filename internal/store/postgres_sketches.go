@@ -0,0 +1,212 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// sketchFlushInterval is the longest counted attempts wait in memory before
+// their sketches are merged into telemetry_sketches; a crash loses at most
+// that much from the distinct counts.
+const sketchFlushInterval = 30 * time.Second
+
+// pendingSketches holds the sketch updates of attempts this process wrote
+// since the last flush.
+type pendingSketches struct {
+	mu        sync.Mutex
+	sketches  telemetrySketches
+	flushedAt time.Time
+	// ready is set once telemetry_sketches is known to exist.
+	ready bool
+}
+
+// countAttempts adds committed attempts to the pending sketches and flushes
+// them when the last flush is sketchFlushInterval old. A failed flush keeps
+// them for the next one.
+func (s *PostgresStore) countAttempts(attempts ...domain.PromptAttempt) {
+	s.sketches.mu.Lock()
+	if s.sketches.sketches == nil {
+		s.sketches.sketches = telemetrySketches{}
+		s.sketches.flushedAt = time.Now()
+	}
+	for _, attempt := range attempts {
+		s.sketches.sketches.addAttempt(attempt)
+	}
+	due := time.Since(s.sketches.flushedAt) >= sketchFlushInterval
+	s.sketches.mu.Unlock()
+	if due {
+		_ = s.flushSketches(context.Background())
+	}
+}
+
+// flushSketches merges the pending sketches into telemetry_sketches. Each
+// row is created empty if missing and then updated under FOR UPDATE, so
+// replicas flushing the same day at once do not overwrite each other.
+func (s *PostgresStore) flushSketches(ctx context.Context) error {
+	s.sketches.mu.Lock()
+	pending := s.sketches.sketches
+	s.sketches.sketches = telemetrySketches{}
+	s.sketches.flushedAt = time.Now()
+	s.sketches.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	err := s.writeSketches(ctx, pending)
+	if err != nil {
+		s.sketches.mu.Lock()
+		s.sketches.sketches.merge(pending)
+		s.sketches.mu.Unlock()
+	}
+	return err
+}
+
+func (s *PostgresStore) writeSketches(ctx context.Context, pending telemetrySketches) error {
+	ready, err := s.sketchTableReady(ctx)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return domain.FailedPrecondition("telemetry sketches need migration 018, which has not been applied yet; run `modeloman-server migrate`")
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	keys := make([]sketchKey, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	// A fixed order keeps concurrent flushes from deadlocking.
+	slices.SortFunc(keys, func(a, b sketchKey) int {
+		return cmp.Or(cmp.Compare(a.day, b.day), cmp.Compare(a.dimension, b.dimension))
+	})
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to start telemetry sketch flush", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	empty := hllSketch{}
+	for _, key := range keys {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO telemetry_sketches (day, dimension, registers)
+			VALUES ($1::date, $2, $3)
+			ON CONFLICT (day, dimension) DO NOTHING
+		`, key.day, key.dimension, empty[:]); err != nil {
+			return domain.Internal("failed to create telemetry sketch", err)
+		}
+		var registers []byte
+		if err := tx.QueryRow(ctx, `
+			SELECT registers FROM telemetry_sketches WHERE day = $1::date AND dimension = $2 FOR UPDATE
+		`, key.day, key.dimension).Scan(&registers); err != nil {
+			return domain.Internal("failed to read telemetry sketch", err)
+		}
+		merged, err := decodeSketch(registers)
+		if err != nil {
+			return err
+		}
+		merged.merge(pending[key])
+		if _, err := tx.Exec(ctx, `
+			UPDATE telemetry_sketches SET registers = $3, updated_at = NOW() WHERE day = $1::date AND dimension = $2
+		`, key.day, key.dimension, merged[:]); err != nil {
+			return domain.Internal("failed to update telemetry sketch", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit telemetry sketches", err)
+	}
+	return nil
+}
+
+// telemetrySketches flushes this process's pending sketches and reads every
+// stored day; pending sketches that could not be flushed are merged in.
+func (s *PostgresStore) telemetrySketches(ctx context.Context) (telemetrySketches, error) {
+	out := telemetrySketches{}
+	_ = s.flushSketches(ctx)
+	s.sketches.mu.Lock()
+	out.merge(s.sketches.sketches)
+	s.sketches.mu.Unlock()
+	ready, err := s.sketchTableReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ready {
+		return out, nil
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.readDB("SummarizeTelemetry").Query(ctx, `SELECT day::text, dimension, registers FROM telemetry_sketches`)
+	if err != nil {
+		return nil, domain.Internal("failed to read telemetry sketches", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key sketchKey
+		var registers []byte
+		if err := rows.Scan(&key.day, &key.dimension, &registers); err != nil {
+			return nil, domain.Internal("failed to scan telemetry sketch", err)
+		}
+		sketch, err := decodeSketch(registers)
+		if err != nil {
+			return nil, err
+		}
+		out.sketch(key).merge(sketch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate telemetry sketches", err)
+	}
+	return out, nil
+}
+
+// pruneSketches drops the sketch days that end by cutoff, from the table
+// and from the pending sketches.
+func (s *PostgresStore) pruneSketches(ctx context.Context, cutoff time.Time) error {
+	s.sketches.mu.Lock()
+	s.sketches.sketches.pruneBefore(cutoff)
+	s.sketches.mu.Unlock()
+	ready, err := s.sketchTableReady(ctx)
+	if err != nil || !ready {
+		return err
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	if _, err := s.db.Exec(ctx, `DELETE FROM telemetry_sketches WHERE day < $1::date`, cutoff.UTC().Format(time.DateOnly)); err != nil {
+		return domain.Internal("failed to prune telemetry sketches", err)
+	}
+	return nil
+}
+
+// sketchTableReady reports whether migration 018 has been applied; only a
+// server in compatibility mode starts without it. A positive answer is
+// cached.
+func (s *PostgresStore) sketchTableReady(ctx context.Context) (bool, error) {
+	s.sketches.mu.Lock()
+	ready := s.sketches.ready
+	s.sketches.mu.Unlock()
+	if ready {
+		return true, nil
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	if err := s.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, s.Schema()+".telemetry_sketches").Scan(&ready); err != nil {
+		return false, domain.Internal("failed to verify telemetry sketches table", err)
+	}
+	s.sketches.mu.Lock()
+	s.sketches.ready = ready
+	s.sketches.mu.Unlock()
+	return ready, nil
+}
+
+func decodeSketch(registers []byte) (*hllSketch, error) {
+	if len(registers) != hllRegisters {
+		return nil, domain.Internal(fmt.Sprintf("telemetry sketch has %d registers, want %d", len(registers), hllRegisters), nil)
+	}
+	sketch := &hllSketch{}
+	copy(sketch[:], registers)
+	return sketch, nil
+}
@@ -5,60 +5,194 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bcrosbie/modeloman/internal/domain"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresStore struct {
-	db *sql.DB
+	db *pgxpool.Pool
+	// compressAfterDays, when set, is the age at which Load makes sure
+	// prompt_attempts and run_events chunks are compressed.
+	compressAfterDays int64
+	// replica, when set, serves the reads named in replicaReads.
+	replica      *pgxpool.Pool
+	replicaReads map[string]bool
+	queryTimeout time.Duration
+	schema       schemaState
+	sketches     pendingSketches
+	rollups      rollupState
+	// dbSchema is the Postgres schema a shard store's tables live in; empty
+	// means public.
+	dbSchema string
+	maxConns int32
 }
 
 const (
-	defaultDBMaxOpenConns    = 25
-	defaultDBMaxIdleConns    = 10
+	defaultDBMaxConns        = 25
 	defaultDBConnMaxLifetime = 30 * time.Minute
 	defaultDBConnMaxIdleTime = 5 * time.Minute
 	defaultDBPingTimeout     = 5 * time.Second
+	defaultDBQueryTimeout    = 30 * time.Second
+	// defaultShardMaxConns keeps one pool per shard schema from exhausting
+	// the server's connections.
+	defaultShardMaxConns = 5
 )
 
 func NewPostgresStore(dsn string) (*PostgresStore, error) {
 	if strings.TrimSpace(dsn) == "" {
 		return nil, domain.InvalidArgument("DATABASE_URL is required when STORE_DRIVER=postgres")
 	}
-
-	db, err := sql.Open("pgx", dsn)
+	s := &PostgresStore{queryTimeout: defaultDBQueryTimeout}
+	db, err := s.newPool(dsn)
 	if err != nil {
 		return nil, domain.Internal("failed to open postgres connection", err)
 	}
-	db.SetMaxOpenConns(defaultDBMaxOpenConns)
-	db.SetMaxIdleConns(defaultDBMaxIdleConns)
-	db.SetConnMaxLifetime(defaultDBConnMaxLifetime)
-	db.SetConnMaxIdleTime(defaultDBConnMaxIdleTime)
+	s.db = db
+	return s, nil
+}
+
+// NewPostgresShardStore opens a store whose tables live in schema instead of
+// public, for a ShardedStore. Its connections search schema first and then
+// public, where the timescaledb extension lives; Migrate creates the schema.
+func NewPostgresShardStore(dsn, schema string) (*PostgresStore, error) {
+	if strings.TrimSpace(dsn) == "" {
+		return nil, domain.InvalidArgument("DATABASE_URL is required when STORE_DRIVER=postgres")
+	}
+	if err := validateShardSchema(schema); err != nil {
+		return nil, err
+	}
+	s := &PostgresStore{queryTimeout: defaultDBQueryTimeout, dbSchema: schema, maxConns: defaultShardMaxConns}
+	db, err := s.newPool(dsn)
+	if err != nil {
+		return nil, domain.Internal("failed to open postgres connection for shard "+schema, err)
+	}
+	s.db = db
+	return s, nil
+}
+
+// Schema is the Postgres schema the store's tables live in.
+func (s *PostgresStore) Schema() string {
+	if s.dbSchema == "" {
+		return "public"
+	}
+	return s.dbSchema
+}
+
+// newPool configures a pool without connecting; Load pings it. Each
+// connection starts with statement_timeout set to the query timeout, so the
+// server gives up on a statement even if the client's cancel never reaches it.
+// Statements are traced under the request's span when tracing is on.
+func (s *PostgresStore) newPool(dsn string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxConns = defaultDBMaxConns
+	if s.maxConns > 0 {
+		config.MaxConns = s.maxConns
+	}
+	config.MaxConnLifetime = defaultDBConnMaxLifetime
+	config.MaxConnIdleTime = defaultDBConnMaxIdleTime
+	config.ConnConfig.Tracer = queryTracer{schema: s.Schema()}
+	config.BeforeConnect = func(_ context.Context, connConfig *pgx.ConnConfig) error {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(s.queryTimeout.Milliseconds(), 10)
+		if s.dbSchema != "" {
+			connConfig.RuntimeParams["search_path"] = s.dbSchema + ", public"
+		}
+		return nil
+	}
+	return pgxpool.NewWithConfig(context.Background(), config)
+}
+
+// SetQueryTimeout bounds each store call's queries, client side and as the
+// connections' statement_timeout; 0 keeps the default. Call it before Load.
+func (s *PostgresStore) SetQueryTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.queryTimeout = timeout
+	}
+}
+
+// queryContext bounds one store call's queries by the query timeout on top
+// of the caller's own deadline or cancellation.
+func (s *PostgresStore) queryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, s.queryTimeout)
+}
 
-	return &PostgresStore{db: db}, nil
+// SetCompressAfterDays makes Load check, and if needed replace, the
+// Timescale compression policy on prompt_attempts and run_events. 0 leaves
+// whatever the migrations configured.
+func (s *PostgresStore) SetCompressAfterDays(days int64) {
+	s.compressAfterDays = days
 }
 
 func (s *PostgresStore) Load() error {
 	pingCtx, cancel := context.WithTimeout(context.Background(), defaultDBPingTimeout)
 	defer cancel()
-	if err := s.db.PingContext(pingCtx); err != nil {
+	if err := s.db.Ping(pingCtx); err != nil {
 		return domain.Internal("failed to connect to postgres", err)
 	}
-	return s.verifySchemaReady()
+	if err := s.pingReplica(); err != nil {
+		return err
+	}
+	if err := s.verifySchemaReady(); err != nil {
+		return err
+	}
+	return s.ensureCompression()
 }
 
 func (s *PostgresStore) Close() error {
-	if s.db == nil {
-		return nil
+	if s.db != nil {
+		ctx, cancel := s.queryContext(context.Background())
+		_ = s.flushSketches(ctx)
+		cancel()
+	}
+	if s.replica != nil {
+		s.replica.Close()
+	}
+	if s.db != nil {
+		s.db.Close()
+	}
+	return nil
+}
+
+// PoolStats reports the primary pool and, when configured, the replica's.
+// AcquireWaitMS is the total time callers have waited for a connection.
+func (s *PostgresStore) PoolStats() []PoolStats {
+	out := []PoolStats{poolStats("primary", s.db)}
+	if s.replica != nil {
+		out = append(out, poolStats("replica", s.replica))
+	}
+	return out
+}
+
+func poolStats(name string, pool *pgxpool.Pool) PoolStats {
+	stat := pool.Stat()
+	return PoolStats{
+		Name:                 name,
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		IdleConns:            stat.IdleConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireWaitMS:        float64(stat.AcquireDuration()) / float64(time.Millisecond),
 	}
-	return s.db.Close()
 }
 
 func (s *PostgresStore) verifySchemaReady() error {
+	ctx, cancel := s.queryContext(context.Background())
+	defer cancel()
 	requiredTables := []string{
 		"tasks",
 		"notes",
@@ -71,20 +205,35 @@ func (s *PostgresStore) verifySchemaReady() error {
 		"idempotency_keys",
 		"orchestration_policy",
 		"policy_caps",
+		"artifacts",
+		"prompt_releases",
+		"policy_audit",
+	}
+	// Compatibility mode starts without the cold storage, telemetry sketch
+	// and daily rollup tables; the code using them checks for them when used.
+	s.schema.mu.Lock()
+	compat := s.schema.compat
+	s.schema.mu.Unlock()
+	if !compat {
+		requiredTables = append(requiredTables, "prompt_attempts_archive", "run_events_archive", "telemetry_sketches", "attempt_daily_rollups")
 	}
 
 	for _, tableName := range requiredTables {
 		var exists bool
-		if err := s.db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, "public."+tableName).Scan(&exists); err != nil {
+		if err := s.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, s.Schema()+"."+tableName).Scan(&exists); err != nil {
 			return domain.Internal("failed to verify database schema", err)
 		}
 		if !exists {
-			return domain.FailedPrecondition(fmt.Sprintf("required table %q is missing; run database migrations before starting modeloman", tableName))
+			return domain.FailedPrecondition(fmt.Sprintf("required table %q is missing; run `modeloman-server migrate` (or set AUTO_MIGRATE=true) before starting modeloman", tableName))
 		}
 	}
 
+	if err := s.verifyColumns(ctx); err != nil {
+		return err
+	}
+
 	var hasTimescaleExtension bool
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRow(ctx, `
 		SELECT EXISTS (
 			SELECT 1
 			FROM pg_extension
@@ -94,46 +243,130 @@ func (s *PostgresStore) verifySchemaReady() error {
 		return domain.Internal("failed to verify timescaledb extension", err)
 	}
 	if !hasTimescaleExtension {
-		return domain.FailedPrecondition("timescaledb extension is not installed; run database migrations before starting modeloman")
+		return domain.FailedPrecondition("timescaledb extension is not installed; run `modeloman-server migrate` (or set AUTO_MIGRATE=true) before starting modeloman")
+	}
+
+	return nil
+}
+
+// compressedTables mirrors the settings in 002_timescale_policies.sql.
+var compressedTables = []struct {
+	table     string
+	segmentBy string
+}{
+	{table: "prompt_attempts", segmentBy: "run_id,workflow,agent_id,model,outcome"},
+	{table: "run_events", segmentBy: "run_id,event_type,level"},
+}
+
+// ensureCompression leaves tables whose policy already matches alone, so a
+// restricted app role passes the check; otherwise it enables compression and
+// replaces the policy, which needs the table owner.
+func (s *PostgresStore) ensureCompression() error {
+	ctx, cancel := s.queryContext(context.Background())
+	defer cancel()
+	if s.compressAfterDays <= 0 {
+		return nil
 	}
+	for _, target := range compressedTables {
+		var enabled bool
+		err := s.db.QueryRow(ctx, `
+			SELECT compression_enabled
+			FROM timescaledb_information.hypertables
+			WHERE hypertable_schema = $2 AND hypertable_name = $1
+		`, target.table, s.Schema()).Scan(&enabled)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.FailedPrecondition(fmt.Sprintf("%s is not a hypertable; run `modeloman-server migrate` (or set AUTO_MIGRATE=true) before starting modeloman", target.table))
+		}
+		if err != nil {
+			return domain.Internal("failed to verify "+target.table+" compression", err)
+		}
+
+		var policies, matching int
+		if err := s.db.QueryRow(ctx, `
+			SELECT COUNT(*),
+			       COUNT(*) FILTER (WHERE (config->>'compress_after')::interval = make_interval(days => $2::int))
+			FROM timescaledb_information.jobs
+			WHERE proc_name = 'policy_compression' AND hypertable_schema = $3 AND hypertable_name = $1
+		`, target.table, s.compressAfterDays, s.Schema()).Scan(&policies, &matching); err != nil {
+			return domain.Internal("failed to read "+target.table+" compression policy", err)
+		}
+		if enabled && policies == 1 && matching == 1 {
+			continue
+		}
 
+		if err := s.applyCompression(target.table, target.segmentBy, enabled, policies > 0); err != nil {
+			return domain.FailedPrecondition(fmt.Sprintf(
+				"cannot set %s compression to %d days (%v); apply db/migrations/014_compression_policy.sql with compress_after='%d days' or unset COMPRESS_AFTER_DAYS",
+				target.table, s.compressAfterDays, err, s.compressAfterDays,
+			))
+		}
+	}
 	return nil
 }
 
-func (s *PostgresStore) ExportState() (domain.State, error) {
-	policy, err := s.GetPolicy()
+func (s *PostgresStore) applyCompression(table, segmentBy string, enabled, hasPolicy bool) error {
+	ctx, cancel := s.queryContext(context.Background())
+	defer cancel()
+	if !enabled {
+		if _, err := s.db.Exec(ctx, `ALTER TABLE `+table+` SET (timescaledb.compress, timescaledb.compress_segmentby = '`+segmentBy+`')`); err != nil {
+			return err
+		}
+	}
+	if hasPolicy {
+		if _, err := s.db.Exec(ctx, `SELECT remove_compression_policy($1::regclass)`, table); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.Exec(ctx, `SELECT add_compression_policy($1::regclass, make_interval(days => $2::int))`, table, s.compressAfterDays)
+	return err
+}
+
+func (s *PostgresStore) ExportState(ctx context.Context) (domain.State, error) {
+	policy, err := s.GetPolicy(ctx)
+	if err != nil {
+		return domain.State{}, err
+	}
+	policyCaps, err := s.ListPolicyCaps(ctx)
+	if err != nil {
+		return domain.State{}, err
+	}
+	tasks, err := s.ListTasks(ctx)
+	if err != nil {
+		return domain.State{}, err
+	}
+	notes, err := s.ListNotes(ctx)
 	if err != nil {
 		return domain.State{}, err
 	}
-	policyCaps, err := s.ListPolicyCaps()
+	changelog, err := s.ListChangelog(ctx)
 	if err != nil {
 		return domain.State{}, err
 	}
-	tasks, err := s.ListTasks()
+	benchmarks, err := s.ListBenchmarks(ctx)
 	if err != nil {
 		return domain.State{}, err
 	}
-	notes, err := s.ListNotes()
+	runs, err := s.ListRuns(ctx)
 	if err != nil {
 		return domain.State{}, err
 	}
-	changelog, err := s.ListChangelog()
+	attempts, err := s.ListPromptAttempts(ctx, "")
 	if err != nil {
 		return domain.State{}, err
 	}
-	benchmarks, err := s.ListBenchmarks()
+	runEvents, err := s.ListRunEvents(ctx, "")
 	if err != nil {
 		return domain.State{}, err
 	}
-	runs, err := s.ListRuns()
+	artifacts, err := s.ListArtifacts(ctx, domain.ArtifactFilter{})
 	if err != nil {
 		return domain.State{}, err
 	}
-	attempts, err := s.ListPromptAttempts("")
+	releases, err := s.ListPromptReleases(ctx, domain.PromptReleaseFilter{})
 	if err != nil {
 		return domain.State{}, err
 	}
-	runEvents, err := s.ListRunEvents("")
+	audit, err := s.ListPolicyAudit(ctx, domain.PolicyAuditFilter{})
 	if err != nil {
 		return domain.State{}, err
 	}
@@ -146,20 +379,167 @@ func (s *PostgresStore) ExportState() (domain.State, error) {
 		Runs:       runs,
 		Attempts:   attempts,
 		RunEvents:  runEvents,
+		Artifacts:  artifacts,
+		Releases:   releases,
+		Audit:      audit,
 		Policy:     policy,
 		PolicyCaps: policyCaps,
 	}, nil
 }
 
-func (s *PostgresStore) GetPolicy() (domain.OrchestrationPolicy, error) {
-	row := s.db.QueryRow(`
+// SummarizeTelemetry aggregates in SQL, grouped by run status and attempt
+// outcome, so the summary cost does not grow with row count on the Go side.
+func (s *PostgresStore) SummarizeTelemetry(ctx context.Context) (domain.TelemetrySummary, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	summary := domain.TelemetrySummary{}
+
+	runRows, err := s.readDB("SummarizeTelemetry").Query(ctx, `SELECT status, COUNT(*) FROM agent_runs GROUP BY status`)
+	if err != nil {
+		return summary, domain.Internal("failed to count runs", err)
+	}
+	defer runRows.Close()
+	for runRows.Next() {
+		var status string
+		var count int64
+		if err := runRows.Scan(&status, &count); err != nil {
+			return summary, domain.Internal("failed to scan run counts", err)
+		}
+		summary.Counts.Runs += count
+		countRunStatus(&summary, status, count)
+	}
+	if err := runRows.Err(); err != nil {
+		return summary, domain.Internal("failed to iterate run counts", err)
+	}
+
+	attemptRows, err := s.readDB("SummarizeTelemetry").Query(ctx, `
+		SELECT outcome, COUNT(*), COUNT(*) FILTER (WHERE attempt_number > 1),
+		       COALESCE(SUM(tokens_in), 0), COALESCE(SUM(tokens_out), 0),
+		       COALESCE(SUM(cost_usd), 0), COALESCE(SUM(latency_ms), 0)
+		FROM prompt_attempts
+		GROUP BY outcome
+	`)
+	if err != nil {
+		return summary, domain.Internal("failed to aggregate prompt attempts", err)
+	}
+	defer attemptRows.Close()
+	for attemptRows.Next() {
+		var outcome string
+		var count, retries, tokensIn, tokensOut, latency int64
+		var cost float64
+		if err := attemptRows.Scan(&outcome, &count, &retries, &tokensIn, &tokensOut, &cost, &latency); err != nil {
+			return summary, domain.Internal("failed to scan attempt aggregates", err)
+		}
+		summary.Counts.Attempts += count
+		summary.Counts.Retries += retries
+		summary.Totals.TokensIn += tokensIn
+		summary.Totals.TokensOut += tokensOut
+		summary.Totals.CostUSD += cost
+		summary.Totals.LatencyMS += latency
+		if outcome == "success" {
+			summary.Counts.SuccessAttempts += count
+		} else {
+			summary.Counts.FailedAttempts += count
+		}
+	}
+	if err := attemptRows.Err(); err != nil {
+		return summary, domain.Internal("failed to iterate attempt aggregates", err)
+	}
+
+	if err := s.readDB("SummarizeTelemetry").QueryRow(ctx, `SELECT COUNT(*) FROM run_events`).Scan(&summary.Counts.Events); err != nil {
+		return summary, domain.Internal("failed to count run events", err)
+	}
+	sketches, err := s.telemetrySketches(ctx)
+	if err != nil {
+		return summary, err
+	}
+	sketches.summarize(&summary)
+	return summary, nil
+}
+
+// countRows runs COUNT(*) over a whole table; table is always a constant
+// from this file, never caller input.
+func (s *PostgresStore) countRows(ctx context.Context, table, label string) (int, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var count int
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM `+table).Scan(&count); err != nil {
+		return 0, domain.Internal("failed to count "+label, err)
+	}
+	return count, nil
+}
+
+// PruneBefore drops whole chunks past each cutoff with drop_chunks, which
+// frees space without scanning rows, then deletes what is left of the
+// boundary chunk. The archive tables are pruned with the same cutoffs.
+func (s *PostgresStore) PruneBefore(ctx context.Context, runEventsBefore, attemptsBefore time.Time) (domain.PruneResult, error) {
+	result := domain.PruneResult{}
+	type pruneTarget struct {
+		table   string
+		before  time.Time
+		deleted *int64
+	}
+	targets := []pruneTarget{
+		{table: "run_events", before: runEventsBefore, deleted: &result.RunEventsDeleted},
+		{table: "prompt_attempts", before: attemptsBefore, deleted: &result.AttemptsDeleted},
+	}
+	archived, err := s.archiveExists(ctx)
+	if err != nil {
+		return result, domain.Internal("failed to verify archive tables", err)
+	}
+	if archived {
+		targets = append(targets,
+			pruneTarget{table: "run_events_archive", before: runEventsBefore, deleted: &result.RunEventsDeleted},
+			pruneTarget{table: "prompt_attempts_archive", before: attemptsBefore, deleted: &result.AttemptsDeleted},
+		)
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	for _, target := range targets {
+		if target.before.IsZero() {
+			continue
+		}
+		var dropped int64
+		if err := s.db.QueryRow(ctx,
+			`SELECT COUNT(*) FROM drop_chunks($1::regclass, older_than => $2::timestamptz)`,
+			target.table, target.before.UTC(),
+		).Scan(&dropped); err != nil {
+			return result, domain.Internal("failed to drop "+target.table+" chunks", err)
+		}
+		result.ChunksDropped += dropped
+		deleted, err := s.db.Exec(ctx, `DELETE FROM `+target.table+` WHERE created_at < $1`, target.before.UTC())
+		if err != nil {
+			return result, domain.Internal("failed to prune "+target.table, err)
+		}
+		*target.deleted += deleted.RowsAffected()
+	}
+	if !attemptsBefore.IsZero() {
+		if err := s.pruneSketches(ctx, attemptsBefore); err != nil {
+			return result, err
+		}
+		if err := s.pruneRollups(ctx, attemptsBefore); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (s *PostgresStore) GetPolicy(ctx context.Context) (domain.OrchestrationPolicy, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	row := s.db.QueryRow(ctx, fmt.Sprintf(`
 		SELECT kill_switch, kill_switch_reason, max_cost_per_run_usd, max_attempts_per_run,
-		       max_tokens_per_run, max_latency_per_attempt_ms, updated_at
+		       max_tokens_per_run, max_latency_per_attempt_ms, %s, %s,
+		       maintenance_windows, %s, scheduled_kill_switch, scheduled_kill_switch_reason, updated_at
 		FROM orchestration_policy
 		WHERE policy_id = 1
-	`)
+	`,
+		s.columnOr(ctx, "orchestration_policy", "max_cost_per_hour_usd", "0::DOUBLE PRECISION"),
+		s.columnOr(ctx, "orchestration_policy", "max_cost_per_day_usd", "0::DOUBLE PRECISION"),
+		s.columnOr(ctx, "orchestration_policy", "alert_maintenance_windows", "'[]'::JSONB")))
 
 	policy := domain.DefaultPolicy()
+	var windows, alertWindows []byte
 	var updatedAt time.Time
 	if err := row.Scan(
 		&policy.KillSwitch,
@@ -168,16 +548,81 @@ func (s *PostgresStore) GetPolicy() (domain.OrchestrationPolicy, error) {
 		&policy.MaxAttemptsPerRun,
 		&policy.MaxTokensPerRun,
 		&policy.MaxLatencyPerAttemptMS,
+		&policy.MaxCostPerHourUSD,
+		&policy.MaxCostPerDayUSD,
+		&windows,
+		&alertWindows,
+		&policy.ScheduledKillSwitch,
+		&policy.ScheduledKillSwitchReason,
 		&updatedAt,
 	); err != nil {
 		return domain.OrchestrationPolicy{}, domain.Internal("failed to read orchestration policy", err)
 	}
+	if len(windows) > 0 {
+		if err := json.Unmarshal(windows, &policy.MaintenanceWindows); err != nil {
+			return domain.OrchestrationPolicy{}, domain.Internal("failed to decode maintenance windows", err)
+		}
+	}
+	if len(alertWindows) > 0 {
+		if err := json.Unmarshal(alertWindows, &policy.AlertMaintenanceWindows); err != nil {
+			return domain.OrchestrationPolicy{}, domain.Internal("failed to decode alert maintenance windows", err)
+		}
+	}
+	if policy.MaintenanceWindows == nil {
+		policy.MaintenanceWindows = []domain.MaintenanceWindow{}
+	}
+	if policy.AlertMaintenanceWindows == nil {
+		policy.AlertMaintenanceWindows = []domain.MaintenanceWindow{}
+	}
 	policy.UpdatedAt = formatTime(updatedAt)
 	return policy, nil
 }
 
-func (s *PostgresStore) SetPolicy(policy domain.OrchestrationPolicy) error {
-	_, err := s.db.Exec(`
+func (s *PostgresStore) SetPolicy(ctx context.Context, policy domain.OrchestrationPolicy) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	windows := policy.MaintenanceWindows
+	if windows == nil {
+		windows = []domain.MaintenanceWindow{}
+	}
+	encodedWindows, err := json.Marshal(windows)
+	if err != nil {
+		return domain.Internal("failed to encode maintenance windows", err)
+	}
+	alertWindows := policy.AlertMaintenanceWindows
+	if alertWindows == nil {
+		alertWindows = []domain.MaintenanceWindow{}
+	}
+	encodedAlertWindows, err := json.Marshal(alertWindows)
+	if err != nil {
+		return domain.Internal("failed to encode alert maintenance windows", err)
+	}
+	args := []any{policy.KillSwitch, policy.KillSwitchReason, policy.MaxCostPerRunUSD, policy.MaxAttemptsPerRun, policy.MaxTokensPerRun, policy.MaxLatencyPerAttemptMS,
+		string(encodedWindows), policy.ScheduledKillSwitch, policy.ScheduledKillSwitchReason}
+	// Columns from migrations a compatibility-mode server may run ahead of
+	// are set only when they exist; a value they would have to hold is
+	// refused instead of dropped.
+	optional := ""
+	for _, column := range []struct {
+		name  string
+		cast  string
+		value any
+		set   bool
+	}{
+		{name: "max_cost_per_hour_usd", value: policy.MaxCostPerHourUSD, set: policy.MaxCostPerHourUSD != 0},
+		{name: "max_cost_per_day_usd", value: policy.MaxCostPerDayUSD, set: policy.MaxCostPerDayUSD != 0},
+		{name: "alert_maintenance_windows", cast: "::jsonb", value: string(encodedAlertWindows), set: len(alertWindows) > 0},
+	} {
+		if !s.hasColumn(ctx, "orchestration_policy", column.name) {
+			if column.set {
+				return missingColumnError("orchestration_policy", column.name)
+			}
+			continue
+		}
+		args = append(args, column.value)
+		optional += fmt.Sprintf("\n\t\t    %s = $%d%s,", column.name, len(args), column.cast)
+	}
+	_, err = s.db.Exec(ctx, `
 		UPDATE orchestration_policy
 		SET kill_switch = $1,
 		    kill_switch_reason = $2,
@@ -185,21 +630,27 @@ func (s *PostgresStore) SetPolicy(policy domain.OrchestrationPolicy) error {
 		    max_attempts_per_run = $4,
 		    max_tokens_per_run = $5,
 		    max_latency_per_attempt_ms = $6,
+		    maintenance_windows = $7::jsonb,
+		    scheduled_kill_switch = $8,
+		    scheduled_kill_switch_reason = $9,`+optional+`
 		    updated_at = NOW()
 		WHERE policy_id = 1
-	`, policy.KillSwitch, policy.KillSwitchReason, policy.MaxCostPerRunUSD, policy.MaxAttemptsPerRun, policy.MaxTokensPerRun, policy.MaxLatencyPerAttemptMS)
+	`, args...)
 	if err != nil {
 		return domain.Internal("failed to update orchestration policy", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) ListPolicyCaps() ([]domain.PolicyCap, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, provider_type, provider, model,
+func (s *PostgresStore) ListPolicyCaps(ctx context.Context) ([]domain.PolicyCap, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.db.Query(ctx, `
+		SELECT id, project, name, provider_type, provider, model, workflow, agent_id,
 		       max_cost_per_run_usd, max_attempts_per_run, max_tokens_per_run,
 		       max_cost_per_attempt_usd, max_tokens_per_attempt, max_latency_per_attempt_ms,
-		       priority, dry_run, is_active, updated_at
+		       max_cost_per_day_usd, max_cost_per_month_usd,
+		       priority, dry_run, is_active, valid_from, valid_until, kind, routing, updated_at
 		FROM policy_caps
 		ORDER BY priority DESC, id ASC
 	`)
@@ -211,26 +662,49 @@ func (s *PostgresStore) ListPolicyCaps() ([]domain.PolicyCap, error) {
 	items := []domain.PolicyCap{}
 	for rows.Next() {
 		var item domain.PolicyCap
+		var validFrom, validUntil sql.NullTime
+		var routing []byte
 		var updatedAt time.Time
 		if err := rows.Scan(
 			&item.ID,
+			&item.Project,
 			&item.Name,
 			&item.ProviderType,
 			&item.Provider,
 			&item.Model,
+			&item.Workflow,
+			&item.AgentID,
 			&item.MaxCostPerRunUSD,
 			&item.MaxAttemptsPerRun,
 			&item.MaxTokensPerRun,
 			&item.MaxCostPerAttemptUSD,
 			&item.MaxTokensPerAttempt,
 			&item.MaxLatencyPerAttemptMS,
+			&item.MaxCostPerDayUSD,
+			&item.MaxCostPerMonthUSD,
 			&item.Priority,
 			&item.DryRun,
 			&item.IsActive,
+			&validFrom,
+			&validUntil,
+			&item.Kind,
+			&routing,
 			&updatedAt,
 		); err != nil {
 			return nil, domain.Internal("failed to decode policy cap row", err)
 		}
+		if len(routing) > 0 {
+			item.Routing = &domain.RoutingPreference{}
+			if err := json.Unmarshal(routing, item.Routing); err != nil {
+				return nil, domain.Internal("failed to decode policy cap routing", err)
+			}
+		}
+		if validFrom.Valid {
+			item.ValidFrom = validFrom.Time.UTC().Format(time.RFC3339)
+		}
+		if validUntil.Valid {
+			item.ValidUntil = validUntil.Time.UTC().Format(time.RFC3339)
+		}
 		item.UpdatedAt = formatTime(updatedAt)
 		items = append(items, item)
 	}
@@ -240,62 +714,132 @@ func (s *PostgresStore) ListPolicyCaps() ([]domain.PolicyCap, error) {
 	return items, nil
 }
 
-func (s *PostgresStore) UpsertPolicyCap(cap domain.PolicyCap) error {
-	_, err := s.db.Exec(`
+func (s *PostgresStore) UpsertPolicyCap(ctx context.Context, cap domain.PolicyCap) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	var routing any
+	if cap.Routing != nil {
+		encoded, err := json.Marshal(cap.Routing)
+		if err != nil {
+			return domain.Internal("failed to encode policy cap routing", err)
+		}
+		routing = string(encoded)
+	}
+	_, err := s.db.Exec(ctx, `
 		INSERT INTO policy_caps (
 			id, name, provider_type, provider, model,
 			max_cost_per_run_usd, max_attempts_per_run, max_tokens_per_run,
 			max_cost_per_attempt_usd, max_tokens_per_attempt, max_latency_per_attempt_ms,
-			priority, dry_run, is_active, updated_at
+			priority, dry_run, is_active, project,
+			agent_id, max_cost_per_day_usd, max_cost_per_month_usd,
+			valid_from, valid_until, workflow, kind, routing, updated_at
 		) VALUES (
 			$1, $2, $3, $4, $5,
 			$6, $7, $8,
 			$9, $10, $11,
-			$12, $13, $14, NOW()
+			$12, $13, $14, $15,
+			$16, $17, $18,
+			$19, $20, $21, $22, $23::jsonb, NOW()
 		)
 		ON CONFLICT (id) DO UPDATE
 		SET name = EXCLUDED.name,
+		    project = EXCLUDED.project,
 		    provider_type = EXCLUDED.provider_type,
 		    provider = EXCLUDED.provider,
 		    model = EXCLUDED.model,
+		    workflow = EXCLUDED.workflow,
+		    agent_id = EXCLUDED.agent_id,
 		    max_cost_per_run_usd = EXCLUDED.max_cost_per_run_usd,
 		    max_attempts_per_run = EXCLUDED.max_attempts_per_run,
 		    max_tokens_per_run = EXCLUDED.max_tokens_per_run,
 		    max_cost_per_attempt_usd = EXCLUDED.max_cost_per_attempt_usd,
 		    max_tokens_per_attempt = EXCLUDED.max_tokens_per_attempt,
 		    max_latency_per_attempt_ms = EXCLUDED.max_latency_per_attempt_ms,
+		    max_cost_per_day_usd = EXCLUDED.max_cost_per_day_usd,
+		    max_cost_per_month_usd = EXCLUDED.max_cost_per_month_usd,
 		    priority = EXCLUDED.priority,
 		    dry_run = EXCLUDED.dry_run,
 		    is_active = EXCLUDED.is_active,
+		    valid_from = EXCLUDED.valid_from,
+		    valid_until = EXCLUDED.valid_until,
+		    kind = EXCLUDED.kind,
+		    routing = EXCLUDED.routing,
 		    updated_at = NOW()
 	`, cap.ID, cap.Name, cap.ProviderType, cap.Provider, cap.Model,
 		cap.MaxCostPerRunUSD, cap.MaxAttemptsPerRun, cap.MaxTokensPerRun,
 		cap.MaxCostPerAttemptUSD, cap.MaxTokensPerAttempt, cap.MaxLatencyPerAttemptMS,
-		cap.Priority, cap.DryRun, cap.IsActive)
+		cap.Priority, cap.DryRun, cap.IsActive, cap.Project,
+		cap.AgentID, cap.MaxCostPerDayUSD, cap.MaxCostPerMonthUSD,
+		nullableTimestamp(cap.ValidFrom), nullableTimestamp(cap.ValidUntil), cap.Workflow, cap.Kind, routing)
 	if err != nil {
 		return domain.Internal("failed to upsert policy cap", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) DeletePolicyCap(id string) (bool, error) {
-	result, err := s.db.Exec(`DELETE FROM policy_caps WHERE id = $1`, id)
+func (s *PostgresStore) DeletePolicyCap(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	result, err := s.db.Exec(ctx, `DELETE FROM policy_caps WHERE id = $1`, id)
 	if err != nil {
 		return false, domain.Internal("failed to delete policy cap", err)
 	}
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return false, domain.Internal("failed to read policy cap delete result", err)
-	}
+	affected := result.RowsAffected()
 	return affected > 0, nil
 }
 
-func (s *PostgresStore) ListTasks() ([]domain.Task, error) {
-	rows, err := s.db.Query(`
-		SELECT id, title, details, status, tags, created_at, updated_at
+func (s *PostgresStore) CountTasks(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "tasks", "tasks")
+}
+
+func (s *PostgresStore) ListTasks(ctx context.Context) ([]domain.Task, error) {
+	return s.ListTasksFiltered(ctx, domain.TaskFilter{IncludeArchived: true})
+}
+
+func (s *PostgresStore) ListTasksFiltered(ctx context.Context, filter domain.TaskFilter) ([]domain.Task, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, project, title, details, status, tags, created_at, updated_at, external_id
 		FROM tasks
-		ORDER BY updated_at DESC, id DESC
-	`)
+	`
+	args := []any{}
+	conditions := []string{}
+
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("project = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.ExternalID) != "" {
+		args = append(args, filter.ExternalID)
+		conditions = append(conditions, fmt.Sprintf("external_id = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if !filter.IncludeArchived {
+		args = append(args, domain.TaskStatusArchived)
+		conditions = append(conditions, fmt.Sprintf("status <> $%d", len(args)))
+	}
+	if len(filter.Tags) > 0 {
+		args = append(args, filter.Tags)
+		conditions = append(conditions, fmt.Sprintf("tags @> $%d::text[]", len(args)))
+	}
+	if strings.TrimSpace(filter.Query) != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(title ILIKE $%d OR details ILIKE $%d)", len(args), len(args)))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY updated_at DESC, id DESC "
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d ", len(args))
+	}
+
+	rows, err := s.readDB("ListTasksFiltered").Query(ctx, query, args...)
 	if err != nil {
 		return nil, domain.Internal("failed to list tasks", err)
 	}
@@ -308,12 +852,14 @@ func (s *PostgresStore) ListTasks() ([]domain.Task, error) {
 		var updatedAt time.Time
 		if err := rows.Scan(
 			&item.ID,
+			&item.Project,
 			&item.Title,
 			&item.Details,
 			&item.Status,
 			&item.Tags,
 			&createdAt,
 			&updatedAt,
+			&item.ExternalID,
 		); err != nil {
 			return nil, domain.Internal("failed to decode task row", err)
 		}
@@ -327,7 +873,9 @@ func (s *PostgresStore) ListTasks() ([]domain.Task, error) {
 	return items, nil
 }
 
-func (s *PostgresStore) UpsertTask(task domain.Task) error {
+func (s *PostgresStore) UpsertTask(ctx context.Context, task domain.Task) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	createdAt, err := parseTimestamp(task.CreatedAt)
 	if err != nil {
 		return domain.Internal("task created_at is invalid", err)
@@ -337,36 +885,45 @@ func (s *PostgresStore) UpsertTask(task domain.Task) error {
 		return domain.Internal("task updated_at is invalid", err)
 	}
 
-	_, err = s.db.Exec(`
-		INSERT INTO tasks (id, title, details, status, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO tasks (id, title, details, status, tags, created_at, updated_at, project, external_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (id) DO UPDATE
 		SET title = EXCLUDED.title,
 		    details = EXCLUDED.details,
 		    status = EXCLUDED.status,
 		    tags = EXCLUDED.tags,
-		    updated_at = EXCLUDED.updated_at
-	`, task.ID, task.Title, task.Details, task.Status, task.Tags, createdAt, updatedAt)
+		    updated_at = EXCLUDED.updated_at,
+		    external_id = EXCLUDED.external_id
+	`, task.ID, task.Title, task.Details, task.Status, task.Tags, createdAt, updatedAt, task.Project, task.ExternalID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("a task with this external_id already exists in the project")
+	}
 	if err != nil {
 		return domain.Internal("failed to upsert task", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) DeleteTask(id string) (bool, error) {
-	result, err := s.db.Exec(`DELETE FROM tasks WHERE id = $1`, id)
+func (s *PostgresStore) DeleteTask(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	result, err := s.db.Exec(ctx, `DELETE FROM tasks WHERE id = $1`, id)
 	if err != nil {
 		return false, domain.Internal("failed to delete task", err)
 	}
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return false, domain.Internal("failed to read delete result", err)
-	}
+	affected := result.RowsAffected()
 	return affected > 0, nil
 }
 
-func (s *PostgresStore) ListNotes() ([]domain.Note, error) {
-	rows, err := s.db.Query(`
+func (s *PostgresStore) CountNotes(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "notes", "notes")
+}
+
+func (s *PostgresStore) ListNotes(ctx context.Context) ([]domain.Note, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.readDB("ListNotes").Query(ctx, `
 		SELECT id, title, body, tags, created_at
 		FROM notes
 		ORDER BY created_at DESC, id DESC
@@ -392,13 +949,15 @@ func (s *PostgresStore) ListNotes() ([]domain.Note, error) {
 	return items, nil
 }
 
-func (s *PostgresStore) InsertNote(note domain.Note) error {
+func (s *PostgresStore) InsertNote(ctx context.Context, note domain.Note) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	createdAt, err := parseTimestamp(note.CreatedAt)
 	if err != nil {
 		return domain.Internal("note created_at is invalid", err)
 	}
 
-	_, err = s.db.Exec(`
+	_, err = s.db.Exec(ctx, `
 		INSERT INTO notes (id, title, body, tags, created_at)
 		VALUES ($1, $2, $3, $4, $5)
 	`, note.ID, note.Title, note.Body, note.Tags, createdAt)
@@ -408,8 +967,14 @@ func (s *PostgresStore) InsertNote(note domain.Note) error {
 	return nil
 }
 
-func (s *PostgresStore) ListChangelog() ([]domain.ChangelogEntry, error) {
-	rows, err := s.db.Query(`
+func (s *PostgresStore) CountChangelog(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "changelog", "changelog")
+}
+
+func (s *PostgresStore) ListChangelog(ctx context.Context) ([]domain.ChangelogEntry, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.readDB("ListChangelog").Query(ctx, `
 		SELECT id, category, summary, details, actor, created_at
 		FROM changelog
 		ORDER BY created_at DESC, id DESC
@@ -435,13 +1000,15 @@ func (s *PostgresStore) ListChangelog() ([]domain.ChangelogEntry, error) {
 	return items, nil
 }
 
-func (s *PostgresStore) InsertChangelog(entry domain.ChangelogEntry) error {
+func (s *PostgresStore) InsertChangelog(ctx context.Context, entry domain.ChangelogEntry) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	createdAt, err := parseTimestamp(entry.CreatedAt)
 	if err != nil {
 		return domain.Internal("changelog created_at is invalid", err)
 	}
 
-	_, err = s.db.Exec(`
+	_, err = s.db.Exec(ctx, `
 		INSERT INTO changelog (id, category, summary, details, actor, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
 	`, entry.ID, entry.Category, entry.Summary, entry.Details, entry.Actor, createdAt)
@@ -451,8 +1018,10 @@ func (s *PostgresStore) InsertChangelog(entry domain.ChangelogEntry) error {
 	return nil
 }
 
-func (s *PostgresStore) ListBenchmarks() ([]domain.Benchmark, error) {
-	rows, err := s.db.Query(`
+func (s *PostgresStore) ListBenchmarks(ctx context.Context) ([]domain.Benchmark, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	rows, err := s.readDB("ListBenchmarks").Query(ctx, `
 		SELECT id, workflow, provider_type, provider, model,
 		       tokens_in, tokens_out, cost_usd, latency_ms, quality_score, notes, created_at
 		FROM benchmarks
@@ -492,13 +1061,15 @@ func (s *PostgresStore) ListBenchmarks() ([]domain.Benchmark, error) {
 	return items, nil
 }
 
-func (s *PostgresStore) InsertBenchmark(benchmark domain.Benchmark) error {
+func (s *PostgresStore) InsertBenchmark(ctx context.Context, benchmark domain.Benchmark) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	createdAt, err := parseTimestamp(benchmark.CreatedAt)
 	if err != nil {
 		return domain.Internal("benchmark created_at is invalid", err)
 	}
 
-	_, err = s.db.Exec(`
+	_, err = s.db.Exec(ctx, `
 		INSERT INTO benchmarks (
 			id, workflow, provider_type, provider, model,
 			tokens_in, tokens_out, cost_usd, latency_ms, quality_score, notes, created_at
@@ -514,24 +1085,39 @@ func (s *PostgresStore) InsertBenchmark(benchmark domain.Benchmark) error {
 	return nil
 }
 
-func (s *PostgresStore) ListRuns() ([]domain.AgentRun, error) {
-	return s.ListRunsFiltered(domain.RunFilter{})
+func (s *PostgresStore) CountRuns(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "agent_runs", "runs")
+}
+
+func (s *PostgresStore) ListRuns(ctx context.Context) ([]domain.AgentRun, error) {
+	return s.ListRunsFiltered(ctx, domain.RunFilter{})
 }
 
-func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.AgentRun, error) {
+func (s *PostgresStore) ListRunsFiltered(ctx context.Context, filter domain.RunFilter) ([]domain.AgentRun, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	query := `
-		SELECT id, task_id, workflow, agent_id, prompt_version, model_policy, status, max_retries,
+		SELECT id, project, task_id, workflow, agent_id, prompt_version, model_policy, status, max_retries,
 		       total_attempts, success_attempts, failed_attempts, total_tokens_in, total_tokens_out,
-		       total_cost_usd, duration_ms, last_error, started_at, finished_at
+		       total_cost_usd, duration_ms, last_error, metadata, started_at, finished_at, name, description,
+		       starred, starred_at, external_id
 		FROM agent_runs
 	`
 	args := []any{}
 	conditions := []string{}
 
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("project = $%d", len(args)))
+	}
 	if strings.TrimSpace(filter.RunID) != "" {
 		args = append(args, filter.RunID)
 		conditions = append(conditions, fmt.Sprintf("id = $%d", len(args)))
 	}
+	if strings.TrimSpace(filter.ExternalID) != "" {
+		args = append(args, filter.ExternalID)
+		conditions = append(conditions, fmt.Sprintf("external_id = $%d", len(args)))
+	}
 	if strings.TrimSpace(filter.TaskID) != "" {
 		args = append(args, filter.TaskID)
 		conditions = append(conditions, fmt.Sprintf("task_id = $%d", len(args)))
@@ -560,6 +1146,17 @@ func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.Agen
 		args = append(args, filter.StartedBefore)
 		conditions = append(conditions, fmt.Sprintf("started_at <= $%d::timestamptz", len(args)))
 	}
+	if len(filter.Labels) > 0 {
+		labels, err := encodeMetadata(filter.Labels)
+		if err != nil {
+			return nil, domain.Internal("failed to encode run label filter", err)
+		}
+		args = append(args, labels)
+		conditions = append(conditions, fmt.Sprintf("metadata @> $%d::jsonb", len(args)))
+	}
+	if filter.Starred {
+		conditions = append(conditions, "starred")
+	}
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
@@ -569,7 +1166,7 @@ func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.Agen
 		query += fmt.Sprintf(" LIMIT $%d ", len(args))
 	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB("ListRunsFiltered").Query(ctx, query, args...)
 	if err != nil {
 		return nil, domain.Internal("failed to list runs", err)
 	}
@@ -578,10 +1175,12 @@ func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.Agen
 	items := []domain.AgentRun{}
 	for rows.Next() {
 		var item domain.AgentRun
+		var metadata []byte
 		var startedAt time.Time
-		var finishedAt sql.NullTime
+		var finishedAt, starredAt sql.NullTime
 		if err := rows.Scan(
 			&item.ID,
+			&item.Project,
 			&item.TaskID,
 			&item.Workflow,
 			&item.AgentID,
@@ -597,15 +1196,29 @@ func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.Agen
 			&item.TotalCostUSD,
 			&item.DurationMS,
 			&item.LastError,
+			&metadata,
 			&startedAt,
 			&finishedAt,
+			&item.Name,
+			&item.Description,
+			&item.Starred,
+			&starredAt,
+			&item.ExternalID,
 		); err != nil {
 			return nil, domain.Internal("failed to decode run row", err)
 		}
+		decodedMetadata, err := decodeMetadata(metadata)
+		if err != nil {
+			return nil, domain.Internal("failed to decode run metadata", err)
+		}
+		item.Metadata = decodedMetadata
 		item.StartedAt = formatTime(startedAt)
 		if finishedAt.Valid {
 			item.FinishedAt = formatTime(finishedAt.Time)
 		}
+		if starredAt.Valid {
+			item.StarredAt = formatTime(starredAt.Time)
+		}
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
@@ -614,33 +1227,51 @@ func (s *PostgresStore) ListRunsFiltered(filter domain.RunFilter) ([]domain.Agen
 	return items, nil
 }
 
-func (s *PostgresStore) InsertRun(run domain.AgentRun) error {
+func (s *PostgresStore) InsertRun(ctx context.Context, run domain.AgentRun) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	startedAt, err := parseTimestamp(run.StartedAt)
 	if err != nil {
 		return domain.Internal("run started_at is invalid", err)
 	}
+	metadata, err := encodeMetadata(run.Metadata)
+	if err != nil {
+		return domain.Internal("failed to encode run metadata", err)
+	}
 
-	_, err = s.db.Exec(`
+	_, err = s.db.Exec(ctx, `
 		INSERT INTO agent_runs (
 			id, task_id, workflow, agent_id, prompt_version, model_policy, status, max_retries,
 			total_attempts, success_attempts, failed_attempts, total_tokens_in, total_tokens_out,
-			total_cost_usd, duration_ms, last_error, started_at, finished_at
+			total_cost_usd, duration_ms, last_error, metadata, started_at, finished_at, project, name, description,
+			starred, starred_at, external_id
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8,
 			$9, $10, $11, $12, $13,
-			$14, $15, $16, $17, $18
+			$14, $15, $16, $17::jsonb, $18, $19, $20, $21, $22,
+			$23, $24, $25
 		)
 	`, run.ID, run.TaskID, run.Workflow, run.AgentID, run.PromptVersion, run.ModelPolicy, run.Status, run.MaxRetries,
 		run.TotalAttempts, run.SuccessAttempts, run.FailedAttempts, run.TotalTokensIn, run.TotalTokensOut,
-		run.TotalCostUSD, run.DurationMS, run.LastError, startedAt, nullableTimestamp(run.FinishedAt))
+		run.TotalCostUSD, run.DurationMS, run.LastError, metadata, startedAt, nullableTimestamp(run.FinishedAt), run.Project,
+		run.Name, run.Description, run.Starred, nullableTimestamp(run.StarredAt), run.ExternalID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("a run with this external_id already exists in the project")
+	}
 	if err != nil {
 		return domain.Internal("failed to insert run", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) UpdateRun(run domain.AgentRun) error {
-	_, err := s.db.Exec(`
+func (s *PostgresStore) UpdateRun(ctx context.Context, run domain.AgentRun) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	startedAt, err := parseTimestamp(run.StartedAt)
+	if err != nil {
+		return domain.Internal("run started_at is invalid", err)
+	}
+	_, err = s.db.Exec(ctx, `
 		UPDATE agent_runs
 		SET task_id = $2,
 		    workflow = $3,
@@ -657,30 +1288,119 @@ func (s *PostgresStore) UpdateRun(run domain.AgentRun) error {
 		    total_cost_usd = $14,
 		    duration_ms = $15,
 		    last_error = $16,
-		    finished_at = $17
+		    finished_at = $17,
+		    started_at = $18
 		WHERE id = $1
 	`, run.ID, run.TaskID, run.Workflow, run.AgentID, run.PromptVersion, run.ModelPolicy, run.Status, run.MaxRetries,
 		run.TotalAttempts, run.SuccessAttempts, run.FailedAttempts, run.TotalTokensIn, run.TotalTokensOut,
-		run.TotalCostUSD, run.DurationMS, run.LastError, nullableTimestamp(run.FinishedAt))
+		run.TotalCostUSD, run.DurationMS, run.LastError, nullableTimestamp(run.FinishedAt), startedAt)
 	if err != nil {
 		return domain.Internal("failed to update run", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) ListPromptAttempts(runID string) ([]domain.PromptAttempt, error) {
-	return s.ListPromptAttemptsFiltered(domain.AttemptFilter{RunID: runID})
+// SetRunStarred updates only the star columns, so it cannot race the
+// attempt totals UpdateRun and FinalizeRun write.
+func (s *PostgresStore) SetRunStarred(ctx context.Context, run domain.AgentRun) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tag, err := s.db.Exec(ctx, `UPDATE agent_runs SET starred = $2, starred_at = $3 WHERE id = $1`,
+		run.ID, run.Starred, nullableTimestamp(run.StarredAt))
+	if err != nil {
+		return domain.Internal("failed to star run", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.NotFound("run not found")
+	}
+	return nil
 }
 
-func (s *PostgresStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
-	query := `
-		SELECT id, run_id, attempt_number, workflow, agent_id, provider_type, provider, model,
-		       prompt_version, prompt_hash, outcome, error_type, error_message, tokens_in, tokens_out,
-		       cost_usd, latency_ms, quality_score, created_at
-		FROM prompt_attempts
-	`
+// FinalizeRun aggregates the run's attempts inside the UPDATE, so the totals
+// are read and written in one statement instead of shipping every attempt to
+// the service and racing attempts inserted in between.
+// FinalizeRun locks the run row for the update, so attempts inserted
+// concurrently (which hold it FOR SHARE) are either counted or rejected as
+// arriving after the run finished.
+func (s *PostgresStore) FinalizeRun(ctx context.Context, run domain.AgentRun) (domain.AgentRun, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.AgentRun{}, domain.Internal("failed to start run finalization", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	var locked string
+	if err := tx.QueryRow(ctx, `SELECT id FROM agent_runs WHERE id = $1 FOR UPDATE`, run.ID).Scan(&locked); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.AgentRun{}, domain.NotFound("run not found")
+		}
+		return domain.AgentRun{}, domain.Internal("failed to lock run", err)
+	}
+
+	row := tx.QueryRow(ctx, `
+		UPDATE agent_runs AS r
+		SET status = $2,
+		    last_error = $3,
+		    duration_ms = $4,
+		    finished_at = $5,
+		    total_attempts = a.total_attempts,
+		    success_attempts = a.success_attempts,
+		    failed_attempts = a.total_attempts - a.success_attempts,
+		    total_tokens_in = a.total_tokens_in,
+		    total_tokens_out = a.total_tokens_out,
+		    total_cost_usd = a.total_cost_usd
+		FROM (
+			SELECT COUNT(*) AS total_attempts,
+			       COUNT(*) FILTER (WHERE outcome = 'success') AS success_attempts,
+			       COALESCE(SUM(tokens_in), 0) AS total_tokens_in,
+			       COALESCE(SUM(tokens_out), 0) AS total_tokens_out,
+			       COALESCE(SUM(cost_usd), 0) AS total_cost_usd
+			FROM prompt_attempts
+			WHERE run_id = $1
+		) AS a
+		WHERE r.id = $1
+		RETURNING r.total_attempts, r.success_attempts, r.failed_attempts,
+		          r.total_tokens_in, r.total_tokens_out, r.total_cost_usd
+	`, run.ID, run.Status, run.LastError, run.DurationMS, nullableTimestamp(run.FinishedAt))
+	if err := row.Scan(
+		&run.TotalAttempts,
+		&run.SuccessAttempts,
+		&run.FailedAttempts,
+		&run.TotalTokensIn,
+		&run.TotalTokensOut,
+		&run.TotalCostUSD,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.AgentRun{}, domain.NotFound("run not found")
+		}
+		return domain.AgentRun{}, domain.Internal("failed to finalize run", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.AgentRun{}, domain.Internal("failed to commit run finalization", err)
+	}
+	return run, nil
+}
+
+func (s *PostgresStore) CountPromptAttempts(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "prompt_attempts", "prompt attempts")
+}
+
+func (s *PostgresStore) ListPromptAttempts(ctx context.Context, runID string) ([]domain.PromptAttempt, error) {
+	return s.ListPromptAttemptsFiltered(ctx, domain.AttemptFilter{RunID: runID})
+}
+
+// attemptFilterConditions builds the WHERE conditions shared by attempt
+// listing and aggregation; Limit is left to the caller.
+func attemptFilterConditions(filter domain.AttemptFilter) ([]string, []any) {
 	args := []any{}
 	conditions := []string{}
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("project = $%d", len(args)))
+	}
 	if strings.TrimSpace(filter.RunID) != "" {
 		args = append(args, filter.RunID)
 		conditions = append(conditions, fmt.Sprintf("run_id = $%d", len(args)))
@@ -713,48 +1433,174 @@ func (s *PostgresStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter)
 		args = append(args, filter.CreatedBefore)
 		conditions = append(conditions, fmt.Sprintf("created_at <= $%d::timestamptz", len(args)))
 	}
+	return conditions, args
+}
+
+// LeaderboardAggregate groups in SQL so the leaderboard does not load every
+// matching attempt.
+func (s *PostgresStore) LeaderboardAggregate(ctx context.Context, filter domain.AttemptFilter) ([]domain.LeaderboardEntry, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	conditions, args := attemptFilterConditions(filter)
+	query := `
+		SELECT workflow, prompt_version, model, COUNT(*),
+		       COUNT(*) FILTER (WHERE outcome = 'success'),
+		       AVG(cost_usd), AVG(latency_ms)::DOUBLE PRECISION
+		FROM prompt_attempts
+	`
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
-	query += ` ORDER BY created_at DESC, id DESC `
-	if filter.Limit > 0 {
-		args = append(args, filter.Limit)
-		query += fmt.Sprintf(" LIMIT $%d ", len(args))
-	}
+	query += ` GROUP BY workflow, prompt_version, model `
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB("LeaderboardAggregate").Query(ctx, query, args...)
 	if err != nil {
-		return nil, domain.Internal("failed to list prompt attempts", err)
+		return nil, domain.Internal("failed to aggregate leaderboard", err)
 	}
 	defer rows.Close()
 
-	items := []domain.PromptAttempt{}
+	items := []domain.LeaderboardEntry{}
 	for rows.Next() {
-		var item domain.PromptAttempt
-		var createdAt time.Time
+		var item domain.LeaderboardEntry
 		if err := rows.Scan(
-			&item.ID,
-			&item.RunID,
-			&item.AttemptNumber,
 			&item.Workflow,
-			&item.AgentID,
-			&item.ProviderType,
-			&item.Provider,
-			&item.Model,
 			&item.PromptVersion,
-			&item.PromptHash,
-			&item.Outcome,
-			&item.ErrorType,
-			&item.ErrorMessage,
-			&item.TokensIn,
-			&item.TokensOut,
+			&item.Model,
+			&item.Attempts,
+			&item.SuccessAttempts,
+			&item.AverageCostUSD,
+			&item.AverageLatencyMS,
+		); err != nil {
+			return nil, domain.Internal("failed to scan leaderboard row", err)
+		}
+		item.FailedAttempts = item.Attempts - item.SuccessAttempts
+		item.SuccessRate = float64(item.SuccessAttempts) / float64(item.Attempts)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate leaderboard rows", err)
+	}
+	return items, nil
+}
+
+// AttemptTimeSeries buckets in SQL, like LeaderboardAggregate.
+func (s *PostgresStore) AttemptTimeSeries(ctx context.Context, filter domain.AttemptFilter, bucket time.Duration) ([]domain.AttemptBucket, error) {
+	if bucket < time.Second {
+		return nil, domain.InvalidArgument("bucket must be at least one second")
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	conditions, args := attemptFilterConditions(filter)
+	args = append(args, int64(bucket/time.Second))
+	width := fmt.Sprintf("$%d::BIGINT", len(args))
+	query := `
+		SELECT to_timestamp(floor(extract(epoch FROM created_at) / ` + width + `) * ` + width + `) AS bucket,
+		       workflow, model, COUNT(*),
+		       COUNT(*) FILTER (WHERE outcome = 'success'),
+		       COALESCE(SUM(cost_usd), 0)
+		FROM prompt_attempts
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += ` GROUP BY bucket, workflow, model ORDER BY bucket `
+
+	rows, err := s.readDB("AttemptTimeSeries").Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to bucket prompt attempts", err)
+	}
+	defer rows.Close()
+
+	items := []domain.AttemptBucket{}
+	for rows.Next() {
+		var item domain.AttemptBucket
+		var start time.Time
+		if err := rows.Scan(&start, &item.Workflow, &item.Model, &item.Attempts, &item.SuccessAttempts, &item.CostUSD); err != nil {
+			return nil, domain.Internal("failed to scan attempt bucket", err)
+		}
+		item.Start = start.UTC().Format(time.RFC3339)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate attempt buckets", err)
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	if filter.IncludeArchived {
+		if err := s.requireArchive(ctx); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return listPromptAttempts(ctx, s.readDB("ListPromptAttemptsFiltered"), filter)
+}
+
+// queryer is the read side shared by *pgxpool.Pool and pgx.Tx.
+type queryer interface {
+	Query(ctx context.Context, query string, args ...any) (pgx.Rows, error)
+}
+
+func listPromptAttempts(ctx context.Context, db queryer, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	query := `
+		SELECT id, project, run_id, attempt_number, workflow, agent_id, provider_type, provider, model,
+		       prompt_version, prompt_hash, outcome, error_type, error_message, tokens_in, tokens_out,
+		       cost_usd, latency_ms, quality_score, metadata, created_at
+		FROM ` + attemptsSource(filter.IncludeArchived) + `
+	`
+	conditions, args := attemptFilterConditions(filter)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id DESC `
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d ", len(args))
+	}
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to list prompt attempts", err)
+	}
+	defer rows.Close()
+
+	items := []domain.PromptAttempt{}
+	for rows.Next() {
+		var item domain.PromptAttempt
+		var metadata []byte
+		var createdAt time.Time
+		if err := rows.Scan(
+			&item.ID,
+			&item.Project,
+			&item.RunID,
+			&item.AttemptNumber,
+			&item.Workflow,
+			&item.AgentID,
+			&item.ProviderType,
+			&item.Provider,
+			&item.Model,
+			&item.PromptVersion,
+			&item.PromptHash,
+			&item.Outcome,
+			&item.ErrorType,
+			&item.ErrorMessage,
+			&item.TokensIn,
+			&item.TokensOut,
 			&item.CostUSD,
 			&item.LatencyMS,
 			&item.QualityScore,
+			&metadata,
 			&createdAt,
 		); err != nil {
 			return nil, domain.Internal("failed to decode prompt attempt row", err)
 		}
+		decodedMetadata, err := decodeMetadata(metadata)
+		if err != nil {
+			return nil, domain.Internal("failed to decode prompt attempt metadata", err)
+		}
+		item.Metadata = decodedMetadata
 		item.CreatedAt = formatTime(createdAt)
 		items = append(items, item)
 	}
@@ -764,42 +1610,287 @@ func (s *PostgresStore) ListPromptAttemptsFiltered(filter domain.AttemptFilter)
 	return items, nil
 }
 
-func (s *PostgresStore) InsertPromptAttempt(attempt domain.PromptAttempt) error {
-	createdAt, err := parseTimestamp(attempt.CreatedAt)
-	if err != nil {
-		return domain.Internal("prompt attempt created_at is invalid", err)
-	}
+// promptAttemptColumns is the column order of insertPromptAttemptSQL and
+// promptAttemptArgs.
+var promptAttemptColumns = []string{
+	"id", "run_id", "attempt_number", "workflow", "agent_id", "provider_type", "provider", "model",
+	"prompt_version", "prompt_hash", "outcome", "error_type", "error_message", "tokens_in", "tokens_out",
+	"cost_usd", "latency_ms", "quality_score", "metadata", "created_at", "project",
+}
 
-	_, err = s.db.Exec(`
+const insertPromptAttemptSQL = `
 		INSERT INTO prompt_attempts (
 			id, run_id, attempt_number, workflow, agent_id, provider_type, provider, model,
 			prompt_version, prompt_hash, outcome, error_type, error_message, tokens_in, tokens_out,
-			cost_usd, latency_ms, quality_score, created_at
+			cost_usd, latency_ms, quality_score, metadata, created_at, project
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8,
 			$9, $10, $11, $12, $13, $14, $15,
-			$16, $17, $18, $19
+			$16, $17, $18, $19::jsonb, $20, $21
 		)
-	`, attempt.ID, attempt.RunID, attempt.AttemptNumber, attempt.Workflow, attempt.AgentID, attempt.ProviderType, attempt.Provider, attempt.Model,
+	`
+
+func promptAttemptArgs(attempt domain.PromptAttempt) ([]any, error) {
+	createdAt, err := parseTimestamp(attempt.CreatedAt)
+	if err != nil {
+		return nil, domain.Internal("prompt attempt created_at is invalid", err)
+	}
+	metadata, err := encodeMetadata(attempt.Metadata)
+	if err != nil {
+		return nil, domain.Internal("failed to encode prompt attempt metadata", err)
+	}
+	return []any{attempt.ID, attempt.RunID, attempt.AttemptNumber, attempt.Workflow, attempt.AgentID, attempt.ProviderType, attempt.Provider, attempt.Model,
 		attempt.PromptVersion, attempt.PromptHash, attempt.Outcome, attempt.ErrorType, attempt.ErrorMessage, attempt.TokensIn, attempt.TokensOut,
-		attempt.CostUSD, attempt.LatencyMS, attempt.QualityScore, createdAt)
+		attempt.CostUSD, attempt.LatencyMS, attempt.QualityScore, metadata, createdAt, attempt.Project}, nil
+}
+
+func (s *PostgresStore) InsertPromptAttempt(ctx context.Context, attempt domain.PromptAttempt) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	args, err := promptAttemptArgs(attempt)
 	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to start prompt attempt insert", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if err := lockRunsForAttempts(ctx, tx, []string{attempt.RunID}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, insertPromptAttemptSQL, args...); err != nil {
 		return domain.Internal("failed to insert prompt attempt", err)
 	}
+	if err := s.addRollups(ctx, tx, attempt); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit prompt attempt", err)
+	}
+	s.countAttempts(attempt)
+	return nil
+}
+
+// InsertPromptAttemptGuarded takes the hub and agent advisory locks named by
+// locks, in that order, then the run row FOR UPDATE, and runs check against
+// the attempts visible in the transaction before inserting. Every guarded
+// insert locks in the same order, so they cannot deadlock.
+func (s *PostgresStore) InsertPromptAttemptGuarded(ctx context.Context, attempt domain.PromptAttempt, locks AttemptLocks, check func(AttemptReader) error) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	args, err := promptAttemptArgs(attempt)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to start prompt attempt insert", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	lockKeys := []string{}
+	if locks.Hub {
+		lockKeys = append(lockKeys, "modeloman:attempt-budget:hub")
+	}
+	if locks.AgentID != "" {
+		lockKeys = append(lockKeys, "modeloman:attempt-budget:agent:"+locks.AgentID)
+	}
+	for _, key := range lockKeys {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, key); err != nil {
+			return domain.Internal("failed to lock attempt budget", err)
+		}
+	}
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM agent_runs WHERE id = $1 FOR UPDATE`, attempt.RunID).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.NotFound("run not found")
+		}
+		return domain.Internal("failed to lock run", err)
+	}
+	if status != "running" {
+		return domain.FailedPrecondition("run is not in running state")
+	}
+	if err := check(txAttemptReader{ctx: ctx, tx: tx}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, insertPromptAttemptSQL, args...); err != nil {
+		return domain.Internal("failed to insert prompt attempt", err)
+	}
+	if err := s.addRollups(ctx, tx, attempt); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit prompt attempt", err)
+	}
+	s.countAttempts(attempt)
+	return nil
+}
+
+// txAttemptReader lists attempts inside a guarded insert's transaction.
+type txAttemptReader struct {
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+// ListPromptAttemptsFiltered reads under the transaction's context, which
+// already derives from the caller's.
+func (r txAttemptReader) ListPromptAttemptsFiltered(_ context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	return listPromptAttempts(r.ctx, r.tx, filter)
+}
+
+// InsertPromptAttempts copies attempts in one transaction; one bad row, or
+// an attempt for a run that is no longer running, rolls back the batch.
+func (s *PostgresStore) InsertPromptAttempts(ctx context.Context, attempts []domain.PromptAttempt) error {
+	lockRuns := func(ctx context.Context, tx pgx.Tx) error {
+		runIDs := make([]string, 0, len(attempts))
+		for _, attempt := range attempts {
+			runIDs = append(runIDs, attempt.RunID)
+		}
+		if err := lockRunsForAttempts(ctx, tx, runIDs); err != nil {
+			return err
+		}
+		return s.addRollups(ctx, tx, attempts...)
+	}
+	if err := s.copyRows(ctx, "prompt_attempts", "prompt attempts", promptAttemptColumns, len(attempts), lockRuns, func(i int) ([]any, error) {
+		return promptAttemptArgs(attempts[i])
+	}); err != nil {
+		return err
+	}
+	s.countAttempts(attempts...)
+	return nil
+}
+
+// lockRunsForAttempts takes the runs' rows FOR SHARE until the transaction
+// ends, so FinalizeRun (FOR UPDATE) waits for the insert, and fails unless
+// every run exists and is still running.
+func lockRunsForAttempts(ctx context.Context, tx pgx.Tx, runIDs []string) error {
+	slices.Sort(runIDs)
+	runIDs = slices.Compact(runIDs)
+	rows, err := tx.Query(ctx, `SELECT id, status FROM agent_runs WHERE id = ANY($1) ORDER BY id FOR SHARE`, runIDs)
+	if err != nil {
+		return domain.Internal("failed to lock runs", err)
+	}
+	defer rows.Close()
+	found := 0
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return domain.Internal("failed to lock runs", err)
+		}
+		if status != "running" {
+			return domain.FailedPrecondition("run is not in running state")
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return domain.Internal("failed to lock runs", err)
+	}
+	if found != len(runIDs) {
+		return domain.NotFound("run not found")
+	}
+	return nil
+}
+
+// copyRows writes rows into table with COPY inside a transaction, after
+// before (when set) has run in it.
+func (s *PostgresStore) copyRows(ctx context.Context, table, label string, columns []string, count int, before func(context.Context, pgx.Tx) error, row func(i int) ([]any, error)) error {
+	if count == 0 {
+		return nil
+	}
+	rows := make([][]any, 0, count)
+	for i := 0; i < count; i++ {
+		values, err := row(i)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, values)
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return domain.Internal("failed to start "+label+" batch", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if before != nil {
+		if err := before(ctx, tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return domain.Internal("failed to copy "+label, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.Internal("failed to commit "+label+" batch", err)
+	}
 	return nil
 }
 
-func (s *PostgresStore) ListRunEvents(runID string) ([]domain.RunEvent, error) {
-	return s.ListRunEventsFiltered(domain.EventFilter{RunID: runID})
+func (s *PostgresStore) CountRunEvents(ctx context.Context) (int, error) {
+	return s.countRows(ctx, "run_events", "run events")
+}
+
+func (s *PostgresStore) ListRunEvents(ctx context.Context, runID string) ([]domain.RunEvent, error) {
+	return s.ListRunEventsFiltered(ctx, domain.EventFilter{RunID: runID})
 }
 
-func (s *PostgresStore) ListRunEventsFiltered(filter domain.EventFilter) ([]domain.RunEvent, error) {
+func (s *PostgresStore) LastRunActivity(ctx context.Context, runIDs []string) (map[string]string, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	out := map[string]string{}
+	if len(runIDs) == 0 {
+		return out, nil
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT run_id, MAX(created_at) FROM (
+			SELECT run_id, MAX(created_at) AS created_at FROM run_events WHERE run_id = ANY($1) GROUP BY run_id
+			UNION ALL
+			SELECT run_id, MAX(created_at) AS created_at FROM prompt_attempts WHERE run_id = ANY($1) GROUP BY run_id
+		) activity
+		GROUP BY run_id
+	`, runIDs)
+	if err != nil {
+		return nil, domain.Internal("failed to read run activity", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var runID string
+		var latest time.Time
+		if err := rows.Scan(&runID, &latest); err != nil {
+			return nil, domain.Internal("failed to decode run activity row", err)
+		}
+		out[runID] = formatTime(latest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate run activity rows", err)
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) ListRunEventsFiltered(ctx context.Context, filter domain.EventFilter) ([]domain.RunEvent, error) {
+	if filter.IncludeArchived {
+		if err := s.requireArchive(ctx); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	query := `
-		SELECT id, run_id, event_type, level, message, data_json, created_at
-		FROM run_events
+		SELECT id, run_id, event_type, level, message, data_json, request_id, created_at
+		FROM ` + eventsSource(filter.IncludeArchived) + `
 	`
 	args := []any{}
 	conditions := []string{}
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("run_id IN (SELECT id FROM agent_runs WHERE project = $%d)", len(args)))
+	}
 	if strings.TrimSpace(filter.RunID) != "" {
 		args = append(args, filter.RunID)
 		conditions = append(conditions, fmt.Sprintf("run_id = $%d", len(args)))
@@ -829,7 +1920,7 @@ func (s *PostgresStore) ListRunEventsFiltered(filter domain.EventFilter) ([]doma
 		query += fmt.Sprintf(" LIMIT $%d ", len(args))
 	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB("ListRunEventsFiltered").Query(ctx, query, args...)
 	if err != nil {
 		return nil, domain.Internal("failed to list run events", err)
 	}
@@ -846,6 +1937,7 @@ func (s *PostgresStore) ListRunEventsFiltered(filter domain.EventFilter) ([]doma
 			&item.Level,
 			&item.Message,
 			&item.DataJSON,
+			&item.RequestID,
 			&createdAt,
 		); err != nil {
 			return nil, domain.Internal("failed to decode run event row", err)
@@ -859,29 +1951,307 @@ func (s *PostgresStore) ListRunEventsFiltered(filter domain.EventFilter) ([]doma
 	return items, nil
 }
 
-func (s *PostgresStore) InsertRunEvent(event domain.RunEvent) error {
+// runEventColumns is the column order of insertRunEventSQL and runEventArgs.
+var runEventColumns = []string{"id", "run_id", "event_type", "level", "message", "data_json", "request_id", "created_at"}
+
+const insertRunEventSQL = `
+		INSERT INTO run_events (id, run_id, event_type, level, message, data_json, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+func runEventArgs(event domain.RunEvent) ([]any, error) {
 	createdAt, err := parseTimestamp(event.CreatedAt)
 	if err != nil {
-		return domain.Internal("run event created_at is invalid", err)
+		return nil, domain.Internal("run event created_at is invalid", err)
 	}
+	return []any{event.ID, event.RunID, event.EventType, event.Level, event.Message, event.DataJSON, event.RequestID, createdAt}, nil
+}
 
-	_, err = s.db.Exec(`
-		INSERT INTO run_events (id, run_id, event_type, level, message, data_json, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, event.ID, event.RunID, event.EventType, event.Level, event.Message, event.DataJSON, createdAt)
+func (s *PostgresStore) InsertRunEvent(ctx context.Context, event domain.RunEvent) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	args, err := runEventArgs(event)
 	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(ctx, insertRunEventSQL, args...); err != nil {
 		return domain.Internal("failed to insert run event", err)
 	}
 	return nil
 }
 
-func (s *PostgresStore) AuthenticateAgentKey(rawKey string) (AgentPrincipal, bool, error) {
+func (s *PostgresStore) InsertRunEvents(ctx context.Context, events []domain.RunEvent) error {
+	return s.copyRows(ctx, "run_events", "run events", runEventColumns, len(events), nil, func(i int) ([]any, error) {
+		return runEventArgs(events[i])
+	})
+}
+
+func (s *PostgresStore) ListArtifacts(ctx context.Context, filter domain.ArtifactFilter) ([]domain.Artifact, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, run_id, name, kind, content_type, size_bytes, sha256, created_at
+		FROM artifacts
+	`
+	args := []any{}
+	conditions := []string{}
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("run_id IN (SELECT id FROM agent_runs WHERE project = $%d)", len(args)))
+	}
+	if strings.TrimSpace(filter.ID) != "" {
+		args = append(args, filter.ID)
+		conditions = append(conditions, fmt.Sprintf("id = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.RunID) != "" {
+		args = append(args, filter.RunID)
+		conditions = append(conditions, fmt.Sprintf("run_id = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.Kind) != "" {
+		args = append(args, filter.Kind)
+		conditions = append(conditions, fmt.Sprintf("kind = $%d", len(args)))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC "
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d ", len(args))
+	}
+
+	rows, err := s.readDB("ListArtifacts").Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to list artifacts", err)
+	}
+	defer rows.Close()
+
+	items := []domain.Artifact{}
+	for rows.Next() {
+		var item domain.Artifact
+		var createdAt time.Time
+		if err := rows.Scan(
+			&item.ID,
+			&item.RunID,
+			&item.Name,
+			&item.Kind,
+			&item.ContentType,
+			&item.SizeBytes,
+			&item.SHA256,
+			&createdAt,
+		); err != nil {
+			return nil, domain.Internal("failed to decode artifact row", err)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate artifact rows", err)
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) InsertArtifact(ctx context.Context, artifact domain.Artifact) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	createdAt, err := parseTimestamp(artifact.CreatedAt)
+	if err != nil {
+		return domain.Internal("artifact created_at is invalid", err)
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO artifacts (id, run_id, name, kind, content_type, size_bytes, sha256, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, artifact.ID, artifact.RunID, artifact.Name, artifact.Kind, artifact.ContentType, artifact.SizeBytes, artifact.SHA256, createdAt)
+	if err != nil {
+		return domain.Internal("failed to insert artifact", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListPromptReleases(ctx context.Context, filter domain.PromptReleaseFilter) ([]domain.PromptRelease, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, project, workflow, prompt_version, previous_version,
+		       canary_version, canary_percent, canary_margin, canary_min_runs,
+		       action, actor, reason, created_at
+		FROM prompt_releases
+	`
+	args := []any{}
+	conditions := []string{}
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("project = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.Workflow) != "" {
+		args = append(args, filter.Workflow)
+		conditions = append(conditions, fmt.Sprintf("workflow = $%d", len(args)))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC "
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d ", len(args))
+	}
+
+	rows, err := s.readDB("ListPromptReleases").Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to list prompt releases", err)
+	}
+	defer rows.Close()
+
+	items := []domain.PromptRelease{}
+	for rows.Next() {
+		var item domain.PromptRelease
+		var createdAt time.Time
+		if err := rows.Scan(
+			&item.ID,
+			&item.Project,
+			&item.Workflow,
+			&item.PromptVersion,
+			&item.PreviousVersion,
+			&item.CanaryVersion,
+			&item.CanaryPercent,
+			&item.CanaryMargin,
+			&item.CanaryMinRuns,
+			&item.Action,
+			&item.Actor,
+			&item.Reason,
+			&createdAt,
+		); err != nil {
+			return nil, domain.Internal("failed to decode prompt release row", err)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate prompt release rows", err)
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) InsertPromptRelease(ctx context.Context, release domain.PromptRelease) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	createdAt, err := parseTimestamp(release.CreatedAt)
+	if err != nil {
+		return domain.Internal("prompt release created_at is invalid", err)
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO prompt_releases (
+			id, project, workflow, prompt_version, previous_version,
+			canary_version, canary_percent, canary_margin, canary_min_runs,
+			action, actor, reason, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, release.ID, release.Project, release.Workflow, release.PromptVersion, release.PreviousVersion,
+		release.CanaryVersion, release.CanaryPercent, release.CanaryMargin, release.CanaryMinRuns,
+		release.Action, release.Actor, release.Reason, createdAt)
+	if err != nil {
+		return domain.Internal("failed to insert prompt release", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListPolicyAudit(ctx context.Context, filter domain.PolicyAuditFilter) ([]domain.PolicyAuditEntry, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	query := `
+		SELECT id, project, target_type, target_id, action, actor_agent_id, actor_key_id,
+		       before_json, after_json, created_at
+		FROM policy_audit
+	`
+	args := []any{}
+	conditions := []string{}
+	if strings.TrimSpace(filter.Project) != "" {
+		args = append(args, filter.Project)
+		conditions = append(conditions, fmt.Sprintf("project = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.TargetType) != "" {
+		args = append(args, filter.TargetType)
+		conditions = append(conditions, fmt.Sprintf("target_type = $%d", len(args)))
+	}
+	if strings.TrimSpace(filter.TargetID) != "" {
+		args = append(args, filter.TargetID)
+		conditions = append(conditions, fmt.Sprintf("target_id = $%d", len(args)))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC "
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d ", len(args))
+	}
+
+	rows, err := s.readDB("ListPolicyAudit").Query(ctx, query, args...)
+	if err != nil {
+		return nil, domain.Internal("failed to list policy audit", err)
+	}
+	defer rows.Close()
+
+	items := []domain.PolicyAuditEntry{}
+	for rows.Next() {
+		var item domain.PolicyAuditEntry
+		var createdAt time.Time
+		if err := rows.Scan(
+			&item.ID,
+			&item.Project,
+			&item.TargetType,
+			&item.TargetID,
+			&item.Action,
+			&item.ActorAgentID,
+			&item.ActorKeyID,
+			&item.BeforeJSON,
+			&item.AfterJSON,
+			&createdAt,
+		); err != nil {
+			return nil, domain.Internal("failed to decode policy audit row", err)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.Internal("failed to iterate policy audit rows", err)
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) InsertPolicyAudit(ctx context.Context, entry domain.PolicyAuditEntry) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	createdAt, err := parseTimestamp(entry.CreatedAt)
+	if err != nil {
+		return domain.Internal("policy audit created_at is invalid", err)
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO policy_audit (
+			id, project, target_type, target_id, action, actor_agent_id, actor_key_id,
+			before_json, after_json, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, entry.ID, entry.Project, entry.TargetType, entry.TargetID, entry.Action, entry.ActorAgentID, entry.ActorKeyID,
+		entry.BeforeJSON, entry.AfterJSON, createdAt)
+	if err != nil {
+		return domain.Internal("failed to insert policy audit entry", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) AuthenticateAgentKey(ctx context.Context, rawKey string) (AgentPrincipal, bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	hash := hashAPIKey(rawKey)
 	if hash == "" {
 		return AgentPrincipal{}, false, nil
 	}
 
-	row := s.db.QueryRow(`
+	row := s.db.QueryRow(ctx, `
 		SELECT agent_id, key_id, scopes
 		FROM agent_api_keys
 		WHERE key_hash = $1
@@ -892,20 +2262,22 @@ func (s *PostgresStore) AuthenticateAgentKey(rawKey string) (AgentPrincipal, boo
 
 	var principal AgentPrincipal
 	if err := row.Scan(&principal.AgentID, &principal.KeyID, &principal.Scopes); err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return AgentPrincipal{}, false, nil
 		}
 		return AgentPrincipal{}, false, domain.Internal("failed to validate api key", err)
 	}
 
-	if _, err := s.db.Exec(`UPDATE agent_api_keys SET last_used_at = NOW() WHERE key_id = $1`, principal.KeyID); err != nil {
+	if _, err := s.db.Exec(ctx, `UPDATE agent_api_keys SET last_used_at = NOW() WHERE key_id = $1`, principal.KeyID); err != nil {
 		return AgentPrincipal{}, false, domain.Internal("failed to update api key last_used_at", err)
 	}
 
 	return principal, true, nil
 }
 
-func (s *PostgresStore) EnsureAgentKey(agentID, rawKey string) (string, bool, error) {
+func (s *PostgresStore) EnsureAgentKey(ctx context.Context, agentID, rawKey string) (string, bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	cleanAgentID := strings.TrimSpace(agentID)
 	if cleanAgentID == "" {
 		return "", false, domain.InvalidArgument("agentID is required")
@@ -916,16 +2288,16 @@ func (s *PostgresStore) EnsureAgentKey(agentID, rawKey string) (string, bool, er
 	}
 
 	var existingKeyID string
-	err := s.db.QueryRow(`SELECT key_id FROM agent_api_keys WHERE key_hash = $1`, hash).Scan(&existingKeyID)
+	err := s.db.QueryRow(ctx, `SELECT key_id FROM agent_api_keys WHERE key_hash = $1`, hash).Scan(&existingKeyID)
 	if err == nil {
 		return existingKeyID, false, nil
 	}
-	if err != sql.ErrNoRows {
+	if !errors.Is(err, pgx.ErrNoRows) {
 		return "", false, domain.Internal("failed to query existing api key", err)
 	}
 
 	keyID := newKeyID(cleanAgentID)
-	_, err = s.db.Exec(`
+	_, err = s.db.Exec(ctx, `
 		INSERT INTO agent_api_keys (
 			agent_id, key_id, key_hash, is_active, created_at, last_used_at
 		) VALUES ($1, $2, $3, TRUE, NOW(), NULL)
@@ -936,7 +2308,9 @@ func (s *PostgresStore) EnsureAgentKey(agentID, rawKey string) (string, bool, er
 	return keyID, true, nil
 }
 
-func (s *PostgresStore) ReserveIdempotencyKey(method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error) {
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error) {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	method = strings.TrimSpace(method)
 	idempotencyKey = strings.TrimSpace(idempotencyKey)
 	requestHash = strings.TrimSpace(requestHash)
@@ -944,7 +2318,7 @@ func (s *PostgresStore) ReserveIdempotencyKey(method, idempotencyKey, requestHas
 		return IdempotencyRecord{}, false, domain.InvalidArgument("method, idempotency_key, and request_hash are required")
 	}
 
-	result, err := s.db.Exec(`
+	result, err := s.db.Exec(ctx, `
 		INSERT INTO idempotency_keys (method, idempotency_key, request_hash, response_json, created_at, completed_at)
 		VALUES ($1, $2, $3, '', NOW(), NULL)
 		ON CONFLICT (method, idempotency_key) DO NOTHING
@@ -952,21 +2326,18 @@ func (s *PostgresStore) ReserveIdempotencyKey(method, idempotencyKey, requestHas
 	if err != nil {
 		return IdempotencyRecord{}, false, domain.Internal("failed to reserve idempotency key", err)
 	}
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return IdempotencyRecord{}, false, domain.Internal("failed to read idempotency key reserve result", err)
-	}
+	affected := result.RowsAffected()
 	if affected > 0 {
 		return IdempotencyRecord{}, true, nil
 	}
 
 	var record IdempotencyRecord
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRow(ctx, `
 		SELECT request_hash, response_json, completed_at IS NOT NULL
 		FROM idempotency_keys
 		WHERE method = $1 AND idempotency_key = $2
 	`, method, idempotencyKey).Scan(&record.RequestHash, &record.ResponseJSON, &record.Completed); err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return IdempotencyRecord{}, false, domain.NotFound("idempotency key was not found after reserve conflict")
 		}
 		return IdempotencyRecord{}, false, domain.Internal("failed to read existing idempotency key", err)
@@ -974,14 +2345,16 @@ func (s *PostgresStore) ReserveIdempotencyKey(method, idempotencyKey, requestHas
 	return record, false, nil
 }
 
-func (s *PostgresStore) CompleteIdempotencyKey(method, idempotencyKey, responseJSON string) error {
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, method, idempotencyKey, responseJSON string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	method = strings.TrimSpace(method)
 	idempotencyKey = strings.TrimSpace(idempotencyKey)
 	if method == "" || idempotencyKey == "" {
 		return domain.InvalidArgument("method and idempotency_key are required")
 	}
 
-	result, err := s.db.Exec(`
+	result, err := s.db.Exec(ctx, `
 		UPDATE idempotency_keys
 		SET response_json = $3,
 		    completed_at = NOW()
@@ -992,21 +2365,18 @@ func (s *PostgresStore) CompleteIdempotencyKey(method, idempotencyKey, responseJ
 	if err != nil {
 		return domain.Internal("failed to complete idempotency key", err)
 	}
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return domain.Internal("failed to read idempotency completion result", err)
-	}
+	affected := result.RowsAffected()
 	if affected > 0 {
 		return nil
 	}
 
 	var completed bool
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRow(ctx, `
 		SELECT completed_at IS NOT NULL
 		FROM idempotency_keys
 		WHERE method = $1 AND idempotency_key = $2
 	`, method, idempotencyKey).Scan(&completed); err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return domain.NotFound("idempotency key not found")
 		}
 		return domain.Internal("failed to verify idempotency completion", err)
@@ -1017,14 +2387,16 @@ func (s *PostgresStore) CompleteIdempotencyKey(method, idempotencyKey, responseJ
 	return domain.Internal("idempotency key completion did not apply", nil)
 }
 
-func (s *PostgresStore) ReleaseIdempotencyKey(method, idempotencyKey string) error {
+func (s *PostgresStore) ReleaseIdempotencyKey(ctx context.Context, method, idempotencyKey string) error {
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
 	method = strings.TrimSpace(method)
 	idempotencyKey = strings.TrimSpace(idempotencyKey)
 	if method == "" || idempotencyKey == "" {
 		return nil
 	}
 
-	if _, err := s.db.Exec(`
+	if _, err := s.db.Exec(ctx, `
 		DELETE FROM idempotency_keys
 		WHERE method = $1
 		  AND idempotency_key = $2
@@ -1036,6 +2408,8 @@ func (s *PostgresStore) ReleaseIdempotencyKey(method, idempotencyKey string) err
 }
 
 func (s *PostgresStore) ensureSchema() error {
+	ctx, cancel := s.queryContext(context.Background())
+	defer cancel()
 	statements := []string{
 		`CREATE EXTENSION IF NOT EXISTS timescaledb`,
 		`CREATE TABLE IF NOT EXISTS tasks (
@@ -1094,6 +2468,7 @@ func (s *PostgresStore) ensureSchema() error {
 			total_cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
 			duration_ms BIGINT NOT NULL DEFAULT 0,
 			last_error TEXT NOT NULL DEFAULT '',
+			metadata JSONB NOT NULL DEFAULT '{}'::JSONB,
 			started_at TIMESTAMPTZ NOT NULL,
 			finished_at TIMESTAMPTZ NULL
 		)`,
@@ -1116,6 +2491,7 @@ func (s *PostgresStore) ensureSchema() error {
 			cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
 			latency_ms BIGINT NOT NULL DEFAULT 0,
 			quality_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			metadata JSONB NOT NULL DEFAULT '{}'::JSONB,
 			created_at TIMESTAMPTZ NOT NULL,
 			PRIMARY KEY (id, created_at)
 		)`,
@@ -1158,6 +2534,11 @@ func (s *PostgresStore) ensureSchema() error {
 			max_attempts_per_run BIGINT NOT NULL DEFAULT 0,
 			max_tokens_per_run BIGINT NOT NULL DEFAULT 0,
 			max_latency_per_attempt_ms BIGINT NOT NULL DEFAULT 0,
+			max_cost_per_hour_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+			max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+			maintenance_windows JSONB NOT NULL DEFAULT '[]'::jsonb,
+			scheduled_kill_switch BOOLEAN NOT NULL DEFAULT FALSE,
+			scheduled_kill_switch_reason TEXT NOT NULL DEFAULT '',
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 		`INSERT INTO orchestration_policy (
@@ -1170,22 +2551,132 @@ func (s *PostgresStore) ensureSchema() error {
 			provider_type TEXT NOT NULL DEFAULT '',
 			provider TEXT NOT NULL DEFAULT '',
 			model TEXT NOT NULL DEFAULT '',
+			workflow TEXT NOT NULL DEFAULT '',
 			max_cost_per_run_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
 			max_attempts_per_run BIGINT NOT NULL DEFAULT 0,
 			max_tokens_per_run BIGINT NOT NULL DEFAULT 0,
 			max_cost_per_attempt_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
 			max_tokens_per_attempt BIGINT NOT NULL DEFAULT 0,
 			max_latency_per_attempt_ms BIGINT NOT NULL DEFAULT 0,
+			agent_id TEXT NOT NULL DEFAULT '',
+			max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+			max_cost_per_month_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
 			priority BIGINT NOT NULL DEFAULT 0,
 			dry_run BOOLEAN NOT NULL DEFAULT FALSE,
 			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			valid_from TIMESTAMPTZ NULL,
+			valid_until TIMESTAMPTZ NULL,
+			kind TEXT NOT NULL DEFAULT 'limit',
+			routing JSONB NULL,
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS artifacts (
+			id TEXT PRIMARY KEY,
+			run_id TEXT NOT NULL REFERENCES agent_runs(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			content_type TEXT NOT NULL DEFAULT '',
+			size_bytes BIGINT NOT NULL DEFAULT 0,
+			sha256 TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS prompt_releases (
+			id TEXT PRIMARY KEY,
+			project TEXT NOT NULL DEFAULT 'default',
+			workflow TEXT NOT NULL,
+			prompt_version TEXT NOT NULL,
+			previous_version TEXT NOT NULL DEFAULT '',
+			canary_version TEXT NOT NULL DEFAULT '',
+			canary_percent BIGINT NOT NULL DEFAULT 0,
+			canary_margin DOUBLE PRECISION NOT NULL DEFAULT 0,
+			canary_min_runs BIGINT NOT NULL DEFAULT 0,
+			action TEXT NOT NULL,
+			actor TEXT NOT NULL DEFAULT '',
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS policy_audit (
+			id TEXT PRIMARY KEY,
+			project TEXT NOT NULL DEFAULT '',
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			actor_agent_id TEXT NOT NULL DEFAULT '',
+			actor_key_id TEXT NOT NULL DEFAULT '',
+			before_json TEXT NOT NULL DEFAULT '',
+			after_json TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
 		`SELECT create_hypertable('benchmarks', 'created_at', if_not_exists => TRUE, migrate_data => TRUE)`,
 		`SELECT create_hypertable('prompt_attempts', 'created_at', if_not_exists => TRUE, migrate_data => TRUE)`,
 		`SELECT create_hypertable('run_events', 'created_at', if_not_exists => TRUE, migrate_data => TRUE)`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE prompt_attempts ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS maintenance_windows JSONB NOT NULL DEFAULT '[]'::jsonb`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS scheduled_kill_switch BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS scheduled_kill_switch_reason TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS alert_maintenance_windows JSONB NOT NULL DEFAULT '[]'::jsonb`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS agent_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS max_cost_per_month_usd DOUBLE PRECISION NOT NULL DEFAULT 0`,
+		`ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_version TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_percent BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_margin DOUBLE PRECISION NOT NULL DEFAULT 0`,
+		`ALTER TABLE prompt_releases ADD COLUMN IF NOT EXISTS canary_min_runs BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ NULL`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS valid_until TIMESTAMPTZ NULL`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS workflow TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS max_cost_per_hour_usd DOUBLE PRECISION NOT NULL DEFAULT 0`,
+		`ALTER TABLE orchestration_policy ADD COLUMN IF NOT EXISTS max_cost_per_day_usd DOUBLE PRECISION NOT NULL DEFAULT 0`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'limit'`,
+		`ALTER TABLE policy_caps ADD COLUMN IF NOT EXISTS routing JSONB NULL`,
+		`CREATE TABLE IF NOT EXISTS prompt_attempts_archive (LIKE prompt_attempts INCLUDING DEFAULTS INCLUDING CONSTRAINTS, PRIMARY KEY (id, created_at))`,
+		`CREATE TABLE IF NOT EXISTS run_events_archive (LIKE run_events INCLUDING DEFAULTS INCLUDING CONSTRAINTS, PRIMARY KEY (id, created_at))`,
+		`SELECT create_hypertable('prompt_attempts_archive', 'created_at', chunk_time_interval => INTERVAL '30 days', if_not_exists => TRUE, migrate_data => TRUE)`,
+		`SELECT create_hypertable('run_events_archive', 'created_at', chunk_time_interval => INTERVAL '30 days', if_not_exists => TRUE, migrate_data => TRUE)`,
+		`ALTER TABLE run_events ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE run_events_archive ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS starred BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS starred_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS idx_agent_runs_starred ON agent_runs (started_at DESC) WHERE starred`,
+		`ALTER TABLE agent_runs ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS external_id TEXT NOT NULL DEFAULT ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_runs_external_id ON agent_runs (project, external_id) WHERE external_id <> ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_external_id ON tasks (project, external_id) WHERE external_id <> ''`,
+		`CREATE TABLE IF NOT EXISTS telemetry_sketches (
+			day DATE NOT NULL,
+			dimension TEXT NOT NULL,
+			registers BYTEA NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (day, dimension)
+		)`,
+		`CREATE TABLE IF NOT EXISTS attempt_daily_rollups (
+			day DATE NOT NULL,
+			project TEXT NOT NULL,
+			workflow TEXT NOT NULL,
+			provider_type TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			prompt_version TEXT NOT NULL,
+			attempts BIGINT NOT NULL DEFAULT 0,
+			success_attempts BIGINT NOT NULL DEFAULT 0,
+			tokens_in BIGINT NOT NULL DEFAULT 0,
+			tokens_out BIGINT NOT NULL DEFAULT 0,
+			cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+			latency_ms BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (day, project, workflow, provider_type, provider, model, prompt_version)
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_tasks_updated_at ON tasks (updated_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_project_updated_at ON tasks (project, updated_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_agent_runs_project_started_at ON agent_runs (project, started_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_project_created_at ON prompt_attempts (project, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_agent_created_at ON prompt_attempts (agent_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_notes_created_at ON notes (created_at DESC, id DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_changelog_created_at ON changelog (created_at DESC, id DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_benchmarks_created_at ON benchmarks (created_at DESC, id DESC)`,
@@ -1195,27 +2686,35 @@ func (s *PostgresStore) ensureSchema() error {
 		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_run_created_at ON prompt_attempts (run_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_outcome_created_at ON prompt_attempts (outcome, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_run_events_run_created_at ON run_events (run_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_archive_run_created_at ON prompt_attempts_archive (run_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_attempts_archive_project_created_at ON prompt_attempts_archive (project, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_run_events_archive_run_created_at ON run_events_archive (run_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_attempt_daily_rollups_project_day ON attempt_daily_rollups (project, day)`,
+		`CREATE INDEX IF NOT EXISTS idx_artifacts_run_created_at ON artifacts (run_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_releases_workflow_created_at ON prompt_releases (project, workflow, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_policy_audit_created_at ON policy_audit (created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_policy_audit_target ON policy_audit (target_type, target_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_agent_api_keys_agent_id ON agent_api_keys (agent_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_agent_api_keys_active ON agent_api_keys (is_active, revoked_at, expires_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_policy_caps_lookup ON policy_caps (provider_type, provider, model, is_active, priority DESC)`,
 	}
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return domain.Internal("failed to start schema transaction", err)
 	}
 	defer func() {
-		_ = tx.Rollback()
+		_ = tx.Rollback(ctx)
 	}()
 
 	for _, statement := range statements {
-		if _, err := tx.Exec(statement); err != nil {
+		if _, err := tx.Exec(ctx, statement); err != nil {
 			return domain.Internal(fmt.Sprintf("failed to run schema statement: %s", statement), err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return domain.Internal("failed to commit schema transaction", err)
 	}
 	return nil
@@ -1229,6 +2728,13 @@ func formatTime(value time.Time) string {
 	return value.UTC().Format(time.RFC3339Nano)
 }
 
+// isUniqueViolation reports whether err is Postgres refusing a write that
+// would duplicate a unique index.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 func nullableTimestamp(value string) any {
 	clean := strings.TrimSpace(value)
 	if clean == "" {
@@ -1241,6 +2747,32 @@ func nullableTimestamp(value string) any {
 	return parsed
 }
 
+func encodeMetadata(metadata map[string]string) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func decodeMetadata(raw []byte) (map[string]string, error) {
+	out := map[string]string{}
+	if len(raw) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 func hashAPIKey(rawKey string) string {
 	clean := strings.TrimSpace(rawKey)
 	if clean == "" {
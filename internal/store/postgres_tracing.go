@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxTracedStatement bounds the db.statement attribute; the longest list
+// queries with their archive unions run to a few kilobytes.
+const maxTracedStatement = 2048
+
+// queryTracer gives each statement run inside a traced request its own
+// client span, under the service span that issued it. Statements from
+// background jobs with no span in their context are not traced, and
+// arguments are never recorded.
+type queryTracer struct {
+	schema string
+}
+
+func (t queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.start(ctx, data.SQL)
+}
+
+func (t queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return t.start(ctx, "COPY "+data.TableName.Sanitize()+" ("+strings.Join(data.ColumnNames, ", ")+") FROM STDIN")
+}
+
+func (t queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t queryTracer) start(ctx context.Context, sql string) context.Context {
+	if tracing.SpanFromContext(ctx) == nil {
+		return ctx
+	}
+	statement := strings.Join(strings.Fields(sql), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+	if len(statement) > maxTracedStatement {
+		statement = statement[:maxTracedStatement] + "..."
+	}
+	ctx, _ = tracing.StartKind(ctx, "postgres "+operation, tracing.KindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.operation", operation),
+		tracing.String("db.statement", statement),
+		tracing.String("db.schema", t.schema),
+	)
+	return ctx
+}
+
+// end closes the span start put in ctx; pgx passes start's context back.
+// When start traced nothing there is no span.
+func (t queryTracer) end(ctx context.Context, rows int64, err error) {
+	span := tracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	span.SetAttributes(tracing.Int64("db.rows_affected", rows))
+	span.RecordError(err)
+	span.End()
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// rollupKey names one daily rollup row.
+type rollupKey struct {
+	day           string
+	project       string
+	workflow      string
+	providerType  string
+	provider      string
+	model         string
+	promptVersion string
+}
+
+func (k rollupKey) compare(other rollupKey) int {
+	return cmp.Or(
+		cmp.Compare(k.day, other.day),
+		cmp.Compare(k.project, other.project),
+		cmp.Compare(k.workflow, other.workflow),
+		cmp.Compare(k.providerType, other.providerType),
+		cmp.Compare(k.provider, other.provider),
+		cmp.Compare(k.model, other.model),
+		cmp.Compare(k.promptVersion, other.promptVersion),
+	)
+}
+
+func rollupKeyOf(rollup domain.DailyRollup) rollupKey {
+	return rollupKey{
+		day:           rollup.Day,
+		project:       rollup.Project,
+		workflow:      rollup.Workflow,
+		providerType:  rollup.ProviderType,
+		provider:      rollup.Provider,
+		model:         rollup.Model,
+		promptVersion: rollup.PromptVersion,
+	}
+}
+
+// dailyRollups holds per-day attempt totals; see domain.DailyRollup.
+type dailyRollups map[rollupKey]*domain.DailyRollup
+
+// addAttempt counts an attempt on the UTC day it was created. Attempts whose
+// created_at does not parse are skipped, as the sketches skip them.
+func (r dailyRollups) addAttempt(attempt domain.PromptAttempt) {
+	createdAt, err := time.Parse(time.RFC3339Nano, attempt.CreatedAt)
+	if err != nil {
+		return
+	}
+	project := attempt.Project
+	if project == "" {
+		project = domain.DefaultProject
+	}
+	stub := domain.DailyRollup{
+		Day:           createdAt.UTC().Format(time.DateOnly),
+		Project:       project,
+		Workflow:      attempt.Workflow,
+		ProviderType:  attempt.ProviderType,
+		Provider:      attempt.Provider,
+		Model:         attempt.Model,
+		PromptVersion: attempt.PromptVersion,
+	}
+	key := rollupKeyOf(stub)
+	rollup, ok := r[key]
+	if !ok {
+		rollup = &stub
+		r[key] = rollup
+	}
+	rollup.Attempts++
+	if attempt.Outcome == "success" {
+		rollup.SuccessAttempts++
+	}
+	rollup.TokensIn += attempt.TokensIn
+	rollup.TokensOut += attempt.TokensOut
+	rollup.CostUSD += attempt.CostUSD
+	rollup.LatencyMS += attempt.LatencyMS
+}
+
+// replaceDay drops day's rollups and recounts them from attempts, which
+// should be every attempt created that day.
+func (r dailyRollups) replaceDay(day string, attempts []domain.PromptAttempt) {
+	for key := range r {
+		if key.day == day {
+			delete(r, key)
+		}
+	}
+	for _, attempt := range attempts {
+		r.addAttempt(attempt)
+	}
+}
+
+// pruneBefore drops the days that end at or before cutoff, as the sketches
+// do; a zero cutoff keeps everything.
+func (r dailyRollups) pruneBefore(cutoff time.Time) {
+	if cutoff.IsZero() {
+		return
+	}
+	for key := range r {
+		if day, err := time.Parse(time.DateOnly, key.day); err == nil && !day.Add(24*time.Hour).After(cutoff) {
+			delete(r, key)
+		}
+	}
+}
+
+// list returns copies of the rollups matching filter in key order.
+func (r dailyRollups) list(filter domain.RollupFilter) []domain.DailyRollup {
+	keys := make([]rollupKey, 0, len(r))
+	for key, rollup := range r {
+		if rollupMatches(filter, *rollup) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, rollupKey.compare)
+	out := make([]domain.DailyRollup, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, *r[key])
+	}
+	return out
+}
+
+// sorted returns the rollups in key order, which batch upserts follow so
+// concurrent writers lock rows in the same order.
+func (r dailyRollups) sorted() []domain.DailyRollup {
+	return r.list(domain.RollupFilter{})
+}
+
+func rollupMatches(filter domain.RollupFilter, rollup domain.DailyRollup) bool {
+	switch {
+	case filter.Project != "" && rollup.Project != filter.Project:
+		return false
+	case filter.Workflow != "" && rollup.Workflow != filter.Workflow:
+		return false
+	case filter.Model != "" && rollup.Model != filter.Model:
+		return false
+	case filter.PromptVersion != "" && rollup.PromptVersion != filter.PromptVersion:
+		return false
+	case filter.FromDay != "" && rollup.Day < filter.FromDay:
+		return false
+	case filter.ToDay != "" && rollup.Day > filter.ToDay:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,801 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
+
+// maxCachedRunShards bounds the run ID to shard cache; past it the cache
+// starts over.
+const maxCachedRunShards = 10000
+
+var shardSchemaPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// ShardedStore routes each project's tasks, runs, attempts, run events and
+// artifacts to the store for that project's shard, and everything else
+// (policy, caps, notes, changelog, benchmarks, releases, audit, keys and
+// idempotency) to the primary. Projects without a shard stay on the primary.
+//
+// Reads scoped to one project touch only its shard. Unscoped reads, such as
+// an admin listing every project, query each store in turn and merge the
+// results in the order a single store returns them. A run's events and
+// artifacts go where the run is; the router finds it by ID and caches the
+// answer.
+type ShardedStore struct {
+	primary HubStore
+	shards  map[string]HubStore
+	// routes maps a project to its shard's name.
+	routes map[string]string
+	// all is the primary followed by the shards in name order.
+	all []HubStore
+
+	mu        sync.Mutex
+	runShards map[string]HubStore
+}
+
+// NewShardedStore routes the projects in routes (project to shard name) to
+// the matching stores in shards. Every shard must be routed to.
+func NewShardedStore(primary HubStore, shards map[string]HubStore, routes map[string]string) (*ShardedStore, error) {
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		if !slices.Contains(mapValues(routes), name) {
+			return nil, domain.InvalidArgument("shard " + name + " has no projects routed to it")
+		}
+		names = append(names, name)
+	}
+	for project, name := range routes {
+		if _, ok := shards[name]; !ok {
+			return nil, domain.InvalidArgument(fmt.Sprintf("project %s is routed to unknown shard %s", project, name))
+		}
+	}
+	slices.Sort(names)
+	all := []HubStore{primary}
+	for _, name := range names {
+		all = append(all, shards[name])
+	}
+	return &ShardedStore{
+		primary:   primary,
+		shards:    shards,
+		routes:    routes,
+		all:       all,
+		runShards: map[string]HubStore{},
+	}, nil
+}
+
+// ParseProjectShards reads PROJECT_SHARDS entries of the form
+// project=schema. Several projects may share a schema.
+func ParseProjectShards(entries []string) (map[string]string, error) {
+	routes := map[string]string{}
+	for _, entry := range entries {
+		project, schema, ok := strings.Cut(entry, "=")
+		project, schema = strings.ToLower(strings.TrimSpace(project)), strings.TrimSpace(schema)
+		if !ok || project == "" {
+			return nil, domain.InvalidArgument(fmt.Sprintf("PROJECT_SHARDS entry %q must be project=schema", entry))
+		}
+		if err := validateShardSchema(schema); err != nil {
+			return nil, err
+		}
+		if _, ok := routes[project]; ok {
+			return nil, domain.InvalidArgument("PROJECT_SHARDS lists project " + project + " twice")
+		}
+		routes[project] = schema
+	}
+	return routes, nil
+}
+
+func validateShardSchema(schema string) error {
+	if !shardSchemaPattern.MatchString(schema) || schema == "public" ||
+		strings.HasPrefix(schema, "pg_") || strings.HasPrefix(schema, "_timescaledb") || strings.HasPrefix(schema, "timescaledb") {
+		return domain.InvalidArgument(fmt.Sprintf("shard schema %q must be a lowercase identifier other than public and the pg_ and timescaledb schemas", schema))
+	}
+	return nil
+}
+
+// Stores returns the primary followed by the shards in name order.
+func (s *ShardedStore) Stores() []HubStore {
+	return slices.Clone(s.all)
+}
+
+// ShardNames returns the shard names in the order Stores lists them after
+// the primary.
+func (s *ShardedStore) ShardNames() []string {
+	names := mapValues(s.routes)
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// storeFor is the store holding project's records.
+func (s *ShardedStore) storeFor(project string) HubStore {
+	if name, ok := s.routes[project]; ok {
+		return s.shards[name]
+	}
+	return s.primary
+}
+
+// targets is the stores a read filtered by project has to query: its shard,
+// or every store when project is empty.
+func (s *ShardedStore) targets(project string) []HubStore {
+	if project != "" {
+		return []HubStore{s.storeFor(project)}
+	}
+	return s.all
+}
+
+// storeForRun finds the store holding runID. Unknown runs resolve to the
+// primary, which then reports them the way an unsharded store would.
+func (s *ShardedStore) storeForRun(ctx context.Context, runID string) (HubStore, error) {
+	s.mu.Lock()
+	cached, ok := s.runShards[runID]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+	for _, candidate := range s.all {
+		runs, err := candidate.ListRunsFiltered(ctx, domain.RunFilter{RunID: runID, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			s.rememberRun(runID, candidate)
+			return candidate, nil
+		}
+	}
+	return s.primary, nil
+}
+
+func (s *ShardedStore) rememberRun(runID string, shard HubStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.runShards) >= maxCachedRunShards {
+		s.runShards = map[string]HubStore{}
+	}
+	s.runShards[runID] = shard
+}
+
+// fanOut lists from each store and, when there are several, merges the rows
+// by order and applies limit.
+func fanOut[T any](stores []HubStore, limit int64, list func(HubStore) ([]T, error), order func(a, b T) int) ([]T, error) {
+	if len(stores) == 1 {
+		return list(stores[0])
+	}
+	out := []T{}
+	for _, shard := range stores {
+		items, err := list(shard)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, items...)
+	}
+	if order != nil {
+		slices.SortStableFunc(out, order)
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// sumOver adds up count across every store.
+func sumOver(stores []HubStore, count func(HubStore) (int, error)) (int, error) {
+	total := 0
+	for _, shard := range stores {
+		n, err := count(shard)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// newestFirst orders by timestamp and then ID, both descending, matching
+// the stores' list queries.
+func newestFirst(aTime, aID, bTime, bID string) int {
+	a, _ := time.Parse(time.RFC3339Nano, aTime)
+	b, _ := time.Parse(time.RFC3339Nano, bTime)
+	if c := b.Compare(a); c != 0 {
+		return c
+	}
+	return strings.Compare(bID, aID)
+}
+
+func (s *ShardedStore) Load() error {
+	for _, shard := range s.all {
+		if err := shard.Load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) Close() error {
+	var errs []error
+	for _, shard := range s.all {
+		errs = append(errs, shard.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// PoolStats reports each store's pools, with shard pools named
+// schema/primary and schema/replica.
+func (s *ShardedStore) PoolStats() []PoolStats {
+	out := []PoolStats{}
+	if reporter, ok := s.primary.(PoolStatsReporter); ok {
+		out = append(out, reporter.PoolStats()...)
+	}
+	for _, name := range s.ShardNames() {
+		reporter, ok := s.shards[name].(PoolStatsReporter)
+		if !ok {
+			continue
+		}
+		for _, stat := range reporter.PoolStats() {
+			stat.Name = name + "/" + stat.Name
+			out = append(out, stat)
+		}
+	}
+	return out
+}
+
+func (s *ShardedStore) ExportState(ctx context.Context) (domain.State, error) {
+	state, err := s.primary.ExportState(ctx)
+	if err != nil {
+		return domain.State{}, err
+	}
+	for _, shard := range s.all[1:] {
+		part, err := shard.ExportState(ctx)
+		if err != nil {
+			return domain.State{}, err
+		}
+		state.Tasks = append(state.Tasks, part.Tasks...)
+		state.Runs = append(state.Runs, part.Runs...)
+		state.Attempts = append(state.Attempts, part.Attempts...)
+		state.RunEvents = append(state.RunEvents, part.RunEvents...)
+		state.Artifacts = append(state.Artifacts, part.Artifacts...)
+	}
+	return state, nil
+}
+
+func (s *ShardedStore) GetPolicy(ctx context.Context) (domain.OrchestrationPolicy, error) {
+	return s.primary.GetPolicy(ctx)
+}
+
+func (s *ShardedStore) SetPolicy(ctx context.Context, policy domain.OrchestrationPolicy) error {
+	return s.primary.SetPolicy(ctx, policy)
+}
+
+func (s *ShardedStore) ListPolicyCaps(ctx context.Context) ([]domain.PolicyCap, error) {
+	return s.primary.ListPolicyCaps(ctx)
+}
+
+func (s *ShardedStore) UpsertPolicyCap(ctx context.Context, cap domain.PolicyCap) error {
+	return s.primary.UpsertPolicyCap(ctx, cap)
+}
+
+func (s *ShardedStore) DeletePolicyCap(ctx context.Context, id string) (bool, error) {
+	return s.primary.DeletePolicyCap(ctx, id)
+}
+
+func (s *ShardedStore) ListTasksFiltered(ctx context.Context, filter domain.TaskFilter) ([]domain.Task, error) {
+	return fanOut(s.targets(filter.Project), filter.Limit, func(shard HubStore) ([]domain.Task, error) {
+		return shard.ListTasksFiltered(ctx, filter)
+	}, func(a, b domain.Task) int { return newestFirst(a.UpdatedAt, a.ID, b.UpdatedAt, b.ID) })
+}
+
+func (s *ShardedStore) ListTasks(ctx context.Context) ([]domain.Task, error) {
+	return fanOut(s.all, 0, func(shard HubStore) ([]domain.Task, error) { return shard.ListTasks(ctx) }, nil)
+}
+
+func (s *ShardedStore) CountTasks(ctx context.Context) (int, error) {
+	return sumOver(s.all, func(shard HubStore) (int, error) { return shard.CountTasks(ctx) })
+}
+
+func (s *ShardedStore) UpsertTask(ctx context.Context, task domain.Task) error {
+	return s.storeFor(task.Project).UpsertTask(ctx, task)
+}
+
+func (s *ShardedStore) DeleteTask(ctx context.Context, id string) (bool, error) {
+	for _, shard := range s.all {
+		deleted, err := shard.DeleteTask(ctx, id)
+		if err != nil || deleted {
+			return deleted, err
+		}
+	}
+	return false, nil
+}
+
+func (s *ShardedStore) ListNotes(ctx context.Context) ([]domain.Note, error) {
+	return s.primary.ListNotes(ctx)
+}
+
+func (s *ShardedStore) CountNotes(ctx context.Context) (int, error) {
+	return s.primary.CountNotes(ctx)
+}
+
+func (s *ShardedStore) InsertNote(ctx context.Context, note domain.Note) error {
+	return s.primary.InsertNote(ctx, note)
+}
+
+func (s *ShardedStore) ListChangelog(ctx context.Context) ([]domain.ChangelogEntry, error) {
+	return s.primary.ListChangelog(ctx)
+}
+
+func (s *ShardedStore) CountChangelog(ctx context.Context) (int, error) {
+	return s.primary.CountChangelog(ctx)
+}
+
+func (s *ShardedStore) InsertChangelog(ctx context.Context, entry domain.ChangelogEntry) error {
+	return s.primary.InsertChangelog(ctx, entry)
+}
+
+func (s *ShardedStore) ListBenchmarks(ctx context.Context) ([]domain.Benchmark, error) {
+	return s.primary.ListBenchmarks(ctx)
+}
+
+func (s *ShardedStore) InsertBenchmark(ctx context.Context, benchmark domain.Benchmark) error {
+	return s.primary.InsertBenchmark(ctx, benchmark)
+}
+
+func (s *ShardedStore) ListRunsFiltered(ctx context.Context, filter domain.RunFilter) ([]domain.AgentRun, error) {
+	return fanOut(s.targets(filter.Project), filter.Limit, func(shard HubStore) ([]domain.AgentRun, error) {
+		return shard.ListRunsFiltered(ctx, filter)
+	}, func(a, b domain.AgentRun) int { return newestFirst(a.StartedAt, a.ID, b.StartedAt, b.ID) })
+}
+
+func (s *ShardedStore) ListRuns(ctx context.Context) ([]domain.AgentRun, error) {
+	return fanOut(s.all, 0, func(shard HubStore) ([]domain.AgentRun, error) { return shard.ListRuns(ctx) }, nil)
+}
+
+func (s *ShardedStore) CountRuns(ctx context.Context) (int, error) {
+	return sumOver(s.all, func(shard HubStore) (int, error) { return shard.CountRuns(ctx) })
+}
+
+func (s *ShardedStore) InsertRun(ctx context.Context, run domain.AgentRun) error {
+	shard := s.storeFor(run.Project)
+	if err := shard.InsertRun(ctx, run); err != nil {
+		return err
+	}
+	s.rememberRun(run.ID, shard)
+	return nil
+}
+
+func (s *ShardedStore) UpdateRun(ctx context.Context, run domain.AgentRun) error {
+	return s.storeFor(run.Project).UpdateRun(ctx, run)
+}
+
+func (s *ShardedStore) FinalizeRun(ctx context.Context, run domain.AgentRun) (domain.AgentRun, error) {
+	return s.storeFor(run.Project).FinalizeRun(ctx, run)
+}
+
+func (s *ShardedStore) SetRunStarred(ctx context.Context, run domain.AgentRun) error {
+	return s.storeFor(run.Project).SetRunStarred(ctx, run)
+}
+
+func (s *ShardedStore) LastRunActivity(ctx context.Context, runIDs []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, shard := range s.all {
+		activity, err := shard.LastRunActivity(ctx, runIDs)
+		if err != nil {
+			return nil, err
+		}
+		for id, at := range activity {
+			out[id] = at
+		}
+	}
+	return out, nil
+}
+
+func (s *ShardedStore) ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	return fanOut(s.targets(filter.Project), filter.Limit, func(shard HubStore) ([]domain.PromptAttempt, error) {
+		return shard.ListPromptAttemptsFiltered(ctx, filter)
+	}, newestAttemptFirst)
+}
+
+func newestAttemptFirst(a, b domain.PromptAttempt) int {
+	return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+}
+
+func (s *ShardedStore) ListPromptAttempts(ctx context.Context, runID string) ([]domain.PromptAttempt, error) {
+	shard, err := s.storeForRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.ListPromptAttempts(ctx, runID)
+}
+
+func (s *ShardedStore) CountPromptAttempts(ctx context.Context) (int, error) {
+	return sumOver(s.all, func(shard HubStore) (int, error) { return shard.CountPromptAttempts(ctx) })
+}
+
+func (s *ShardedStore) InsertPromptAttempt(ctx context.Context, attempt domain.PromptAttempt) error {
+	return s.storeFor(attempt.Project).InsertPromptAttempt(ctx, attempt)
+}
+
+// InsertPromptAttemptGuarded runs the insert in the attempt's shard. The
+// check's reader also sees the other shards, so hub-wide and agent budgets
+// count every project; shards share one database, so the budget advisory
+// locks still serialize guarded inserts across them.
+func (s *ShardedStore) InsertPromptAttemptGuarded(ctx context.Context, attempt domain.PromptAttempt, locks AttemptLocks, check func(AttemptReader) error) error {
+	home := s.storeFor(attempt.Project)
+	guarded, ok := home.(GuardedAttemptInserter)
+	if !ok {
+		if err := check(shardedAttemptReader{store: s, home: home, inner: home}); err != nil {
+			return err
+		}
+		return home.InsertPromptAttempt(ctx, attempt)
+	}
+	return guarded.InsertPromptAttemptGuarded(ctx, attempt, locks, func(reader AttemptReader) error {
+		return check(shardedAttemptReader{store: s, home: home, inner: reader})
+	})
+}
+
+// shardedAttemptReader reads the home shard through inner, the guarded
+// insert's transaction, and the other shards directly.
+type shardedAttemptReader struct {
+	store *ShardedStore
+	home  HubStore
+	inner AttemptReader
+}
+
+func (r shardedAttemptReader) ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error) {
+	return fanOut(r.store.targets(filter.Project), filter.Limit, func(shard HubStore) ([]domain.PromptAttempt, error) {
+		if shard == r.home {
+			return r.inner.ListPromptAttemptsFiltered(ctx, filter)
+		}
+		return shard.ListPromptAttemptsFiltered(ctx, filter)
+	}, newestAttemptFirst)
+}
+
+// InsertPromptAttempts batches attempts per shard. Each shard's batch is
+// atomic, but a batch spanning shards can fail after earlier shards stored
+// theirs.
+func (s *ShardedStore) InsertPromptAttempts(ctx context.Context, attempts []domain.PromptAttempt) error {
+	groups := map[HubStore][]domain.PromptAttempt{}
+	for _, attempt := range attempts {
+		shard := s.storeFor(attempt.Project)
+		groups[shard] = append(groups[shard], attempt)
+	}
+	for _, shard := range s.all {
+		group := groups[shard]
+		if len(group) == 0 {
+			continue
+		}
+		if batch, ok := shard.(BatchInserter); ok {
+			if err := batch.InsertPromptAttempts(ctx, group); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, attempt := range group {
+			if err := shard.InsertPromptAttempt(ctx, attempt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) LeaderboardAggregate(ctx context.Context, filter domain.AttemptFilter) ([]domain.LeaderboardEntry, error) {
+	stores := s.targets(filter.Project)
+	if len(stores) == 1 {
+		return stores[0].LeaderboardAggregate(ctx, filter)
+	}
+	type key struct{ workflow, promptVersion, model string }
+	merged := map[key]*domain.LeaderboardEntry{}
+	order := []key{}
+	for _, shard := range stores {
+		entries, err := shard.LeaderboardAggregate(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			k := key{entry.Workflow, entry.PromptVersion, entry.Model}
+			total, ok := merged[k]
+			if !ok {
+				copied := entry
+				merged[k] = &copied
+				order = append(order, k)
+				continue
+			}
+			attempts := float64(total.Attempts + entry.Attempts)
+			total.AverageCostUSD = (total.AverageCostUSD*float64(total.Attempts) + entry.AverageCostUSD*float64(entry.Attempts)) / attempts
+			total.AverageLatencyMS = (total.AverageLatencyMS*float64(total.Attempts) + entry.AverageLatencyMS*float64(entry.Attempts)) / attempts
+			total.Attempts += entry.Attempts
+			total.SuccessAttempts += entry.SuccessAttempts
+			total.FailedAttempts += entry.FailedAttempts
+			total.SuccessRate = float64(total.SuccessAttempts) / attempts
+		}
+	}
+	out := make([]domain.LeaderboardEntry, 0, len(order))
+	for _, k := range order {
+		out = append(out, *merged[k])
+	}
+	return out, nil
+}
+
+func (s *ShardedStore) AttemptTimeSeries(ctx context.Context, filter domain.AttemptFilter, bucket time.Duration) ([]domain.AttemptBucket, error) {
+	stores := s.targets(filter.Project)
+	if len(stores) == 1 {
+		return stores[0].AttemptTimeSeries(ctx, filter, bucket)
+	}
+	type key struct{ start, workflow, model string }
+	merged := map[key]*domain.AttemptBucket{}
+	order := []key{}
+	for _, shard := range stores {
+		buckets, err := shard.AttemptTimeSeries(ctx, filter, bucket)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range buckets {
+			k := key{item.Start, item.Workflow, item.Model}
+			total, ok := merged[k]
+			if !ok {
+				copied := item
+				merged[k] = &copied
+				order = append(order, k)
+				continue
+			}
+			total.Attempts += item.Attempts
+			total.SuccessAttempts += item.SuccessAttempts
+			total.CostUSD += item.CostUSD
+		}
+	}
+	out := make([]domain.AttemptBucket, 0, len(order))
+	for _, k := range order {
+		out = append(out, *merged[k])
+	}
+	return out, nil
+}
+
+func (s *ShardedStore) SummarizeTelemetry(ctx context.Context) (domain.TelemetrySummary, error) {
+	var total domain.TelemetrySummary
+	for _, shard := range s.all {
+		part, err := shard.SummarizeTelemetry(ctx)
+		if err != nil {
+			return domain.TelemetrySummary{}, err
+		}
+		total.Counts.Runs += part.Counts.Runs
+		total.Counts.RunningRuns += part.Counts.RunningRuns
+		total.Counts.CompletedRuns += part.Counts.CompletedRuns
+		total.Counts.FailedRuns += part.Counts.FailedRuns
+		total.Counts.CancelledRuns += part.Counts.CancelledRuns
+		total.Counts.Attempts += part.Counts.Attempts
+		total.Counts.SuccessAttempts += part.Counts.SuccessAttempts
+		total.Counts.FailedAttempts += part.Counts.FailedAttempts
+		total.Counts.Retries += part.Counts.Retries
+		total.Counts.Events += part.Counts.Events
+		total.Totals.TokensIn += part.Totals.TokensIn
+		total.Totals.TokensOut += part.Totals.TokensOut
+		total.Totals.CostUSD += part.Totals.CostUSD
+		total.Totals.LatencyMS += part.Totals.LatencyMS
+	}
+	// Distinct values repeat across shards, so merge the sketches instead of
+	// adding up each shard's estimates.
+	sketches := telemetrySketches{}
+	for _, shard := range s.all {
+		source, ok := shard.(sketchSource)
+		if !ok {
+			continue
+		}
+		part, err := source.telemetrySketches(ctx)
+		if err != nil {
+			return domain.TelemetrySummary{}, err
+		}
+		sketches.merge(part)
+	}
+	sketches.summarize(&total)
+	return total, nil
+}
+
+// ListDailyRollups concatenates the shards' rollups; a project's rows live
+// in one shard, so no two shards return the same key.
+func (s *ShardedStore) ListDailyRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.DailyRollup, error) {
+	stores := s.targets(filter.Project)
+	if len(stores) == 1 {
+		return stores[0].ListDailyRollups(ctx, filter)
+	}
+	out := []domain.DailyRollup{}
+	for _, shard := range stores {
+		rollups, err := shard.ListDailyRollups(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rollups...)
+	}
+	slices.SortFunc(out, func(a, b domain.DailyRollup) int {
+		return rollupKeyOf(a).compare(rollupKeyOf(b))
+	})
+	return out, nil
+}
+
+func (s *ShardedStore) RebuildDailyRollups(ctx context.Context, day time.Time) error {
+	for _, shard := range s.all {
+		if err := shard.RebuildDailyRollups(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) PruneBefore(ctx context.Context, runEventsBefore, attemptsBefore time.Time) (domain.PruneResult, error) {
+	var total domain.PruneResult
+	for _, shard := range s.all {
+		part, err := shard.PruneBefore(ctx, runEventsBefore, attemptsBefore)
+		total.RunEventsDeleted += part.RunEventsDeleted
+		total.AttemptsDeleted += part.AttemptsDeleted
+		total.ChunksDropped += part.ChunksDropped
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *ShardedStore) ArchiveBefore(ctx context.Context, cutoff time.Time) (domain.ArchiveResult, error) {
+	var total domain.ArchiveResult
+	for _, shard := range s.all {
+		part, err := shard.ArchiveBefore(ctx, cutoff)
+		total.AttemptsArchived += part.AttemptsArchived
+		total.RunEventsArchived += part.RunEventsArchived
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *ShardedStore) ListRunEventsFiltered(ctx context.Context, filter domain.EventFilter) ([]domain.RunEvent, error) {
+	stores := s.targets(filter.Project)
+	if filter.Project == "" && filter.RunID != "" {
+		shard, err := s.storeForRun(ctx, filter.RunID)
+		if err != nil {
+			return nil, err
+		}
+		stores = []HubStore{shard}
+	}
+	return fanOut(stores, filter.Limit, func(shard HubStore) ([]domain.RunEvent, error) {
+		return shard.ListRunEventsFiltered(ctx, filter)
+	}, func(a, b domain.RunEvent) int { return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID) })
+}
+
+func (s *ShardedStore) ListRunEvents(ctx context.Context, runID string) ([]domain.RunEvent, error) {
+	shard, err := s.storeForRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.ListRunEvents(ctx, runID)
+}
+
+func (s *ShardedStore) CountRunEvents(ctx context.Context) (int, error) {
+	return sumOver(s.all, func(shard HubStore) (int, error) { return shard.CountRunEvents(ctx) })
+}
+
+func (s *ShardedStore) InsertRunEvent(ctx context.Context, event domain.RunEvent) error {
+	shard, err := s.storeForRun(ctx, event.RunID)
+	if err != nil {
+		return err
+	}
+	return shard.InsertRunEvent(ctx, event)
+}
+
+// InsertRunEvents batches events per shard of their run, with the same
+// partial-failure caveat as InsertPromptAttempts.
+func (s *ShardedStore) InsertRunEvents(ctx context.Context, events []domain.RunEvent) error {
+	groups := map[HubStore][]domain.RunEvent{}
+	for _, event := range events {
+		shard, err := s.storeForRun(ctx, event.RunID)
+		if err != nil {
+			return err
+		}
+		groups[shard] = append(groups[shard], event)
+	}
+	for _, shard := range s.all {
+		group := groups[shard]
+		if len(group) == 0 {
+			continue
+		}
+		if batch, ok := shard.(BatchInserter); ok {
+			if err := batch.InsertRunEvents(ctx, group); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, event := range group {
+			if err := shard.InsertRunEvent(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ShardedStore) ListArtifacts(ctx context.Context, filter domain.ArtifactFilter) ([]domain.Artifact, error) {
+	stores := s.targets(filter.Project)
+	if filter.Project == "" && filter.RunID != "" {
+		shard, err := s.storeForRun(ctx, filter.RunID)
+		if err != nil {
+			return nil, err
+		}
+		stores = []HubStore{shard}
+	}
+	return fanOut(stores, filter.Limit, func(shard HubStore) ([]domain.Artifact, error) {
+		return shard.ListArtifacts(ctx, filter)
+	}, func(a, b domain.Artifact) int { return newestFirst(a.CreatedAt, a.ID, b.CreatedAt, b.ID) })
+}
+
+func (s *ShardedStore) InsertArtifact(ctx context.Context, artifact domain.Artifact) error {
+	shard, err := s.storeForRun(ctx, artifact.RunID)
+	if err != nil {
+		return err
+	}
+	return shard.InsertArtifact(ctx, artifact)
+}
+
+func (s *ShardedStore) ListPromptReleases(ctx context.Context, filter domain.PromptReleaseFilter) ([]domain.PromptRelease, error) {
+	return s.primary.ListPromptReleases(ctx, filter)
+}
+
+func (s *ShardedStore) InsertPromptRelease(ctx context.Context, release domain.PromptRelease) error {
+	return s.primary.InsertPromptRelease(ctx, release)
+}
+
+func (s *ShardedStore) ListPolicyAudit(ctx context.Context, filter domain.PolicyAuditFilter) ([]domain.PolicyAuditEntry, error) {
+	return s.primary.ListPolicyAudit(ctx, filter)
+}
+
+func (s *ShardedStore) InsertPolicyAudit(ctx context.Context, entry domain.PolicyAuditEntry) error {
+	return s.primary.InsertPolicyAudit(ctx, entry)
+}
+
+func (s *ShardedStore) AuthenticateAgentKey(ctx context.Context, rawKey string) (AgentPrincipal, bool, error) {
+	auth, ok := s.primary.(AgentKeyAuthenticator)
+	if !ok {
+		return AgentPrincipal{}, false, nil
+	}
+	return auth.AuthenticateAgentKey(ctx, rawKey)
+}
+
+func (s *ShardedStore) EnsureAgentKey(ctx context.Context, agentID, rawKey string) (string, bool, error) {
+	auth, ok := s.primary.(AgentKeyAuthenticator)
+	if !ok {
+		return "", false, domain.FailedPrecondition("the primary store does not hold agent keys")
+	}
+	return auth.EnsureAgentKey(ctx, agentID, rawKey)
+}
+
+func (s *ShardedStore) ReserveIdempotencyKey(ctx context.Context, method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error) {
+	keys, ok := s.primary.(IdempotencyStore)
+	if !ok {
+		return IdempotencyRecord{}, true, nil
+	}
+	return keys.ReserveIdempotencyKey(ctx, method, idempotencyKey, requestHash)
+}
+
+func (s *ShardedStore) CompleteIdempotencyKey(ctx context.Context, method, idempotencyKey, responseJSON string) error {
+	if keys, ok := s.primary.(IdempotencyStore); ok {
+		return keys.CompleteIdempotencyKey(ctx, method, idempotencyKey, responseJSON)
+	}
+	return nil
+}
+
+func (s *ShardedStore) ReleaseIdempotencyKey(ctx context.Context, method, idempotencyKey string) error {
+	if keys, ok := s.primary.(IdempotencyStore); ok {
+		return keys.ReleaseIdempotencyKey(ctx, method, idempotencyKey)
+	}
+	return nil
+}
+
+func mapValues(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for _, value := range m {
+		out = append(out, value)
+	}
+	return out
+}
@@ -1,44 +1,149 @@
 package store
 
-import "github.com/bcrosbie/modeloman/internal/domain"
+import (
+	"context"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+)
 
 // HubStore is the persistence contract used by the service layer.
 type HubStore interface {
 	Load() error
 	Close() error
 
-	ExportState() (domain.State, error)
-	GetPolicy() (domain.OrchestrationPolicy, error)
-	SetPolicy(domain.OrchestrationPolicy) error
-	ListPolicyCaps() ([]domain.PolicyCap, error)
-	UpsertPolicyCap(domain.PolicyCap) error
-	DeletePolicyCap(id string) (bool, error)
+	ExportState(ctx context.Context) (domain.State, error)
+	GetPolicy(ctx context.Context) (domain.OrchestrationPolicy, error)
+	SetPolicy(ctx context.Context, policy domain.OrchestrationPolicy) error
+	ListPolicyCaps(ctx context.Context) ([]domain.PolicyCap, error)
+	UpsertPolicyCap(ctx context.Context, cap domain.PolicyCap) error
+	DeletePolicyCap(ctx context.Context, id string) (bool, error)
+
+	ListTasksFiltered(ctx context.Context, filter domain.TaskFilter) ([]domain.Task, error)
+	ListTasks(ctx context.Context) ([]domain.Task, error)
+	CountTasks(ctx context.Context) (int, error)
+	UpsertTask(ctx context.Context, task domain.Task) error
+	DeleteTask(ctx context.Context, id string) (bool, error)
+
+	ListNotes(ctx context.Context) ([]domain.Note, error)
+	CountNotes(ctx context.Context) (int, error)
+	InsertNote(ctx context.Context, note domain.Note) error
+
+	ListChangelog(ctx context.Context) ([]domain.ChangelogEntry, error)
+	CountChangelog(ctx context.Context) (int, error)
+	InsertChangelog(ctx context.Context, entry domain.ChangelogEntry) error
+
+	ListBenchmarks(ctx context.Context) ([]domain.Benchmark, error)
+	InsertBenchmark(ctx context.Context, benchmark domain.Benchmark) error
+
+	ListRunsFiltered(ctx context.Context, filter domain.RunFilter) ([]domain.AgentRun, error)
+	ListRuns(ctx context.Context) ([]domain.AgentRun, error)
+	CountRuns(ctx context.Context) (int, error)
+	InsertRun(ctx context.Context, run domain.AgentRun) error
+	UpdateRun(ctx context.Context, run domain.AgentRun) error
+	// FinalizeRun stores run's status, last error, duration and finish time,
+	// computing its attempt totals from the stored attempts in the same write,
+	// and returns the run with those totals.
+	FinalizeRun(ctx context.Context, run domain.AgentRun) (domain.AgentRun, error)
+	// SetRunStarred stores run's Starred and StarredAt, leaving the rest of
+	// the stored run as it is.
+	SetRunStarred(ctx context.Context, run domain.AgentRun) error
+	// LastRunActivity returns the newest created_at among each run's events
+	// and attempts, keyed by run ID; runs with neither are left out.
+	LastRunActivity(ctx context.Context, runIDs []string) (map[string]string, error)
+
+	ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error)
+	ListPromptAttempts(ctx context.Context, runID string) ([]domain.PromptAttempt, error)
+	CountPromptAttempts(ctx context.Context) (int, error)
+	InsertPromptAttempt(ctx context.Context, attempt domain.PromptAttempt) error
+	// LeaderboardAggregate groups matching attempts by workflow, prompt
+	// version and model with counts, success rate and average cost/latency.
+	// Score and ordering are left to the caller; filter.Limit is ignored.
+	LeaderboardAggregate(ctx context.Context, filter domain.AttemptFilter) ([]domain.LeaderboardEntry, error)
+	// AttemptTimeSeries totals matching attempts per workflow, model and
+	// bucket, with buckets bucket long counted from the Unix epoch in UTC.
+	// Empty buckets are left out; filter.Limit is ignored.
+	AttemptTimeSeries(ctx context.Context, filter domain.AttemptFilter, bucket time.Duration) ([]domain.AttemptBucket, error)
+
+	// ListDailyRollups returns the per-day attempt totals matching filter,
+	// kept up to date as attempts are written. They outlive cold storage, so
+	// archived attempts stay in them until retention drops their day.
+	ListDailyRollups(ctx context.Context, filter domain.RollupFilter) ([]domain.DailyRollup, error)
+	// RebuildDailyRollups recomputes day's rollups from its hot and archived
+	// attempts, replacing what was counted as they were written.
+	RebuildDailyRollups(ctx context.Context, day time.Time) error
+
+	// SummarizeTelemetry returns run/attempt/event counts and attempt totals
+	// without loading the rows; averages are left for the caller.
+	SummarizeTelemetry(ctx context.Context) (domain.TelemetrySummary, error)
+
+	// PruneBefore removes run events and prompt attempts created before the
+	// given cutoffs; a zero cutoff leaves that table alone.
+	PruneBefore(ctx context.Context, runEventsBefore, attemptsBefore time.Time) (domain.PruneResult, error)
+	// ArchiveBefore moves prompt attempts and run events created before
+	// cutoff to cold storage, where lists skip them unless the filter sets
+	// IncludeArchived. PruneBefore applies to archived records too.
+	ArchiveBefore(ctx context.Context, cutoff time.Time) (domain.ArchiveResult, error)
+
+	ListRunEventsFiltered(ctx context.Context, filter domain.EventFilter) ([]domain.RunEvent, error)
+	ListRunEvents(ctx context.Context, runID string) ([]domain.RunEvent, error)
+	CountRunEvents(ctx context.Context) (int, error)
+	InsertRunEvent(ctx context.Context, event domain.RunEvent) error
 
-	ListTasks() ([]domain.Task, error)
-	UpsertTask(domain.Task) error
-	DeleteTask(id string) (bool, error)
+	ListArtifacts(ctx context.Context, filter domain.ArtifactFilter) ([]domain.Artifact, error)
+	InsertArtifact(ctx context.Context, artifact domain.Artifact) error
 
-	ListNotes() ([]domain.Note, error)
-	InsertNote(domain.Note) error
+	ListPromptReleases(ctx context.Context, filter domain.PromptReleaseFilter) ([]domain.PromptRelease, error)
+	InsertPromptRelease(ctx context.Context, release domain.PromptRelease) error
 
-	ListChangelog() ([]domain.ChangelogEntry, error)
-	InsertChangelog(domain.ChangelogEntry) error
+	ListPolicyAudit(ctx context.Context, filter domain.PolicyAuditFilter) ([]domain.PolicyAuditEntry, error)
+	InsertPolicyAudit(ctx context.Context, entry domain.PolicyAuditEntry) error
+}
 
-	ListBenchmarks() ([]domain.Benchmark, error)
-	InsertBenchmark(domain.Benchmark) error
+// BatchInserter is implemented by stores that write many attempts or run
+// events more cheaply in one call than one at a time.
+type BatchInserter interface {
+	InsertPromptAttempts(ctx context.Context, attempts []domain.PromptAttempt) error
+	InsertRunEvents(ctx context.Context, events []domain.RunEvent) error
+}
 
-	ListRunsFiltered(filter domain.RunFilter) ([]domain.AgentRun, error)
-	ListRuns() ([]domain.AgentRun, error)
-	InsertRun(domain.AgentRun) error
-	UpdateRun(domain.AgentRun) error
+// AttemptReader lists prompt attempts. The reader handed to a guarded
+// insert's check reads inside the insert's transaction.
+type AttemptReader interface {
+	ListPromptAttemptsFiltered(ctx context.Context, filter domain.AttemptFilter) ([]domain.PromptAttempt, error)
+}
 
-	ListPromptAttemptsFiltered(filter domain.AttemptFilter) ([]domain.PromptAttempt, error)
-	ListPromptAttempts(runID string) ([]domain.PromptAttempt, error)
-	InsertPromptAttempt(domain.PromptAttempt) error
+// AttemptLocks says which budgets a guarded attempt insert serializes on
+// besides its run: the agent's (AgentID set) and the hub-wide one (Hub).
+type AttemptLocks struct {
+	AgentID string
+	Hub     bool
+}
 
-	ListRunEventsFiltered(filter domain.EventFilter) ([]domain.RunEvent, error)
-	ListRunEvents(runID string) ([]domain.RunEvent, error)
-	InsertRunEvent(domain.RunEvent) error
+// GuardedAttemptInserter is implemented by stores that can run the budget
+// check and the insert of an attempt atomically: concurrent guarded inserts
+// sharing a run, agent or hub lock wait for each other, so two attempts
+// cannot both pass a check that only one of them fits under.
+type GuardedAttemptInserter interface {
+	InsertPromptAttemptGuarded(ctx context.Context, attempt domain.PromptAttempt, locks AttemptLocks, check func(AttemptReader) error) error
+}
+
+// PoolStats is a snapshot of one database connection pool.
+type PoolStats struct {
+	Name                 string  `json:"name"`
+	MaxConns             int32   `json:"max_conns"`
+	TotalConns           int32   `json:"total_conns"`
+	IdleConns            int32   `json:"idle_conns"`
+	AcquiredConns        int32   `json:"acquired_conns"`
+	AcquireCount         int64   `json:"acquire_count"`
+	EmptyAcquireCount    int64   `json:"empty_acquire_count"`
+	CanceledAcquireCount int64   `json:"canceled_acquire_count"`
+	AcquireWaitMS        float64 `json:"acquire_wait_ms"`
+}
+
+// PoolStatsReporter is implemented by stores backed by connection pools.
+type PoolStatsReporter interface {
+	PoolStats() []PoolStats
 }
 
 type AgentPrincipal struct {
@@ -49,8 +154,8 @@ type AgentPrincipal struct {
 
 // AgentKeyAuthenticator validates write API keys and returns the caller principal.
 type AgentKeyAuthenticator interface {
-	AuthenticateAgentKey(rawKey string) (AgentPrincipal, bool, error)
-	EnsureAgentKey(agentID, rawKey string) (keyID string, created bool, err error)
+	AuthenticateAgentKey(ctx context.Context, rawKey string) (AgentPrincipal, bool, error)
+	EnsureAgentKey(ctx context.Context, agentID, rawKey string) (keyID string, created bool, err error)
 }
 
 type IdempotencyRecord struct {
@@ -61,7 +166,14 @@ type IdempotencyRecord struct {
 
 // IdempotencyStore tracks dedupe keys for write RPC replay protection.
 type IdempotencyStore interface {
-	ReserveIdempotencyKey(method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error)
-	CompleteIdempotencyKey(method, idempotencyKey, responseJSON string) error
-	ReleaseIdempotencyKey(method, idempotencyKey string) error
+	ReserveIdempotencyKey(ctx context.Context, method, idempotencyKey, requestHash string) (IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, method, idempotencyKey, responseJSON string) error
+	ReleaseIdempotencyKey(ctx context.Context, method, idempotencyKey string) error
+}
+
+// ArtifactBlobStore holds raw artifact bytes; metadata lives in HubStore.
+type ArtifactBlobStore interface {
+	PutArtifactBlob(id string, content []byte) error
+	GetArtifactBlob(id string) ([]byte, error)
+	DeleteArtifactBlob(id string) error
 }
@@ -0,0 +1,294 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	exportQueueSize = 2048
+	exportBatchSize = 512
+	exportInterval  = 5 * time.Second
+	exportTimeout   = 10 * time.Second
+	instrumentScope = "github.com/bcrosbie/modeloman"
+)
+
+// ExporterConfig configures an OTLP/HTTP trace exporter, normally from the
+// standard OTEL_* environment variables.
+type ExporterConfig struct {
+	// Endpoint is the full traces URL, such as
+	// http://collector:4318/v1/traces.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	// ResourceAttributes are added to service.name on the exported resource.
+	ResourceAttributes map[string]string
+}
+
+// ParseKeyValues reads OTEL_EXPORTER_OTLP_HEADERS and
+// OTEL_RESOURCE_ATTRIBUTES style lists of key=value entries, skipping
+// entries without a key.
+func ParseKeyValues(items []string) map[string]string {
+	out := map[string]string{}
+	for _, item := range items {
+		key, value, _ := strings.Cut(item, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			out[key] = strings.TrimSpace(value)
+		}
+	}
+	return out
+}
+
+// Exporter batches ended spans and posts them as OTLP/HTTP JSON. Spans are
+// queued without blocking and dropped when the queue is full, so a slow or
+// unreachable collector never holds up requests.
+type Exporter struct {
+	config ExporterConfig
+	client *http.Client
+
+	queue     chan *Span
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+// NewExporter starts an exporter posting to config.Endpoint.
+func NewExporter(config ExporterConfig) *Exporter {
+	e := newExporter(config)
+	go e.run()
+	return e
+}
+
+func newExporter(config ExporterConfig) *Exporter {
+	if config.ServiceName == "" {
+		config.ServiceName = "modeloman"
+	}
+	return &Exporter{
+		config:  config,
+		client:  &http.Client{Timeout: exportTimeout},
+		queue:   make(chan *Span, exportQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (e *Exporter) enqueue(span *Span) {
+	select {
+	case <-e.done:
+		return
+	default:
+	}
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Shutdown exports what is queued and stops, giving up when ctx ends.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	e.closeOnce.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= exportBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(batch []*Span) {
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		slog.Warn("tracing: export queue full, dropped spans", "spans", dropped)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := e.post(ctx, batch); err != nil {
+		slog.Error("tracing: failed to export spans", "spans", len(batch), "err", err)
+	}
+}
+
+func (e *Exporter) post(ctx context.Context, batch []*Span) error {
+	body, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.config.Headers {
+		request.Header.Set(key, value)
+	}
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", response.Status)
+	}
+	return nil
+}
+
+// The types below are the subset of the OTLP JSON encoding the exporter
+// writes. IDs are hex and 64-bit integers are decimal strings, as the
+// protobuf JSON mapping for OTLP requires.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *Exporter) encode(batch []*Span) otlpRequest {
+	resource := []otlpKeyValue{keyValue(String("service.name", e.config.ServiceName))}
+	for key, value := range e.config.ResourceAttributes {
+		if key != "service.name" {
+			resource = append(resource, keyValue(String(key, value)))
+		}
+	}
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		span.mu.Lock()
+		encoded := otlpSpan{
+			TraceID:           hex.EncodeToString(span.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.context.SpanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: span.status, Message: span.message},
+		}
+		if span.parentID != [8]byte{} {
+			encoded.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		}
+		for _, attribute := range span.attributes {
+			encoded.Attributes = append(encoded.Attributes, keyValue(attribute))
+		}
+		span.mu.Unlock()
+		spans = append(spans, encoded)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentScope}, Spans: spans}},
+	}}}
+}
+
+func keyValue(attribute Attribute) otlpKeyValue {
+	value := otlpValue{}
+	switch typed := attribute.Value.(type) {
+	case string:
+		value.StringValue = &typed
+	case int64:
+		text := strconv.FormatInt(typed, 10)
+		value.IntValue = &text
+	case int:
+		text := strconv.Itoa(typed)
+		value.IntValue = &text
+	case float64:
+		value.DoubleValue = &typed
+	case bool:
+		value.BoolValue = &typed
+	default:
+		text := fmt.Sprint(typed)
+		value.StringValue = &text
+	}
+	return otlpKeyValue{Key: attribute.Key, Value: value}
+}
@@ -0,0 +1,262 @@
+// Package tracing records OpenTelemetry-compatible spans for the gRPC
+// transport, the hub service and store queries, and exports them over
+// OTLP/HTTP. Until SetProvider installs a provider every span is a no-op,
+// so instrumented code pays only for a context lookup.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind follows the OTLP enum values.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// Attribute is one span attribute. Values are strings, int64s, float64s or
+// bools; anything else is exported as its string form.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute          { return Attribute{Key: key, Value: value} }
+func Int64(key string, value int64) Attribute     { return Attribute{Key: key, Value: value} }
+func Bool(key string, value bool) Attribute       { return Attribute{Key: key, Value: value} }
+func Float64(key string, value float64) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanContext identifies a span for propagation.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set, as W3C trace context requires.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent renders sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent reads a W3C traceparent header value. Versions other
+// than 00 are accepted as long as the first four fields parse, as the spec
+// asks of implementations that do not know the version.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Span is one timed operation. All methods are safe on a nil Span, which is
+// what Start returns while tracing is off.
+type Span struct {
+	provider *Provider
+	context  SpanContext
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	status     int
+	message    string
+	ended      bool
+}
+
+// SpanContext is the span's identity, for propagation to downstream calls.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes adds or replaces attributes on the span.
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+outer:
+	for _, attribute := range attributes {
+		for i := range s.attributes {
+			if s.attributes[i].Key == attribute.Key {
+				s.attributes[i] = attribute
+				continue outer
+			}
+		}
+		s.attributes = append(s.attributes, attribute)
+	}
+}
+
+// RecordError marks the span failed with err's message. A nil err is
+// ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.context.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.status = statusError
+	s.message = err.Error()
+}
+
+// SetOK marks the span succeeded, overriding an earlier RecordError.
+func (s *Span) SetOK() {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.status = statusOK
+		s.message = ""
+	}
+}
+
+// End finishes the span and hands it to the exporter. Only the first call
+// counts.
+func (s *Span) End() {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.provider.exporter.enqueue(s)
+}
+
+// Provider starts spans and sends the sampled ones to its exporter.
+type Provider struct {
+	exporter *Exporter
+	// sampleBelow is the ratio sampler's threshold on the low 63 bits of a
+	// new trace ID.
+	sampleBelow uint64
+}
+
+// NewProvider samples ratio of new traces, clamped to [0, 1], and exports
+// them through exporter. Spans with a remote parent follow the parent's
+// sampling decision instead.
+func NewProvider(exporter *Exporter, ratio float64) *Provider {
+	ratio = min(max(ratio, 0), 1)
+	return &Provider{exporter: exporter, sampleBelow: uint64(ratio * (1 << 63))}
+}
+
+var global atomic.Pointer[Provider]
+
+// SetProvider installs p for Start; nil turns tracing off.
+func SetProvider(p *Provider) {
+	global.Store(p)
+}
+
+// Enabled reports whether a provider is installed.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the span Start stored in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent makes sc, typically parsed from an incoming
+// traceparent, the parent of the next span started from ctx.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Start begins an internal span named name under the span in ctx. Callers
+// must End the span it returns; the returned context carries it as the
+// parent of spans started from it.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attributes...)
+}
+
+// StartKind is Start with an explicit span kind.
+func StartKind(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	provider := global.Load()
+	if provider == nil {
+		return ctx, nil
+	}
+	span := &Span{provider: provider, name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.context.TraceID = parent.context.TraceID
+		span.context.Sampled = parent.context.Sampled
+		span.parentID = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.context.TraceID = remote.TraceID
+		span.context.Sampled = remote.Sampled
+		span.parentID = remote.SpanID
+	} else {
+		_, _ = rand.Read(span.context.TraceID[:])
+		span.context.Sampled = binary.BigEndian.Uint64(span.context.TraceID[8:])&(1<<63-1) < provider.sampleBelow
+	}
+	_, _ = rand.Read(span.context.SpanID[:])
+	span.SetAttributes(attributes...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || !sc.Sampled {
+		t.Fatalf("expected a sampled span context, got %+v ok=%v", sc, ok)
+	}
+	if got := sc.Traceparent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("traceparent did not round-trip: %s", got)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(invalid); ok {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Fatalf("expected a future version with extra fields to be accepted")
+	}
+}
+
+func TestStartIsNoopWithoutProvider(t *testing.T) {
+	SetProvider(nil)
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatalf("expected no span without a provider")
+	}
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	span.End()
+}
+
+func TestExporterPostsNestedSpans(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected the configured header, got %q", r.Header.Get("Authorization"))
+		}
+		var body otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode export: %v", err)
+		}
+		received <- body
+	}))
+	defer server.Close()
+
+	exporter := NewExporter(ExporterConfig{
+		Endpoint:    server.URL + "/v1/traces",
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+		ServiceName: "modeloman-test",
+	})
+	SetProvider(NewProvider(exporter, 1))
+	defer SetProvider(nil)
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithRemoteParent(context.Background(), remote)
+	ctx, parent := StartKind(ctx, "HubService.RecordPromptAttempt", KindServer, String("rpc.system", "grpc"))
+	_, child := Start(ctx, "store.InsertPromptAttempt", Int64("db.rows", 1))
+	child.RecordError(errors.New("insert failed"))
+	child.End()
+	parent.End()
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(shutdown); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	var body otlpRequest
+	select {
+	case body = <-received:
+	default:
+		t.Fatalf("expected the spans to be exported on shutdown")
+	}
+
+	resource := body.ResourceSpans[0]
+	if name := *resource.Resource.Attributes[0].Value.StringValue; name != "modeloman-test" {
+		t.Fatalf("unexpected service.name %q", name)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected two spans, got %+v", spans)
+	}
+	exportedChild, exportedParent := spans[0], spans[1]
+	if exportedParent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || exportedParent.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("expected the server span under the remote parent, got %+v", exportedParent)
+	}
+	if exportedChild.TraceID != exportedParent.TraceID || exportedChild.ParentSpanID != exportedParent.SpanID {
+		t.Fatalf("expected the store span under the server span, got %+v", exportedChild)
+	}
+	if exportedChild.Status.Code != statusError || exportedChild.Status.Message != "insert failed" {
+		t.Fatalf("expected the child's error status, got %+v", exportedChild.Status)
+	}
+	if exportedParent.Kind != KindServer || *exportedChild.Attributes[0].Value.IntValue != "1" {
+		t.Fatalf("unexpected kind or attributes: %+v %+v", exportedParent, exportedChild)
+	}
+}
+
+func TestUnsampledTracesAreNotExported(t *testing.T) {
+	exporter := newExporter(ExporterConfig{Endpoint: "http://127.0.0.1:0"})
+	SetProvider(NewProvider(exporter, 0))
+	defer SetProvider(nil)
+
+	ctx, parent := Start(context.Background(), "root")
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+	if parent.SpanContext().Sampled || len(exporter.queue) != 0 {
+		t.Fatalf("expected a zero ratio to drop new traces")
+	}
+	if child.SpanContext().TraceID != parent.SpanContext().TraceID {
+		t.Fatalf("expected unsampled spans to still propagate the trace ID")
+	}
+}
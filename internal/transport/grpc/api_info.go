@@ -0,0 +1,49 @@
+package grpcx
+
+import "github.com/bcrosbie/modeloman/internal/rpccontract"
+
+// APIInfo is GetAPIInfo's response: what a client needs to check it can
+// talk to this server before relying on a method.
+type APIInfo struct {
+	Service           string          `json:"service"`
+	APIVersion        string          `json:"api_version"`
+	SupportedVersions []string        `json:"supported_versions"`
+	Methods           []APIMethodInfo `json:"methods"`
+}
+
+type APIMethodInfo struct {
+	Name string `json:"name"`
+	// Access is "public", "read" (authenticated) or "write".
+	Access        string `json:"access"`
+	Scope         string `json:"scope,omitempty"`
+	ProjectScoped bool   `json:"project_scoped"`
+	Deprecated    string `json:"deprecated,omitempty"`
+}
+
+// apiInfo describes every registered hub method in descriptor order.
+func apiInfo() APIInfo {
+	info := APIInfo{
+		Service:           rpccontract.ServiceName,
+		APIVersion:        rpccontract.APIVersion,
+		SupportedVersions: rpccontract.SupportedAPIVersions,
+		Methods:           make([]APIMethodInfo, 0, len(hubServiceDesc.Methods)),
+	}
+	for _, method := range hubServiceDesc.Methods {
+		fullMethod := "/" + rpccontract.ServiceName + "/" + method.MethodName
+		access := "public"
+		if _, ok := rpccontract.WriteMethods[fullMethod]; ok {
+			access = "write"
+		} else if rpccontract.RequiresAuthentication(fullMethod) {
+			access = "read"
+		}
+		scope, _ := rpccontract.RequiredScope(fullMethod)
+		info.Methods = append(info.Methods, APIMethodInfo{
+			Name:          method.MethodName,
+			Access:        access,
+			Scope:         scope,
+			ProjectScoped: rpccontract.IsProjectScoped(fullMethod),
+			Deprecated:    rpccontract.DeprecatedMethods[fullMethod],
+		})
+	}
+	return info
+}
@@ -2,21 +2,28 @@ package grpcx
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bcrosbie/modeloman/internal/domain"
 	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/service"
 	"github.com/bcrosbie/modeloman/internal/store"
+	"github.com/bcrosbie/modeloman/internal/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -27,6 +34,10 @@ import (
 
 type principalContextKey struct{}
 
+// maxRequestIDLength bounds caller-supplied x-request-id values; longer or
+// non-printable ones are replaced with a generated ID.
+const maxRequestIDLength = 128
+
 type TokenBucketRateLimiterConfig struct {
 	AuthenticatedPerSecond   float64
 	AuthenticatedBurst       float64
@@ -156,7 +167,7 @@ func IdempotencyUnaryInterceptor(idStore store.IdempotencyStore) grpc.UnaryServe
 		if err != nil {
 			return nil, err
 		}
-		record, created, err := idStore.ReserveIdempotencyKey(info.FullMethod, idempotencyKey, requestHash)
+		record, created, err := idStore.ReserveIdempotencyKey(ctx, info.FullMethod, idempotencyKey, requestHash)
 		if err != nil {
 			return nil, err
 		}
@@ -175,23 +186,115 @@ func IdempotencyUnaryInterceptor(idStore store.IdempotencyStore) grpc.UnaryServe
 		}
 
 		response, err := handler(ctx, req)
+		// The key is settled even when the caller has gone away, or a
+		// cancelled call would leave it in progress until it expires.
+		settleCtx := context.WithoutCancel(ctx)
 		if err != nil {
-			_ = idStore.ReleaseIdempotencyKey(info.FullMethod, idempotencyKey)
+			_ = idStore.ReleaseIdempotencyKey(settleCtx, info.FullMethod, idempotencyKey)
 			return nil, err
 		}
 		encodedResponse, err := encodeIdempotentResponse(response)
 		if err != nil {
-			_ = idStore.ReleaseIdempotencyKey(info.FullMethod, idempotencyKey)
+			_ = idStore.ReleaseIdempotencyKey(settleCtx, info.FullMethod, idempotencyKey)
 			return nil, err
 		}
-		if err := idStore.CompleteIdempotencyKey(info.FullMethod, idempotencyKey, encodedResponse); err != nil {
+		if err := idStore.CompleteIdempotencyKey(settleCtx, info.FullMethod, idempotencyKey, encodedResponse); err != nil {
 			return nil, err
 		}
 		return response, nil
 	}
 }
 
-func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+// RequestIDUnaryInterceptor gives every call a request ID: the caller's
+// x-request-id when it is usable, otherwise a generated one. The ID is sent
+// back in the x-request-id response header and tagged onto the log lines of
+// the interceptors after it, server-side error messages and the run events
+// the call records, so it belongs first in the chain.
+func RequestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		requestID := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			requestID = strings.TrimSpace(first(md.Get("x-request-id")))
+		}
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID)); err != nil {
+			slog.WarnContext(ctx, "request id header failed", "method", info.FullMethod, "request_id", requestID, "err", err)
+		}
+		return handler(service.WithRequestID(ctx, requestID), req)
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "req_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "req_" + hex.EncodeToString(raw)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	return service.RequestIDFromContext(ctx)
+}
+
+// TracingUnaryInterceptor starts a server span for each hub RPC, continuing
+// the caller's trace when it sends a W3C traceparent. It belongs right after
+// RequestIDUnaryInterceptor, so the span covers the rest of the chain and
+// sees the status code the caller gets.
+func TracingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !tracing.Enabled() || !strings.HasPrefix(info.FullMethod, "/"+rpccontract.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if parent, ok := tracing.ParseTraceparent(first(md.Get("traceparent"))); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, parent)
+			}
+		}
+		method := strings.TrimPrefix(info.FullMethod, "/"+rpccontract.ServiceName+"/")
+		ctx, span := tracing.StartKind(ctx, strings.TrimPrefix(info.FullMethod, "/"), tracing.KindServer,
+			tracing.String("rpc.system", "grpc"),
+			tracing.String("rpc.service", rpccontract.ServiceName),
+			tracing.String("rpc.method", method),
+			tracing.String("modeloman.request_id", requestIDFromContext(ctx)),
+		)
+		defer span.End()
+		response, err := handler(ctx, req)
+		code := status.Code(err)
+		span.SetAttributes(tracing.Int64("rpc.grpc.status_code", int64(code)))
+		if err != nil {
+			span.RecordError(err)
+		}
+		return response, err
+	}
+}
+
+// RecoveryUnaryInterceptor turns handler panics into Internal errors and
+// reports each one, with a fingerprint of its stack, to recorders.
+func RecoveryUnaryInterceptor(recorders ...PanicRecorder) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
@@ -200,15 +303,103 @@ func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
 	) (response any, err error) {
 		defer func() {
 			if recovered := recover(); recovered != nil {
-				log.Printf("panic recovered method=%s panic=%v\n%s", info.FullMethod, recovered, string(debug.Stack()))
-				err = status.Error(codes.Internal, "internal server error")
+				report := service.PanicReport{
+					Method:      info.FullMethod,
+					RequestID:   requestIDFromContext(ctx),
+					Fingerprint: panicFingerprint(recovered),
+					Message:     fmt.Sprint(recovered),
+					Stack:       string(debug.Stack()),
+				}
+				slog.ErrorContext(ctx, "panic recovered", "method", report.Method, "request_id", report.RequestID, "fingerprint", report.Fingerprint, "panic", report.Message, "stack", report.Stack)
+				// The caller may be gone; the record should still land.
+				recordCtx := context.WithoutCancel(ctx)
+				for _, recorder := range recorders {
+					if recordErr := recorder.RecordPanic(recordCtx, report); recordErr != nil {
+						slog.WarnContext(ctx, "panic record failed", "fingerprint", report.Fingerprint, "err", recordErr)
+					}
+				}
+				err = withRequestID(ctx, status.Error(codes.Internal, "internal server error"))
 			}
 		}()
 		return handler(ctx, req)
 	}
 }
 
-func AuthUnaryInterceptor(token string, allowLegacyToken bool, keyAuth store.AgentKeyAuthenticator) grpc.UnaryServerInterceptor {
+// PanicRecorder receives each panic RecoveryUnaryInterceptor recovers.
+type PanicRecorder interface {
+	RecordPanic(ctx context.Context, report service.PanicReport) error
+}
+
+// panicFingerprint hashes the panic value's type and the functions between
+// the panic and this interceptor, skipping the runtime's own frames. Line
+// numbers and values are left out, so the fingerprint survives unrelated
+// edits and differing inputs, and frames outside the interceptor are left
+// out so gRPC and HTTP calls share it. It must be called from the deferred
+// recover.
+func panicFingerprint(recovered any) string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%T\n", recovered)
+	for {
+		frame, more := frames.Next()
+		if strings.Contains(frame.Function, ".RecoveryUnaryInterceptor.") {
+			break
+		}
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			fmt.Fprintln(hash, frame.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// AuthUnaryInterceptor authenticates private and write methods. Tokens are
+// tried against the verifiers (e.g. a JWTVerifier) first, then agent API keys,
+// then the legacy shared token when enabled.
+// Authenticator resolves a bearer token the way the gRPC server does: a JWT
+// one of the verifiers accepts, then an agent key, then the legacy shared
+// token when allowed. The HTTP dashboard uses it to accept agent credentials.
+type Authenticator struct {
+	token            string
+	allowLegacyToken bool
+	keyAuth          store.AgentKeyAuthenticator
+	verifiers        []TokenVerifier
+}
+
+func NewAuthenticator(token string, allowLegacyToken bool, keyAuth store.AgentKeyAuthenticator, verifiers ...TokenVerifier) *Authenticator {
+	return &Authenticator{token: token, allowLegacyToken: allowLegacyToken, keyAuth: keyAuth, verifiers: verifiers}
+}
+
+// Authenticate returns the principal requestToken stands for. ok is false
+// when no credential matches; err reports a verifier or key store failure.
+func (a *Authenticator) Authenticate(ctx context.Context, requestToken string) (store.AgentPrincipal, bool, error) {
+	for _, verifier := range a.verifiers {
+		principal, ok, err := verifier.VerifyToken(ctx, requestToken)
+		if err != nil || ok {
+			return principal, ok, err
+		}
+	}
+	if a.keyAuth != nil {
+		principal, ok, err := a.keyAuth.AuthenticateAgentKey(ctx, requestToken)
+		if err != nil || ok {
+			return principal, ok, err
+		}
+	}
+	if a.allowLegacyToken && a.token != "" && legacyTokenMatch(requestToken, a.token) {
+		return store.AgentPrincipal{
+			AgentID: "legacy-shared-token",
+			KeyID:   "legacy_shared_token",
+			Scopes:  append([]string(nil), rpccontract.DefaultAgentKeyScopes...),
+		}, true, nil
+	}
+	return store.AgentPrincipal{}, false, nil
+}
+
+func AuthUnaryInterceptor(token string, allowLegacyToken bool, keyAuth store.AgentKeyAuthenticator, verifiers ...TokenVerifier) grpc.UnaryServerInterceptor {
+	authenticator := NewAuthenticator(token, allowLegacyToken, keyAuth, verifiers...)
 	return func(
 		ctx context.Context,
 		req any,
@@ -224,30 +415,11 @@ func AuthUnaryInterceptor(token string, allowLegacyToken bool, keyAuth store.Age
 			return nil, status.Error(codes.Unauthenticated, "missing authentication token")
 		}
 
-		var principal store.AgentPrincipal
-		authenticated := false
-
-		if keyAuth != nil {
-			authenticatedPrincipal, ok, err := keyAuth.AuthenticateAgentKey(requestToken)
-			if err != nil {
-				log.Printf("auth validation failure method=%s err=%v", info.FullMethod, err)
-				return nil, status.Error(codes.Internal, "authentication subsystem unavailable")
-			}
-			if ok {
-				principal = authenticatedPrincipal
-				authenticated = true
-			}
-		}
-
-		if !authenticated && allowLegacyToken && token != "" && legacyTokenMatch(requestToken, token) {
-			principal = store.AgentPrincipal{
-				AgentID: "legacy-shared-token",
-				KeyID:   "legacy_shared_token",
-				Scopes:  append([]string(nil), rpccontract.DefaultAgentKeyScopes...),
-			}
-			authenticated = true
+		principal, authenticated, err := authenticator.Authenticate(ctx, requestToken)
+		if err != nil {
+			slog.ErrorContext(ctx, "auth validation failure", "method", info.FullMethod, "request_id", requestIDFromContext(ctx), "err", err)
+			return nil, status.Error(codes.Internal, "authentication subsystem unavailable")
 		}
-
 		if !authenticated {
 			return nil, status.Error(codes.Unauthenticated, "invalid authentication token")
 		}
@@ -255,11 +427,42 @@ func AuthUnaryInterceptor(token string, allowLegacyToken bool, keyAuth store.Age
 		if requiredScope, hasRequiredScope := rpccontract.RequiredScope(info.FullMethod); hasRequiredScope && !hasScope(principal.Scopes, requiredScope) {
 			return nil, status.Error(codes.PermissionDenied, "api key scope does not allow this method")
 		}
-		log.Printf("authenticated method=%s agent_id=%s key_id=%s", info.FullMethod, principal.AgentID, principal.KeyID)
+		if err := applyProjectScope(ctx, info.FullMethod, principal, req); err != nil {
+			return nil, err
+		}
+		slog.DebugContext(ctx, "authenticated", "method", info.FullMethod, "request_id", requestIDFromContext(ctx), "agent_id", principal.AgentID, "key_id", principal.KeyID)
 		return handler(withPrincipal(ctx, principal), req)
 	}
 }
 
+// LatencyObserver receives each hub RPC's server-side duration.
+type LatencyObserver interface {
+	ObserveRPC(method string, elapsed time.Duration)
+}
+
+// LatencyUnaryInterceptor reports hub RPC durations to observer; health
+// checks and reflection are not counted. A nil observer disables it.
+func LatencyUnaryInterceptor(observer LatencyObserver) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if observer == nil || !strings.HasPrefix(info.FullMethod, "/"+rpccontract.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		started := time.Now()
+		response, err := handler(ctx, req)
+		observer.ObserveRPC(info.FullMethod, time.Since(started))
+		return response, err
+	}
+}
+
+// LoggingUnaryInterceptor logs each call's method, request ID, agent, run
+// (when the request or a StartRun response names one), duration and status
+// code. Calls failing on the server side log at error level, the rest at
+// info. It runs after AuthUnaryInterceptor so the agent is known.
 func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -269,11 +472,41 @@ func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
 	) (any, error) {
 		started := time.Now()
 		response, err := handler(ctx, req)
-		log.Printf("grpc method=%s duration=%s code=%s", info.FullMethod, time.Since(started), status.Code(err))
+		code := status.Code(err)
+		attrs := []any{"method", info.FullMethod, "request_id", requestIDFromContext(ctx)}
+		if principal, ok := principalFromContext(ctx); ok {
+			attrs = append(attrs, "agent_id", principal.AgentID)
+		}
+		if runID := loggedRunID(info.FullMethod, req, response); runID != "" {
+			attrs = append(attrs, "run_id", runID)
+		}
+		attrs = append(attrs, "duration_ms", float64(time.Since(started).Microseconds())/1000, "code", code.String())
+		level := slog.LevelInfo
+		switch code {
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "grpc request", attrs...)
 		return response, err
 	}
 }
 
+// loggedRunID is the request's run_id, or the ID of the run StartRun
+// created.
+func loggedRunID(fullMethod string, req, response any) string {
+	if request, ok := req.(*structpb.Struct); ok {
+		if runID := request.GetFields()["run_id"].GetStringValue(); runID != "" {
+			return runID
+		}
+	}
+	if fullMethod == "/"+rpccontract.ServiceName+"/StartRun" {
+		if run, ok := response.(*structpb.Struct); ok {
+			return run.GetFields()["id"].GetStringValue()
+		}
+	}
+	return ""
+}
+
 func ErrorUnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -290,11 +523,101 @@ func ErrorUnaryInterceptor() grpc.UnaryServerInterceptor {
 			return nil, err
 		}
 
-		return nil, mapError(err)
+		return nil, withRequestID(ctx, mapError(err))
+	}
+}
+
+// withRequestID appends the call's request ID to Internal errors, whose
+// messages say little on their own, so a client that only logs the error
+// still has the ID to find the server's side of the failure.
+func withRequestID(ctx context.Context, err error) error {
+	requestID := requestIDFromContext(ctx)
+	converted := status.Convert(err)
+	if requestID == "" || converted.Code() != codes.Internal {
+		return err
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("%s (request_id=%s)", converted.Message(), requestID))
+}
+
+// warningHeader carries the warnings a call raised, one value each, such as
+// a list the server's guardrails truncated.
+const warningHeader = "x-modeloman-warning"
+
+// APIVersionUnaryInterceptor answers every hub call with the server's API
+// version and rejects callers that ask, through the same header, for a
+// version the server does not support. Calls to deprecated methods also get
+// the deprecation note in x-modeloman-deprecated and a log line, so
+// stragglers can be found before the method is removed.
+func APIVersionUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, "/"+rpccontract.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		header := metadata.Pairs(rpccontract.APIVersionHeader, rpccontract.APIVersion)
+		note, deprecated := rpccontract.DeprecatedMethods[info.FullMethod]
+		if deprecated {
+			header.Set(rpccontract.DeprecationHeader, note)
+		}
+		if err := grpc.SetHeader(ctx, header); err != nil {
+			slog.WarnContext(ctx, "api version header failed", "method", info.FullMethod, "request_id", requestIDFromContext(ctx), "err", err)
+		}
+		requested := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			requested = strings.TrimSpace(first(md.Get(rpccontract.APIVersionHeader)))
+		}
+		if requested != "" && !rpccontract.SupportsAPIVersion(requested) {
+			return nil, status.Errorf(codes.FailedPrecondition, "API version %q is not supported; this server supports %s", requested, strings.Join(rpccontract.SupportedAPIVersions, ", "))
+		}
+		if deprecated {
+			slog.WarnContext(ctx, "deprecated method called", "method", info.FullMethod, "request_id", requestIDFromContext(ctx), "note", note)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// WarningUnaryInterceptor collects the warnings the service raises while
+// handling a call and sends them back in x-modeloman-warning response
+// headers. Failed calls send none.
+func WarningUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, warnings := service.WithWarnings(ctx)
+		response, err := handler(ctx, req)
+		if err != nil {
+			return response, err
+		}
+		if raised := warnings(); len(raised) > 0 {
+			pairs := make([]string, 0, 2*len(raised))
+			for _, warning := range raised {
+				pairs = append(pairs, warningHeader, warning)
+			}
+			if err := grpc.SetHeader(ctx, metadata.Pairs(pairs...)); err != nil {
+				slog.WarnContext(ctx, "warning header failed", "method", info.FullMethod, "request_id", requestIDFromContext(ctx), "err", err)
+			}
+		}
+		return response, nil
 	}
 }
 
 func mapError(err error) error {
+	// Stores wrap a query cut short by the caller as Internal; report it as
+	// what it was.
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
 	var appError *domain.AppError
 	if errors.As(err, &appError) {
 		switch appError.Code {
@@ -337,6 +660,57 @@ func extractToken(ctx context.Context) string {
 	return ""
 }
 
+// applyProjectScope resolves the project for project-aware methods (request
+// field first, then x-modeloman-project header) and pins it onto the request
+// so the service layer filters by it. Keys carrying project:<name> scopes may
+// only touch those projects.
+func applyProjectScope(ctx context.Context, fullMethod string, principal store.AgentPrincipal, req any) error {
+	allowed, restricted := rpccontract.AllowedProjects(principal.Scopes)
+	if !rpccontract.IsProjectScoped(fullMethod) {
+		if restricted {
+			return status.Error(codes.PermissionDenied, "api key is limited to projects and cannot call hub-wide methods")
+		}
+		return nil
+	}
+
+	requestStruct, ok := req.(*structpb.Struct)
+	if !ok || requestStruct == nil {
+		if restricted {
+			return status.Error(codes.InvalidArgument, "project-scoped methods require an object request payload")
+		}
+		return nil
+	}
+
+	project := ""
+	if value, exists := requestStruct.GetFields()["project"]; exists {
+		project = strings.ToLower(strings.TrimSpace(value.GetStringValue()))
+	}
+	if project == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			project = strings.ToLower(strings.TrimSpace(first(md.Get("x-modeloman-project"))))
+		}
+	}
+	if restricted {
+		if project == "" {
+			if len(allowed) != 1 {
+				return status.Error(codes.InvalidArgument, "project is required for keys scoped to multiple projects")
+			}
+			project = allowed[0]
+		}
+		if !slices.Contains(allowed, project) {
+			return status.Error(codes.PermissionDenied, "api key scope does not allow this project")
+		}
+	}
+	if project == "" {
+		return nil
+	}
+	if requestStruct.Fields == nil {
+		requestStruct.Fields = map[string]*structpb.Value{}
+	}
+	requestStruct.Fields["project"] = structpb.NewStringValue(project)
+	return nil
+}
+
 func first(items []string) string {
 	if len(items) == 0 {
 		return ""
@@ -346,7 +720,7 @@ func first(items []string) string {
 
 func hasScope(scopes []string, required string) bool {
 	for _, scope := range scopes {
-		if strings.TrimSpace(scope) == required {
+		if rpccontract.GrantsScope(scope, required) {
 			return true
 		}
 	}
@@ -10,7 +10,9 @@ import (
 
 	"github.com/bcrosbie/modeloman/internal/domain"
 	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/service"
 	"github.com/bcrosbie/modeloman/internal/store"
+	"github.com/bcrosbie/modeloman/internal/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -25,11 +27,11 @@ type staticKeyAuth struct {
 	err       error
 }
 
-func (s staticKeyAuth) AuthenticateAgentKey(rawKey string) (store.AgentPrincipal, bool, error) {
+func (s staticKeyAuth) AuthenticateAgentKey(ctx context.Context, rawKey string) (store.AgentPrincipal, bool, error) {
 	return s.principal, s.ok, s.err
 }
 
-func (s staticKeyAuth) EnsureAgentKey(agentID, rawKey string) (string, bool, error) {
+func (s staticKeyAuth) EnsureAgentKey(ctx context.Context, agentID, rawKey string) (string, bool, error) {
 	return "", false, nil
 }
 
@@ -44,7 +46,7 @@ func newFakeIdempotencyStore() *fakeIdempotencyStore {
 	}
 }
 
-func (s *fakeIdempotencyStore) ReserveIdempotencyKey(method, idempotencyKey, requestHash string) (store.IdempotencyRecord, bool, error) {
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, method, idempotencyKey, requestHash string) (store.IdempotencyRecord, bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -59,7 +61,7 @@ func (s *fakeIdempotencyStore) ReserveIdempotencyKey(method, idempotencyKey, req
 	return store.IdempotencyRecord{}, true, nil
 }
 
-func (s *fakeIdempotencyStore) CompleteIdempotencyKey(method, idempotencyKey, responseJSON string) error {
+func (s *fakeIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, method, idempotencyKey, responseJSON string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -74,7 +76,7 @@ func (s *fakeIdempotencyStore) CompleteIdempotencyKey(method, idempotencyKey, re
 	return nil
 }
 
-func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(method, idempotencyKey string) error {
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, method, idempotencyKey string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -129,6 +131,90 @@ func TestAuthInterceptorRejectsMissingScope(t *testing.T) {
 	}
 }
 
+func TestAuthInterceptorExportScopes(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		want   codes.Code
+	}{
+		{scopes: []string{rpccontract.ScopeAdminRead}, want: codes.PermissionDenied},
+		{scopes: []string{rpccontract.ScopeExport}, want: codes.OK},
+		{scopes: []string{rpccontract.ScopeExportFull}, want: codes.OK},
+	}
+	for _, tc := range cases {
+		keyAuth := staticKeyAuth{
+			principal: store.AgentPrincipal{AgentID: "a1", KeyID: "k1", Scopes: tc.scopes},
+			ok:        true,
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-modeloman-token", "agent-key"))
+		interceptor := AuthUnaryInterceptor("", false, keyAuth)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{
+			FullMethod: rpccontract.MethodExportState,
+		}, func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		})
+		if status.Code(err) != tc.want {
+			t.Fatalf("scopes %v: expected %s, got %s", tc.scopes, tc.want, status.Code(err))
+		}
+	}
+}
+
+func TestAuthInterceptorPinsProjectScopedKey(t *testing.T) {
+	keyAuth := staticKeyAuth{
+		principal: store.AgentPrincipal{
+			AgentID: "a1",
+			KeyID:   "k1",
+			Scopes:  []string{rpccontract.ScopeAdminRead, rpccontract.ProjectScopePrefix + "payments"},
+		},
+		ok: true,
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-modeloman-token", "agent-key"))
+	interceptor := AuthUnaryInterceptor("", false, keyAuth)
+
+	request := mustStruct(t, map[string]any{"status": "running"})
+	_, err := interceptor(ctx, request, &grpc.UnaryServerInfo{
+		FullMethod: rpccontract.MethodListRuns,
+	}, func(ctx context.Context, req any) (any, error) {
+		project := req.(*structpb.Struct).GetFields()["project"].GetStringValue()
+		if project != "payments" {
+			t.Fatalf("expected project to be pinned to payments, got %q", project)
+		}
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	_, err = interceptor(ctx, mustStruct(t, map[string]any{"project": "search"}), &grpc.UnaryServerInfo{
+		FullMethod: rpccontract.MethodListRuns,
+	}, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for foreign project, got %s", status.Code(err))
+	}
+}
+
+func TestAuthInterceptorRejectsHubWideMethodForProjectKey(t *testing.T) {
+	keyAuth := staticKeyAuth{
+		principal: store.AgentPrincipal{
+			AgentID: "a1",
+			KeyID:   "k1",
+			Scopes:  []string{rpccontract.ScopeAdminRead, rpccontract.ProjectScopePrefix + "payments"},
+		},
+		ok: true,
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-modeloman-token", "agent-key"))
+	interceptor := AuthUnaryInterceptor("", false, keyAuth)
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{
+		FullMethod: rpccontract.MethodExportState,
+	}, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %s", status.Code(err))
+	}
+}
+
 func TestAuthInterceptorAllowsLegacyTokenWhenEnabled(t *testing.T) {
 	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-modeloman-token", "legacy-secret"))
 	interceptor := AuthUnaryInterceptor("legacy-secret", true, nil)
@@ -335,3 +421,371 @@ func TestIdempotencyInterceptorReleasesKeyOnHandlerError(t *testing.T) {
 		t.Fatalf("expected handler to run twice, ran %d times", handlerCalls)
 	}
 }
+
+func TestRequestIDInterceptorKeepsCallerIDAndGeneratesOtherwise(t *testing.T) {
+	interceptor := RequestIDUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodGetHealth}
+	seen := func(ctx context.Context) string {
+		var requestID string
+		_, _ = interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			requestID = requestIDFromContext(ctx)
+			return "ok", nil
+		})
+		return requestID
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "support-123"))
+	if got := seen(ctx); got != "support-123" {
+		t.Fatalf("expected caller request id, got %q", got)
+	}
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "has space"))
+	if got := seen(ctx); !strings.HasPrefix(got, "req_") {
+		t.Fatalf("expected generated request id for invalid input, got %q", got)
+	}
+	if first, second := seen(context.Background()), seen(context.Background()); first == second || !strings.HasPrefix(first, "req_") {
+		t.Fatalf("expected distinct generated request ids, got %q and %q", first, second)
+	}
+}
+
+func TestMapErrorReportsContextErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{domain.Internal("failed to list runs", context.Canceled), codes.Canceled},
+		{domain.Internal("failed to list runs", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{domain.NotFound("run not found"), codes.NotFound},
+	}
+	for _, tc := range cases {
+		if got := status.Code(mapError(tc.err)); got != tc.want {
+			t.Fatalf("%v: expected %s, got %s", tc.err, tc.want, got)
+		}
+	}
+}
+
+type recordingLatencyObserver struct {
+	methods []string
+}
+
+func (o *recordingLatencyObserver) ObserveRPC(method string, elapsed time.Duration) {
+	o.methods = append(o.methods, method)
+}
+
+func TestLatencyInterceptorObservesHubMethodsOnly(t *testing.T) {
+	observer := &recordingLatencyObserver{}
+	interceptor := LatencyUnaryInterceptor(observer)
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	for _, method := range []string{rpccontract.MethodListRuns, "/grpc.health.v1.Health/Check"} {
+		if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(observer.methods) != 1 || observer.methods[0] != rpccontract.MethodListRuns {
+		t.Fatalf("expected only the hub method to be observed, got %v", observer.methods)
+	}
+	if _, err := LatencyUnaryInterceptor(nil)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodListRuns}, handler); err != nil {
+		t.Fatalf("expected a nil observer to pass calls through, got %v", err)
+	}
+}
+
+func TestTracingInterceptorContinuesCallerTrace(t *testing.T) {
+	exporter := tracing.NewExporter(tracing.ExporterConfig{Endpoint: "http://127.0.0.1:1/v1/traces"})
+	tracing.SetProvider(tracing.NewProvider(exporter, 1))
+	defer tracing.SetProvider(nil)
+	defer func() { _ = exporter.Shutdown(context.Background()) }()
+
+	interceptor := TracingUnaryInterceptor()
+	var span *tracing.Span
+	handler := func(ctx context.Context, req any) (any, error) {
+		span = tracing.SpanFromContext(ctx)
+		return "ok", nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodListRuns}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if span == nil || !strings.HasPrefix(span.SpanContext().Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Fatalf("expected a server span in the caller's trace, got %+v", span.SpanContext())
+	}
+
+	span = nil
+	if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if span != nil {
+		t.Fatalf("expected health checks not to be traced")
+	}
+}
+
+type headerRecordingStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerRecordingStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestWarningInterceptorReportsClampedLists(t *testing.T) {
+	memory := store.NewMemoryStore()
+	if err := memory.Load(); err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	hub := service.NewHubService(memory, "memory")
+	hub.SetListGuardrails(service.ListGuardrails{MaxLimit: 2})
+	for range 3 {
+		if _, err := hub.StartRun(context.Background(), service.StartRunRequest{Workflow: "w", AgentID: "a"}); err != nil {
+			t.Fatalf("start run: %v", err)
+		}
+	}
+
+	interceptor := WarningUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodListRuns}
+	list := func(limit int64) ([]string, int) {
+		stream := &headerRecordingStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		response, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return hub.ListRuns(ctx, service.ListRunsRequest{Limit: limit})
+		})
+		if err != nil {
+			t.Fatalf("list runs: %v", err)
+		}
+		return stream.header.Get(warningHeader), len(response.([]domain.AgentRun))
+	}
+
+	warnings, count := list(0)
+	if count != 2 || len(warnings) != 1 || !strings.Contains(warnings[0], "truncated to the server maximum of 2 runs") {
+		t.Fatalf("expected 2 runs and a truncation warning, got %d runs and %q", count, warnings)
+	}
+	if warnings, count := list(2); count != 2 || len(warnings) != 0 {
+		t.Fatalf("expected a limit within the cap to pass without warnings, got %d runs and %q", count, warnings)
+	}
+}
+
+func TestRequestIDReachesInternalErrorsAndRunEvents(t *testing.T) {
+	requestID := RequestIDUnaryInterceptor()
+	errorsInterceptor := ErrorUnaryInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "support-456"))
+	ctx = grpc.NewContextWithServerTransportStream(ctx, &headerRecordingStream{})
+	chain := func(handler grpc.UnaryHandler) error {
+		_, err := requestID(ctx, nil, &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodRecordRunEvent}, func(ctx context.Context, req any) (any, error) {
+			return errorsInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodRecordRunEvent}, handler)
+		})
+		return err
+	}
+
+	err := chain(func(ctx context.Context, req any) (any, error) {
+		return nil, domain.Internal("failed to insert run event", nil)
+	})
+	if status.Code(err) != codes.Internal || !strings.HasSuffix(status.Convert(err).Message(), "(request_id=support-456)") {
+		t.Fatalf("expected the request id in the internal error, got %v", err)
+	}
+	err = chain(func(ctx context.Context, req any) (any, error) {
+		return nil, domain.InvalidArgument("run_id and event_type are required")
+	})
+	if status.Convert(err).Message() != "run_id and event_type are required" {
+		t.Fatalf("expected client errors unchanged, got %v", err)
+	}
+
+	memory := store.NewMemoryStore()
+	if err := memory.Load(); err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	hub := service.NewHubService(memory, "memory")
+	run, err := hub.StartRun(context.Background(), service.StartRunRequest{Workflow: "w", AgentID: "a"})
+	if err != nil {
+		t.Fatalf("start run: %v", err)
+	}
+	var event domain.RunEvent
+	if err := chain(func(ctx context.Context, req any) (any, error) {
+		event, err = hub.RecordRunEvent(ctx, service.RecordRunEventRequest{RunID: run.ID, EventType: "step"})
+		return event, err
+	}); err != nil {
+		t.Fatalf("record run event: %v", err)
+	}
+	if event.RequestID != "support-456" {
+		t.Fatalf("expected the event to carry the request id, got %q", event.RequestID)
+	}
+}
+
+func TestIdempotencyInterceptorSettlesKeyAfterCallerCancels(t *testing.T) {
+	memory := store.NewMemoryStore()
+	if err := memory.Load(); err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	interceptor := IdempotencyUnaryInterceptor(memory)
+	request := mustStruct(t, map[string]any{"title": "t", "idempotency_key": "idem-cancel"})
+	info := &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodCreateTask}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := interceptor(ctx, request, info, func(ctx context.Context, req any) (any, error) {
+		cancel()
+		return mustStruct(t, map[string]any{"id": "task_1"}), nil
+	}); err != nil {
+		t.Fatalf("expected the first call to succeed, got %v", err)
+	}
+
+	response, err := interceptor(context.Background(), request, info, func(ctx context.Context, req any) (any, error) {
+		t.Fatalf("expected the completed key to replay instead of running the handler")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected a replay, got %v", err)
+	}
+	if id := response.(*structpb.Struct).GetFields()["id"].GetStringValue(); id != "task_1" {
+		t.Fatalf("expected the first response to replay, got %v", response)
+	}
+}
+
+func TestLocalInvokerRunsTheInterceptorChain(t *testing.T) {
+	memory := store.NewMemoryStore()
+	if err := memory.Load(); err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	invoker := NewLocalInvoker(NewHubHandler(service.NewHubService(memory, "memory")),
+		RequestIDUnaryInterceptor(),
+		AuthUnaryInterceptor("legacy-secret", true, nil),
+		ErrorUnaryInterceptor(),
+	)
+	request := mustStruct(t, map[string]any{"agent_id": "a", "workflow": "w"})
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}
+
+	_, _, err := invoker.Invoke(context.Background(), rpccontract.MethodStartRun, request, metadata.MD{}, remote)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without a token, got %v", err)
+	}
+
+	md := metadata.Pairs("x-modeloman-token", "legacy-secret", "x-request-id", "req-local")
+	response, header, err := invoker.Invoke(context.Background(), rpccontract.MethodStartRun, request, md, remote)
+	if err != nil {
+		t.Fatalf("expected StartRun to succeed, got %v", err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-local" {
+		t.Fatalf("expected the request id header, got %v", header)
+	}
+	run, ok := response.(*structpb.Struct)
+	if !ok || run.GetFields()["workflow"].GetStringValue() != "w" {
+		t.Fatalf("unexpected response %v", response)
+	}
+
+	if _, _, err := invoker.Invoke(context.Background(), "/modeloman.v1.ModeloManHub/Nope", request, md, remote); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented for an unknown method, got %v", err)
+	}
+}
+
+type recordingPanicRecorder struct {
+	reports []service.PanicReport
+}
+
+func (r *recordingPanicRecorder) RecordPanic(ctx context.Context, report service.PanicReport) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func panicAt(index int) int {
+	return []int{1}[index]
+}
+
+func TestRecoveryInterceptorFingerprintsAndRecordsPanics(t *testing.T) {
+	memory := store.NewMemoryStore()
+	if err := memory.Load(); err != nil {
+		t.Fatalf("load store: %v", err)
+	}
+	hub := service.NewHubService(memory, "memory")
+	recorder := &recordingPanicRecorder{}
+	interceptor := RecoveryUnaryInterceptor(hub, recorder)
+	info := &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodCreateTask}
+	call := func(handler grpc.UnaryHandler) {
+		t.Helper()
+		if _, err := interceptor(context.Background(), nil, info, handler); status.Code(err) != codes.Internal {
+			t.Fatalf("expected Internal, got %v", err)
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		call(func(ctx context.Context, req any) (any, error) { return panicAt(i), nil })
+	}
+	call(func(ctx context.Context, req any) (any, error) { panic("boom") })
+
+	if len(recorder.reports) != 4 {
+		t.Fatalf("expected 4 reports, got %d", len(recorder.reports))
+	}
+	first := recorder.reports[0].Fingerprint
+	if first == "" || recorder.reports[1].Fingerprint != first || recorder.reports[2].Fingerprint != first {
+		t.Fatalf("expected one fingerprint for the same panic site, got %+v", recorder.reports)
+	}
+	if recorder.reports[3].Fingerprint == first {
+		t.Fatalf("expected a different fingerprint for a different panic site")
+	}
+
+	entries, err := hub.ListChangelog(context.Background())
+	if err != nil {
+		t.Fatalf("list changelog: %v", err)
+	}
+	// The repeated panic is recorded on its 1st and 2nd occurrences, not its 3rd.
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 changelog entries, got %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Category != "platform" || !strings.HasPrefix(entry.Summary, "panic in CreateTask: ") || !strings.Contains(entry.Details, "fingerprint=") {
+			t.Fatalf("unexpected changelog entry %+v", entry)
+		}
+	}
+}
+
+func TestAPIVersionInterceptorNegotiatesAndFlagsDeprecation(t *testing.T) {
+	interceptor := APIVersionUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: rpccontract.MethodListNotes}
+	rpccontract.DeprecatedMethods[rpccontract.MethodListNotes] = "use ListChangelog; removed in API version 2"
+	t.Cleanup(func() { delete(rpccontract.DeprecatedMethods, rpccontract.MethodListNotes) })
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	stream := &headerRecordingStream{}
+	ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(rpccontract.APIVersionHeader, rpccontract.APIVersion)), stream)
+	if _, err := interceptor(ctx, nil, info, ok); err != nil {
+		t.Fatalf("expected the supported version to pass, got %v", err)
+	}
+	if got := stream.header.Get(rpccontract.APIVersionHeader); len(got) != 1 || got[0] != rpccontract.APIVersion {
+		t.Fatalf("expected the server version header, got %v", stream.header)
+	}
+	if got := stream.header.Get(rpccontract.DeprecationHeader); len(got) != 1 || !strings.Contains(got[0], "ListChangelog") {
+		t.Fatalf("expected the deprecation note, got %v", stream.header)
+	}
+
+	stream = &headerRecordingStream{}
+	ctx = grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(rpccontract.APIVersionHeader, "99")), stream)
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		t.Fatalf("expected an unsupported version to be rejected before the handler")
+		return nil, nil
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	if got := stream.header.Get(rpccontract.APIVersionHeader); len(got) != 1 {
+		t.Fatalf("expected the rejection to name the server version, got %v", stream.header)
+	}
+}
+
+func TestAPIInfoDescribesEveryMethod(t *testing.T) {
+	info := apiInfo()
+	if info.APIVersion != rpccontract.APIVersion || len(info.Methods) != len(hubServiceDesc.Methods) {
+		t.Fatalf("unexpected api info %+v", info)
+	}
+	methods := map[string]APIMethodInfo{}
+	for _, method := range info.Methods {
+		methods[method.Name] = method
+	}
+	if got := methods["GetAPIInfo"]; got.Access != "public" || got.Scope != "" {
+		t.Fatalf("expected GetAPIInfo to be public, got %+v", got)
+	}
+	if got := methods["StartRun"]; got.Access != "write" || got.Scope != rpccontract.ScopeTelemetryWrite || !got.ProjectScoped {
+		t.Fatalf("unexpected StartRun entry %+v", got)
+	}
+	if got := methods["ListChangelog"]; got.Access != "read" || got.ProjectScoped {
+		t.Fatalf("unexpected ListChangelog entry %+v", got)
+	}
+}
@@ -0,0 +1,395 @@
+package grpcx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// jwksMissRefreshInterval bounds refetches triggered by an unknown kid, so
+	// tokens with made-up key IDs cannot hammer the identity provider.
+	jwksMissRefreshInterval = 30 * time.Second
+	jwtClockLeeway          = time.Minute
+	// maxJWKSBytes caps the key set read from the identity provider.
+	maxJWKSBytes = 1 << 20
+)
+
+// TokenVerifier authenticates bearer tokens that are not agent API keys.
+// ok is false when the token is not one the verifier accepts; err is reserved
+// for failures of the verifier itself, such as an unreachable key endpoint.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, rawToken string) (store.AgentPrincipal, bool, error)
+}
+
+type JWTVerifierConfig struct {
+	Issuer   string
+	Audience string
+	JWKSURL  string
+	// AgentIDClaim names the claim used as the principal's agent ID (default
+	// "sub").
+	AgentIDClaim string
+	// ScopesClaim names the claim holding scopes, either a space-separated
+	// string or a list (default "scope").
+	ScopesClaim string
+	// ScopePrefix, when set, keeps only scopes with this prefix and strips it,
+	// e.g. "modeloman:" maps "modeloman:tasks:write" to "tasks:write".
+	ScopePrefix     string
+	RefreshInterval time.Duration
+	HTTPClient      *http.Client
+}
+
+// JWTVerifier checks RS256/384/512 and ES256/384/512 bearer JWTs against an
+// issuer's JWKS and maps their claims to an AgentPrincipal.
+type JWTVerifier struct {
+	cfg JWTVerifierConfig
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refresh is the JWKS fetch in flight, if any; callers that need fresh
+	// keys wait on it instead of starting their own.
+	refresh *jwksRefresh
+}
+
+type jwksRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+func NewJWTVerifier(cfg JWTVerifierConfig) (*JWTVerifier, error) {
+	cfg.Issuer = strings.TrimSpace(cfg.Issuer)
+	cfg.Audience = strings.TrimSpace(cfg.Audience)
+	cfg.JWKSURL = strings.TrimSpace(cfg.JWKSURL)
+	if cfg.Issuer == "" || cfg.Audience == "" || cfg.JWKSURL == "" {
+		return nil, errors.New("jwt auth needs an issuer, audience and JWKS URL")
+	}
+	if strings.TrimSpace(cfg.AgentIDClaim) == "" {
+		cfg.AgentIDClaim = "sub"
+	}
+	if strings.TrimSpace(cfg.ScopesClaim) == "" {
+		cfg.ScopesClaim = "scope"
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &JWTVerifier{cfg: cfg, keys: map[string]crypto.PublicKey{}}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (v *JWTVerifier) VerifyToken(ctx context.Context, rawToken string) (store.AgentPrincipal, bool, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return store.AgentPrincipal{}, false, nil
+	}
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return store.AgentPrincipal{}, false, nil
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		slog.WarnContext(ctx, "jwt rejected: unsupported alg", "alg", header.Alg)
+		return store.AgentPrincipal{}, false, nil
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return store.AgentPrincipal{}, false, err
+	}
+	if key == nil {
+		slog.WarnContext(ctx, "jwt rejected: unknown kid", "kid", header.Kid)
+		return store.AgentPrincipal{}, false, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return store.AgentPrincipal{}, false, nil
+	}
+	if err := verifyJWTSignature(header.Alg, hash, key, parts[0]+"."+parts[1], signature); err != nil {
+		slog.WarnContext(ctx, "jwt rejected", "err", err)
+		return store.AgentPrincipal{}, false, nil
+	}
+
+	var claims map[string]any
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return store.AgentPrincipal{}, false, nil
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		slog.WarnContext(ctx, "jwt rejected", "err", err)
+		return store.AgentPrincipal{}, false, nil
+	}
+	agentID, _ := claims[v.cfg.AgentIDClaim].(string)
+	if strings.TrimSpace(agentID) == "" {
+		slog.WarnContext(ctx, "jwt rejected: missing agent id claim", "claim", v.cfg.AgentIDClaim)
+		return store.AgentPrincipal{}, false, nil
+	}
+	return store.AgentPrincipal{
+		AgentID: strings.TrimSpace(agentID),
+		KeyID:   "jwt:" + header.Kid,
+		Scopes:  v.scopes(claims[v.cfg.ScopesClaim]),
+	}, true, nil
+}
+
+func (v *JWTVerifier) checkClaims(claims map[string]any, now time.Time) error {
+	if issuer, _ := claims["iss"].(string); issuer != v.cfg.Issuer {
+		return fmt.Errorf("issuer %q does not match", issuer)
+	}
+	audiences := []string{}
+	switch typed := claims["aud"].(type) {
+	case string:
+		audiences = append(audiences, typed)
+	case []any:
+		for _, item := range typed {
+			if value, ok := item.(string); ok {
+				audiences = append(audiences, value)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.cfg.Audience) {
+		return errors.New("audience does not match")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.Add(-jwtClockLeeway).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtClockLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+	return nil
+}
+
+func (v *JWTVerifier) scopes(raw any) []string {
+	values := []string{}
+	switch typed := raw.(type) {
+	case string:
+		values = strings.Fields(typed)
+	case []any:
+		for _, item := range typed {
+			if value, ok := item.(string); ok {
+				values = append(values, strings.TrimSpace(value))
+			}
+		}
+	}
+	prefix := strings.TrimSpace(v.cfg.ScopePrefix)
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if prefix != "" {
+			if !strings.HasPrefix(value, prefix) {
+				continue
+			}
+			value = strings.TrimPrefix(value, prefix)
+		}
+		if value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// key returns the JWKS key for kid, refreshing the set when it is stale or
+// the kid is unknown. A nil key with a nil error means the kid is not in the
+// set. The fetch runs outside v.mu, and concurrent callers share one fetch;
+// a caller holding a cached key does not wait for it.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	now := time.Now()
+	key, known := v.keys[kid]
+	stale := v.fetchedAt.IsZero() || now.Sub(v.fetchedAt) > v.cfg.RefreshInterval
+	if known && !stale {
+		v.mu.Unlock()
+		return key, nil
+	}
+	refresh := v.refresh
+	if refresh != nil && known {
+		v.mu.Unlock()
+		return key, nil
+	}
+	if refresh == nil {
+		if now.Sub(v.lastAttempt) < jwksMissRefreshInterval {
+			v.mu.Unlock()
+			return key, nil
+		}
+		v.lastAttempt = now
+		refresh = &jwksRefresh{done: make(chan struct{})}
+		v.refresh = refresh
+		v.mu.Unlock()
+
+		keys, err := v.fetchKeys(ctx)
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+			v.fetchedAt = now
+		}
+		refresh.err = err
+		v.refresh = nil
+		close(refresh.done)
+	} else {
+		v.mu.Unlock()
+		select {
+		case <-refresh.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		v.mu.Lock()
+	}
+	defer v.mu.Unlock()
+
+	if refresh.err != nil {
+		if known {
+			// Keep serving the cached key while the endpoint is down.
+			slog.WarnContext(ctx, "jwks refresh failed, using cached keys", "err", refresh.err)
+			return key, nil
+		}
+		return nil, refresh.err
+	}
+	return v.keys[kid], nil
+}
+
+type jwkSet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+func (v *JWTVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build jwks request: %w", err)
+	}
+	response, err := v.cfg.HTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", response.StatusCode)
+	}
+	var set jwkSet
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, item := range set.Keys {
+		if item.Use != "" && item.Use != "sig" {
+			continue
+		}
+		switch item.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(item.N)
+			e, errE := base64.RawURLEncoding.DecodeString(item.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[item.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curve, ok := jwkCurves[item.Crv]
+			if !ok {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(item.X)
+			y, errY := base64.RawURLEncoding.DecodeString(item.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[item.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtCurves is the curve each ES alg is defined over.
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func verifyJWTSignature(alg string, hash crypto.Hash, key crypto.PublicKey, signingInput string, signature []byte) error {
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	switch typed := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("alg %s does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(typed, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if jwtCurves[alg] != typed.Curve {
+			return fmt.Errorf("alg %s does not match %s key", alg, typed.Curve.Params().Name)
+		}
+		size := (typed.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(typed, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported key type")
+}
+
+func decodeJWTSegment(segment string, out any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
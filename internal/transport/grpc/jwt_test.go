@@ -0,0 +1,188 @@
+package grpcx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	encode := func(value any) string {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	signingInput := encode(map[string]any{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthInterceptorAcceptsJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]any{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	verifier, err := NewJWTVerifier(JWTVerifierConfig{
+		Issuer:      "https://idp.example.com",
+		Audience:    "modeloman",
+		JWKSURL:     jwks.URL,
+		ScopePrefix: "modeloman:",
+	})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	interceptor := AuthUnaryInterceptor("", false, nil, verifier)
+	call := func(token string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{
+			FullMethod: rpccontract.MethodSetPolicy,
+		}, func(ctx context.Context, req any) (any, error) {
+			principal, ok := principalFromContext(ctx)
+			if !ok || principal.AgentID != "ci-bot" || principal.KeyID != "jwt:k1" {
+				t.Fatalf("unexpected principal: ok=%v %+v", ok, principal)
+			}
+			return "ok", nil
+		})
+		return err
+	}
+
+	claims := func(overrides map[string]any) map[string]any {
+		out := map[string]any{
+			"iss":   "https://idp.example.com",
+			"aud":   []string{"other", "modeloman"},
+			"sub":   "ci-bot",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "openid modeloman:policy:write",
+		}
+		for k, v := range overrides {
+			out[k] = v
+		}
+		return out
+	}
+
+	if err := call(signTestJWT(t, key, "k1", claims(nil))); err != nil {
+		t.Fatalf("expected valid jwt to pass, got %v", err)
+	}
+	if err := call(signTestJWT(t, key, "k1", claims(map[string]any{"aud": "someone-else"}))); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for wrong audience, got %v", err)
+	}
+	if err := call(signTestJWT(t, key, "k1", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for expired token, got %v", err)
+	}
+	if err := call(signTestJWT(t, key, "k1", claims(map[string]any{"scope": "modeloman:tasks:write"}))); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied without policy scope, got %v", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if err := call(signTestJWT(t, other, "k1", claims(nil))); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for forged signature, got %v", err)
+	}
+}
+
+func TestJWTVerifierChecksCurveAndSharesFetch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]any{{
+			"kty": "EC",
+			"kid": "ec1",
+			"crv": "P-384",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 48))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 48))),
+		}}})
+	}))
+	defer jwks.Close()
+
+	verifier, err := NewJWTVerifier(JWTVerifierConfig{
+		Issuer:   "https://idp.example.com",
+		Audience: "modeloman",
+		JWKSURL:  jwks.URL,
+	})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	sign := func(alg string, hash crypto.Hash) string {
+		encode := func(value any) string {
+			raw, _ := json.Marshal(value)
+			return base64.RawURLEncoding.EncodeToString(raw)
+		}
+		signingInput := encode(map[string]any{"alg": alg, "kid": "ec1"}) + "." + encode(map[string]any{
+			"iss": "https://idp.example.com",
+			"aud": "modeloman",
+			"sub": "ci-bot",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		hasher := hash.New()
+		hasher.Write([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, key, hasher.Sum(nil))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		signature := append(r.FillBytes(make([]byte, 48)), s.FillBytes(make([]byte, 48))...)
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := verifier.VerifyToken(context.Background(), sign("ES384", crypto.SHA384)); !ok || err != nil {
+				t.Errorf("expected ES384 token on a P-384 key to pass, got ok=%v err=%v", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("expected concurrent verifies to share one jwks fetch, got %d", got)
+	}
+	if _, ok, _ := verifier.VerifyToken(context.Background(), sign("ES256", crypto.SHA256)); ok {
+		t.Fatalf("expected ES256 token on a P-384 key to be rejected")
+	}
+}
@@ -0,0 +1,103 @@
+package grpcx
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// LocalInvoker calls hub methods in process through an interceptor chain,
+// normally the gRPC server's own, so transports that cannot speak gRPC get
+// the same authentication, scopes, rate limits, idempotency and logging.
+type LocalInvoker struct {
+	server      HubRPCServer
+	methods     map[string]grpc.MethodHandler
+	interceptor grpc.UnaryServerInterceptor
+}
+
+func NewLocalInvoker(server HubRPCServer, interceptors ...grpc.UnaryServerInterceptor) *LocalInvoker {
+	methods := make(map[string]grpc.MethodHandler, len(hubServiceDesc.Methods))
+	for _, method := range hubServiceDesc.Methods {
+		methods["/"+hubServiceDesc.ServiceName+"/"+method.MethodName] = method.Handler
+	}
+	return &LocalInvoker{server: server, methods: methods, interceptor: chainUnary(interceptors)}
+}
+
+// Invoke runs fullMethod with request as if it arrived over gRPC with md as
+// its incoming metadata from remote. It returns the response together with
+// the response headers the chain set, such as x-request-id.
+func (l *LocalInvoker) Invoke(ctx context.Context, fullMethod string, request *structpb.Struct, md metadata.MD, remote net.Addr) (any, metadata.MD, error) {
+	handler, ok := l.methods[fullMethod]
+	if !ok {
+		return nil, nil, status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+	stream := &localStream{method: fullMethod}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: remote})
+	ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+	decode := func(target any) error {
+		// Methods without a body decode into emptypb.Empty.
+		if message, ok := target.(*structpb.Struct); ok && request != nil {
+			proto.Merge(message, request)
+		}
+		return nil
+	}
+	response, err := handler(l.server, ctx, decode, l.interceptor)
+	return response, stream.headers(), err
+}
+
+// chainUnary runs interceptors in order, the first outermost, like
+// grpc.ChainUnaryInterceptor.
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// localStream collects the headers and trailers grpc.SetHeader and
+// grpc.SetTrailer record during a local call.
+type localStream struct {
+	method string
+	mu     sync.Mutex
+	header metadata.MD
+}
+
+func (s *localStream) Method() string {
+	return s.method
+}
+
+func (s *localStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *localStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *localStream) SetTrailer(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *localStream) headers() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.header.Copy()
+}
@@ -0,0 +1,212 @@
+package grpcx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	protoPackagePattern = regexp.MustCompile(`(?m)^package\s+([\w.]+)\s*;`)
+	protoImportPattern  = regexp.MustCompile(`(?m)^import\s+"([^"]+)"\s*;`)
+	protoRPCPattern     = regexp.MustCompile(`(?m)^\s*rpc\s+(\w+)\s*\(\s*\.?([\w.]+)\s*\)\s*returns\s*\(\s*\.?([\w.]+)\s*\)`)
+)
+
+// ReflectionFilter limits what reflection exposes. Entries are service names
+// ("grpc.health.v1.Health") or full method names
+// ("modeloman.v1.ModeloManHub/ExportState", leading slash optional). With
+// Allow empty everything not denied is exposed; Deny wins over Allow.
+type ReflectionFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+func (f ReflectionFilter) contains(list []string, service, method string) bool {
+	for _, entry := range list {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), "/")
+		if entry == service || (method != "" && entry == service+"/"+method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsService reports whether service is listed: it is not denied and, with
+// an allow list, it or one of its methods is allowed.
+func (f ReflectionFilter) allowsService(service string) bool {
+	if f.contains(f.Deny, service, "") {
+		return false
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, entry := range f.Allow {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), "/")
+		if entry == service || strings.HasPrefix(entry, service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (f ReflectionFilter) allowsMethod(service, method string) bool {
+	if !f.allowsService(service) || f.contains(f.Deny, service, method) {
+		return false
+	}
+	return len(f.Allow) == 0 || f.contains(f.Allow, service, method)
+}
+
+// RegisterReflection registers the v1 and v1alpha reflection services with
+// the filter applied. The hub, which has no generated descriptors, is
+// described from hubProto (the hub.proto source) with denied methods left
+// out; call it after RegisterHubServer.
+func RegisterReflection(server *grpc.Server, hubProto, hubProtoPath string, filter ReflectionFilter) error {
+	hubService, ok := server.GetServiceInfo()[rpccontract.ServiceName]
+	if !ok {
+		return fmt.Errorf("reflection: %s is not registered", rpccontract.ServiceName)
+	}
+	hubFile, err := hubFileDescriptor(hubProto, hubProtoPath, hubService, filter)
+	if err != nil {
+		return err
+	}
+	options := reflection.ServerOptions{
+		Services:           filteredServices{server: server, filter: filter},
+		DescriptorResolver: filteredResolver{hub: hubFile, filter: filter},
+	}
+	v1alphareflectiongrpc.RegisterServerReflectionServer(server, reflection.NewServer(options))
+	v1reflectiongrpc.RegisterServerReflectionServer(server, reflection.NewServerV1(options))
+	return nil
+}
+
+// hubFileDescriptor builds hub.proto's descriptor from its rpc lines, keeping
+// the allowed methods. Every registered method must have an rpc line.
+func hubFileDescriptor(source, path string, registered grpc.ServiceInfo, filter ReflectionFilter) (protoreflect.FileDescriptor, error) {
+	pkg := protoPackagePattern.FindStringSubmatch(source)
+	if pkg == nil {
+		return nil, fmt.Errorf("reflection: %s has no package", path)
+	}
+	serviceName := strings.TrimPrefix(rpccontract.ServiceName, pkg[1]+".")
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(path),
+		Package: proto.String(pkg[1]),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, match := range protoImportPattern.FindAllStringSubmatch(source, -1) {
+		file.Dependency = append(file.Dependency, match[1])
+	}
+
+	types := map[string][2]string{}
+	for _, match := range protoRPCPattern.FindAllStringSubmatch(source, -1) {
+		types[match[1]] = [2]string{match[2], match[3]}
+	}
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String(serviceName)}
+	for _, method := range registered.Methods {
+		io, ok := types[method.Name]
+		if !ok {
+			return nil, fmt.Errorf("reflection: %s has no rpc for %s", path, method.Name)
+		}
+		if !filter.allowsMethod(rpccontract.ServiceName, method.Name) {
+			continue
+		}
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(method.Name),
+			InputType:  proto.String("." + io[0]),
+			OutputType: proto.String("." + io[1]),
+		})
+	}
+	if filter.allowsService(rpccontract.ServiceName) {
+		file.Service = append(file.Service, service)
+	}
+
+	descriptor, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("reflection: describe %s: %w", path, err)
+	}
+	return descriptor, nil
+}
+
+// filteredServices lists only the services the filter allows.
+type filteredServices struct {
+	server *grpc.Server
+	filter ReflectionFilter
+}
+
+func (s filteredServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	out := map[string]grpc.ServiceInfo{}
+	for name, info := range s.server.GetServiceInfo() {
+		if s.filter.allowsService(name) {
+			out[name] = info
+		}
+	}
+	return out
+}
+
+// filteredResolver serves the hub descriptor and the global registry, hiding
+// denied services and methods and files that declare a denied service.
+type filteredResolver struct {
+	hub    protoreflect.FileDescriptor
+	filter ReflectionFilter
+}
+
+func (r filteredResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if path == r.hub.Path() {
+		return r.hub, nil
+	}
+	file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !r.allowsFile(file) {
+		return nil, protoregistry.NotFound
+	}
+	return file, nil
+}
+
+func (r filteredResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if descriptor := r.findInHub(name); descriptor != nil {
+		return descriptor, nil
+	}
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.allowsFile(descriptor.ParentFile()) {
+		return nil, protoregistry.NotFound
+	}
+	return descriptor, nil
+}
+
+func (r filteredResolver) findInHub(name protoreflect.FullName) protoreflect.Descriptor {
+	services := r.hub.Services()
+	for i := 0; i < services.Len(); i++ {
+		service := services.Get(i)
+		if service.FullName() == name {
+			return service
+		}
+		if method := service.Methods().ByName(name.Name()); method != nil && method.FullName() == name {
+			return method
+		}
+	}
+	return nil
+}
+
+func (r filteredResolver) allowsFile(file protoreflect.FileDescriptor) bool {
+	services := file.Services()
+	for i := 0; i < services.Len(); i++ {
+		if !r.filter.allowsService(string(services.Get(i).FullName())) {
+			return false
+		}
+	}
+	return true
+}
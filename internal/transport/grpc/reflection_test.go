@@ -0,0 +1,68 @@
+package grpcx
+
+import (
+	"testing"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	modelomanv1 "github.com/bcrosbie/modeloman/proto/modeloman/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestHubFileDescriptorDescribesEveryRegisteredMethod(t *testing.T) {
+	server := grpc.NewServer()
+	RegisterHubServer(server, NewHubHandler(nil))
+	registered := server.GetServiceInfo()[rpccontract.ServiceName]
+
+	file, err := hubFileDescriptor(modelomanv1.HubProto, modelomanv1.HubProtoPath, registered, ReflectionFilter{})
+	if err != nil {
+		t.Fatalf("hubFileDescriptor: %v", err)
+	}
+	methods := file.Services().ByName("ModeloManHub").Methods()
+	if methods.Len() != len(registered.Methods) {
+		t.Fatalf("expected %d methods, got %d", len(registered.Methods), methods.Len())
+	}
+	if got := methods.ByName("ExportState").Input().FullName(); got != "google.protobuf.Empty" {
+		t.Fatalf("expected ExportState to take google.protobuf.Empty, got %s", got)
+	}
+}
+
+func TestReflectionFilterHidesDeniedServicesAndMethods(t *testing.T) {
+	server := grpc.NewServer()
+	RegisterHubServer(server, NewHubHandler(nil))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	filter := ReflectionFilter{Deny: []string{"grpc.health.v1.Health", "/" + rpccontract.ServiceName + "/ExportState"}}
+
+	file, err := hubFileDescriptor(modelomanv1.HubProto, modelomanv1.HubProtoPath, server.GetServiceInfo()[rpccontract.ServiceName], filter)
+	if err != nil {
+		t.Fatalf("hubFileDescriptor: %v", err)
+	}
+	services := filteredServices{server: server, filter: filter}.GetServiceInfo()
+	if _, ok := services["grpc.health.v1.Health"]; ok {
+		t.Fatalf("expected health service to be hidden")
+	}
+	if _, ok := services[rpccontract.ServiceName]; !ok {
+		t.Fatalf("expected hub service to be listed")
+	}
+
+	resolver := filteredResolver{hub: file, filter: filter}
+	if _, err := resolver.FindDescriptorByName(protoreflect.FullName(rpccontract.ServiceName + ".ExportState")); err == nil {
+		t.Fatalf("expected ExportState to be hidden")
+	}
+	if _, err := resolver.FindDescriptorByName(protoreflect.FullName(rpccontract.ServiceName + ".GetHealth")); err != nil {
+		t.Fatalf("expected GetHealth to be described: %v", err)
+	}
+	if _, err := resolver.FindFileByPath("grpc/health/v1/health.proto"); err == nil {
+		t.Fatalf("expected health.proto to be hidden")
+	}
+
+	allowOnly := ReflectionFilter{Allow: []string{rpccontract.ServiceName + "/GetHealth"}}
+	if !allowOnly.allowsMethod(rpccontract.ServiceName, "GetHealth") || allowOnly.allowsMethod(rpccontract.ServiceName, "ExportState") {
+		t.Fatalf("expected the allow list to expose only GetHealth")
+	}
+	if allowOnly.allowsService("grpc.health.v1.Health") {
+		t.Fatalf("expected services outside the allow list to be hidden")
+	}
+}
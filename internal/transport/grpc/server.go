@@ -14,12 +14,13 @@ import (
 
 type HubRPCServer interface {
 	GetHealth(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	GetAPIInfo(context.Context, *emptypb.Empty) (*structpb.Struct, error)
 	GetSummary(context.Context, *emptypb.Empty) (*structpb.Struct, error)
 	ExportState(context.Context, *emptypb.Empty) (*structpb.Struct, error)
 	CreateTask(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	UpdateTask(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	DeleteTask(context.Context, *structpb.Struct) (*structpb.Struct, error)
-	ListTasks(context.Context, *emptypb.Empty) (*structpb.ListValue, error)
+	ListTasks(context.Context, *structpb.Struct) (*structpb.ListValue, error)
 	CreateNote(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	ListNotes(context.Context, *emptypb.Empty) (*structpb.ListValue, error)
 	AppendChangelog(context.Context, *structpb.Struct) (*structpb.Struct, error)
@@ -28,18 +29,35 @@ type HubRPCServer interface {
 	ListBenchmarks(context.Context, *emptypb.Empty) (*structpb.ListValue, error)
 	StartRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	FinishRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	CancelRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	StarRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ShareRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	ListRuns(context.Context, *structpb.Struct) (*structpb.ListValue, error)
+	GetRun(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	RecordPromptAttempt(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	ListPromptAttempts(context.Context, *structpb.Struct) (*structpb.ListValue, error)
 	RecordRunEvent(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RecordRunEvents(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ImportTelemetry(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	ListRunEvents(context.Context, *structpb.Struct) (*structpb.ListValue, error)
 	GetTelemetrySummary(context.Context, *emptypb.Empty) (*structpb.Struct, error)
 	GetPolicy(context.Context, *emptypb.Empty) (*structpb.Struct, error)
 	SetPolicy(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	GetLeaderboard(context.Context, *structpb.Struct) (*structpb.ListValue, error)
-	ListPolicyCaps(context.Context, *emptypb.Empty) (*structpb.ListValue, error)
+	ListPolicyCaps(context.Context, *structpb.Struct) (*structpb.ListValue, error)
 	UpsertPolicyCap(context.Context, *structpb.Struct) (*structpb.Struct, error)
 	DeletePolicyCap(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RecordArtifact(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetArtifact(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListArtifacts(context.Context, *structpb.Struct) (*structpb.ListValue, error)
+	SetActivePromptVersion(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RollbackPromptVersion(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListPromptReleases(context.Context, *structpb.Struct) (*structpb.ListValue, error)
+	ListPolicyAudit(context.Context, *structpb.Struct) (*structpb.ListValue, error)
+	GetEffectiveLimits(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	RecommendModel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetRetryReport(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	Prune(context.Context, *structpb.Struct) (*structpb.Struct, error)
 }
 
 type HubHandler struct {
@@ -51,321 +69,571 @@ func NewHubHandler(hub *service.HubService) *HubHandler {
 }
 
 func RegisterHubServer(server *grpc.Server, handler HubRPCServer) {
-	server.RegisterService(&grpc.ServiceDesc{
-		ServiceName: rpccontract.ServiceName,
-		HandlerType: (*HubRPCServer)(nil),
-		Methods: []grpc.MethodDesc{
-			{MethodName: "GetHealth", Handler: getHealthHandler},
-			{MethodName: "GetSummary", Handler: getSummaryHandler},
-			{MethodName: "ExportState", Handler: exportStateHandler},
-			{MethodName: "CreateTask", Handler: createTaskHandler},
-			{MethodName: "UpdateTask", Handler: updateTaskHandler},
-			{MethodName: "DeleteTask", Handler: deleteTaskHandler},
-			{MethodName: "ListTasks", Handler: listTasksHandler},
-			{MethodName: "CreateNote", Handler: createNoteHandler},
-			{MethodName: "ListNotes", Handler: listNotesHandler},
-			{MethodName: "AppendChangelog", Handler: appendChangelogHandler},
-			{MethodName: "ListChangelog", Handler: listChangelogHandler},
-			{MethodName: "RecordBenchmark", Handler: recordBenchmarkHandler},
-			{MethodName: "ListBenchmarks", Handler: listBenchmarksHandler},
-			{MethodName: "StartRun", Handler: startRunHandler},
-			{MethodName: "FinishRun", Handler: finishRunHandler},
-			{MethodName: "ListRuns", Handler: listRunsHandler},
-			{MethodName: "RecordPromptAttempt", Handler: recordPromptAttemptHandler},
-			{MethodName: "ListPromptAttempts", Handler: listPromptAttemptsHandler},
-			{MethodName: "RecordRunEvent", Handler: recordRunEventHandler},
-			{MethodName: "ListRunEvents", Handler: listRunEventsHandler},
-			{MethodName: "GetTelemetrySummary", Handler: getTelemetrySummaryHandler},
-			{MethodName: "GetPolicy", Handler: getPolicyHandler},
-			{MethodName: "SetPolicy", Handler: setPolicyHandler},
-			{MethodName: "GetLeaderboard", Handler: getLeaderboardHandler},
-			{MethodName: "ListPolicyCaps", Handler: listPolicyCapsHandler},
-			{MethodName: "UpsertPolicyCap", Handler: upsertPolicyCapHandler},
-			{MethodName: "DeletePolicyCap", Handler: deletePolicyCapHandler},
-		},
-		Streams:  []grpc.StreamDesc{},
-		Metadata: "proto/modeloman/v1/hub.proto",
-	}, handler)
+	server.RegisterService(&hubServiceDesc, handler)
+}
+
+var hubServiceDesc = grpc.ServiceDesc{
+	ServiceName: rpccontract.ServiceName,
+	HandlerType: (*HubRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetHealth", Handler: getHealthHandler},
+		{MethodName: "GetAPIInfo", Handler: getAPIInfoHandler},
+		{MethodName: "GetSummary", Handler: getSummaryHandler},
+		{MethodName: "ExportState", Handler: exportStateHandler},
+		{MethodName: "CreateTask", Handler: createTaskHandler},
+		{MethodName: "UpdateTask", Handler: updateTaskHandler},
+		{MethodName: "DeleteTask", Handler: deleteTaskHandler},
+		{MethodName: "ListTasks", Handler: listTasksHandler},
+		{MethodName: "CreateNote", Handler: createNoteHandler},
+		{MethodName: "ListNotes", Handler: listNotesHandler},
+		{MethodName: "AppendChangelog", Handler: appendChangelogHandler},
+		{MethodName: "ListChangelog", Handler: listChangelogHandler},
+		{MethodName: "RecordBenchmark", Handler: recordBenchmarkHandler},
+		{MethodName: "ListBenchmarks", Handler: listBenchmarksHandler},
+		{MethodName: "StartRun", Handler: startRunHandler},
+		{MethodName: "FinishRun", Handler: finishRunHandler},
+		{MethodName: "CancelRun", Handler: cancelRunHandler},
+		{MethodName: "StarRun", Handler: starRunHandler},
+		{MethodName: "ShareRun", Handler: shareRunHandler},
+		{MethodName: "ListRuns", Handler: listRunsHandler},
+		{MethodName: "GetRun", Handler: getRunHandler},
+		{MethodName: "RecordPromptAttempt", Handler: recordPromptAttemptHandler},
+		{MethodName: "ListPromptAttempts", Handler: listPromptAttemptsHandler},
+		{MethodName: "RecordRunEvent", Handler: recordRunEventHandler},
+		{MethodName: "RecordRunEvents", Handler: recordRunEventsHandler},
+		{MethodName: "ImportTelemetry", Handler: importTelemetryHandler},
+		{MethodName: "ListRunEvents", Handler: listRunEventsHandler},
+		{MethodName: "GetTelemetrySummary", Handler: getTelemetrySummaryHandler},
+		{MethodName: "GetPolicy", Handler: getPolicyHandler},
+		{MethodName: "SetPolicy", Handler: setPolicyHandler},
+		{MethodName: "GetLeaderboard", Handler: getLeaderboardHandler},
+		{MethodName: "ListPolicyCaps", Handler: listPolicyCapsHandler},
+		{MethodName: "UpsertPolicyCap", Handler: upsertPolicyCapHandler},
+		{MethodName: "DeletePolicyCap", Handler: deletePolicyCapHandler},
+		{MethodName: "RecordArtifact", Handler: recordArtifactHandler},
+		{MethodName: "GetArtifact", Handler: getArtifactHandler},
+		{MethodName: "ListArtifacts", Handler: listArtifactsHandler},
+		{MethodName: "SetActivePromptVersion", Handler: setActivePromptVersionHandler},
+		{MethodName: "RollbackPromptVersion", Handler: rollbackPromptVersionHandler},
+		{MethodName: "ListPromptReleases", Handler: listPromptReleasesHandler},
+		{MethodName: "ListPolicyAudit", Handler: listPolicyAuditHandler},
+		{MethodName: "GetEffectiveLimits", Handler: getEffectiveLimitsHandler},
+		{MethodName: "RecommendModel", Handler: recommendModelHandler},
+		{MethodName: "GetRetryReport", Handler: getRetryReportHandler},
+		{MethodName: "Prune", Handler: pruneHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/modeloman/v1/hub.proto",
 }
 
 func (h *HubHandler) GetHealth(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
 	return toStruct(h.hub.Health())
 }
 
-func (h *HubHandler) GetSummary(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
-	summary, err := h.hub.Summary()
+func (h *HubHandler) GetAPIInfo(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	return toStruct(apiInfo())
+}
+
+func (h *HubHandler) GetSummary(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	summary, err := h.hub.Summary(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(summary)
 }
 
-func (h *HubHandler) ExportState(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
-	state, err := h.hub.ExportState()
+func (h *HubHandler) ExportState(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	principal, _ := principalFromContext(ctx)
+	state, err := h.hub.ExportState(ctx, hasScope(principal.Scopes, rpccontract.ScopeExportFull))
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(state)
 }
 
-func (h *HubHandler) CreateTask(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) CreateTask(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.CreateTaskRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	created, err := h.hub.CreateTask(decoded)
+	created, err := h.hub.CreateTask(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(created)
 }
 
-func (h *HubHandler) UpdateTask(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) UpdateTask(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.UpdateTaskRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	updated, err := h.hub.UpdateTask(decoded)
+	updated, err := h.hub.UpdateTask(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(updated)
 }
 
-func (h *HubHandler) DeleteTask(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) DeleteTask(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.DeleteTaskRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	if err := h.hub.DeleteTask(decoded); err != nil {
+	if err := h.hub.DeleteTask(ctx, decoded); err != nil {
 		return nil, err
 	}
 	return toStruct(map[string]any{"ok": true})
 }
 
-func (h *HubHandler) ListTasks(_ context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
-	items, err := h.hub.ListTasks()
+func (h *HubHandler) ListTasks(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+	decoded, err := decodeStruct[service.ListTasksRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.hub.ListTasks(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) CreateNote(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) CreateNote(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.CreateNoteRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	created, err := h.hub.CreateNote(decoded)
+	created, err := h.hub.CreateNote(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(created)
 }
 
-func (h *HubHandler) ListNotes(_ context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
-	items, err := h.hub.ListNotes()
+func (h *HubHandler) ListNotes(ctx context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
+	items, err := h.hub.ListNotes(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) AppendChangelog(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) AppendChangelog(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.AppendChangelogRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	created, err := h.hub.AppendChangelog(decoded)
+	created, err := h.hub.AppendChangelog(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(created)
 }
 
-func (h *HubHandler) ListChangelog(_ context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
-	items, err := h.hub.ListChangelog()
+func (h *HubHandler) ListChangelog(ctx context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
+	items, err := h.hub.ListChangelog(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) RecordBenchmark(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) RecordBenchmark(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.RecordBenchmarkRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	recorded, err := h.hub.RecordBenchmark(decoded)
+	recorded, err := h.hub.RecordBenchmark(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(recorded)
 }
 
-func (h *HubHandler) ListBenchmarks(_ context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
-	items, err := h.hub.ListBenchmarks()
+func (h *HubHandler) ListBenchmarks(ctx context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
+	items, err := h.hub.ListBenchmarks(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) StartRun(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) StartRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.StartRunRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	created, err := h.hub.StartRun(decoded)
+	created, err := h.hub.StartRun(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(created)
 }
 
-func (h *HubHandler) FinishRun(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) FinishRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.FinishRunRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	updated, err := h.hub.FinishRun(decoded)
+	updated, err := h.hub.FinishRun(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(updated)
 }
 
-func (h *HubHandler) ListRuns(_ context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+func (h *HubHandler) CancelRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.CancelRunRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := h.hub.CancelRun(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(cancelled)
+}
+
+func (h *HubHandler) StarRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.StarRunRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	run, err := h.hub.StarRun(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(run)
+}
+
+func (h *HubHandler) ShareRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.ShareRunRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	decoded.Actor = auditActorFromContext(ctx)
+	share, err := h.hub.ShareRun(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(share)
+}
+
+func (h *HubHandler) ListRuns(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
 	decoded, err := decodeStruct[service.ListRunsRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	items, err := h.hub.ListRuns(decoded)
+	items, err := h.hub.ListRuns(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) RecordPromptAttempt(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) GetRun(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.GetRunRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := h.hub.GetRun(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(detail)
+}
+
+func (h *HubHandler) RecordPromptAttempt(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.RecordPromptAttemptRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	recorded, err := h.hub.RecordPromptAttempt(decoded)
+	recorded, err := h.hub.RecordPromptAttempt(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(recorded)
 }
 
-func (h *HubHandler) ListPromptAttempts(_ context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+func (h *HubHandler) ListPromptAttempts(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
 	decoded, err := decodeStruct[service.ListPromptAttemptsRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	items, err := h.hub.ListPromptAttempts(decoded)
+	items, err := h.hub.ListPromptAttempts(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) RecordRunEvent(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) RecordRunEvent(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.RecordRunEventRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	recorded, err := h.hub.RecordRunEvent(decoded)
+	recorded, err := h.hub.RecordRunEvent(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(recorded)
 }
 
-func (h *HubHandler) ListRunEvents(_ context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+func (h *HubHandler) RecordRunEvents(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.RecordRunEventsRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.RecordRunEvents(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) ImportTelemetry(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.ImportTelemetryRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.ImportTelemetry(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) ListRunEvents(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
 	decoded, err := decodeStruct[service.ListRunEventsRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	items, err := h.hub.ListRunEvents(decoded)
+	items, err := h.hub.ListRunEvents(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) GetTelemetrySummary(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
-	summary, err := h.hub.TelemetrySummary()
+func (h *HubHandler) GetTelemetrySummary(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	summary, err := h.hub.TelemetrySummary(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(summary)
 }
 
-func (h *HubHandler) GetPolicy(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
-	policy, err := h.hub.GetPolicy()
+func (h *HubHandler) GetPolicy(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	policy, err := h.hub.GetPolicy(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(policy)
 }
 
-func (h *HubHandler) SetPolicy(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) SetPolicy(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.SetPolicyRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	policy, err := h.hub.SetPolicy(decoded)
+	decoded.Actor = auditActorFromContext(ctx)
+	policy, err := h.hub.SetPolicy(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(policy)
 }
 
-func (h *HubHandler) GetLeaderboard(_ context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+func (h *HubHandler) GetLeaderboard(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
 	decoded, err := decodeStruct[service.LeaderboardRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	items, err := h.hub.Leaderboard(decoded)
+	items, err := h.hub.Leaderboard(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) ListPolicyCaps(_ context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
-	items, err := h.hub.ListPolicyCaps()
+func (h *HubHandler) ListPolicyCaps(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+	decoded, err := decodeStruct[service.ListPolicyCapsRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.hub.ListPolicyCaps(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toList(items)
 }
 
-func (h *HubHandler) UpsertPolicyCap(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) UpsertPolicyCap(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.UpsertPolicyCapRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	item, err := h.hub.UpsertPolicyCap(decoded)
+	decoded.Actor = auditActorFromContext(ctx)
+	item, err := h.hub.UpsertPolicyCap(ctx, decoded)
 	if err != nil {
 		return nil, err
 	}
 	return toStruct(item)
 }
 
-func (h *HubHandler) DeletePolicyCap(_ context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+func (h *HubHandler) DeletePolicyCap(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
 	decoded, err := decodeStruct[service.DeletePolicyCapRequest](request)
 	if err != nil {
 		return nil, err
 	}
-	if err := h.hub.DeletePolicyCap(decoded); err != nil {
+	decoded.Actor = auditActorFromContext(ctx)
+	if err := h.hub.DeletePolicyCap(ctx, decoded); err != nil {
 		return nil, err
 	}
 	return toStruct(map[string]any{"ok": true})
 }
 
+func (h *HubHandler) Prune(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.PruneRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.Prune(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) RecordArtifact(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.RecordArtifactRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.RecordArtifact(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) GetArtifact(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.GetArtifactRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.GetArtifact(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) ListArtifacts(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+	decoded, err := decodeStruct[service.ListArtifactsRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.ListArtifacts(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toList(result)
+}
+
+func (h *HubHandler) SetActivePromptVersion(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.SetActivePromptVersionRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.SetActivePromptVersion(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) RollbackPromptVersion(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.RollbackPromptVersionRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.RollbackPromptVersion(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) ListPromptReleases(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+	decoded, err := decodeStruct[service.ListPromptReleasesRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.ListPromptReleases(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toList(result)
+}
+
+func (h *HubHandler) ListPolicyAudit(ctx context.Context, request *structpb.Struct) (*structpb.ListValue, error) {
+	decoded, err := decodeStruct[service.ListPolicyAuditRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.ListPolicyAudit(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toList(result)
+}
+
+// auditActorFromContext reports the authenticated caller for policy audit rows.
+func auditActorFromContext(ctx context.Context) service.AuditActor {
+	principal, ok := principalFromContext(ctx)
+	if !ok {
+		return service.AuditActor{}
+	}
+	return service.AuditActor{AgentID: principal.AgentID, KeyID: principal.KeyID}
+}
+
+func (h *HubHandler) GetEffectiveLimits(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.GetEffectiveLimitsRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.GetEffectiveLimits(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) RecommendModel(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.RecommendModelRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.hub.RecommendModel(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(result)
+}
+
+func (h *HubHandler) GetRetryReport(ctx context.Context, request *structpb.Struct) (*structpb.Struct, error) {
+	decoded, err := decodeStruct[service.RetryReportRequest](request)
+	if err != nil {
+		return nil, err
+	}
+	report, err := h.hub.RetryReport(ctx, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(report)
+}
+
 func toStruct(value any) (*structpb.Struct, error) {
 	serialized, err := json.Marshal(value)
 	if err != nil {
@@ -432,6 +700,26 @@ func getHealthHandler(
 	return interceptor(ctx, request, info, handler)
 }
 
+func getAPIInfoHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(emptypb.Empty)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).GetAPIInfo(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodGetAPIInfo}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).GetAPIInfo(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
 func getSummaryHandler(
 	srv any,
 	ctx context.Context,
@@ -538,7 +826,7 @@ func listTasksHandler(
 	decoder func(any) error,
 	interceptor grpc.UnaryServerInterceptor,
 ) (any, error) {
-	request := new(emptypb.Empty)
+	request := new(structpb.Struct)
 	if err := decoder(request); err != nil {
 		return nil, err
 	}
@@ -547,7 +835,7 @@ func listTasksHandler(
 	}
 	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodListTasks}
 	handler := func(ctx context.Context, req any) (any, error) {
-		return srv.(HubRPCServer).ListTasks(ctx, req.(*emptypb.Empty))
+		return srv.(HubRPCServer).ListTasks(ctx, req.(*structpb.Struct))
 	}
 	return interceptor(ctx, request, info, handler)
 }
@@ -712,6 +1000,66 @@ func finishRunHandler(
 	return interceptor(ctx, request, info, handler)
 }
 
+func cancelRunHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).CancelRun(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodCancelRun}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).CancelRun(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func starRunHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).StarRun(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodStarRun}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).StarRun(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func shareRunHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).ShareRun(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodShareRun}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).ShareRun(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
 func listRunsHandler(
 	srv any,
 	ctx context.Context,
@@ -732,6 +1080,26 @@ func listRunsHandler(
 	return interceptor(ctx, request, info, handler)
 }
 
+func getRunHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).GetRun(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodGetRun}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).GetRun(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
 func recordPromptAttemptHandler(
 	srv any,
 	ctx context.Context,
@@ -792,6 +1160,46 @@ func recordRunEventHandler(
 	return interceptor(ctx, request, info, handler)
 }
 
+func recordRunEventsHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).RecordRunEvents(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodRecordRunEvents}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).RecordRunEvents(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func importTelemetryHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).ImportTelemetry(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodImportTelemetry}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).ImportTelemetry(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
 func listRunEventsHandler(
 	srv any,
 	ctx context.Context,
@@ -898,7 +1306,7 @@ func listPolicyCapsHandler(
 	decoder func(any) error,
 	interceptor grpc.UnaryServerInterceptor,
 ) (any, error) {
-	request := new(emptypb.Empty)
+	request := new(structpb.Struct)
 	if err := decoder(request); err != nil {
 		return nil, err
 	}
@@ -907,7 +1315,7 @@ func listPolicyCapsHandler(
 	}
 	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodListPolicyCaps}
 	handler := func(ctx context.Context, req any) (any, error) {
-		return srv.(HubRPCServer).ListPolicyCaps(ctx, req.(*emptypb.Empty))
+		return srv.(HubRPCServer).ListPolicyCaps(ctx, req.(*structpb.Struct))
 	}
 	return interceptor(ctx, request, info, handler)
 }
@@ -951,3 +1359,223 @@ func deletePolicyCapHandler(
 	}
 	return interceptor(ctx, request, info, handler)
 }
+
+func recordArtifactHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).RecordArtifact(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodRecordArtifact}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).RecordArtifact(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func getArtifactHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).GetArtifact(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodGetArtifact}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).GetArtifact(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func listArtifactsHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).ListArtifacts(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodListArtifacts}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).ListArtifacts(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func setActivePromptVersionHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).SetActivePromptVersion(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodSetActivePromptVersion}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).SetActivePromptVersion(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func rollbackPromptVersionHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).RollbackPromptVersion(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodRollbackPromptVersion}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).RollbackPromptVersion(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func listPromptReleasesHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).ListPromptReleases(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodListPromptReleases}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).ListPromptReleases(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func listPolicyAuditHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).ListPolicyAudit(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodListPolicyAudit}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).ListPolicyAudit(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func getEffectiveLimitsHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).GetEffectiveLimits(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodGetEffectiveLimits}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).GetEffectiveLimits(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func recommendModelHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).RecommendModel(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodRecommendModel}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).RecommendModel(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func getRetryReportHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).GetRetryReport(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodGetRetryReport}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).GetRetryReport(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
+
+func pruneHandler(
+	srv any,
+	ctx context.Context,
+	decoder func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	request := new(structpb.Struct)
+	if err := decoder(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HubRPCServer).Prune(ctx, request)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: rpccontract.MethodPrune}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(HubRPCServer).Prune(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, request, info, handler)
+}
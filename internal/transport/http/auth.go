@@ -0,0 +1,155 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+// authCookie carries a dashboard token for requests a page cannot add
+// headers to, such as the event stream and the WebSocket.
+const authCookie = "modeloman_token"
+
+// KeyAuthenticator resolves agent credentials; grpcx.Authenticator
+// implements it with the gRPC server's JWT, agent key and legacy token rules.
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (store.AgentPrincipal, bool, error)
+}
+
+// Auth guards the dashboard's /api read routes and /ws. With nothing set
+// they stay public. The write API is left to the interceptor chain, which
+// authenticates it already.
+type Auth struct {
+	// Token is a dashboard token, separate from agent keys.
+	Token string
+	// Keys also admits agent credentials holding admin:read. Keys pinned to
+	// projects are refused, since the dashboard reads every project.
+	Keys KeyAuthenticator
+	// BasicUser and BasicPassword enable HTTP basic auth.
+	BasicUser     string
+	BasicPassword string
+}
+
+func (a Auth) enabled() bool {
+	return a.Token != "" || a.Keys != nil || a.BasicUser != ""
+}
+
+// methods lists the schemes a 401 tells the dashboard pages to offer.
+func (a Auth) methods() []string {
+	var methods []string
+	if a.Token != "" || a.Keys != nil {
+		methods = append(methods, "token")
+	}
+	if a.BasicUser != "" {
+		methods = append(methods, "basic")
+	}
+	return methods
+}
+
+// requireAuth wraps mux so that routes under /api/ and /ws need credentials,
+// except those in exempt (keyed by mux pattern). Pages and /healthz stay open.
+func requireAuth(mux *http.ServeMux, auth Auth, exempt map[string]bool) http.Handler {
+	if !auth.enabled() {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" && !strings.HasPrefix(r.URL.Path, "/api/") {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := mux.Handler(r); exempt[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		status, message := auth.check(r)
+		if status == 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if status == http.StatusUnauthorized && auth.BasicUser != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ModeloMan", charset="UTF-8"`)
+		}
+		writeJSON(w, status, map[string]any{"error": message, "auth": auth.methods()})
+	})
+}
+
+// check returns 0 when r carries acceptable credentials, else the status
+// and message to refuse it with.
+func (a Auth) check(r *http.Request) (int, string) {
+	if user, password, ok := r.BasicAuth(); ok && a.BasicUser != "" {
+		// Both compared so a wrong user costs as much as a wrong password.
+		userOK := secretEqual(user, a.BasicUser)
+		if secretEqual(password, a.BasicPassword) && userOK {
+			return 0, ""
+		}
+		return http.StatusUnauthorized, "invalid basic auth credentials"
+	}
+	token := requestToken(r)
+	if token == "" {
+		return http.StatusUnauthorized, "authentication required"
+	}
+	if a.Token != "" && secretEqual(token, a.Token) {
+		return 0, ""
+	}
+	if a.Keys == nil {
+		return http.StatusUnauthorized, "invalid authentication token"
+	}
+	principal, ok, err := a.Keys.Authenticate(r.Context(), token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "dashboard auth validation failure", "path", r.URL.Path, "err", err)
+		return http.StatusInternalServerError, "authentication subsystem unavailable"
+	}
+	if !ok {
+		return http.StatusUnauthorized, "invalid authentication token"
+	}
+	if !grantsAny(principal.Scopes, rpccontract.ScopeAdminRead) {
+		return http.StatusForbidden, "api key scope does not allow dashboard reads; admin:read is required"
+	}
+	if _, restricted := rpccontract.AllowedProjects(principal.Scopes); restricted {
+		return http.StatusForbidden, "project-scoped keys cannot read the dashboard"
+	}
+	return 0, ""
+}
+
+// requestToken reads the token as the gRPC server does (x-modeloman-token,
+// then a bearer Authorization header), falling back to the page cookie.
+func requestToken(r *http.Request) string {
+	if token := strings.TrimSpace(r.Header.Get("x-modeloman-token")); token != "" {
+		return token
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		if token = strings.TrimSpace(token); token != "" {
+			return token
+		}
+	}
+	if cookie, err := r.Cookie(authCookie); err == nil {
+		if token, err := url.QueryUnescape(cookie.Value); err == nil {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+func grantsAny(scopes []string, required string) bool {
+	for _, scope := range scopes {
+		if rpccontract.GrantsScope(scope, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretEqual compares hashes so the time taken reveals neither the secret
+// nor its length.
+func secretEqual(given, expected string) bool {
+	givenHash := sha256.Sum256([]byte(given))
+	expectedHash := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(givenHash[:], expectedHash[:]) == 1
+}
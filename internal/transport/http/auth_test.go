@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/service"
+	"github.com/bcrosbie/modeloman/internal/store"
+)
+
+func newTestHandler(t *testing.T, options Options) http.Handler {
+	t.Helper()
+	hubStore := store.NewMemoryStore()
+	if err := hubStore.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return NewServer("", service.NewHubService(hubStore, "memory"), options).Handler
+}
+
+func serve(handler http.Handler, method, target string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	if setup != nil {
+		setup(request)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// fakeKeys authenticates "good-key" with scopes, and fails on "broken-key".
+type fakeKeys struct {
+	scopes []string
+}
+
+func (k fakeKeys) Authenticate(ctx context.Context, token string) (store.AgentPrincipal, bool, error) {
+	switch token {
+	case "good-key":
+		return store.AgentPrincipal{AgentID: "agent", Scopes: k.scopes}, true, nil
+	case "broken-key":
+		return store.AgentPrincipal{}, false, errors.New("key store down")
+	}
+	return store.AgentPrincipal{}, false, nil
+}
+
+func TestRequireAuthLeavesRoutesOpenWithoutAuth(t *testing.T) {
+	handler := newTestHandler(t, Options{})
+	for _, target := range []string{"/api/runs", "/api/telemetry-summary", "/healthz"} {
+		if got := serve(handler, http.MethodGet, target, nil).Code; got != http.StatusOK {
+			t.Fatalf("expected %s to be open without auth, got %d", target, got)
+		}
+	}
+}
+
+func TestRequireAuthChecksDashboardToken(t *testing.T) {
+	handler := newTestHandler(t, Options{Auth: Auth{Token: "secret"}})
+
+	for _, target := range []string{"/api/runs", "/api/policy", "/ws"} {
+		response := serve(handler, http.MethodGet, target, nil)
+		if response.Code != http.StatusUnauthorized {
+			t.Fatalf("expected %s to need credentials, got %d", target, response.Code)
+		}
+		var body struct {
+			Auth []string `json:"auth"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil || len(body.Auth) != 1 || body.Auth[0] != "token" {
+			t.Fatalf("expected the 401 to offer token auth, got %s", response.Body.String())
+		}
+		if got := serve(handler, http.MethodGet, target, func(r *http.Request) { r.Header.Set("x-modeloman-token", "wrong") }).Code; got != http.StatusUnauthorized {
+			t.Fatalf("expected a wrong token on %s to be refused, got %d", target, got)
+		}
+	}
+
+	for name, setup := range map[string]func(*http.Request){
+		"header": func(r *http.Request) { r.Header.Set("x-modeloman-token", "secret") },
+		"bearer": func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+		"cookie": func(r *http.Request) { r.AddCookie(&http.Cookie{Name: authCookie, Value: "secret"}) },
+	} {
+		if got := serve(handler, http.MethodGet, "/api/runs", setup).Code; got != http.StatusOK {
+			t.Fatalf("expected the token in a %s to be accepted, got %d", name, got)
+		}
+	}
+
+	for _, target := range []string{"/healthz", "/", "/runs"} {
+		if got := serve(handler, http.MethodGet, target, nil).Code; got != http.StatusOK {
+			t.Fatalf("expected %s to stay open, got %d", target, got)
+		}
+	}
+	if got := serve(handler, http.MethodGet, "/api/shared-runs/not-a-token", nil).Code; got == http.StatusUnauthorized || got == http.StatusForbidden {
+		t.Fatalf("expected shared runs to skip dashboard auth, got %d", got)
+	}
+}
+
+func TestRequireAuthChecksBasicAuth(t *testing.T) {
+	handler := newTestHandler(t, Options{Auth: Auth{BasicUser: "admin", BasicPassword: "pw"}})
+	response := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.SetBasicAuth("admin", "nope") })
+	if response.Code != http.StatusUnauthorized || response.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected a wrong password to get 401 with a challenge, got %d %q", response.Code, response.Header().Get("WWW-Authenticate"))
+	}
+	if got := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.SetBasicAuth("admin", "pw") }).Code; got != http.StatusOK {
+		t.Fatalf("expected basic auth to be accepted, got %d", got)
+	}
+}
+
+func TestRequireAuthChecksAgentKeys(t *testing.T) {
+	cases := []struct {
+		name   string
+		scopes []string
+		token  string
+		want   int
+	}{
+		{"admin read", []string{rpccontract.ScopeAdminRead}, "good-key", http.StatusOK},
+		{"no admin read", []string{"tasks:write"}, "good-key", http.StatusForbidden},
+		{"project scoped", []string{rpccontract.ScopeAdminRead, rpccontract.ProjectScopePrefix + "acme"}, "good-key", http.StatusForbidden},
+		{"unknown key", []string{rpccontract.ScopeAdminRead}, "other-key", http.StatusUnauthorized},
+		{"key store down", []string{rpccontract.ScopeAdminRead}, "broken-key", http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		handler := newTestHandler(t, Options{Auth: Auth{Keys: fakeKeys{scopes: tc.scopes}}})
+		got := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tc.token) }).Code
+		if got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minGzipSize is the smallest body worth compressing; below it gzip's
+// header and the CPU cost outweigh the saving.
+const minGzipSize = 1024
+
+// withCaching buffers GET responses so it can give successful ones an ETag,
+// answer matching If-None-Match requests with 304, set a default
+// Cache-Control and gzip bodies for clients that accept it. Dashboards that
+// poll then mostly get empty 304s, and the rest travel compressed. Routes
+// in streaming (keyed by mux pattern) are left alone, since they flush or
+// hijack the connection.
+func withCaching(next http.Handler, mux *http.ServeMux, streaming map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := mux.Handler(r); streaming[pattern] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buffered := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		header := w.Header()
+		for key, values := range buffered.header {
+			if key == "Vary" {
+				// Keeps what withCORS set.
+				values = append(header[key], values...)
+			}
+			header[key] = values
+		}
+		header.Add("Vary", "Accept-Encoding")
+		body := buffered.body.Bytes()
+		if buffered.status == http.StatusOK && header.Get("Content-Encoding") == "" {
+			if header.Get("Cache-Control") == "" {
+				header.Set("Cache-Control", defaultCacheControl(r.URL.Path))
+			}
+			if !strings.Contains(header.Get("Cache-Control"), "no-store") {
+				if header.Get("ETag") == "" {
+					header.Set("ETag", bodyETag(body))
+				}
+				if etagMatches(r.Header.Get("If-None-Match"), header.Get("ETag")) {
+					header.Del("Content-Length")
+					header.Del("Content-Type")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		}
+		if len(body) >= minGzipSize && header.Get("Content-Encoding") == "" && acceptsGzip(r) && compressible(header.Get("Content-Type")) {
+			if compressed, err := gzipBytes(body); err != nil {
+				slog.ErrorContext(r.Context(), "http gzip failed", "path", r.URL.Path, "err", err)
+			} else {
+				header.Set("Content-Encoding", "gzip")
+				body = compressed
+			}
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buffered.status)
+		_, _ = w.Write(body)
+	})
+}
+
+// defaultCacheControl is the policy for responses that set none. API data
+// changes all the time and may need credentials, so browsers keep it
+// private and revalidate on each use; the embedded pages and assets only
+// change with a new server binary, so a short max-age saves the round trip.
+func defaultCacheControl(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/"):
+		return "private, no-cache"
+	case strings.HasPrefix(path, "/static/"):
+		return "public, max-age=300"
+	default:
+		return "no-cache"
+	}
+}
+
+// bodyETag is a weak validator over the uncompressed body, so the gzipped
+// and identity responses of one body share it.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches applies If-None-Match's weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func gzipBytes(body []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "application/javascript") ||
+		strings.HasPrefix(contentType, "image/svg+xml")
+}
+
+// bufferedResponse collects a handler's response for withCaching. It has
+// no Flush or Hijack, which is why streaming routes bypass it.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wrote {
+		return
+	}
+	b.status = status
+	b.wrote = true
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(data)
+}
@@ -0,0 +1,132 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newCachingTestHandler() http.Handler {
+	big := `{"rows":"` + strings.Repeat("x", 2*minGzipSize) + `"}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Vary", "Authorization")
+		_, _ = io.WriteString(w, big)
+	})
+	mux.HandleFunc("GET /api/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("GET /static/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = io.WriteString(w, big)
+	})
+	mux.HandleFunc("GET /api/private", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = io.WriteString(w, "secret")
+	})
+	mux.HandleFunc("GET /api/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, "oops")
+	})
+	mux.HandleFunc("GET /api/stream", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "event")
+	})
+	mux.HandleFunc("POST /api/big", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, big)
+	})
+	return withCaching(mux, mux, map[string]bool{"GET /api/stream": true})
+}
+
+func TestWithCachingAnswersMatchingETagsWith304(t *testing.T) {
+	handler := newCachingTestHandler()
+	first := serve(handler, http.MethodGet, "/api/small", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a 200 with a weak ETag, got %d %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Fatalf("expected API responses to default to private, no-cache, got %q", got)
+	}
+
+	for _, ifNoneMatch := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag, "*"} {
+		response := serve(handler, http.MethodGet, "/api/small", func(r *http.Request) { r.Header.Set("If-None-Match", ifNoneMatch) })
+		if response.Code != http.StatusNotModified || response.Body.Len() != 0 {
+			t.Fatalf("If-None-Match %s: expected an empty 304, got %d with %d bytes", ifNoneMatch, response.Code, response.Body.Len())
+		}
+		if response.Header().Get("Content-Length") != "" || response.Header().Get("ETag") != etag {
+			t.Fatalf("If-None-Match %s: expected the ETag and no Content-Length on a 304, got %v", ifNoneMatch, response.Header())
+		}
+	}
+	if got := serve(handler, http.MethodGet, "/api/small", func(r *http.Request) { r.Header.Set("If-None-Match", `W/"stale"`) }).Code; got != http.StatusOK {
+		t.Fatalf("expected a stale ETag to get the body, got %d", got)
+	}
+}
+
+func TestWithCachingSkipsUncacheableResponses(t *testing.T) {
+	handler := newCachingTestHandler()
+	for _, target := range []string{"/api/private", "/api/broken", "/api/stream"} {
+		response := serve(handler, http.MethodGet, target, func(r *http.Request) { r.Header.Set("If-None-Match", "*") })
+		if response.Code == http.StatusNotModified || response.Header().Get("ETag") != "" {
+			t.Fatalf("expected %s to get no ETag or 304, got %d %q", target, response.Code, response.Header().Get("ETag"))
+		}
+	}
+	if got := serve(handler, http.MethodGet, "/api/broken", nil).Header().Get("Cache-Control"); got != "" {
+		t.Fatalf("expected errors to get no default Cache-Control, got %q", got)
+	}
+	if got := serve(handler, http.MethodGet, "/static/logo.png", nil).Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Fatalf("expected static assets to be cacheable for 5 minutes, got %q", got)
+	}
+	post := serve(handler, http.MethodPost, "/api/big", func(r *http.Request) { r.Header.Set("Accept-Encoding", "gzip") })
+	if post.Header().Get("ETag") != "" || post.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected POSTs to pass through untouched, got %v", post.Header())
+	}
+}
+
+func TestWithCachingNegotiatesGzip(t *testing.T) {
+	handler := newCachingTestHandler()
+	plain := serve(handler, http.MethodGet, "/api/big", nil)
+	compressed := serve(handler, http.MethodGet, "/api/big", func(r *http.Request) { r.Header.Set("Accept-Encoding", "br, gzip;q=0.8") })
+	if plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected no gzip without Accept-Encoding")
+	}
+	if compressed.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip when accepted, got %v", compressed.Header())
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(decoded, plain.Body.Bytes()) {
+		t.Fatalf("expected the gzipped body to decode to the plain one")
+	}
+	if compressed.Header().Get("ETag") != plain.Header().Get("ETag") {
+		t.Fatalf("expected both encodings to share an ETag")
+	}
+	for _, vary := range [][]string{varyValues(plain.Header()), varyValues(compressed.Header())} {
+		if !slices.Contains(vary, "Accept-Encoding") || !slices.Contains(vary, "Authorization") {
+			t.Fatalf("expected Vary to add Accept-Encoding to the handler's own, got %v", vary)
+		}
+	}
+
+	cases := []struct {
+		name, target, acceptEncoding string
+	}{
+		{"refused", "/api/big", "gzip; q=0"},
+		{"other coding", "/api/big", "br"},
+		{"small body", "/api/small", "gzip"},
+		{"binary image", "/static/logo.png", "gzip"},
+	}
+	for _, tc := range cases {
+		response := serve(handler, http.MethodGet, tc.target, func(r *http.Request) { r.Header.Set("Accept-Encoding", tc.acceptEncoding) })
+		if got := response.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("%s: expected no gzip, got %q", tc.name, got)
+		}
+	}
+}
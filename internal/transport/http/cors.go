@@ -0,0 +1,100 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsExposedHeaders are the response headers cross-origin scripts may read.
+const corsExposedHeaders = "x-request-id, x-modeloman-api-version, x-modeloman-warning"
+
+// CORS lets browser pages on other origins call the /api routes and open
+// /ws. The zero value allows none, keeping the dashboard same-origin.
+// Credentials travel in Authorization or x-modeloman-token; cookies are not
+// allowed cross-origin, so the dashboard's sign-in cookie stays its own.
+type CORS struct {
+	// AllowedOrigins are exact origins such as https://grafana.example.com,
+	// or "*" for any.
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers preflights approve. Empty
+	// means Content-Type and the headers the write API forwards.
+	AllowedHeaders []string
+	// MaxAge is how long browsers may cache a preflight; 0 leaves it to them.
+	MaxAge time.Duration
+}
+
+// Validate reports origins that are not "*" or a bare scheme://host[:port].
+func (c CORS) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.TrimSuffix(parsed.Path, "/") != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("cors origin %q must be \"*\" or scheme://host[:port]", origin)
+		}
+	}
+	return nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" when it is not allowed.
+func (c CORS) allowOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func (c CORS) allowedHeaders() string {
+	if len(c.AllowedHeaders) > 0 {
+		return strings.Join(c.AllowedHeaders, ", ")
+	}
+	return strings.Join(append([]string{"content-type"}, forwardedHeaders...), ", ")
+}
+
+// withCORS answers preflights for /api routes from allowed origins and
+// marks their responses readable. Other requests pass through untouched,
+// so browsers keep refusing disallowed origins.
+func withCORS(next http.Handler, cors CORS) http.Handler {
+	if len(cors.AllowedOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		allowed := cors.allowOrigin(origin)
+		if allowed == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", allowed)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			header.Set("Access-Control-Allow-Headers", cors.allowedHeaders())
+			if cors.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(cors.MaxAge/time.Second), 10))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
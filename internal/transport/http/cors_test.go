@@ -0,0 +1,133 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func varyValues(header http.Header) []string {
+	var values []string
+	for _, line := range header.Values("Vary") {
+		for _, value := range strings.Split(line, ",") {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return values
+}
+
+func TestCORSMarksAllowedOrigins(t *testing.T) {
+	handler := newTestHandler(t, Options{CORS: CORS{AllowedOrigins: []string{"https://grafana.example.com/"}}})
+
+	allowed := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.Header.Set("Origin", "https://Grafana.example.com") })
+	if got := allowed.Header().Get("Access-Control-Allow-Origin"); got != "https://Grafana.example.com" {
+		t.Fatalf("expected the allowed origin to be echoed, got %q", got)
+	}
+	if allowed.Header().Get("Access-Control-Expose-Headers") != corsExposedHeaders {
+		t.Fatalf("expected exposed headers on an allowed response, got %q", allowed.Header().Get("Access-Control-Expose-Headers"))
+	}
+	if vary := varyValues(allowed.Header()); !slices.Contains(vary, "Origin") || !slices.Contains(vary, "Accept-Encoding") {
+		t.Fatalf("expected Vary to keep Origin alongside Accept-Encoding, got %v", vary)
+	}
+
+	denied := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.Header.Set("Origin", "https://evil.example.com") })
+	if got := denied.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow header for a denied origin, got %q", got)
+	}
+	if !slices.Contains(varyValues(denied.Header()), "Origin") {
+		t.Fatalf("expected Vary: Origin on a denied response too, got %v", denied.Header().Values("Vary"))
+	}
+
+	page := serve(handler, http.MethodGet, "/", func(r *http.Request) { r.Header.Set("Origin", "https://grafana.example.com") })
+	if page.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected pages to get no CORS headers")
+	}
+}
+
+func TestCORSAnswersPreflightsBeforeAuth(t *testing.T) {
+	handler := newTestHandler(t, Options{
+		Auth: Auth{Token: "secret"},
+		CORS: CORS{AllowedOrigins: []string{"https://grafana.example.com"}, AllowedHeaders: []string{"authorization"}, MaxAge: 10 * time.Minute},
+	})
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		return serve(handler, http.MethodOptions, "/api/runs", func(r *http.Request) {
+			r.Header.Set("Origin", origin)
+			r.Header.Set("Access-Control-Request-Method", "GET")
+			r.Header.Set("Access-Control-Request-Headers", "authorization")
+		})
+	}
+
+	response := preflight("https://grafana.example.com")
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("expected an allowed preflight to get 204 without credentials, got %d", response.Code)
+	}
+	header := response.Header()
+	if header.Get("Access-Control-Allow-Headers") != "authorization" || header.Get("Access-Control-Max-Age") != "600" || header.Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("unexpected preflight headers: %v", header)
+	}
+	if vary := varyValues(header); !slices.Contains(vary, "Origin") || !slices.Contains(vary, "Access-Control-Request-Method") {
+		t.Fatalf("expected the preflight to vary on origin and requested method, got %v", vary)
+	}
+
+	refused := preflight("https://evil.example.com")
+	if refused.Code == http.StatusNoContent || refused.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected a denied preflight not to be approved, got %d %v", refused.Code, refused.Header())
+	}
+	if got := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.Header.Set("Origin", "https://grafana.example.com") }).Code; got != http.StatusUnauthorized {
+		t.Fatalf("expected an allowed origin to still need credentials, got %d", got)
+	}
+}
+
+func TestCORSWildcardAndValidate(t *testing.T) {
+	handler := newTestHandler(t, Options{CORS: CORS{AllowedOrigins: []string{"*"}}})
+	response := serve(handler, http.MethodGet, "/api/runs", func(r *http.Request) { r.Header.Set("Origin", "https://anywhere.example.com") })
+	if got := response.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected * for a wildcard, got %q", got)
+	}
+
+	if err := (CORS{AllowedOrigins: []string{"*", "https://a.example.com", "http://localhost:3000/"}}).Validate(); err != nil {
+		t.Fatalf("expected valid origins to pass: %v", err)
+	}
+	for _, origin := range []string{"a.example.com", "ftp://a.example.com", "https://a.example.com/path", "https://a.example.com?x=1", "https://"} {
+		if err := (CORS{AllowedOrigins: []string{origin}}).Validate(); err == nil {
+			t.Fatalf("expected %q to be rejected", origin)
+		}
+	}
+}
+
+func TestLiveSocketChecksOrigin(t *testing.T) {
+	server := httptest.NewServer(newTestHandler(t, Options{CORS: CORS{AllowedOrigins: []string{"https://grafana.example.com"}}}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	dial := func(origin string) error {
+		conn, err := websocket.Dial(url, "", origin)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	if err := dial("https://evil.example.com"); err == nil {
+		t.Fatalf("expected a cross-origin websocket to be refused")
+	}
+	if err := dial("https://grafana.example.com"); err != nil {
+		t.Fatalf("expected an allowed origin to connect: %v", err)
+	}
+	if err := dial(server.URL); err != nil {
+		t.Fatalf("expected the dashboard's own origin to connect: %v", err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if err := originHandshake(&websocket.Config{}, request, CORS{}); err != nil {
+		t.Fatalf("expected a client without Origin to be accepted: %v", err)
+	}
+	request.Header.Set("Origin", "https://grafana.example.com")
+	if err := originHandshake(&websocket.Config{}, request, CORS{}); err == nil {
+		t.Fatalf("expected another origin to be refused without CORS")
+	}
+}
@@ -0,0 +1,78 @@
+package httpx
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// costsHandler serves GET /api/costs, cost and tokens per time bucket,
+// workflow and provider, filtered by the project, workflow, provider,
+// window_days and bucket query parameters.
+func costsHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		windowDays := int64(0)
+		if raw := strings.TrimSpace(query.Get("window_days")); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "window_days must be non-negative int64"})
+				return
+			}
+			windowDays = parsed
+		}
+		report, err := hub.Costs(r.Context(), service.CostsRequest{
+			Project:    strings.TrimSpace(query.Get("project")),
+			Workflow:   strings.TrimSpace(query.Get("workflow")),
+			Provider:   strings.TrimSpace(query.Get("provider")),
+			WindowDays: windowDays,
+			Bucket:     strings.TrimSpace(query.Get("bucket")),
+		})
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// retriesHandler serves GET /api/retries, the GetRetryReport RPC, filtered
+// by the project, workflow, model, window_days and min_success_rate query
+// parameters.
+func retriesHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		windowDays := int64(0)
+		if raw := strings.TrimSpace(query.Get("window_days")); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "window_days must be non-negative int64"})
+				return
+			}
+			windowDays = parsed
+		}
+		minSuccessRate := 0.0
+		if raw := strings.TrimSpace(query.Get("min_success_rate")); raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "min_success_rate must be a number"})
+				return
+			}
+			minSuccessRate = parsed
+		}
+		report, err := hub.RetryReport(r.Context(), service.RetryReportRequest{
+			Project:        strings.TrimSpace(query.Get("project")),
+			Workflow:       strings.TrimSpace(query.Get("workflow")),
+			Model:          strings.TrimSpace(query.Get("model")),
+			WindowDays:     windowDays,
+			MinSuccessRate: minSuccessRate,
+		})
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
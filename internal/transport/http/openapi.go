@@ -0,0 +1,286 @@
+package httpx
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// readRoutes are the dashboard's JSON routes, described in the OpenAPI
+// document next to writeRoutes.
+var readRoutes = []struct {
+	pattern  string
+	summary  string
+	query    []string
+	response any
+}{
+	{"GET /healthz", "Liveness check", nil, map[string]string{}},
+	{"GET /api/telemetry-summary", "Hub-wide telemetry totals", nil, domain.TelemetrySummary{}},
+	{"GET /api/policy", "Current orchestration policy", nil, domain.OrchestrationPolicy{}},
+	{"GET /api/policy-caps", "Policy caps", []string{"project"}, []domain.PolicyCap{}},
+	{"GET /api/leaderboard", "Prompt and model leaderboard", []string{"project", "workflow", "model", "prompt_version", "window_days", "limit"}, []domain.LeaderboardEntry{}},
+	{"GET /api/cost-series", "Daily cost series", []string{"project", "workflow", "window_days"}, []domain.CostSeriesPoint{}},
+	{"GET /api/timeseries", "Bucketed cost, attempts or success rate per workflow and model; bucket and window are durations such as 1h or 7d", []string{"project", "workflow", "model", "metric", "bucket", "window"}, service.TimeSeriesReport{}},
+	{"GET /api/runs", "Recent runs, newest first", []string{"project", "external_id", "workflow", "agent_id", "status", "prompt_version", "started_after", "started_before", "starred", "limit"}, []domain.AgentRun{}},
+	{"GET /api/runs/{id}", "One run with its attempts, events and artifacts (GetRun)", []string{"project", "include_archived"}, service.RunDetail{}},
+	{"GET /api/retries", "Retry effectiveness per workflow and first model (GetRetryReport)", []string{"project", "workflow", "model", "window_days", "min_success_rate"}, service.RetryReport{}},
+	{"GET /api/live-runs", "Running runs by last activity", []string{"project", "stale_after_seconds"}, liveRunsResponse{}},
+}
+
+// liveRunsResponse documents the /api/live-runs body.
+type liveRunsResponse struct {
+	Runs          []domain.LiveRun `json:"runs"`
+	CancelEnabled bool             `json:"cancel_enabled"`
+}
+
+// integerQuery, numberQuery and booleanQuery are the query parameters the
+// routes parse as integers, floats and booleans.
+var (
+	integerQuery = map[string]bool{"window_days": true, "limit": true, "stale_after_seconds": true}
+	numberQuery  = map[string]bool{"min_success_rate": true}
+	booleanQuery = map[string]bool{"include_archived": true, "starred": true}
+)
+
+// OpenAPIDocument describes the HTTP API as an OpenAPI 3 document: the write
+// routes with their request and response schemas, derived from the same Go
+// types the RPCs decode and return, and the dashboard's read routes. It is
+// served at /api/openapi.json and printed by modeloman-cli openapi.
+func OpenAPIDocument() map[string]any {
+	schemas := openAPISchemas{components: map[string]any{}, names: map[reflect.Type]string{}}
+	paths := map[string]map[string]any{}
+	operation := func(pattern string) (map[string]any, string) {
+		method, path, _ := strings.Cut(pattern, " ")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		op := map[string]any{}
+		paths[path][strings.ToLower(method)] = op
+		return op, path
+	}
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     jsonContent(map[string]any{"$ref": "#/components/schemas/Error"}),
+	}
+	// Dashboard routes need credentials only when the server sets
+	// HTTP_AUTH_TOKEN, HTTP_AUTH_AGENT_KEYS or HTTP_BASIC_AUTH_USER; the
+	// empty requirement says they may also be open.
+	dashboardSecurity := []map[string][]string{{"bearerAuth": {}}, {"tokenHeader": {}}, {"tokenCookie": {}}, {"basicAuth": {}}, {}}
+
+	for _, route := range writeRoutes {
+		op, path := operation(route.pattern)
+		name := strings.TrimPrefix(route.method, "/"+rpccontract.ServiceName+"/")
+		op["operationId"] = name
+		op["summary"] = "Calls " + name
+		op["tags"] = []string{"write"}
+		op["security"] = []map[string][]string{{"bearerAuth": {}}, {"tokenHeader": {}}}
+		parameters := []map[string]any{
+			headerParameter("x-modeloman-project", "Project for project-scoped keys"),
+			headerParameter("x-idempotency-key", "Replays the first response for a repeated key"),
+			headerParameter(rpccontract.APIVersionHeader, "API version the client speaks"),
+		}
+		if strings.Contains(path, "{id}") {
+			parameters = append(parameters, map[string]any{
+				"name": "id", "in": "path", "required": true,
+				"description": "Fills the " + route.pathField + " body field",
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		op["parameters"] = parameters
+		op["requestBody"] = map[string]any{"required": true, "content": jsonContent(schemas.of(reflect.TypeOf(route.request)))}
+		op["responses"] = map[string]any{
+			"200":     map[string]any{"description": "OK", "content": jsonContent(schemas.of(reflect.TypeOf(route.response)))},
+			"default": errorResponse,
+		}
+	}
+	for _, route := range readRoutes {
+		op, path := operation(route.pattern)
+		op["summary"] = route.summary
+		op["tags"] = []string{"dashboard"}
+		if strings.HasPrefix(path, "/api/") {
+			op["security"] = dashboardSecurity
+		}
+		var parameters []map[string]any
+		if strings.Contains(path, "{id}") {
+			parameters = append(parameters, map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		if len(route.query) > 0 {
+			for _, name := range route.query {
+				schema := map[string]any{"type": "string"}
+				if integerQuery[name] {
+					schema = map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+				} else if numberQuery[name] {
+					schema = map[string]any{"type": "number", "minimum": 0, "maximum": 1}
+				} else if booleanQuery[name] {
+					schema = map[string]any{"type": "boolean"}
+				}
+				parameters = append(parameters, map[string]any{"name": name, "in": "query", "schema": schema})
+			}
+		}
+		if len(parameters) > 0 {
+			op["parameters"] = parameters
+		}
+		op["responses"] = map[string]any{
+			"200":     map[string]any{"description": "OK", "content": jsonContent(schemas.of(reflect.TypeOf(route.response)))},
+			"default": errorResponse,
+		}
+	}
+
+	cancel, _ := operation("POST /api/runs/cancel")
+	cancel["summary"] = "Cancel a run from the dashboard (HTTP_ALLOW_RUN_CANCEL)"
+	cancel["tags"] = []string{"dashboard"}
+	cancel["security"] = dashboardSecurity
+	cancel["requestBody"] = map[string]any{"required": true, "content": jsonContent(schemas.of(reflect.TypeOf(service.CancelRunRequest{})))}
+	cancel["responses"] = map[string]any{
+		"200":     map[string]any{"description": "OK", "content": jsonContent(schemas.of(reflect.TypeOf(domain.AgentRun{})))},
+		"default": errorResponse,
+	}
+	stream, _ := operation("GET /api/events/stream")
+	stream["summary"] = "Server-sent live updates: run_event, attempt and run events"
+	stream["tags"] = []string{"dashboard"}
+	stream["security"] = dashboardSecurity
+	stream["parameters"] = []map[string]any{
+		{"name": "project", "in": "query", "schema": map[string]any{"type": "string"}},
+		{"name": "run_id", "in": "query", "schema": map[string]any{"type": "string"}},
+	}
+	stream["responses"] = map[string]any{
+		"200": map[string]any{"description": "Event stream; each data line is a LiveUpdate", "content": map[string]any{
+			"text/event-stream": map[string]any{"schema": schemas.of(reflect.TypeOf(service.LiveUpdate{}))},
+		}},
+	}
+	shared, _ := operation(sharedRunPattern)
+	shared["summary"] = "The run a share link names, read-only; the token is the credential and each access is audited"
+	shared["tags"] = []string{"dashboard"}
+	shared["security"] = []map[string][]string{}
+	shared["parameters"] = []map[string]any{{"name": "token", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}
+	shared["responses"] = map[string]any{
+		"200":     map[string]any{"description": "OK", "content": jsonContent(schemas.of(reflect.TypeOf(service.SharedRun{})))},
+		"default": errorResponse,
+	}
+	spec, _ := operation("GET /api/openapi.json")
+	spec["summary"] = "This document"
+	spec["tags"] = []string{"dashboard"}
+	spec["security"] = dashboardSecurity
+	spec["responses"] = map[string]any{"200": map[string]any{"description": "OpenAPI 3 document", "content": jsonContent(map[string]any{"type": "object"})}}
+
+	schemas.components["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+			"code":  map[string]any{"type": "string", "description": "gRPC status code name, on write routes"},
+		},
+		"required": []string{"error"},
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "ModeloMan HTTP API",
+			"version":     rpccontract.APIVersion,
+			"description": "JSON write API and dashboard read routes. Write routes run the gRPC method of the same name through the gRPC interceptor chain; dashboard routes take a dashboard token, an agent key with admin:read or basic auth when the server requires them. The /ws dashboard WebSocket is described in the README.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth":  map[string]any{"type": "http", "scheme": "bearer"},
+				"tokenHeader": map[string]any{"type": "apiKey", "in": "header", "name": "x-modeloman-token"},
+				"tokenCookie": map[string]any{"type": "apiKey", "in": "cookie", "name": authCookie},
+				"basicAuth":   map[string]any{"type": "http", "scheme": "basic"},
+			},
+		},
+	}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func headerParameter(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "header", "description": description, "schema": map[string]any{"type": "string"}}
+}
+
+// openAPISchemas turns Go types into JSON schemas the way encoding/json
+// would marshal them, collecting named structs as components.
+type openAPISchemas struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+func (s *openAPISchemas) of(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if _, ref := schema["$ref"]; ref {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+			if _, taken := s.components[name]; taken {
+				name = strings.ReplaceAll(t.String(), ".", "_")
+			}
+			// Record the name before recursing, so self-referencing types
+			// resolve to it and nested types cannot take it.
+			s.names[t] = name
+			s.components[name] = nil
+			s.components[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s *openAPISchemas) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	s.addFields(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (s *openAPISchemas) addFields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.addFields(field.Type, properties)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+	}
+}
+
+func openAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, OpenAPIDocument())
+}
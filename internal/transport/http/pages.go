@@ -0,0 +1,123 @@
+package httpx
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The dashboard pages are html/template files under web/templates, each
+// rendered inside layout.html, which holds the shared head, headline and
+// nav. CSS and JS every page uses are static files under web/static,
+// served at /static/.
+
+//go:embed web
+var webFS embed.FS
+
+// Dashboard is server-side configuration handed to the dashboard pages.
+type Dashboard struct {
+	// Title names the dashboard in page titles and headings; default
+	// "ModeloMan".
+	Title string
+	// RefreshInterval is how often the homepage polls its Live Runs panel
+	// besides the live updates; 0 turns the poll off.
+	RefreshInterval time.Duration
+}
+
+// dashboardConfig is Dashboard as page scripts see it, in dashboardConfig.
+type dashboardConfig struct {
+	Title          string `json:"title"`
+	RefreshSeconds int64  `json:"refresh_seconds"`
+}
+
+type navLink struct {
+	Path  string
+	Label string
+}
+
+var dashboardNav = []navLink{
+	{Path: "/", Label: "Leaderboard"},
+	{Path: "/runs", Label: "Runs"},
+	{Path: "/costs", Label: "Costs"},
+	{Path: "/policy", Label: "Policy"},
+}
+
+// page is one dashboard page: its template under web/templates and what
+// layout.html shows for it. Path is the nav entry marked current.
+type page struct {
+	template string
+	Title    string
+	Heading  string
+	Tagline  string
+	Path     string
+}
+
+var (
+	leaderboardPage = page{template: "leaderboard", Title: "Leaderboard", Heading: "Prompt Leaderboard", Path: "/",
+		Tagline: "Live runs, and prompt versions ranked by quality, cost, and latency."}
+	runsPage = page{template: "runs", Title: "Runs", Heading: "Runs", Path: "/runs",
+		Tagline: "Recent runs with status, cost and duration."}
+	runPage   = page{template: "run", Title: "Run"}
+	costsPage = page{template: "costs", Title: "Costs", Heading: "Costs", Path: "/costs",
+		Tagline: "Spend and tokens over time, by workflow and provider."}
+	policyPage = page{template: "policy", Title: "Policy", Heading: "Policy", Path: "/policy",
+		Tagline: "Kill switch, global limits and policy caps, for incident response without the CLI."}
+)
+
+var pageTemplates = parsePages(leaderboardPage, runsPage, runPage, costsPage, policyPage)
+
+func parsePages(pages ...page) map[string]*template.Template {
+	parsed := make(map[string]*template.Template, len(pages))
+	for _, p := range pages {
+		parsed[p.template] = template.Must(template.ParseFS(webFS, "web/templates/layout.html", "web/templates/"+p.template+".html"))
+	}
+	return parsed
+}
+
+type pageData struct {
+	page
+	Nav    []navLink
+	Config dashboardConfig
+}
+
+// pageHandler renders p with the dashboard configuration. Rendering into a
+// buffer first keeps a template error from sending half a page.
+func pageHandler(p page, dashboard Dashboard) http.HandlerFunc {
+	config := dashboardConfig{Title: strings.TrimSpace(dashboard.Title), RefreshSeconds: int64(dashboard.RefreshInterval / time.Second)}
+	if config.Title == "" {
+		config.Title = "ModeloMan"
+	}
+	data := pageData{page: p, Nav: dashboardNav, Config: config}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		if err := pageTemplates[p.template].ExecuteTemplate(&body, "layout", data); err != nil {
+			slog.ErrorContext(r.Context(), "dashboard page render failed", "page", p.template, "err", err)
+			http.Error(w, "dashboard page render failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(body.Bytes())
+	}
+}
+
+// staticHandler serves web/static under /static/, without directory
+// listings.
+func staticHandler() http.Handler {
+	web, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServerFS(web)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
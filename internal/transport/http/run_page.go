@@ -0,0 +1,44 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// runDetailHandler serves GET /api/runs/{id}, the GetRun aggregation.
+// include_archived=true adds attempts and events in cold storage.
+func runDetailHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		detail, err := hub.GetRun(r.Context(), service.GetRunRequest{
+			Project:         strings.TrimSpace(query.Get("project")),
+			RunID:           r.PathValue("id"),
+			IncludeArchived: strings.TrimSpace(query.Get("include_archived")) == "true",
+		})
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, detail)
+	}
+}
+
+// sharedRunHandler serves GET /api/shared-runs/{token}, the run a share
+// link names. The token is the credential, so dashboard auth does not apply.
+func sharedRunHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shared, err := hub.OpenRunShare(r.Context(), service.OpenRunShareRequest{
+			Token:      r.PathValue("token"),
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		})
+		w.Header().Set("Cache-Control", "no-store")
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, shared)
+	}
+}
@@ -0,0 +1,53 @@
+package httpx
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// runsHandler serves GET /api/runs, ListRuns filtered by the project,
+// external_id, workflow, agent_id, status, prompt_version, started_after,
+// started_before, starred and limit query parameters, newest first.
+func runsHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := int64(50)
+		if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "limit must be non-negative int64"})
+				return
+			}
+			limit = parsed
+		}
+		starred := false
+		if raw := strings.TrimSpace(query.Get("starred")); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "starred must be a boolean"})
+				return
+			}
+			starred = parsed
+		}
+		items, err := hub.ListRuns(r.Context(), service.ListRunsRequest{
+			Project:       strings.TrimSpace(query.Get("project")),
+			ExternalID:    strings.TrimSpace(query.Get("external_id")),
+			Workflow:      strings.TrimSpace(query.Get("workflow")),
+			AgentID:       strings.TrimSpace(query.Get("agent_id")),
+			Status:        strings.TrimSpace(query.Get("status")),
+			PromptVersion: strings.TrimSpace(query.Get("prompt_version")),
+			StartedAfter:  strings.TrimSpace(query.Get("started_after")),
+			StartedBefore: strings.TrimSpace(query.Get("started_before")),
+			Limit:         limit,
+			Starred:       starred,
+		})
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	}
+}
@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// streamKeepAlive is how often an idle event stream sends a comment, so
+// proxies and browsers do not time it out.
+const streamKeepAlive = 15 * time.Second
+
+// eventStreamHandler sends the hub's live updates as server-sent events: a
+// "run_event" event for each recorded run event, an "attempt" event for each
+// prompt attempt and a "run" event when a run starts or finishes, each with
+// the update as JSON data. project and run_id
+// query parameters narrow the stream. The stream ends when the client goes
+// away, the server shuts down (closing stop), or the client falls too far
+// behind; EventSource clients reconnect on their own. Updates missed while
+// disconnected are not replayed.
+func eventStreamHandler(hub *service.HubService, stop <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "streaming is not supported by this connection"})
+			return
+		}
+		query := r.URL.Query()
+		updates, unsubscribe := hub.SubscribeLive(service.LiveFilter{
+			Project: strings.ToLower(strings.TrimSpace(query.Get("project"))),
+			RunID:   strings.TrimSpace(query.Get("run_id")),
+		})
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, "retry: 3000\n: connected\n\n")
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-stop:
+				return
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case update, ok := <-updates:
+				if !ok {
+					slog.InfoContext(r.Context(), "event stream dropped a slow client", "remote_addr", r.RemoteAddr)
+					return
+				}
+				data, err := json.Marshal(update)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "event stream encode failed", "err", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Kind, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
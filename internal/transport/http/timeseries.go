@@ -0,0 +1,70 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/service"
+)
+
+// timeSeriesHandler serves GET /api/timeseries: metric=cost|attempts|
+// success_rate per workflow and model, in buckets of bucket (default 1h)
+// over the last window (default 7d).
+func timeSeriesHandler(hub *service.HubService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		bucket, err := parseSpan(query.Get("bucket"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "bucket " + err.Error()})
+			return
+		}
+		window, err := parseSpan(query.Get("window"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "window " + err.Error()})
+			return
+		}
+		report, err := hub.TimeSeries(r.Context(), service.TimeSeriesRequest{
+			Project:       strings.TrimSpace(query.Get("project")),
+			Workflow:      strings.TrimSpace(query.Get("workflow")),
+			Model:         strings.TrimSpace(query.Get("model")),
+			Metric:        strings.TrimSpace(query.Get("metric")),
+			BucketSeconds: int64(bucket / time.Second),
+			WindowSeconds: int64(window / time.Second),
+		})
+		if err != nil {
+			writeJSON(w, errorStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// parseSpan reads a whole number of seconds written as a Go duration
+// ("90m", "1h") or in days ("7d"); "" is 0, leaving the default.
+func parseSpan(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	var span time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		parsed, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || parsed <= 0 || parsed > 10000 {
+			return 0, fmt.Errorf("must be a duration such as 1h or 7d")
+		}
+		span = time.Duration(parsed) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("must be a duration such as 1h or 7d")
+		}
+		span = parsed
+	}
+	if span <= 0 || span%time.Second != 0 {
+		return 0, fmt.Errorf("must be a positive whole number of seconds")
+	}
+	return span, nil
+}
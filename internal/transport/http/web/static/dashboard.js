@@ -0,0 +1,158 @@
+// Helpers shared by the dashboard pages, loaded before each page's own
+// script, and the theme toggle behind #themeBtn.
+
+// When the dashboard APIs need a token, the first 401 asks for one and
+// keeps it in a same-site cookie, which the event stream and socket send
+// too, then reloads. Basic auth is left to the browser's own prompt.
+let signingIn = false;
+function signIn(payload) {
+  if (signingIn || !(payload.auth || []).includes("token")) return;
+  signingIn = true;
+  const token = (window.prompt("Dashboard token (or an agent key with the admin:read scope):", "") || "").trim();
+  if (!token) return;
+  document.cookie = "modeloman_token=" + encodeURIComponent(token) + "; path=/; SameSite=Strict" + (window.location.protocol === "https:" ? "; Secure" : "");
+  window.location.reload();
+}
+async function fetchJSON(url) {
+  const res = await fetch(url);
+  if (res.status === 401) signIn(await res.clone().json().catch(() => ({})));
+  if (!res.ok) throw new Error(await res.text());
+  return res.json();
+}
+function pct(v) { return (v * 100).toFixed(1) + "%"; }
+function usd(v) { return "$" + Number(v || 0).toFixed(4); }
+function ms(v) { return Number(v || 0).toFixed(1) + " ms"; }
+function ago(seconds) {
+  seconds = Math.max(0, Math.floor(Number(seconds || 0)));
+  if (seconds < 60) return seconds + "s";
+  if (seconds < 3600) return Math.floor(seconds / 60) + "m " + (seconds % 60) + "s";
+  return Math.floor(seconds / 3600) + "h " + Math.floor((seconds % 3600) / 60) + "m";
+}
+function cell(tr, text, cls) {
+  const td = document.createElement("td");
+  if (cls) td.className = cls;
+  td.textContent = text;
+  tr.appendChild(td);
+  return td;
+}
+// runCell links a run to its page, labelled with the run's name, if it
+// has one, above its ID; starred runs get a star.
+function runCell(tr, run) {
+  const td = cell(tr, "", "mono");
+  const link = document.createElement("a");
+  link.href = "/runs/" + encodeURIComponent(run.id);
+  link.textContent = (run.starred ? "\u2605 " : "") + (run.name || run.id);
+  link.title = run.description || run.id;
+  td.appendChild(link);
+  if (run.name) {
+    const id = document.createElement("div");
+    id.className = "run-id";
+    id.textContent = run.id;
+    td.appendChild(id);
+  }
+  return td;
+}
+
+// The operator key for the JSON write API lives in sessionStorage, so it
+// is forgotten with the tab.
+const operatorKeyStorage = "modeloman-operator-key";
+function operatorKey() { return sessionStorage.getItem(operatorKeyStorage) || ""; }
+// writeAPI calls the JSON write API with the operator key, forgetting a
+// key the server rejects.
+async function writeAPI(method, url, body) {
+  const res = await fetch(url, {
+    method: method,
+    headers: { "Content-Type": "application/json", "Authorization": "Bearer " + operatorKey() },
+    body: JSON.stringify(body),
+  });
+  const payload = await res.json().catch(() => ({}));
+  if (res.status === 401) sessionStorage.removeItem(operatorKeyStorage);
+  if (!res.ok) throw new Error(payload.error || res.statusText);
+  return payload;
+}
+
+const seriesColors = ["#4db6ff", "#54f2b2", "#ffca63", "#ff6b7d", "#b38cff", "#ff9d5c", "#5ce1e6", "#d6e35c"];
+const svgNS = "http://www.w3.org/2000/svg";
+
+function svgEl(tag, attrs) {
+  const el = document.createElementNS(svgNS, tag);
+  Object.entries(attrs).forEach(([k, v]) => el.setAttribute(k, v));
+  return el;
+}
+
+// stackedBars draws a bar per bucket into an svg.chart, stacked by
+// series, with a legend entry per series. values maps bucket to series
+// to value; format renders values and label a bucket's axis label.
+function stackedBars(chart, legend, buckets, values, format, label) {
+  chart.innerHTML = "";
+  legend.innerHTML = "";
+  const seriesKeys = [];
+  buckets.forEach((b) => Object.keys(values[b] || {}).forEach((key) => {
+    if (!seriesKeys.includes(key)) seriesKeys.push(key);
+  }));
+  seriesKeys.sort();
+
+  const width = 1000, height = 220, left = 56, bottom = 22, top = 8;
+  const plotH = height - bottom - top;
+  const maxBucket = Math.max(0, ...buckets.map((b) => Object.values(values[b] || {}).reduce((x, y) => x + y, 0)));
+  const scale = maxBucket > 0 ? plotH / maxBucket : 0;
+  const slot = (width - left) / Math.max(1, buckets.length);
+  const barW = Math.max(2, slot * 0.7);
+
+  chart.appendChild(svgEl("line", { x1: left, y1: top + plotH, x2: width, y2: top + plotH, class: "axis" }));
+  [0, 0.5, 1].forEach((f) => {
+    const y = top + plotH - plotH * f;
+    const text = svgEl("text", { x: left - 6, y: y + 3, "text-anchor": "end" });
+    text.textContent = format(maxBucket * f);
+    chart.appendChild(text);
+  });
+
+  buckets.forEach((b, i) => {
+    const x = left + i * slot + (slot - barW) / 2;
+    let y = top + plotH;
+    seriesKeys.forEach((key, s) => {
+      const value = (values[b] || {})[key] || 0;
+      if (value <= 0) return;
+      const h = value * scale;
+      y -= h;
+      const rect = svgEl("rect", { x: x, y: y, width: barW, height: h, fill: seriesColors[s % seriesColors.length] });
+      const title = svgEl("title", {});
+      title.textContent = b + " " + key + ": " + format(value);
+      rect.appendChild(title);
+      chart.appendChild(rect);
+    });
+    if (buckets.length <= 16 || i % Math.ceil(buckets.length / 12) === 0) {
+      const text = svgEl("text", { x: x + barW / 2, y: height - 6, "text-anchor": "middle" });
+      text.textContent = label(b);
+      chart.appendChild(text);
+    }
+  });
+
+  seriesKeys.forEach((key, s) => {
+    const item = document.createElement("span");
+    const swatch = document.createElement("i");
+    swatch.style.background = seriesColors[s % seriesColors.length];
+    item.appendChild(swatch);
+    item.appendChild(document.createTextNode(key));
+    legend.appendChild(item);
+  });
+}
+
+function currentTheme() {
+  const explicit = document.documentElement.dataset.theme;
+  if (explicit) return explicit;
+  return window.matchMedia && window.matchMedia("(prefers-color-scheme: light)").matches ? "light" : "dark";
+}
+function renderThemeButton() {
+  document.getElementById("themeBtn").textContent = currentTheme() === "light" ? "Dark mode" : "Light mode";
+}
+document.getElementById("themeBtn").addEventListener("click", () => {
+  const next = currentTheme() === "light" ? "dark" : "light";
+  document.documentElement.dataset.theme = next;
+  try { localStorage.setItem("modeloman-theme", next); } catch (err) {}
+  renderThemeButton();
+});
+if (window.matchMedia) {
+  window.matchMedia("(prefers-color-scheme: light)").addEventListener("change", renderThemeButton);
+}
+renderThemeButton();
@@ -0,0 +1,166 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/rpccontract"
+	"github.com/bcrosbie/modeloman/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxWriteBodyBytes matches the gRPC server's receive limit.
+const maxWriteBodyBytes = 1 << 20
+
+// Invoker runs a hub RPC in process. grpcx.LocalInvoker implements it with
+// the gRPC server's interceptor chain, so the write API is authenticated,
+// scoped, rate limited and idempotent exactly like gRPC.
+type Invoker interface {
+	Invoke(ctx context.Context, fullMethod string, request *structpb.Struct, md metadata.MD, remote net.Addr) (any, metadata.MD, error)
+}
+
+// writeRoutes maps the JSON write API onto hub RPCs. pathField names the
+// request field the {id} path value fills in; request and response are the
+// types the RPC decodes and returns, for the OpenAPI document.
+var writeRoutes = []struct {
+	pattern   string
+	method    string
+	pathField string
+	request   any
+	response  any
+}{
+	{"POST /api/tasks", rpccontract.MethodCreateTask, "", service.CreateTaskRequest{}, domain.Task{}},
+	{"PUT /api/tasks/{id}", rpccontract.MethodUpdateTask, "id", service.UpdateTaskRequest{}, domain.Task{}},
+	{"POST /api/runs", rpccontract.MethodStartRun, "", service.StartRunRequest{}, domain.AgentRun{}},
+	{"POST /api/runs/{id}/finish", rpccontract.MethodFinishRun, "run_id", service.FinishRunRequest{}, domain.AgentRun{}},
+	{"POST /api/runs/{id}/cancel", rpccontract.MethodCancelRun, "run_id", service.CancelRunRequest{}, domain.AgentRun{}},
+	{"POST /api/runs/{id}/star", rpccontract.MethodStarRun, "run_id", service.StarRunRequest{}, domain.AgentRun{}},
+	{"POST /api/runs/{id}/share", rpccontract.MethodShareRun, "run_id", service.ShareRunRequest{}, service.RunShare{}},
+	{"POST /api/attempts", rpccontract.MethodRecordPromptAttempt, "", service.RecordPromptAttemptRequest{}, domain.PromptAttempt{}},
+	{"POST /api/events", rpccontract.MethodRecordRunEvent, "", service.RecordRunEventRequest{}, domain.RunEvent{}},
+	{"POST /api/events/batch", rpccontract.MethodRecordRunEvents, "", service.RecordRunEventsRequest{}, service.RecordRunEventsResult{}},
+	{"PUT /api/policy", rpccontract.MethodSetPolicy, "", service.SetPolicyRequest{}, domain.OrchestrationPolicy{}},
+	{"POST /api/policy-caps", rpccontract.MethodUpsertPolicyCap, "", service.UpsertPolicyCapRequest{}, domain.PolicyCap{}},
+	{"PUT /api/policy-caps/{id}", rpccontract.MethodUpsertPolicyCap, "id", service.UpsertPolicyCapRequest{}, domain.PolicyCap{}},
+	{"DELETE /api/policy-caps/{id}", rpccontract.MethodDeletePolicyCap, "id", service.DeletePolicyCapRequest{}, map[string]bool{}},
+}
+
+// forwardedHeaders are the request headers passed to the RPC as metadata.
+var forwardedHeaders = []string{
+	"authorization",
+	"x-modeloman-token",
+	"x-modeloman-project",
+	"x-idempotency-key",
+	"x-request-id",
+	"x-modeloman-api-version",
+	"traceparent",
+	"tracestate",
+}
+
+func registerWriteRoutes(mux *http.ServeMux, invoker Invoker) {
+	for _, route := range writeRoutes {
+		mux.HandleFunc(route.pattern, writeHandler(invoker, route.method, route.pathField))
+	}
+}
+
+func writeHandler(invoker Invoker, method, pathField string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{"error": "content type must be application/json"})
+			return
+		}
+		body := map[string]any{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWriteBodyBytes)).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid JSON body: the request must be a JSON object"})
+			return
+		}
+		if pathField != "" {
+			id := r.PathValue("id")
+			if given, ok := body[pathField]; ok && given != id {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": pathField + " in the body does not match the path"})
+				return
+			}
+			body[pathField] = id
+		}
+		request, err := structpb.NewStruct(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+
+		md := metadata.MD{}
+		for _, name := range forwardedHeaders {
+			if values := r.Header.Values(name); len(values) > 0 {
+				md.Set(name, values...)
+			}
+		}
+		response, header, err := invoker.Invoke(r.Context(), method, request, md, remoteAddr(r.RemoteAddr))
+		for name, values := range header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		if err != nil {
+			st := status.Convert(err)
+			writeJSON(w, rpcStatus(st.Code()), map[string]any{"error": st.Message(), "code": st.Code().String()})
+			return
+		}
+		switch typed := response.(type) {
+		case *structpb.Struct:
+			writeJSON(w, http.StatusOK, typed.AsMap())
+		case *structpb.ListValue:
+			writeJSON(w, http.StatusOK, typed.AsSlice())
+		default:
+			writeJSON(w, http.StatusOK, typed)
+		}
+	}
+}
+
+// rpcStatus maps a gRPC status code to the closest HTTP status.
+func rpcStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// remoteAddr is the client address the rate limiter keys unauthenticated
+// calls on.
+type remoteAddr string
+
+func (a remoteAddr) Network() string {
+	return "tcp"
+}
+
+func (a remoteAddr) String() string {
+	return strings.TrimSpace(string(a))
+}
@@ -0,0 +1,311 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrosbie/modeloman/internal/domain"
+	"github.com/bcrosbie/modeloman/internal/service"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// wsDebounce folds a burst of writes into one recomputation.
+	wsDebounce = time.Second
+	// wsKeepAlive is how often an idle socket gets a ping message.
+	wsKeepAlive = 30 * time.Second
+	// wsWriteTimeout bounds each send, so a stalled client cannot hold the
+	// connection open past shutdown.
+	wsWriteTimeout = 10 * time.Second
+)
+
+// wsFilter is a connection's leaderboard filter. Clients set it with query
+// parameters on connect and may replace it by sending it as a JSON message.
+type wsFilter struct {
+	Workflow      string `json:"workflow"`
+	Model         string `json:"model"`
+	PromptVersion string `json:"prompt_version"`
+	WindowDays    int64  `json:"window_days"`
+	Limit         int64  `json:"limit"`
+}
+
+func (f wsFilter) normalized() (wsFilter, error) {
+	f.Workflow = strings.TrimSpace(f.Workflow)
+	f.Model = strings.TrimSpace(f.Model)
+	f.PromptVersion = strings.TrimSpace(f.PromptVersion)
+	if f.WindowDays < 0 {
+		return wsFilter{}, domain.InvalidArgument("window_days must be non-negative")
+	}
+	if f.Limit < 0 {
+		return wsFilter{}, domain.InvalidArgument("limit must be non-negative")
+	}
+	if f.Limit == 0 {
+		f.Limit = 20
+	}
+	return f, nil
+}
+
+// touches reports whether update can change the filter's leaderboard.
+func (f wsFilter) touches(update service.LiveUpdate) bool {
+	switch {
+	case update.Attempt != nil:
+		attempt := update.Attempt
+		return (f.Workflow == "" || f.Workflow == attempt.Workflow) &&
+			(f.Model == "" || f.Model == attempt.Model) &&
+			(f.PromptVersion == "" || f.PromptVersion == attempt.PromptVersion)
+	case update.Run != nil:
+		// Run outcomes feed success rates.
+		return f.Workflow == "" || f.Workflow == update.Run.Workflow
+	}
+	return false
+}
+
+func wsFilterFromQuery(query url.Values) (wsFilter, error) {
+	filter := wsFilter{
+		Workflow:      query.Get("workflow"),
+		Model:         query.Get("model"),
+		PromptVersion: query.Get("prompt_version"),
+	}
+	for name, target := range map[string]*int64{"window_days": &filter.WindowDays, "limit": &filter.Limit} {
+		if raw := strings.TrimSpace(query.Get(name)); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				return wsFilter{}, domain.InvalidArgument(name + " must be non-negative int64")
+			}
+			*target = parsed
+		}
+	}
+	return filter.normalized()
+}
+
+// wsMessage is one message sent to a dashboard socket.
+type wsMessage struct {
+	Type string `json:"type"`
+	// Summary is the full telemetry summary; Delta holds only the numbers
+	// that changed since the previous summary message, keyed by dotted JSON
+	// path such as "counts.attempts". The first summary has no delta.
+	Summary *domain.TelemetrySummary `json:"summary,omitempty"`
+	Delta   map[string]float64       `json:"delta,omitempty"`
+	// Filter and Entries are the connection's leaderboard.
+	Filter  *wsFilter                 `json:"filter,omitempty"`
+	Entries []domain.LeaderboardEntry `json:"entries,omitempty"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+// liveSocketHandler serves dashboards over a WebSocket: a "summary" message
+// with the telemetry summary and a "leaderboard" message for the
+// connection's filter on connect, then a summary with its delta whenever
+// attempts or runs change the numbers, and a new leaderboard whenever one
+// that touches the filter changes the ranking. Writes are folded into one
+// update a second. A project query parameter narrows which writes trigger
+// updates; the summary itself is hub-wide, as on /api/telemetry-summary.
+// Cross-origin browser connections are refused unless cors allows them.
+func liveSocketHandler(hub *service.HubService, stop <-chan struct{}, cors CORS) http.Handler {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			return originHandshake(config, r, cors)
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			serveLiveSocket(conn, hub, stop)
+		},
+	}
+}
+
+// originHandshake accepts clients that send no Origin (non-browsers),
+// browsers on the dashboard's own host and origins cors allows.
+func originHandshake(config *websocket.Config, r *http.Request, cors CORS) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil || (!strings.EqualFold(parsed.Host, r.Host) && cors.allowOrigin(origin) == "") {
+		return fmt.Errorf("cross-origin websocket from %q refused", origin)
+	}
+	config.Origin = parsed
+	return nil
+}
+
+func serveLiveSocket(conn *websocket.Conn, hub *service.HubService, stop <-chan struct{}) {
+	r := conn.Request()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	send := func(message wsMessage) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return websocket.JSON.Send(conn, message) == nil
+	}
+
+	filter, err := wsFilterFromQuery(r.URL.Query())
+	if err != nil {
+		send(wsMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	updates, unsubscribe := hub.SubscribeLive(service.LiveFilter{
+		Project: strings.ToLower(strings.TrimSpace(r.URL.Query().Get("project"))),
+	})
+	defer unsubscribe()
+
+	filters := make(chan wsFilter)
+	go func() {
+		defer cancel()
+		for {
+			var next wsFilter
+			if err := websocket.JSON.Receive(conn, &next); err != nil {
+				return
+			}
+			select {
+			case filters <- next:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var lastSummary map[string]float64
+	var lastEntries []domain.LeaderboardEntry
+	pushSummary := func() bool {
+		summary, err := hub.TelemetrySummary(ctx)
+		if err != nil {
+			return send(wsMessage{Type: "error", Error: err.Error()})
+		}
+		numbers := flattenNumbers(summary)
+		message := wsMessage{Type: "summary", Summary: &summary}
+		if lastSummary != nil {
+			message.Delta = numberDelta(lastSummary, numbers)
+			if len(message.Delta) == 0 {
+				return true
+			}
+		}
+		lastSummary = numbers
+		return send(message)
+	}
+	pushLeaderboard := func(force bool) bool {
+		entries, err := hub.Leaderboard(ctx, service.LeaderboardRequest{
+			Workflow:      filter.Workflow,
+			Model:         filter.Model,
+			PromptVersion: filter.PromptVersion,
+			WindowDays:    filter.WindowDays,
+			Limit:         filter.Limit,
+		})
+		if err != nil {
+			return send(wsMessage{Type: "error", Error: err.Error()})
+		}
+		if entries == nil {
+			entries = []domain.LeaderboardEntry{}
+		}
+		if !force && reflect.DeepEqual(entries, lastEntries) {
+			return true
+		}
+		lastEntries = entries
+		current := filter
+		return send(wsMessage{Type: "leaderboard", Filter: &current, Entries: entries})
+	}
+	if !pushSummary() || !pushLeaderboard(true) {
+		return
+	}
+
+	debounce := time.NewTimer(wsDebounce)
+	debounce.Stop()
+	pending, leaderboardDirty := false, false
+	keepAlive := time.NewTicker(wsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-keepAlive.C:
+			if !send(wsMessage{Type: "ping"}) {
+				return
+			}
+		case next := <-filters:
+			normalized, err := next.normalized()
+			if err != nil {
+				if !send(wsMessage{Type: "error", Error: err.Error()}) {
+					return
+				}
+				continue
+			}
+			filter = normalized
+			if !pushLeaderboard(true) {
+				return
+			}
+		case update, ok := <-updates:
+			if !ok {
+				slog.InfoContext(ctx, "dashboard socket dropped a slow client", "remote_addr", r.RemoteAddr)
+				return
+			}
+			if update.Kind == service.LiveRunEvent {
+				continue
+			}
+			leaderboardDirty = leaderboardDirty || filter.touches(update)
+			if !pending {
+				pending = true
+				debounce.Reset(wsDebounce)
+			}
+		case <-debounce.C:
+			pending = false
+			if !pushSummary() {
+				return
+			}
+			if leaderboardDirty {
+				leaderboardDirty = false
+				if !pushLeaderboard(false) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// flattenNumbers maps every number in value's JSON form to its dotted path.
+// Arrays are skipped; for the summary they are per-day breakdowns the
+// dashboard does not diff.
+func flattenNumbers(value any) map[string]float64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return map[string]float64{}
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return map[string]float64{}
+	}
+	numbers := map[string]float64{}
+	var walk func(prefix string, node any)
+	walk = func(prefix string, node any) {
+		switch typed := node.(type) {
+		case map[string]any:
+			for key, child := range typed {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, child)
+			}
+		case float64:
+			numbers[prefix] = typed
+		}
+	}
+	walk("", decoded)
+	return numbers
+}
+
+// numberDelta returns next minus previous for each number that changed.
+func numberDelta(previous, next map[string]float64) map[string]float64 {
+	delta := map[string]float64{}
+	for key, value := range next {
+		if diff := value - previous[key]; diff != 0 {
+			delta[key] = diff
+		}
+	}
+	return delta
+}
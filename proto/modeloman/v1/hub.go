@@ -0,0 +1,13 @@
+// Package modelomanv1 embeds the hub contract so the server can describe it
+// over gRPC reflection without generated code.
+package modelomanv1
+
+import _ "embed"
+
+// HubProto is the source of hub.proto.
+//
+//go:embed hub.proto
+var HubProto string
+
+// HubProtoPath is hub.proto's path relative to the buf module root.
+const HubProtoPath = "modeloman/v1/hub.proto"